@@ -16,7 +16,6 @@ ssh "${REMOTE_HOST}" "rm -rf ${REMOTE_DIR}/*"
 ssh "${REMOTE_HOST}" "mkdir -p ${REMOTE_DIR}"
 
 scp "${BUILD_DIR}/${BINARY_NAME}" "${REMOTE_HOST}:${REMOTE_DIR}/"
-scp -r web "${REMOTE_HOST}:${REMOTE_DIR}/"
 scp config.yaml "${REMOTE_HOST}:${REMOTE_DIR}/"
 
 ssh "${REMOTE_HOST}" "systemctl start linht-web"
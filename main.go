@@ -1,12 +1,23 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"embed"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
+	"net"
 	"os"
 	"os/signal"
+	"reflect"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -27,60 +38,165 @@ const (
 	MaxBodySize = 10 * 1024 * 1024 * 1024 // 10 GB
 )
 
+// Web UI compiled into the binary
+//
+//go:embed web
+var embeddedWeb embed.FS
+
 type Config struct {
 	Server struct {
-		Port string `yaml:"port"`
-		Host string `yaml:"host"`
+		Port        string `yaml:"port"`
+		Host        string `yaml:"host"`
+		ReadOnly    bool   `yaml:"read_only"`
+		WebDir      string `yaml:"web_dir"`
+		Compression string `yaml:"compression"`
+
+		CORS   plugins.CORSConfig    `yaml:"cors"`
+		Static []plugins.StaticMount `yaml:"static"`
 	} `yaml:"server"`
-	Docker struct {
-		Socket               string `yaml:"socket"`
-		ContainerStopTimeout int    `yaml:"container_stop_timeout"`
-		DefaultLogLines      string `yaml:"default_log_lines"`
-	} `yaml:"docker"`
-	WebShell struct {
-		Shell    string `yaml:"shell"`
-		Terminal struct {
-			Rows int `yaml:"rows"`
-			Cols int `yaml:"cols"`
-		} `yaml:"terminal"`
-	} `yaml:"webshell"`
-	FileManager struct {
-		MaxUploadSize int64 `yaml:"max_upload_size"`
-	} `yaml:"filemanager"`
-	Hardware struct {
-		SX1255 struct {
-			SPIDevice string `yaml:"spi_device"`
-			SPISpeed  uint32 `yaml:"spi_speed"`
-			GPIOChip  string `yaml:"gpio_chip"`
-			ResetPin  int    `yaml:"reset_pin"`
-			TxRxPin   int    `yaml:"tx_rx_pin"`
-			ClockFreq uint32 `yaml:"clock_freq"`
-		} `yaml:"sx1255"`
-	} `yaml:"hardware"`
-	CPS struct {
-		SettingsPath string `yaml:"settings_path"`
-	} `yaml:"cps"`
-	Services struct {
-		Prefix          string `yaml:"prefix"`
-		DefaultLogLines string `yaml:"default_log_lines"`
-	} `yaml:"services"`
-	Plugins []string `yaml:"plugins"`
-}
-
-var config Config
+	Logging struct {
+		Level      string `yaml:"level"`
+		Format     string `yaml:"format"`
+		File       string `yaml:"file"`
+		MaxSizeMB  int    `yaml:"max_size_mb"`
+		MaxBackups int    `yaml:"max_backups"`
+		Buffer     int    `yaml:"buffer"` // records kept in memory for /logs/recent
+
+		Syslog plugins.SyslogConfig `yaml:"syslog"`
+	} `yaml:"logging"`
+	Docker      plugins.DockerConfig      `yaml:"docker"`
+	WebShell    plugins.WebShellConfig    `yaml:"webshell"`
+	FileManager plugins.FileManagerConfig `yaml:"filemanager"`
+	Hardware    plugins.HardwareConfig    `yaml:"hardware"`
+	CPS         plugins.CPSConfig         `yaml:"cps"`
+	Services    plugins.ServicesConfig    `yaml:"services"`
+	Storage     plugins.StorageConfig     `yaml:"storage"`
+	GNSS        plugins.GNSSConfig        `yaml:"gnss"`
+	Power       plugins.PowerConfig       `yaml:"power"`
+	Processes   plugins.ProcessesConfig   `yaml:"processes"`
+	Packages    plugins.PackagesConfig    `yaml:"packages"`
+	WiFi        plugins.WiFiConfig        `yaml:"wifi"`
+	Firewall    plugins.FirewallConfig    `yaml:"firewall"`
+	WireGuard   plugins.WireGuardConfig   `yaml:"wireguard"`
+	DDNS        plugins.DDNSConfig        `yaml:"ddns"`
+	Proxy       plugins.ProxyConfig       `yaml:"proxy"`
+	Health      plugins.HealthConfig      `yaml:"health"`
+	MQTT        plugins.MQTTConfig        `yaml:"mqtt"`
+	SNMP        plugins.SNMPConfig        `yaml:"snmp"`
+	Webhooks    plugins.WebhooksConfig    `yaml:"webhooks"`
+	External    plugins.ExternalConfig    `yaml:"external"`
+	Backup      plugins.BackupConfig      `yaml:"backup"`
+	APRS        plugins.APRSConfig        `yaml:"aprs"`
+	Audio       plugins.AudioConfig       `yaml:"audio"`
+	Metrics     plugins.MetricsConfig     `yaml:"metrics"`
+	Dashboard   plugins.DashboardConfig   `yaml:"dashboard"`
+	Remotes     []plugins.RemoteConfig    `yaml:"remotes"`
+	Plugins     []string                  `yaml:"plugins"`
+}
+
+// Path of the configuration file
+const configPath = "config.yaml"
+
+// newConfig returns a Config holding the defaults for settings where zero is a
+// valid value; plugins fill in all other defaults themselves. The hardware
+// defaults come from the board profile selected in data.
+func newConfig(data []byte) (Config, error) {
+	var cfg Config
+	cfg.WebShell = plugins.DefaultWebShellConfig()
+
+	var board struct {
+		Hardware struct {
+			Board  string                 `yaml:"board"`
+			Boards []plugins.BoardProfile `yaml:"boards"`
+		} `yaml:"hardware"`
+	}
+	if err := yaml.Unmarshal(data, &board); err != nil {
+		return cfg, fmt.Errorf("failed to parse config: %w", err)
+	}
+	hardware, err := plugins.BoardHardwareConfig(board.Hardware.Board, board.Hardware.Boards)
+	cfg.Hardware = hardware
+	return cfg, err
+}
+
+// reloadableSettings lists config keys (or key prefixes ending in '.') that can
+// be applied at runtime; all other changes require a restart
+var reloadableSettings = []string{
+	"server.read_only",
+	"server.cors.",
+	"logging.level",
+	"docker.container_stop_timeout",
+	"docker.default_log_lines",
+	"docker.default_limits.",
+	"docker.registries",
+	"docker.arch_check",
+	"cps.",
+	"filemanager.",
+	"hardware.",
+	"services.",
+	"storage.",
+	"gnss.",
+	"power.",
+	"processes.",
+	"packages.",
+	"wifi.",
+	"firewall.",
+	"wireguard.",
+	"ddns.",
+	"proxy.",
+	"mqtt.",
+	"snmp.",
+	"webhooks.",
+	"backup.",
+	"aprs.",
+	"audio.",
+	"metrics.",
+	"dashboard.",
+	"remotes",
+}
+
+// ReloadResult reports the outcome of a configuration reload
+type ReloadResult struct {
+	Applied         []string          `json:"applied"`
+	RestartRequired []string          `json:"restart_required"`
+	Plugins         []string          `json:"plugins"`          // plugins that took the new settings
+	Failed          map[string]string `json:"failed,omitempty"` // plugins that rejected them, with the error
+}
+
+var (
+	config        Config
+	configMu      sync.Mutex
+	loadedPlugins = make(map[string]plugins.Plugin)
+	pluginOrder   []string              // load order, dependencies first
+	logLevel                            = new(slog.LevelVar)
+	logOutput     io.Writer             = os.Stdout
+	syslogClient  *plugins.SyslogClient // nil unless logging.syslog.address is set
+	logBuffer     *plugins.LogBuffer
+)
 
 func main() {
-	// Setup structured logging
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	}))
-	slog.SetDefault(logger)
+	// Setup default structured logging until the config is loaded
+	slog.SetDefault(slog.New(plugins.NewContextHandler(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: logLevel,
+	}))))
 
 	// Load configuration
-	if err := loadConfig("config.yaml"); err != nil {
+	if err := loadConfig(configPath); err != nil {
 		slog.Error("Failed to load config", "error", err)
 		os.Exit(1)
 	}
+
+	// Apply configured logging
+	logFile, err := setupLogging()
+	if err != nil {
+		slog.Error("Failed to setup logging", "error", err)
+		os.Exit(1)
+	}
+	if logFile != nil {
+		defer logFile.Close()
+	}
+	if syslogClient != nil {
+		defer syslogClient.Close()
+	}
 	slog.Info("Configuration loaded")
 
 	// Log server configuration
@@ -98,18 +214,58 @@ func main() {
 		BodyLimit:    MaxBodySize,
 	})
 
+	// Serve unversioned /api paths from the current API version
+	app.Use(plugins.LegacyAPIRewrite())
+
+	// Assign request IDs for log correlation
+	app.Use(plugins.RequestIDMiddleware())
+
+	// Send changing API requests to the syslog collector (logging.syslog.audit)
+	if syslogClient != nil {
+		app.Use(syslogClient.AuditMiddleware())
+	}
+
+	// Serve signed download links as the request they were minted for
+	app.Use(plugins.SignedLinkMiddleware())
+
 	// Add logger middleware
 	app.Use(fiberLogger.New(fiberLogger.Config{
-		Format: "[${time}] ${status} - ${method} ${path} (${latency})\n",
+		Output: logOutput,
+		Format: "[${time}] ${status} - ${method} ${path} (${latency}) request_id=${respHeader:" + plugins.RequestIDHeader + "}\n",
 	}))
 
+	// Compress text responses and answer conditional GETs
+	compression, err := plugins.CompressionMiddleware(config.Server.Compression)
+	if err != nil {
+		slog.Error("Invalid server settings", "error", err)
+		os.Exit(1)
+	}
+	app.Use(compression)
+	app.Use(plugins.ETagMiddleware())
+
+	// Let configured frontends on other origins call the API
+	if err := plugins.ConfigureCORS(config.Server.CORS); err != nil {
+		slog.Error("Invalid server settings", "error", err)
+		os.Exit(1)
+	}
+	app.Use(plugins.CORSMiddleware())
+
+	// Trim API responses to the fields a client asks for
+	app.Use(plugins.FieldsMiddleware())
+
+	// Refuse mutating requests in read-only mode
+	if config.Server.ReadOnly {
+		plugins.SetReadOnly(true, "configured")
+	}
+	app.Use(plugins.ReadOnlyMiddleware())
+
 	// Add memory tracking middleware for large file operations
 	app.Use(func(c *fiber.Ctx) error {
 		// Track memory for upload and import endpoints
-		if c.Path() == "/api/filemanager/upload" || c.Path() == "/api/images/import" {
+		if c.Path() == plugins.APIPath("/filemanager/upload") || c.Path() == plugins.APIPath("/images/import") {
 			var m runtime.MemStats
 			runtime.ReadMemStats(&m)
-			slog.Info("Request started",
+			slog.InfoContext(c.UserContext(), "Request started",
 				"path", c.Path(),
 				"method", c.Method(),
 				"content_length", c.Get("Content-Length"),
@@ -119,37 +275,107 @@ func main() {
 		return c.Next()
 	})
 
-	// Serve static files
-	app.Static("/", "./web")
-
-	// Create shared Docker client
-	dockerClient, err := createDockerClient(config.Docker.Socket)
+	// Serve the web UI
+	webFiles, err := fs.Sub(embeddedWeb, "web")
+	if err != nil {
+		slog.Error("Failed to open embedded web UI", "error", err)
+		os.Exit(1)
+	}
+	webUI, err := plugins.NewWebUI(webFiles, config.Server.WebDir)
 	if err != nil {
-		slog.Error("Failed to create Docker client", "error", err, "socket", config.Docker.Socket)
+		slog.Error("Failed to load web UI", "error", err)
 		os.Exit(1)
 	}
-	defer dockerClient.Close()
-	slog.Info("Docker client created", "socket", config.Docker.Socket)
+	webVersion := webUI.Version()
+	slog.Info("Web UI loaded", "source", webVersion.Source, "version", webVersion.Version, "files", webVersion.Files)
+	app.Use(webUI.Handler())
+	plugins.APIGroup(app, "/webui").Get("/version", webUI.HandleVersion)
 
-	// Initialize and register plugins
-	if err := initPlugins(app, dockerClient); err != nil {
+	// Serve site-specific web apps
+	if err := plugins.ValidateStaticMounts(config.Server.Static); err != nil {
+		slog.Error("Invalid server settings", "error", err)
+		os.Exit(1)
+	}
+	plugins.MountStatic(app, config.Server.Static)
+
+	// Initialize, register and start plugins
+	if err := initPlugins(app); err != nil {
 		slog.Error("Failed to initialize plugins", "error", err)
+		shutdownPlugins()
 		os.Exit(1)
 	}
 
+	// Event stream shared by all plugins
+	plugins.APIGroup(app, "").Get("/events", plugins.HandleEventStream)
+
+	// Read-only mode toggle
+	readOnlyAPI := plugins.APIGroup(app, "/readonly")
+	readOnlyAPI.Get("/", plugins.HandleReadOnlyStatus)
+	readOnlyAPI.Put("/", plugins.HandleReadOnlyToggle)
+
+	// Temporary download links
+	linksAPI := plugins.APIGroup(app, "/links")
+	linksAPI.Get("/", plugins.HandleSignedLinkTargets)
+	linksAPI.Post("/", plugins.HandleCreateSignedLink)
+
+	// Long-running operations
+	jobsAPI := plugins.APIGroup(app, "/jobs")
+	jobsAPI.Get("/", plugins.HandleListJobs)
+	jobsAPI.Get("/:id", plugins.HandleGetJob)
+	jobsAPI.Get("/:id/events", plugins.HandleJobEvents)
+	jobsAPI.Post("/:id/cancel", plugins.HandleCancelJob)
+	jobsAPI.Delete("/:id", plugins.HandleDeleteJob)
+
+	// Config reload endpoint
+	plugins.APIGroup(app, "/config").Post("/reload", func(c *fiber.Ctx) error {
+		result, err := reloadConfig()
+		if err != nil && result == nil {
+			return plugins.SendError(c, 500, err)
+		}
+		if err != nil {
+			// A partial reload still reports which plugins took the new settings
+			return c.Status(500).JSON(plugins.APIResponse{
+				Success: false,
+				Data:    result,
+				Error:   err.Error(),
+			})
+		}
+		return plugins.SendSuccess(c, result, "Configuration reloaded")
+	})
+
+	// Reload configuration on SIGHUP
+	go func() {
+		hupChan := make(chan os.Signal, 1)
+		signal.Notify(hupChan, syscall.SIGHUP)
+		for range hupChan {
+			slog.Info("SIGHUP received, reloading configuration")
+			if _, err := reloadConfig(); err != nil {
+				slog.Error("Failed to reload configuration", "error", err)
+			}
+		}
+	}()
+
 	// Start server with graceful shutdown
 	addr := config.Server.Host + ":" + config.Server.Port
 
 	// Setup graceful shutdown
+	shutdownDone := make(chan struct{})
 	go func() {
+		defer close(shutdownDone)
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 		<-sigChan
 
 		slog.Info("Shutting down server...")
+		// Stop running jobs first; requests waiting for them would hold up the shutdown
+		jobsCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		plugins.CancelJobs(jobsCtx)
+		cancel()
 		if err := app.ShutdownWithContext(context.Background()); err != nil {
 			slog.Error("Server shutdown error", "error", err)
 		}
+
+		shutdownPlugins()
 	}()
 
 	slog.Info("Starting Linht Web Manager", "address", addr)
@@ -157,6 +383,67 @@ func main() {
 		slog.Error("Failed to start server", "error", err, "address", addr)
 		os.Exit(1)
 	}
+	// Listen returns as soon as the server is shut down; wait for the plugins
+	<-shutdownDone
+}
+
+// setupLogging configures the default logger from the logging config
+// Returns the rotating log file when file output is enabled
+func setupLogging() (*plugins.RotatingFile, error) {
+	if err := logLevel.UnmarshalText([]byte(defaultString(config.Logging.Level, "info"))); err != nil {
+		return nil, fmt.Errorf("invalid logging.level: %w", err)
+	}
+
+	var logFile *plugins.RotatingFile
+	if config.Logging.File != "" {
+		maxSizeMB := config.Logging.MaxSizeMB
+		if maxSizeMB <= 0 {
+			maxSizeMB = 10
+		}
+
+		var err error
+		logFile, err = plugins.NewRotatingFile(config.Logging.File, int64(maxSizeMB)*1024*1024, config.Logging.MaxBackups)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		logOutput = io.MultiWriter(os.Stdout, logFile)
+	}
+
+	options := &slog.HandlerOptions{Level: logLevel}
+	var handler slog.Handler
+	switch defaultString(config.Logging.Format, "text") {
+	case "text":
+		handler = slog.NewTextHandler(logOutput, options)
+	case "json":
+		handler = slog.NewJSONHandler(logOutput, options)
+	default:
+		return nil, fmt.Errorf("invalid logging.format %q (use text or json)", config.Logging.Format)
+	}
+
+	// Keep recent records for the log viewer
+	logBuffer = plugins.NewLogBuffer(config.Logging.Buffer)
+	handler = plugins.NewMultiHandler(handler, logBuffer.Handler(logLevel))
+
+	// Forward to a syslog collector at its own level
+	if config.Logging.Syslog.Address != "" {
+		var err error
+		syslogClient, err = plugins.NewSyslogClient(config.Logging.Syslog)
+		if err != nil {
+			return nil, err
+		}
+		handler = plugins.NewMultiHandler(handler, syslogClient.Handler())
+	}
+
+	slog.SetDefault(slog.New(plugins.NewContextHandler(handler)))
+	return logFile, nil
+}
+
+// defaultString returns value, or fallback when value is empty
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
 }
 
 func loadConfig(path string) error {
@@ -164,75 +451,379 @@ func loadConfig(path string) error {
 	if err != nil {
 		return err
 	}
+	config, err = newConfig(data)
+	if err != nil {
+		return err
+	}
 	return yaml.Unmarshal(data, &config)
 }
 
-func createDockerClient(socket string) (*client.Client, error) {
-	cli, err := client.NewClientWithOpts(
-		client.WithHost(socket),
-		client.WithAPIVersionNegotiation(),
-	)
+// reloadConfig re-reads the config file and applies runtime-safe changes to loaded plugins
+// The new file is validated before anything is changed. A plugin that still rejects its
+// settings does not stop the others; the result then lists which plugins were reloaded.
+func reloadConfig() (*ReloadResult, error) {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	data, err := os.ReadFile(configPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := validateConfig(data); err != nil {
+		return nil, fmt.Errorf("invalid config, nothing reloaded: %w", err)
 	}
-	return cli, nil
-}
 
-func initPlugins(app *fiber.App, dockerClient *client.Client) error {
-	for _, name := range config.Plugins {
-		factory, exists := plugins.Get(name)
-		if !exists {
-			slog.Warn("Unknown plugin", "name", name)
-			continue
+	updated, err := newConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &updated); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	result := &ReloadResult{
+		Applied:         []string{},
+		RestartRequired: []string{},
+		Plugins:         []string{},
+	}
+	for _, key := range diffConfig(config, updated) {
+		if isReloadable(key) {
+			result.Applied = append(result.Applied, key)
+		} else {
+			result.RestartRequired = append(result.RestartRequired, key)
 		}
+	}
 
-		// Get plugin-specific config
-		var pluginConfig interface{}
-		switch name {
-		case "docker":
-			pluginConfig = map[string]interface{}{
-				"client":                 dockerClient,
-				"container_stop_timeout": config.Docker.ContainerStopTimeout,
-				"default_log_lines":      config.Docker.DefaultLogLines,
-			}
-		case "webshell":
-			pluginConfig = map[string]interface{}{
-				"client": dockerClient,
-				"shell":  config.WebShell.Shell,
-			}
-		case "filemanager":
-			pluginConfig = map[string]interface{}{
-				"max_upload_size": config.FileManager.MaxUploadSize,
-			}
-		case "hardware":
-			pluginConfig = map[string]interface{}{
-				"sx1255": map[string]interface{}{
-					"spi_device": config.Hardware.SX1255.SPIDevice,
-					"spi_speed":  config.Hardware.SX1255.SPISpeed,
-					"gpio_chip":  config.Hardware.SX1255.GPIOChip,
-					"reset_pin":  config.Hardware.SX1255.ResetPin,
-					"tx_rx_pin":  config.Hardware.SX1255.TxRxPin,
-					"clock_freq": config.Hardware.SX1255.ClockFreq,
-				},
+	// A changed read_only setting overrides the runtime toggle
+	if updated.Server.ReadOnly != config.Server.ReadOnly {
+		plugins.SetReadOnly(updated.Server.ReadOnly, "configured")
+	}
+
+	config = updated
+
+	if err := logLevel.UnmarshalText([]byte(defaultString(config.Logging.Level, "info"))); err != nil {
+		slog.Warn("Invalid logging.level, keeping current level", "error", err)
+	}
+	if err := plugins.ConfigureCORS(config.Server.CORS); err != nil {
+		slog.Warn("Invalid CORS settings, keeping current settings", "error", err)
+	}
+
+	for _, name := range pluginOrder {
+		reloadable, ok := loadedPlugins[name].(plugins.Reloadable)
+		if !ok {
+			continue
+		}
+		if err := reloadable.Reload(pluginConfig(&config, name)); err != nil {
+			if result.Failed == nil {
+				result.Failed = map[string]string{}
 			}
-		case "cps":
-			pluginConfig = map[string]interface{}{
-				"settings_path": config.CPS.SettingsPath,
+			result.Failed[name] = err.Error()
+			slog.Error("Failed to reload plugin", "plugin", name, "error", err)
+			continue
+		}
+		result.Plugins = append(result.Plugins, name)
+	}
+
+	if len(result.Failed) > 0 {
+		failed := make([]string, 0, len(result.Failed))
+		for name := range result.Failed {
+			failed = append(failed, name)
+		}
+		sort.Strings(failed)
+		return result, fmt.Errorf("configuration partially reloaded, failed plugins: %s", strings.Join(failed, ", "))
+	}
+
+	slog.Info("Configuration reloaded",
+		"applied", result.Applied,
+		"restart_required", result.RestartRequired)
+	return result, nil
+}
+
+// validateConfig checks that config file contents decode cleanly and are usable
+func validateConfig(data []byte) error {
+	updated, err := newConfig(data)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&updated); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	if updated.Server.Port == "" {
+		return errors.New("server.port is required")
+	}
+	if _, err := plugins.CompressionMiddleware(updated.Server.Compression); err != nil {
+		return err
+	}
+	if err := updated.Server.CORS.Validate(); err != nil {
+		return err
+	}
+	if err := plugins.ValidateStaticMounts(updated.Server.Static); err != nil {
+		return err
+	}
+	if err := updated.Logging.Syslog.Validate(); err != nil {
+		return err
+	}
+	if err := plugins.ValidateRemotes(updated.Remotes); err != nil {
+		return err
+	}
+	order, err := plugins.LoadOrder(updated.Plugins)
+	if err != nil {
+		return err
+	}
+	for _, name := range order {
+		if validator, ok := pluginConfig(&updated, name).(plugins.ConfigValidator); ok {
+			if err := validator.Validate(); err != nil {
+				return fmt.Errorf("invalid %s settings: %w", name, err)
 			}
-		case "services":
-			pluginConfig = map[string]interface{}{
-				"prefix":            config.Services.Prefix,
-				"default_log_lines": config.Services.DefaultLogLines,
+		}
+	}
+	return nil
+}
+
+// isReloadable reports whether a changed config key can be applied at runtime
+func isReloadable(key string) bool {
+	for _, setting := range reloadableSettings {
+		if key == setting || (strings.HasSuffix(setting, ".") && strings.HasPrefix(key, setting)) {
+			return true
+		}
+	}
+	return false
+}
+
+// diffConfig returns the sorted dotted keys whose values differ between two configs
+func diffConfig(oldConfig, newConfig Config) []string {
+	oldValues := flattenConfig(oldConfig)
+	newValues := flattenConfig(newConfig)
+
+	changed := []string{}
+	for key, value := range newValues {
+		if !reflect.DeepEqual(oldValues[key], value) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range oldValues {
+		if _, exists := newValues[key]; !exists {
+			changed = append(changed, key)
+		}
+	}
+
+	sort.Strings(changed)
+	return changed
+}
+
+// flattenConfig converts a config into a map of dotted keys to leaf values
+func flattenConfig(cfg Config) map[string]interface{} {
+	result := make(map[string]interface{})
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return result
+	}
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return result
+	}
+
+	var walk func(prefix string, value interface{})
+	walk = func(prefix string, value interface{}) {
+		if m, ok := value.(map[string]interface{}); ok {
+			for key, child := range m {
+				walk(prefix+key+".", child)
 			}
+			return
 		}
+		result[strings.TrimSuffix(prefix, ".")] = value
+	}
+	walk("", tree)
+
+	return result
+}
+
+// initPlugins initializes the enabled plugins and their dependencies in
+// dependency order, registers their routes and then starts them
+func initPlugins(app *fiber.App) error {
+	enabled := []string{}
+	for _, name := range config.Plugins {
+		if _, exists := plugins.Get(name); !exists {
+			slog.Warn("Unknown plugin", "name", name)
+			continue
+		}
+		enabled = append(enabled, name)
+	}
+
+	order, err := plugins.LoadOrder(enabled)
+	if err != nil {
+		return err
+	}
 
-		plugin, err := factory(pluginConfig)
+	for _, name := range order {
+		factory, _ := plugins.Get(name)
+		plugin, err := factory(pluginConfig(&config, name))
 		if err != nil {
-			return err
+			return fmt.Errorf("plugin %s: %w", name, err)
 		}
 
 		plugin.RegisterRoutes(app)
-		slog.Info("Plugin loaded", "name", plugin.Name())
+		loadedPlugins[name] = plugin
+		pluginOrder = append(pluginOrder, name)
+		slog.Info("Plugin loaded", "name", plugin.Name(), "depends_on", plugins.Dependencies(name))
+	}
+
+	for _, name := range pluginOrder {
+		starter, ok := loadedPlugins[name].(plugins.Starter)
+		if !ok {
+			continue
+		}
+		if err := starter.Start(); err != nil {
+			return fmt.Errorf("failed to start plugin %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// shutdownPlugins stops the loaded plugins in reverse dependency order
+func shutdownPlugins() {
+	for i := len(pluginOrder) - 1; i >= 0; i-- {
+		name := pluginOrder[i]
+		if err := loadedPlugins[name].Shutdown(); err != nil {
+			slog.Error("Plugin shutdown error", "plugin", name, "error", err)
+		}
+	}
+}
+
+// localAPIURL returns the base URL at which local processes reach the API
+func localAPIURL(cfg *Config) string {
+	host := cfg.Server.Host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, cfg.Server.Port) + plugins.APIPath("")
+}
+
+// sharedDockerClient returns the client of the docker client service, or nil
+// when no loaded plugin depends on it
+func sharedDockerClient() *client.Client {
+	if service, ok := loadedPlugins["dockerclient"].(*plugins.DockerClientService); ok {
+		return service.Client()
+	}
+	return nil
+}
+
+// pluginConfig returns the typed config struct for a plugin, adding shared
+// resources and settings the plugin takes from other sections
+func pluginConfig(cfg *Config, name string) interface{} {
+	dockerClient := sharedDockerClient()
+	switch name {
+	case "dockerclient":
+		return cfg.Docker
+	case "docker":
+		dockerConfig := cfg.Docker
+		dockerConfig.Client = dockerClient
+		return dockerConfig
+	case "webshell":
+		webshellConfig := cfg.WebShell
+		webshellConfig.Client = dockerClient
+		return webshellConfig
+	case "filemanager":
+		fileManagerConfig := cfg.FileManager
+		fileManagerConfig.Remotes = cfg.Remotes
+		return fileManagerConfig
+	case "hardware":
+		return cfg.Hardware
+	case "cps":
+		return cfg.CPS
+	case "services":
+		return cfg.Services
+	case "storage":
+		return cfg.Storage
+	case "gnss":
+		return cfg.GNSS
+	case "power":
+		return cfg.Power
+	case "processes":
+		return cfg.Processes
+	case "packages":
+		return cfg.Packages
+	case "wifi":
+		return cfg.WiFi
+	case "firewall":
+		firewallConfig := cfg.Firewall
+		firewallConfig.ManagerPort, _ = strconv.Atoi(cfg.Server.Port)
+		return firewallConfig
+	case "wireguard":
+		return cfg.WireGuard
+	case "ddns":
+		return cfg.DDNS
+	case "proxy":
+		proxyConfig := cfg.Proxy
+		proxyConfig.DockerClient = dockerClient
+		return proxyConfig
+	case "health":
+		healthConfig := cfg.Health
+		healthConfig.DockerClient = dockerClient
+		healthConfig.SPIDevice = cfg.Hardware.SX1255.SPIDevice
+		healthConfig.GPIOChip = cfg.Hardware.SX1255.GPIOChip
+		healthConfig.SettingsPath = cfg.CPS.SettingsPath
+		return healthConfig
+	case "mqtt":
+		mqttConfig := cfg.MQTT
+		mqttConfig.DockerClient = dockerClient
+		mqttConfig.ContainerStopTimeout = cfg.Docker.ContainerStopTimeout
+		mqttConfig.ServicePrefix = cfg.Services.Prefix
+		return mqttConfig
+	case "snmp":
+		snmpConfig := cfg.SNMP
+		snmpConfig.DockerClient = dockerClient
+		if len(snmpConfig.DiskPaths) == 0 {
+			snmpConfig.DiskPaths = cfg.Health.DiskPaths
+		}
+		return snmpConfig
+	case "webhooks":
+		return cfg.Webhooks
+	case "external":
+		externalConfig := cfg.External
+		externalConfig.APIURL = localAPIURL(cfg)
+		return externalConfig
+	case "backup":
+		backupConfig := cfg.Backup
+		backupConfig.Remotes = cfg.Remotes
+		backupConfig.DockerClient = dockerClient
+		return backupConfig
+	case "aprs":
+		return cfg.APRS
+	case "audio":
+		return cfg.Audio
+	case "metrics":
+		metricsConfig := cfg.Metrics
+		metricsConfig.DockerClient = dockerClient
+		if len(metricsConfig.DiskPaths) == 0 {
+			metricsConfig.DiskPaths = cfg.Health.DiskPaths
+		}
+		return metricsConfig
+	case "dashboard":
+		dashboardConfig := cfg.Dashboard
+		dashboardConfig.DockerClient = dockerClient
+		dashboardConfig.ServicePrefix = cfg.Services.Prefix
+		dashboardConfig.DiskPaths = cfg.Health.DiskPaths
+		return dashboardConfig
+	case "logs":
+		return plugins.LogsConfig{File: cfg.Logging.File, Buffer: logBuffer}
+	case "config":
+		return plugins.ConfigPluginConfig{
+			Path:     configPath,
+			Validate: validateConfig,
+			Reload: func() (interface{}, error) {
+				return reloadConfig()
+			},
+			Effective: func() interface{} {
+				configMu.Lock()
+				defer configMu.Unlock()
+				return config
+			},
+		}
 	}
 	return nil
 }
@@ -0,0 +1,46 @@
+package plugins
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// APIVersion is the current API version
+// Unversioned /api requests are served by this version
+const APIVersion = "v1"
+
+// versionSegment matches an API version path segment such as "v1"
+var versionSegment = regexp.MustCompile(`^v[0-9]+$`)
+
+// APIGroup returns a route group for the current API version
+func APIGroup(app *fiber.App, prefix string) fiber.Router {
+	return VersionedGroup(app, APIVersion, prefix)
+}
+
+// VersionedGroup returns a route group for a specific API version
+// Use this to keep serving an old response shape after a breaking change
+func VersionedGroup(app *fiber.App, version string, prefix string) fiber.Router {
+	return app.Group("/api/" + version + prefix)
+}
+
+// APIPath returns the versioned path for an unversioned API path
+func APIPath(path string) string {
+	return "/api/" + APIVersion + path
+}
+
+// LegacyAPIRewrite maps unversioned /api paths onto the current API version
+// Must be registered before any routes
+func LegacyAPIRewrite() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		path := c.Path()
+		if rest, ok := strings.CutPrefix(path, "/api"); ok && (rest == "" || rest[0] == '/') {
+			segment, _, _ := strings.Cut(strings.TrimPrefix(rest, "/"), "/")
+			if !versionSegment.MatchString(segment) {
+				c.Path(APIPath(rest))
+			}
+		}
+		return c.Next()
+	}
+}
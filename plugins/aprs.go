@@ -0,0 +1,704 @@
+package plugins
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// APRS sources
+const (
+	APRSSourceKISS = "kiss" // Direwolf KISS TCP port
+	APRSSourceLog  = "log"  // aprx rf log
+)
+
+// APRS defaults
+const (
+	DefaultAPRSKISSAddress = "127.0.0.1:8001"
+	DefaultAPRSLogFile     = "/var/log/aprx/aprx-rf.log"
+	DefaultAPRSHistory     = 200
+	maxAPRSHistory         = 10000
+	aprsMaxStations        = 1000
+	aprsReconnectDelay     = 5 * time.Second
+	aprsLogPollInterval    = time.Second
+	aprsStreamMinInterval  = 250 * time.Millisecond
+	aprxIgatePort          = "APRSIS"
+	aprsConnectedEvent     = "aprs.connected"
+	aprsDisconnectedEvent  = "aprs.disconnected"
+	aprsEventSource        = "aprs"
+)
+
+// APRSConfig holds APRS plugin configuration
+type APRSConfig struct {
+	Source      string `yaml:"source"`       // kiss or log
+	KISSAddress string `yaml:"kiss_address"` // host:port of the Direwolf KISS TCP port
+	LogFile     string `yaml:"log_file"`     // aprx rf log, followed like tail -f
+	Callsign    string `yaml:"callsign"`     // own digipeater/igate call; tx packets from other stations are digipeats
+	History     int    `yaml:"history"`      // packets kept for GET /aprs/packets
+}
+
+// APRSPortStats counts the packets of one KISS port or aprx interface
+type APRSPortStats struct {
+	Received    int `json:"received"`
+	Transmitted int `json:"transmitted"`
+}
+
+// APRSStats are the packet counters since the plugin started
+type APRSStats struct {
+	Since         time.Time                 `json:"since"`
+	Received      int                       `json:"received"`
+	Transmitted   int                       `json:"transmitted"`
+	Digipeated    int                       `json:"digipeated"`     // tx packets relayed for other stations
+	Own           int                       `json:"own"`            // tx packets originated by the station (beacons, igated messages)
+	Direct        int                       `json:"direct"`         // rx packets heard directly
+	ViaDigipeater int                       `json:"via_digipeater"` // rx packets heard through a digipeater
+	Undecodable   int                       `json:"undecodable"`
+	LastPacket    *time.Time                `json:"last_packet"`
+	Ports         map[string]*APRSPortStats `json:"ports"`
+	Digipeaters   map[string]int            `json:"digipeaters"` // rx packets per relaying station
+	Types         map[string]int            `json:"types"`
+}
+
+// APRSStation is a station heard on RF
+type APRSStation struct {
+	Call       string    `json:"call"`
+	Packets    int       `json:"packets"`
+	LastHeard  time.Time `json:"last_heard"`
+	LastType   string    `json:"last_type"`
+	Digipeater string    `json:"digipeater,omitempty"` // relay of the last packet, empty when heard directly
+}
+
+// APRSPlugin follows a Direwolf or aprx instance and keeps packet history and statistics
+type APRSPlugin struct {
+	mu        sync.RWMutex
+	config    APRSConfig
+	connected bool
+	lastError string
+	packets   []APRSPacket
+	seq       uint64
+	stats     APRSStats
+	stations  map[string]*APRSStation
+	// Closed and replaced on every packet to wake up streams
+	updated chan struct{}
+
+	stopChan chan struct{}
+	doneChan chan struct{}
+	started  bool // the reader runs, set by Start
+}
+
+// NewAPRSPlugin creates a new APRS plugin instance
+func NewAPRSPlugin(cfg APRSConfig) (*APRSPlugin, error) {
+	cfg = normalizeAPRSConfig(cfg)
+	if err := validateAPRSConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	return &APRSPlugin{
+		config:   cfg,
+		packets:  []APRSPacket{},
+		stats:    newAPRSStats(),
+		stations: make(map[string]*APRSStation),
+		updated:  make(chan struct{}),
+	}, nil
+}
+
+// Start begins reading from the source
+func (p *APRSPlugin) Start() error {
+	p.mu.Lock()
+	p.started = true
+	p.mu.Unlock()
+
+	p.start()
+	return nil
+}
+
+// newAPRSStats returns empty counters starting now
+func newAPRSStats() APRSStats {
+	return APRSStats{
+		Since:       time.Now(),
+		Ports:       make(map[string]*APRSPortStats),
+		Digipeaters: make(map[string]int),
+		Types:       make(map[string]int),
+	}
+}
+
+// Name returns the plugin identifier
+func (p *APRSPlugin) Name() string {
+	return "aprs"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *APRSPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/aprs")
+
+	api.Get("/status", p.handleStatus)
+	api.Get("/packets", p.handlePackets)
+	api.Get("/stats", p.handleStats)
+	api.Post("/stats/reset", p.handleResetStats)
+	api.Get("/stations", p.handleStations)
+	api.Get("/stream", p.handleStream)
+}
+
+// Shutdown stops the reader
+func (p *APRSPlugin) Shutdown() error {
+	p.stop()
+	return nil
+}
+
+// Reload restarts the reader when the source settings change
+// History and statistics are kept.
+func (p *APRSPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[APRSConfig]("aprs", config)
+	if err != nil {
+		return err
+	}
+	cfg = normalizeAPRSConfig(cfg)
+	if err := validateAPRSConfig(cfg); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	previous := p.config
+	changed := cfg.Source != previous.Source || cfg.KISSAddress != previous.KISSAddress || cfg.LogFile != previous.LogFile
+	p.config = cfg
+	if len(p.packets) > cfg.History {
+		p.packets = append([]APRSPacket{}, p.packets[len(p.packets)-cfg.History:]...)
+	}
+	started := p.started
+	p.mu.Unlock()
+
+	if changed && started {
+		p.stop()
+		p.start()
+	}
+
+	slog.Info("APRS config reloaded",
+		"source", cfg.Source,
+		"kiss_address", cfg.KISSAddress,
+		"log_file", cfg.LogFile,
+		"callsign", cfg.Callsign,
+		"restarted", changed)
+	return nil
+}
+
+// start launches the reader goroutine
+func (p *APRSPlugin) start() {
+	p.stopChan = make(chan struct{})
+	p.doneChan = make(chan struct{})
+
+	cfg := p.getConfig()
+	slog.Info("APRS reader started", "source", cfg.Source)
+	go p.run(cfg, p.stopChan, p.doneChan)
+}
+
+// stop terminates the reader goroutine and waits for it to exit
+func (p *APRSPlugin) stop() {
+	if p.stopChan == nil {
+		return
+	}
+	close(p.stopChan)
+	<-p.doneChan
+
+	p.mu.Lock()
+	p.connected = false
+	p.mu.Unlock()
+}
+
+// getConfig returns the current configuration
+func (p *APRSPlugin) getConfig() APRSConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config
+}
+
+// run reads from the source, reconnecting after errors until stopped
+func (p *APRSPlugin) run(cfg APRSConfig, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	var lastErr string
+	for {
+		var err error
+		if cfg.Source == APRSSourceKISS {
+			err = p.readKISS(cfg, stop)
+		} else {
+			err = p.readLog(cfg, stop)
+		}
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		// Log only changes so a stopped TNC does not flood the log
+		if err != nil && err.Error() != lastErr {
+			slog.Warn("APRS source unavailable", "source", cfg.Source, "error", err)
+			lastErr = err.Error()
+		}
+		p.mu.Lock()
+		wasConnected := p.connected
+		p.connected = false
+		if err != nil {
+			p.lastError = err.Error()
+		}
+		p.mu.Unlock()
+		if wasConnected {
+			PublishEvent(aprsDisconnectedEvent, aprsEventSource, fiber.Map{"source": cfg.Source, "error": p.lastErrorText()})
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(aprsReconnectDelay):
+		}
+	}
+}
+
+// lastErrorText returns the last source error
+func (p *APRSPlugin) lastErrorText() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.lastError
+}
+
+// setConnected marks the source connected
+func (p *APRSPlugin) setConnected(cfg APRSConfig) {
+	p.mu.Lock()
+	p.connected = true
+	p.lastError = ""
+	p.mu.Unlock()
+	slog.Info("APRS source connected", "source", cfg.Source)
+	PublishEvent(aprsConnectedEvent, aprsEventSource, fiber.Map{"source": cfg.Source})
+}
+
+// readKISS decodes frames from the KISS TCP port until it fails or stop is closed
+func (p *APRSPlugin) readKISS(cfg APRSConfig, stop <-chan struct{}) error {
+	conn, err := net.DialTimeout("tcp", cfg.KISSAddress, aprsReconnectDelay)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Closing the connection unblocks the reader on stop
+	closed := make(chan struct{})
+	defer close(closed)
+	go func() {
+		select {
+		case <-stop:
+			conn.Close()
+		case <-closed:
+		}
+	}()
+
+	p.setConnected(cfg)
+	reader := bufio.NewReader(conn)
+	for {
+		frame, err := readKISSFrame(reader)
+		if err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("KISS connection to %s closed", cfg.KISSAddress)
+			}
+			return err
+		}
+		port, text, err := decodeKISSFrame(frame)
+		if err == nil {
+			var packet APRSPacket
+			if packet, err = parseTNC2(text); err == nil {
+				packet.Direction = "rx"
+				packet.Port = strconv.Itoa(port)
+				packet.Time = time.Now()
+				p.record(packet)
+				continue
+			}
+		}
+		slog.Debug("Undecodable KISS frame", "error", err)
+		p.countUndecodable()
+	}
+}
+
+// readLog follows the aprx rf log from its end until it fails or stop is closed
+// A rotated or truncated log is reopened from the start.
+func (p *APRSPlugin) readLog(cfg APRSConfig, stop <-chan struct{}) error {
+	file, err := os.Open(cfg.LogFile)
+	if err != nil {
+		return err
+	}
+	defer func() { file.Close() }()
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+
+	p.setConnected(cfg)
+	reader := bufio.NewReader(file)
+	var pending string
+	for {
+		line, err := reader.ReadString('\n')
+		if err == nil {
+			p.recordLogLine(pending + line)
+			pending = ""
+			continue
+		}
+		if err != io.EOF {
+			return err
+		}
+		pending += line
+
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(aprsLogPollInterval):
+		}
+
+		current, err := os.Stat(cfg.LogFile)
+		if err != nil {
+			return err
+		}
+		opened, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		offset, err := file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		if os.SameFile(current, opened) && current.Size() >= offset {
+			continue
+		}
+		slog.Info("APRS log rotated, reopening", "file", cfg.LogFile)
+		file.Close()
+		if file, err = os.Open(cfg.LogFile); err != nil {
+			return err
+		}
+		reader.Reset(file)
+		pending = ""
+	}
+}
+
+// recordLogLine records the packet of an aprx log line
+func (p *APRSPlugin) recordLogLine(line string) {
+	packet, ok, err := parseAprxLogLine(line)
+	if err != nil {
+		slog.Debug("Undecodable aprx log line", "line", strings.TrimSpace(line), "error", err)
+		p.countUndecodable()
+		return
+	}
+	if ok {
+		p.record(packet)
+	}
+}
+
+// countUndecodable counts a frame or line that could not be decoded
+func (p *APRSPlugin) countUndecodable() {
+	p.mu.Lock()
+	p.stats.Undecodable++
+	p.mu.Unlock()
+}
+
+// record adds a packet to the history and statistics and wakes up streams
+func (p *APRSPlugin) record(packet APRSPacket) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := &p.stats
+	p.seq++
+	packet.Seq = p.seq
+	port := stats.Ports[packet.Port]
+	if port == nil {
+		port = &APRSPortStats{}
+		stats.Ports[packet.Port] = port
+	}
+
+	if packet.Direction == "tx" {
+		own := strings.EqualFold(packet.Source, p.config.Callsign) || strings.EqualFold(packet.Source, packet.Port)
+		packet.Digipeated = !own && packet.Port != aprxIgatePort
+		stats.Transmitted++
+		port.Transmitted++
+		if packet.Digipeated {
+			stats.Digipeated++
+		} else {
+			stats.Own++
+		}
+	} else {
+		stats.Received++
+		port.Received++
+		if packet.Digipeater == "" {
+			stats.Direct++
+		} else {
+			stats.ViaDigipeater++
+			stats.Digipeaters[packet.Digipeater]++
+		}
+		if packet.Port != aprxIgatePort {
+			p.heard(packet)
+		}
+	}
+	stats.Types[packet.Type]++
+	last := packet.Time
+	stats.LastPacket = &last
+
+	p.packets = append(p.packets, packet)
+	if len(p.packets) > p.config.History {
+		p.packets = p.packets[len(p.packets)-p.config.History:]
+	}
+	close(p.updated)
+	p.updated = make(chan struct{})
+}
+
+// heard updates the station list, dropping the station heard longest ago when it is full
+// Called with p.mu held.
+func (p *APRSPlugin) heard(packet APRSPacket) {
+	station := p.stations[packet.Source]
+	if station == nil {
+		if len(p.stations) >= aprsMaxStations {
+			var oldest *APRSStation
+			for _, s := range p.stations {
+				if oldest == nil || s.LastHeard.Before(oldest.LastHeard) {
+					oldest = s
+				}
+			}
+			delete(p.stations, oldest.Call)
+		}
+		station = &APRSStation{Call: packet.Source}
+		p.stations[packet.Source] = station
+	}
+	station.Packets++
+	station.LastHeard = packet.Time
+	station.LastType = packet.Type
+	station.Digipeater = packet.Digipeater
+}
+
+// getStats returns a copy of the statistics
+func (p *APRSPlugin) getStats() APRSStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	stats := p.stats
+	stats.Ports = make(map[string]*APRSPortStats, len(p.stats.Ports))
+	for name, port := range p.stats.Ports {
+		counts := *port
+		stats.Ports[name] = &counts
+	}
+	stats.Digipeaters = make(map[string]int, len(p.stats.Digipeaters))
+	for call, n := range p.stats.Digipeaters {
+		stats.Digipeaters[call] = n
+	}
+	stats.Types = make(map[string]int, len(p.stats.Types))
+	for kind, n := range p.stats.Types {
+		stats.Types[kind] = n
+	}
+	return stats
+}
+
+// packetsSince returns the kept packets after seq and the latest sequence number
+func (p *APRSPlugin) packetsSince(seq uint64) ([]APRSPacket, uint64) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var packets []APRSPacket
+	for _, packet := range p.packets {
+		if packet.Seq > seq {
+			packets = append(packets, packet)
+		}
+	}
+	return packets, p.seq
+}
+
+// waitUpdate returns a channel closed on the next packet
+func (p *APRSPlugin) waitUpdate() <-chan struct{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.updated
+}
+
+// handleStatus handles GET /api/aprs/status
+func (p *APRSPlugin) handleStatus(c *fiber.Ctx) error {
+	cfg := p.getConfig()
+
+	p.mu.RLock()
+	status := fiber.Map{
+		"source":      cfg.Source,
+		"connected":   p.connected,
+		"last_error":  p.lastError,
+		"callsign":    cfg.Callsign,
+		"packets":     len(p.packets),
+		"stations":    len(p.stations),
+		"last_packet": p.stats.LastPacket,
+	}
+	p.mu.RUnlock()
+
+	if cfg.Source == APRSSourceKISS {
+		status["kiss_address"] = cfg.KISSAddress
+	} else {
+		status["log_file"] = cfg.LogFile
+	}
+	return SendSuccess(c, status, "")
+}
+
+// handlePackets handles GET /api/aprs/packets?limit=50&station=CALL&direction=rx
+// Returns the most recent packets, oldest first
+func (p *APRSPlugin) handlePackets(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 {
+		return SendErrorMessage(c, 400, "limit must be positive")
+	}
+	station := c.Query("station")
+	direction := c.Query("direction")
+	if direction != "" && direction != "rx" && direction != "tx" {
+		return SendErrorMessage(c, 400, "direction must be rx or tx")
+	}
+
+	all, _ := p.packetsSince(0)
+	packets := make([]APRSPacket, 0, limit)
+	for i := len(all) - 1; i >= 0 && len(packets) < limit; i-- {
+		packet := all[i]
+		if station != "" && !strings.EqualFold(packet.Source, station) {
+			continue
+		}
+		if direction != "" && packet.Direction != direction {
+			continue
+		}
+		packets = append(packets, packet)
+	}
+	for i, j := 0, len(packets)-1; i < j; i, j = i+1, j-1 {
+		packets[i], packets[j] = packets[j], packets[i]
+	}
+	return SendSuccess(c, fiber.Map{"packets": packets}, "")
+}
+
+// handleStats handles GET /api/aprs/stats
+func (p *APRSPlugin) handleStats(c *fiber.Ctx) error {
+	return SendSuccess(c, p.getStats(), "")
+}
+
+// handleResetStats handles POST /api/aprs/stats/reset
+// Clears the counters and the station list; the packet history is kept
+func (p *APRSPlugin) handleResetStats(c *fiber.Ctx) error {
+	p.mu.Lock()
+	p.stats = newAPRSStats()
+	p.stations = make(map[string]*APRSStation)
+	p.mu.Unlock()
+
+	slog.InfoContext(c.UserContext(), "APRS statistics reset")
+	return SendSuccess(c, nil, "APRS statistics reset")
+}
+
+// handleStations handles GET /api/aprs/stations
+// Lists the stations heard on RF, most recently heard first
+func (p *APRSPlugin) handleStations(c *fiber.Ctx) error {
+	p.mu.RLock()
+	stations := make([]APRSStation, 0, len(p.stations))
+	for _, station := range p.stations {
+		stations = append(stations, *station)
+	}
+	p.mu.RUnlock()
+
+	sort.Slice(stations, func(i, j int) bool {
+		return stations[i].LastHeard.After(stations[j].LastHeard)
+	})
+	return SendSuccess(c, fiber.Map{"stations": stations}, "")
+}
+
+// handleStream handles GET /api/aprs/stream
+// Streams new packets ("packet" events) followed by the updated statistics ("stats"),
+// at most every 250ms
+func (p *APRSPlugin) handleStream(c *fiber.Ctx) error {
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		keepAlive := time.NewTicker(eventKeepAlive)
+		defer keepAlive.Stop()
+
+		// Start after the packets already kept; GET /aprs/packets returns those
+		_, seq := p.packetsSince(^uint64(0))
+		for {
+			// Register for the next packet before sending so none is missed
+			update := p.waitUpdate()
+
+			var packets []APRSPacket
+			packets, seq = p.packetsSince(seq)
+			for _, packet := range packets {
+				data, _ := json.Marshal(packet)
+				fmt.Fprintf(w, "event: packet\ndata: %s\n\n", data)
+			}
+			data, _ := json.Marshal(p.getStats())
+			fmt.Fprintf(w, "event: stats\ndata: %s\n\n", data)
+			if err := w.Flush(); err != nil {
+				return
+			}
+
+		wait:
+			for {
+				select {
+				case <-update:
+					break wait
+				case <-keepAlive.C:
+					fmt.Fprint(w, ": keep-alive\n\n")
+					if err := w.Flush(); err != nil {
+						return
+					}
+				}
+			}
+			time.Sleep(aprsStreamMinInterval)
+		}
+	})
+
+	return nil
+}
+
+// normalizeAPRSConfig fills in defaults
+func normalizeAPRSConfig(cfg APRSConfig) APRSConfig {
+	if cfg.Source == "" {
+		cfg.Source = APRSSourceKISS
+	}
+	if cfg.KISSAddress == "" {
+		cfg.KISSAddress = DefaultAPRSKISSAddress
+	}
+	if cfg.LogFile == "" {
+		cfg.LogFile = DefaultAPRSLogFile
+	}
+	if cfg.History <= 0 {
+		cfg.History = DefaultAPRSHistory
+	}
+	cfg.Callsign = strings.ToUpper(strings.TrimSpace(cfg.Callsign))
+	return cfg
+}
+
+// validateAPRSConfig checks the source selection and history size
+func validateAPRSConfig(cfg APRSConfig) error {
+	if cfg.Source != APRSSourceKISS && cfg.Source != APRSSourceLog {
+		return fmt.Errorf("invalid aprs source %q (use kiss or log)", cfg.Source)
+	}
+	if cfg.History > maxAPRSHistory {
+		return fmt.Errorf("aprs.history must not exceed %d packets", maxAPRSHistory)
+	}
+	return nil
+}
+
+// Validate checks the settings after filling in defaults
+func (cfg APRSConfig) Validate() error {
+	return validateAPRSConfig(normalizeAPRSConfig(cfg))
+}
+
+// Register the plugin
+func init() {
+	Register("aprs", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[APRSConfig]("aprs", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewAPRSPlugin(cfg)
+	})
+}
@@ -0,0 +1,248 @@
+package plugins
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KISS framing bytes
+const (
+	kissFEND  = 0xC0
+	kissFESC  = 0xDB
+	kissTFEND = 0xDC
+	kissTFESC = 0xDD
+)
+
+// AX.25 UI frame fields carrying APRS
+const (
+	ax25AddressLen = 7
+	ax25ControlUI  = 0x03
+	ax25PIDNoLayer = 0xF0
+)
+
+// aprxLogTime is the timestamp layout of aprx rf log lines
+const aprxLogTime = "2006-01-02 15:04:05.000"
+
+// aprsAliasPattern matches the generic path aliases a digipeater consumes (WIDE2-1, TRACE, RELAY)
+var aprsAliasPattern = regexp.MustCompile(`^(WIDE|TRACE|RELAY|TEMP)\d*(-\d+)?$`)
+
+// aprsDataTypes maps the APRS data type identifier (first info byte) to a packet type
+var aprsDataTypes = map[byte]string{
+	'!':  "position",
+	'=':  "position",
+	'/':  "position",
+	'@':  "position",
+	'$':  "raw_gps",
+	'`':  "mic-e",
+	'\'': "mic-e",
+	':':  "message",
+	'>':  "status",
+	';':  "object",
+	')':  "item",
+	'T':  "telemetry",
+	'_':  "weather",
+	'}':  "third_party",
+	'<':  "capabilities",
+	'?':  "query",
+}
+
+// APRSHop is one digipeater entry of the path
+type APRSHop struct {
+	Call string `json:"call"`
+	Used bool   `json:"used"` // has-been-repeated bit set
+}
+
+// APRSPacket is a decoded packet heard or sent by the APRS stack
+type APRSPacket struct {
+	Seq         uint64    `json:"seq"`
+	Time        time.Time `json:"time"`
+	Direction   string    `json:"direction"`      // rx or tx
+	Port        string    `json:"port,omitempty"` // KISS port, or the aprx interface (APRSIS for the igate)
+	Source      string    `json:"source"`
+	Destination string    `json:"destination"`
+	Path        []APRSHop `json:"path"`
+	Type        string    `json:"type"`
+	Info        string    `json:"info"`
+	Digipeater  string    `json:"digipeater,omitempty"` // last station that relayed the packet
+	Digipeated  bool      `json:"digipeated"`           // tx: relayed for another station
+	Raw         string    `json:"raw"`                  // TNC2 monitor format
+}
+
+// readKISSFrame reads the next KISS frame and removes the escaping
+// Empty frames (back-to-back FENDs) are skipped; bytes before the first FEND
+// of a connection end up in a frame that fails to decode.
+func readKISSFrame(r *bufio.Reader) ([]byte, error) {
+	var frame []byte
+	escaped := false
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case b == kissFEND:
+			if len(frame) > 0 {
+				return frame, nil
+			}
+			escaped = false
+		case escaped:
+			switch b {
+			case kissTFEND:
+				frame = append(frame, kissFEND)
+			case kissTFESC:
+				frame = append(frame, kissFESC)
+			}
+			escaped = false
+		case b == kissFESC:
+			escaped = true
+		default:
+			frame = append(frame, b)
+		}
+	}
+}
+
+// decodeAX25Address decodes a 7-byte AX.25 address into CALL-SSID and the H/C bit
+func decodeAX25Address(data []byte) (string, bool) {
+	call := make([]byte, 0, 6)
+	for _, b := range data[:6] {
+		if c := b >> 1; c != ' ' {
+			call = append(call, c)
+		}
+	}
+	text := string(call)
+	if ssid := (data[6] >> 1) & 0x0F; ssid != 0 {
+		text += "-" + strconv.Itoa(int(ssid))
+	}
+	return text, data[6]&0x80 != 0
+}
+
+// decodeKISSFrame decodes a KISS data frame holding an AX.25 UI frame to TNC2 monitor format
+// Returns the KISS port the frame was received on.
+func decodeKISSFrame(frame []byte) (int, string, error) {
+	if len(frame) == 0 || frame[0]&0x0F != 0 {
+		return 0, "", fmt.Errorf("not a KISS data frame")
+	}
+	port, ax25 := int(frame[0]>>4), frame[1:]
+
+	var calls []string
+	end, used := 0, -1
+	for {
+		if len(ax25) < end+ax25AddressLen {
+			return port, "", fmt.Errorf("truncated AX.25 address field")
+		}
+		call, marked := decodeAX25Address(ax25[end : end+ax25AddressLen])
+		if len(calls) >= 2 && marked {
+			used = len(calls)
+		}
+		calls = append(calls, call)
+		last := ax25[end+ax25AddressLen-1]&0x01 != 0
+		end += ax25AddressLen
+		if last {
+			break
+		}
+	}
+	if len(calls) < 2 {
+		return port, "", fmt.Errorf("AX.25 frame without source address")
+	}
+	if len(ax25) < end+2 || ax25[end] != ax25ControlUI || ax25[end+1] != ax25PIDNoLayer {
+		return port, "", fmt.Errorf("not an AX.25 UI frame")
+	}
+
+	// TNC2 marks only the last repeated hop
+	if used >= 0 {
+		calls[used] += "*"
+	}
+	var b strings.Builder
+	b.WriteString(calls[1] + ">" + calls[0])
+	for _, hop := range calls[2:] {
+		b.WriteString("," + hop)
+	}
+	b.WriteString(":" + string(ax25[end+2:]))
+	return port, b.String(), nil
+}
+
+// parseTNC2 parses a packet in TNC2 monitor format (SRC>DEST,PATH:info)
+// Every hop up to the last one marked with * has been repeated.
+func parseTNC2(text string) (APRSPacket, error) {
+	text = strings.TrimRight(text, "\r\n")
+	header, info, ok := strings.Cut(text, ":")
+	if !ok {
+		return APRSPacket{}, fmt.Errorf("no information field")
+	}
+	source, rest, ok := strings.Cut(header, ">")
+	if !ok || source == "" || rest == "" {
+		return APRSPacket{}, fmt.Errorf("invalid header %q", header)
+	}
+	fields := strings.Split(rest, ",")
+
+	packet := APRSPacket{
+		Source:      source,
+		Destination: fields[0],
+		Path:        []APRSHop{},
+		Info:        info,
+		Type:        "other",
+		Raw:         text,
+	}
+	used := -1
+	for i, call := range fields[1:] {
+		if strings.HasSuffix(call, "*") {
+			used = i
+		}
+		packet.Path = append(packet.Path, APRSHop{Call: strings.TrimSuffix(call, "*")})
+	}
+	for i := 0; i <= used; i++ {
+		packet.Path[i].Used = true
+		if !aprsAliasPattern.MatchString(packet.Path[i].Call) {
+			packet.Digipeater = packet.Path[i].Call
+		}
+	}
+	if info != "" {
+		if kind, ok := aprsDataTypes[info[0]]; ok {
+			packet.Type = kind
+		}
+	}
+	return packet, nil
+}
+
+// parseAprxLogLine parses a line of the aprx rf log:
+//
+//	2024-05-01 12:00:00.000 OE3XYZ-10 R OE3ABC-9>APRS,WIDE1-1:!4812.34N/01612.34E>
+//
+// The port is the interface callsign, or APRSIS for traffic of the igate. R lines were
+// received, T lines transmitted; other lines are skipped (ok is false).
+func parseAprxLogLine(line string) (packet APRSPacket, ok bool, err error) {
+	fields := make([]string, 0, 4)
+	rest := strings.TrimSpace(line)
+	for len(fields) < 4 {
+		field, tail, found := strings.Cut(rest, " ")
+		if !found {
+			return APRSPacket{}, false, fmt.Errorf("truncated aprx log line")
+		}
+		fields = append(fields, field)
+		rest = strings.TrimLeft(tail, " ")
+	}
+
+	var direction string
+	switch fields[3] {
+	case "R":
+		direction = "rx"
+	case "T":
+		direction = "tx"
+	default:
+		return APRSPacket{}, false, nil
+	}
+	if packet, err = parseTNC2(rest); err != nil {
+		return APRSPacket{}, false, err
+	}
+	packet.Direction = direction
+	packet.Port = fields[2]
+	packet.Time = time.Now()
+	if t, err := time.ParseInLocation(aprxLogTime, fields[0]+" "+fields[1], time.Local); err == nil {
+		packet.Time = t
+	}
+	return packet, true, nil
+}
@@ -0,0 +1,127 @@
+package plugins
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// archiveBufferSize is the copy buffer for archive entries
+const archiveBufferSize = 256 * 1024
+
+// copyContext copies src to dst, stopping when ctx is canceled
+func copyContext(ctx context.Context, dst io.Writer, src io.Reader) (int64, error) {
+	buf := make([]byte, archiveBufferSize)
+	var written int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		n, readErr := src.Read(buf)
+		if n > 0 {
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return written, err
+			}
+			written += int64(n)
+		}
+		if readErr == io.EOF {
+			return written, nil
+		}
+		if readErr != nil {
+			return written, readErr
+		}
+	}
+}
+
+// archiveWriter adds files to a tar.gz archive
+type archiveWriter struct {
+	tw *tar.Writer
+	gz *gzip.Writer
+}
+
+// newArchiveWriter starts a tar.gz archive on w
+func newArchiveWriter(w io.Writer) *archiveWriter {
+	gz := gzip.NewWriter(w)
+	return &archiveWriter{tw: tar.NewWriter(gz), gz: gz}
+}
+
+// add writes one entry; name uses forward slashes, linkTarget is set for links
+func (a *archiveWriter) add(ctx context.Context, path, name string, info fs.FileInfo, linkTarget string) error {
+	header, err := tar.FileInfoHeader(info, linkTarget)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if err := a.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	return copyFile(ctx, a.tw, path)
+}
+
+// addTree adds a file or directory with everything below it as name and
+// returns the number of regular files; progress is called for every entry
+// Symbolic links are stored as links; sockets, devices and FIFOs are left out.
+func (a *archiveWriter) addTree(ctx context.Context, srcPath, name string, progress func(rel string, size int64)) (int, error) {
+	files := 0
+	err := filepath.WalkDir(srcPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var linkTarget string
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			if linkTarget, err = os.Readlink(path); err != nil {
+				return err
+			}
+		case !d.IsDir() && !d.Type().IsRegular():
+			return nil
+		}
+
+		rel, _ := filepath.Rel(srcPath, path)
+		rel = filepath.ToSlash(filepath.Join(name, rel))
+		if err := a.add(ctx, path, rel, info, linkTarget); err != nil {
+			return err
+		}
+		var size int64
+		if d.Type().IsRegular() {
+			files++
+			size = info.Size()
+		}
+		progress(rel, size)
+		return nil
+	})
+	return files, err
+}
+
+// close finishes the archive
+func (a *archiveWriter) close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	return a.gz.Close()
+}
+
+// copyFile copies the content of a file into an archive entry
+func copyFile(ctx context.Context, w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = copyContext(ctx, w, file)
+	return err
+}
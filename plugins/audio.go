@@ -0,0 +1,513 @@
+package plugins
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Audio sources
+const (
+	AudioSourceALSA = "alsa" // capture device, read with arecord
+	AudioSourceUDP  = "udp"  // raw PCM datagrams, e.g. from the modem container
+)
+
+// Audio defaults
+const (
+	DefaultAudioDevice       = "default"
+	DefaultAudioUDPAddress   = "127.0.0.1:7355"
+	DefaultAudioSampleRate   = 48000
+	DefaultAudioChannels     = 1
+	DefaultAudioBitrate      = 24 // kbit/s
+	DefaultAudioMaxListeners = 4
+	audioListenerBuffer      = 64 // Ogg pages queued per listener before it is dropped
+	audioSilenceInterval     = 100 * time.Millisecond
+	audioMaxDelay            = 100 // ms opusenc may hold audio before writing a page
+	audioStartedEvent        = "audio.started"
+	audioStoppedEvent        = "audio.stopped"
+	audioEventSource         = "audio"
+)
+
+// errAudioBusy is returned when max_listeners are connected
+var errAudioBusy = errors.New("too many audio listeners")
+
+// AudioConfig holds audio plugin configuration
+type AudioConfig struct {
+	Source       string `yaml:"source"`        // alsa or udp
+	Device       string `yaml:"device"`        // ALSA capture device (source: alsa)
+	UDPAddress   string `yaml:"udp_address"`   // address receiving signed 16-bit little-endian PCM (source: udp)
+	SampleRate   int    `yaml:"sample_rate"`   // Hz
+	Channels     int    `yaml:"channels"`      // 1 or 2
+	Bitrate      int    `yaml:"bitrate"`       // Opus bitrate in kbit/s
+	MaxListeners int    `yaml:"max_listeners"` // concurrent streams
+}
+
+// audioListener is a browser connected to the stream
+type audioListener struct {
+	pages chan []byte // closed when the listener is dropped or the stream ends
+}
+
+// audioSession is a running encoder shared by all listeners
+// It starts with the first listener and stops when the last one leaves.
+type audioSession struct {
+	encoder   *exec.Cmd
+	capture   *exec.Cmd      // arecord (source: alsa)
+	conn      net.PacketConn // source: udp
+	startedAt time.Time
+	audio     bool // an audio page was seen; the headers are complete
+	stopped   bool // set by stopLocked; the end is not an error
+	done      chan struct{}
+}
+
+// AudioPlugin serves received audio to browsers as Ogg/Opus over HTTP
+type AudioPlugin struct {
+	mu        sync.Mutex
+	config    AudioConfig
+	session   *audioSession
+	headers   [][]byte // OpusHead and OpusTags pages of the running session
+	listeners map[*audioListener]struct{}
+	lastError string
+}
+
+// NewAudioPlugin creates a new audio plugin instance
+func NewAudioPlugin(cfg AudioConfig) (*AudioPlugin, error) {
+	cfg = normalizeAudioConfig(cfg)
+	if err := validateAudioConfig(cfg); err != nil {
+		return nil, err
+	}
+	if _, err := exec.LookPath("opusenc"); err != nil {
+		slog.Warn("opusenc not found, audio streaming will fail (install opus-tools)")
+	}
+
+	return &AudioPlugin{
+		config:    cfg,
+		listeners: make(map[*audioListener]struct{}),
+	}, nil
+}
+
+// Name returns the plugin identifier
+func (p *AudioPlugin) Name() string {
+	return "audio"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *AudioPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/audio")
+
+	api.Get("/status", p.handleStatus)
+	api.Get("/stream", p.handleStream)
+}
+
+// Shutdown stops the encoder and ends all streams
+func (p *AudioPlugin) Shutdown() error {
+	p.stop()
+	return nil
+}
+
+// Reload applies new settings; a running stream is ended so listeners reconnect with them
+func (p *AudioPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[AudioConfig]("audio", config)
+	if err != nil {
+		return err
+	}
+	cfg = normalizeAudioConfig(cfg)
+	if err := validateAudioConfig(cfg); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	changed := cfg != p.config
+	p.config = cfg
+	p.mu.Unlock()
+
+	restarted := changed && p.stop()
+
+	slog.Info("Audio config reloaded",
+		"source", cfg.Source,
+		"device", cfg.Device,
+		"udp_address", cfg.UDPAddress,
+		"sample_rate", cfg.SampleRate,
+		"bitrate", cfg.Bitrate,
+		"restarted", restarted)
+	return nil
+}
+
+// startSession starts the capture and the encoder
+// Called with p.mu held.
+func (p *AudioPlugin) startSession() error {
+	cfg := p.config
+	encoder := exec.Command("opusenc", "--quiet",
+		"--raw",
+		"--raw-bits", "16",
+		"--raw-rate", strconv.Itoa(cfg.SampleRate),
+		"--raw-chan", strconv.Itoa(cfg.Channels),
+		"--raw-endianness", "0",
+		"--bitrate", strconv.Itoa(cfg.Bitrate),
+		"--max-delay", strconv.Itoa(audioMaxDelay),
+		"-", "-")
+	var stderr strings.Builder
+	encoder.Stderr = &stderr
+	stdout, err := encoder.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	session := &audioSession{encoder: encoder, startedAt: time.Now().UTC(), done: make(chan struct{})}
+	var stdin io.WriteCloser
+	var captureErr strings.Builder
+	switch cfg.Source {
+	case AudioSourceALSA:
+		session.capture = exec.Command("arecord", "-q",
+			"-D", cfg.Device,
+			"-t", "raw",
+			"-f", "S16_LE",
+			"-c", strconv.Itoa(cfg.Channels),
+			"-r", strconv.Itoa(cfg.SampleRate))
+		session.capture.Stderr = &captureErr
+		if encoder.Stdin, err = session.capture.StdoutPipe(); err != nil {
+			return err
+		}
+		if err := session.capture.Start(); err != nil {
+			return fmt.Errorf("failed to start arecord: %w", err)
+		}
+	default:
+		if session.conn, err = net.ListenPacket("udp", cfg.UDPAddress); err != nil {
+			return err
+		}
+		if stdin, err = encoder.StdinPipe(); err != nil {
+			session.conn.Close()
+			return err
+		}
+	}
+
+	if err := encoder.Start(); err != nil {
+		if session.capture != nil {
+			session.capture.Process.Kill()
+			session.capture.Wait()
+		} else {
+			session.conn.Close()
+		}
+		return fmt.Errorf("failed to start opusenc: %w", err)
+	}
+	if session.conn != nil {
+		go readAudioUDP(session.conn, stdin, cfg)
+	}
+
+	p.session = session
+	p.headers = nil
+	p.lastError = ""
+	go p.runSession(session, stdout, &stderr, &captureErr)
+
+	slog.Info("Audio stream started", "source", cfg.Source, "sample_rate", cfg.SampleRate, "bitrate", cfg.Bitrate)
+	PublishEvent(audioStartedEvent, audioEventSource, fiber.Map{"source": cfg.Source})
+	return nil
+}
+
+// readAudioUDP feeds received datagrams to the encoder until the socket is closed
+// Silence is inserted while nothing arrives, so listeners keep playing through squelch.
+func readAudioUDP(conn net.PacketConn, stdin io.WriteCloser, cfg AudioConfig) {
+	defer stdin.Close()
+
+	buf := make([]byte, 65536)
+	silence := make([]byte, cfg.SampleRate*cfg.Channels*2*int(audioSilenceInterval/time.Millisecond)/1000)
+	for {
+		conn.SetReadDeadline(time.Now().Add(audioSilenceInterval))
+		n, _, err := conn.ReadFrom(buf)
+		data := buf[:n]
+		if err != nil {
+			var netErr net.Error
+			if !errors.As(err, &netErr) || !netErr.Timeout() {
+				return
+			}
+			data = silence
+		}
+		if _, err := stdin.Write(data); err != nil {
+			return
+		}
+	}
+}
+
+// runSession forwards the encoded pages to the listeners until the encoder exits
+func (p *AudioPlugin) runSession(session *audioSession, stdout io.Reader, stderr, captureErr *strings.Builder) {
+	defer close(session.done)
+
+	reader := bufio.NewReader(stdout)
+	for {
+		page, err := readOggPage(reader)
+		if err != nil {
+			break
+		}
+		p.broadcast(session, page)
+	}
+
+	if session.conn != nil {
+		session.conn.Close()
+	}
+	if session.capture != nil {
+		session.capture.Process.Kill()
+		session.capture.Wait()
+	}
+	// Output that is not Ogg leaves the encoder running; it has exited otherwise
+	session.encoder.Process.Kill()
+	waitErr := session.encoder.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !session.stopped {
+		var reason string
+		switch {
+		case strings.TrimSpace(captureErr.String()) != "":
+			reason = "arecord: " + strings.TrimSpace(captureErr.String())
+		case strings.TrimSpace(stderr.String()) != "":
+			reason = "opusenc: " + strings.TrimSpace(stderr.String())
+		case waitErr != nil:
+			reason = "opusenc: " + waitErr.Error()
+		default:
+			reason = "audio source ended"
+		}
+		p.lastError = reason
+		slog.Warn("Audio stream ended", "error", reason)
+		PublishEvent(audioStoppedEvent, audioEventSource, fiber.Map{"error": reason})
+	}
+	p.endSession(session)
+}
+
+// broadcast queues a page for every listener; listeners that fall behind are dropped
+func (p *AudioPlugin) broadcast(session *audioSession, page oggPage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.session != session {
+		return
+	}
+
+	// Pages before the first audio page are the stream headers every new listener needs
+	if !session.audio && page.header() {
+		p.headers = append(p.headers, page.data)
+	} else {
+		session.audio = true
+	}
+	for listener := range p.listeners {
+		select {
+		case listener.pages <- page.data:
+		default:
+			slog.Warn("Audio listener too slow, dropped")
+			close(listener.pages)
+			delete(p.listeners, listener)
+		}
+	}
+}
+
+// subscribe adds a listener, starting the encoder for the first one
+// Returns the header pages collected so far; the following pages arrive on the channel.
+func (p *AudioPlugin) subscribe() (*audioListener, [][]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.listeners) >= p.config.MaxListeners {
+		return nil, nil, errAudioBusy
+	}
+	if p.session == nil {
+		if err := p.startSession(); err != nil {
+			p.lastError = err.Error()
+			return nil, nil, err
+		}
+	}
+	listener := &audioListener{pages: make(chan []byte, audioListenerBuffer)}
+	p.listeners[listener] = struct{}{}
+	return listener, append([][]byte{}, p.headers...), nil
+}
+
+// unsubscribe removes a listener and stops the encoder after the last one
+func (p *AudioPlugin) unsubscribe(listener *audioListener) {
+	p.mu.Lock()
+	if _, ok := p.listeners[listener]; ok {
+		close(listener.pages)
+		delete(p.listeners, listener)
+	}
+	var done <-chan struct{}
+	if len(p.listeners) == 0 && p.session != nil {
+		done = p.stopLocked(p.session)
+	}
+	p.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+// stop ends the running session and waits for its processes to exit
+// Returns false when no session was running.
+func (p *AudioPlugin) stop() bool {
+	p.mu.Lock()
+	if p.session == nil {
+		p.mu.Unlock()
+		return false
+	}
+	done := p.stopLocked(p.session)
+	p.mu.Unlock()
+
+	<-done
+	return true
+}
+
+// stopLocked ends a session and kills its processes; done is closed once they exited
+// Called with p.mu held, so no listener can join the session in between.
+func (p *AudioPlugin) stopLocked(session *audioSession) (done <-chan struct{}) {
+	session.stopped = true
+	p.endSession(session)
+	if session.capture != nil {
+		session.capture.Process.Kill()
+	}
+	if session.conn != nil {
+		session.conn.Close()
+	}
+	session.encoder.Process.Kill()
+	slog.Info("Audio stream stopped")
+	PublishEvent(audioStoppedEvent, audioEventSource, fiber.Map{})
+	return session.done
+}
+
+// endSession detaches a session and ends the streams of its listeners
+// Called with p.mu held.
+func (p *AudioPlugin) endSession(session *audioSession) {
+	if p.session != session {
+		return
+	}
+	p.session = nil
+	p.headers = nil
+	for listener := range p.listeners {
+		close(listener.pages)
+		delete(p.listeners, listener)
+	}
+}
+
+// handleStatus handles GET /api/audio/status
+func (p *AudioPlugin) handleStatus(c *fiber.Ctx) error {
+	p.mu.Lock()
+	cfg := p.config
+	status := fiber.Map{
+		"source":        cfg.Source,
+		"sample_rate":   cfg.SampleRate,
+		"channels":      cfg.Channels,
+		"bitrate":       cfg.Bitrate,
+		"running":       p.session != nil,
+		"listeners":     len(p.listeners),
+		"max_listeners": cfg.MaxListeners,
+		"last_error":    p.lastError,
+	}
+	if p.session != nil {
+		status["started_at"] = p.session.startedAt
+	}
+	p.mu.Unlock()
+
+	if cfg.Source == AudioSourceALSA {
+		status["device"] = cfg.Device
+	} else {
+		status["udp_address"] = cfg.UDPAddress
+	}
+	return SendSuccess(c, status, "")
+}
+
+// handleStream handles GET /api/audio/stream
+// Streams the received audio as Ogg/Opus, playable with <audio src="/api/v1/audio/stream">
+func (p *AudioPlugin) handleStream(c *fiber.Ctx) error {
+	listener, headers, err := p.subscribe()
+	if err != nil {
+		if errors.Is(err, errAudioBusy) {
+			return SendError(c, 503, err)
+		}
+		return SendError(c, 500, err)
+	}
+
+	c.Set("Content-Type", "audio/ogg")
+	c.Set("Cache-Control", "no-cache, no-store")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer p.unsubscribe(listener)
+
+		for _, page := range headers {
+			w.Write(page)
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+		for page := range listener.pages {
+			w.Write(page)
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+// normalizeAudioConfig fills in defaults
+func normalizeAudioConfig(cfg AudioConfig) AudioConfig {
+	if cfg.Source == "" {
+		cfg.Source = AudioSourceUDP
+	}
+	if cfg.Device == "" {
+		cfg.Device = DefaultAudioDevice
+	}
+	if cfg.UDPAddress == "" {
+		cfg.UDPAddress = DefaultAudioUDPAddress
+	}
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = DefaultAudioSampleRate
+	}
+	if cfg.Channels <= 0 {
+		cfg.Channels = DefaultAudioChannels
+	}
+	if cfg.Bitrate <= 0 {
+		cfg.Bitrate = DefaultAudioBitrate
+	}
+	if cfg.MaxListeners <= 0 {
+		cfg.MaxListeners = DefaultAudioMaxListeners
+	}
+	return cfg
+}
+
+// validateAudioConfig checks the source and encoding settings
+func validateAudioConfig(cfg AudioConfig) error {
+	if cfg.Source != AudioSourceALSA && cfg.Source != AudioSourceUDP {
+		return fmt.Errorf("invalid audio source %q (use alsa or udp)", cfg.Source)
+	}
+	if cfg.SampleRate < 8000 || cfg.SampleRate > 192000 {
+		return fmt.Errorf("audio.sample_rate must be within 8000-192000 Hz")
+	}
+	if cfg.Channels > 2 {
+		return fmt.Errorf("audio.channels must be 1 or 2")
+	}
+	if cfg.Bitrate < 6 || cfg.Bitrate > 256 {
+		return fmt.Errorf("audio.bitrate must be within 6-256 kbit/s")
+	}
+	return nil
+}
+
+// Validate checks the settings after filling in defaults
+func (cfg AudioConfig) Validate() error {
+	return validateAudioConfig(normalizeAudioConfig(cfg))
+}
+
+// Register the plugin
+func init() {
+	Register("audio", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[AudioConfig]("audio", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewAudioPlugin(cfg)
+	})
+}
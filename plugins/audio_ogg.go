@@ -0,0 +1,58 @@
+package plugins
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Ogg page layout
+const (
+	oggHeaderLen  = 27
+	oggCapture    = "OggS"
+	oggGranuleOff = 6
+	oggSegmentsAt = 26
+)
+
+// oggPage is one page of an Ogg stream as read from the encoder
+type oggPage struct {
+	data    []byte
+	granule int64 // -1 for pages on which no packet ends
+}
+
+// header reports whether the page belongs to the stream headers (OpusHead, OpusTags)
+// They carry no audio, so their granule position is 0 or -1.
+func (pg oggPage) header() bool {
+	return pg.granule <= 0
+}
+
+// readOggPage reads the next page of an Ogg stream
+func readOggPage(r *bufio.Reader) (oggPage, error) {
+	header := make([]byte, oggHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return oggPage{}, err
+	}
+	if string(header[:4]) != oggCapture {
+		return oggPage{}, fmt.Errorf("invalid Ogg page: missing capture pattern")
+	}
+	segments := make([]byte, header[oggSegmentsAt])
+	if _, err := io.ReadFull(r, segments); err != nil {
+		return oggPage{}, err
+	}
+	size := 0
+	for _, n := range segments {
+		size += int(n)
+	}
+
+	data := make([]byte, 0, oggHeaderLen+len(segments)+size)
+	data = append(append(data, header...), segments...)
+	data = data[:cap(data)]
+	if _, err := io.ReadFull(r, data[oggHeaderLen+len(segments):]); err != nil {
+		return oggPage{}, err
+	}
+	return oggPage{
+		data:    data,
+		granule: int64(binary.LittleEndian.Uint64(header[oggGranuleOff:])),
+	}, nil
+}
@@ -0,0 +1,664 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Backup defaults
+const (
+	DefaultBackupStagingDir = "/var/tmp/linht-backup"
+	backupTimeFormat        = "20060102-150405"
+	backupEventSource       = "backup"
+	backupJobType           = "backup.run"
+)
+
+// backupFile matches the files of a run: <name>-<time>-<part>
+var backupFile = regexp.MustCompile(`^(.+)-(\d{8}-\d{6})-(images\.tar|volume-.+\.tar\.gz)$`)
+
+// BackupSpec describes one scheduled backup
+type BackupSpec struct {
+	Name     string   `yaml:"name"`
+	Images   []string `yaml:"images"`   // tag patterns, e.g. linht/* or ghcr.io/org/app:1.*
+	Volumes  []string `yaml:"volumes"`  // volume names
+	Target   string   `yaml:"target"`   // local directory (USB disk) or remote:path
+	At       string   `yaml:"at"`       // daily at HH:MM local time
+	Interval int      `yaml:"interval"` // or every interval hours
+	Keep     int      `yaml:"keep"`     // runs kept at the target, 0 keeps all
+}
+
+// BackupConfig holds backup plugin configuration
+type BackupConfig struct {
+	StagingDir string       `yaml:"staging_dir"` // files for remote targets are written here before the upload
+	Backups    []BackupSpec `yaml:"backups"`
+
+	Remotes      []RemoteConfig `yaml:"-"` // taken from the remotes section
+	DockerClient *client.Client `yaml:"-"`
+}
+
+// BackupRun is the outcome of one backup run
+type BackupRun struct {
+	Time     time.Time  `json:"time"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Target   string     `json:"target"`
+	Images   []string   `json:"images"`
+	Volumes  []string   `json:"volumes"`
+	Files    []string   `json:"files"`
+	Bytes    int64      `json:"bytes"`
+	Removed  []string   `json:"removed,omitempty"` // older runs removed by the retention policy
+	Warnings []string   `json:"warnings,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// BackupStatus describes a configured backup and its last run
+type BackupStatus struct {
+	Name     string     `json:"name"`
+	Target   string     `json:"target"`
+	Schedule string     `json:"schedule"`
+	Keep     int        `json:"keep"`
+	NextRun  *time.Time `json:"next_run,omitempty"`
+	Running  bool       `json:"running"`
+	JobID    string     `json:"job_id,omitempty"` // job of the running or last run
+	LastRun  *BackupRun `json:"last_run,omitempty"`
+}
+
+// BackupSet is a run found at the target
+type BackupSet struct {
+	Time  time.Time     `json:"time"`
+	Files []RemoteEntry `json:"files"`
+	Bytes int64         `json:"bytes"`
+}
+
+// backupState is the runtime state of one backup
+type backupState struct {
+	schedule string // schedule next was computed for
+	next     time.Time
+	running  bool
+	jobID    string
+	last     *BackupRun
+}
+
+// BackupPlugin exports images and volumes on a schedule
+type BackupPlugin struct {
+	trigger  chan struct{}
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	mu     sync.Mutex
+	config BackupConfig
+	states map[string]*backupState
+}
+
+// NewBackupPlugin creates a new backup plugin instance
+func NewBackupPlugin(cfg BackupConfig) (*BackupPlugin, error) {
+	cfg = normalizeBackupConfig(cfg)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	p := &BackupPlugin{
+		trigger:  make(chan struct{}, 1),
+		stopChan: make(chan struct{}),
+		states:   make(map[string]*backupState),
+	}
+	p.apply(cfg)
+	return p, nil
+}
+
+// Start begins the schedule
+func (p *BackupPlugin) Start() error {
+	p.wg.Add(1)
+	go p.run()
+	slog.Info("Backup scheduler started", "backups", len(p.getConfig().Backups))
+	return nil
+}
+
+// Name returns the plugin identifier
+func (p *BackupPlugin) Name() string {
+	return "backup"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *BackupPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/backup")
+
+	api.Get("/", p.handleStatus)
+	api.Get("/:name/runs", p.handleListRuns)
+	api.Post("/:name/run", p.handleRun)
+}
+
+// Shutdown stops the scheduler; running backups are canceled with the jobs
+func (p *BackupPlugin) Shutdown() error {
+	p.stopOnce.Do(func() {
+		close(p.stopChan)
+	})
+	p.wg.Wait()
+	return nil
+}
+
+// Reload applies new backups and schedules
+func (p *BackupPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[BackupConfig]("backup", config)
+	if err != nil {
+		return err
+	}
+	cfg = normalizeBackupConfig(cfg)
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	p.apply(cfg)
+
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+	slog.Info("Backup config reloaded", "backups", len(cfg.Backups))
+	return nil
+}
+
+// Validate checks the backups, their schedules and targets
+func (cfg BackupConfig) Validate() error {
+	if !filepath.IsAbs(cfg.StagingDir) {
+		return fmt.Errorf("backup.staging_dir must be an absolute path")
+	}
+	if err := ValidateRemotes(cfg.Remotes); err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	for i, spec := range cfg.Backups {
+		if !remoteName.MatchString(spec.Name) {
+			return fmt.Errorf("backup.backups[%d]: name %q must be lowercase letters, digits, - or _", i, spec.Name)
+		}
+		if names[spec.Name] {
+			return fmt.Errorf("backup.backups[%d]: duplicate name %q", i, spec.Name)
+		}
+		names[spec.Name] = true
+		if len(spec.Images) == 0 && len(spec.Volumes) == 0 {
+			return fmt.Errorf("backup.backups[%d]: needs images or volumes", i)
+		}
+		for _, pattern := range spec.Images {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("backup.backups[%d]: invalid image pattern %q", i, pattern)
+			}
+		}
+		for _, volume := range spec.Volumes {
+			if volume == "" || strings.ContainsAny(volume, "/\\") {
+				return fmt.Errorf("backup.backups[%d]: invalid volume name %q", i, volume)
+			}
+		}
+		if _, _, err := parseTarget(spec.Target, cfg.Remotes); err != nil {
+			return fmt.Errorf("backup.backups[%d]: %w", i, err)
+		}
+		switch {
+		case spec.At != "" && spec.Interval != 0:
+			return fmt.Errorf("backup.backups[%d]: set either at or interval", i)
+		case spec.At != "":
+			if _, err := time.Parse("15:04", spec.At); err != nil {
+				return fmt.Errorf("backup.backups[%d]: at must be HH:MM, got %q", i, spec.At)
+			}
+		case spec.Interval < 0:
+			return fmt.Errorf("backup.backups[%d]: interval must be positive", i)
+		}
+		if spec.Keep < 0 {
+			return fmt.Errorf("backup.backups[%d]: keep must not be negative", i)
+		}
+	}
+	return nil
+}
+
+// apply stores a config and schedules its backups, keeping the state of known ones
+func (p *BackupPlugin) apply(cfg BackupConfig) {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	states := make(map[string]*backupState)
+	for _, spec := range cfg.Backups {
+		state, ok := p.states[spec.Name]
+		if !ok {
+			state = &backupState{}
+		}
+		if !ok || state.schedule != spec.schedule() {
+			state.schedule = spec.schedule()
+			state.next = nextBackup(spec, now)
+		}
+		states[spec.Name] = state
+	}
+	p.config = cfg
+	p.states = states
+}
+
+// nextBackup returns the next scheduled run after now, or zero for manual backups
+// Interval schedules count from now, so they restart with the service.
+func nextBackup(spec BackupSpec, now time.Time) time.Time {
+	if spec.Interval > 0 {
+		return now.Add(time.Duration(spec.Interval) * time.Hour)
+	}
+	if spec.At == "" {
+		return time.Time{}
+	}
+	at, _ := time.Parse("15:04", spec.At)
+	next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// schedule describes when a backup runs
+func (spec BackupSpec) schedule() string {
+	switch {
+	case spec.At != "":
+		return "daily at " + spec.At
+	case spec.Interval > 0:
+		return fmt.Sprintf("every %d hours", spec.Interval)
+	}
+	return "manual"
+}
+
+// run starts the backups that are due and sleeps until the next one
+func (p *BackupPlugin) run() {
+	defer p.wg.Done()
+
+	for {
+		now := time.Now()
+		var due []BackupSpec
+		var wake time.Time
+		p.mu.Lock()
+		for _, spec := range p.config.Backups {
+			state := p.states[spec.Name]
+			if state.next.IsZero() {
+				continue
+			}
+			if !state.next.After(now) {
+				due = append(due, spec)
+				state.next = nextBackup(spec, now)
+			}
+			if wake.IsZero() || state.next.Before(wake) {
+				wake = state.next
+			}
+		}
+		p.mu.Unlock()
+
+		for _, spec := range due {
+			if _, err := p.start(spec); err != nil {
+				slog.Warn("Scheduled backup skipped", "backup", spec.Name, "error", err)
+			}
+		}
+
+		// Wake at least hourly so clock changes are noticed
+		sleep := time.Hour
+		if !wake.IsZero() && time.Until(wake) < sleep {
+			sleep = time.Until(wake)
+		}
+		timer := time.NewTimer(sleep)
+		select {
+		case <-p.stopChan:
+			timer.Stop()
+			return
+		case <-p.trigger:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// start runs a backup as a job unless it is already running
+func (p *BackupPlugin) start(spec BackupSpec) (*jobEntry, error) {
+	p.mu.Lock()
+	state := p.states[spec.Name]
+	if state.running {
+		p.mu.Unlock()
+		return nil, fiber.NewError(fiber.StatusConflict, fmt.Sprintf("backup %s is already running", spec.Name))
+	}
+	state.running = true
+	cfg := p.config
+	p.mu.Unlock()
+
+	entry := startJob(backupJobType, "Back up "+spec.Name+" to "+spec.Target, func(ctx context.Context, job *JobHandle) (interface{}, error) {
+		result, err := runBackup(ctx, cfg, spec, job)
+		now := time.Now()
+		result.Finished = &now
+		if err != nil {
+			result.Error = err.Error()
+		}
+
+		p.mu.Lock()
+		state.running = false
+		state.last = result
+		p.mu.Unlock()
+
+		if err != nil {
+			slog.Error("Backup failed", "backup", spec.Name, "target", spec.Target, "error", err)
+			PublishEvent("backup.failed", backupEventSource, fiber.Map{"name": spec.Name, "target": spec.Target, "error": err.Error()})
+			return nil, err
+		}
+		slog.Info("Backup finished", "backup", spec.Name, "target", spec.Target, "files", len(result.Files), "bytes", result.Bytes)
+		PublishEvent("backup.completed", backupEventSource, fiber.Map{"name": spec.Name, "target": spec.Target, "files": result.Files, "bytes": result.Bytes})
+		return result, nil
+	})
+
+	p.mu.Lock()
+	state.jobID = entry.snapshot().ID
+	p.mu.Unlock()
+	return entry, nil
+}
+
+// backupStore returns the store of a target and the directory files are written to before put
+func backupStore(cfg BackupConfig, target string) (remoteStore, string, string, error) {
+	remote, dir, err := parseTarget(target, cfg.Remotes)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if remote == nil {
+		// Written next to the final files, so put only renames
+		return &localStore{dir: dir}, "", dir, nil
+	}
+	return newRemoteStore(*remote), dir, cfg.StagingDir, nil
+}
+
+// runBackup exports the images and volumes of a backup to its target and applies the retention policy
+func runBackup(ctx context.Context, cfg BackupConfig, spec BackupSpec, job *JobHandle) (*BackupRun, error) {
+	start := time.Now()
+	result := &BackupRun{Time: start, Target: spec.Target, Images: []string{}, Volumes: []string{}, Files: []string{}}
+	if cfg.DockerClient == nil {
+		return result, fmt.Errorf("docker is not available")
+	}
+	store, dir, staging, err := backupStore(cfg, spec.Target)
+	if err != nil {
+		return result, err
+	}
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return result, err
+	}
+	prefix := spec.Name + "-" + start.Format(backupTimeFormat) + "-"
+
+	// write creates a file in the staging directory and hands it to the store
+	write := func(name string, fill func(w io.Writer) error) error {
+		tmp, err := os.CreateTemp(staging, "."+name+".part-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		tmp.Chmod(0644)
+		err = fill(tmp)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(tmp.Name())
+		if err != nil {
+			return err
+		}
+		job.Message("storing " + name)
+		if err := store.put(ctx, tmp.Name(), path.Join(dir, name), nil); err != nil {
+			return err
+		}
+		result.Files = append(result.Files, name)
+		result.Bytes += info.Size()
+		return nil
+	}
+
+	if len(spec.Images) > 0 {
+		tags, warnings, err := matchImages(ctx, cfg.DockerClient, spec.Images)
+		if err != nil {
+			return result, err
+		}
+		result.Warnings = append(result.Warnings, warnings...)
+		if len(tags) > 0 {
+			job.Message(fmt.Sprintf("exporting %d images", len(tags)))
+			err := write(prefix+"images.tar", func(w io.Writer) error {
+				reader, err := cfg.DockerClient.ImageSave(ctx, tags)
+				if err != nil {
+					return err
+				}
+				defer reader.Close()
+				_, err = copyContext(ctx, w, job.Reader(reader, 0))
+				return err
+			})
+			if err != nil {
+				return result, fmt.Errorf("exporting images: %w", err)
+			}
+			result.Images = tags
+		}
+	}
+
+	for _, name := range spec.Volumes {
+		vol, err := cfg.DockerClient.VolumeInspect(ctx, name)
+		if err != nil {
+			return result, fmt.Errorf("volume %s: %w", name, err)
+		}
+		job.Message("archiving volume " + name)
+		err = write(prefix+"volume-"+name+".tar.gz", func(w io.Writer) error {
+			archive := newArchiveWriter(w)
+			var done int64
+			_, err := archive.addTree(ctx, vol.Mountpoint, name, func(rel string, size int64) {
+				done += size
+				job.Progress(done, 0, "bytes")
+			})
+			if closeErr := archive.close(); err == nil {
+				err = closeErr
+			}
+			return err
+		})
+		if err != nil {
+			return result, fmt.Errorf("archiving volume %s: %w", name, err)
+		}
+		result.Volumes = append(result.Volumes, name)
+	}
+
+	if len(result.Files) == 0 {
+		return result, fmt.Errorf("no image matches %s", strings.Join(spec.Images, ", "))
+	}
+
+	if spec.Keep > 0 {
+		job.Message("applying retention")
+		removed, err := pruneBackups(ctx, store, dir, spec.Name, spec.Keep)
+		result.Removed = removed
+		if err != nil {
+			result.Warnings = append(result.Warnings, "retention: "+err.Error())
+		}
+	}
+	return result, nil
+}
+
+// matchImages returns the tags matching the patterns; a pattern without a tag matches every tag of a repository
+func matchImages(ctx context.Context, cli *client.Client, patterns []string) ([]string, []string, error) {
+	images, err := cli.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tags, warnings []string
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matched := false
+		for _, img := range images {
+			for _, tag := range img.RepoTags {
+				subject := tag
+				if !strings.Contains(path.Base(pattern), ":") {
+					subject = tag[:strings.LastIndex(tag, ":")]
+				}
+				if ok, _ := path.Match(pattern, subject); !ok {
+					continue
+				}
+				matched = true
+				if !seen[tag] {
+					seen[tag] = true
+					tags = append(tags, tag)
+				}
+			}
+		}
+		if !matched {
+			warnings = append(warnings, fmt.Sprintf("no image matches %s", pattern))
+		}
+	}
+	sort.Strings(tags)
+	return tags, warnings, nil
+}
+
+// backupSets groups the files of a backup at its target into runs, newest first
+func backupSets(ctx context.Context, store remoteStore, dir, name string) ([]BackupSet, error) {
+	entries, err := store.list(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byTime := make(map[string]*BackupSet)
+	for _, entry := range entries {
+		match := backupFile.FindStringSubmatch(entry.Name)
+		if entry.IsDir || match == nil || match[1] != name {
+			continue
+		}
+		set, ok := byTime[match[2]]
+		if !ok {
+			t, _ := time.ParseInLocation(backupTimeFormat, match[2], time.Local)
+			set = &BackupSet{Time: t}
+			byTime[match[2]] = set
+		}
+		set.Files = append(set.Files, entry)
+		set.Bytes += entry.Size
+	}
+
+	sets := make([]BackupSet, 0, len(byTime))
+	for _, set := range byTime {
+		sets = append(sets, *set)
+	}
+	sort.Slice(sets, func(i, j int) bool {
+		return sets[i].Time.After(sets[j].Time)
+	})
+	return sets, nil
+}
+
+// pruneBackups removes the runs of a backup beyond the newest keep
+func pruneBackups(ctx context.Context, store remoteStore, dir, name string, keep int) ([]string, error) {
+	sets, err := backupSets(ctx, store, dir, name)
+	if err != nil || len(sets) <= keep {
+		return nil, err
+	}
+	var removed []string
+	for _, set := range sets[keep:] {
+		for _, file := range set.Files {
+			if err := store.remove(ctx, path.Join(dir, file.Name)); err != nil {
+				return removed, err
+			}
+			removed = append(removed, file.Name)
+		}
+	}
+	return removed, nil
+}
+
+// findBackup returns a configured backup by name
+func (p *BackupPlugin) findBackup(name string) (BackupSpec, bool) {
+	for _, spec := range p.getConfig().Backups {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+	return BackupSpec{}, false
+}
+
+// handleStatus handles GET /api/backup
+// Lists the configured backups with their schedule and last run
+func (p *BackupPlugin) handleStatus(c *fiber.Ctx) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	statuses := make([]BackupStatus, 0, len(p.config.Backups))
+	for _, spec := range p.config.Backups {
+		state := p.states[spec.Name]
+		status := BackupStatus{
+			Name:     spec.Name,
+			Target:   spec.Target,
+			Schedule: spec.schedule(),
+			Keep:     spec.Keep,
+			Running:  state.running,
+			JobID:    state.jobID,
+			LastRun:  state.last,
+		}
+		if !state.next.IsZero() {
+			next := state.next
+			status.NextRun = &next
+		}
+		statuses = append(statuses, status)
+	}
+	return SendSuccess(c, statuses, "")
+}
+
+// handleListRuns handles GET /api/backup/:name/runs
+// Lists the runs stored at the target, newest first
+func (p *BackupPlugin) handleListRuns(c *fiber.Ctx) error {
+	spec, ok := p.findBackup(c.Params("name"))
+	if !ok {
+		return SendErrorMessage(c, 404, fmt.Sprintf("Backup %s not found", c.Params("name")))
+	}
+	store, dir, _, err := backupStore(p.getConfig(), spec.Target)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+
+	ctx, cancel := RequestContext(c)
+	defer cancel()
+	sets, err := backupSets(ctx, store, dir, spec.Name)
+	if err != nil {
+		return SendError(c, 502, err)
+	}
+	return SendSuccess(c, sets, "")
+}
+
+// handleRun handles POST /api/backup/:name/run
+// Runs a backup now; supports ?async=true
+func (p *BackupPlugin) handleRun(c *fiber.Ctx) error {
+	spec, ok := p.findBackup(c.Params("name"))
+	if !ok {
+		return SendErrorMessage(c, 404, fmt.Sprintf("Backup %s not found", c.Params("name")))
+	}
+	entry, err := p.start(spec)
+	if err != nil {
+		return SendErrorMessage(c, 409, err.Error())
+	}
+	return waitJob(c, entry, "Backup finished")
+}
+
+// getConfig returns the current configuration
+func (p *BackupPlugin) getConfig() BackupConfig {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.config
+}
+
+// normalizeBackupConfig fills in defaults
+func normalizeBackupConfig(cfg BackupConfig) BackupConfig {
+	if cfg.StagingDir == "" {
+		cfg.StagingDir = DefaultBackupStagingDir
+	}
+	return cfg
+}
+
+// Register the plugin
+func init() {
+	Register("backup", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[BackupConfig]("backup", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewBackupPlugin(cfg)
+	}, "dockerclient")
+}
@@ -0,0 +1,90 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestContext returns a context for the work of a request: it carries the
+// request ID and ends when the client disconnects or the server shuts down
+// The returned cancel must be called before the handler returns. Work done in
+// a body stream writer cannot use it and notices disconnects by failed writes.
+func RequestContext(c *fiber.Ctx) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(c.UserContext())
+	stopWatch := watchDisconnect(c.Context().Conn(), cancel)
+
+	serverDone := c.Context().Done()
+	go func() {
+		select {
+		case <-serverDone:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		stopWatch()
+		cancel()
+	}
+}
+
+// watchDisconnect calls disconnected when the client closes the connection
+// It peeks at the socket without consuming data, so a pipelined request that
+// arrives meanwhile is left for the server. The returned function stops
+// watching and must be called before the server uses the connection again.
+func watchDisconnect(conn net.Conn, disconnected func()) func() {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return func() {}
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 1)
+		for {
+			var n int
+			var peekErr error
+			err := raw.Read(func(fd uintptr) bool {
+				n, _, peekErr = syscall.Recvfrom(int(fd), buf, syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+				return peekErr != syscall.EAGAIN
+			})
+
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			switch {
+			case errors.Is(err, os.ErrDeadlineExceeded):
+				// The server's read timeout does not apply while the handler runs
+				conn.SetReadDeadline(time.Time{})
+			case err != nil, peekErr != nil, n == 0:
+				disconnected()
+				return
+			default:
+				// Data of the next request: the client is still there
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		// Wake the watcher, then leave the deadline to the server
+		conn.SetReadDeadline(time.Unix(1, 0))
+		<-done
+		conn.SetReadDeadline(time.Time{})
+	}
+}
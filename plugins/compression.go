@@ -0,0 +1,122 @@
+package plugins
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// Compression levels for server.compression
+const (
+	CompressionOff     = "off"
+	CompressionSpeed   = "speed"
+	CompressionDefault = "default"
+	CompressionBest    = "best"
+)
+
+// compressibleTypes lists the content type prefixes worth compressing
+// Binary downloads and archives are sent as they are
+var compressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// CompressionMiddleware compresses responses with brotli, gzip or deflate
+// depending on the client's Accept-Encoding
+func CompressionMiddleware(level string) (fiber.Handler, error) {
+	var brotliLevel, otherLevel int
+	switch level {
+	case CompressionOff:
+		return func(c *fiber.Ctx) error { return c.Next() }, nil
+	case "", CompressionSpeed:
+		brotliLevel, otherLevel = fasthttp.CompressBrotliBestSpeed, fasthttp.CompressBestSpeed
+	case CompressionDefault:
+		brotliLevel, otherLevel = fasthttp.CompressBrotliDefaultCompression, fasthttp.CompressDefaultCompression
+	case CompressionBest:
+		brotliLevel, otherLevel = fasthttp.CompressBrotliBestCompression, fasthttp.CompressBestCompression
+	default:
+		return nil, fmt.Errorf("server.compression must be off, speed, default or best, got %q", level)
+	}
+
+	compressor := fasthttp.CompressHandlerBrotliLevel(func(*fasthttp.RequestCtx) {}, brotliLevel, otherLevel)
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if shouldCompress(c) {
+			compressor(c.Context())
+		}
+		return nil
+	}, nil
+}
+
+// shouldCompress reports whether a response is text-like and has a body
+// Event streams are left alone so every event reaches the client immediately
+func shouldCompress(c *fiber.Ctx) bool {
+	switch c.Response().StatusCode() {
+	case fiber.StatusSwitchingProtocols, fiber.StatusNoContent, fiber.StatusNotModified:
+		return false
+	}
+	contentType := string(c.Response().Header.ContentType())
+	if strings.HasPrefix(contentType, "text/event-stream") {
+		return false
+	}
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ETagMiddleware adds an ETag to buffered GET responses and answers
+// matching If-None-Match requests with 304
+// Streamed responses (downloads, event streams) are not hashed
+func ETagMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+			return c.Next()
+		}
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		resp := c.Response()
+		if resp.StatusCode() != fiber.StatusOK || resp.IsBodyStream() || len(resp.Header.Peek(fiber.HeaderETag)) > 0 {
+			return nil
+		}
+		body := resp.Body()
+		if len(body) == 0 {
+			return nil
+		}
+
+		etag := contentETag(body)
+		if etagMatches(c.Get(fiber.HeaderIfNoneMatch), etag) {
+			c.Context().ResetBody()
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+		c.Set(fiber.HeaderETag, etag)
+		return nil
+	}
+}
+
+// etagMatches reports whether an If-None-Match header matches an ETag
+// Uses weak comparison as required for If-None-Match
+func etagMatches(header string, etag string) bool {
+	if header == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
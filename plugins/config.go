@@ -0,0 +1,508 @@
+package plugins
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config editor constants
+const (
+	RedactedPlaceholder = "********"
+	diffContextLines    = 3
+)
+
+// secretKeyPattern matches config keys whose values must not be exposed
+var secretKeyPattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|psk|private_key|api_key|community)`)
+
+// ConfigPluginConfig connects the config plugin to the server's configuration handling
+type ConfigPluginConfig struct {
+	Path      string
+	Validate  func([]byte) error
+	Reload    func() (interface{}, error)
+	Effective func() interface{}
+}
+
+// ConfigPlugin exposes the server configuration for viewing and editing
+type ConfigPlugin struct {
+	configPath string
+	validate   func([]byte) error
+	reload     func() (interface{}, error)
+	effective  func() interface{}
+	mu         sync.Mutex
+}
+
+// NewConfigPlugin creates a new config plugin instance
+func NewConfigPlugin(configPath string, validate func([]byte) error, reload func() (interface{}, error), effective func() interface{}) (*ConfigPlugin, error) {
+	if configPath == "" {
+		return nil, fmt.Errorf("config_path is required in config plugin configuration")
+	}
+	if validate == nil || reload == nil || effective == nil {
+		return nil, fmt.Errorf("config plugin requires validate, reload and effective callbacks")
+	}
+
+	return &ConfigPlugin{
+		configPath: configPath,
+		validate:   validate,
+		reload:     reload,
+		effective:  effective,
+	}, nil
+}
+
+// Name returns the plugin identifier
+func (p *ConfigPlugin) Name() string {
+	return "config"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *ConfigPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/config")
+
+	api.Get("/", p.getEffective)
+	api.Get("/file", p.getFile)
+	api.Post("/preview", p.previewFile)
+	api.Post("/save", p.saveFile)
+}
+
+// Shutdown performs cleanup
+func (p *ConfigPlugin) Shutdown() error {
+	return nil
+}
+
+// getEffective handles GET /api/config
+func (p *ConfigPlugin) getEffective(c *fiber.Ctx) error {
+	var node yaml.Node
+	if err := node.Encode(p.effective()); err != nil {
+		return SendError(c, 500, fmt.Errorf("failed to encode config: %w", err))
+	}
+	redactYAMLNode(&node)
+
+	return SendSuccess(c, yamlNodeToOrderedJSON(&node), "")
+}
+
+// getFile handles GET /api/config/file
+// Returns the config file with secret values replaced by a placeholder
+func (p *ConfigPlugin) getFile(c *fiber.Ctx) error {
+	content, err := p.redactedFile()
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+
+	return SendSuccess(c, fiber.Map{
+		"path":    p.configPath,
+		"content": content,
+	}, "")
+}
+
+// previewFile handles POST /api/config/preview
+func (p *ConfigPlugin) previewFile(c *fiber.Ctx) error {
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+
+	current, err := p.redactedFile()
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+
+	result := fiber.Map{
+		"diff":  lineDiff(current, req.Content),
+		"valid": true,
+	}
+
+	if _, err := p.prepareContent(req.Content); err != nil {
+		result["valid"] = false
+		result["error"] = err.Error()
+	}
+
+	return SendSuccess(c, result, "")
+}
+
+// saveFile handles POST /api/config/save
+func (p *ConfigPlugin) saveFile(c *fiber.Ctx) error {
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data, err := p.prepareContent(req.Content)
+	if err != nil {
+		return SendErrorMessage(c, 400, fmt.Sprintf("Invalid configuration: %v", err))
+	}
+
+	backupPath, err := p.backupFile()
+	if err != nil {
+		return SendError(c, 500, fmt.Errorf("failed to back up config: %w", err))
+	}
+
+	if err := writeFileAtomic(p.configPath, data); err != nil {
+		return SendError(c, 500, fmt.Errorf("failed to write config: %w", err))
+	}
+
+	slog.InfoContext(c.UserContext(), "Configuration file updated", "path", p.configPath, "backup", backupPath)
+
+	reloadResult, err := p.reload()
+	if err != nil {
+		return c.Status(500).JSON(APIResponse{
+			Success: false,
+			Data:    fiber.Map{"backup": backupPath, "reload": reloadResult},
+			Error:   fmt.Sprintf("config saved but reload failed: %v", err),
+		})
+	}
+
+	return SendSuccess(c, fiber.Map{
+		"backup": backupPath,
+		"reload": reloadResult,
+	}, "Configuration saved")
+}
+
+// redactedFile reads the config file and returns it with secrets redacted
+func (p *ConfigPlugin) redactedFile() (string, error) {
+	data, err := os.ReadFile(p.configPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read config: %w", err)
+	}
+
+	content, err := redactYAMLText(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse config: %w", err)
+	}
+	return content, nil
+}
+
+// prepareContent restores redacted secrets from the current file and validates the result
+func (p *ConfigPlugin) prepareContent(content string) ([]byte, error) {
+	data := []byte(content)
+
+	if currentData, err := os.ReadFile(p.configPath); err == nil {
+		restored, err := restoreYAMLText(content, currentData)
+		if err != nil {
+			return nil, err
+		}
+		data = []byte(restored)
+	}
+
+	if err := p.validate(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// backupFile copies the current config file to a timestamped backup
+func (p *ConfigPlugin) backupFile() (string, error) {
+	data, err := os.ReadFile(p.configPath)
+	if err != nil {
+		return "", err
+	}
+
+	backupPath := fmt.Sprintf("%s.%s.bak", p.configPath, time.Now().Format("20060102-150405"))
+	if err := os.WriteFile(backupPath, data, 0600); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
+
+// writeFileAtomic writes data to a temp file in the same directory and renames it into place
+// A symlinked path replaces the link target; mode and ownership are kept
+func writeFileAtomic(path string, data []byte) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	mode := os.FileMode(0644)
+	uid, gid := -1, -1
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			uid, gid = int(stat.Uid), int(stat.Gid)
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return err
+	}
+	if uid >= 0 && (uid != os.Getuid() || gid != os.Getgid()) {
+		// Keeps files owned by other daemons readable for them; only possible as root
+		os.Lchown(tmpPath, uid, gid)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	// Persist the rename itself
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// redactYAMLNode replaces scalar values of secret keys with a placeholder
+func redactYAMLNode(node *yaml.Node) {
+	for _, secret := range secretValueNodes(node) {
+		secret.Value = RedactedPlaceholder
+		secret.Tag = "!!str"
+		secret.Style = yaml.DoubleQuotedStyle
+	}
+}
+
+// secretValueNodes returns the non-empty scalar value nodes of secret keys
+func secretValueNodes(node *yaml.Node) []*yaml.Node {
+	var result []*yaml.Node
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			result = append(result, secretValueNodes(child)...)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode := node.Content[i]
+			valueNode := node.Content[i+1]
+			if valueNode.Kind == yaml.ScalarNode && valueNode.Value != "" && secretKeyPattern.MatchString(keyNode.Value) {
+				result = append(result, valueNode)
+				continue
+			}
+			result = append(result, secretValueNodes(valueNode)...)
+		}
+	}
+	return result
+}
+
+// isSingleLineScalar reports whether a scalar node occupies a single source line
+func isSingleLineScalar(node *yaml.Node) bool {
+	return node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0 && !strings.Contains(node.Value, "\n")
+}
+
+// redactYAMLText replaces secret values in YAML source text, preserving layout and comments
+// Falls back to re-serializing the document when a secret spans multiple lines
+func redactYAMLText(data []byte) (string, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return "", err
+	}
+
+	secrets := secretValueNodes(&node)
+	lines := strings.Split(string(data), "\n")
+	for _, secret := range secrets {
+		if !isSingleLineScalar(secret) || secret.Line > len(lines) {
+			redactYAMLNode(&node)
+			out, err := yaml.Marshal(&node)
+			return string(out), err
+		}
+	}
+
+	for _, secret := range secrets {
+		line := lines[secret.Line-1]
+		redacted := line[:secret.Column-1] + `"` + RedactedPlaceholder + `"`
+		if secret.LineComment != "" {
+			redacted += " " + secret.LineComment
+		}
+		lines[secret.Line-1] = redacted
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// restoreYAMLText replaces redaction placeholders with the original secret values
+func restoreYAMLText(content string, original []byte) (string, error) {
+	var node, originalNode yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &node); err != nil {
+		return "", err
+	}
+	if err := yaml.Unmarshal(original, &originalNode); err != nil {
+		// Nothing to restore from an unparseable original
+		return content, nil
+	}
+
+	lines := strings.Split(content, "\n")
+	for _, pair := range redactedPairs(&node, &originalNode) {
+		placeholder, originalValue := pair[0], pair[1]
+
+		encoded, err := yaml.Marshal(originalValue)
+		if err != nil {
+			return "", err
+		}
+
+		line := lines[placeholder.Line-1]
+		rest := line[placeholder.Column-1:]
+		quoted := len(rest) > 0 && (rest[0] == '"' || rest[0] == '\'')
+		tokenLen := len(RedactedPlaceholder)
+		if quoted {
+			tokenLen += 2
+		}
+		lines[placeholder.Line-1] = line[:placeholder.Column-1] + strings.TrimSuffix(string(encoded), "\n") + rest[tokenLen:]
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// redactedPairs walks two YAML trees in parallel and returns placeholder nodes
+// paired with the original secret value at the same path
+func redactedPairs(node *yaml.Node, original *yaml.Node) [][2]*yaml.Node {
+	if node == nil || original == nil {
+		return nil
+	}
+
+	var result [][2]*yaml.Node
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) > 0 && len(original.Content) > 0 {
+			result = redactedPairs(node.Content[0], original.Content[0])
+		}
+	case yaml.SequenceNode:
+		if original.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i, child := range node.Content {
+			if i < len(original.Content) {
+				result = append(result, redactedPairs(child, original.Content[i])...)
+			}
+		}
+	case yaml.MappingNode:
+		if original.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			valueNode := node.Content[i+1]
+			originalValue := mappingValue(original, key)
+
+			if valueNode.Kind == yaml.ScalarNode && valueNode.Value == RedactedPlaceholder && secretKeyPattern.MatchString(key) {
+				if originalValue != nil && originalValue.Kind == yaml.ScalarNode {
+					result = append(result, [2]*yaml.Node{valueNode, originalValue})
+				}
+				continue
+			}
+			result = append(result, redactedPairs(valueNode, originalValue)...)
+		}
+	}
+	return result
+}
+
+// mappingValue returns the value node for a key in a mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// lineDiff returns a unified-style line diff between two texts
+// Unchanged regions are collapsed to a few lines of context around each change
+func lineDiff(oldText, newText string) string {
+	a := strings.Split(strings.TrimSuffix(oldText, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(newText, "\n"), "\n")
+
+	// Longest common subsequence table
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// Build the edit script
+	type diffLine struct {
+		op   byte
+		text string
+	}
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		default:
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		}
+	}
+
+	// Mark lines within context distance of a change
+	keep := make([]bool, len(lines))
+	for idx, line := range lines {
+		if line.op == ' ' {
+			continue
+		}
+		for k := max(0, idx-diffContextLines); k <= min(len(lines)-1, idx+diffContextLines); k++ {
+			keep[k] = true
+		}
+	}
+
+	var sb strings.Builder
+	skipped := false
+	for idx, line := range lines {
+		if !keep[idx] {
+			skipped = true
+			continue
+		}
+		if skipped || idx == 0 {
+			sb.WriteString("@@\n")
+			skipped = false
+		}
+		sb.WriteByte(line.op)
+		sb.WriteString(line.text)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// Register the plugin
+func init() {
+	Register("config", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[ConfigPluginConfig]("config", config)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewConfigPlugin(cfg.Path, cfg.Validate, cfg.Reload, cfg.Effective)
+	})
+}
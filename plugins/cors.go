@@ -0,0 +1,157 @@
+package plugins
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CORS defaults
+var (
+	DefaultCORSMethods       = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	DefaultCORSHeaders       = []string{"Content-Type", "Authorization", "If-None-Match", "If-Match", RequestIDHeader}
+	DefaultCORSExposeHeaders = []string{RequestIDHeader, "ETag", "Content-Disposition"}
+)
+
+// DefaultCORSMaxAge is how long browsers may cache a preflight answer
+const DefaultCORSMaxAge = 600 // seconds
+
+// CORSConfig lets frontends on other origins call the API from the browser
+// Without allowed origins, browsers keep blocking cross-origin API calls.
+type CORSConfig struct {
+	AllowOrigins     []string `yaml:"allow_origins"`     // e.g. https://grafana.example.org, https://*.example.org or *
+	AllowMethods     []string `yaml:"allow_methods"`     // default GET, POST, PUT, PATCH, DELETE
+	AllowHeaders     []string `yaml:"allow_headers"`     // request headers scripts may send
+	ExposeHeaders    []string `yaml:"expose_headers"`    // response headers scripts may read
+	AllowCredentials bool     `yaml:"allow_credentials"` // let browsers send cookies and HTTP auth
+	MaxAge           int      `yaml:"max_age"`           // seconds, default 600
+}
+
+// CORS settings shared by the middleware, replaced on reload
+var (
+	corsConfig CORSConfig
+	corsMu     sync.RWMutex
+)
+
+// normalize fills in defaults and canonical spellings
+func (cfg CORSConfig) normalize() CORSConfig {
+	if len(cfg.AllowMethods) == 0 {
+		cfg.AllowMethods = DefaultCORSMethods
+	}
+	if len(cfg.AllowHeaders) == 0 {
+		cfg.AllowHeaders = DefaultCORSHeaders
+	}
+	if len(cfg.ExposeHeaders) == 0 {
+		cfg.ExposeHeaders = DefaultCORSExposeHeaders
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = DefaultCORSMaxAge
+	}
+	methods := make([]string, len(cfg.AllowMethods))
+	for i, method := range cfg.AllowMethods {
+		methods[i] = strings.ToUpper(method)
+	}
+	cfg.AllowMethods = methods
+	origins := make([]string, len(cfg.AllowOrigins))
+	for i, origin := range cfg.AllowOrigins {
+		origins[i] = strings.TrimSuffix(strings.ToLower(origin), "/")
+	}
+	cfg.AllowOrigins = origins
+	return cfg
+}
+
+// Validate checks the origins and the credentials setting
+func (cfg CORSConfig) Validate() error {
+	cfg = cfg.normalize()
+	for _, origin := range cfg.AllowOrigins {
+		if origin == "*" {
+			if cfg.AllowCredentials {
+				return fmt.Errorf("server.cors: allow_credentials cannot be combined with the * origin")
+			}
+			continue
+		}
+		u, err := url.Parse(strings.Replace(origin, "*.", "", 1))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return fmt.Errorf("server.cors: invalid origin %q, expected scheme://host[:port]", origin)
+		}
+		if strings.Contains(origin, "*") && !strings.HasPrefix(origin, u.Scheme+"://*.") {
+			return fmt.Errorf("server.cors: invalid origin %q, wildcards only match subdomains (https://*.example.org)", origin)
+		}
+	}
+	if cfg.MaxAge < 0 {
+		return fmt.Errorf("server.cors: max_age must not be negative")
+	}
+	return nil
+}
+
+// ConfigureCORS replaces the CORS settings used by CORSMiddleware
+func ConfigureCORS(cfg CORSConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	corsMu.Lock()
+	defer corsMu.Unlock()
+	corsConfig = cfg.normalize()
+	return nil
+}
+
+// allowsOrigin reports whether an Origin header matches an allowed origin
+func (cfg CORSConfig) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range cfg.AllowOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		if scheme, domain, ok := strings.Cut(allowed, "://*."); ok {
+			if host, ok := strings.CutPrefix(origin, scheme+"://"); ok && strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CORSMiddleware adds CORS headers to API responses for allowed origins and
+// answers their preflight requests
+// Must be registered after LegacyAPIRewrite so paths are versioned
+func CORSMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		origin := c.Get(fiber.HeaderOrigin)
+		if origin == "" || !strings.HasPrefix(c.Path(), "/api/") {
+			return c.Next()
+		}
+		corsMu.RLock()
+		cfg := corsConfig
+		corsMu.RUnlock()
+
+		c.Vary(fiber.HeaderOrigin)
+		if !cfg.allowsOrigin(origin) {
+			return c.Next()
+		}
+
+		allowOrigin := origin
+		if len(cfg.AllowOrigins) == 1 && cfg.AllowOrigins[0] == "*" {
+			allowOrigin = "*"
+		}
+		c.Set(fiber.HeaderAccessControlAllowOrigin, allowOrigin)
+		if cfg.AllowCredentials {
+			c.Set(fiber.HeaderAccessControlAllowCredentials, "true")
+		}
+
+		// Preflight: the browser asks before sending the actual request
+		if c.Method() == fiber.MethodOptions && c.Get(fiber.HeaderAccessControlRequestMethod) != "" {
+			c.Vary(fiber.HeaderAccessControlRequestMethod, fiber.HeaderAccessControlRequestHeaders)
+			c.Set(fiber.HeaderAccessControlAllowMethods, strings.Join(cfg.AllowMethods, ", "))
+			c.Set(fiber.HeaderAccessControlAllowHeaders, strings.Join(cfg.AllowHeaders, ", "))
+			c.Set(fiber.HeaderAccessControlMaxAge, strconv.Itoa(cfg.MaxAge))
+			return c.SendStatus(fiber.StatusNoContent)
+		}
+
+		c.Set(fiber.HeaderAccessControlExposeHeaders, strings.Join(cfg.ExposeHeaders, ", "))
+		return c.Next()
+	}
+}
@@ -2,9 +2,16 @@ package plugins
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"gopkg.in/yaml.v3"
@@ -110,6 +117,8 @@ func yamlNodeToOrderedJSON(node *yaml.Node) interface{} {
 }
 
 // updateYAMLNodeWithValues updates a yaml.Node tree with values from a map while preserving structure
+// Comments, anchors and scalar formatting are kept for values that did not change,
+// and keys missing from the file are appended
 func updateYAMLNodeWithValues(node *yaml.Node, values map[string]interface{}) {
 	switch node.Kind {
 	case yaml.DocumentNode:
@@ -118,33 +127,131 @@ func updateYAMLNodeWithValues(node *yaml.Node, values map[string]interface{}) {
 		}
 
 	case yaml.MappingNode:
+		existing := make(map[string]bool)
 		for i := 0; i < len(node.Content); i += 2 {
-			keyNode := node.Content[i]
-			valueNode := node.Content[i+1]
-			key := keyNode.Value
+			key := node.Content[i].Value
+			existing[key] = true
 
 			if newValue, exists := values[key]; exists {
-				// Update the value node based on the new value type
-				switch v := newValue.(type) {
-				case map[string]interface{}:
-					// Recursively update nested maps
-					if valueNode.Kind == yaml.MappingNode {
-						updateYAMLNodeWithValues(valueNode, v)
-					}
-				case []interface{}:
-					// Handle arrays - rebuild the sequence
-					valueNode.Kind = yaml.SequenceNode
-					valueNode.Content = nil
-					for _, item := range v {
-						valueNode.Content = append(valueNode.Content, createYAMLNode(item))
+				node.Content[i+1] = updateYAMLValue(node.Content[i+1], newValue)
+			}
+		}
+
+		// Keys added by the client, sorted since JSON objects arrive unordered
+		for _, key := range sortedKeys(values) {
+			if existing[key] {
+				continue
+			}
+			keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: key, Tag: "!!str"}
+			node.Content = append(node.Content, keyNode, createYAMLNode(values[key]))
+		}
+	}
+}
+
+// updateYAMLValue updates a value node in place, or returns a replacement when the
+// value no longer fits the node (an alias that changed, or a different kind)
+func updateYAMLValue(node *yaml.Node, value interface{}) *yaml.Node {
+	if node.Kind != yaml.AliasNode {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if node.Kind == yaml.MappingNode {
+				updateYAMLNodeWithValues(node, v)
+				return node
+			}
+		case []interface{}:
+			if node.Kind == yaml.SequenceNode {
+				updateYAMLSequence(node, v)
+				return node
+			}
+		default:
+			if node.Kind == yaml.ScalarNode {
+				if !yamlValueEqual(node, v) {
+					updateScalarNode(node, v)
+					if node.Tag != "!!str" {
+						node.Style = 0
 					}
-				default:
-					// Update scalar value
-					updateScalarNode(valueNode, v)
 				}
+				return node
 			}
 		}
+	} else if yamlValueEqual(node, value) {
+		// Aliases stay as long as they still resolve to the submitted value
+		return node
 	}
+
+	replacement := createYAMLNode(value)
+	replacement.Anchor = node.Anchor
+	replacement.HeadComment = node.HeadComment
+	replacement.LineComment = node.LineComment
+	replacement.FootComment = node.FootComment
+	return replacement
+}
+
+// updateYAMLSequence updates sequence items in place, appending or dropping items as needed
+func updateYAMLSequence(node *yaml.Node, items []interface{}) {
+	for i, item := range items {
+		if i < len(node.Content) {
+			node.Content[i] = updateYAMLValue(node.Content[i], item)
+		} else {
+			node.Content = append(node.Content, createYAMLNode(item))
+		}
+	}
+	if len(items) < len(node.Content) {
+		node.Content = node.Content[:len(items)]
+	}
+}
+
+// yamlValueEqual reports whether a node holds the same value as decoded JSON
+func yamlValueEqual(node *yaml.Node, value interface{}) bool {
+	encoded, err := json.Marshal(yamlNodeToOrderedJSON(node))
+	if err != nil {
+		return false
+	}
+	var current interface{}
+	if err := json.Unmarshal(encoded, &current); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(current, value)
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// clearMergeTags drops the explicit tag from merge keys ("<<"), which yaml.v3
+// would otherwise write out as "!!merge <<"
+func clearMergeTags(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!merge" {
+		node.Tag = ""
+	}
+	for _, child := range node.Content {
+		clearMergeTags(child)
+	}
+}
+
+// yamlIndent returns the indentation width used by a YAML document
+// Defaults to the yaml.v3 default of 4 spaces
+func yamlIndent(data []byte) int {
+	indent := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if width := len(line) - len(trimmed); width > 0 && (indent == 0 || width < indent) {
+			indent = width
+		}
+	}
+	if indent < 2 || indent > 8 {
+		return 4
+	}
+	return indent
 }
 
 // createYAMLNode creates a yaml.Node from an interface value
@@ -152,13 +259,13 @@ func createYAMLNode(value interface{}) *yaml.Node {
 	switch v := value.(type) {
 	case map[string]interface{}:
 		node := &yaml.Node{Kind: yaml.MappingNode}
-		for key, val := range v {
+		for _, key := range sortedKeys(v) {
 			keyNode := &yaml.Node{
 				Kind:  yaml.ScalarNode,
 				Value: key,
 				Tag:   "!!str",
 			}
-			node.Content = append(node.Content, keyNode, createYAMLNode(val))
+			node.Content = append(node.Content, keyNode, createYAMLNode(v[key]))
 		}
 		return node
 
@@ -226,22 +333,77 @@ func updateScalarNode(node *yaml.Node, value interface{}) {
 	}
 }
 
+// DefaultCPSLockTimeout is how long a save waits for the settings lock, in milliseconds
+const DefaultCPSLockTimeout = 2000
+
+// CPSConfig holds the cps section of the configuration
+type CPSConfig struct {
+	SettingsPath string    `yaml:"settings_path"`
+	LockFile     string    `yaml:"lock_file"`    // flock held while saving, shared with the radio daemon; empty disables
+	LockTimeout  int       `yaml:"lock_timeout"` // milliseconds
+	Hooks        []CPSHook `yaml:"hooks"`        // run in order after every save
+}
+
+// Validate checks the settings path and hooks
+func (cfg CPSConfig) Validate() error {
+	if cfg.SettingsPath == "" {
+		return fmt.Errorf("settings_path is required in cps plugin configuration")
+	}
+	for i, hook := range cfg.Hooks {
+		if err := hook.validate(); err != nil {
+			return fmt.Errorf("hooks[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
 // CPSPlugin provides Customer Programming Software functionality for editing settings
 type CPSPlugin struct {
-	settingsPath string
+	config   CPSConfig
+	configMu sync.RWMutex
+	mu       sync.Mutex // serializes saves
 }
 
 // NewCPSPlugin creates a new CPS plugin instance
-func NewCPSPlugin(settingsPath string) (*CPSPlugin, error) {
-	if settingsPath == "" {
-		return nil, fmt.Errorf("settings_path is required in cps plugin configuration")
+func NewCPSPlugin(cfg CPSConfig) (*CPSPlugin, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = DefaultCPSLockTimeout
 	}
 
 	return &CPSPlugin{
-		settingsPath: settingsPath,
+		config: cfg,
 	}, nil
 }
 
+// Reload applies new settings, lock and hook configuration
+func (p *CPSPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[CPSConfig]("cps", config)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = DefaultCPSLockTimeout
+	}
+
+	p.configMu.Lock()
+	p.config = cfg
+	p.configMu.Unlock()
+	return nil
+}
+
+// getConfig returns the current configuration
+func (p *CPSPlugin) getConfig() CPSConfig {
+	p.configMu.RLock()
+	defer p.configMu.RUnlock()
+	return p.config
+}
+
 // Name returns the plugin identifier
 func (p *CPSPlugin) Name() string {
 	return "cps"
@@ -249,10 +411,13 @@ func (p *CPSPlugin) Name() string {
 
 // RegisterRoutes adds the plugin's HTTP routes
 func (p *CPSPlugin) RegisterRoutes(app *fiber.App) {
-	api := app.Group("/api/cps")
+	api := APIGroup(app, "/cps")
 
 	api.Get("/load", p.loadSettings)
 	api.Post("/save", p.saveSettings)
+	api.Get("/sections", p.listSections)
+	api.Get("/section/:name", p.loadSection)
+	api.Put("/section/:name", p.saveSection)
 }
 
 // Shutdown performs cleanup
@@ -262,25 +427,21 @@ func (p *CPSPlugin) Shutdown() error {
 
 // loadSettings handles GET /api/cps/load
 func (p *CPSPlugin) loadSettings(c *fiber.Ctx) error {
-	// Read the settings file
-	data, err := os.ReadFile(p.settingsPath)
+	rootNode, data, err := readSettings(p.getConfig().SettingsPath)
 	if err != nil {
-		return SendError(c, 500, fmt.Errorf("failed to read settings file: %w", err))
-	}
-
-	// Parse YAML into yaml.Node to preserve key order
-	var rootNode yaml.Node
-	if err := yaml.Unmarshal(data, &rootNode); err != nil {
-		return SendError(c, 500, fmt.Errorf("failed to parse settings file: %w", err))
+		return SendError(c, 500, err)
 	}
 
 	// Convert to ordered JSON structure
-	orderedData := yamlNodeToOrderedJSON(&rootNode)
+	orderedData := yamlNodeToOrderedJSON(rootNode)
 
+	c.Set(fiber.HeaderETag, contentETag(data))
 	return SendSuccess(c, orderedData, "Settings loaded successfully")
 }
 
 // saveSettings handles POST /api/cps/save
+// The file is replaced atomically, so readers see either the old or the new settings
+// A save sent with If-Match is refused with 409 when the file changed since it was loaded.
 func (p *CPSPlugin) saveSettings(c *fiber.Ctx) error {
 	// Parse the request body into a generic structure
 	var newSettings map[string]interface{}
@@ -288,46 +449,152 @@ func (p *CPSPlugin) saveSettings(c *fiber.Ctx) error {
 		return SendErrorMessage(c, 400, "Invalid request body")
 	}
 
-	// Read the original YAML file to preserve structure and key order
-	originalData, err := os.ReadFile(p.settingsPath)
+	ifMatch := c.Get(fiber.HeaderIfMatch)
+	results, data, err := p.writeSettings(c.UserContext(), c.QueryBool("hooks", true), func(root *yaml.Node, data []byte) error {
+		if revision := contentETag(data); !revisionMatches(ifMatch, revision) {
+			return &cpsConflict{Revision: revision, Current: yamlNodeToOrderedJSON(root)}
+		}
+
+		// Update the yaml.Node tree with new values while preserving structure
+		updateYAMLNodeWithValues(root, newSettings)
+		return nil
+	})
+	if err != nil {
+		return sendSaveError(c, err)
+	}
+	return sendSaved(c, results, contentETag(data))
+}
+
+// readSettings reads and parses the settings file into a yaml.Node to
+// preserve key order, comments and anchors
+// An empty file yields an empty mapping.
+func readSettings(path string) (*yaml.Node, []byte, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return SendError(c, 500, fmt.Errorf("failed to read original settings file: %w", err))
+		return nil, nil, fmt.Errorf("failed to read settings file: %w", err)
 	}
 
-	// Parse original YAML into yaml.Node to preserve structure
 	var rootNode yaml.Node
-	if err := yaml.Unmarshal(originalData, &rootNode); err != nil {
-		return SendError(c, 500, fmt.Errorf("failed to parse original settings file: %w", err))
+	if err := yaml.Unmarshal(data, &rootNode); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse settings file: %w", err)
+	}
+	if rootNode.Kind == 0 {
+		rootNode = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
 	}
+	return &rootNode, data, nil
+}
 
-	// Update the yaml.Node tree with new values while preserving structure
-	updateYAMLNodeWithValues(&rootNode, newSettings)
+// writeSettings applies update to the settings document under the save lock,
+// replaces the file atomically and runs the hooks
+// update also receives the file contents it was parsed from; the new contents are returned.
+func (p *CPSPlugin) writeSettings(ctx context.Context, runHooks bool, update func(root *yaml.Node, data []byte) error) ([]CPSHookResult, []byte, error) {
+	cfg := p.getConfig()
 
-	// Marshal back to YAML
-	data, err := yaml.Marshal(&rootNode)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	lock, err := lockFile(cfg.LockFile, true, time.Duration(cfg.LockTimeout)*time.Millisecond)
 	if err != nil {
-		return SendError(c, 500, fmt.Errorf("failed to serialize settings: %w", err))
+		return nil, nil, err
 	}
+	defer lock.unlock()
 
-	// Write to file
-	if err := os.WriteFile(p.settingsPath, data, 0644); err != nil {
-		return SendError(c, 500, fmt.Errorf("failed to write settings file: %w", err))
+	rootNode, originalData, err := readSettings(cfg.SettingsPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := update(rootNode, originalData); err != nil {
+		return nil, nil, err
 	}
+	clearMergeTags(rootNode)
 
-	return SendSuccess(c, nil, "Settings saved successfully")
+	// Marshal back to YAML with the file's indentation
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(yamlIndent(originalData))
+	if err := encoder.Encode(rootNode); err != nil {
+		return nil, nil, fmt.Errorf("failed to serialize settings: %w", err)
+	}
+	encoder.Close()
+
+	// Write to a temp file, fsync and rename into place
+	if err := writeFileAtomic(cfg.SettingsPath, buf.Bytes()); err != nil {
+		return nil, nil, fmt.Errorf("failed to write settings file: %w", err)
+	}
+	lock.unlock()
+
+	// Let the daemons pick up the new settings
+	results := []CPSHookResult{}
+	if runHooks {
+		results = runCPSHooks(ctx, cfg.Hooks)
+	}
+	PublishEvent(cpsSavedEvent, "cps", fiber.Map{"path": cfg.SettingsPath, "hooks": results})
+	return results, buf.Bytes(), nil
+}
+
+// cpsConflict is returned when a save was based on an outdated revision
+type cpsConflict struct {
+	Revision string      // current revision
+	Current  interface{} // current settings or section
+}
+
+func (e *cpsConflict) Error() string {
+	return "settings were changed since they were loaded"
+}
+
+// revisionMatches reports whether a save's If-Match allows writing over the
+// current revision; saves without If-Match always do
+func revisionMatches(ifMatch, revision string) bool {
+	return ifMatch == "" || etagMatches(ifMatch, revision)
+}
+
+// sendSaveError responds to a failed save
+// A conflict carries the current revision and contents, so the client can
+// show what changed without another request
+func sendSaveError(c *fiber.Ctx, err error) error {
+	var conflict *cpsConflict
+	if errors.As(err, &conflict) {
+		c.Set(fiber.HeaderETag, conflict.Revision)
+		return c.Status(409).JSON(APIResponse{
+			Success: false,
+			Error:   err.Error(),
+			Data:    fiber.Map{"revision": conflict.Revision, "current": conflict.Current},
+		})
+	}
+
+	switch {
+	case errors.Is(err, errFileLocked):
+		return SendError(c, 503, err)
+	case errors.Is(err, errCPSSectionNotFound):
+		return SendError(c, 404, err)
+	}
+	return SendError(c, 500, err)
+}
+
+// sendSaved responds to a save with the hook results and the new revision
+func sendSaved(c *fiber.Ctx, results []CPSHookResult, revision string) error {
+	failed := 0
+	for _, result := range results {
+		if !result.Success {
+			failed++
+		}
+	}
+	message := "Settings saved successfully"
+	if failed > 0 {
+		message = fmt.Sprintf("Settings saved, but %d of %d hooks failed", failed, len(results))
+	}
+	c.Set(fiber.HeaderETag, revision)
+	return SendSuccess(c, fiber.Map{"hooks": results, "revision": revision}, message)
 }
 
 // Register the plugin
 func init() {
 	Register("cps", func(config interface{}) (Plugin, error) {
-		var settingsPath string
-
-		if configMap, ok := config.(map[string]interface{}); ok {
-			if path, ok := configMap["settings_path"].(string); ok && path != "" {
-				settingsPath = path
-			}
+		cfg, err := configAs[CPSConfig]("cps", config)
+		if err != nil {
+			return nil, err
 		}
 
-		return NewCPSPlugin(settingsPath)
+		return NewCPSPlugin(cfg)
 	})
 }
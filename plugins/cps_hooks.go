@@ -0,0 +1,159 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// CPS hook defaults
+const (
+	cpsHookTimeout = 30 * time.Second
+	cpsSavedEvent  = "cps.saved"
+	procCommLength = 15 // longest process name kept in /proc/<pid>/comm
+)
+
+// cpsHookSignals lists the signals a hook may send
+var cpsHookSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"INT":  syscall.SIGINT,
+	"TERM": syscall.SIGTERM,
+}
+
+// CPSHook is an action run after the settings were saved, so the radio
+// daemons pick up the new codeplug
+// Either restart a systemd unit, or send a signal to a process found by
+// name (process) or by pid_file.
+type CPSHook struct {
+	Restart string `yaml:"restart"`  // systemd unit to restart
+	Signal  string `yaml:"signal"`   // HUP, USR1, USR2, INT or TERM
+	Process string `yaml:"process"`  // process name as in /proc/<pid>/comm
+	PIDFile string `yaml:"pid_file"` // file holding the process ID
+}
+
+// CPSHookResult reports the outcome of a hook
+type CPSHookResult struct {
+	Hook    string `json:"hook"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// validate checks that the hook has exactly one action and a target
+func (h CPSHook) validate() error {
+	switch {
+	case h.Restart != "" && h.Signal != "":
+		return fmt.Errorf("set either restart or signal, not both")
+	case h.Restart != "":
+		if strings.ContainsAny(h.Restart, "/ ") {
+			return fmt.Errorf("invalid unit name %q", h.Restart)
+		}
+	case h.Signal != "":
+		if _, ok := cpsHookSignals[strings.TrimPrefix(strings.ToUpper(h.Signal), "SIG")]; !ok {
+			return fmt.Errorf("unsupported signal %q", h.Signal)
+		}
+		if (h.Process == "") == (h.PIDFile == "") {
+			return fmt.Errorf("signal requires either process or pid_file")
+		}
+	default:
+		return fmt.Errorf("set restart or signal")
+	}
+	return nil
+}
+
+// String describes the hook for results and logs
+func (h CPSHook) String() string {
+	if h.Restart != "" {
+		return "restart " + unitName(h.Restart)
+	}
+	target := h.Process
+	if target == "" {
+		target = h.PIDFile
+	}
+	return fmt.Sprintf("signal %s %s", strings.ToUpper(h.Signal), target)
+}
+
+// run performs the hook action
+func (h CPSHook) run(ctx context.Context) error {
+	if h.Restart != "" {
+		ctx, cancel := context.WithTimeout(ctx, cpsHookTimeout)
+		defer cancel()
+		output, err := exec.CommandContext(ctx, "systemctl", "restart", unitName(h.Restart)).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+
+	signal := cpsHookSignals[strings.TrimPrefix(strings.ToUpper(h.Signal), "SIG")]
+	pids, err := h.targetPIDs()
+	if err != nil {
+		return err
+	}
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, signal); err != nil {
+			return fmt.Errorf("failed to signal process %d: %w", pid, err)
+		}
+	}
+	return nil
+}
+
+// targetPIDs returns the processes a signal hook is sent to
+func (h CPSHook) targetPIDs() ([]int, error) {
+	if h.PIDFile != "" {
+		data, err := os.ReadFile(h.PIDFile)
+		if err != nil {
+			return nil, err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil || pid <= 0 {
+			return nil, fmt.Errorf("invalid process ID in %s", h.PIDFile)
+		}
+		return []int{pid}, nil
+	}
+
+	name := h.Process
+	if len(name) > procCommLength {
+		name = name[:procCommLength]
+	}
+	comms, _ := filepath.Glob("/proc/[0-9]*/comm")
+	var pids []int
+	for _, path := range comms {
+		comm, err := os.ReadFile(path)
+		if err != nil || strings.TrimSpace(string(comm)) != name {
+			continue
+		}
+		if pid, err := strconv.Atoi(filepath.Base(filepath.Dir(path))); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("no process named %s is running", h.Process)
+	}
+	return pids, nil
+}
+
+// runCPSHooks runs the hooks in order; a failing hook does not stop the others
+func runCPSHooks(ctx context.Context, hooks []CPSHook) []CPSHookResult {
+	results := make([]CPSHookResult, 0, len(hooks))
+	for _, hook := range hooks {
+		result := CPSHookResult{Hook: hook.String(), Success: true}
+		if err := hook.run(ctx); err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			slog.WarnContext(ctx, "CPS save hook failed", "hook", result.Hook, "error", err)
+		} else {
+			slog.InfoContext(ctx, "CPS save hook completed", "hook", result.Hook)
+		}
+		results = append(results, result)
+	}
+	return results
+}
@@ -0,0 +1,105 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// errCPSSectionNotFound is returned for top-level sections the settings file does not have
+var errCPSSectionNotFound = errors.New("settings section not found")
+
+// settingsMapping returns the top-level mapping of a settings document
+func settingsMapping(root *yaml.Node) (*yaml.Node, error) {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("settings file is not a mapping of sections")
+	}
+	return root.Content[0], nil
+}
+
+// sectionRevision identifies the contents of a section
+// Only the values count, so edits to other sections or comments do not conflict.
+func sectionRevision(section *yaml.Node) string {
+	data, _ := json.Marshal(yamlNodeToOrderedJSON(section))
+	return contentETag(data)
+}
+
+// listSections handles GET /api/cps/sections
+// Lists the top-level sections in file order
+func (p *CPSPlugin) listSections(c *fiber.Ctx) error {
+	root, _, err := readSettings(p.getConfig().SettingsPath)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	mapping, err := settingsMapping(root)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+
+	sections := make([]string, 0, len(mapping.Content)/2)
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		sections = append(sections, mapping.Content[i].Value)
+	}
+	return SendSuccess(c, sections, "")
+}
+
+// loadSection handles GET /api/cps/section/:name
+func (p *CPSPlugin) loadSection(c *fiber.Ctx) error {
+	name := c.Params("name")
+
+	root, _, err := readSettings(p.getConfig().SettingsPath)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	mapping, err := settingsMapping(root)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	section := mappingValue(mapping, name)
+	if section == nil {
+		return SendError(c, 404, fmt.Errorf("%w: %s", errCPSSectionNotFound, name))
+	}
+
+	c.Set(fiber.HeaderETag, sectionRevision(section))
+	return SendSuccess(c, yamlNodeToOrderedJSON(section), "")
+}
+
+// saveSection handles PUT /api/cps/section/:name
+// Updates one top-level section like a full save would; the rest of the file is untouched
+// If-Match is checked against the section's revision only.
+func (p *CPSPlugin) saveSection(c *fiber.Ctx) error {
+	name := c.Params("name")
+
+	var value interface{}
+	if err := json.Unmarshal(c.Body(), &value); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+
+	ifMatch := c.Get(fiber.HeaderIfMatch)
+	var section *yaml.Node
+	results, _, err := p.writeSettings(c.UserContext(), c.QueryBool("hooks", true), func(root *yaml.Node, _ []byte) error {
+		mapping, err := settingsMapping(root)
+		if err != nil {
+			return err
+		}
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			if mapping.Content[i].Value != name {
+				continue
+			}
+			if revision := sectionRevision(mapping.Content[i+1]); !revisionMatches(ifMatch, revision) {
+				return &cpsConflict{Revision: revision, Current: yamlNodeToOrderedJSON(mapping.Content[i+1])}
+			}
+			section = updateYAMLValue(mapping.Content[i+1], value)
+			mapping.Content[i+1] = section
+			return nil
+		}
+		return fmt.Errorf("%w: %s", errCPSSectionNotFound, name)
+	})
+	if err != nil {
+		return sendSaveError(c, err)
+	}
+	return sendSaved(c, results, sectionRevision(section))
+}
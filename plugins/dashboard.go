@@ -0,0 +1,329 @@
+package plugins
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Dashboard defaults
+const (
+	DefaultDashboardAlarms  = 10
+	dashboardCollectTimeout = 10 * time.Second
+)
+
+// DashboardConfig holds dashboard configuration
+type DashboardConfig struct {
+	Alarms int `yaml:"alarms"` // recent hardware alarms included
+
+	// Sources taken from the docker, services and health sections
+	DockerClient  *client.Client `yaml:"-"`
+	ServicePrefix string         `yaml:"-"`
+	DiskPaths     []string       `yaml:"-"`
+}
+
+// DashboardContainers counts the containers by state
+type DashboardContainers struct {
+	Total      int            `json:"total"`
+	Running    int            `json:"running"`
+	Stopped    int            `json:"stopped"` // created, exited or dead
+	Paused     int            `json:"paused"`
+	Restarting int            `json:"restarting"`
+	Unhealthy  int            `json:"unhealthy"`
+	States     map[string]int `json:"states"`
+}
+
+// DashboardServices counts the managed systemd units by state
+type DashboardServices struct {
+	Total    int      `json:"total"`
+	Active   int      `json:"active"`
+	Inactive int      `json:"inactive"`
+	Failed   []string `json:"failed"` // names of failed units
+}
+
+// DashboardMemory is the memory usage of the system
+type DashboardMemory struct {
+	TotalMB     float64 `json:"total_mb"`
+	AvailableMB float64 `json:"available_mb"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// DashboardDisk is the usage of a filesystem
+type DashboardDisk struct {
+	Path        string  `json:"path"`
+	TotalMB     float64 `json:"total_mb"`
+	FreeMB      float64 `json:"free_mb"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// DashboardSystem is the load and resource usage of the system
+type DashboardSystem struct {
+	Uptime float64          `json:"uptime"` // seconds
+	Load1  float64          `json:"load1"`
+	Memory *DashboardMemory `json:"memory,omitempty"`
+	Disks  []DashboardDisk  `json:"disks"`
+}
+
+// DashboardHardware is the transceiver state
+type DashboardHardware struct {
+	Mode         string `json:"mode,omitempty"`
+	RxPLLLocked  bool   `json:"rx_pll_locked"`
+	TxPLLLocked  bool   `json:"tx_pll_locked"`
+	XoscReady    bool   `json:"xosc_ready"`
+	ActiveAlarms int    `json:"active_alarms"`
+	Error        string `json:"error,omitempty"`
+}
+
+// DashboardSummary aggregates the landing page data
+// Sections whose plugin is not loaded are left out; sections that failed are reported in errors.
+type DashboardSummary struct {
+	Time        time.Time            `json:"time"`
+	Containers  *DashboardContainers `json:"containers,omitempty"`
+	Services    *DashboardServices   `json:"services,omitempty"`
+	System      DashboardSystem      `json:"system"`
+	Hardware    *DashboardHardware   `json:"hardware,omitempty"`
+	Alarms      []HardwareAlarm      `json:"alarms"` // most recent first
+	Shell       *ShellSessionCounts  `json:"shell,omitempty"`
+	Maintenance bool                 `json:"maintenance"`
+	ReadOnly    bool                 `json:"read_only"`
+	Errors      map[string]string    `json:"errors,omitempty"`
+}
+
+// DashboardPlugin serves the landing page summary in one request
+type DashboardPlugin struct {
+	config   DashboardConfig
+	services *ServicesPlugin
+	mu       sync.RWMutex
+}
+
+// NewDashboardPlugin creates a new dashboard plugin instance
+func NewDashboardPlugin(cfg DashboardConfig) (*DashboardPlugin, error) {
+	cfg = normalizeDashboardConfig(cfg)
+	services, err := NewServicesPlugin(cfg.ServicePrefix, "")
+	if err != nil {
+		return nil, err
+	}
+	return &DashboardPlugin{
+		config:   cfg,
+		services: services,
+	}, nil
+}
+
+// Name returns the plugin identifier
+func (p *DashboardPlugin) Name() string {
+	return "dashboard"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *DashboardPlugin) RegisterRoutes(app *fiber.App) {
+	APIGroup(app, "").Get("/dashboard", p.handleDashboard)
+}
+
+// Shutdown has nothing to clean up
+func (p *DashboardPlugin) Shutdown() error {
+	return nil
+}
+
+// Reload applies the new alarm count and service prefix
+func (p *DashboardPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[DashboardConfig]("dashboard", config)
+	if err != nil {
+		return err
+	}
+	cfg = normalizeDashboardConfig(cfg)
+	if err := p.services.Reload(ServicesConfig{Prefix: cfg.ServicePrefix}); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.config = cfg
+	p.mu.Unlock()
+
+	slog.Info("Dashboard config reloaded", "alarms", cfg.Alarms)
+	return nil
+}
+
+// getConfig returns the current configuration
+func (p *DashboardPlugin) getConfig() DashboardConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config
+}
+
+// handleDashboard handles GET /api/dashboard
+// The sections are collected in parallel, so the request takes as long as the slowest source
+func (p *DashboardPlugin) handleDashboard(c *fiber.Ctx) error {
+	cfg := p.getConfig()
+	ctx, cancel := context.WithTimeout(c.UserContext(), dashboardCollectTimeout)
+	defer cancel()
+
+	summary := DashboardSummary{
+		Time:        time.Now(),
+		Alarms:      []HardwareAlarm{},
+		Maintenance: MaintenanceMode(),
+		ReadOnly:    ReadOnlyMode(),
+	}
+	var (
+		wg       sync.WaitGroup
+		errorsMu sync.Mutex
+	)
+	fail := func(section string, err error) {
+		errorsMu.Lock()
+		defer errorsMu.Unlock()
+		if summary.Errors == nil {
+			summary.Errors = map[string]string{}
+		}
+		summary.Errors[section] = err.Error()
+	}
+	collect := func(fn func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn()
+		}()
+	}
+
+	if cfg.DockerClient != nil {
+		collect(func() {
+			containers, err := dashboardContainers(ctx, cfg.DockerClient)
+			if err != nil {
+				fail("containers", err)
+				return
+			}
+			summary.Containers = containers
+		})
+	}
+	collect(func() {
+		services, err := p.dashboardServices(ctx)
+		if err != nil {
+			fail("services", err)
+			return
+		}
+		summary.Services = services
+	})
+	collect(func() {
+		if status, ok := CurrentHardwareStatus(); ok {
+			hardware := &DashboardHardware{
+				Mode:         status.Mode,
+				RxPLLLocked:  status.RxPLLLocked,
+				TxPLLLocked:  status.TxPLLLocked,
+				XoscReady:    status.XoscReady,
+				ActiveAlarms: len(status.Alarms),
+				Error:        status.Error,
+			}
+			summary.Hardware = hardware
+		}
+		if alarms, ok := RecentHardwareAlarms(cfg.Alarms); ok {
+			summary.Alarms = alarms
+		}
+	})
+	summary.System = dashboardSystem(cfg.DiskPaths)
+	if shell, ok := CurrentShellSessions(); ok {
+		summary.Shell = &shell
+	}
+	wg.Wait()
+
+	return SendSuccess(c, summary, "")
+}
+
+// dashboardContainers counts the containers by state and health
+func dashboardContainers(ctx context.Context, cli *client.Client) (*DashboardContainers, error) {
+	list, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	containers := &DashboardContainers{Total: len(list), States: map[string]int{}}
+	for _, cont := range list {
+		containers.States[cont.State]++
+		switch cont.State {
+		case "running":
+			containers.Running++
+		case "paused":
+			containers.Paused++
+		case "restarting":
+			containers.Restarting++
+		default:
+			containers.Stopped++
+		}
+		if healthFromStatus(cont.Status) == "unhealthy" {
+			containers.Unhealthy++
+		}
+	}
+	return containers, nil
+}
+
+// dashboardServices counts the managed units by active state
+func (p *DashboardPlugin) dashboardServices(ctx context.Context) (*DashboardServices, error) {
+	list, err := p.services.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	services := &DashboardServices{Total: len(list), Failed: []string{}}
+	for _, service := range list {
+		switch {
+		case service.ActiveState == "failed":
+			services.Failed = append(services.Failed, service.Name)
+		case service.IsActive:
+			services.Active++
+		default:
+			services.Inactive++
+		}
+	}
+	return services, nil
+}
+
+// dashboardSystem reads uptime, load, memory and the usage of the filesystems
+func dashboardSystem(diskPaths []string) DashboardSystem {
+	system := DashboardSystem{Disks: []DashboardDisk{}}
+	system.Uptime, _ = readUptime()
+	system.Load1, _ = readLoadAverage()
+	if total, available, ok := readMemInfo(); ok {
+		system.Memory = &DashboardMemory{
+			TotalMB:     float64(total) / 1024,
+			AvailableMB: float64(available) / 1024,
+			UsedPercent: 100 * float64(total-available) / float64(total),
+		}
+	}
+	for _, path := range diskPaths {
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(path, &stat); err != nil || stat.Blocks == 0 {
+			continue
+		}
+		bsize := float64(stat.Bsize)
+		system.Disks = append(system.Disks, DashboardDisk{
+			Path:        path,
+			TotalMB:     float64(stat.Blocks) * bsize / 1024 / 1024,
+			FreeMB:      float64(stat.Bavail) * bsize / 1024 / 1024,
+			UsedPercent: 100 * float64(stat.Blocks-stat.Bfree) / float64(stat.Blocks-stat.Bfree+stat.Bavail),
+		})
+	}
+	return system
+}
+
+// normalizeDashboardConfig fills in defaults
+func normalizeDashboardConfig(cfg DashboardConfig) DashboardConfig {
+	if cfg.Alarms <= 0 {
+		cfg.Alarms = DefaultDashboardAlarms
+	}
+	if len(cfg.DiskPaths) == 0 {
+		cfg.DiskPaths = []string{"/"}
+	}
+	return cfg
+}
+
+// Register the plugin
+func init() {
+	Register("dashboard", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[DashboardConfig]("dashboard", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewDashboardPlugin(cfg)
+	}, "dockerclient")
+}
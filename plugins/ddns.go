@@ -0,0 +1,450 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DDNS defaults
+const (
+	DefaultDDNSInterval = 300 // seconds
+	MinDDNSInterval     = 60  // seconds, providers block clients that poll too often
+	ddnsRequestTimeout  = 15 * time.Second
+	ddnsEventSource     = "ddns"
+)
+
+// DefaultDDNSIPURLs answer with the caller's public IPv4 address as plain text
+var DefaultDDNSIPURLs = []string{"https://api.ipify.org", "https://ipv4.icanhazip.com"}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which DNS cannot point to usefully
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// DDNSProviderConfig describes one dynamic DNS record to keep up to date
+type DDNSProviderConfig struct {
+	Name     string `yaml:"name"`
+	Type     string `yaml:"type"` // duckdns, dyndns2, cloudflare or url
+	Hostname string `yaml:"hostname"`
+	Server   string `yaml:"server"`   // dyndns2 server, default members.dyndns.org
+	Username string `yaml:"username"` // dyndns2
+	Password string `yaml:"password"` // dyndns2
+	Token    string `yaml:"token"`    // DuckDNS token, Cloudflare API token or {token} in url
+	ZoneID   string `yaml:"zone_id"`  // cloudflare
+	URL      string `yaml:"url"`      // url type; {ip}, {hostname} and {token} are filled in
+}
+
+// DDNSConfig holds DDNS plugin configuration
+type DDNSConfig struct {
+	Interval  int                  `yaml:"interval"`  // seconds between address checks
+	Interface string               `yaml:"interface"` // take the address of this interface instead of asking ip_urls
+	IPURLs    []string             `yaml:"ip_urls"`   // services returning the public IPv4 address, tried in order
+	Providers []DDNSProviderConfig `yaml:"providers"`
+}
+
+// DDNSProviderStatus is the outcome of the last update of one provider
+type DDNSProviderStatus struct {
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	Hostname    string     `json:"hostname,omitempty"`
+	IP          string     `json:"ip,omitempty"` // address last accepted by the provider
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+	AttemptedAt *time.Time `json:"attempted_at,omitempty"`
+	Result      string     `json:"result,omitempty"` // provider answer of the last successful update
+	Error       string     `json:"error,omitempty"`
+	Blocked     bool       `json:"blocked"` // rejected by the provider, retried only after a config change or forced update
+}
+
+// DDNSStatus describes the detected address and the provider records
+type DDNSStatus struct {
+	IP        string               `json:"ip,omitempty"`
+	Source    string               `json:"source,omitempty"` // interface or URL the address came from
+	CheckedAt *time.Time           `json:"checked_at,omitempty"`
+	NextCheck *time.Time           `json:"next_check,omitempty"`
+	Error     string               `json:"error,omitempty"` // address detection error of the last check
+	Interval  int                  `json:"interval"`
+	Providers []DDNSProviderStatus `json:"providers"`
+}
+
+// DDNSPlugin keeps dynamic DNS records pointed at the current public address
+type DDNSPlugin struct {
+	client   *http.Client
+	trigger  chan struct{}
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+	runMu    sync.Mutex // serializes checks from the loop and the API
+
+	mu        sync.Mutex
+	config    DDNSConfig
+	ip        string
+	source    string
+	checkedAt time.Time
+	nextCheck time.Time
+	ipErr     string
+	providers map[string]*DDNSProviderStatus
+}
+
+// NewDDNSPlugin creates a new DDNS plugin instance
+func NewDDNSPlugin(cfg DDNSConfig) (*DDNSPlugin, error) {
+	cfg = normalizeDDNSConfig(cfg)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &DDNSPlugin{
+		config:    cfg,
+		client:    &http.Client{Timeout: ddnsRequestTimeout},
+		trigger:   make(chan struct{}, 1),
+		stopChan:  make(chan struct{}),
+		providers: make(map[string]*DDNSProviderStatus),
+	}, nil
+}
+
+// Start begins the periodic address checks
+func (p *DDNSPlugin) Start() error {
+	p.wg.Add(1)
+	go p.run()
+	slog.Info("DDNS updater started", "interval", p.getConfig().Interval, "providers", len(p.getConfig().Providers))
+	return nil
+}
+
+// Name returns the plugin identifier
+func (p *DDNSPlugin) Name() string {
+	return "ddns"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *DDNSPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/ddns")
+
+	api.Get("/", p.handleStatus)
+	api.Post("/update", p.handleUpdate)
+}
+
+// Shutdown stops the check loop
+func (p *DDNSPlugin) Shutdown() error {
+	p.stopOnce.Do(func() {
+		close(p.stopChan)
+	})
+	p.wg.Wait()
+	return nil
+}
+
+// Reload applies new providers and settings and checks at once
+// Providers blocked after a rejection are retried with the new settings.
+func (p *DDNSPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[DDNSConfig]("ddns", config)
+	if err != nil {
+		return err
+	}
+	cfg = normalizeDDNSConfig(cfg)
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.config = cfg
+	states := make(map[string]*DDNSProviderStatus)
+	for _, pc := range cfg.Providers {
+		if state, ok := p.providers[pc.Name]; ok && state.Type == pc.Type && state.Hostname == pc.Hostname {
+			state.Blocked = false
+			states[pc.Name] = state
+		}
+	}
+	p.providers = states
+	p.mu.Unlock()
+
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+
+	slog.Info("DDNS config reloaded", "interval", cfg.Interval, "providers", len(cfg.Providers))
+	return nil
+}
+
+// Validate checks the interval, address sources and providers
+func (cfg DDNSConfig) Validate() error {
+	if cfg.Interval != 0 && cfg.Interval < MinDDNSInterval {
+		return fmt.Errorf("ddns.interval must be at least %d seconds", MinDDNSInterval)
+	}
+	for _, u := range cfg.IPURLs {
+		if err := validateHTTPURL(u); err != nil {
+			return fmt.Errorf("ddns.ip_urls: %w", err)
+		}
+	}
+	names := make(map[string]bool)
+	for i, pc := range cfg.Providers {
+		if err := pc.validate(); err != nil {
+			return fmt.Errorf("ddns.providers[%d]: %w", i, err)
+		}
+		if names[pc.Name] {
+			return fmt.Errorf("ddns.providers[%d]: duplicate name %q", i, pc.Name)
+		}
+		names[pc.Name] = true
+	}
+	return nil
+}
+
+// run checks the address every interval, or at once when triggered
+func (p *DDNSPlugin) run() {
+	defer p.wg.Done()
+
+	for {
+		p.check(context.Background(), false, "")
+
+		interval := time.Duration(p.getConfig().Interval) * time.Second
+		p.mu.Lock()
+		p.nextCheck = time.Now().Add(interval)
+		p.mu.Unlock()
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-p.stopChan:
+			timer.Stop()
+			return
+		case <-p.trigger:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// check detects the public address and updates the providers whose record is stale
+// A forced check updates every provider (or only the named one) regardless of its state.
+func (p *DDNSPlugin) check(ctx context.Context, force bool, only string) error {
+	p.runMu.Lock()
+	defer p.runMu.Unlock()
+
+	cfg := p.getConfig()
+	ip, source, err := detectPublicIP(ctx, p.client, cfg)
+
+	p.mu.Lock()
+	p.checkedAt = time.Now()
+	if err != nil {
+		p.ipErr = err.Error()
+		p.mu.Unlock()
+		slog.Warn("DDNS address detection failed", "error", err)
+		return err
+	}
+	previous := p.ip
+	p.ip, p.source, p.ipErr = ip, source, ""
+	p.mu.Unlock()
+
+	if previous != "" && previous != ip {
+		slog.Info("Public address changed", "old", previous, "new", ip, "source", source)
+		PublishEvent("ddns.ip_changed", ddnsEventSource, fiber.Map{"old": previous, "new": ip})
+	}
+
+	for _, pc := range cfg.Providers {
+		if only != "" && pc.Name != only {
+			continue
+		}
+		state := p.providerState(pc)
+		p.mu.Lock()
+		current := state.Error == "" && state.IP == ip
+		blocked := state.Blocked
+		p.mu.Unlock()
+		if !force && (current || blocked) {
+			continue
+		}
+		p.update(ctx, pc, state, ip)
+	}
+	return nil
+}
+
+// providerState returns the state of a provider, creating it on first use
+func (p *DDNSPlugin) providerState(pc DDNSProviderConfig) *DDNSProviderStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	state, ok := p.providers[pc.Name]
+	if !ok {
+		state = &DDNSProviderStatus{Name: pc.Name, Type: pc.Type, Hostname: pc.Hostname}
+		p.providers[pc.Name] = state
+	}
+	return state
+}
+
+// update sends the address to one provider and records the outcome
+func (p *DDNSPlugin) update(ctx context.Context, pc DDNSProviderConfig, state *DDNSProviderStatus, ip string) {
+	result, err := updateDDNS(ctx, p.client, pc, ip)
+	now := time.Now()
+
+	p.mu.Lock()
+	state.AttemptedAt = &now
+	if err != nil {
+		var rejected *ddnsRejected
+		state.Error = err.Error()
+		state.Blocked = errors.As(err, &rejected)
+	} else {
+		state.IP = ip
+		state.UpdatedAt = &now
+		state.Result = result
+		state.Error = ""
+		state.Blocked = false
+	}
+	p.mu.Unlock()
+
+	event := fiber.Map{"provider": pc.Name, "hostname": pc.Hostname, "ip": ip, "success": err == nil}
+	if err != nil {
+		event["error"] = err.Error()
+		slog.Error("DDNS update failed", "provider", pc.Name, "hostname", pc.Hostname, "error", err, "blocked", state.Blocked)
+	} else {
+		slog.Info("DDNS record updated", "provider", pc.Name, "hostname", pc.Hostname, "ip", ip, "result", result)
+	}
+	PublishEvent("ddns.updated", ddnsEventSource, event)
+}
+
+// status returns the detected address and the state of every configured provider
+func (p *DDNSPlugin) status() DDNSStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := DDNSStatus{
+		IP:        p.ip,
+		Source:    p.source,
+		Error:     p.ipErr,
+		Interval:  p.config.Interval,
+		Providers: make([]DDNSProviderStatus, 0, len(p.config.Providers)),
+	}
+	if !p.checkedAt.IsZero() {
+		checkedAt := p.checkedAt
+		status.CheckedAt = &checkedAt
+	}
+	if !p.nextCheck.IsZero() {
+		nextCheck := p.nextCheck
+		status.NextCheck = &nextCheck
+	}
+	for _, pc := range p.config.Providers {
+		if state, ok := p.providers[pc.Name]; ok {
+			status.Providers = append(status.Providers, *state)
+		} else {
+			status.Providers = append(status.Providers, DDNSProviderStatus{Name: pc.Name, Type: pc.Type, Hostname: pc.Hostname})
+		}
+	}
+	return status
+}
+
+// detectPublicIP returns the public IPv4 address and where it came from
+func detectPublicIP(ctx context.Context, client *http.Client, cfg DDNSConfig) (string, string, error) {
+	if cfg.Interface != "" {
+		ip, err := interfacePublicIP(cfg.Interface)
+		return ip, cfg.Interface, err
+	}
+
+	var errs []string
+	for _, u := range cfg.IPURLs {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		status, body, err := ddnsDo(client, req)
+		if err == nil && status != http.StatusOK {
+			err = fmt.Errorf("%s returned HTTP %d", u, status)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		addr, err := netip.ParseAddr(body)
+		if err != nil || !addr.Is4() {
+			errs = append(errs, fmt.Sprintf("%s did not return an IPv4 address", u))
+			continue
+		}
+		return addr.String(), u, nil
+	}
+	return "", "", fmt.Errorf("no address service answered: %s", strings.Join(errs, "; "))
+}
+
+// interfacePublicIP returns the first public IPv4 address of an interface
+func interfacePublicIP(name string) (string, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip, ok := netip.AddrFromSlice(ipNet.IP)
+		ip = ip.Unmap()
+		if ok && ip.Is4() && ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip) {
+			return ip.String(), nil
+		}
+	}
+	return "", fmt.Errorf("interface %s has no public IPv4 address", name)
+}
+
+// handleStatus handles GET /api/ddns
+func (p *DDNSPlugin) handleStatus(c *fiber.Ctx) error {
+	return SendSuccess(c, p.status(), "")
+}
+
+// handleUpdate handles POST /api/ddns/update
+// Detects the address and updates every provider, or only ?provider=, even
+// when the record looks current or the provider was blocked.
+func (p *DDNSPlugin) handleUpdate(c *fiber.Ctx) error {
+	only := c.Query("provider")
+	if only != "" {
+		known := false
+		for _, pc := range p.getConfig().Providers {
+			known = known || pc.Name == only
+		}
+		if !known {
+			return SendErrorMessage(c, 404, fmt.Sprintf("DDNS provider %s not found", only))
+		}
+	}
+
+	if err := p.check(c.UserContext(), true, only); err != nil {
+		return c.Status(502).JSON(APIResponse{
+			Success: false,
+			Data:    p.status(),
+			Error:   err.Error(),
+		})
+	}
+	return SendSuccess(c, p.status(), "DDNS update finished")
+}
+
+// getConfig returns the current configuration
+func (p *DDNSPlugin) getConfig() DDNSConfig {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.config
+}
+
+// normalizeDDNSConfig fills in defaults
+func normalizeDDNSConfig(cfg DDNSConfig) DDNSConfig {
+	if cfg.Interval == 0 {
+		cfg.Interval = DefaultDDNSInterval
+	}
+	if len(cfg.IPURLs) == 0 {
+		cfg.IPURLs = DefaultDDNSIPURLs
+	}
+	return cfg
+}
+
+// Register the plugin
+func init() {
+	Register("ddns", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[DDNSConfig]("ddns", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewDDNSPlugin(cfg)
+	})
+}
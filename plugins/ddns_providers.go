@@ -0,0 +1,268 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DDNS provider types
+const (
+	DDNSDuckDNS    = "duckdns"
+	DDNSDynDNS2    = "dyndns2" // dyndns.org protocol, also spoken by No-IP, Dynu, ...
+	DDNSCloudflare = "cloudflare"
+	DDNSURL        = "url" // plain GET request to a URL template
+)
+
+// DDNS provider constants
+const (
+	DefaultDynDNS2Server = "members.dyndns.org"
+	cloudflareAPI        = "https://api.cloudflare.com/client/v4"
+	ddnsUserAgent        = "linht-web-manager/1.0"
+	maxDDNSResponseSize  = 64 * 1024
+)
+
+// dyndns2Rejections are answers after which a client must not retry until its settings change
+var dyndns2Rejections = map[string]bool{"badauth": true, "!donator": true, "notfqdn": true, "nohost": true, "numhost": true, "abuse": true, "badagent": true}
+
+// ddnsRejected is returned when the provider refuses the update itself
+// Retrying with the same settings would fail again or get the host blocked.
+type ddnsRejected struct {
+	Result string
+}
+
+func (e *ddnsRejected) Error() string {
+	return fmt.Sprintf("update rejected: %s", e.Result)
+}
+
+// validate checks that a provider has the settings its type needs
+func (pc DDNSProviderConfig) validate() error {
+	if pc.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch pc.Type {
+	case DDNSDuckDNS:
+		if pc.Hostname == "" || pc.Token == "" {
+			return fmt.Errorf("duckdns needs hostname and token")
+		}
+	case DDNSDynDNS2:
+		if pc.Hostname == "" || pc.Username == "" || pc.Password == "" {
+			return fmt.Errorf("dyndns2 needs hostname, username and password")
+		}
+	case DDNSCloudflare:
+		if pc.Hostname == "" || pc.Token == "" || pc.ZoneID == "" {
+			return fmt.Errorf("cloudflare needs hostname, token and zone_id")
+		}
+	case DDNSURL:
+		if err := validateHTTPURL(pc.URL); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown type %q, expected duckdns, dyndns2, cloudflare or url", pc.Type)
+	}
+	return nil
+}
+
+// validateHTTPURL checks for an absolute http or https URL
+func validateHTTPURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q", value)
+	}
+	return nil
+}
+
+// updateDDNS points the provider's record at ip and returns the provider's answer
+func updateDDNS(ctx context.Context, client *http.Client, pc DDNSProviderConfig, ip string) (string, error) {
+	switch pc.Type {
+	case DDNSDuckDNS:
+		return updateDuckDNS(ctx, client, pc, ip)
+	case DDNSDynDNS2:
+		return updateDynDNS2(ctx, client, pc, ip)
+	case DDNSCloudflare:
+		return updateCloudflare(ctx, client, pc, ip)
+	case DDNSURL:
+		return updateURL(ctx, client, pc, ip)
+	}
+	return "", fmt.Errorf("unknown provider type %q", pc.Type)
+}
+
+// updateDuckDNS uses the DuckDNS update API, which answers OK or KO
+func updateDuckDNS(ctx context.Context, client *http.Client, pc DDNSProviderConfig, ip string) (string, error) {
+	query := url.Values{
+		"domains": {strings.TrimSuffix(pc.Hostname, ".duckdns.org")},
+		"token":   {pc.Token},
+		"ip":      {ip},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://www.duckdns.org/update?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	status, body, err := ddnsDo(client, req)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("duckdns returned HTTP %d", status)
+	}
+	if body != "OK" {
+		return "", &ddnsRejected{Result: body}
+	}
+	return body, nil
+}
+
+// updateDynDNS2 speaks the dyndns2 protocol; "good" and "nochg" are successes
+func updateDynDNS2(ctx context.Context, client *http.Client, pc DDNSProviderConfig, ip string) (string, error) {
+	server := pc.Server
+	if server == "" {
+		server = DefaultDynDNS2Server
+	}
+	if !strings.Contains(server, "://") {
+		server = "https://" + server
+	}
+	query := url.Values{"hostname": {pc.Hostname}, "myip": {ip}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(server, "/")+"/nic/update?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(pc.Username, pc.Password)
+
+	status, body, err := ddnsDo(client, req)
+	if err != nil {
+		return "", err
+	}
+	// Some servers send rejections with an error status, so the answer is checked first
+	code, _, _ := strings.Cut(body, " ")
+	switch {
+	case code == "good" || code == "nochg":
+		return body, nil
+	case dyndns2Rejections[code]:
+		return "", &ddnsRejected{Result: body}
+	case status != http.StatusOK:
+		return "", fmt.Errorf("dyndns2 server returned HTTP %d: %s", status, body)
+	}
+	return "", fmt.Errorf("unexpected answer %q", body)
+}
+
+// cloudflareResponse is the envelope of Cloudflare API responses
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+// cloudflareRecord is the part of a DNS record the update needs
+type cloudflareRecord struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+}
+
+// updateCloudflare looks up the A record of the hostname and patches its content
+// The record must exist; the token needs the Zone.DNS edit permission.
+func updateCloudflare(ctx context.Context, client *http.Client, pc DDNSProviderConfig, ip string) (string, error) {
+	records := cloudflareAPI + "/zones/" + url.PathEscape(pc.ZoneID) + "/dns_records"
+
+	var found []cloudflareRecord
+	query := url.Values{"type": {"A"}, "name": {pc.Hostname}}
+	if err := cloudflareCall(ctx, client, pc.Token, http.MethodGet, records+"?"+query.Encode(), nil, &found); err != nil {
+		return "", err
+	}
+	if len(found) == 0 {
+		return "", &ddnsRejected{Result: fmt.Sprintf("no A record named %s in the zone", pc.Hostname)}
+	}
+	if found[0].Content == ip {
+		return "nochg " + ip, nil
+	}
+
+	body, _ := json.Marshal(map[string]string{"content": ip})
+	var updated cloudflareRecord
+	if err := cloudflareCall(ctx, client, pc.Token, http.MethodPatch, records+"/"+url.PathEscape(found[0].ID), body, &updated); err != nil {
+		return "", err
+	}
+	return "good " + updated.Content, nil
+}
+
+// cloudflareCall sends one API request and decodes its result
+func cloudflareCall(ctx context.Context, client *http.Client, token, method, target string, body []byte, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	status, text, err := ddnsDo(client, req)
+	if err != nil {
+		return err
+	}
+	var resp cloudflareResponse
+	if err := json.Unmarshal([]byte(text), &resp); err != nil {
+		return fmt.Errorf("cloudflare returned HTTP %d", status)
+	}
+	if !resp.Success {
+		var messages []string
+		for _, e := range resp.Errors {
+			messages = append(messages, fmt.Sprintf("%d %s", e.Code, e.Message))
+		}
+		if status == http.StatusUnauthorized || status == http.StatusForbidden {
+			return &ddnsRejected{Result: strings.Join(messages, "; ")}
+		}
+		return fmt.Errorf("cloudflare: %s", strings.Join(messages, "; "))
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+// updateURL requests the URL template with {ip}, {hostname} and {token} filled in
+// Any 2xx status counts as success.
+func updateURL(ctx context.Context, client *http.Client, pc DDNSProviderConfig, ip string) (string, error) {
+	target := strings.NewReplacer(
+		"{ip}", url.QueryEscape(ip),
+		"{hostname}", url.QueryEscape(pc.Hostname),
+		"{token}", url.QueryEscape(pc.Token),
+	).Replace(pc.URL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return "", err
+	}
+	status, body, err := ddnsDo(client, req)
+	if err != nil {
+		return "", err
+	}
+	if status < 200 || status > 299 {
+		return "", fmt.Errorf("HTTP %d: %s", status, body)
+	}
+	if len(body) > 200 {
+		body = body[:200]
+	}
+	return body, nil
+}
+
+// ddnsDo sends a request and returns the status and trimmed body
+// Errors never include the URL, which may carry a token.
+func ddnsDo(client *http.Client, req *http.Request) (int, string, error) {
+	req.Header.Set("User-Agent", ddnsUserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, "", fmt.Errorf("%s %s: %w", req.Method, req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDDNSResponseSize))
+	if err != nil {
+		return 0, "", err
+	}
+	return resp.StatusCode, strings.TrimSpace(string(body)), nil
+}
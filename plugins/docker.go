@@ -7,56 +7,205 @@ import (
 	"io"
 	"log/slog"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
 	"github.com/gofiber/fiber/v2"
 )
 
+// DockerConfig holds the docker section of the configuration
+type DockerConfig struct {
+	Socket               string             `yaml:"socket"` // used by the server to create the shared client
+	TLS                  DockerTLSConfig    `yaml:"tls"`    // certificates for a tcp:// socket
+	ContainerStopTimeout int                `yaml:"container_stop_timeout"`
+	DefaultLogLines      string             `yaml:"default_log_lines"`
+	DefaultLimits        ContainerLimits    `yaml:"default_limits"` // applied to new containers
+	ManagedFile          string             `yaml:"managed_file"`   // containers started in order after boot
+	BootTimeout          int                `yaml:"boot_timeout"`   // seconds to wait for each gated container
+	PingInterval         int                `yaml:"ping_interval"`  // seconds between daemon liveness checks
+	Hosts                []DockerHostConfig `yaml:"hosts"`          // further daemons, selected per request with ?host=
+	Registries           []RegistryConfig   `yaml:"registries"`     // registries to browse before pulling
+	ArchCheck            string             `yaml:"arch_check"`     // off, warn (default) or reject imports for another platform
+
+	Client *client.Client `yaml:"-"`
+}
+
 type DockerPlugin struct {
 	client               *client.Client
 	containerStopTimeout int
 	defaultLogLines      string
+	defaultLimits        ContainerLimits
+	managed              *managedContainers
+	registries           []RegistryConfig
+	archCheck            string
+	registry             *registryClient
+	mu                   sync.RWMutex
+	stopChan             chan struct{}
+	doneChan             chan struct{}
 }
 
-func NewDockerPlugin(cli *client.Client, containerStopTimeout int, defaultLogLines string) (*DockerPlugin, error) {
-	if cli == nil {
+func NewDockerPlugin(cfg DockerConfig) (*DockerPlugin, error) {
+	if cfg.Client == nil {
 		return nil, fmt.Errorf("docker client cannot be nil")
 	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 	// Set defaults if not provided
+	if cfg.ContainerStopTimeout <= 0 {
+		cfg.ContainerStopTimeout = 10
+	}
+	if cfg.DefaultLogLines == "" {
+		cfg.DefaultLogLines = "100"
+	}
+	if cfg.ManagedFile == "" {
+		cfg.ManagedFile = DefaultManagedFile
+	}
+	if cfg.BootTimeout <= 0 {
+		cfg.BootTimeout = DefaultBootTimeout
+	}
+	if cfg.ArchCheck == "" {
+		cfg.ArchCheck = ArchCheckWarn
+	}
+
+	managed, err := loadManagedContainers(cfg.ManagedFile, time.Duration(cfg.BootTimeout)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	p := &DockerPlugin{
+		client:               cfg.Client,
+		containerStopTimeout: cfg.ContainerStopTimeout,
+		defaultLogLines:      cfg.DefaultLogLines,
+		defaultLimits:        cfg.DefaultLimits,
+		managed:              managed,
+		registries:           cfg.Registries,
+		archCheck:            cfg.ArchCheck,
+		registry:             newRegistryClient(),
+		stopChan:             make(chan struct{}),
+	}
+	return p, nil
+}
+
+// Start begins publishing container events to the bus and starts the
+// managed containers
+func (p *DockerPlugin) Start() error {
+	p.doneChan = make(chan struct{})
+	go p.watchContainers(p.stopChan, p.doneChan)
+	p.startManaged(managedTriggerBoot)
+	return nil
+}
+
+// Shutdown implements the Plugin interface
+// Note: Docker client is owned by the docker client service, so we don't close it here
+func (p *DockerPlugin) Shutdown() error {
+	close(p.stopChan)
+	if p.doneChan != nil {
+		<-p.doneChan
+	}
+	p.managed.wg.Wait()
+	return nil
+}
+
+// Reload applies new stop timeout, log line and limit defaults, the registries
+// and the architecture check at runtime
+// The Docker client itself is shared and requires a restart to change
+func (p *DockerPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[DockerConfig]("docker", config)
+	if err != nil {
+		return err
+	}
+	containerStopTimeout := cfg.ContainerStopTimeout
 	if containerStopTimeout <= 0 {
 		containerStopTimeout = 10
 	}
+	defaultLogLines := cfg.DefaultLogLines
 	if defaultLogLines == "" {
 		defaultLogLines = "100"
 	}
-	return &DockerPlugin{
-		client:               cli,
-		containerStopTimeout: containerStopTimeout,
-		defaultLogLines:      defaultLogLines,
-	}, nil
-}
+	archCheck := cfg.ArchCheck
+	if archCheck == "" {
+		archCheck = ArchCheckWarn
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
 
-// Shutdown implements the Plugin interface
-// Note: Docker client is shared, so we don't close it here
-func (p *DockerPlugin) Shutdown() error {
+	p.mu.Lock()
+	p.containerStopTimeout = containerStopTimeout
+	p.defaultLogLines = defaultLogLines
+	p.defaultLimits = cfg.DefaultLimits
+	p.registries = cfg.Registries
+	p.archCheck = archCheck
+	p.mu.Unlock()
+
+	slog.Info("Docker config reloaded",
+		"container_stop_timeout", containerStopTimeout,
+		"default_log_lines", defaultLogLines,
+		"arch_check", archCheck)
 	return nil
 }
 
+// settings returns the current stop timeout and default log line count
+func (p *DockerPlugin) settings() (int, string) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.containerStopTimeout, p.defaultLogLines
+}
+
+// archCheckMode returns how imports for another platform are handled
+func (p *DockerPlugin) archCheckMode() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.archCheck
+}
+
+// limitDefaults returns the limits applied to new containers
+func (p *DockerPlugin) limitDefaults() ContainerLimits {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.defaultLimits
+}
+
+// Validate checks the default container limits, the daemon endpoints, the
+// registries and the architecture check
+func (cfg DockerConfig) Validate() error {
+	if _, err := cfg.DefaultLimits.toResources(); err != nil {
+		return fmt.Errorf("docker.default_limits: %w", err)
+	}
+	if err := validateRegistries(cfg.Registries); err != nil {
+		return err
+	}
+	if err := validateArchCheck(cfg.ArchCheck); err != nil {
+		return err
+	}
+	return cfg.validateDockerHosts()
+}
+
 func (p *DockerPlugin) Name() string {
 	return "docker"
 }
 
 func (p *DockerPlugin) RegisterRoutes(app *fiber.App) {
-	api := app.Group("/api")
+	api := APIGroup(app, "")
+
+	// Refuse requests with 503 while the daemon is down
+	requireDocker := RequireDocker()
+	api.Use("/images", requireDocker)
+	api.Use("/containers", requireDocker)
 
 	// Images
 	api.Get("/images", p.listImages)
 	api.Post("/images/import", p.importImage)
+	api.Post("/images/build", p.buildImage)
 	api.Get("/images/:id/export", p.exportImage)
+	AllowSignedLinks("/images/:id/export")
+	api.Get("/images/:id", p.getImage)
 	api.Delete("/images/:id", p.deleteImage)
 
 	// Containers
@@ -64,18 +213,60 @@ func (p *DockerPlugin) RegisterRoutes(app *fiber.App) {
 	api.Post("/containers", p.createContainer)
 	api.Post("/containers/:id/start", p.startContainer)
 	api.Post("/containers/:id/stop", p.stopContainer)
+	api.Post("/containers/:id/restart", p.restartContainer)
+	api.Post("/containers/:id/pause", p.pauseContainer)
+	api.Post("/containers/:id/unpause", p.unpauseContainer)
 	api.Delete("/containers/:id", p.deleteContainer)
 	api.Get("/containers/:id/logs", p.streamLogs)
+	api.Get("/containers/:id/health", p.getContainerHealth)
+	api.Get("/containers/:id/files", p.listContainerFiles)
+	api.Get("/containers/:id/files/download", p.downloadContainerFile)
+	AllowSignedLinks("/containers/:id/files/download")
+	api.Post("/containers/:id/files/upload", p.uploadContainerFile)
+
+	// Containers started in order after boot
+	api.Get("/docker/managed", p.listManaged)
+	api.Post("/docker/managed/start", requireDocker, p.runManaged)
+	api.Put("/docker/managed/:name", requireDocker, p.setManaged)
+	api.Delete("/docker/managed/:name", p.removeManaged)
+
+	// Docker daemon
+	api.Post("/docker/prune", requireDocker, p.prune)
+	api.Get("/docker/events", requireDocker, p.streamEvents)
+
+	// Registries, which do not need the daemon
+	api.Get("/registries", p.listRegistries)
+	api.Get("/registries/:name/repositories", p.listRegistryRepositories)
+	api.Get("/registries/:name/tags", p.listRegistryTags)
+	api.Get("/registries/:name/manifest", p.getRegistryManifest)
 }
 
 // Image handlers
 
+// imageListSpec names the image fields for sorting and filtering the image list
+var imageListSpec = listSpec[image.Summary]{
+	key: "id",
+	fields: map[string]func(image.Summary) interface{}{
+		"id":      func(img image.Summary) interface{} { return img.ID },
+		"tags":    func(img image.Summary) interface{} { return img.RepoTags },
+		"size":    func(img image.Summary) interface{} { return img.Size },
+		"created": func(img image.Summary) interface{} { return time.Unix(img.Created, 0) },
+	},
+}
+
+// listImages handles GET /api/images with the shared list parameters
 func (p *DockerPlugin) listImages(c *fiber.Ctx) error {
-	ctx := context.Background()
-	images, err := p.client.ImageList(ctx, image.ListOptions{})
+	cli := selectedDockerClient(c, p.client)
+	ctx, cancel := RequestContext(c)
+	defer cancel()
+	images, err := cli.ImageList(ctx, image.ListOptions{})
 	if err != nil {
 		return SendError(c, 500, err)
 	}
+	images, meta, err := pageList(c, images, imageListSpec)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
 
 	result := make([]fiber.Map, len(images))
 	for i, img := range images {
@@ -92,17 +283,18 @@ func (p *DockerPlugin) listImages(c *fiber.Ctx) error {
 		}
 	}
 
-	return SendSuccess(c, result, "")
+	return SendList(c, result, meta)
 }
 
 func (p *DockerPlugin) importImage(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
 	file, err := c.FormFile("file")
 	if err != nil {
 		return SendErrorMessage(c, 400, "No file provided")
 	}
 
 	// Log image import details
-	slog.Info("Docker image import started",
+	slog.InfoContext(c.UserContext(), "Docker image import started",
 		"filename", file.Filename,
 		"size", file.Size)
 
@@ -114,64 +306,98 @@ func (p *DockerPlugin) importImage(c *fiber.Ctx) error {
 	// Log memory usage before starting import
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
-	slog.Info("Memory stats before Docker image import",
+	slog.InfoContext(c.UserContext(), "Memory stats before Docker image import",
 		"alloc", m.Alloc/1024/1024, // MB
 		"sys", m.Sys/1024/1024, // MB
 		"num_gc", m.NumGC)
 
+	// The upload stays readable after the request ends, so async jobs can keep using it
 	src, err := file.Open()
 	if err != nil {
 		return SendErrorMessage(c, 500, "Failed to open file")
 	}
-	defer src.Close()
 
-	// Create a context with longer timeout for large images
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
-	defer cancel()
+	logCtx := c.UserContext()
+	archCheck := p.archCheckMode()
+	return runJob(c, "image.import", "Import "+file.Filename, func(ctx context.Context, job *JobHandle) (interface{}, error) {
+		defer src.Close()
+
+		// Allow a longer timeout for large images
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+		defer cancel()
+
+		// Images for another platform load fine but fail to start, so read
+		// their platform from the archive before loading it
+		var result interface{}
+		if archCheck != ArchCheckOff {
+			job.Message("Checking image platform")
+			found, err := archivePlatforms(job.Reader(src, file.Size))
+			if err != nil {
+				return nil, fiber.NewError(400, err.Error())
+			}
+			host, err := hostPlatform(ctx, cli)
+			if err != nil {
+				return nil, err
+			}
+			platforms, warning, err := checkImagePlatforms(found, host, archCheck)
+			if err != nil {
+				slog.WarnContext(logCtx, "Docker image import rejected", "filename", file.Filename, "error", err)
+				return nil, err
+			}
+			if warning != "" {
+				slog.WarnContext(logCtx, "Docker image import platform warning", "filename", file.Filename, "warning", warning)
+			}
+			result = fiber.Map{"platforms": platforms, "host_platform": host.String(), "warning": warning}
+			if _, err := src.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
 
-	startTime := time.Now()
-	slog.Info("Starting Docker ImageLoad", "filename", file.Filename)
+		startTime := time.Now()
+		slog.InfoContext(logCtx, "Starting Docker ImageLoad", "filename", file.Filename)
 
-	resp, err := p.client.ImageLoad(ctx, src, true)
-	if err != nil {
-		slog.Error("Docker ImageLoad failed",
-			"filename", file.Filename,
-			"error", err,
-			"duration", time.Since(startTime))
-		return SendError(c, 500, err)
-	}
-	defer resp.Body.Close()
+		resp, err := cli.ImageLoad(ctx, job.Reader(src, file.Size), true)
+		if err != nil {
+			slog.ErrorContext(logCtx, "Docker ImageLoad failed",
+				"filename", file.Filename,
+				"error", err,
+				"duration", time.Since(startTime))
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		// Read response to ensure completion
+		slog.InfoContext(logCtx, "Processing Docker image load response")
+		job.Message("Loading image")
+		_, err = io.Copy(io.Discard, resp.Body)
+		if err != nil {
+			slog.ErrorContext(logCtx, "Failed to process Docker image load response",
+				"filename", file.Filename,
+				"error", err,
+				"duration", time.Since(startTime))
+			return nil, fmt.Errorf("Failed to process response: %v", err)
+		}
 
-	// Read response to ensure completion
-	slog.Info("Processing Docker image load response")
-	_, err = io.Copy(io.Discard, resp.Body)
-	if err != nil {
-		slog.Error("Failed to process Docker image load response",
+		// Log completion and memory usage after import
+		runtime.ReadMemStats(&m)
+		slog.InfoContext(logCtx, "Docker image import completed",
 			"filename", file.Filename,
-			"error", err,
-			"duration", time.Since(startTime))
-		return SendErrorMessage(c, 500, fmt.Sprintf("Failed to process response: %v", err))
-	}
-
-	// Log completion and memory usage after import
-	runtime.ReadMemStats(&m)
-	slog.Info("Docker image import completed",
-		"filename", file.Filename,
-		"size", file.Size,
-		"duration", time.Since(startTime),
-		"alloc_after", m.Alloc/1024/1024, // MB
-		"sys_after", m.Sys/1024/1024) // MB
-
-	return SendSuccess(c, nil, "Image imported successfully")
+			"size", file.Size,
+			"duration", time.Since(startTime),
+			"alloc_after", m.Alloc/1024/1024, // MB
+			"sys_after", m.Sys/1024/1024) // MB
+		return result, nil
+	}, "Image imported successfully")
 }
 
 func (p *DockerPlugin) exportImage(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
 	imageID := c.Params("id")
 	ctx := context.Background()
 
-	reader, err := p.client.ImageSave(ctx, []string{imageID})
+	reader, err := cli.ImageSave(ctx, []string{imageID})
 	if err != nil {
-		slog.Error("Failed to export image", "imageID", imageID[:12], "error", err)
+		slog.ErrorContext(c.UserContext(), "Failed to export image", "imageID", imageID[:12], "error", err)
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -204,10 +430,12 @@ func (p *DockerPlugin) exportImage(c *fiber.Ctx) error {
 }
 
 func (p *DockerPlugin) deleteImage(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
 	imageID := c.Params("id")
-	ctx := context.Background()
+	ctx, cancel := RequestContext(c)
+	defer cancel()
 
-	_, err := p.client.ImageRemove(ctx, imageID, image.RemoveOptions{
+	_, err := cli.ImageRemove(ctx, imageID, image.RemoveOptions{
 		Force:         true,
 		PruneChildren: true,
 	})
@@ -220,13 +448,37 @@ func (p *DockerPlugin) deleteImage(c *fiber.Ctx) error {
 
 // Container handlers
 
+// containerListSpec names the container fields for sorting and filtering the container list
+var containerListSpec = listSpec[types.Container]{
+	key: "id",
+	fields: map[string]func(types.Container) interface{}{
+		"id":      func(cont types.Container) interface{} { return cont.ID },
+		"name":    func(cont types.Container) interface{} { return containerName(cont.Names) },
+		"image":   func(cont types.Container) interface{} { return cont.Image },
+		"state":   func(cont types.Container) interface{} { return cont.State },
+		"status":  func(cont types.Container) interface{} { return cont.Status },
+		"health":  func(cont types.Container) interface{} { return healthFromStatus(cont.Status) },
+		"created": func(cont types.Container) interface{} { return time.Unix(cont.Created, 0) },
+	},
+}
+
+// listContainers handles GET /api/containers with the shared list parameters
+// Limits and restart policies need an inspect per container, so they are only
+// added with ?limits=1, for the containers of the requested page.
 func (p *DockerPlugin) listContainers(c *fiber.Ctx) error {
-	ctx := context.Background()
-	containers, err := p.client.ContainerList(ctx, container.ListOptions{All: true})
+	cli := selectedDockerClient(c, p.client)
+	ctx, cancel := RequestContext(c)
+	defer cancel()
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
 	if err != nil {
 		return SendError(c, 500, err)
 	}
+	containers, meta, err := pageList(c, containers, containerListSpec)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
 
+	withLimits := c.QueryBool("limits")
 	result := make([]fiber.Map, len(containers))
 	for i, cont := range containers {
 		result[i] = fiber.Map{
@@ -235,19 +487,35 @@ func (p *DockerPlugin) listContainers(c *fiber.Ctx) error {
 			"image":   cont.Image,
 			"state":   cont.State,
 			"status":  cont.Status,
+			"health":  healthFromStatus(cont.Status),
 			"created": time.Unix(cont.Created, 0).Format(time.RFC3339),
 		}
+		// The list endpoint does not report resources
+		if withLimits {
+			if inspect, err := cli.ContainerInspect(ctx, cont.ID); err == nil && inspect.HostConfig != nil {
+				result[i]["limits"] = containerLimits(inspect.HostConfig.Resources)
+				result[i]["restart_policy"] = inspect.HostConfig.RestartPolicy.Name
+			}
+		}
+		// Managed containers are those of the local daemon
+		if entry, ok := p.managed.get(containerName(cont.Names)); ok && cli == p.client {
+			result[i]["managed"] = entry
+		}
 	}
 
-	return SendSuccess(c, result, "")
+	return SendList(c, result, meta)
 }
 
 func (p *DockerPlugin) createContainer(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
 	var req struct {
-		Image string   `json:"image"`
-		Name  string   `json:"name"`
-		Env   []string `json:"env"`
-		Cmd   []string `json:"cmd"`
+		Image       string              `json:"image"`
+		Name        string              `json:"name"`
+		Env         []string            `json:"env"`
+		Cmd         []string            `json:"cmd"`
+		Healthcheck *HealthcheckRequest `json:"healthcheck"`
+		Limits      ContainerLimits     `json:"limits"`
+		Restart     string              `json:"restart_policy"` // no, always, on-failure, unless-stopped
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -264,7 +532,8 @@ func (p *DockerPlugin) createContainer(c *fiber.Ctx) error {
 		return SendErrorMessage(c, 400, "Image name too long")
 	}
 
-	ctx := context.Background()
+	ctx, cancel := RequestContext(c)
+	defer cancel()
 
 	// Create container config
 	config := &container.Config{
@@ -273,8 +542,28 @@ func (p *DockerPlugin) createContainer(c *fiber.Ctx) error {
 		Cmd:   req.Cmd,
 	}
 
+	if req.Healthcheck != nil {
+		healthcheck, err := req.Healthcheck.toHealthConfig()
+		if err != nil {
+			return SendError(c, 400, err)
+		}
+		config.Healthcheck = healthcheck
+	}
+
+	// Unconstrained containers can exhaust the device's memory
+	resources, err := req.Limits.withDefaults(p.limitDefaults()).toResources()
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+
+	restartPolicy := container.RestartPolicy{Name: container.RestartPolicyMode(req.Restart)}
+	if err := container.ValidateRestartPolicy(restartPolicy); err != nil {
+		return SendError(c, 400, err)
+	}
+
 	// Create container
-	resp, err := p.client.ContainerCreate(ctx, config, nil, nil, nil, req.Name)
+	hostConfig := &container.HostConfig{Resources: resources, RestartPolicy: restartPolicy}
+	resp, err := cli.ContainerCreate(ctx, config, hostConfig, nil, nil, req.Name)
 	if err != nil {
 		return SendError(c, 500, err)
 	}
@@ -286,10 +575,12 @@ func (p *DockerPlugin) createContainer(c *fiber.Ctx) error {
 }
 
 func (p *DockerPlugin) startContainer(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
 	containerID := c.Params("id")
-	ctx := context.Background()
+	ctx, cancel := RequestContext(c)
+	defer cancel()
 
-	if err := p.client.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
+	if err := cli.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
 		return SendError(c, 500, err)
 	}
 
@@ -297,22 +588,76 @@ func (p *DockerPlugin) startContainer(c *fiber.Ctx) error {
 }
 
 func (p *DockerPlugin) stopContainer(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
 	containerID := c.Params("id")
-	ctx := context.Background()
+	ctx, cancel := RequestContext(c)
+	defer cancel()
 
-	timeout := p.containerStopTimeout
-	if err := p.client.ContainerStop(ctx, containerID, container.StopOptions{Timeout: &timeout}); err != nil {
+	timeout, _ := p.settings()
+	if err := cli.ContainerStop(ctx, containerID, container.StopOptions{Timeout: &timeout}); err != nil {
 		return SendError(c, 500, err)
 	}
 
 	return SendSuccess(c, nil, "Container stopped")
 }
 
+// restartContainer restarts a container in a single daemon call
+// Accepts an optional ?timeout=seconds overriding the configured stop timeout
+func (p *DockerPlugin) restartContainer(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
+	containerID := c.Params("id")
+	ctx, cancel := RequestContext(c)
+	defer cancel()
+
+	timeout, _ := p.settings()
+	if value := c.Query("timeout"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return SendErrorMessage(c, 400, "Invalid timeout")
+		}
+		timeout = parsed
+	}
+
+	if err := cli.ContainerRestart(ctx, containerID, container.StopOptions{Timeout: &timeout}); err != nil {
+		return SendError(c, 500, err)
+	}
+
+	return SendSuccess(c, nil, "Container restarted")
+}
+
+func (p *DockerPlugin) pauseContainer(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
+	containerID := c.Params("id")
+	ctx, cancel := RequestContext(c)
+	defer cancel()
+
+	if err := cli.ContainerPause(ctx, containerID); err != nil {
+		return SendError(c, 500, err)
+	}
+
+	return SendSuccess(c, nil, "Container paused")
+}
+
+func (p *DockerPlugin) unpauseContainer(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
+	containerID := c.Params("id")
+	ctx, cancel := RequestContext(c)
+	defer cancel()
+
+	if err := cli.ContainerUnpause(ctx, containerID); err != nil {
+		return SendError(c, 500, err)
+	}
+
+	return SendSuccess(c, nil, "Container unpaused")
+}
+
 func (p *DockerPlugin) deleteContainer(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
 	containerID := c.Params("id")
-	ctx := context.Background()
+	ctx, cancel := RequestContext(c)
+	defer cancel()
 
-	if err := p.client.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: true}); err != nil {
+	if err := cli.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: true}); err != nil {
 		return SendError(c, 500, err)
 	}
 
@@ -320,6 +665,7 @@ func (p *DockerPlugin) deleteContainer(c *fiber.Ctx) error {
 }
 
 func (p *DockerPlugin) streamLogs(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
 	containerID := c.Params("id")
 	ctx := context.Background()
 
@@ -330,11 +676,12 @@ func (p *DockerPlugin) streamLogs(c *fiber.Ctx) error {
 	c.Set("X-Accel-Buffering", "no")
 
 	// Get container logs
-	logs, err := p.client.ContainerLogs(ctx, containerID, container.LogsOptions{
+	_, defaultLogLines := p.settings()
+	logs, err := cli.ContainerLogs(ctx, containerID, container.LogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
 		Follow:     true,
-		Tail:       p.defaultLogLines,
+		Tail:       defaultLogLines,
 	})
 	if err != nil {
 		return c.Status(500).JSON(APIResponse{
@@ -375,26 +722,11 @@ func hasValidImageExtension(filename string) bool {
 // Register the plugin
 func init() {
 	Register("docker", func(config interface{}) (Plugin, error) {
-		cfg, ok := config.(map[string]interface{})
-		if !ok {
-			return nil, fmt.Errorf("invalid config for docker plugin: expected map[string]interface{}")
-		}
-
-		cli, ok := cfg["client"].(*client.Client)
-		if !ok {
-			return nil, fmt.Errorf("invalid config for docker plugin: missing or invalid client")
-		}
-
-		containerStopTimeout := 10
-		if timeout, ok := cfg["container_stop_timeout"].(int); ok {
-			containerStopTimeout = timeout
-		}
-
-		defaultLogLines := "100"
-		if lines, ok := cfg["default_log_lines"].(string); ok && lines != "" {
-			defaultLogLines = lines
+		cfg, err := configAs[DockerConfig]("docker", config)
+		if err != nil {
+			return nil, err
 		}
 
-		return NewDockerPlugin(cli, containerStopTimeout, defaultLogLines)
-	})
+		return NewDockerPlugin(cfg)
+	}, "dockerclient")
 }
@@ -0,0 +1,168 @@
+package plugins
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Image architecture checks on import
+const (
+	ArchCheckOff    = "off"
+	ArchCheckWarn   = "warn" // import and report the mismatch
+	ArchCheckReject = "reject"
+
+	archMaxMetadata = 1024 * 1024      // bytes of one JSON file in an image archive
+	archMaxKept     = 16 * 1024 * 1024 // bytes of JSON files kept while reading an archive
+)
+
+// ImagePlatform is the platform an image in an archive is built for
+type ImagePlatform struct {
+	Tags         []string `json:"tags,omitempty"`
+	OS           string   `json:"os"`
+	Architecture string   `json:"architecture"`
+	Variant      string   `json:"variant,omitempty"`
+	Matches      bool     `json:"matches"` // runs on the Docker host
+}
+
+// String returns the platform as os/architecture[/variant]
+func (ip ImagePlatform) String() string {
+	s := ip.OS + "/" + ip.Architecture
+	if ip.Variant != "" {
+		s += "/" + ip.Variant
+	}
+	return s
+}
+
+// validateArchCheck checks the docker.arch_check mode
+func validateArchCheck(mode string) error {
+	switch mode {
+	case "", ArchCheckOff, ArchCheckWarn, ArchCheckReject:
+		return nil
+	}
+	return fmt.Errorf("docker.arch_check must be off, warn or reject, got %q", mode)
+}
+
+// imageConfig is the part of an image config naming its platform
+type imageConfig struct {
+	OS           string          `json:"os"`
+	Architecture string          `json:"architecture"`
+	Variant      string          `json:"variant"`
+	RootFS       json.RawMessage `json:"rootfs"`
+}
+
+// archivePlatforms reads the platforms of the images in a docker save or OCI archive
+// The archive may be gzip compressed. Image configs are found through
+// manifest.json, which names the tags, or else as the JSON files that
+// describe a root filesystem.
+func archivePlatforms(r io.Reader) ([]ImagePlatform, error) {
+	br := bufio.NewReader(r)
+	var src io.Reader = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	files := make(map[string][]byte)
+	kept := 0
+	tr := tar.NewReader(src)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid image archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg || hdr.Size > archMaxMetadata || kept+int(hdr.Size) > archMaxKept {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image archive: %w", err)
+		}
+		if data = bytes.TrimSpace(data); len(data) > 0 && (data[0] == '{' || data[0] == '[') {
+			files[path.Clean(hdr.Name)] = data
+			kept += len(data)
+		}
+	}
+
+	platforms := []ImagePlatform{}
+	var manifest []struct {
+		Config   string
+		RepoTags []string
+	}
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err == nil && len(manifest) > 0 {
+		for _, entry := range manifest {
+			var cfg imageConfig
+			if json.Unmarshal(files[path.Clean(entry.Config)], &cfg) != nil || cfg.Architecture == "" {
+				continue
+			}
+			platforms = append(platforms, ImagePlatform{Tags: entry.RepoTags, OS: cfg.OS, Architecture: cfg.Architecture, Variant: cfg.Variant})
+		}
+		return platforms, nil
+	}
+
+	// OCI archives without manifest.json
+	for _, data := range files {
+		var cfg imageConfig
+		if json.Unmarshal(data, &cfg) != nil || cfg.Architecture == "" || cfg.RootFS == nil {
+			continue
+		}
+		platforms = append(platforms, ImagePlatform{OS: cfg.OS, Architecture: cfg.Architecture, Variant: cfg.Variant})
+	}
+	return platforms, nil
+}
+
+// hostPlatform returns the os/architecture of the Docker host
+func hostPlatform(ctx context.Context, cli *client.Client) (ImagePlatform, error) {
+	version, err := cli.ServerVersion(ctx)
+	if err != nil {
+		return ImagePlatform{}, err
+	}
+	return ImagePlatform{OS: version.Os, Architecture: version.Arch}, nil
+}
+
+// checkImagePlatforms compares the images of an archive with the Docker host
+// It returns a warning for images built for another platform, or an error
+// in reject mode. Archives whose platform cannot be read are let through.
+func checkImagePlatforms(platforms []ImagePlatform, host ImagePlatform, mode string) ([]ImagePlatform, string, error) {
+	if len(platforms) == 0 {
+		return platforms, "could not read the platform of the image from the archive", nil
+	}
+	var foreign []string
+	for i := range platforms {
+		ip := &platforms[i]
+		ip.Matches = ip.OS == host.OS && ip.Architecture == host.Architecture
+		if !ip.Matches {
+			name := ip.String()
+			if len(ip.Tags) > 0 {
+				name = strings.Join(ip.Tags, ", ") + " (" + name + ")"
+			}
+			foreign = append(foreign, name)
+		}
+	}
+	if len(foreign) == 0 {
+		return platforms, "", nil
+	}
+
+	message := fmt.Sprintf("image built for another platform than the Docker host (%s): %s", host, strings.Join(foreign, "; "))
+	if mode == ArchCheckReject {
+		return platforms, "", fiber.NewError(fiber.StatusUnprocessableEntity, message)
+	}
+	return platforms, message, nil
+}
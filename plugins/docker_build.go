@@ -0,0 +1,201 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Image build constants
+const (
+	DefaultDockerfile = "Dockerfile"
+	ImageBuildTimeout = 60 * time.Minute
+)
+
+// buildMessage is a single line of the Docker build progress stream
+type buildMessage struct {
+	Stream      string `json:"stream"`
+	Status      string `json:"status"`
+	Error       string `json:"error"`
+	ErrorDetail struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+	Aux struct {
+		ID string `json:"ID"`
+	} `json:"aux"`
+}
+
+// buildImage handles POST /api/images/build
+// Accepts a multipart upload of a tar build context (file) with tag, dockerfile,
+// build_arg (KEY=VALUE, repeatable), nocache and pull fields; streams build output via SSE
+func (p *DockerPlugin) buildImage(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
+	file, err := c.FormFile("file")
+	if err != nil {
+		return SendErrorMessage(c, 400, "No build context provided")
+	}
+	if !hasValidImageExtension(file.Filename) {
+		return SendErrorMessage(c, 400, "Invalid build context. Only .tar, .tar.gz, or .tgz files are accepted")
+	}
+
+	tags, err := parseImageTags(c.FormValue("tag"))
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+
+	buildArgs := make(map[string]*string)
+	if form, err := c.MultipartForm(); err == nil {
+		for _, arg := range form.Value["build_arg"] {
+			key, value, ok := strings.Cut(arg, "=")
+			if !ok || key == "" {
+				return SendErrorMessage(c, 400, fmt.Sprintf("Invalid build_arg %q, expected KEY=VALUE", arg))
+			}
+			buildArgs[key] = &value
+		}
+	}
+
+	dockerfile := c.FormValue("dockerfile", DefaultDockerfile)
+	if strings.Contains(dockerfile, "..") {
+		return SendErrorMessage(c, 400, "Invalid dockerfile path")
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return SendErrorMessage(c, 500, "Failed to open build context")
+	}
+	defer src.Close()
+
+	requestCtx := c.UserContext()
+	slog.InfoContext(requestCtx, "Docker image build started",
+		"filename", file.Filename,
+		"size", file.Size,
+		"tags", tags,
+		"dockerfile", dockerfile)
+
+	ctx, cancel := context.WithTimeout(context.Background(), ImageBuildTimeout)
+
+	// Until output is streamed, a disconnect is only noticed on the connection itself
+	stopWatch := watchDisconnect(c.Context().Conn(), cancel)
+
+	// The daemon reads the full context before responding, so the upload can be closed afterwards
+	resp, err := cli.ImageBuild(ctx, src, types.ImageBuildOptions{
+		Tags:        tags,
+		Dockerfile:  dockerfile,
+		BuildArgs:   buildArgs,
+		NoCache:     c.FormValue("nocache") == "true",
+		PullParent:  c.FormValue("pull") == "true",
+		Remove:      true,
+		ForceRemove: true,
+	})
+	stopWatch()
+	if err != nil {
+		cancel()
+		slog.ErrorContext(requestCtx, "Docker image build failed", "error", err)
+		return SendError(c, 500, err)
+	}
+
+	// Set SSE headers
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	startTime := time.Now()
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		defer resp.Body.Close()
+
+		imageID, buildErr := streamBuildOutput(resp.Body, w)
+		if buildErr != nil {
+			slog.ErrorContext(requestCtx, "Docker image build failed",
+				"error", buildErr,
+				"duration", time.Since(startTime))
+			data, _ := json.Marshal(fiber.Map{"error": buildErr.Error()})
+			fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+			w.Flush()
+			return
+		}
+
+		slog.InfoContext(requestCtx, "Docker image build completed",
+			"id", imageID,
+			"tags", tags,
+			"duration", time.Since(startTime))
+		data, _ := json.Marshal(fiber.Map{"id": imageID, "tags": tags})
+		fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
+		w.Flush()
+	})
+
+	return nil
+}
+
+// streamBuildOutput forwards build progress lines as SSE data events
+// Returns the built image ID or the build error
+func streamBuildOutput(body io.Reader, w *bufio.Writer) (string, error) {
+	var imageID string
+	decoder := json.NewDecoder(body)
+
+	for {
+		var msg buildMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", fmt.Errorf("failed to read build output: %w", err)
+		}
+
+		if msg.Error != "" {
+			return "", fmt.Errorf("%s", msg.Error)
+		}
+		if msg.Aux.ID != "" {
+			imageID = msg.Aux.ID
+		}
+
+		text := msg.Stream
+		if text == "" {
+			text = msg.Status
+		}
+		for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+			if line == "" {
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", line)
+		}
+		if err := w.Flush(); err != nil {
+			// Client went away; the deferred cancel aborts the build
+			return "", fmt.Errorf("client disconnected")
+		}
+	}
+
+	if imageID == "" {
+		return "", fmt.Errorf("build finished without producing an image")
+	}
+	return imageID, nil
+}
+
+// parseImageTags splits a comma-separated tag list and checks each tag
+func parseImageTags(value string) ([]string, error) {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		// Full reference validation is left to the daemon
+		if len(tag) > 255 || strings.ContainsAny(tag, " \t\n") {
+			return nil, fmt.Errorf("invalid image tag %q", tag)
+		}
+		tags = append(tags, tag)
+	}
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("at least one tag is required")
+	}
+	return tags, nil
+}
@@ -0,0 +1,302 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Docker liveness defaults
+const (
+	DefaultDockerPingInterval = 10 // seconds
+	dockerPingTimeout         = 5 * time.Second
+	dockerRecheckInterval     = 2 * time.Second // requests recheck an unavailable daemon at most this often
+	dockerClientEventSource   = "dockerclient"
+)
+
+// ErrCodeDockerUnavailable is the error code of responses refused while the daemon is down
+const ErrCodeDockerUnavailable = "docker_unavailable"
+
+// DockerStatus describes the connection to a Docker daemon
+type DockerStatus struct {
+	Host       string    `json:"host"`
+	Available  bool      `json:"available"`
+	Socket     string    `json:"socket"`
+	APIVersion string    `json:"api_version,omitempty"` // negotiated with the daemon
+	Error      string    `json:"error,omitempty"`
+	Since      time.Time `json:"since"` // when availability last changed
+	LastCheck  time.Time `json:"last_check"`
+	Reconnects int       `json:"reconnects"` // times the daemon came back after being unavailable
+}
+
+// dockerHost is one daemon the manager administers
+type dockerHost struct {
+	client  *client.Client
+	checkMu sync.Mutex // one check at a time
+	status  DockerStatus
+}
+
+// DockerClientService owns the Docker clients shared by the plugins that depend on it
+// It is loaded automatically as a dependency and watches whether the daemons answer.
+// Other plugins use the client of the local daemon; Docker routes can select
+// one of docker.hosts per request.
+type DockerClientService struct {
+	hosts        []*dockerHost // local first
+	pingInterval time.Duration
+	stopChan     chan struct{}
+	doneChan     chan struct{}
+}
+
+// Docker hosts shared by the Docker routes; daemons are assumed available until the first check
+var (
+	dockerHosts   map[string]*dockerHost
+	dockerHostsMu sync.RWMutex
+)
+
+// NewDockerClientService creates the clients for docker.socket and docker.hosts
+func NewDockerClientService(cfg DockerConfig) (*DockerClientService, error) {
+	if err := cfg.validateDockerHosts(); err != nil {
+		return nil, err
+	}
+	if cfg.PingInterval <= 0 {
+		cfg.PingInterval = DefaultDockerPingInterval
+	}
+	s := &DockerClientService{
+		pingInterval: time.Duration(cfg.PingInterval) * time.Second,
+		stopChan:     make(chan struct{}),
+	}
+
+	endpoints := append([]DockerHostConfig{{Name: LocalDockerHost, Socket: cfg.Socket, TLS: cfg.TLS}}, cfg.Hosts...)
+	hosts := make(map[string]*dockerHost, len(endpoints))
+	for _, endpoint := range endpoints {
+		cli, err := newDockerClient(endpoint.Socket, endpoint.TLS)
+		if err != nil {
+			for _, host := range s.hosts {
+				host.client.Close()
+			}
+			return nil, fmt.Errorf("failed to create Docker client for %s: %w", endpoint.Name, err)
+		}
+		host := &dockerHost{
+			client: cli,
+			status: DockerStatus{Host: endpoint.Name, Available: true, Socket: endpoint.Socket, Since: time.Now()},
+		}
+		s.hosts = append(s.hosts, host)
+		hosts[endpoint.Name] = host
+		slog.Info("Docker client created", "host", endpoint.Name, "socket", endpoint.Socket)
+	}
+
+	dockerHostsMu.Lock()
+	dockerHosts = hosts
+	dockerHostsMu.Unlock()
+	return s, nil
+}
+
+// Name returns the plugin identifier
+func (s *DockerClientService) Name() string {
+	return "dockerclient"
+}
+
+// RegisterRoutes adds the daemon status routes
+// They are registered before the Docker plugin's routes and stay reachable while a daemon is down.
+func (s *DockerClientService) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/docker")
+	api.Get("/status", s.handleStatus)
+	api.Get("/hosts", s.handleHosts)
+}
+
+// Start checks the daemons and keeps watching them
+func (s *DockerClientService) Start() error {
+	s.checkAll()
+	s.doneChan = make(chan struct{})
+	go s.watch()
+	return nil
+}
+
+// Shutdown stops watching and closes the clients after all dependent plugins have stopped
+func (s *DockerClientService) Shutdown() error {
+	close(s.stopChan)
+	if s.doneChan != nil {
+		<-s.doneChan
+	}
+	dockerHostsMu.Lock()
+	dockerHosts = nil
+	dockerHostsMu.Unlock()
+
+	var firstErr error
+	for _, host := range s.hosts {
+		if err := host.client.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Client returns the client of the local daemon
+func (s *DockerClientService) Client() *client.Client {
+	return s.hosts[0].client
+}
+
+// watch pings the daemons every ping interval until stopped
+func (s *DockerClientService) watch() {
+	defer close(s.doneChan)
+	ticker := time.NewTicker(s.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.checkAll()
+		}
+	}
+}
+
+// checkAll checks the daemons in parallel, so a slow remote host does not delay the others
+func (s *DockerClientService) checkAll() {
+	var wg sync.WaitGroup
+	for _, host := range s.hosts {
+		wg.Add(1)
+		go func(host *dockerHost) {
+			defer wg.Done()
+			host.check()
+		}(host)
+	}
+	wg.Wait()
+}
+
+// check pings the daemon and records the outcome
+// When the daemon is back, pooled connections to the old one are dropped and
+// the API version is negotiated again, as the daemon may have been upgraded.
+func (h *dockerHost) check() DockerStatus {
+	h.checkMu.Lock()
+	defer h.checkMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), dockerPingTimeout)
+	defer cancel()
+	_, err := h.client.Ping(ctx)
+
+	previous := h.currentStatus()
+	status := previous
+	status.LastCheck = time.Now()
+	status.Available = err == nil
+	status.Error = ""
+	if err != nil {
+		status.Error = err.Error()
+	}
+	if status.Available != previous.Available {
+		status.Since = status.LastCheck
+	}
+
+	switch {
+	case err != nil && previous.Available:
+		slog.Warn("Docker daemon unavailable", "host", status.Host, "socket", status.Socket, "error", err)
+		PublishEvent("docker.unavailable", dockerClientEventSource, status)
+	case err == nil && !previous.Available:
+		h.client.Close()
+		h.client.NegotiateAPIVersion(ctx)
+		status.Reconnects++
+		slog.Info("Docker daemon available again", "host", status.Host, "socket", status.Socket,
+			"api_version", h.client.ClientVersion(), "down_for", status.LastCheck.Sub(previous.Since).Round(time.Second))
+		PublishEvent("docker.available", dockerClientEventSource, status)
+	}
+	if err == nil {
+		status.APIVersion = h.client.ClientVersion()
+	}
+
+	dockerHostsMu.Lock()
+	h.status = status
+	dockerHostsMu.Unlock()
+	return status
+}
+
+// currentStatus returns the last recorded daemon status
+func (h *dockerHost) currentStatus() DockerStatus {
+	dockerHostsMu.RLock()
+	defer dockerHostsMu.RUnlock()
+	return h.status
+}
+
+// lookupDockerHost returns a configured host by name; ok is false for unknown
+// names and when no Docker client service is loaded
+func lookupDockerHost(name string) (*dockerHost, bool) {
+	dockerHostsMu.RLock()
+	defer dockerHostsMu.RUnlock()
+	host, ok := dockerHosts[name]
+	return host, ok
+}
+
+// RequireDocker selects the daemon of a request (?host=, default local) and
+// answers 503 with the docker_unavailable code while it is unavailable,
+// instead of passing requests on to fail with socket errors
+// An unavailable daemon is checked again on request, so routes recover as soon
+// as it is back; a failed request triggers a check in the background.
+func RequireDocker() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		name := c.Query("host", LocalDockerHost)
+		host, ok := lookupDockerHost(name)
+		if !ok {
+			if name == LocalDockerHost {
+				return c.Next()
+			}
+			return SendErrorMessage(c, 404, fmt.Sprintf("Unknown Docker host %q", name))
+		}
+
+		status := host.currentStatus()
+		if !status.Available && time.Since(status.LastCheck) >= dockerRecheckInterval {
+			status = host.check()
+		}
+		if !status.Available {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(APIResponse{
+				Success: false,
+				Error:   "Docker daemon is not available: " + status.Error,
+				Code:    ErrCodeDockerUnavailable,
+			})
+		}
+
+		c.Locals(dockerClientLocal, host.client)
+		err := c.Next()
+		if c.Response().StatusCode() >= fiber.StatusInternalServerError {
+			go host.check()
+		}
+		return err
+	}
+}
+
+// handleStatus handles GET /api/docker/status?host=local&check=false
+func (s *DockerClientService) handleStatus(c *fiber.Ctx) error {
+	host, ok := lookupDockerHost(c.Query("host", LocalDockerHost))
+	if !ok {
+		return SendErrorMessage(c, 404, "Unknown Docker host")
+	}
+	if c.QueryBool("check") {
+		return SendSuccess(c, host.check(), "")
+	}
+	return SendSuccess(c, host.currentStatus(), "")
+}
+
+// handleHosts handles GET /api/docker/hosts
+// Lists the local daemon and docker.hosts with their status
+func (s *DockerClientService) handleHosts(c *fiber.Ctx) error {
+	statuses := make([]DockerStatus, len(s.hosts))
+	for i, host := range s.hosts {
+		statuses[i] = host.currentStatus()
+	}
+	return SendSuccess(c, statuses, "")
+}
+
+// Register the plugin
+func init() {
+	Register("dockerclient", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[DockerConfig]("dockerclient", config)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewDockerClientService(cfg)
+	})
+}
@@ -0,0 +1,173 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Container failure events published on the event bus
+const (
+	containerDiedEvent    = "docker.container.died"
+	containerCrashedEvent = "docker.container.crashed"
+	containerOOMEvent     = "docker.container.oom"
+	dockerEventSource     = "docker"
+	dockerWatchRetry      = 5 * time.Second
+)
+
+// DockerEvent is a simplified Docker daemon event
+type DockerEvent struct {
+	Type       string            `json:"type"`
+	Action     string            `json:"action"`
+	ID         string            `json:"id"`
+	Name       string            `json:"name,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Time       time.Time         `json:"time"`
+}
+
+// streamEvents handles GET /api/docker/events?type=container&container=id&event=start
+// Streams Docker daemon events via SSE; each query parameter accepts a comma-separated list
+func (p *DockerPlugin) streamEvents(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
+	args := filters.NewArgs()
+	addEventFilter(args, "type", c.Query("type"))
+	addEventFilter(args, "container", c.Query("container"))
+	addEventFilter(args, "event", c.Query("event"))
+
+	// Set SSE headers
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	requestCtx := c.UserContext()
+	slog.InfoContext(requestCtx, "Docker event stream opened", "filters", args.Keys())
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		messages, errs := cli.Events(ctx, events.ListOptions{Filters: args})
+
+		keepAlive := time.NewTicker(eventKeepAlive)
+		defer keepAlive.Stop()
+
+		// Tell the client the subscription is live so it can sync its state once
+		fmt.Fprint(w, "event: ready\ndata: {}\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		for {
+			select {
+			case msg := <-messages:
+				data, err := json.Marshal(toDockerEvent(msg))
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Type, data)
+			case err := <-errs:
+				slog.ErrorContext(requestCtx, "Docker event stream failed", "error", err)
+				data, _ := json.Marshal(fiber.Map{"error": err.Error()})
+				fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+				w.Flush()
+				return
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+
+			if err := w.Flush(); err != nil {
+				slog.InfoContext(requestCtx, "Docker event stream closed")
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+// toDockerEvent converts a daemon event message
+func toDockerEvent(msg events.Message) DockerEvent {
+	return DockerEvent{
+		Type:       string(msg.Type),
+		Action:     string(msg.Action),
+		ID:         msg.Actor.ID,
+		Name:       msg.Actor.Attributes["name"],
+		Attributes: msg.Actor.Attributes,
+		Time:       time.Unix(0, msg.TimeNano),
+	}
+}
+
+// addEventFilter adds each value of a comma-separated list to the filter key
+func addEventFilter(args filters.Args, key string, value string) {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			args.Add(key, item)
+		}
+	}
+}
+
+// watchContainers publishes container exits on the event bus until stopped
+// An exit with a non-zero code that was not preceded by a kill (docker stop/kill) is reported as a crash
+func (p *DockerPlugin) watchContainers(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	args := filters.NewArgs()
+	args.Add("type", string(events.ContainerEventType))
+	args.Add("event", string(events.ActionKill))
+	args.Add("event", string(events.ActionDie))
+	args.Add("event", string(events.ActionOOM))
+
+	killed := make(map[string]bool)
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		messages, errs := p.client.Events(ctx, events.ListOptions{Filters: args})
+
+	watch:
+		for {
+			select {
+			case <-stop:
+				cancel()
+				return
+			case err := <-errs:
+				slog.Debug("Docker container watch interrupted", "error", err)
+				break watch
+			case msg := <-messages:
+				p.publishContainerEvent(toDockerEvent(msg), killed)
+			}
+		}
+		cancel()
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(dockerWatchRetry):
+		}
+	}
+}
+
+// publishContainerEvent maps a daemon event to bus events
+func (p *DockerPlugin) publishContainerEvent(event DockerEvent, killed map[string]bool) {
+	switch event.Action {
+	case string(events.ActionKill):
+		killed[event.ID] = true
+	case string(events.ActionOOM):
+		PublishEvent(containerOOMEvent, dockerEventSource, event)
+	case string(events.ActionDie):
+		exitCode := event.Attributes["exitCode"]
+		PublishEvent(containerDiedEvent, dockerEventSource, event)
+		if exitCode != "0" && !killed[event.ID] {
+			slog.Warn("Container crashed", "name", event.Name, "exit_code", exitCode)
+			PublishEvent(containerCrashedEvent, dockerEventSource, event)
+		}
+		delete(killed, event.ID)
+	}
+}
@@ -0,0 +1,306 @@
+package plugins
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/gofiber/fiber/v2"
+)
+
+// containerListScanLimit bounds the archive read to list a container directory
+// The daemon sends the whole tree below the directory; listings of larger
+// trees stop early and are marked truncated.
+const containerListScanLimit = 256 * 1024 * 1024 // 256MB
+
+// ContainerDirectoryListing is the contents of a directory inside a container
+type ContainerDirectoryListing struct {
+	DirectoryListing
+	Truncated bool `json:"truncated,omitempty"` // the tree was too large to read completely
+}
+
+// containerPath cleans a path inside a container; container paths are always absolute
+func containerPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+// statContainerPath describes a path inside a container, following a final symbolic link
+// It returns the path that was described, which is the link target for links.
+func statContainerPath(ctx context.Context, cli *client.Client, containerID, p string) (container.PathStat, string, error) {
+	stat, err := cli.ContainerStatPath(ctx, containerID, p)
+	if err != nil {
+		return stat, p, err
+	}
+	if stat.Mode&os.ModeSymlink != 0 && stat.LinkTarget != "" {
+		// The daemon resolves the link within the container's filesystem
+		p = stat.LinkTarget
+		stat, err = cli.ContainerStatPath(ctx, containerID, p)
+	}
+	return stat, p, err
+}
+
+// sendContainerPathError answers a failed stat or copy
+func sendContainerPathError(c *fiber.Ctx, err error) error {
+	if errdefs.IsNotFound(err) {
+		return SendErrorMessage(c, 404, "Container or path not found")
+	}
+	return SendError(c, 500, err)
+}
+
+// listContainerFiles handles GET /api/containers/:id/files?path=/etc
+// Lists a directory inside the container with the shared list parameters,
+// without running commands in it, so it works for minimal images too
+func (p *DockerPlugin) listContainerFiles(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
+	containerID := c.Params("id")
+	ctx, cancel := RequestContext(c)
+	defer cancel()
+
+	stat, dirPath, err := statContainerPath(ctx, cli, containerID, containerPath(c.Query("path", "/")))
+	if err != nil {
+		return sendContainerPathError(c, err)
+	}
+	if !stat.Mode.IsDir() {
+		return SendErrorMessage(c, 400, "Path is not a directory")
+	}
+
+	reader, _, err := cli.CopyFromContainer(ctx, containerID, dirPath)
+	if err != nil {
+		return sendContainerPathError(c, err)
+	}
+	defer reader.Close()
+
+	// Entries are named after the directory, e.g. etc/hosts for /etc
+	var (
+		items     = []FileItem{}
+		root      string
+		first     = true
+		truncated bool
+		scanned   = &io.LimitedReader{R: reader, N: containerListScanLimit}
+	)
+	tr := tar.NewReader(scanned)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			if scanned.N <= 0 {
+				truncated = true
+			} else if err != io.EOF {
+				return SendError(c, 500, err)
+			}
+			break
+		}
+
+		name := strings.Trim(hdr.Name, "/")
+		if first {
+			root, first = name, false
+			continue
+		}
+		rel := strings.TrimPrefix(name, "./")
+		if root != "" && root != "." {
+			if !strings.HasPrefix(name, root+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(name, root+"/")
+		}
+		if rel == "" || strings.Contains(rel, "/") {
+			continue
+		}
+
+		item := FileItem{
+			Name:     rel,
+			Path:     path.Join(dirPath, rel),
+			IsDir:    hdr.Typeflag == tar.TypeDir,
+			Size:     hdr.Size,
+			Modified: hdr.ModTime,
+		}
+		if hdr.Typeflag == tar.TypeSymlink {
+			item.IsSymlink = true
+			item.Target = hdr.Linkname
+		}
+		items = append(items, item)
+	}
+
+	parent := path.Dir(dirPath)
+	if parent == dirPath {
+		parent = ""
+	}
+
+	items, meta, err := pageList(c, items, fileListSpec)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+
+	return SendList(c, ContainerDirectoryListing{
+		DirectoryListing: DirectoryListing{Path: dirPath, Parent: parent, Items: items},
+		Truncated:        truncated,
+	}, meta)
+}
+
+// tarEntryReader reads the first file of a tar stream and closes the stream
+type tarEntryReader struct {
+	*tar.Reader
+	stream io.Closer
+}
+
+func (r *tarEntryReader) Close() error {
+	return r.stream.Close()
+}
+
+// downloadContainerFile handles GET /api/containers/:id/files/download?path=/etc/hosts
+// Sends a file as is and a directory as a tar archive
+func (p *DockerPlugin) downloadContainerFile(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
+	containerID := c.Params("id")
+	pathParam := c.Query("path")
+	if pathParam == "" {
+		return SendErrorMessage(c, 400, "File path required")
+	}
+
+	ctx, cancel := RequestContext(c)
+	stat, filePath, err := statContainerPath(ctx, cli, containerID, containerPath(pathParam))
+	cancel()
+	if err != nil {
+		return sendContainerPathError(c, err)
+	}
+	if !stat.Mode.IsDir() && !stat.Mode.IsRegular() {
+		return SendErrorMessage(c, 400, "Path is neither a file nor a directory")
+	}
+
+	// The stream outlives the handler and ends when the body is sent
+	reader, _, err := cli.CopyFromContainer(context.Background(), containerID, filePath)
+	if err != nil {
+		return sendContainerPathError(c, err)
+	}
+
+	name := path.Base(filePath)
+	if stat.Mode.IsDir() {
+		if name == "/" {
+			name = "root"
+		}
+		c.Set("Content-Type", "application/x-tar")
+		c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".tar"))
+		c.Context().SetBodyStream(reader, -1)
+		return nil
+	}
+
+	tr := tar.NewReader(reader)
+	hdr, err := tr.Next()
+	if err != nil {
+		reader.Close()
+		return SendError(c, 500, err)
+	}
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	c.Set("Content-Type", "application/octet-stream")
+	if ext := path.Ext(name); ext != "" {
+		c.Type(ext)
+	}
+	c.Context().SetBodyStream(&tarEntryReader{Reader: tr, stream: reader}, int(hdr.Size))
+	return nil
+}
+
+// uploadContainerFile handles POST /api/containers/:id/files/upload
+// Copies the file of the form into the directory path inside the container;
+// existing files are only replaced when the form sets overwrite=true
+func (p *DockerPlugin) uploadContainerFile(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
+	containerID := c.Params("id")
+	destPath := c.FormValue("path")
+	if destPath == "" {
+		return SendErrorMessage(c, 400, "Destination path required")
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return SendErrorMessage(c, 400, "No file provided")
+	}
+	filename := path.Base(file.Filename)
+	if filename == "" || filename == "." || filename == ".." || filename == "/" {
+		return SendErrorMessage(c, 400, "Invalid filename")
+	}
+
+	overwrite := false
+	if value := c.FormValue("overwrite"); value != "" {
+		if overwrite, err = strconv.ParseBool(value); err != nil {
+			return SendErrorMessage(c, 400, "Invalid overwrite flag")
+		}
+	}
+
+	ctx, cancel := RequestContext(c)
+	defer cancel()
+
+	stat, dirPath, err := statContainerPath(ctx, cli, containerID, containerPath(destPath))
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return SendErrorMessage(c, 400, "Destination path does not exist")
+		}
+		return SendError(c, 500, err)
+	}
+	if !stat.Mode.IsDir() {
+		return SendErrorMessage(c, 400, "Destination path is not a directory")
+	}
+
+	destFile := path.Join(dirPath, filename)
+	existing, err := cli.ContainerStatPath(ctx, containerID, destFile)
+	switch {
+	case err == nil && existing.Mode.IsDir():
+		return SendErrorMessage(c, 409, fmt.Sprintf("%s is a directory", filename))
+	case err == nil && !overwrite:
+		return SendErrorMessage(c, 409, fmt.Sprintf("%s already exists (set overwrite to replace it)", filename))
+	case err != nil && !errdefs.IsNotFound(err):
+		return SendError(c, 500, err)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	defer src.Close()
+
+	// The daemon takes a tar archive and unpacks it in the destination directory
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     filename,
+			Mode:     0644,
+			Size:     file.Size,
+			ModTime:  time.Now(),
+		})
+		if err == nil {
+			_, err = io.Copy(tw, src)
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	startTime := time.Now()
+	err = cli.CopyToContainer(ctx, containerID, dirPath, pr, container.CopyToContainerOptions{})
+	pr.CloseWithError(errors.New("upload ended"))
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Failed to copy file into container",
+			"container", containerID, "destination", destFile, "error", err)
+		return SendError(c, 500, err)
+	}
+
+	slog.InfoContext(c.UserContext(), "File copied into container",
+		"container", containerID,
+		"destination", destFile,
+		"size", file.Size,
+		"overwrite", overwrite,
+		"duration", time.Since(startTime))
+	return SendSuccess(c, fiber.Map{"path": destFile, "size": file.Size}, "File uploaded successfully")
+}
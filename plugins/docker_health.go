@@ -0,0 +1,127 @@
+package plugins
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/gofiber/fiber/v2"
+)
+
+// HealthcheckRequest describes a container healthcheck
+// Durations are in seconds; zero inherits the image default
+type HealthcheckRequest struct {
+	Test        []string `json:"test"`    // e.g. ["CMD", "curl", "-f", "http://localhost"]
+	Command     string   `json:"command"` // shorthand for ["CMD-SHELL", command]
+	Interval    int      `json:"interval"`
+	Timeout     int      `json:"timeout"`
+	StartPeriod int      `json:"start_period"`
+	Retries     int      `json:"retries"`
+	Disable     bool     `json:"disable"`
+}
+
+// HealthcheckProbe is a single healthcheck probe result
+type HealthcheckProbe struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration string    `json:"duration"`
+	ExitCode int       `json:"exit_code"`
+	Output   string    `json:"output"`
+}
+
+// toHealthConfig validates the request and converts it to a Docker healthcheck
+func (r *HealthcheckRequest) toHealthConfig() (*container.HealthConfig, error) {
+	if r.Disable {
+		return &container.HealthConfig{Test: []string{"NONE"}}, nil
+	}
+
+	test := r.Test
+	if r.Command != "" {
+		if len(test) > 0 {
+			return nil, fmt.Errorf("healthcheck accepts either test or command, not both")
+		}
+		test = []string{"CMD-SHELL", r.Command}
+	}
+	if len(test) > 0 && test[0] != "CMD" && test[0] != "CMD-SHELL" && test[0] != "NONE" {
+		return nil, fmt.Errorf("healthcheck test must start with CMD, CMD-SHELL or NONE")
+	}
+	if r.Interval < 0 || r.Timeout < 0 || r.StartPeriod < 0 || r.Retries < 0 {
+		return nil, fmt.Errorf("healthcheck durations and retries must not be negative")
+	}
+
+	return &container.HealthConfig{
+		Test:        test,
+		Interval:    time.Duration(r.Interval) * time.Second,
+		Timeout:     time.Duration(r.Timeout) * time.Second,
+		StartPeriod: time.Duration(r.StartPeriod) * time.Second,
+		Retries:     r.Retries,
+	}, nil
+}
+
+// healthFromStatus extracts the health state from a container status line
+// The list endpoint only reports health as part of e.g. "Up 5 minutes (healthy)"
+func healthFromStatus(status string) string {
+	switch {
+	case strings.Contains(status, "(healthy)"):
+		return types.Healthy
+	case strings.Contains(status, "(unhealthy)"):
+		return types.Unhealthy
+	case strings.Contains(status, "(health: starting)"):
+		return types.Starting
+	default:
+		return types.NoHealthcheck
+	}
+}
+
+// getContainerHealth handles GET /api/containers/:id/health?limit=N
+// Returns the healthcheck definition, current state and the last N probe results (newest first)
+// The daemon only retains the five most recent probes
+func (p *DockerPlugin) getContainerHealth(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
+	containerID := c.Params("id")
+	limit := c.QueryInt("limit", 5)
+	if limit <= 0 {
+		return SendErrorMessage(c, 400, "limit must be greater than 0")
+	}
+
+	ctx, cancel := RequestContext(c)
+	defer cancel()
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+
+	status := types.NoHealthcheck
+	failingStreak := 0
+	probes := []HealthcheckProbe{}
+	if info.State != nil && info.State.Health != nil {
+		health := info.State.Health
+		status = health.Status
+		failingStreak = health.FailingStreak
+		for i := len(health.Log) - 1; i >= 0 && len(probes) < limit; i-- {
+			result := health.Log[i]
+			probes = append(probes, HealthcheckProbe{
+				Start:    result.Start,
+				End:      result.End,
+				Duration: result.End.Sub(result.Start).String(),
+				ExitCode: result.ExitCode,
+				Output:   result.Output,
+			})
+		}
+	}
+
+	var healthcheck *container.HealthConfig
+	if info.Config != nil {
+		healthcheck = info.Config.Healthcheck
+	}
+
+	return SendSuccess(c, fiber.Map{
+		"id":             info.ID,
+		"status":         status,
+		"failing_streak": failingStreak,
+		"healthcheck":    healthcheck,
+		"probes":         probes,
+	}, "")
+}
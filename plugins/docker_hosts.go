@@ -0,0 +1,243 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/gofiber/fiber/v2"
+)
+
+// LocalDockerHost names the daemon at docker.socket
+const LocalDockerHost = "local"
+
+// dockerHostName limits host names to what is safe in query strings and logs
+var dockerHostName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// dockerClientLocal is the fiber.Ctx local holding the client a request selected
+const dockerClientLocal = "dockerClient"
+
+// DockerTLSConfig holds the certificates for tcp:// endpoints protected with TLS
+type DockerTLSConfig struct {
+	CACert string `yaml:"ca_cert"` // CA of the daemon's certificate; system CAs when empty
+	Cert   string `yaml:"cert"`    // client certificate
+	Key    string `yaml:"key"`     // client key
+}
+
+// enabled reports whether any TLS setting is made
+func (cfg DockerTLSConfig) enabled() bool {
+	return cfg.CACert != "" || cfg.Cert != "" || cfg.Key != ""
+}
+
+// DockerHostConfig names a further Docker daemon, e.g. a second compute box at the site
+type DockerHostConfig struct {
+	Name   string          `yaml:"name"`
+	Socket string          `yaml:"socket"` // unix://, tcp://host:2376 or ssh://user@host
+	TLS    DockerTLSConfig `yaml:"tls"`
+}
+
+// validateDockerEndpoint checks a socket URL and its TLS settings
+func validateDockerEndpoint(socket string, tls DockerTLSConfig) error {
+	u, err := url.Parse(socket)
+	if err != nil {
+		return fmt.Errorf("invalid socket %q: %w", socket, err)
+	}
+	switch u.Scheme {
+	case "unix", "npipe":
+	case "tcp":
+		if u.Host == "" {
+			return fmt.Errorf("socket %q has no host", socket)
+		}
+	case "ssh":
+		if u.Hostname() == "" || strings.HasPrefix(u.Hostname(), "-") {
+			return fmt.Errorf("socket %q has no valid host", socket)
+		}
+		if u.Path != "" && u.Path != "/" {
+			return fmt.Errorf("socket %q: ssh endpoints take no path", socket)
+		}
+	default:
+		return fmt.Errorf("socket %q must use unix://, tcp:// or ssh://", socket)
+	}
+	if tls.enabled() {
+		if u.Scheme != "tcp" {
+			return fmt.Errorf("socket %q: tls only applies to tcp:// endpoints", socket)
+		}
+		if (tls.Cert == "") != (tls.Key == "") {
+			return fmt.Errorf("socket %q: tls needs both cert and key", socket)
+		}
+	}
+	return nil
+}
+
+// validateDockerHosts checks the endpoints of the configured hosts
+func (cfg DockerConfig) validateDockerHosts() error {
+	if cfg.Socket != "" {
+		if err := validateDockerEndpoint(cfg.Socket, cfg.TLS); err != nil {
+			return fmt.Errorf("docker.socket: %w", err)
+		}
+	}
+	seen := map[string]bool{LocalDockerHost: true}
+	for i, host := range cfg.Hosts {
+		if !dockerHostName.MatchString(host.Name) {
+			return fmt.Errorf("docker.hosts[%d]: name %q must be lowercase letters, digits, - or _", i, host.Name)
+		}
+		if seen[host.Name] {
+			return fmt.Errorf("docker.hosts[%d]: name %q is used twice or reserved", i, host.Name)
+		}
+		seen[host.Name] = true
+		if err := validateDockerEndpoint(host.Socket, host.TLS); err != nil {
+			return fmt.Errorf("docker.hosts[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// newDockerClient creates a client for a unix, tcp or ssh endpoint
+// ssh endpoints run `docker system dial-stdio` on the remote host through the
+// ssh client, which must be able to log in without a prompt (keys, known_hosts).
+func newDockerClient(socket string, tls DockerTLSConfig) (*client.Client, error) {
+	opts := []client.Opt{client.WithAPIVersionNegotiation()}
+	u, err := url.Parse(socket)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "ssh":
+		opts = append(opts,
+			client.WithHost("http://"+u.Hostname()),
+			client.WithDialContext(sshDialer(u)))
+	case "tcp":
+		opts = append(opts, client.WithHost(socket))
+		if tls.enabled() {
+			opts = append(opts, client.WithTLSClientConfig(tls.CACert, tls.Cert, tls.Key))
+		}
+	default:
+		opts = append(opts, client.WithHost(socket))
+	}
+	return client.NewClientWithOpts(opts...)
+}
+
+// sshDialer returns a dialer that tunnels each connection through ssh
+func sshDialer(u *url.URL) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	args := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=10"}
+	if u.Port() != "" {
+		args = append(args, "-p", u.Port())
+	}
+	destination := u.Hostname()
+	if u.User != nil {
+		destination = u.User.Username() + "@" + destination
+	}
+	args = append(args, "--", destination, "docker", "system", "dial-stdio")
+
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		// The connection outlives the dial context; it is ended by Close
+		return newCommandConn(exec.Command("ssh", args...), u.Host)
+	}
+}
+
+// commandConn is a connection over the stdin and stdout of a command
+type commandConn struct {
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	stdout    io.ReadCloser
+	stderr    *limitedBuffer
+	remote    string
+	closeOnce sync.Once
+}
+
+// newCommandConn starts cmd and connects to its stdin and stdout
+func newCommandConn(cmd *exec.Cmd, remote string) (*commandConn, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := &limitedBuffer{max: 4096}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ssh: %w", err)
+	}
+	return &commandConn{cmd: cmd, stdin: stdin, stdout: stdout, stderr: stderr, remote: remote}, nil
+}
+
+func (c *commandConn) Read(p []byte) (int, error) {
+	n, err := c.stdout.Read(p)
+	if err == io.EOF {
+		// ssh reports refused logins and missing docker on stderr
+		if message := strings.TrimSpace(c.stderr.String()); message != "" {
+			return n, fmt.Errorf("ssh %s: %s", c.remote, message)
+		}
+	}
+	return n, err
+}
+
+func (c *commandConn) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+// Close ends the command
+func (c *commandConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.stdin.Close()
+		c.cmd.Process.Kill()
+		c.cmd.Wait()
+	})
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr  { return commandAddr("ssh") }
+func (c *commandConn) RemoteAddr() net.Addr { return commandAddr(c.remote) }
+
+// Deadlines are not supported by pipes; requests are bounded by their contexts
+func (c *commandConn) SetDeadline(time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(time.Time) error { return nil }
+
+// commandAddr is the address of a command connection
+type commandAddr string
+
+func (a commandAddr) Network() string { return "ssh" }
+func (a commandAddr) String() string  { return string(a) }
+
+// limitedBuffer keeps the first max bytes written to it
+type limitedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if room := b.max - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// selectedDockerClient returns the client of the host a request selected with
+// ?host=, or fallback when RequireDocker did not run
+func selectedDockerClient(c *fiber.Ctx, fallback *client.Client) *client.Client {
+	if cli, ok := c.Locals(dockerClientLocal).(*client.Client); ok {
+		return cli
+	}
+	return fallback
+}
@@ -0,0 +1,127 @@
+package plugins
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ImageLayer is one step of an image's history
+type ImageLayer struct {
+	ID        string    `json:"id,omitempty"` // set for steps that are tagged or pulled images
+	Created   time.Time `json:"created"`
+	CreatedBy string    `json:"created_by"` // the Dockerfile instruction
+	Size      int64     `json:"size"`
+	Percent   float64   `json:"percent"` // share of the image size
+	Empty     bool      `json:"empty"`   // the step only changed metadata, e.g. ENV or CMD
+	Tags      []string  `json:"tags,omitempty"`
+	Comment   string    `json:"comment,omitempty"`
+}
+
+// ImageDetails is the inspect data of an image with its history
+type ImageDetails struct {
+	ID           string            `json:"id"`
+	Tags         []string          `json:"tags"`
+	Digests      []string          `json:"digests,omitempty"`
+	Created      string            `json:"created"`
+	Size         int64             `json:"size"`
+	Architecture string            `json:"architecture"`
+	OS           string            `json:"os"`
+	Variant      string            `json:"variant,omitempty"`
+	Author       string            `json:"author,omitempty"`
+	Entrypoint   []string          `json:"entrypoint,omitempty"`
+	Cmd          []string          `json:"cmd,omitempty"`
+	WorkingDir   string            `json:"working_dir,omitempty"`
+	User         string            `json:"user,omitempty"`
+	Env          []string          `json:"env,omitempty"` // names only; values may hold secrets
+	ExposedPorts []string          `json:"exposed_ports,omitempty"`
+	Volumes      []string          `json:"volumes,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Layers       []string          `json:"layers"`  // digests of the filesystem layers, base first
+	History      []ImageLayer      `json:"history"` // newest step first, like docker history
+}
+
+// getImage handles GET /api/images/:id
+// Returns the inspect data with the history of the image, so the steps
+// that make an image large can be found
+func (p *DockerPlugin) getImage(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
+	imageID := c.Params("id")
+	ctx, cancel := RequestContext(c)
+	defer cancel()
+
+	inspect, _, err := cli.ImageInspectWithRaw(ctx, imageID)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return SendErrorMessage(c, 404, "Image not found")
+		}
+		return SendError(c, 500, err)
+	}
+	history, err := cli.ImageHistory(ctx, inspect.ID)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+
+	details := ImageDetails{
+		ID:           inspect.ID,
+		Tags:         inspect.RepoTags,
+		Digests:      inspect.RepoDigests,
+		Created:      inspect.Created,
+		Size:         inspect.Size,
+		Architecture: inspect.Architecture,
+		OS:           inspect.Os,
+		Variant:      inspect.Variant,
+		Author:       inspect.Author,
+		Layers:       inspect.RootFS.Layers,
+		History:      make([]ImageLayer, len(history)),
+	}
+	if len(details.Tags) == 0 {
+		details.Tags = []string{"<none>"}
+	}
+	if details.Layers == nil {
+		details.Layers = []string{}
+	}
+	if cfg := inspect.Config; cfg != nil {
+		details.Entrypoint = cfg.Entrypoint
+		details.Cmd = cfg.Cmd
+		details.WorkingDir = cfg.WorkingDir
+		details.User = cfg.User
+		details.Labels = cfg.Labels
+		for _, env := range cfg.Env {
+			name, _, _ := strings.Cut(env, "=")
+			details.Env = append(details.Env, name)
+		}
+		for port := range cfg.ExposedPorts {
+			details.ExposedPorts = append(details.ExposedPorts, string(port))
+		}
+		sort.Strings(details.ExposedPorts)
+		for volume := range cfg.Volumes {
+			details.Volumes = append(details.Volumes, volume)
+		}
+		sort.Strings(details.Volumes)
+	}
+
+	for i, step := range history {
+		layer := ImageLayer{
+			Created:   time.Unix(step.Created, 0),
+			CreatedBy: step.CreatedBy,
+			Size:      step.Size,
+			Empty:     step.Size == 0,
+			Tags:      step.Tags,
+			Comment:   step.Comment,
+		}
+		// Steps of base images built elsewhere are reported as <missing>
+		if step.ID != "<missing>" {
+			layer.ID = step.ID
+		}
+		if inspect.Size > 0 {
+			layer.Percent = float64(step.Size) * 100 / float64(inspect.Size)
+		}
+		details.History[i] = layer
+	}
+
+	return SendSuccess(c, details, "")
+}
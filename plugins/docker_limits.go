@@ -0,0 +1,130 @@
+package plugins
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-units"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ContainerLimits describes container resource limits
+// Memory sizes accept units ("256m", "1g") or bytes. Unset fields inherit
+// docker.default_limits; "-1" (memory) and -1 (cpus, pids_limit) remove a default.
+type ContainerLimits struct {
+	Memory            string  `yaml:"memory" json:"memory"`
+	MemoryReservation string  `yaml:"memory_reservation" json:"memory_reservation"` // soft limit under memory pressure
+	MemorySwap        string  `yaml:"memory_swap" json:"memory_swap"`               // memory plus swap, "-1" for unlimited swap
+	CPUs              float64 `yaml:"cpus" json:"cpus"`                             // e.g. 0.5 for half a core
+	CPUShares         int64   `yaml:"cpu_shares" json:"cpu_shares"`                 // relative weight, Docker default 1024
+	CPUQuota          int64   `yaml:"cpu_quota" json:"cpu_quota"`                   // microseconds per cpu_period
+	CPUPeriod         int64   `yaml:"cpu_period" json:"cpu_period"`                 // microseconds
+	PidsLimit         int64   `yaml:"pids_limit" json:"pids_limit"`
+}
+
+// withDefaults fills unset limits from the configured defaults
+func (l ContainerLimits) withDefaults(defaults ContainerLimits) ContainerLimits {
+	if l.Memory == "" {
+		l.Memory = defaults.Memory
+	}
+	if l.MemoryReservation == "" {
+		l.MemoryReservation = defaults.MemoryReservation
+	}
+	if l.MemorySwap == "" {
+		l.MemorySwap = defaults.MemorySwap
+	}
+	// CPUs and an explicit quota are mutually exclusive, so a requested quota replaces a default cpus
+	if l.CPUs == 0 && l.CPUQuota == 0 && l.CPUPeriod == 0 {
+		l.CPUs = defaults.CPUs
+		l.CPUQuota = defaults.CPUQuota
+		l.CPUPeriod = defaults.CPUPeriod
+	}
+	if l.CPUShares == 0 {
+		l.CPUShares = defaults.CPUShares
+	}
+	if l.PidsLimit == 0 {
+		l.PidsLimit = defaults.PidsLimit
+	}
+	return l
+}
+
+// toResources validates the limits and converts them to Docker resources
+func (l ContainerLimits) toResources() (container.Resources, error) {
+	var resources container.Resources
+
+	memory, err := parseMemoryLimit("memory", l.Memory)
+	if err != nil {
+		return resources, err
+	}
+	reservation, err := parseMemoryLimit("memory_reservation", l.MemoryReservation)
+	if err != nil {
+		return resources, err
+	}
+	swap := int64(0)
+	if l.MemorySwap == "-1" {
+		swap = -1
+	} else if swap, err = parseMemoryLimit("memory_swap", l.MemorySwap); err != nil {
+		return resources, err
+	}
+	if swap > 0 && (memory == 0 || swap < memory) {
+		return resources, fmt.Errorf("memory_swap must be at least memory and requires a memory limit")
+	}
+	if reservation > 0 && memory > 0 && reservation > memory {
+		return resources, fmt.Errorf("memory_reservation must not exceed memory")
+	}
+
+	if l.CPUs > 0 && (l.CPUQuota != 0 || l.CPUPeriod != 0) {
+		return resources, fmt.Errorf("limits accept either cpus or cpu_quota/cpu_period, not both")
+	}
+	if l.CPUShares < 0 || l.CPUQuota < 0 || l.CPUPeriod < 0 {
+		return resources, fmt.Errorf("cpu_shares, cpu_quota and cpu_period must not be negative")
+	}
+	if l.PidsLimit < -1 {
+		return resources, fmt.Errorf("pids_limit must be positive or -1 for unlimited")
+	}
+
+	resources.Memory = memory
+	resources.MemoryReservation = reservation
+	resources.MemorySwap = swap
+	if l.CPUs > 0 {
+		resources.NanoCPUs = int64(l.CPUs * 1e9)
+	}
+	resources.CPUShares = l.CPUShares
+	resources.CPUQuota = l.CPUQuota
+	resources.CPUPeriod = l.CPUPeriod
+	if l.PidsLimit != 0 {
+		pidsLimit := l.PidsLimit
+		resources.PidsLimit = &pidsLimit
+	}
+	return resources, nil
+}
+
+// parseMemoryLimit parses a memory size; empty and "-1" mean unlimited
+func parseMemoryLimit(field string, value string) (int64, error) {
+	if value == "" || value == "-1" {
+		return 0, nil
+	}
+	size, err := units.RAMInBytes(value)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid %s %q", field, value)
+	}
+	return size, nil
+}
+
+// containerLimits reports the limits of a container; zero values are unlimited
+func containerLimits(resources container.Resources) fiber.Map {
+	pidsLimit := int64(0)
+	if resources.PidsLimit != nil && *resources.PidsLimit > 0 {
+		pidsLimit = *resources.PidsLimit
+	}
+	return fiber.Map{
+		"memory":             resources.Memory,
+		"memory_reservation": resources.MemoryReservation,
+		"memory_swap":        resources.MemorySwap,
+		"cpus":               float64(resources.NanoCPUs) / 1e9,
+		"cpu_shares":         resources.CPUShares,
+		"cpu_quota":          resources.CPUQuota,
+		"cpu_period":         resources.CPUPeriod,
+		"pids_limit":         pidsLimit,
+	}
+}
@@ -0,0 +1,439 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Managed container defaults
+const (
+	DefaultManagedFile  = "/var/lib/linht/managed-containers.json"
+	DefaultBootTimeout  = 120 // seconds
+	managedPollInterval = 2 * time.Second
+)
+
+// Managed container start triggers and events
+const (
+	managedTriggerBoot      = "boot"
+	managedTriggerManual    = "manual"
+	managedCompletedEvent   = "docker.managed.completed"
+	managedFailedEvent      = "docker.managed.failed"
+	managedStatePending     = "pending"
+	managedStateStarting    = "starting"
+	managedStateWaiting     = "waiting"
+	managedStateRunning     = "running"
+	managedStateHealthy     = "healthy"
+	managedStateFailed      = "failed"
+	managedStateSkipped     = "skipped"
+	managedStateInterrupted = "interrupted"
+)
+
+// ManagedContainer marks a container to be started by the manager after boot
+// Containers are referenced by name, which survives re-creation
+type ManagedContainer struct {
+	Name        string `json:"name"`
+	Order       int    `json:"order"`        // lower starts first, ties by name
+	WaitHealthy bool   `json:"wait_healthy"` // later containers wait until this one is healthy (or running without a healthcheck)
+}
+
+// ManagedContainerStatus is the outcome of starting one managed container
+type ManagedContainerStatus struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	Error string `json:"error,omitempty"`
+}
+
+// ManagedRun describes the most recent start sequence
+type ManagedRun struct {
+	Running    bool                     `json:"running"`
+	Trigger    string                   `json:"trigger,omitempty"`
+	StartedAt  *time.Time               `json:"started_at,omitempty"`
+	FinishedAt *time.Time               `json:"finished_at,omitempty"`
+	Containers []ManagedContainerStatus `json:"containers"`
+}
+
+// managedContainers holds the persisted list and the start sequence state
+type managedContainers struct {
+	file    string
+	timeout time.Duration
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	entries map[string]ManagedContainer
+	run     ManagedRun
+}
+
+// loadManagedContainers reads the managed container list; a missing file is an empty list
+func loadManagedContainers(file string, timeout time.Duration) (*managedContainers, error) {
+	m := &managedContainers{
+		file:    file,
+		timeout: timeout,
+		entries: make(map[string]ManagedContainer),
+		run:     ManagedRun{Containers: []ManagedContainerStatus{}},
+	}
+
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read managed containers: %w", err)
+	}
+	var entries []ManagedContainer
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid managed containers file %s: %w", file, err)
+	}
+	for _, entry := range entries {
+		m.entries[entry.Name] = entry
+	}
+	return m, nil
+}
+
+// sorted returns the entries in start order
+func (m *managedContainers) sorted() []ManagedContainer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := make([]ManagedContainer, 0, len(m.entries))
+	for _, entry := range m.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Order != entries[j].Order {
+			return entries[i].Order < entries[j].Order
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// get returns the entry of a container
+func (m *managedContainers) get(name string) (ManagedContainer, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[name]
+	return entry, ok
+}
+
+// update adds, replaces or (with remove) deletes an entry and persists the list
+func (m *managedContainers) update(entry ManagedContainer, remove bool) error {
+	m.mu.Lock()
+	previous, existed := m.entries[entry.Name]
+	if remove {
+		delete(m.entries, entry.Name)
+	} else {
+		m.entries[entry.Name] = entry
+	}
+	m.mu.Unlock()
+
+	if err := m.save(); err != nil {
+		// Keep memory and file consistent
+		m.mu.Lock()
+		if existed {
+			m.entries[entry.Name] = previous
+		} else {
+			delete(m.entries, entry.Name)
+		}
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// save writes the list atomically
+func (m *managedContainers) save() error {
+	data, _ := json.MarshalIndent(m.sorted(), "", "  ")
+	if err := os.MkdirAll(filepath.Dir(m.file), 0755); err != nil {
+		return fmt.Errorf("failed to persist managed containers: %w", err)
+	}
+	tmp := m.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to persist managed containers: %w", err)
+	}
+	if err := os.Rename(tmp, m.file); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to persist managed containers: %w", err)
+	}
+	return nil
+}
+
+// getRun returns a copy of the current start sequence state
+func (m *managedContainers) getRun() ManagedRun {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run := m.run
+	run.Containers = append([]ManagedContainerStatus(nil), m.run.Containers...)
+	return run
+}
+
+// setState updates the state of a container in the running sequence
+func (m *managedContainers) setState(index int, state string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.run.Containers[index].State = state
+	if err != nil {
+		m.run.Containers[index].Error = err.Error()
+	}
+}
+
+// containerName returns the primary name of a container without the leading slash
+func containerName(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(names[0], "/")
+}
+
+// startManaged starts the managed containers in the background
+// Returns false when a start sequence is already running
+func (p *DockerPlugin) startManaged(trigger string) bool {
+	entries := p.managed.sorted()
+
+	m := p.managed
+	m.mu.Lock()
+	if m.run.Running {
+		m.mu.Unlock()
+		return false
+	}
+	if len(entries) == 0 {
+		m.mu.Unlock()
+		return true
+	}
+	now := time.Now()
+	m.run = ManagedRun{Running: true, Trigger: trigger, StartedAt: &now}
+	for _, entry := range entries {
+		m.run.Containers = append(m.run.Containers, ManagedContainerStatus{Name: entry.Name, State: managedStatePending})
+	}
+	m.wg.Add(1)
+	m.mu.Unlock()
+
+	go func() {
+		defer m.wg.Done()
+		p.runManagedSequence(entries, trigger)
+	}()
+	return true
+}
+
+// runManagedSequence starts each container in order, waiting on gated ones
+// A gated container that does not come up stops the sequence
+func (p *DockerPlugin) runManagedSequence(entries []ManagedContainer, trigger string) {
+	m := p.managed
+	slog.Info("Starting managed containers", "trigger", trigger, "count", len(entries))
+
+	// After boot the daemon may still be starting
+	if err := p.waitForDaemon(); err != nil {
+		for i := range entries {
+			m.setState(i, managedStateFailed, err)
+		}
+		p.finishManaged(trigger, &ManagedContainerStatus{Name: entries[0].Name, State: managedStateFailed, Error: err.Error()})
+		return
+	}
+
+	var failed *ManagedContainerStatus
+	for i, entry := range entries {
+		select {
+		case <-p.stopChan:
+			for j := i; j < len(entries); j++ {
+				m.setState(j, managedStateInterrupted, nil)
+			}
+			p.finishManaged(trigger, nil)
+			return
+		default:
+		}
+
+		state, err := p.startManagedContainer(i, entry)
+		m.setState(i, state, err)
+		if err == nil {
+			continue
+		}
+
+		slog.Warn("Managed container failed to start", "container", entry.Name, "error", err)
+		if entry.WaitHealthy {
+			failed = &ManagedContainerStatus{Name: entry.Name, State: state, Error: err.Error()}
+			for j := i + 1; j < len(entries); j++ {
+				m.setState(j, managedStateSkipped, nil)
+			}
+			break
+		}
+	}
+	p.finishManaged(trigger, failed)
+}
+
+// finishManaged records the end of a start sequence and publishes the outcome
+func (p *DockerPlugin) finishManaged(trigger string, failed *ManagedContainerStatus) {
+	m := p.managed
+	m.mu.Lock()
+	now := time.Now()
+	m.run.Running = false
+	m.run.FinishedAt = &now
+	m.mu.Unlock()
+
+	run := p.managed.getRun()
+	if failed != nil {
+		PublishEvent(managedFailedEvent, dockerEventSource, fiber.Map{
+			"trigger":    trigger,
+			"container":  failed.Name,
+			"error":      failed.Error,
+			"containers": run.Containers,
+		})
+		return
+	}
+	PublishEvent(managedCompletedEvent, dockerEventSource, fiber.Map{
+		"trigger":    trigger,
+		"containers": run.Containers,
+	})
+	slog.Info("Managed containers started", "trigger", trigger)
+}
+
+// waitForDaemon waits up to the boot timeout until the Docker daemon answers
+func (p *DockerPlugin) waitForDaemon() error {
+	deadline := time.Now().Add(p.managed.timeout)
+	for {
+		_, err := p.client.Ping(context.Background())
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("docker daemon not reachable: %w", err)
+		}
+		select {
+		case <-p.stopChan:
+			return errors.New("interrupted by shutdown")
+		case <-time.After(managedPollInterval):
+		}
+	}
+}
+
+// startManagedContainer starts one container and, if gated, waits until it is up
+// Returns the final state of the container
+func (p *DockerPlugin) startManagedContainer(index int, entry ManagedContainer) (string, error) {
+	ctx := context.Background()
+	p.managed.setState(index, managedStateStarting, nil)
+
+	inspect, err := p.client.ContainerInspect(ctx, entry.Name)
+	if err != nil {
+		return managedStateFailed, err
+	}
+	if !inspect.State.Running {
+		if err := p.client.ContainerStart(ctx, inspect.ID, container.StartOptions{}); err != nil {
+			return managedStateFailed, err
+		}
+	}
+	if !entry.WaitHealthy {
+		return managedStateRunning, nil
+	}
+
+	p.managed.setState(index, managedStateWaiting, nil)
+	deadline := time.Now().Add(p.managed.timeout)
+	ticker := time.NewTicker(managedPollInterval)
+	defer ticker.Stop()
+	for {
+		inspect, err := p.client.ContainerInspect(ctx, inspect.ID)
+		if err != nil {
+			return managedStateFailed, err
+		}
+		switch {
+		case !inspect.State.Running:
+			return managedStateFailed, fmt.Errorf("container exited with code %d", inspect.State.ExitCode)
+		case inspect.State.Health == nil:
+			return managedStateRunning, nil
+		case inspect.State.Health.Status == types.Healthy:
+			return managedStateHealthy, nil
+		case inspect.State.Health.Status == types.Unhealthy:
+			return managedStateFailed, errors.New("container is unhealthy")
+		}
+
+		if time.Now().After(deadline) {
+			return managedStateFailed, fmt.Errorf("not healthy within %s", p.managed.timeout)
+		}
+		select {
+		case <-p.stopChan:
+			return managedStateInterrupted, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// listManaged handles GET /api/docker/managed
+func (p *DockerPlugin) listManaged(c *fiber.Ctx) error {
+	return SendSuccess(c, fiber.Map{
+		"containers": p.managed.sorted(),
+		"last_run":   p.managed.getRun(),
+	}, "")
+}
+
+// setManaged handles PUT /api/docker/managed/:name
+// Adds the container to the start sequence or updates its order and gating
+func (p *DockerPlugin) setManaged(c *fiber.Ctx) error {
+	var req struct {
+		Order       int  `json:"order"`
+		WaitHealthy bool `json:"wait_healthy"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+
+	ctx, cancel := RequestContext(c)
+	defer cancel()
+	inspect, err := p.client.ContainerInspect(ctx, c.Params("name"))
+	if errdefs.IsNotFound(err) {
+		return SendErrorMessage(c, 404, "Container not found")
+	}
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+
+	entry := ManagedContainer{
+		Name:        strings.TrimPrefix(inspect.Name, "/"),
+		Order:       req.Order,
+		WaitHealthy: req.WaitHealthy,
+	}
+	if err := p.managed.update(entry, false); err != nil {
+		return SendError(c, 500, err)
+	}
+
+	// Docker starts these itself when the daemon comes up, ignoring the order
+	result := fiber.Map{"container": entry}
+	if inspect.HostConfig != nil {
+		switch inspect.HostConfig.RestartPolicy.Name {
+		case container.RestartPolicyAlways, container.RestartPolicyUnlessStopped:
+			result["warning"] = fmt.Sprintf("restart policy %q starts the container before the managed sequence; use \"no\" or \"on-failure\"", inspect.HostConfig.RestartPolicy.Name)
+		}
+	}
+	return SendSuccess(c, result, "Container is managed")
+}
+
+// removeManaged handles DELETE /api/docker/managed/:name
+func (p *DockerPlugin) removeManaged(c *fiber.Ctx) error {
+	name := c.Params("name")
+	if _, ok := p.managed.get(name); !ok {
+		return SendErrorMessage(c, 404, "Container is not managed")
+	}
+	if err := p.managed.update(ManagedContainer{Name: name}, true); err != nil {
+		return SendError(c, 500, err)
+	}
+	return SendSuccess(c, nil, "Container is no longer managed")
+}
+
+// runManaged handles POST /api/docker/managed/start
+// Runs the start sequence now, e.g. after changing it
+func (p *DockerPlugin) runManaged(c *fiber.Ctx) error {
+	if !p.startManaged(managedTriggerManual) {
+		return SendErrorMessage(c, 409, "Managed containers are already being started")
+	}
+	return SendSuccess(c, p.managed.getRun(), "Starting managed containers")
+}
@@ -0,0 +1,300 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Prune targets
+const (
+	PruneContainers = "containers"
+	PruneImages     = "images"
+	PruneVolumes    = "volumes"
+	PruneNetworks   = "networks"
+	PruneBuildCache = "build_cache"
+)
+
+// pruneTargets lists all targets in the order they are pruned
+// Containers go first so the images and volumes they held become unused
+var pruneTargets = []string{PruneContainers, PruneImages, PruneVolumes, PruneNetworks, PruneBuildCache}
+
+// Label set by the daemon on volumes created without a name
+const anonymousVolumeLabel = "com.docker.volume.anonymous"
+
+// PruneRequest selects what to prune
+type PruneRequest struct {
+	Targets []string `json:"targets"`
+	DryRun  bool     `json:"dry_run"`
+}
+
+// PruneResult reports what was (or would be) removed for one target
+type PruneResult struct {
+	Target         string   `json:"target"`
+	Items          []string `json:"items"`
+	SpaceReclaimed uint64   `json:"space_reclaimed"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// validate normalizes the targets, defaulting to stopped containers and dangling images
+func (r *PruneRequest) validate() error {
+	if len(r.Targets) == 0 {
+		r.Targets = []string{PruneContainers, PruneImages}
+		return nil
+	}
+
+	selected := make(map[string]bool)
+	for _, target := range r.Targets {
+		if target == "all" {
+			r.Targets = pruneTargets
+			return nil
+		}
+		valid := false
+		for _, known := range pruneTargets {
+			if target == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unknown prune target %q (valid: %s, all)", target, strings.Join(pruneTargets, ", "))
+		}
+		selected[target] = true
+	}
+
+	r.Targets = r.Targets[:0]
+	for _, target := range pruneTargets {
+		if selected[target] {
+			r.Targets = append(r.Targets, target)
+		}
+	}
+	return nil
+}
+
+// prune handles POST /api/docker/prune
+// With dry_run the reclaimable items and space are reported without removing anything
+func (p *DockerPlugin) prune(c *fiber.Ctx) error {
+	cli := selectedDockerClient(c, p.client)
+	var req PruneRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return SendErrorMessage(c, 400, "Invalid request body")
+		}
+	}
+	if c.QueryBool("dry_run") {
+		req.DryRun = true
+	}
+	if err := req.validate(); err != nil {
+		return SendError(c, 400, err)
+	}
+
+	ctx, cancel := RequestContext(c)
+	defer cancel()
+	slog.InfoContext(ctx, "Docker prune started", "targets", req.Targets, "dry_run", req.DryRun)
+
+	var usage types.DiskUsage
+	if req.DryRun {
+		var err error
+		usage, err = cli.DiskUsage(ctx, types.DiskUsageOptions{})
+		if err != nil {
+			return SendError(c, 500, err)
+		}
+	}
+
+	results := make([]PruneResult, 0, len(req.Targets))
+	var total uint64
+	failed := 0
+	for _, target := range req.Targets {
+		var result PruneResult
+		var err error
+		if req.DryRun {
+			result, err = estimatePrune(ctx, cli, target, usage)
+		} else {
+			result, err = runPrune(ctx, cli, target)
+		}
+		result.Target = target
+		if result.Items == nil {
+			result.Items = []string{}
+		}
+		if err != nil {
+			slog.ErrorContext(c.UserContext(), "Docker prune failed", "target", target, "error", err)
+			result.Error = err.Error()
+			failed++
+		}
+		total += result.SpaceReclaimed
+		results = append(results, result)
+	}
+
+	slog.InfoContext(c.UserContext(), "Docker prune completed",
+		"targets", req.Targets,
+		"dry_run", req.DryRun,
+		"space_reclaimed", total)
+
+	data := fiber.Map{
+		"dry_run":         req.DryRun,
+		"results":         results,
+		"space_reclaimed": total,
+	}
+	if failed == len(results) {
+		return c.Status(500).JSON(APIResponse{
+			Success: false,
+			Data:    data,
+			Error:   results[0].Error,
+		})
+	}
+
+	message := "Prune completed"
+	if req.DryRun {
+		message = "Dry run, nothing was removed"
+	}
+	return SendSuccess(c, data, message)
+}
+
+// runPrune removes unused objects of one target type
+func runPrune(ctx context.Context, cli *client.Client, target string) (PruneResult, error) {
+	var result PruneResult
+
+	switch target {
+	case PruneContainers:
+		report, err := cli.ContainersPrune(ctx, filters.NewArgs())
+		if err != nil {
+			return result, err
+		}
+		result.Items = report.ContainersDeleted
+		result.SpaceReclaimed = report.SpaceReclaimed
+
+	case PruneImages:
+		report, err := cli.ImagesPrune(ctx, filters.NewArgs(filters.Arg("dangling", "true")))
+		if err != nil {
+			return result, err
+		}
+		for _, deleted := range report.ImagesDeleted {
+			if deleted.Deleted != "" {
+				result.Items = append(result.Items, deleted.Deleted)
+			}
+		}
+		result.SpaceReclaimed = report.SpaceReclaimed
+
+	case PruneVolumes:
+		report, err := cli.VolumesPrune(ctx, filters.NewArgs())
+		if err != nil {
+			return result, err
+		}
+		result.Items = report.VolumesDeleted
+		result.SpaceReclaimed = report.SpaceReclaimed
+
+	case PruneNetworks:
+		report, err := cli.NetworksPrune(ctx, filters.NewArgs())
+		if err != nil {
+			return result, err
+		}
+		result.Items = report.NetworksDeleted
+
+	case PruneBuildCache:
+		report, err := cli.BuildCachePrune(ctx, types.BuildCachePruneOptions{})
+		if err != nil {
+			return result, err
+		}
+		result.Items = report.CachesDeleted
+		result.SpaceReclaimed = report.SpaceReclaimed
+	}
+
+	return result, nil
+}
+
+// estimatePrune reports what runPrune would remove, based on a disk usage snapshot
+// Sizes are estimates: image layers shared with other images are not counted
+func estimatePrune(ctx context.Context, cli *client.Client, target string, usage types.DiskUsage) (PruneResult, error) {
+	var result PruneResult
+
+	switch target {
+	case PruneContainers:
+		for _, cont := range usage.Containers {
+			if cont.State == "running" || cont.State == "paused" || cont.State == "restarting" {
+				continue
+			}
+			result.Items = append(result.Items, cont.ID)
+			result.SpaceReclaimed += uint64(cont.SizeRw)
+		}
+
+	case PruneImages:
+		for _, img := range usage.Images {
+			if !isDanglingImage(img.RepoTags) || img.Containers > 0 {
+				continue
+			}
+			result.Items = append(result.Items, img.ID)
+			size := img.Size
+			if img.SharedSize > 0 {
+				size -= img.SharedSize
+			}
+			result.SpaceReclaimed += uint64(size)
+		}
+
+	case PruneVolumes:
+		// Current daemons only prune anonymous volumes by default
+		for _, vol := range usage.Volumes {
+			if vol.UsageData == nil || vol.UsageData.RefCount != 0 {
+				continue
+			}
+			if _, ok := vol.Labels[anonymousVolumeLabel]; !ok {
+				continue
+			}
+			result.Items = append(result.Items, vol.Name)
+			if vol.UsageData.Size > 0 {
+				result.SpaceReclaimed += uint64(vol.UsageData.Size)
+			}
+		}
+
+	case PruneNetworks:
+		networks, err := cli.NetworkList(ctx, network.ListOptions{})
+		if err != nil {
+			return result, err
+		}
+		for _, summary := range networks {
+			if isPredefinedNetwork(summary.Name) {
+				continue
+			}
+			// The list endpoint does not include attached containers
+			inspect, err := cli.NetworkInspect(ctx, summary.ID, network.InspectOptions{})
+			if err != nil {
+				return result, err
+			}
+			if len(inspect.Containers) == 0 {
+				result.Items = append(result.Items, summary.Name)
+			}
+		}
+
+	case PruneBuildCache:
+		for _, cache := range usage.BuildCache {
+			if cache.InUse || cache.Shared {
+				continue
+			}
+			result.Items = append(result.Items, cache.ID)
+			result.SpaceReclaimed += uint64(cache.Size)
+		}
+	}
+
+	return result, nil
+}
+
+// isDanglingImage reports whether an image has no tags
+func isDanglingImage(tags []string) bool {
+	for _, tag := range tags {
+		if tag != "<none>:<none>" {
+			return false
+		}
+	}
+	return true
+}
+
+// isPredefinedNetwork reports whether a network is created by the daemon and never pruned
+func isPredefinedNetwork(name string) bool {
+	return name == "bridge" || name == "host" || name == "none"
+}
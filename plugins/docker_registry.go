@@ -0,0 +1,571 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Registry client limits
+const (
+	registryRequestTimeout = 20 * time.Second
+	registryMaxResponse    = 4 * 1024 * 1024 // bytes of one JSON answer
+	registryMaxEntries     = 10000           // repositories or tags read by following pages
+	registryPageSize       = 1000
+	dockerHubRegistry      = "registry-1.docker.io"
+)
+
+// Manifest media types
+const (
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
+// RegistryConfig describes a registry that can be browsed before pulling
+type RegistryConfig struct {
+	Name     string `yaml:"name"`
+	URL      string `yaml:"url"` // e.g. https://registry-1.docker.io
+	Username string `yaml:"username"`
+	Password string `yaml:"password"` // password or access token
+}
+
+// RegistryInfo describes a configured registry; credentials are never returned
+type RegistryInfo struct {
+	Name          string `json:"name"`
+	URL           string `json:"url"`
+	Authenticated bool   `json:"authenticated"`
+}
+
+// RegistryEntry is a repository or tag of a registry
+type RegistryEntry struct {
+	Name string `json:"name"`
+}
+
+// RegistryPlatform is one image of a multi-platform manifest
+type RegistryPlatform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+	Digest       string `json:"digest"`
+	Size         int64  `json:"size,omitempty"` // compressed layers, known for the single image and the device's platform
+	Matches      bool   `json:"matches"`        // runs on this device
+}
+
+// RegistryManifest describes a tag and the platforms it is available for
+type RegistryManifest struct {
+	Repository     string             `json:"repository"`
+	Reference      string             `json:"reference"`
+	Digest         string             `json:"digest"`
+	MediaType      string             `json:"media_type"`
+	MultiPlatform  bool               `json:"multi_platform"`
+	DevicePlatform string             `json:"device_platform"` // e.g. linux/arm64
+	Platforms      []RegistryPlatform `json:"platforms"`
+}
+
+// registryRepository matches repository names of the distribution spec
+var registryRepository = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+
+// registryReference matches tags and digests
+var registryReference = regexp.MustCompile(`^(?:[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}|[a-z0-9]+:[a-f0-9]{32,})$`)
+
+// registryChallengeParam matches the key="value" pairs of a WWW-Authenticate header
+var registryChallengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// registryEntrySpec names the fields for sorting and filtering repositories and tags
+var registryEntrySpec = listSpec[RegistryEntry]{
+	key: "name",
+	fields: map[string]func(RegistryEntry) interface{}{
+		"name": func(entry RegistryEntry) interface{} { return entry.Name },
+	},
+}
+
+// registryError is an error answer of a registry
+type registryError struct {
+	status  int
+	message string
+}
+
+func (e *registryError) Error() string {
+	return e.message
+}
+
+// registryToken is a bearer token for one scope
+type registryToken struct {
+	token   string
+	expires time.Time
+}
+
+// registryClient queries registries over the distribution API (v2)
+type registryClient struct {
+	http   *http.Client
+	mu     sync.Mutex
+	tokens map[string]registryToken // by registry name and scope
+}
+
+// newRegistryClient creates a registry client
+func newRegistryClient() *registryClient {
+	return &registryClient{
+		http:   &http.Client{Timeout: registryRequestTimeout},
+		tokens: make(map[string]registryToken),
+	}
+}
+
+// validateRegistries checks the configured registries
+func validateRegistries(registries []RegistryConfig) error {
+	seen := make(map[string]bool)
+	for i, reg := range registries {
+		if !dockerHostName.MatchString(reg.Name) {
+			return fmt.Errorf("docker.registries[%d]: name %q must be lowercase letters, digits, - or _", i, reg.Name)
+		}
+		if seen[reg.Name] {
+			return fmt.Errorf("docker.registries[%d]: name %q is used twice", i, reg.Name)
+		}
+		seen[reg.Name] = true
+		u, err := url.Parse(reg.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("docker.registries[%d]: url %q must be an http or https URL", i, reg.URL)
+		}
+	}
+	return nil
+}
+
+// repositoryName adds the library/ namespace of official Docker Hub images
+func repositoryName(reg RegistryConfig, repository string) string {
+	if u, err := url.Parse(reg.URL); err == nil && u.Host == dockerHubRegistry && !strings.Contains(repository, "/") {
+		return "library/" + repository
+	}
+	return repository
+}
+
+// get requests a registry path, authenticating when the registry asks for it
+// Registries answer 401 with a challenge: either basic auth or a bearer token
+// from the token service named in it, which is cached for its lifetime.
+func (r *registryClient) get(ctx context.Context, reg RegistryConfig, path string, accept []string) (*http.Response, error) {
+	target := strings.TrimSuffix(reg.URL, "/") + path
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, mediaType := range accept {
+			req.Header.Add("Accept", mediaType)
+		}
+		return req, nil
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, registryStatus(resp)
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	if req, err = newRequest(); err != nil {
+		return nil, err
+	}
+	scheme, params, _ := strings.Cut(challenge, " ")
+	switch strings.ToLower(scheme) {
+	case "bearer":
+		token, err := r.token(ctx, reg, params)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case "basic":
+		if reg.Username == "" {
+			return nil, &registryError{status: http.StatusUnauthorized, message: "registry requires credentials"}
+		}
+		req.SetBasicAuth(reg.Username, reg.Password)
+	default:
+		return nil, &registryError{status: http.StatusUnauthorized, message: "registry refused access"}
+	}
+	if resp, err = r.http.Do(req); err != nil {
+		return nil, err
+	}
+	return resp, registryStatus(resp)
+}
+
+// registryStatus turns an error answer into a registryError and closes its body
+func registryStatus(resp *http.Response) error {
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, registryMaxResponse)).Decode(&body)
+	message := resp.Status
+	if len(body.Errors) > 0 && body.Errors[0].Message != "" {
+		message = body.Errors[0].Message
+	}
+	return &registryError{status: resp.StatusCode, message: "registry: " + message}
+}
+
+// token returns a bearer token for the realm, service and scope of a challenge
+func (r *registryClient) token(ctx context.Context, reg RegistryConfig, challenge string) (string, error) {
+	params := make(map[string]string)
+	for _, match := range registryChallengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(match[1])] = match[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || (realm.Scheme != "https" && realm.Scheme != "http") {
+		return "", &registryError{status: http.StatusUnauthorized, message: "registry sent an invalid token realm"}
+	}
+
+	key := reg.Name + " " + params["scope"]
+	r.mu.Lock()
+	cached, ok := r.tokens[key]
+	r.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.token, nil
+	}
+
+	query := realm.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	if params["scope"] != "" {
+		query.Set("scope", params["scope"])
+	}
+	realm.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if reg.Username != "" {
+		req.SetBasicAuth(reg.Username, reg.Password)
+	}
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	if err := registryStatus(resp); err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var answer struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, registryMaxResponse)).Decode(&answer); err != nil {
+		return "", fmt.Errorf("registry token: %w", err)
+	}
+	token := answer.Token
+	if token == "" {
+		token = answer.AccessToken
+	}
+	if token == "" {
+		return "", &registryError{status: http.StatusUnauthorized, message: "registry sent no token"}
+	}
+	// Tokens live at least 60 seconds; renew them a little early
+	lifetime := max(answer.ExpiresIn, 60) - 10
+
+	r.mu.Lock()
+	r.tokens[key] = registryToken{token: token, expires: time.Now().Add(time.Duration(lifetime) * time.Second)}
+	r.mu.Unlock()
+	return token, nil
+}
+
+// getJSON requests a registry path and decodes the JSON answer
+// It returns the answer's headers for paging and digests.
+func (r *registryClient) getJSON(ctx context.Context, reg RegistryConfig, path string, accept []string, v interface{}) (http.Header, error) {
+	resp, err := r.get(ctx, reg, path, accept)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(io.LimitReader(resp.Body, registryMaxResponse)).Decode(v); err != nil {
+		return nil, fmt.Errorf("registry answer: %w", err)
+	}
+	return resp.Header, nil
+}
+
+// list reads a paged list such as the catalog or the tags of a repository
+// Pages are followed through the Link header up to registryMaxEntries names.
+func (r *registryClient) list(ctx context.Context, reg RegistryConfig, path, field string) ([]RegistryEntry, error) {
+	entries := []RegistryEntry{}
+	next := fmt.Sprintf("%s?n=%d", path, registryPageSize)
+	for next != "" && len(entries) < registryMaxEntries {
+		var page map[string]json.RawMessage
+		header, err := r.getJSON(ctx, reg, next, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		if raw, ok := page[field]; ok {
+			if err := json.Unmarshal(raw, &names); err != nil {
+				return nil, fmt.Errorf("registry answer: %w", err)
+			}
+		}
+		for _, name := range names {
+			entries = append(entries, RegistryEntry{Name: name})
+		}
+		next = nextPage(header.Get("Link"))
+	}
+	return entries, nil
+}
+
+// nextPage returns the path of a Link: </v2/...>; rel="next" header
+func nextPage(link string) string {
+	target, params, ok := strings.Cut(link, ";")
+	if !ok || !strings.Contains(params, `rel="next"`) {
+		return ""
+	}
+	target = strings.Trim(strings.TrimSpace(target), "<>")
+	u, err := url.Parse(target)
+	if err != nil || !strings.HasPrefix(u.Path, "/v2/") {
+		return ""
+	}
+	return u.RequestURI()
+}
+
+// manifestDescriptor points at a manifest or blob
+type manifestDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+	Platform  *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+		Variant      string `json:"variant"`
+	} `json:"platform"`
+}
+
+// imageManifest is a manifest or a manifest list (index)
+type imageManifest struct {
+	MediaType string               `json:"mediaType"`
+	Config    manifestDescriptor   `json:"config"`
+	Layers    []manifestDescriptor `json:"layers"`
+	Manifests []manifestDescriptor `json:"manifests"`
+}
+
+// layerSize is the size of the compressed layers of a manifest
+func (m imageManifest) layerSize() int64 {
+	var size int64
+	for _, layer := range m.Layers {
+		size += layer.Size
+	}
+	return size
+}
+
+// manifestAccept lists the manifest formats the client understands
+var manifestAccept = []string{mediaTypeOCIIndex, mediaTypeDockerManifestList, mediaTypeOCIManifest, mediaTypeDockerManifest}
+
+// manifest reads a manifest by tag or digest and returns it with its digest
+func (r *registryClient) manifest(ctx context.Context, reg RegistryConfig, repository, reference string) (imageManifest, string, error) {
+	var manifest imageManifest
+	header, err := r.getJSON(ctx, reg, "/v2/"+repository+"/manifests/"+reference, manifestAccept, &manifest)
+	if err != nil {
+		return manifest, "", err
+	}
+	if manifest.MediaType == "" {
+		manifest.MediaType = header.Get("Content-Type")
+	}
+	digest := header.Get("Docker-Content-Digest")
+	if digest == "" && strings.Contains(reference, ":") {
+		digest = reference
+	}
+	return manifest, digest, nil
+}
+
+// devicePlatform reports whether an image for os and architecture runs on this device
+func devicePlatform(os, architecture string) bool {
+	return os == runtime.GOOS && architecture == runtime.GOARCH
+}
+
+// describeManifest lists the platforms of a manifest
+// The platform of a single image is read from its config; of a manifest list
+// only the image matching the device is read, for its size.
+func (r *registryClient) describeManifest(ctx context.Context, reg RegistryConfig, repository, reference string) (RegistryManifest, error) {
+	manifest, digest, err := r.manifest(ctx, reg, repository, reference)
+	if err != nil {
+		return RegistryManifest{}, err
+	}
+	result := RegistryManifest{
+		Repository:     repository,
+		Reference:      reference,
+		Digest:         digest,
+		MediaType:      manifest.MediaType,
+		DevicePlatform: runtime.GOOS + "/" + runtime.GOARCH,
+		Platforms:      []RegistryPlatform{},
+	}
+
+	if len(manifest.Manifests) == 0 {
+		var config struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+			Variant      string `json:"variant"`
+		}
+		if _, err := r.getJSON(ctx, reg, "/v2/"+repository+"/blobs/"+manifest.Config.Digest, nil, &config); err != nil {
+			return result, err
+		}
+		result.Platforms = append(result.Platforms, RegistryPlatform{
+			OS:           config.OS,
+			Architecture: config.Architecture,
+			Variant:      config.Variant,
+			Digest:       digest,
+			Size:         manifest.layerSize(),
+			Matches:      devicePlatform(config.OS, config.Architecture),
+		})
+		return result, nil
+	}
+
+	result.MultiPlatform = true
+	for _, entry := range manifest.Manifests {
+		// Attestations (SBOM, provenance) are listed with platform unknown/unknown
+		if entry.Platform == nil || entry.Platform.OS == "unknown" {
+			continue
+		}
+		platform := RegistryPlatform{
+			OS:           entry.Platform.OS,
+			Architecture: entry.Platform.Architecture,
+			Variant:      entry.Platform.Variant,
+			Digest:       entry.Digest,
+			Matches:      devicePlatform(entry.Platform.OS, entry.Platform.Architecture),
+		}
+		if platform.Matches {
+			if image, _, err := r.manifest(ctx, reg, repository, entry.Digest); err == nil {
+				platform.Size = image.layerSize()
+			}
+		}
+		result.Platforms = append(result.Platforms, platform)
+	}
+	return result, nil
+}
+
+// registryByName returns a configured registry
+func (p *DockerPlugin) registryByName(name string) (RegistryConfig, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, reg := range p.registries {
+		if reg.Name == name {
+			return reg, true
+		}
+	}
+	return RegistryConfig{}, false
+}
+
+// sendRegistryError answers a failed registry request
+func sendRegistryError(c *fiber.Ctx, err error) error {
+	var regErr *registryError
+	if errors.As(err, &regErr) {
+		switch regErr.status {
+		case http.StatusNotFound:
+			return SendErrorMessage(c, 404, regErr.message)
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return SendErrorMessage(c, 502, regErr.message+" (check the registry credentials)")
+		}
+	}
+	return SendError(c, 502, err)
+}
+
+// listRegistries handles GET /api/registries
+func (p *DockerPlugin) listRegistries(c *fiber.Ctx) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	list := make([]RegistryInfo, len(p.registries))
+	for i, reg := range p.registries {
+		list[i] = RegistryInfo{Name: reg.Name, URL: reg.URL, Authenticated: reg.Username != ""}
+	}
+	return SendSuccess(c, list, "")
+}
+
+// listRegistryRepositories handles GET /api/registries/:name/repositories
+// with the shared list parameters; Docker Hub does not offer a catalog
+func (p *DockerPlugin) listRegistryRepositories(c *fiber.Ctx) error {
+	reg, ok := p.registryByName(c.Params("name"))
+	if !ok {
+		return SendErrorMessage(c, 404, "Registry not found")
+	}
+	ctx, cancel := RequestContext(c)
+	defer cancel()
+
+	entries, err := p.registry.list(ctx, reg, "/v2/_catalog", "repositories")
+	if err != nil {
+		slog.WarnContext(c.UserContext(), "Registry catalog failed", "registry", reg.Name, "error", err)
+		return sendRegistryError(c, err)
+	}
+	entries, meta, err := pageList(c, entries, registryEntrySpec)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+	return SendList(c, entries, meta)
+}
+
+// listRegistryTags handles GET /api/registries/:name/tags?repository=library/alpine
+// with the shared list parameters, e.g. filter=name~arm64
+func (p *DockerPlugin) listRegistryTags(c *fiber.Ctx) error {
+	reg, ok := p.registryByName(c.Params("name"))
+	if !ok {
+		return SendErrorMessage(c, 404, "Registry not found")
+	}
+	repository := c.Query("repository")
+	if !registryRepository.MatchString(repository) {
+		return SendErrorMessage(c, 400, "A valid repository is required, e.g. library/alpine")
+	}
+	repository = repositoryName(reg, repository)
+	ctx, cancel := RequestContext(c)
+	defer cancel()
+
+	entries, err := p.registry.list(ctx, reg, "/v2/"+repository+"/tags/list", "tags")
+	if err != nil {
+		return sendRegistryError(c, err)
+	}
+	entries, meta, err := pageList(c, entries, registryEntrySpec)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+	return SendList(c, entries, meta)
+}
+
+// getRegistryManifest handles GET /api/registries/:name/manifest?repository=library/alpine&reference=latest
+// Lists the platforms a tag or digest is available for and marks those that run on this device
+func (p *DockerPlugin) getRegistryManifest(c *fiber.Ctx) error {
+	reg, ok := p.registryByName(c.Params("name"))
+	if !ok {
+		return SendErrorMessage(c, 404, "Registry not found")
+	}
+	repository := c.Query("repository")
+	if !registryRepository.MatchString(repository) {
+		return SendErrorMessage(c, 400, "A valid repository is required, e.g. library/alpine")
+	}
+	repository = repositoryName(reg, repository)
+	reference := c.Query("reference", "latest")
+	if !registryReference.MatchString(reference) {
+		return SendErrorMessage(c, 400, "Invalid tag or digest")
+	}
+	ctx, cancel := RequestContext(c)
+	defer cancel()
+
+	manifest, err := p.registry.describeManifest(ctx, reg, repository, reference)
+	if err != nil {
+		return sendRegistryError(c, err)
+	}
+	return SendSuccess(c, manifest, "")
+}
@@ -0,0 +1,151 @@
+package plugins
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Event bus constants
+const (
+	eventSubscriberBuffer = 64
+	eventKeepAlive        = 15 * time.Second
+)
+
+// Event represents a notification published on the event bus
+type Event struct {
+	ID     uint64      `json:"id"`
+	Type   string      `json:"type"`
+	Source string      `json:"source"`
+	Time   time.Time   `json:"time"`
+	Data   interface{} `json:"data,omitempty"`
+}
+
+// EventBus fans out events to subscribers
+// Slow subscribers drop events instead of blocking publishers
+type EventBus struct {
+	subscribers map[chan Event]struct{}
+	nextID      uint64
+	mu          sync.Mutex
+}
+
+// Events is the shared event bus used by all plugins
+var Events = NewEventBus()
+
+// NewEventBus creates a new event bus
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Publish sends an event to all current subscribers
+func (b *EventBus) Publish(eventType string, source string, data interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	event := Event{
+		ID:     b.nextID,
+		Type:   eventType,
+		Source: source,
+		Time:   time.Now(),
+		Data:   data,
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Subscribe registers a new subscriber
+// The returned function must be called to unsubscribe
+func (b *EventBus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventSubscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// PublishEvent publishes an event on the shared event bus
+func PublishEvent(eventType string, source string, data interface{}) {
+	Events.Publish(eventType, source, data)
+}
+
+// HandleEventStream handles GET /api/events?type=prefix
+// Streams events from the shared bus via SSE, optionally filtered by type prefix
+func HandleEventStream(c *fiber.Ctx) error {
+	var filters []string
+	if typeParam := c.Query("type"); typeParam != "" {
+		filters = strings.Split(typeParam, ",")
+	}
+
+	// Set SSE headers
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	events, unsubscribe := Events.Subscribe()
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		keepAlive := time.NewTicker(eventKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case event := <-events:
+				if !matchesEventFilter(event.Type, filters) {
+					continue
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+// matchesEventFilter reports whether an event type matches any of the type prefixes
+func matchesEventFilter(eventType string, filters []string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, filter := range filters {
+		if strings.HasPrefix(eventType, strings.TrimSpace(filter)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,490 @@
+package plugins
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/proxy"
+	"github.com/valyala/fasthttp"
+)
+
+// External plugin defaults
+const (
+	DefaultExternalSocketDir    = "/run/linht/plugins"
+	DefaultExternalStartTimeout = 10 // seconds to answer GET /manifest after starting
+	DefaultExternalRestartDelay = 5  // seconds, doubled after each crash
+	DefaultExternalTimeout      = 60 // seconds per proxied request
+	maxExternalRestartDelay     = 5 * time.Minute
+	externalStopTimeout         = 5 * time.Second
+	externalReadyPoll           = 200 * time.Millisecond
+	externalProtocolVersion     = "1"
+	externalExitedEvent         = "external.plugin.exited"
+	externalEventSource         = "external"
+)
+
+// External plugin process states
+const (
+	ExternalStarting = "starting"
+	ExternalRunning  = "running"
+	ExternalCrashed  = "crashed" // waiting to be restarted
+	ExternalStopped  = "stopped"
+)
+
+// externalName limits plugin names to values usable in paths and file names
+var externalName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// errExternalRestart is returned by run when a restart was requested
+var errExternalRestart = errors.New("restart requested")
+
+// ExternalPluginConfig describes one out-of-process plugin
+type ExternalPluginConfig struct {
+	Name    string            `yaml:"name"`
+	Command string            `yaml:"command"`
+	Args    []string          `yaml:"args"`
+	Env     map[string]string `yaml:"env"`
+	Dir     string            `yaml:"dir"` // working directory
+}
+
+// ExternalConfig holds the external section of the configuration
+type ExternalConfig struct {
+	SocketDir    string                 `yaml:"socket_dir"`
+	StartTimeout int                    `yaml:"start_timeout"`
+	RestartDelay int                    `yaml:"restart_delay"`
+	Timeout      int                    `yaml:"timeout"`
+	Plugins      []ExternalPluginConfig `yaml:"plugins"`
+
+	APIURL string `yaml:"-"` // manager API base URL handed to the plugins
+}
+
+// ExternalManifest is returned by a plugin's GET /manifest
+type ExternalManifest struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+// ExternalPluginStatus describes the state of a plugin process
+type ExternalPluginStatus struct {
+	Name      string            `json:"name"`
+	Command   string            `json:"command"`
+	Path      string            `json:"path"` // API prefix of the proxied routes
+	State     string            `json:"state"`
+	PID       int               `json:"pid,omitempty"`
+	Restarts  int               `json:"restarts"`
+	StartedAt *time.Time        `json:"started_at,omitempty"`
+	LastError string            `json:"last_error,omitempty"`
+	Manifest  *ExternalManifest `json:"manifest,omitempty"`
+}
+
+// externalProcess supervises one plugin process
+type externalProcess struct {
+	config  ExternalPluginConfig
+	socket  string
+	client  *fasthttp.Client
+	restart chan struct{}
+
+	mu     sync.Mutex
+	status ExternalPluginStatus
+}
+
+// ExternalPlugin runs site-specific plugins as separate processes and proxies
+// their HTTP API, so extensions don't require rebuilding the manager
+//
+// A plugin process serves HTTP on the unix socket in LINHT_PLUGIN_SOCKET and
+// must answer GET /manifest. Requests to /api/<version>/ext/<name>/... are
+// forwarded to it with the prefix removed.
+type ExternalPlugin struct {
+	config    ExternalConfig
+	processes map[string]*externalProcess
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewExternalPlugin creates a new external plugin instance
+// Processes are started in Start
+func NewExternalPlugin(cfg ExternalConfig) (*ExternalPlugin, error) {
+	cfg = normalizeExternalConfig(cfg)
+	if err := validateExternalConfig(cfg); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cfg.SocketDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create plugin socket directory: %w", err)
+	}
+
+	p := &ExternalPlugin{
+		config:    cfg,
+		processes: make(map[string]*externalProcess),
+		stopChan:  make(chan struct{}),
+	}
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	for _, pluginCfg := range cfg.Plugins {
+		socket := filepath.Join(cfg.SocketDir, pluginCfg.Name+".sock")
+		p.processes[pluginCfg.Name] = &externalProcess{
+			config: pluginCfg,
+			socket: socket,
+			client: &fasthttp.Client{
+				Dial: func(string) (net.Conn, error) {
+					return net.Dial("unix", socket)
+				},
+				ReadTimeout:  timeout,
+				WriteTimeout: timeout,
+			},
+			restart: make(chan struct{}, 1),
+			status: ExternalPluginStatus{
+				Name:    pluginCfg.Name,
+				Command: pluginCfg.Command,
+				Path:    APIPath("/ext/" + pluginCfg.Name),
+				State:   ExternalStopped,
+			},
+		}
+	}
+	return p, nil
+}
+
+// Name returns the plugin identifier
+func (p *ExternalPlugin) Name() string {
+	return "external"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *ExternalPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/external")
+	api.Get("/", p.handleList)
+	api.Post("/:name/restart", p.handleRestart)
+
+	APIGroup(app, "/ext").All("/:name/*", p.handleProxy)
+}
+
+// Start launches and supervises the plugin processes
+func (p *ExternalPlugin) Start() error {
+	for _, proc := range p.processes {
+		p.wg.Add(1)
+		go func(proc *externalProcess) {
+			defer p.wg.Done()
+			p.supervise(proc)
+		}(proc)
+	}
+	return nil
+}
+
+// Shutdown stops all plugin processes
+func (p *ExternalPlugin) Shutdown() error {
+	close(p.stopChan)
+	p.wg.Wait()
+	return nil
+}
+
+// getStatus returns a copy of the process status
+func (proc *externalProcess) getStatus() ExternalPluginStatus {
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+	return proc.status
+}
+
+// update modifies the process status under the lock
+func (proc *externalProcess) update(fn func(*ExternalPluginStatus)) {
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+	fn(&proc.status)
+}
+
+// supervise runs a plugin process and restarts it after crashes with exponential backoff
+func (p *ExternalPlugin) supervise(proc *externalProcess) {
+	baseDelay := time.Duration(p.config.RestartDelay) * time.Second
+	delay := baseDelay
+
+	for {
+		started := time.Now()
+		err := p.run(proc)
+
+		select {
+		case <-p.stopChan:
+			proc.update(func(s *ExternalPluginStatus) {
+				s.State = ExternalStopped
+				s.PID = 0
+			})
+			return
+		default:
+		}
+
+		if errors.Is(err, errExternalRestart) {
+			delay = baseDelay
+			proc.update(func(s *ExternalPluginStatus) { s.Restarts++ })
+			continue
+		}
+
+		// A plugin that ran for a while is restarted quickly again
+		if time.Since(started) > maxExternalRestartDelay {
+			delay = baseDelay
+		}
+		proc.update(func(s *ExternalPluginStatus) {
+			s.State = ExternalCrashed
+			s.PID = 0
+			s.LastError = err.Error()
+		})
+		PublishEvent(externalExitedEvent, externalEventSource, fiber.Map{
+			"plugin": proc.config.Name,
+			"error":  err.Error(),
+			"retry":  delay.Seconds(),
+		})
+		slog.Warn("External plugin exited", "plugin", proc.config.Name, "error", err, "retry_in", delay)
+
+		select {
+		case <-p.stopChan:
+			proc.update(func(s *ExternalPluginStatus) { s.State = ExternalStopped })
+			return
+		case <-time.After(delay):
+		case <-proc.restart:
+			delay = baseDelay
+		}
+		delay = min(delay*2, maxExternalRestartDelay)
+		proc.update(func(s *ExternalPluginStatus) { s.Restarts++ })
+	}
+}
+
+// run starts the process, waits for its manifest and blocks until it exits,
+// the plugin is stopped or a restart is requested
+func (p *ExternalPlugin) run(proc *externalProcess) error {
+	os.Remove(proc.socket)
+
+	cmd := exec.Command(proc.config.Command, proc.config.Args...)
+	cmd.Dir = proc.config.Dir
+	cmd.Env = append(os.Environ(),
+		"LINHT_PLUGIN_NAME="+proc.config.Name,
+		"LINHT_PLUGIN_SOCKET="+proc.socket,
+		"LINHT_PLUGIN_PROTOCOL="+externalProtocolVersion,
+		"LINHT_API_URL="+p.config.APIURL,
+	)
+	keys := make([]string, 0, len(proc.config.Env))
+	for key := range proc.config.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cmd.Env = append(cmd.Env, key+"="+proc.config.Env[key])
+	}
+	// Own process group so children are signalled too
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	output, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	go logExternalOutput(proc.config.Name, output)
+
+	if err := cmd.Start(); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to start: %w", err)
+	}
+	now := time.Now()
+	proc.update(func(s *ExternalPluginStatus) {
+		s.State = ExternalStarting
+		s.PID = cmd.Process.Pid
+		s.StartedAt = &now
+		s.Manifest = nil
+	})
+	slog.Info("External plugin started", "plugin", proc.config.Name, "pid", cmd.Process.Pid)
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+		writer.Close()
+	}()
+
+	deadline := time.NewTimer(time.Duration(p.config.StartTimeout) * time.Second)
+	defer deadline.Stop()
+	ticker := time.NewTicker(externalReadyPoll)
+	defer ticker.Stop()
+	ready := false
+
+	for {
+		select {
+		case err := <-exited:
+			if err == nil {
+				return errors.New("process exited")
+			}
+			return fmt.Errorf("process exited: %w", err)
+		case <-p.stopChan:
+			terminateExternal(cmd, exited)
+			return nil
+		case <-proc.restart:
+			terminateExternal(cmd, exited)
+			return errExternalRestart
+		case <-deadline.C:
+			if !ready {
+				terminateExternal(cmd, exited)
+				return fmt.Errorf("no manifest within %d seconds", p.config.StartTimeout)
+			}
+		case <-ticker.C:
+			if ready {
+				continue
+			}
+			manifest, err := proc.fetchManifest()
+			if err != nil {
+				continue
+			}
+			ready = true
+			ticker.Stop()
+			proc.update(func(s *ExternalPluginStatus) {
+				s.State = ExternalRunning
+				s.LastError = ""
+				s.Manifest = manifest
+			})
+			slog.Info("External plugin ready", "plugin", proc.config.Name, "version", manifest.Version)
+		}
+	}
+}
+
+// terminateExternal sends SIGTERM to the process group and SIGKILL if it does not exit in time
+func terminateExternal(cmd *exec.Cmd, exited <-chan error) {
+	pgid := -cmd.Process.Pid
+	syscall.Kill(pgid, syscall.SIGTERM)
+	select {
+	case <-exited:
+	case <-time.After(externalStopTimeout):
+		syscall.Kill(pgid, syscall.SIGKILL)
+		<-exited
+	}
+}
+
+// logExternalOutput logs each line the process writes to stdout or stderr
+func logExternalOutput(name string, output io.Reader) {
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		slog.Info("External plugin output", "plugin", name, "line", scanner.Text())
+	}
+}
+
+// fetchManifest requests GET /manifest from the plugin
+func (proc *externalProcess) fetchManifest() (*ExternalManifest, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI("http://" + proc.config.Name + "/manifest")
+	if err := proc.client.DoTimeout(req, resp, 2*time.Second); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != fiber.StatusOK {
+		return nil, fmt.Errorf("manifest returned status %d", resp.StatusCode())
+	}
+
+	var manifest ExternalManifest
+	if err := json.Unmarshal(resp.Body(), &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// handleList handles GET /api/external
+func (p *ExternalPlugin) handleList(c *fiber.Ctx) error {
+	result := make([]ExternalPluginStatus, 0, len(p.config.Plugins))
+	for _, pluginCfg := range p.config.Plugins {
+		result = append(result, p.processes[pluginCfg.Name].getStatus())
+	}
+	return SendSuccess(c, result, "")
+}
+
+// handleRestart handles POST /api/external/:name/restart
+// Also restarts a crashed plugin without waiting for the backoff delay
+func (p *ExternalPlugin) handleRestart(c *fiber.Ctx) error {
+	proc, ok := p.processes[c.Params("name")]
+	if !ok {
+		return SendErrorMessage(c, 404, "External plugin not found")
+	}
+
+	select {
+	case proc.restart <- struct{}{}:
+	default:
+	}
+	return SendSuccess(c, proc.getStatus(), "Restart requested")
+}
+
+// handleProxy handles /api/ext/:name/* by forwarding the request to the plugin process
+func (p *ExternalPlugin) handleProxy(c *fiber.Ctx) error {
+	name := c.Params("name")
+	proc, ok := p.processes[name]
+	if !ok {
+		return SendErrorMessage(c, 404, "External plugin not found")
+	}
+	if proc.getStatus().State != ExternalRunning {
+		return SendErrorMessage(c, 503, "External plugin is not running")
+	}
+
+	target := "http://" + name + "/" + c.Params("*")
+	if query := c.Request().URI().QueryString(); len(query) > 0 {
+		target += "?" + string(query)
+	}
+	c.Request().Header.Set("X-Forwarded-Prefix", APIPath("/ext/"+name))
+
+	if err := proxy.Do(c, target, proc.client); err != nil {
+		return SendError(c, 502, fmt.Errorf("external plugin %s: %w", name, err))
+	}
+	return nil
+}
+
+// normalizeExternalConfig fills in defaults
+func normalizeExternalConfig(cfg ExternalConfig) ExternalConfig {
+	if cfg.SocketDir == "" {
+		cfg.SocketDir = DefaultExternalSocketDir
+	}
+	if cfg.StartTimeout <= 0 {
+		cfg.StartTimeout = DefaultExternalStartTimeout
+	}
+	if cfg.RestartDelay <= 0 {
+		cfg.RestartDelay = DefaultExternalRestartDelay
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultExternalTimeout
+	}
+	return cfg
+}
+
+// validateExternalConfig checks plugin names and commands
+func validateExternalConfig(cfg ExternalConfig) error {
+	names := make(map[string]bool)
+	for _, pluginCfg := range cfg.Plugins {
+		if !externalName.MatchString(pluginCfg.Name) {
+			return fmt.Errorf("invalid external plugin name %q (use lowercase letters, digits, - and _)", pluginCfg.Name)
+		}
+		if names[pluginCfg.Name] {
+			return fmt.Errorf("duplicate external plugin name %q", pluginCfg.Name)
+		}
+		names[pluginCfg.Name] = true
+
+		if pluginCfg.Command == "" {
+			return fmt.Errorf("external plugin %q: command is required", pluginCfg.Name)
+		}
+	}
+	return nil
+}
+
+// Validate checks the settings after filling in defaults
+func (cfg ExternalConfig) Validate() error {
+	return validateExternalConfig(normalizeExternalConfig(cfg))
+}
+
+// Register the plugin
+func init() {
+	Register("external", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[ExternalConfig]("external", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewExternalPlugin(cfg)
+	})
+}
@@ -0,0 +1,105 @@
+package plugins
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// fieldPathPattern matches one entry of ?fields=, e.g. names or limits.memory
+var fieldPathPattern = regexp.MustCompile(`^[A-Za-z0-9_]+(\.[A-Za-z0-9_]+)*$`)
+
+// fieldSelection is a tree of selected fields; a field without children is kept whole
+type fieldSelection map[string]fieldSelection
+
+// parseFieldSelection reads a comma-separated list of dotted field paths
+func parseFieldSelection(param string) (fieldSelection, error) {
+	selection := make(fieldSelection)
+	for _, path := range strings.Split(param, ",") {
+		path = strings.TrimSpace(path)
+		if !fieldPathPattern.MatchString(path) {
+			return nil, fmt.Errorf("invalid field %q in fields, expected names such as id,state or limits.memory", path)
+		}
+		node := selection
+		segments := strings.Split(path, ".")
+		for i, segment := range segments {
+			child, seen := node[segment]
+			switch {
+			case seen && child == nil:
+				// Already selected whole
+			case i == len(segments)-1:
+				node[segment] = nil
+			case !seen:
+				child = make(fieldSelection)
+				node[segment] = child
+			}
+			if child == nil {
+				break
+			}
+			node = child
+		}
+	}
+	return selection, nil
+}
+
+// apply keeps the selected fields of a decoded JSON value
+// Lists are shaped element by element; fields missing from an object are left out.
+func (selection fieldSelection) apply(value interface{}) interface{} {
+	switch value := value.(type) {
+	case []interface{}:
+		for i, element := range value {
+			value[i] = selection.apply(element)
+		}
+		return value
+	case map[string]interface{}:
+		shaped := make(map[string]interface{}, len(selection))
+		for name, children := range selection {
+			field, ok := value[name]
+			if !ok {
+				continue
+			}
+			if children != nil {
+				field = children.apply(field)
+			}
+			shaped[name] = field
+		}
+		return shaped
+	}
+	return value
+}
+
+// FieldsMiddleware trims the data of successful JSON API responses to ?fields=
+// Must be registered after the ETag and compression middleware, which then
+// work on the trimmed body.
+func FieldsMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		param := c.Query("fields")
+		if param == "" || !strings.HasPrefix(c.Path(), "/api/") {
+			return c.Next()
+		}
+		selection, err := parseFieldSelection(param)
+		if err != nil {
+			return SendError(c, 400, err)
+		}
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		resp := c.Response()
+		if resp.IsBodyStream() || !strings.HasPrefix(string(resp.Header.ContentType()), fiber.MIMEApplicationJSON) {
+			return nil
+		}
+		var envelope APIResponse
+		decoder := json.NewDecoder(bytes.NewReader(resp.Body()))
+		decoder.UseNumber()
+		if err := decoder.Decode(&envelope); err != nil || !envelope.Success {
+			return nil
+		}
+		envelope.Data = selection.apply(envelope.Data)
+		return c.JSON(envelope)
+	}
+}
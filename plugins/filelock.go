@@ -0,0 +1,64 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// fileLockPollInterval is how often a held lock is retried
+const fileLockPollInterval = 5 * time.Millisecond
+
+// errFileLocked is returned when another process holds a lock for too long
+var errFileLocked = errors.New("locked by another process")
+
+// fileLock is an advisory flock(2) shared with other processes
+type fileLock struct {
+	file *os.File
+}
+
+// lockFile takes an exclusive lock on path, waiting up to timeout
+// The file is created when create is set. A nil lock is returned for an empty path.
+func lockFile(path string, create bool, timeout time.Duration) (*fileLock, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	flags := os.O_RDONLY
+	if create {
+		flags |= os.O_CREATE
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file %s: %w", path, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			return &fileLock{file: file}, nil
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) && !errors.Is(err, syscall.EINTR) {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, fmt.Errorf("%s is %w (waited %s)", path, errFileLocked, timeout)
+		}
+		time.Sleep(fileLockPollInterval)
+	}
+}
+
+// unlock releases the lock; further calls do nothing
+func (l *fileLock) unlock() {
+	if l == nil || l.file == nil {
+		return
+	}
+	syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	l.file.Close()
+	l.file = nil
+}
@@ -1,12 +1,15 @@
 package plugins
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -17,9 +20,31 @@ const (
 	DefaultMaxUploadSize = 1 * 1024 * 1024 * 1024 // 1GB
 )
 
+// ErrFileExists is returned when a write would replace a file without overwrite
+var ErrFileExists = errors.New("file already exists")
+
+// FileManagerConfig holds the filemanager section of the configuration
+type FileManagerConfig struct {
+	MaxUploadSize int64    `yaml:"max_upload_size"` // bytes, 0 = default
+	Trash         bool     `yaml:"trash"`
+	Symlinks      string   `yaml:"symlinks"`       // follow (default), deny or restrict
+	SymlinkRoots  []string `yaml:"symlink_roots"`  // allowed link targets for restrict
+	Dedup         bool     `yaml:"dedup"`          // store identical uploads as hard links
+	DedupIndex    string   `yaml:"dedup_index"`    // content hashes of uploaded files
+	DedupMinSize  int64    `yaml:"dedup_min_size"` // bytes, smaller uploads are not hashed
+
+	Remotes []RemoteConfig `yaml:"-"` // taken from the remotes section
+}
+
 // FileManagerPlugin provides simple file management functionality
 type FileManagerPlugin struct {
 	maxUploadSize int64
+	trash         bool
+	dedup         *uploadDedup
+	dedupEnabled  bool
+	dedupMinSize  int64
+	remotes       []RemoteConfig
+	mu            sync.RWMutex
 }
 
 // FileItem represents a file or directory
@@ -29,6 +54,11 @@ type FileItem struct {
 	IsDir    bool      `json:"isDir"`
 	Size     int64     `json:"size"`
 	Modified time.Time `json:"modified"`
+
+	// Symbolic links report the type and size of their target
+	IsSymlink bool   `json:"isSymlink,omitempty"`
+	Target    string `json:"target,omitempty"`
+	Broken    bool   `json:"broken,omitempty"`
 }
 
 // DirectoryListing represents the contents of a directory
@@ -38,17 +68,64 @@ type DirectoryListing struct {
 	Items  []FileItem `json:"items"`
 }
 
+// fileListSpec names the item fields for sorting and filtering directory listings
+var fileListSpec = listSpec[FileItem]{
+	key: "name",
+	fields: map[string]func(FileItem) interface{}{
+		"name":       func(item FileItem) interface{} { return item.Name },
+		"ext":        func(item FileItem) interface{} { return strings.TrimPrefix(filepath.Ext(item.Name), ".") },
+		"size":       func(item FileItem) interface{} { return item.Size },
+		"modified":   func(item FileItem) interface{} { return item.Modified },
+		"is_dir":     func(item FileItem) interface{} { return item.IsDir },
+		"is_symlink": func(item FileItem) interface{} { return item.IsSymlink },
+	},
+}
+
 // NewFileManagerPlugin creates a new FileManager plugin instance
-func NewFileManagerPlugin(maxUploadSize int64) (*FileManagerPlugin, error) {
-	if maxUploadSize <= 0 {
-		maxUploadSize = DefaultMaxUploadSize
+// With trash enabled, deletions are moved to a per-filesystem .trash directory
+func NewFileManagerPlugin(cfg FileManagerConfig) (*FileManagerPlugin, error) {
+	cfg = normalizeFileManagerConfig(cfg)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
+	setSymlinkPolicy(symlinkPolicy{Mode: cfg.Symlinks, Roots: cfg.SymlinkRoots})
 
 	return &FileManagerPlugin{
-		maxUploadSize: maxUploadSize,
+		maxUploadSize: cfg.MaxUploadSize,
+		trash:         cfg.Trash,
+		dedup:         &uploadDedup{path: cfg.DedupIndex},
+		dedupEnabled:  cfg.Dedup,
+		dedupMinSize:  cfg.DedupMinSize,
+		remotes:       cfg.Remotes,
 	}, nil
 }
 
+// normalizeFileManagerConfig fills in defaults
+func normalizeFileManagerConfig(cfg FileManagerConfig) FileManagerConfig {
+	if cfg.MaxUploadSize <= 0 {
+		cfg.MaxUploadSize = DefaultMaxUploadSize
+	}
+	if cfg.Symlinks == "" {
+		cfg.Symlinks = SymlinksFollow
+	}
+	if cfg.DedupIndex == "" {
+		cfg.DedupIndex = DefaultDedupIndex
+	}
+	if cfg.DedupMinSize <= 0 {
+		cfg.DedupMinSize = DefaultDedupMinSize
+	}
+	return cfg
+}
+
+// Validate checks the symlink policy and remotes
+func (cfg FileManagerConfig) Validate() error {
+	cfg = normalizeFileManagerConfig(cfg)
+	if err := validateSymlinkPolicy(symlinkPolicy{Mode: cfg.Symlinks, Roots: cfg.SymlinkRoots}); err != nil {
+		return fmt.Errorf("filemanager.%w", err)
+	}
+	return ValidateRemotes(cfg.Remotes)
+}
+
 // Name returns the plugin identifier
 func (p *FileManagerPlugin) Name() string {
 	return "filemanager"
@@ -56,13 +133,28 @@ func (p *FileManagerPlugin) Name() string {
 
 // RegisterRoutes adds the plugin's HTTP routes
 func (p *FileManagerPlugin) RegisterRoutes(app *fiber.App) {
-	api := app.Group("/api/filemanager")
+	api := APIGroup(app, "/filemanager")
 
 	api.Get("/list", p.listDirectory)
+	api.Get("/roots", p.listRoots)
 	api.Post("/upload", p.uploadFile)
 	api.Get("/download", p.downloadFile)
+	AllowSignedLinks("/filemanager/download")
 	api.Delete("/delete", p.deleteItem)
 	api.Post("/mkdir", p.createFolder)
+	api.Post("/symlink", p.createSymlink)
+	api.Get("/trash", p.listTrashItems)
+	api.Post("/trash/restore", p.restoreTrashItem)
+	api.Delete("/trash", p.purgeTrash)
+	api.Get("/hash", p.hashItem)
+	api.Get("/search", p.searchItems)
+	api.Post("/fetch", p.fetchItem)
+	api.Get("/dedup", p.dedupStats)
+	api.Get("/remotes", p.listRemotes)
+	api.Get("/remotes/:name/list", p.listRemoteDirectory)
+	api.Post("/remotes/:name/upload", p.uploadToRemote)
+	api.Post("/remotes/:name/download", p.downloadFromRemote)
+	api.Delete("/remotes/:name/delete", p.deleteRemoteFile)
 }
 
 // Shutdown performs cleanup
@@ -70,8 +162,65 @@ func (p *FileManagerPlugin) Shutdown() error {
 	return nil
 }
 
+// Reload applies a new upload size limit, trash mode, symlink policy and remotes at runtime
+func (p *FileManagerPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[FileManagerConfig]("filemanager", config)
+	if err != nil {
+		return err
+	}
+	cfg = normalizeFileManagerConfig(cfg)
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.maxUploadSize = cfg.MaxUploadSize
+	p.trash = cfg.Trash
+	p.dedupEnabled = cfg.Dedup
+	p.dedupMinSize = cfg.DedupMinSize
+	p.remotes = cfg.Remotes
+	p.mu.Unlock()
+	p.dedup.setPath(cfg.DedupIndex)
+	setSymlinkPolicy(symlinkPolicy{Mode: cfg.Symlinks, Roots: cfg.SymlinkRoots})
+
+	slog.Info("File manager config reloaded",
+		"max_upload_size", cfg.MaxUploadSize,
+		"trash", cfg.Trash,
+		"symlinks", cfg.Symlinks,
+		"dedup", cfg.Dedup,
+		"remotes", len(cfg.Remotes))
+	return nil
+}
+
+// getMaxUploadSize returns the current upload size limit
+func (p *FileManagerPlugin) getMaxUploadSize() int64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.maxUploadSize
+}
+
+// trashEnabled reports whether deletions go to the trash
+func (p *FileManagerPlugin) trashEnabled() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.trash
+}
+
 // sanitizePath validates and cleans the path to prevent directory traversal
+// and applies the symlink policy (filemanager.symlinks) to it
 func sanitizePath(path string) (string, error) {
+	abs, err := cleanPath(path)
+	if err != nil {
+		return "", err
+	}
+	if err := checkSymlinks(abs); err != nil {
+		return "", err
+	}
+	return abs, nil
+}
+
+// cleanPath converts a request path to a clean absolute path without traversal
+func cleanPath(path string) (string, error) {
 	if path == "" {
 		return "/", nil
 	}
@@ -131,13 +280,17 @@ func (p *FileManagerPlugin) listDirectory(c *fiber.Ctx) error {
 		}
 
 		fullPath := filepath.Join(dirPath, entry.Name())
-		items = append(items, FileItem{
+		item := FileItem{
 			Name:     entry.Name(),
 			Path:     fullPath,
 			IsDir:    entry.IsDir(),
 			Size:     info.Size(),
 			Modified: info.ModTime(),
-		})
+		}
+		if entry.Type()&os.ModeSymlink != 0 {
+			describeSymlink(&item)
+		}
+		items = append(items, item)
 	}
 
 	// Get parent directory
@@ -146,16 +299,36 @@ func (p *FileManagerPlugin) listDirectory(c *fiber.Ctx) error {
 		parent = ""
 	}
 
+	items, meta, err := pageList(c, items, fileListSpec)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+
 	listing := DirectoryListing{
 		Path:   dirPath,
 		Parent: parent,
 		Items:  items,
 	}
 
-	return SendSuccess(c, listing, "")
+	return SendList(c, listing, meta)
+}
+
+// listRoots handles GET /api/filemanager/roots
+// Returns the filesystem root and any removable media mounted by the storage plugin
+func (p *FileManagerPlugin) listRoots(c *fiber.Ctx) error {
+	roots := []FileItem{{Name: "/", Path: "/", IsDir: true}}
+	for _, mount := range StorageMounts() {
+		name := mount.Label
+		if name == "" {
+			name = mount.Name
+		}
+		roots = append(roots, FileItem{Name: name, Path: mount.Mountpoint, IsDir: true})
+	}
+	return SendSuccess(c, roots, "")
 }
 
 // uploadFile handles POST /api/filemanager/upload
+// Existing files are only replaced when the form sets overwrite=true
 func (p *FileManagerPlugin) uploadFile(c *fiber.Ctx) error {
 	// Get destination path
 	destPath := c.FormValue("path")
@@ -184,20 +357,29 @@ func (p *FileManagerPlugin) uploadFile(c *fiber.Ctx) error {
 		return SendErrorMessage(c, 400, "No file provided")
 	}
 
+	overwrite := false
+	if value := c.FormValue("overwrite"); value != "" {
+		if overwrite, err = strconv.ParseBool(value); err != nil {
+			return SendErrorMessage(c, 400, "Invalid overwrite flag")
+		}
+	}
+
+	maxUploadSize := p.getMaxUploadSize()
+
 	// Log file details
-	slog.Info("File upload started",
+	slog.InfoContext(c.UserContext(), "File upload started",
 		"filename", file.Filename,
 		"size", file.Size,
-		"max_size", p.maxUploadSize,
+		"max_size", maxUploadSize,
 		"destination", dirPath)
 
 	// Check file size
-	if file.Size > p.maxUploadSize {
-		slog.Warn("File size exceeds limit",
+	if file.Size > maxUploadSize {
+		slog.WarnContext(c.UserContext(), "File size exceeds limit",
 			"filename", file.Filename,
 			"size", file.Size,
-			"max_size", p.maxUploadSize)
-		return SendErrorMessage(c, 413, fmt.Sprintf("File too large (max %d bytes)", p.maxUploadSize))
+			"max_size", maxUploadSize)
+		return SendErrorMessage(c, 413, fmt.Sprintf("File too large (max %d bytes)", maxUploadSize))
 	}
 
 	// Sanitize filename
@@ -209,38 +391,99 @@ func (p *FileManagerPlugin) uploadFile(c *fiber.Ctx) error {
 	// Build destination file path
 	destFile := filepath.Join(dirPath, filename)
 
+	// Refuse early; commitFile checks again once the data is written
+	if existing, err := os.Lstat(destFile); err == nil {
+		if existing.IsDir() {
+			return SendErrorMessage(c, 409, fmt.Sprintf("%s is a directory", filename))
+		}
+		if !overwrite {
+			return SendErrorMessage(c, 409, fmt.Sprintf("%s already exists (set overwrite to replace it)", filename))
+		}
+	}
+
+	// Write to a temporary file next to the destination, so a failed
+	// upload never leaves a truncated file behind
+	tmp, err := os.CreateTemp(dirPath, "."+filename+".upload-*")
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
 	// Log memory usage before starting upload
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
-	slog.Info("Memory stats before file save",
+	slog.InfoContext(c.UserContext(), "Memory stats before file save",
 		"alloc", m.Alloc/1024/1024, // MB
 		"sys", m.Sys/1024/1024, // MB
 		"num_gc", m.NumGC)
 
 	// Save file with detailed error logging
 	startTime := time.Now()
-	if err := c.SaveFile(file, destFile); err != nil {
-		slog.Error("Failed to save file",
+	if err := c.SaveFile(file, tmpPath); err != nil {
+		slog.ErrorContext(c.UserContext(), "Failed to save file",
 			"filename", file.Filename,
 			"destination", destFile,
 			"error", err,
 			"duration", time.Since(startTime))
 		return SendError(c, 500, err)
 	}
+	// Temporary files are created 0600; uploads are readable like regular files
+	os.Chmod(tmpPath, 0644)
+	dedup, err := p.commitUpload(tmpPath, destFile, file.Size, overwrite)
+	if err != nil {
+		if errors.Is(err, ErrFileExists) {
+			return SendErrorMessage(c, 409, fmt.Sprintf("%s already exists (set overwrite to replace it)", filename))
+		}
+		return SendError(c, 500, err)
+	}
 
 	// Log completion and memory usage after upload
 	runtime.ReadMemStats(&m)
-	slog.Info("File upload completed",
+	slog.InfoContext(c.UserContext(), "File upload completed",
 		"filename", file.Filename,
 		"destination", destFile,
 		"size", file.Size,
 		"duration", time.Since(startTime),
+		"overwrite", overwrite,
+		"dedup", dedup,
 		"alloc_after", m.Alloc/1024/1024, // MB
 		"sys_after", m.Sys/1024/1024) // MB
 
+	switch dedup {
+	case DedupLinked:
+		return SendSuccess(c, fiber.Map{"dedup": dedup}, "File uploaded; stored as a link to an identical file")
+	case DedupSkipped:
+		return SendSuccess(c, fiber.Map{"dedup": dedup}, "File uploaded; the existing file was identical")
+	}
 	return SendSuccess(c, nil, "File uploaded successfully")
 }
 
+// commitFile moves a completed temporary file to its destination
+// Without overwrite an existing destination is never replaced, even one
+// created while the data was written; a link at the destination is replaced,
+// not followed
+func commitFile(tmpPath string, destFile string, overwrite bool) error {
+	if overwrite {
+		return os.Rename(tmpPath, destFile)
+	}
+
+	err := os.Link(tmpPath, destFile)
+	if err == nil {
+		return os.Remove(tmpPath)
+	}
+	if os.IsExist(err) {
+		return fmt.Errorf("%s: %w", destFile, ErrFileExists)
+	}
+
+	// Filesystems without hard links (e.g. FAT on SD cards)
+	if _, err := os.Lstat(destFile); err == nil {
+		return fmt.Errorf("%s: %w", destFile, ErrFileExists)
+	}
+	return os.Rename(tmpPath, destFile)
+}
+
 // downloadFile handles GET /api/filemanager/download?path=/path/to/file
 func (p *FileManagerPlugin) downloadFile(c *fiber.Ctx) error {
 	pathParam := c.Query("path")
@@ -277,9 +520,11 @@ func (p *FileManagerPlugin) downloadFile(c *fiber.Ctx) error {
 }
 
 // deleteItem handles DELETE /api/filemanager/delete
+// In trash mode the item is moved to the trash unless permanent is set or it is already in the trash
 func (p *FileManagerPlugin) deleteItem(c *fiber.Ctx) error {
 	var req struct {
-		Path string `json:"path"`
+		Path      string `json:"path"`
+		Permanent bool   `json:"permanent"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -290,8 +535,8 @@ func (p *FileManagerPlugin) deleteItem(c *fiber.Ctx) error {
 		return SendErrorMessage(c, 400, "Path required")
 	}
 
-	// Sanitize path
-	itemPath, err := sanitizePath(req.Path)
+	// Sanitize path; a link is deleted itself, not its target
+	itemPath, err := sanitizeLinkPath(req.Path)
 	if err != nil {
 		return SendErrorMessage(c, 400, err.Error())
 	}
@@ -302,7 +547,7 @@ func (p *FileManagerPlugin) deleteItem(c *fiber.Ctx) error {
 	}
 
 	// Check if path exists
-	_, err = os.Stat(itemPath)
+	_, err = os.Lstat(itemPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return SendErrorMessage(c, 404, "Item not found")
@@ -310,6 +555,15 @@ func (p *FileManagerPlugin) deleteItem(c *fiber.Ctx) error {
 		return SendError(c, 500, err)
 	}
 
+	if p.trashEnabled() && !req.Permanent && !isInTrash(itemPath) {
+		item, err := moveToTrash(itemPath)
+		if err != nil {
+			return SendError(c, 500, err)
+		}
+		slog.InfoContext(c.UserContext(), "Item moved to trash", "path", itemPath, "id", item.ID, "trash", item.Trash)
+		return SendSuccess(c, item, "Moved to trash")
+	}
+
 	// Delete file or directory
 	if err := os.RemoveAll(itemPath); err != nil {
 		return SendError(c, 500, err)
@@ -354,13 +608,11 @@ func (p *FileManagerPlugin) createFolder(c *fiber.Ctx) error {
 // Register the plugin
 func init() {
 	Register("filemanager", func(config interface{}) (Plugin, error) {
-		configMap, ok := config.(map[string]interface{})
-		if !ok {
-			return nil, fmt.Errorf("invalid config for filemanager plugin: expected map[string]interface{}")
+		cfg, err := configAs[FileManagerConfig]("filemanager", config)
+		if err != nil {
+			return nil, err
 		}
 
-		maxUploadSize, _ := configMap["max_upload_size"].(int64)
-
-		return NewFileManagerPlugin(maxUploadSize)
+		return NewFileManagerPlugin(cfg)
 	})
 }
@@ -0,0 +1,295 @@
+package plugins
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Upload deduplication defaults
+const (
+	DefaultDedupIndex   = "/var/lib/linht/dedup.json"
+	DefaultDedupMinSize = 1024 * 1024 // 1MB, smaller files are stored as uploaded
+	dedupAlgorithm      = "sha256"
+)
+
+// Upload deduplication outcomes
+const (
+	DedupLinked  = "linked"  // stored as a hard link to an identical file
+	DedupSkipped = "skipped" // overwrite of an identical file, left untouched
+)
+
+// dedupEntry is an indexed file; the stat fields tell whether it changed since
+type dedupEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	Dev     uint64    `json:"dev"`
+	Ino     uint64    `json:"ino"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// DedupTotals counts deduplicated uploads since the index was created
+type DedupTotals struct {
+	Linked     int64 `json:"linked"`
+	Skipped    int64 `json:"skipped"`
+	SavedBytes int64 `json:"saved_bytes"` // bytes not written to flash
+}
+
+// dedupIndex is persisted in filemanager.dedup_index
+type dedupIndex struct {
+	Files  map[string][]dedupEntry `json:"files"` // content hash -> files with that content
+	Totals DedupTotals             `json:"totals"`
+}
+
+// DedupStats describes the index and the space it saves
+type DedupStats struct {
+	Enabled     bool        `json:"enabled"`
+	MinSize     int64       `json:"min_size"`
+	Files       int         `json:"files"`        // indexed files still in place
+	Contents    int         `json:"contents"`     // distinct contents among them
+	SharedFiles int         `json:"shared_files"` // files sharing their data with another indexed file
+	SavedBytes  int64       `json:"saved_bytes"`  // space the shared files save right now
+	Totals      DedupTotals `json:"totals"`
+}
+
+// uploadDedup stores uploads with content identical to an earlier one as hard links
+// Hard links share their data: editing one of the files in place changes all
+// of them. The file manager itself always replaces files, never edits them.
+type uploadDedup struct {
+	mu     sync.Mutex
+	path   string
+	index  *dedupIndex
+	loaded string // path the index was loaded from
+}
+
+// statEntry returns the index entry describing a file
+func statEntry(path string) (dedupEntry, bool) {
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return dedupEntry{}, false
+	}
+	entry := dedupEntry{Path: path, Size: info.Size(), ModTime: info.ModTime()}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		entry.Dev = uint64(st.Dev)
+		entry.Ino = st.Ino
+	}
+	return entry, true
+}
+
+// unchanged reports whether a file still is the one that was indexed
+func (e dedupEntry) unchanged() bool {
+	current, ok := statEntry(e.Path)
+	return ok && current.Size == e.Size && current.Dev == e.Dev && current.Ino == e.Ino && current.ModTime.Equal(e.ModTime)
+}
+
+// load reads the index once; called with d.mu held
+func (d *uploadDedup) load() *dedupIndex {
+	if d.index != nil && d.loaded == d.path {
+		return d.index
+	}
+	d.index = &dedupIndex{Files: make(map[string][]dedupEntry)}
+	d.loaded = d.path
+
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Failed to read dedup index", "file", d.path, "error", err)
+		}
+		return d.index
+	}
+	if err := json.Unmarshal(data, d.index); err != nil {
+		slog.Warn("Ignoring invalid dedup index", "file", d.path, "error", err)
+		d.index = &dedupIndex{Files: make(map[string][]dedupEntry)}
+	}
+	if d.index.Files == nil {
+		d.index.Files = make(map[string][]dedupEntry)
+	}
+	return d.index
+}
+
+// save writes the index; called with d.mu held
+func (d *uploadDedup) save() {
+	data, _ := json.Marshal(d.index)
+	err := os.MkdirAll(filepath.Dir(d.path), 0755)
+	if err == nil {
+		err = writeFileAtomic(d.path, data)
+	}
+	if err != nil {
+		slog.Warn("Failed to save dedup index", "file", d.path, "error", err)
+	}
+}
+
+// live returns the files with the given content that are still unchanged
+// and drops the others from the index; called with d.mu held
+func (d *uploadDedup) live(sum string) []dedupEntry {
+	var entries []dedupEntry
+	for _, entry := range d.index.Files[sum] {
+		if entry.unchanged() {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		delete(d.index.Files, sum)
+	} else {
+		d.index.Files[sum] = entries
+	}
+	return entries
+}
+
+// add indexes a file under its content hash, replacing an older entry for the path
+// Called with d.mu held.
+func (d *uploadDedup) add(sum, path string) {
+	for other, entries := range d.index.Files {
+		kept := entries[:0]
+		for _, entry := range entries {
+			if entry.Path != path {
+				kept = append(kept, entry)
+			}
+		}
+		if len(kept) == 0 {
+			delete(d.index.Files, other)
+		} else {
+			d.index.Files[other] = kept
+		}
+	}
+	if entry, ok := statEntry(path); ok {
+		d.index.Files[sum] = append(d.index.Files[sum], entry)
+	}
+}
+
+// commit moves an uploaded temporary file to its destination like commitFile,
+// unless an identical file makes writing it unnecessary
+// Returns DedupSkipped, DedupLinked or "" when the upload was stored as is.
+func (d *uploadDedup) commit(tmpPath, destFile string, overwrite bool) (string, error) {
+	result, err := hashFile(tmpPath, dedupAlgorithm, nil)
+	if err != nil {
+		return "", err
+	}
+	sum := result.Hash
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.load()
+	candidates := d.live(sum)
+	tmp, _ := statEntry(tmpPath)
+
+	// Overwriting a file with the same content changes nothing
+	if overwrite {
+		if dest, ok := statEntry(destFile); ok && dest.Size == result.Size && d.sameContent(sum, dest, candidates) {
+			d.add(sum, destFile)
+			d.index.Totals.Skipped++
+			d.index.Totals.SavedBytes += result.Size
+			d.save()
+			return DedupSkipped, nil
+		}
+	}
+
+	outcome := ""
+	for _, candidate := range candidates {
+		if candidate.Dev != tmp.Dev || candidate.Path == destFile {
+			continue
+		}
+		// Linking next to the upload keeps commitFile's overwrite rules
+		linkPath := tmpPath + ".link"
+		if err := os.Link(candidate.Path, linkPath); err != nil {
+			continue
+		}
+		if err := commitFile(linkPath, destFile, overwrite); err != nil {
+			os.Remove(linkPath)
+			return "", err
+		}
+		outcome = DedupLinked
+		d.index.Totals.Linked++
+		d.index.Totals.SavedBytes += result.Size
+		break
+	}
+	if outcome == "" {
+		if err := commitFile(tmpPath, destFile, overwrite); err != nil {
+			return "", err
+		}
+	}
+
+	d.add(sum, destFile)
+	d.save()
+	return outcome, nil
+}
+
+// sameContent reports whether an existing file has the given content
+// Files not in the index are hashed, which only reads the flash.
+func (d *uploadDedup) sameContent(sum string, file dedupEntry, candidates []dedupEntry) bool {
+	for _, candidate := range candidates {
+		if candidate.Path == file.Path || (candidate.Dev == file.Dev && candidate.Ino == file.Ino) {
+			return true
+		}
+	}
+	result, err := hashFile(file.Path, dedupAlgorithm, nil)
+	return err == nil && result.Hash == sum
+}
+
+// stats summarizes the index
+func (d *uploadDedup) stats() DedupStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.load()
+
+	var stats DedupStats
+	for sum := range d.index.Files {
+		entries := d.live(sum)
+		if len(entries) == 0 {
+			continue
+		}
+		stats.Contents++
+		stats.Files += len(entries)
+
+		// Every additional name of an inode is space saved
+		inodes := make(map[[2]uint64]bool)
+		for _, entry := range entries {
+			key := [2]uint64{entry.Dev, entry.Ino}
+			if inodes[key] {
+				stats.SharedFiles++
+				stats.SavedBytes += entry.Size
+			}
+			inodes[key] = true
+		}
+	}
+	stats.Totals = d.index.Totals
+	return stats
+}
+
+// setPath switches to another index file, which is read on next use
+func (d *uploadDedup) setPath(path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.path = path
+}
+
+// dedupSettings returns the deduplication state of the plugin
+func (p *FileManagerPlugin) dedupSettings() (*uploadDedup, bool, int64) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.dedup, p.dedupEnabled, p.dedupMinSize
+}
+
+// dedupStats handles GET /api/filemanager/dedup
+func (p *FileManagerPlugin) dedupStats(c *fiber.Ctx) error {
+	dedup, enabled, minSize := p.dedupSettings()
+	stats := dedup.stats()
+	stats.Enabled = enabled
+	stats.MinSize = minSize
+	return SendSuccess(c, stats, "")
+}
+
+// commitUpload stores an upload, deduplicating it when enabled and large enough
+func (p *FileManagerPlugin) commitUpload(tmpPath, destFile string, size int64, overwrite bool) (string, error) {
+	dedup, enabled, minSize := p.dedupSettings()
+	if !enabled || size < minSize {
+		return "", commitFile(tmpPath, destFile, overwrite)
+	}
+	return dedup.commit(tmpPath, destFile, overwrite)
+}
@@ -0,0 +1,249 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Fetch constants
+const (
+	FetchTimeout          = 60 * time.Minute
+	fetchProgressInterval = 500 * time.Millisecond
+	fetchBufferSize       = 256 * 1024
+)
+
+// FetchRequest describes a download onto the device
+type FetchRequest struct {
+	URL       string `json:"url"`
+	Path      string `json:"path"`     // destination directory
+	Filename  string `json:"filename"` // defaults to the name from the response or URL
+	Overwrite bool   `json:"overwrite"`
+}
+
+// FetchProgress reports download progress
+// Total is -1 when the server does not send a content length
+type FetchProgress struct {
+	Bytes   int64   `json:"bytes"`
+	Total   int64   `json:"total"`
+	Percent float64 `json:"percent,omitempty"`
+}
+
+// FetchResult is the outcome of a completed download
+type FetchResult struct {
+	URL      string `json:"url"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Duration string `json:"duration"`
+}
+
+// fetchFile downloads a URL into dir, writing to a temporary file that is renamed on success
+// progress returning false aborts the download
+func fetchFile(ctx context.Context, req FetchRequest, dir string, maxSize int64, progress func(FetchProgress) bool) (FetchResult, error) {
+	start := time.Now()
+	result := FetchResult{URL: req.URL}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.URL, nil)
+	if err != nil {
+		return result, err
+	}
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, fmt.Errorf("server returned %s", resp.Status)
+	}
+	if resp.ContentLength > maxSize {
+		return result, fmt.Errorf("file too large (%d bytes, max %d bytes)", resp.ContentLength, maxSize)
+	}
+
+	filename := req.Filename
+	if filename == "" {
+		filename = fetchFilename(resp)
+	}
+	filename = filepath.Base(filename)
+	if filename == "" || filename == "." || filename == ".." || filename == "/" {
+		return result, fmt.Errorf("cannot determine a filename, please provide one")
+	}
+
+	destFile := filepath.Join(dir, filename)
+	if _, err := os.Stat(destFile); err == nil && !req.Overwrite {
+		return result, fmt.Errorf("%s already exists", destFile)
+	}
+	result.Path = destFile
+
+	tmp, err := os.CreateTemp(dir, "."+filename+".part-*")
+	if err != nil {
+		return result, err
+	}
+	tmpPath := tmp.Name()
+	// CreateTemp uses 0600; match the permissions of regular uploads
+	tmp.Chmod(0644)
+	defer func() {
+		if tmp != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	buf := make([]byte, fetchBufferSize)
+	var done int64
+	lastReport := time.Now()
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			done += int64(n)
+			if done > maxSize {
+				return result, fmt.Errorf("file too large (max %d bytes)", maxSize)
+			}
+			if _, err := tmp.Write(buf[:n]); err != nil {
+				return result, err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return result, readErr
+		}
+
+		if progress != nil && time.Since(lastReport) >= fetchProgressInterval {
+			lastReport = time.Now()
+			if !progress(newFetchProgress(done, resp.ContentLength)) {
+				return result, fmt.Errorf("download cancelled")
+			}
+		}
+	}
+
+	if err := tmp.Close(); err != nil {
+		return result, err
+	}
+	if err := commitFile(tmpPath, destFile, req.Overwrite); err != nil {
+		return result, err
+	}
+	tmp = nil
+
+	result.Size = done
+	result.Duration = time.Since(start).String()
+	return result, nil
+}
+
+// newFetchProgress builds a progress report
+func newFetchProgress(done, total int64) FetchProgress {
+	progress := FetchProgress{Bytes: done, Total: total}
+	if total > 0 {
+		progress.Percent = float64(done) * 100 / float64(total)
+	}
+	return progress
+}
+
+// fetchFilename picks a filename from Content-Disposition or the final URL path
+func fetchFilename(resp *http.Response) string {
+	if disposition := resp.Header.Get("Content-Disposition"); disposition != "" {
+		if _, params, err := mime.ParseMediaType(disposition); err == nil && params["filename"] != "" {
+			return params["filename"]
+		}
+	}
+	return path.Base(resp.Request.URL.Path)
+}
+
+// fetchItem handles POST /api/filemanager/fetch?stream=false&async=false
+// Downloads a file from an HTTP(S) URL into a directory on the device
+// Streams progress via SSE when stream=true, otherwise runs as a job
+func (p *FileManagerPlugin) fetchItem(c *fiber.Ctx) error {
+	var req FetchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+
+	parsed, err := url.Parse(req.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return SendErrorMessage(c, 400, "A valid http or https URL is required")
+	}
+	if req.Path == "" {
+		return SendErrorMessage(c, 400, "Destination path required")
+	}
+
+	dirPath, err := sanitizePath(req.Path)
+	if err != nil {
+		return SendErrorMessage(c, 400, err.Error())
+	}
+	info, err := os.Stat(dirPath)
+	if err != nil {
+		return SendErrorMessage(c, 400, "Destination path does not exist")
+	}
+	if !info.IsDir() {
+		return SendErrorMessage(c, 400, "Destination path is not a directory")
+	}
+
+	maxSize := p.getMaxUploadSize()
+	requestCtx := c.UserContext()
+	slog.InfoContext(requestCtx, "File fetch started",
+		"url", req.URL,
+		"destination", dirPath,
+		"max_size", maxSize)
+
+	if !c.QueryBool("stream") {
+		return runJob(c, "filemanager.fetch", "Fetch "+req.URL, func(ctx context.Context, job *JobHandle) (interface{}, error) {
+			ctx, cancel := context.WithTimeout(ctx, FetchTimeout)
+			defer cancel()
+
+			result, err := fetchFile(ctx, req, dirPath, maxSize, func(progress FetchProgress) bool {
+				job.Progress(progress.Bytes, progress.Total, "bytes")
+				return true
+			})
+			if err != nil {
+				slog.ErrorContext(requestCtx, "File fetch failed", "url", req.URL, "error", err)
+				return nil, err
+			}
+			slog.InfoContext(requestCtx, "File fetch completed", "path", result.Path, "size", result.Size, "duration", result.Duration)
+			return result, nil
+		}, "File downloaded successfully")
+	}
+
+	// Set SSE headers
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithTimeout(context.Background(), FetchTimeout)
+		defer cancel()
+
+		result, err := fetchFile(ctx, req, dirPath, maxSize, func(progress FetchProgress) bool {
+			data, _ := json.Marshal(progress)
+			fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data)
+			return w.Flush() == nil
+		})
+		if err != nil {
+			slog.ErrorContext(requestCtx, "File fetch failed", "url", req.URL, "error", err)
+			data, _ := json.Marshal(fiber.Map{"error": err.Error()})
+			fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+			w.Flush()
+			return
+		}
+
+		slog.InfoContext(requestCtx, "File fetch completed", "path", result.Path, "size", result.Size, "duration", result.Duration)
+		data, _ := json.Marshal(result)
+		fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
+		w.Flush()
+	})
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package plugins
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Hashing constants
+const (
+	DefaultHashAlgorithm = "sha256"
+	hashBufferSize       = 256 * 1024
+	hashProgressInterval = 500 * time.Millisecond
+)
+
+// hashAlgorithms maps supported algorithm names to constructors
+var hashAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+// HashProgress reports hashing progress for large files
+type HashProgress struct {
+	Bytes   int64   `json:"bytes"`
+	Total   int64   `json:"total"`
+	Percent float64 `json:"percent"`
+}
+
+// HashResult is the final hashing result
+type HashResult struct {
+	Path      string `json:"path"`
+	Algorithm string `json:"algorithm"`
+	Hash      string `json:"hash"`
+	Size      int64  `json:"size"`
+	Duration  string `json:"duration"`
+}
+
+// hashFile computes the digest of a file, calling progress periodically
+// progress returning false aborts hashing
+func hashFile(path string, algorithm string, progress func(HashProgress) bool) (HashResult, error) {
+	start := time.Now()
+	result := HashResult{Path: path, Algorithm: algorithm}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return result, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return result, err
+	}
+	total := info.Size()
+
+	hasher := hashAlgorithms[algorithm]()
+	buf := make([]byte, hashBufferSize)
+	var done int64
+	lastReport := time.Now()
+
+	for {
+		n, readErr := file.Read(buf)
+		if n > 0 {
+			hasher.Write(buf[:n])
+			done += int64(n)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return result, readErr
+		}
+
+		if progress != nil && time.Since(lastReport) >= hashProgressInterval {
+			lastReport = time.Now()
+			if !progress(newHashProgress(done, total)) {
+				return result, fmt.Errorf("hashing cancelled")
+			}
+		}
+	}
+
+	result.Hash = hex.EncodeToString(hasher.Sum(nil))
+	result.Size = done
+	result.Duration = time.Since(start).String()
+	return result, nil
+}
+
+// newHashProgress builds a progress report
+func newHashProgress(done, total int64) HashProgress {
+	percent := 100.0
+	if total > 0 {
+		percent = float64(done) * 100 / float64(total)
+	}
+	return HashProgress{Bytes: done, Total: total, Percent: percent}
+}
+
+// hashItem handles GET /api/filemanager/hash?path=/path/to/file&algo=sha256&stream=false
+// Returns the digest, or streams progress via SSE when stream=true
+func (p *FileManagerPlugin) hashItem(c *fiber.Ctx) error {
+	pathParam := c.Query("path")
+	if pathParam == "" {
+		return SendErrorMessage(c, 400, "File path required")
+	}
+
+	filePath, err := sanitizePath(pathParam)
+	if err != nil {
+		return SendErrorMessage(c, 400, err.Error())
+	}
+
+	algorithm := strings.ToLower(c.Query("algo", DefaultHashAlgorithm))
+	if _, ok := hashAlgorithms[algorithm]; !ok {
+		return SendErrorMessage(c, 400, "Unsupported algorithm. Use md5, sha1 or sha256")
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return SendErrorMessage(c, 404, "File not found")
+		}
+		return SendError(c, 500, err)
+	}
+	if !info.Mode().IsRegular() {
+		return SendErrorMessage(c, 400, "Path is not a regular file")
+	}
+
+	ctx := c.UserContext()
+	slog.InfoContext(ctx, "File hashing started",
+		"path", filePath,
+		"algorithm", algorithm,
+		"size", info.Size())
+
+	if !c.QueryBool("stream") {
+		hashCtx, cancel := RequestContext(c)
+		defer cancel()
+		result, err := hashFile(filePath, algorithm, func(HashProgress) bool {
+			return hashCtx.Err() == nil
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "File hashing failed", "path", filePath, "error", err)
+			return SendError(c, 500, err)
+		}
+		slog.InfoContext(ctx, "File hashing completed", "path", filePath, "duration", result.Duration)
+		return SendSuccess(c, result, "")
+	}
+
+	// Set SSE headers
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		result, err := hashFile(filePath, algorithm, func(progress HashProgress) bool {
+			data, _ := json.Marshal(progress)
+			fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data)
+			return w.Flush() == nil
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "File hashing failed", "path", filePath, "error", err)
+			data, _ := json.Marshal(fiber.Map{"error": err.Error()})
+			fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+			w.Flush()
+			return
+		}
+
+		slog.InfoContext(ctx, "File hashing completed", "path", filePath, "duration", result.Duration)
+		data, _ := json.Marshal(result)
+		fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
+		w.Flush()
+	})
+
+	return nil
+}
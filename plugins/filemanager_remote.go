@@ -0,0 +1,346 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RemoteInfo describes a configured remote without its credentials
+type RemoteInfo struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Location string `json:"location"` // user@host or endpoint/bucket
+	Path     string `json:"path,omitempty"`
+}
+
+// RemoteListing represents the contents of a directory on a remote
+type RemoteListing struct {
+	Remote string        `json:"remote"`
+	Path   string        `json:"path"`
+	Parent string        `json:"parent"`
+	Items  []RemoteEntry `json:"items"`
+}
+
+// RemoteCopyRequest copies files between the device and a remote
+// For uploads paths are local files and dir is a remote directory; for
+// downloads paths are remote files and dir is a local directory.
+type RemoteCopyRequest struct {
+	Paths     []string `json:"paths"`
+	Dir       string   `json:"dir"`
+	Overwrite bool     `json:"overwrite"`
+}
+
+// RemoteCopyResult is the outcome of a copy
+type RemoteCopyResult struct {
+	Remote string   `json:"remote"`
+	Files  []string `json:"files"` // destination paths
+	Bytes  int64    `json:"bytes"`
+}
+
+// remoteListSpec names the entry fields for sorting and filtering remote listings
+var remoteListSpec = listSpec[RemoteEntry]{
+	key: "name",
+	fields: map[string]func(RemoteEntry) interface{}{
+		"name":     func(entry RemoteEntry) interface{} { return entry.Name },
+		"size":     func(entry RemoteEntry) interface{} { return entry.Size },
+		"modified": func(entry RemoteEntry) interface{} { return entry.Modified },
+		"is_dir":   func(entry RemoteEntry) interface{} { return entry.IsDir },
+	},
+}
+
+// remotePath cleans a path on a remote, relative to its base path
+func remotePath(p string) (string, error) {
+	if err := validRemotePath(p); err != nil {
+		return "", err
+	}
+	return path.Clean("/" + p)[1:], nil
+}
+
+// remoteStat finds a file on a remote by listing its directory
+func remoteStat(ctx context.Context, store remoteStore, name string) (RemoteEntry, bool, error) {
+	entries, err := store.list(ctx, path.Dir(name))
+	if err != nil {
+		return RemoteEntry{}, false, err
+	}
+	for _, entry := range entries {
+		if entry.Name == path.Base(name) {
+			return entry, true, nil
+		}
+	}
+	return RemoteEntry{}, false, nil
+}
+
+// getRemote returns a configured remote by name
+func (p *FileManagerPlugin) getRemote(name string) (RemoteConfig, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return findRemote(p.remotes, name)
+}
+
+// listRemotes handles GET /api/filemanager/remotes
+func (p *FileManagerPlugin) listRemotes(c *fiber.Ctx) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	remotes := make([]RemoteInfo, 0, len(p.remotes))
+	for _, remote := range p.remotes {
+		info := RemoteInfo{Name: remote.Name, Type: remote.Type, Path: remote.Path}
+		if remote.Type == RemoteS3 {
+			info.Location = strings.TrimSuffix(remote.Endpoint, "/") + "/" + remote.Bucket
+		} else {
+			info.Location = remote.Host
+			if remote.User != "" {
+				info.Location = remote.User + "@" + remote.Host
+			}
+		}
+		remotes = append(remotes, info)
+	}
+	return SendSuccess(c, remotes, "")
+}
+
+// listRemoteDirectory handles GET /api/filemanager/remotes/:name/list?path=dir
+func (p *FileManagerPlugin) listRemoteDirectory(c *fiber.Ctx) error {
+	remote, ok := p.getRemote(c.Params("name"))
+	if !ok {
+		return SendErrorMessage(c, 404, fmt.Sprintf("Remote %s not found", c.Params("name")))
+	}
+	dir, err := remotePath(c.Query("path"))
+	if err != nil {
+		return SendErrorMessage(c, 400, err.Error())
+	}
+
+	ctx, cancel := RequestContext(c)
+	defer cancel()
+	entries, err := newRemoteStore(remote).list(ctx, dir)
+	if err != nil {
+		return SendError(c, 502, err)
+	}
+	if entries == nil {
+		entries = []RemoteEntry{}
+	}
+
+	items, meta, err := pageList(c, entries, remoteListSpec)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+	parent := ""
+	if dir != "" {
+		parent = path.Join("/", path.Dir(dir))
+	}
+	return SendList(c, RemoteListing{Remote: remote.Name, Path: "/" + dir, Parent: parent, Items: items}, meta)
+}
+
+// uploadToRemote handles POST /api/filemanager/remotes/:name/upload
+// Copies local files into a remote directory as a job; supports ?async=true
+func (p *FileManagerPlugin) uploadToRemote(c *fiber.Ctx) error {
+	remote, ok := p.getRemote(c.Params("name"))
+	if !ok {
+		return SendErrorMessage(c, 404, fmt.Sprintf("Remote %s not found", c.Params("name")))
+	}
+	var req RemoteCopyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	if len(req.Paths) == 0 {
+		return SendErrorMessage(c, 400, "Paths required")
+	}
+	dir, err := remotePath(req.Dir)
+	if err != nil {
+		return SendErrorMessage(c, 400, err.Error())
+	}
+
+	var sources []string
+	var total int64
+	for _, local := range req.Paths {
+		src, err := sanitizePath(local)
+		if err != nil {
+			return SendErrorMessage(c, 400, err.Error())
+		}
+		info, err := os.Stat(src)
+		if err != nil {
+			return SendErrorMessage(c, 404, fmt.Sprintf("%s not found", local))
+		}
+		if !info.Mode().IsRegular() {
+			return SendErrorMessage(c, 400, fmt.Sprintf("%s is not a file", local))
+		}
+		if err := validRemotePath(filepath.Base(src)); err != nil {
+			return SendErrorMessage(c, 400, err.Error())
+		}
+		sources = append(sources, src)
+		total += info.Size()
+	}
+
+	requestCtx := c.UserContext()
+	description := fmt.Sprintf("Upload %d files to %s:/%s", len(sources), remote.Name, dir)
+	return runJob(c, "filemanager.remote.upload", description, func(ctx context.Context, job *JobHandle) (interface{}, error) {
+		store := newRemoteStore(remote)
+		if !req.Overwrite {
+			existing, err := store.list(ctx, dir)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range existing {
+				for _, src := range sources {
+					if entry.Name == filepath.Base(src) {
+						return nil, fiber.NewError(fiber.StatusConflict, fmt.Sprintf("%s:/%s: %s", remote.Name, path.Join(dir, entry.Name), ErrFileExists))
+					}
+				}
+			}
+		}
+
+		result := RemoteCopyResult{Remote: remote.Name, Files: []string{}}
+		t := &transfer{job: job, total: total}
+		for _, src := range sources {
+			name := path.Join(dir, filepath.Base(src))
+			job.Message(filepath.Base(src))
+			if err := store.put(ctx, src, name, t); err != nil {
+				slog.ErrorContext(requestCtx, "Remote upload failed", "remote", remote.Name, "path", src, "error", err)
+				return nil, err
+			}
+			result.Files = append(result.Files, "/"+name)
+		}
+		result.Bytes = t.done
+		slog.InfoContext(requestCtx, "Remote upload completed", "remote", remote.Name, "files", len(result.Files), "bytes", result.Bytes)
+		return result, nil
+	}, "Files uploaded")
+}
+
+// downloadFromRemote handles POST /api/filemanager/remotes/:name/download
+// Copies remote files into a local directory as a job; supports ?async=true
+func (p *FileManagerPlugin) downloadFromRemote(c *fiber.Ctx) error {
+	remote, ok := p.getRemote(c.Params("name"))
+	if !ok {
+		return SendErrorMessage(c, 404, fmt.Sprintf("Remote %s not found", c.Params("name")))
+	}
+	var req RemoteCopyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	if len(req.Paths) == 0 {
+		return SendErrorMessage(c, 400, "Paths required")
+	}
+	if req.Dir == "" {
+		return SendErrorMessage(c, 400, "Destination path required")
+	}
+	dirPath, err := sanitizePath(req.Dir)
+	if err != nil {
+		return SendErrorMessage(c, 400, err.Error())
+	}
+	info, err := os.Stat(dirPath)
+	if err != nil {
+		return SendErrorMessage(c, 400, "Destination path does not exist")
+	}
+	if !info.IsDir() {
+		return SendErrorMessage(c, 400, "Destination path is not a directory")
+	}
+
+	var names []string
+	for _, requested := range req.Paths {
+		name, err := remotePath(requested)
+		if err != nil {
+			return SendErrorMessage(c, 400, err.Error())
+		}
+		if name == "" {
+			return SendErrorMessage(c, 400, "A file path is required")
+		}
+		dest := filepath.Join(dirPath, path.Base(name))
+		if _, err := os.Lstat(dest); err == nil && !req.Overwrite {
+			return SendErrorMessage(c, 409, fmt.Sprintf("%s: %s", dest, ErrFileExists))
+		}
+		names = append(names, name)
+	}
+
+	requestCtx := c.UserContext()
+	description := fmt.Sprintf("Download %d files from %s to %s", len(names), remote.Name, dirPath)
+	return runJob(c, "filemanager.remote.download", description, func(ctx context.Context, job *JobHandle) (interface{}, error) {
+		store := newRemoteStore(remote)
+		t := &transfer{job: job}
+		for _, name := range names {
+			entry, found, err := remoteStat(ctx, store, name)
+			if err != nil {
+				return nil, err
+			}
+			if !found || entry.IsDir {
+				return nil, fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("%s:/%s is not a file", remote.Name, name))
+			}
+			t.total += entry.Size
+		}
+
+		result := RemoteCopyResult{Remote: remote.Name, Files: []string{}}
+		for _, name := range names {
+			dest := filepath.Join(dirPath, path.Base(name))
+			job.Message(path.Base(name))
+			if err := downloadRemoteFile(ctx, store, name, dest, req.Overwrite, t); err != nil {
+				slog.ErrorContext(requestCtx, "Remote download failed", "remote", remote.Name, "path", name, "error", err)
+				return nil, err
+			}
+			result.Files = append(result.Files, dest)
+		}
+		result.Bytes = t.done
+		slog.InfoContext(requestCtx, "Remote download completed", "remote", remote.Name, "files", len(result.Files), "bytes", result.Bytes)
+		return result, nil
+	}, "Files downloaded")
+}
+
+// downloadRemoteFile downloads to a temporary file that is renamed on success
+func downloadRemoteFile(ctx context.Context, store remoteStore, name, dest string, overwrite bool, t *transfer) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".part-*")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	// CreateTemp uses 0600; match the permissions of regular uploads
+	os.Chmod(tmp.Name(), 0644)
+
+	if err := store.get(ctx, name, tmp.Name(), t); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := commitFile(tmp.Name(), dest, overwrite); err != nil {
+		os.Remove(tmp.Name())
+		if errors.Is(err, ErrFileExists) {
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// deleteRemoteFile handles DELETE /api/filemanager/remotes/:name/delete?path=file
+func (p *FileManagerPlugin) deleteRemoteFile(c *fiber.Ctx) error {
+	remote, ok := p.getRemote(c.Params("name"))
+	if !ok {
+		return SendErrorMessage(c, 404, fmt.Sprintf("Remote %s not found", c.Params("name")))
+	}
+	name, err := remotePath(c.Query("path"))
+	if err != nil {
+		return SendErrorMessage(c, 400, err.Error())
+	}
+	if name == "" {
+		return SendErrorMessage(c, 400, "A file path is required")
+	}
+
+	ctx, cancel := RequestContext(c)
+	defer cancel()
+	store := newRemoteStore(remote)
+	entry, found, err := remoteStat(ctx, store, name)
+	if err != nil {
+		return SendError(c, 502, err)
+	}
+	if !found || entry.IsDir {
+		return SendErrorMessage(c, 404, "File not found")
+	}
+	if err := store.remove(ctx, name); err != nil {
+		return SendError(c, 502, err)
+	}
+	slog.InfoContext(c.UserContext(), "Remote file deleted", "remote", remote.Name, "path", name)
+	return SendSuccess(c, nil, "File deleted")
+}
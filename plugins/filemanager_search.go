@@ -0,0 +1,264 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Search limits
+const (
+	DefaultSearchLimit = 200
+	MaxSearchLimit     = 5000
+	DefaultSearchDepth = 10
+	SearchTimeout      = 30 * time.Second
+)
+
+// searchSkipDirs are pseudo filesystems that are never searched
+var searchSkipDirs = map[string]bool{
+	"/proc": true,
+	"/sys":  true,
+	"/dev":  true,
+}
+
+// errSearchLimit stops the walk once enough results are collected
+var errSearchLimit = errors.New("search limit reached")
+
+// SearchQuery holds the search filters
+type SearchQuery struct {
+	Root           string
+	Pattern        string // glob matched against the file name, e.g. *.sigmf-data
+	Name           string // case-insensitive substring of the file name
+	MinSize        int64
+	MaxSize        int64 // 0 means unlimited
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+	MaxDepth       int
+	Limit          int
+	IncludeDirs    bool
+}
+
+// SearchResult is the outcome of a search
+type SearchResult struct {
+	Root      string     `json:"root"`
+	Items     []FileItem `json:"items"`
+	Scanned   int        `json:"scanned"`
+	Truncated bool       `json:"truncated"`
+	TimedOut  bool       `json:"timed_out"`
+	Duration  string     `json:"duration"`
+}
+
+// matches reports whether an entry satisfies the filters
+func (q *SearchQuery) matches(name string, info fs.FileInfo) bool {
+	if info.IsDir() && !q.IncludeDirs {
+		return false
+	}
+	if q.Pattern != "" {
+		if ok, _ := filepath.Match(q.Pattern, name); !ok {
+			return false
+		}
+	}
+	if q.Name != "" && !strings.Contains(strings.ToLower(name), strings.ToLower(q.Name)) {
+		return false
+	}
+	if !info.IsDir() {
+		if info.Size() < q.MinSize || (q.MaxSize > 0 && info.Size() > q.MaxSize) {
+			return false
+		}
+	}
+	if !q.ModifiedAfter.IsZero() && info.ModTime().Before(q.ModifiedAfter) {
+		return false
+	}
+	if !q.ModifiedBefore.IsZero() && info.ModTime().After(q.ModifiedBefore) {
+		return false
+	}
+	return true
+}
+
+// searchFiles walks the tree below the query root until the limit, depth or context ends it
+func searchFiles(ctx context.Context, q SearchQuery) (SearchResult, error) {
+	start := time.Now()
+	result := SearchResult{Root: q.Root, Items: []FileItem{}}
+	rootDepth := strings.Count(q.Root, string(os.PathSeparator))
+	if q.Root == "/" {
+		rootDepth = 0
+	}
+
+	err := filepath.WalkDir(q.Root, func(path string, entry fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			// Unreadable directories are skipped rather than failing the search
+			if entry != nil && entry.IsDir() && path != q.Root {
+				return fs.SkipDir
+			}
+			if path == q.Root {
+				return err
+			}
+			return nil
+		}
+		if path == q.Root {
+			return nil
+		}
+
+		if entry.IsDir() && searchSkipDirs[path] {
+			return fs.SkipDir
+		}
+
+		result.Scanned++
+		if info, err := entry.Info(); err == nil && q.matches(entry.Name(), info) {
+			if len(result.Items) >= q.Limit {
+				result.Truncated = true
+				return errSearchLimit
+			}
+			result.Items = append(result.Items, FileItem{
+				Name:     entry.Name(),
+				Path:     path,
+				IsDir:    entry.IsDir(),
+				Size:     info.Size(),
+				Modified: info.ModTime(),
+			})
+		}
+
+		// Entries directly below the root are at depth 1
+		if entry.IsDir() && strings.Count(path, string(os.PathSeparator))-rootDepth >= q.MaxDepth {
+			return fs.SkipDir
+		}
+		return nil
+	})
+
+	result.Duration = time.Since(start).String()
+	switch {
+	case errors.Is(err, errSearchLimit):
+		err = nil
+	case errors.Is(err, context.DeadlineExceeded):
+		result.TimedOut = true
+		err = nil
+	}
+	return result, err
+}
+
+// parseSearchQuery reads the search filters from the request
+func parseSearchQuery(c *fiber.Ctx) (SearchQuery, error) {
+	root, err := sanitizePath(c.Query("path", "/"))
+	if err != nil {
+		return SearchQuery{}, err
+	}
+
+	q := SearchQuery{
+		Root:        root,
+		Pattern:     c.Query("pattern"),
+		Name:        c.Query("name"),
+		MaxDepth:    c.QueryInt("max_depth", DefaultSearchDepth),
+		Limit:       c.QueryInt("limit", DefaultSearchLimit),
+		IncludeDirs: c.QueryBool("include_dirs"),
+	}
+
+	if q.Pattern == "" && q.Name == "" {
+		return q, fmt.Errorf("pattern or name is required")
+	}
+	if q.Pattern != "" {
+		if _, err := filepath.Match(q.Pattern, ""); err != nil {
+			return q, fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+	if q.MaxDepth < 1 {
+		return q, fmt.Errorf("max_depth must be at least 1")
+	}
+	if q.Limit < 1 || q.Limit > MaxSearchLimit {
+		return q, fmt.Errorf("limit must be between 1 and %d", MaxSearchLimit)
+	}
+
+	if q.MinSize, err = parseSearchInt(c.Query("min_size")); err != nil {
+		return q, fmt.Errorf("invalid min_size: %w", err)
+	}
+	if q.MaxSize, err = parseSearchInt(c.Query("max_size")); err != nil {
+		return q, fmt.Errorf("invalid max_size: %w", err)
+	}
+	if q.ModifiedAfter, err = parseSearchTime(c.Query("modified_after")); err != nil {
+		return q, fmt.Errorf("invalid modified_after: %w", err)
+	}
+	if q.ModifiedBefore, err = parseSearchTime(c.Query("modified_before")); err != nil {
+		return q, fmt.Errorf("invalid modified_before: %w", err)
+	}
+	return q, nil
+}
+
+// parseSearchInt parses an optional non-negative integer
+func parseSearchInt(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return n, nil
+}
+
+// parseSearchTime parses an optional RFC 3339 timestamp or Unix seconds
+func parseSearchTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// searchItems handles GET /api/filemanager/search?path=/&pattern=*.iq&name=&min_size=&max_size=
+// &modified_after=&modified_before=&max_depth=10&limit=200&include_dirs=false
+// The walk stops at the result limit or after SearchTimeout, whichever comes first
+func (p *FileManagerPlugin) searchItems(c *fiber.Ctx) error {
+	q, err := parseSearchQuery(c)
+	if err != nil {
+		return SendErrorMessage(c, 400, err.Error())
+	}
+
+	info, err := os.Stat(q.Root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return SendErrorMessage(c, 404, "Directory not found")
+		}
+		return SendError(c, 500, err)
+	}
+	if !info.IsDir() {
+		return SendErrorMessage(c, 400, "Path is not a directory")
+	}
+
+	requestCtx, cancelRequest := RequestContext(c)
+	defer cancelRequest()
+	ctx, cancel := context.WithTimeout(requestCtx, SearchTimeout)
+	defer cancel()
+
+	result, err := searchFiles(ctx, q)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+
+	slog.InfoContext(c.UserContext(), "File search completed",
+		"root", q.Root,
+		"pattern", q.Pattern,
+		"name", q.Name,
+		"matches", len(result.Items),
+		"scanned", result.Scanned,
+		"truncated", result.Truncated,
+		"timed_out", result.TimedOut,
+		"duration", result.Duration)
+
+	return SendSuccess(c, result, "")
+}
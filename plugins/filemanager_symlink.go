@@ -0,0 +1,192 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Symlink policies for filemanager.symlinks
+const (
+	SymlinksFollow   = "follow"   // follow links anywhere
+	SymlinksDeny     = "deny"     // refuse paths that pass through a link
+	SymlinksRestrict = "restrict" // follow links only when they resolve below symlink_roots
+)
+
+// ErrSymlinkDenied is returned when a path passes through a link the policy does not allow
+var ErrSymlinkDenied = errors.New("path passes through a symbolic link not allowed by the symlink policy")
+
+// symlinkPolicy decides how sanitizePath treats symbolic links
+type symlinkPolicy struct {
+	Mode  string
+	Roots []string
+}
+
+// Symlink policy shared by all file operations
+var (
+	symlinks   = symlinkPolicy{Mode: SymlinksFollow}
+	symlinksMu sync.RWMutex
+)
+
+// setSymlinkPolicy replaces the symlink policy
+func setSymlinkPolicy(policy symlinkPolicy) {
+	symlinksMu.Lock()
+	defer symlinksMu.Unlock()
+	symlinks = policy
+}
+
+// getSymlinkPolicy returns the current symlink policy
+func getSymlinkPolicy() symlinkPolicy {
+	symlinksMu.RLock()
+	defer symlinksMu.RUnlock()
+	return symlinks
+}
+
+// validateSymlinkPolicy checks the mode and roots
+func validateSymlinkPolicy(policy symlinkPolicy) error {
+	switch policy.Mode {
+	case SymlinksFollow, SymlinksDeny:
+	case SymlinksRestrict:
+		if len(policy.Roots) == 0 {
+			return fmt.Errorf("symlinks: restrict requires symlink_roots")
+		}
+	default:
+		return fmt.Errorf("symlinks must be follow, deny or restrict, got %q", policy.Mode)
+	}
+	for _, root := range policy.Roots {
+		if !filepath.IsAbs(root) {
+			return fmt.Errorf("symlink_roots: %q is not an absolute path", root)
+		}
+	}
+	return nil
+}
+
+// withinRoots reports whether a path is one of the roots or below one
+func (policy symlinkPolicy) withinRoots(path string) bool {
+	for _, root := range policy.Roots {
+		root = filepath.Clean(root)
+		if path == root || strings.HasPrefix(path, strings.TrimSuffix(root, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSymlinks applies the symlink policy to an absolute, clean path
+// Only the part of the path that exists is resolved
+func checkSymlinks(path string) error {
+	policy := getSymlinkPolicy()
+	if policy.Mode == SymlinksFollow {
+		return nil
+	}
+
+	existing := path
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return nil
+		}
+		existing = parent
+	}
+
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		// Only links can make an existing path unresolvable
+		return fmt.Errorf("invalid path: %w (broken symbolic link)", err)
+	}
+	if resolved == existing {
+		return nil
+	}
+	if policy.Mode == SymlinksRestrict && policy.withinRoots(resolved) {
+		return nil
+	}
+	return fmt.Errorf("invalid path: %w (%s resolves to %s)", ErrSymlinkDenied, existing, resolved)
+}
+
+// sanitizeLinkPath is sanitizePath for operations on a link itself (delete,
+// create): the last path element is not followed
+func sanitizeLinkPath(path string) (string, error) {
+	abs, err := cleanPath(path)
+	if err != nil {
+		return "", err
+	}
+	if err := checkSymlinks(filepath.Dir(abs)); err != nil {
+		return "", err
+	}
+	return abs, nil
+}
+
+// describeSymlink fills in a link's target and what it points to
+func describeSymlink(item *FileItem) {
+	item.IsSymlink = true
+	item.Target, _ = os.Readlink(item.Path)
+
+	info, err := os.Stat(item.Path)
+	if err != nil {
+		item.Broken = true
+		return
+	}
+	item.IsDir = info.IsDir()
+	item.Size = info.Size()
+}
+
+// createSymlink handles POST /api/filemanager/symlink
+// Creates a link at path pointing to target (absolute or relative to the link)
+func (p *FileManagerPlugin) createSymlink(c *fiber.Ctx) error {
+	var req struct {
+		Path   string `json:"path"`
+		Target string `json:"target"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	if req.Path == "" || req.Target == "" {
+		return SendErrorMessage(c, 400, "Path and target required")
+	}
+
+	linkPath, err := sanitizeLinkPath(req.Path)
+	if err != nil {
+		return SendErrorMessage(c, 400, err.Error())
+	}
+	if _, err := os.Lstat(linkPath); err == nil {
+		return SendErrorMessage(c, 409, "Path already exists")
+	}
+
+	// Links the policy would refuse to follow are not created
+	policy := getSymlinkPolicy()
+	switch policy.Mode {
+	case SymlinksDeny:
+		return SendErrorMessage(c, 403, "Symbolic links are disabled (filemanager.symlinks: deny)")
+	case SymlinksRestrict:
+		target := req.Target
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(linkPath), target)
+		}
+		target = filepath.Clean(target)
+		if resolved, err := filepath.EvalSymlinks(target); err == nil {
+			target = resolved
+		}
+		if !policy.withinRoots(target) {
+			return SendErrorMessage(c, 403, "Target is outside filemanager.symlink_roots")
+		}
+	}
+
+	if err := os.Symlink(req.Target, linkPath); err != nil {
+		return SendError(c, 500, err)
+	}
+
+	item := FileItem{Name: filepath.Base(linkPath), Path: linkPath}
+	if info, err := os.Lstat(linkPath); err == nil {
+		item.Modified = info.ModTime()
+	}
+	describeSymlink(&item)
+	return SendSuccess(c, item, "Symlink created")
+}
@@ -0,0 +1,307 @@
+package plugins
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Trash layout: <mount point>/.trash/<id>/{meta.json,data}
+const (
+	TrashDirName   = ".trash"
+	trashMetaFile  = "meta.json"
+	trashDataEntry = "data"
+)
+
+// TrashItem describes a deleted item held in a trash directory
+type TrashItem struct {
+	ID           string    `json:"id"`
+	OriginalPath string    `json:"original_path"`
+	IsDir        bool      `json:"isDir"`
+	Size         int64     `json:"size"`
+	DeletedAt    time.Time `json:"deleted_at"`
+	Trash        string    `json:"trash"`
+}
+
+// mountPointFor returns the mount point holding path, using the longest matching entry in /proc/self/mounts
+// Falls back to / when the mount table cannot be read
+func mountPointFor(path string) string {
+	file, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return "/"
+	}
+	defer file.Close()
+
+	best := "/"
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		mount := unescapeMountPath(fields[1])
+		if len(mount) <= len(best) {
+			continue
+		}
+		if path == mount || strings.HasPrefix(path, mount+"/") {
+			best = mount
+		}
+	}
+	return best
+}
+
+// unescapeMountPath decodes the octal escapes used in /proc/self/mounts (e.g. \040 for space)
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if v, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+// isInTrash reports whether path lies inside a trash directory
+func isInTrash(path string) bool {
+	for _, part := range strings.Split(path, string(os.PathSeparator)) {
+		if part == TrashDirName {
+			return true
+		}
+	}
+	return false
+}
+
+// newTrashID generates a unique trash entry ID
+func newTrashID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(buf), nil
+}
+
+// moveToTrash moves an item into the trash on its own filesystem
+// The move is a rename, so it is instant and never copies data across filesystems
+func moveToTrash(path string) (TrashItem, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return TrashItem{}, err
+	}
+
+	id, err := newTrashID()
+	if err != nil {
+		return TrashItem{}, err
+	}
+
+	mount := mountPointFor(path)
+	if mount == path {
+		return TrashItem{}, fmt.Errorf("cannot move mount point %s to trash", path)
+	}
+	trashDir := filepath.Join(mount, TrashDirName)
+	entryDir := filepath.Join(trashDir, id)
+	if err := os.MkdirAll(entryDir, 0700); err != nil {
+		return TrashItem{}, fmt.Errorf("failed to create trash entry: %w", err)
+	}
+
+	item := TrashItem{
+		ID:           id,
+		OriginalPath: path,
+		IsDir:        info.IsDir(),
+		Size:         info.Size(),
+		DeletedAt:    time.Now(),
+		Trash:        trashDir,
+	}
+	meta, _ := json.MarshalIndent(item, "", "  ")
+	if err := os.WriteFile(filepath.Join(entryDir, trashMetaFile), meta, 0600); err != nil {
+		os.RemoveAll(entryDir)
+		return TrashItem{}, fmt.Errorf("failed to write trash metadata: %w", err)
+	}
+
+	if err := os.Rename(path, filepath.Join(entryDir, trashDataEntry)); err != nil {
+		os.RemoveAll(entryDir)
+		if errors.Is(err, syscall.EXDEV) {
+			return TrashItem{}, fmt.Errorf("cannot move %s to trash across filesystems; delete permanently instead", path)
+		}
+		return TrashItem{}, fmt.Errorf("failed to move to trash: %w", err)
+	}
+
+	return item, nil
+}
+
+// trashDirs returns all existing trash directories, one per mounted filesystem
+func trashDirs() []string {
+	mounts := []string{"/"}
+	if file, err := os.Open("/proc/self/mounts"); err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if fields := strings.Fields(scanner.Text()); len(fields) >= 2 {
+				mounts = append(mounts, unescapeMountPath(fields[1]))
+			}
+		}
+		file.Close()
+	}
+
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, mount := range mounts {
+		dir := filepath.Join(mount, TrashDirName)
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// listTrash reads all trash entries, newest first
+func listTrash() []TrashItem {
+	items := []TrashItem{}
+	for _, dir := range trashDirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if item, err := readTrashItem(dir, entry.Name()); err == nil {
+				items = append(items, item)
+			}
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+	return items
+}
+
+// readTrashItem loads the metadata of a trash entry
+func readTrashItem(trashDir string, id string) (TrashItem, error) {
+	data, err := os.ReadFile(filepath.Join(trashDir, id, trashMetaFile))
+	if err != nil {
+		return TrashItem{}, err
+	}
+	var item TrashItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return TrashItem{}, err
+	}
+	item.ID = id
+	item.Trash = trashDir
+	return item, nil
+}
+
+// findTrashItem locates a trash entry by ID across all trash directories
+func findTrashItem(id string) (TrashItem, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		return TrashItem{}, fmt.Errorf("invalid trash id")
+	}
+	for _, dir := range trashDirs() {
+		if item, err := readTrashItem(dir, id); err == nil {
+			return item, nil
+		}
+	}
+	return TrashItem{}, os.ErrNotExist
+}
+
+// listTrashItems handles GET /api/filemanager/trash
+func (p *FileManagerPlugin) listTrashItems(c *fiber.Ctx) error {
+	return SendSuccess(c, listTrash(), "")
+}
+
+// restoreTrashItem handles POST /api/filemanager/trash/restore
+// Moves the item back to its original path, or to path when given
+func (p *FileManagerPlugin) restoreTrashItem(c *fiber.Ctx) error {
+	var req struct {
+		ID   string `json:"id"`
+		Path string `json:"path"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+
+	item, err := findTrashItem(req.ID)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return SendErrorMessage(c, 404, "Trash item not found")
+		}
+		return SendErrorMessage(c, 400, err.Error())
+	}
+
+	target := item.OriginalPath
+	if req.Path != "" {
+		if target, err = sanitizePath(req.Path); err != nil {
+			return SendErrorMessage(c, 400, err.Error())
+		}
+	}
+	if _, err := os.Lstat(target); err == nil {
+		return SendErrorMessage(c, 409, fmt.Sprintf("%s already exists; restore to a different path", target))
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return SendError(c, 500, err)
+	}
+
+	entryDir := filepath.Join(item.Trash, item.ID)
+	if err := os.Rename(filepath.Join(entryDir, trashDataEntry), target); err != nil {
+		if errors.Is(err, syscall.EXDEV) {
+			return SendErrorMessage(c, 400, "Restore path must be on the same filesystem as the trash")
+		}
+		return SendError(c, 500, err)
+	}
+	os.RemoveAll(entryDir)
+
+	slog.InfoContext(c.UserContext(), "Trash item restored", "id", item.ID, "path", target)
+	return SendSuccess(c, fiber.Map{"path": target}, "Restored successfully")
+}
+
+// purgeTrash handles DELETE /api/filemanager/trash?id=...
+// Permanently removes one entry, or every entry when no ID is given
+func (p *FileManagerPlugin) purgeTrash(c *fiber.Ctx) error {
+	id := c.Query("id")
+	if id != "" {
+		item, err := findTrashItem(id)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return SendErrorMessage(c, 404, "Trash item not found")
+			}
+			return SendErrorMessage(c, 400, err.Error())
+		}
+		if err := os.RemoveAll(filepath.Join(item.Trash, item.ID)); err != nil {
+			return SendError(c, 500, err)
+		}
+		slog.InfoContext(c.UserContext(), "Trash item purged", "id", id, "original_path", item.OriginalPath)
+		return SendSuccess(c, nil, "Purged successfully")
+	}
+
+	purged := 0
+	for _, item := range listTrash() {
+		if err := os.RemoveAll(filepath.Join(item.Trash, item.ID)); err != nil {
+			slog.ErrorContext(c.UserContext(), "Failed to purge trash item", "id", item.ID, "error", err)
+			continue
+		}
+		purged++
+	}
+	slog.InfoContext(c.UserContext(), "Trash emptied", "purged", purged)
+	return SendSuccess(c, fiber.Map{"purged": purged}, "Trash emptied")
+}
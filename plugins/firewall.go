@@ -0,0 +1,355 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Firewall defaults
+const (
+	DefaultFirewallRulesFile      = "/var/lib/linht/firewall.json"
+	DefaultFirewallTable          = "linht"
+	DefaultFirewallConfirmTimeout = 60  // seconds
+	MaxFirewallConfirmTimeout     = 600 // seconds
+	firewallEventSource           = "firewall"
+)
+
+// firewallTablePattern restricts the managed table name
+var firewallTablePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,31}$`)
+
+// errNoPendingFirewall is returned when there is nothing to confirm or roll back
+var errNoPendingFirewall = errors.New("no firewall change is waiting for confirmation")
+
+// FirewallConfig holds firewall plugin configuration
+type FirewallConfig struct {
+	RulesFile      string `yaml:"rules_file"`      // confirmed ruleset, applied when the manager starts
+	Table          string `yaml:"table"`           // nftables table (family inet) owned by the plugin
+	ConfirmTimeout int    `yaml:"confirm_timeout"` // seconds before an unconfirmed change is rolled back
+
+	// Set from the server section
+	ManagerPort int `yaml:"-"`
+}
+
+// FirewallStatus describes the applied ruleset and a change waiting for confirmation
+type FirewallStatus struct {
+	Active      FirewallRuleset  `json:"active"`
+	Confirmed   *FirewallRuleset `json:"confirmed"` // nil while the table was never managed
+	Pending     bool             `json:"pending"`
+	Deadline    *time.Time       `json:"deadline,omitempty"` // pending change is rolled back at this time
+	ManagerPort int              `json:"manager_port"`
+	Script      string           `json:"script"`
+}
+
+// FirewallPlugin manages an nftables table with apply and confirm semantics
+// A change is applied at once but rolled back unless confirmed in time, so a
+// rule that locks the operator out undoes itself.
+type FirewallPlugin struct {
+	mu        sync.Mutex
+	config    FirewallConfig
+	confirmed *FirewallRuleset
+	pending   *FirewallRuleset
+	deadline  time.Time
+	timer     *time.Timer
+	started   bool
+}
+
+// NewFirewallPlugin creates a new firewall plugin instance
+// The confirmed ruleset is read here and applied by Start.
+func NewFirewallPlugin(cfg FirewallConfig) (*FirewallPlugin, error) {
+	cfg = normalizeFirewallConfig(cfg)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	p := &FirewallPlugin{config: cfg}
+	if err := p.loadRules(); err != nil {
+		slog.Warn("Failed to load firewall rules", "file", cfg.RulesFile, "error", err)
+	}
+	return p, nil
+}
+
+// Start applies the confirmed ruleset; changes are accepted from then on
+func (p *FirewallPlugin) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.started = true
+	if p.confirmed == nil {
+		return nil
+	}
+	if err := runNft(renderNftables(p.config.Table, *p.confirmed), false); err != nil {
+		slog.Error("Failed to apply firewall rules", "error", err)
+		return nil
+	}
+	slog.Info("Firewall rules applied", "table", p.config.Table, "enabled", p.confirmed.Enabled, "rules", len(p.confirmed.Rules))
+	return nil
+}
+
+// Name returns the plugin identifier
+func (p *FirewallPlugin) Name() string {
+	return "firewall"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *FirewallPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/firewall")
+
+	api.Get("/", p.handleStatus)
+	api.Put("/", p.handleApply)
+	api.Post("/confirm", p.handleConfirm)
+	api.Post("/rollback", p.handleRollback)
+}
+
+// Shutdown rolls back an unconfirmed change, which nobody could confirm anymore
+func (p *FirewallPlugin) Shutdown() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending != nil {
+		slog.Warn("Rolling back unconfirmed firewall change on shutdown")
+		return p.rollback("shutdown")
+	}
+	return nil
+}
+
+// Reload applies a new confirm timeout and rules file
+// The table name is fixed while the plugin runs, so the old table is never orphaned.
+func (p *FirewallPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[FirewallConfig]("firewall", config)
+	if err != nil {
+		return err
+	}
+	cfg = normalizeFirewallConfig(cfg)
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	cfg.Table = p.config.Table
+	p.config = cfg
+	p.mu.Unlock()
+
+	slog.Info("Firewall config reloaded", "confirm_timeout", cfg.ConfirmTimeout)
+	return nil
+}
+
+// Validate checks the table name and confirm timeout
+func (cfg FirewallConfig) Validate() error {
+	if cfg.Table != "" && !firewallTablePattern.MatchString(cfg.Table) {
+		return fmt.Errorf("invalid firewall.table %q", cfg.Table)
+	}
+	if cfg.ConfirmTimeout < 0 || cfg.ConfirmTimeout > MaxFirewallConfirmTimeout {
+		return fmt.Errorf("firewall.confirm_timeout must be between 1 and %d seconds", MaxFirewallConfirmTimeout)
+	}
+	return nil
+}
+
+// loadRules reads the confirmed ruleset; a missing file leaves nftables untouched
+func (p *FirewallPlugin) loadRules() error {
+	data, err := os.ReadFile(p.config.RulesFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var rules FirewallRuleset
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("invalid firewall rules file: %w", err)
+	}
+	if err := rules.normalize(); err != nil {
+		return fmt.Errorf("invalid firewall rules file: %w", err)
+	}
+	p.confirmed = &rules
+	return nil
+}
+
+// status returns the applied ruleset; called with p.mu held
+func (p *FirewallPlugin) status() FirewallStatus {
+	status := FirewallStatus{
+		Active:      FirewallRuleset{Rules: []FirewallRule{}},
+		Confirmed:   p.confirmed,
+		Pending:     p.pending != nil,
+		ManagerPort: p.config.ManagerPort,
+	}
+	if p.pending != nil {
+		status.Active = *p.pending
+		deadline := p.deadline
+		status.Deadline = &deadline
+	} else if p.confirmed != nil {
+		status.Active = *p.confirmed
+	}
+	status.Script = renderNftables(p.config.Table, status.Active)
+	return status
+}
+
+// rollback restores the confirmed ruleset, or removes the table when there is none
+// Called with p.mu held.
+func (p *FirewallPlugin) rollback(reason string) error {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.pending = nil
+
+	previous := FirewallRuleset{}
+	if p.confirmed != nil {
+		previous = *p.confirmed
+	}
+	err := runNft(renderNftables(p.config.Table, previous), false)
+
+	event := fiber.Map{"reason": reason, "success": err == nil}
+	if err != nil {
+		event["error"] = err.Error()
+		slog.Error("Firewall rollback failed", "reason", reason, "error", err)
+	} else {
+		slog.Warn("Firewall change rolled back", "reason", reason)
+	}
+	PublishEvent("firewall.rolled_back", firewallEventSource, event)
+	return err
+}
+
+// expire rolls back the given change if it is still unconfirmed
+func (p *FirewallPlugin) expire(change *FirewallRuleset) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending == change {
+		p.rollback("not confirmed in time")
+	}
+}
+
+// handleStatus handles GET /api/firewall
+func (p *FirewallPlugin) handleStatus(c *fiber.Ctx) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return SendSuccess(c, p.status(), "")
+}
+
+// handleApply handles PUT /api/firewall
+// Applies the ruleset in the body at once; it must be confirmed within
+// ?timeout= seconds (default firewall.confirm_timeout) or it is rolled back.
+// A new change before the confirmation replaces the pending one; the rollback
+// still returns to the last confirmed ruleset.
+func (p *FirewallPlugin) handleApply(c *fiber.Ctx) error {
+	var rules FirewallRuleset
+	if err := c.BodyParser(&rules); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	if err := rules.normalize(); err != nil {
+		return SendError(c, 400, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.started {
+		return SendErrorMessage(c, 503, "The firewall plugin is not started yet")
+	}
+	timeout := c.QueryInt("timeout", p.config.ConfirmTimeout)
+	if timeout < 1 || timeout > MaxFirewallConfirmTimeout {
+		return SendErrorMessage(c, 400, fmt.Sprintf("timeout must be between 1 and %d seconds", MaxFirewallConfirmTimeout))
+	}
+	if rules.Enabled && p.config.ManagerPort > 0 && !rules.allowsPort(p.config.ManagerPort) {
+		return SendErrorMessage(c, 400, fmt.Sprintf("The ruleset has no host rule for the manager port %d/tcp", p.config.ManagerPort))
+	}
+
+	script := renderNftables(p.config.Table, rules)
+	if err := runNft(script, true); err != nil {
+		return SendError(c, 400, err)
+	}
+	if err := runNft(script, false); err != nil {
+		return SendError(c, 500, err)
+	}
+
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	change := &rules
+	p.pending = change
+	p.deadline = time.Now().Add(time.Duration(timeout) * time.Second)
+	p.timer = time.AfterFunc(time.Duration(timeout)*time.Second, func() { p.expire(change) })
+
+	slog.WarnContext(c.UserContext(), "Firewall change applied, waiting for confirmation",
+		"enabled", rules.Enabled,
+		"rules", len(rules.Rules),
+		"timeout", timeout,
+		"client", c.IP())
+	PublishEvent("firewall.applied", firewallEventSource, fiber.Map{"rules": len(rules.Rules), "deadline": p.deadline})
+
+	return SendSuccess(c, p.status(), fmt.Sprintf("Firewall change applied; confirm within %d seconds or it is rolled back", timeout))
+}
+
+// handleConfirm handles POST /api/firewall/confirm
+// Keeps the pending change and saves it as the ruleset applied at startup
+func (p *FirewallPlugin) handleConfirm(c *fiber.Ctx) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pending == nil {
+		return SendError(c, 409, errNoPendingFirewall)
+	}
+
+	data, _ := json.MarshalIndent(p.pending, "", "  ")
+	if err := os.MkdirAll(filepath.Dir(p.config.RulesFile), 0755); err != nil {
+		return SendError(c, 500, fmt.Errorf("failed to save firewall rules: %w", err))
+	}
+	if err := writeFileAtomic(p.config.RulesFile, data); err != nil {
+		return SendError(c, 500, fmt.Errorf("failed to save firewall rules: %w", err))
+	}
+
+	p.timer.Stop()
+	p.timer = nil
+	p.confirmed = p.pending
+	p.pending = nil
+
+	slog.InfoContext(c.UserContext(), "Firewall change confirmed", "rules", len(p.confirmed.Rules))
+	PublishEvent("firewall.confirmed", firewallEventSource, fiber.Map{"rules": len(p.confirmed.Rules)})
+	return SendSuccess(c, p.status(), "Firewall change confirmed")
+}
+
+// handleRollback handles POST /api/firewall/rollback
+func (p *FirewallPlugin) handleRollback(c *fiber.Ctx) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pending == nil {
+		return SendError(c, 409, errNoPendingFirewall)
+	}
+	if err := p.rollback("requested"); err != nil {
+		return SendError(c, 500, err)
+	}
+	return SendSuccess(c, p.status(), "Firewall change rolled back")
+}
+
+// normalizeFirewallConfig fills in defaults
+func normalizeFirewallConfig(cfg FirewallConfig) FirewallConfig {
+	if cfg.RulesFile == "" {
+		cfg.RulesFile = DefaultFirewallRulesFile
+	}
+	if cfg.Table == "" {
+		cfg.Table = DefaultFirewallTable
+	}
+	if cfg.ConfirmTimeout == 0 {
+		cfg.ConfirmTimeout = DefaultFirewallConfirmTimeout
+	}
+	return cfg
+}
+
+// Register the plugin
+func init() {
+	Register("firewall", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[FirewallConfig]("firewall", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewFirewallPlugin(cfg)
+	})
+}
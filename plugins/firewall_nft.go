@@ -0,0 +1,198 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Firewall rule targets and protocols
+const (
+	FirewallTargetHost      = "host"      // ports of the device itself, including the manager
+	FirewallTargetContainer = "container" // ports published by containers
+	nftCommandTimeout       = 10 * time.Second
+)
+
+// firewallCommentPattern keeps comments safe to quote in the nft script
+var firewallCommentPattern = regexp.MustCompile(`^[A-Za-z0-9 _.,:/()+-]{0,64}$`)
+
+// FirewallRuleset is the managed part of the firewall
+type FirewallRuleset struct {
+	Enabled            bool           `json:"enabled"`             // false removes the managed table
+	RestrictContainers bool           `json:"restrict_containers"` // published container ports not listed in rules are blocked
+	Rules              []FirewallRule `json:"rules"`
+}
+
+// FirewallRule allows one port or port range, optionally from some sources only
+type FirewallRule struct {
+	Target   string   `json:"target"`            // host (default) or container
+	Protocol string   `json:"protocol"`          // tcp (default) or udp
+	Ports    string   `json:"ports"`             // "80" or "8000-8010"; the published port for containers
+	Sources  []string `json:"sources,omitempty"` // addresses or prefixes; empty = anywhere
+	Comment  string   `json:"comment,omitempty"`
+}
+
+// normalize fills in rule defaults and validates the ruleset
+func (rs *FirewallRuleset) normalize() error {
+	for i := range rs.Rules {
+		rule := &rs.Rules[i]
+		if rule.Target == "" {
+			rule.Target = FirewallTargetHost
+		}
+		if rule.Protocol == "" {
+			rule.Protocol = "tcp"
+		}
+		if rule.Target != FirewallTargetHost && rule.Target != FirewallTargetContainer {
+			return fmt.Errorf("rule %d: target must be host or container", i+1)
+		}
+		if rule.Protocol != "tcp" && rule.Protocol != "udp" {
+			return fmt.Errorf("rule %d: protocol must be tcp or udp", i+1)
+		}
+		if _, _, err := parsePortRange(rule.Ports); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		for j, source := range rule.Sources {
+			prefix, err := parseSource(source)
+			if err != nil {
+				return fmt.Errorf("rule %d: %w", i+1, err)
+			}
+			rule.Sources[j] = prefix.String()
+		}
+		if !firewallCommentPattern.MatchString(rule.Comment) {
+			return fmt.Errorf("rule %d: comment may only contain letters, digits and simple punctuation (64 characters)", i+1)
+		}
+	}
+	return nil
+}
+
+// allowsPort reports whether a host rule accepts the TCP port
+// Rules limited to some sources count as well; a client outside them is
+// covered by the confirm timeout like any other lock-out.
+func (rs FirewallRuleset) allowsPort(port int) bool {
+	for _, rule := range rs.Rules {
+		if rule.Target != FirewallTargetHost || rule.Protocol != "tcp" {
+			continue
+		}
+		if low, high, err := parsePortRange(rule.Ports); err == nil && port >= low && port <= high {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePortRange parses "80" or "8000-8010"
+func parsePortRange(value string) (int, int, error) {
+	lowText, highText, isRange := strings.Cut(value, "-")
+	low, err := strconv.Atoi(lowText)
+	high := low
+	if err == nil && isRange {
+		high, err = strconv.Atoi(highText)
+	}
+	if err != nil || low < 1 || high > 65535 || low > high {
+		return 0, 0, fmt.Errorf("invalid ports %q, expected a port or range such as 8000-8010", value)
+	}
+	return low, high, nil
+}
+
+// parseSource parses an address or prefix into a masked prefix
+func parseSource(value string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(value); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid source %q", value)
+	}
+	return prefix.Masked(), nil
+}
+
+// renderNftables returns an nft script that replaces the managed table in one transaction
+// A disabled ruleset only removes the table.
+func renderNftables(table string, rs FirewallRuleset) string {
+	var b strings.Builder
+	// Declaring the table first makes the delete succeed when it does not exist yet
+	fmt.Fprintf(&b, "table inet %s\ndelete table inet %s\n", table, table)
+	if !rs.Enabled {
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "table inet %s {\n", table)
+	b.WriteString("\tchain input {\n")
+	b.WriteString("\t\ttype filter hook input priority filter; policy drop;\n")
+	b.WriteString("\t\tct state established,related accept\n")
+	b.WriteString("\t\tct state invalid drop\n")
+	b.WriteString("\t\tiifname \"lo\" accept\n")
+	b.WriteString("\t\tmeta l4proto { icmp, ipv6-icmp } accept\n")
+	for _, rule := range rs.Rules {
+		if rule.Target == FirewallTargetHost {
+			writeNftRule(&b, rule, fmt.Sprintf("%s dport %s", rule.Protocol, rule.Ports))
+		}
+	}
+	b.WriteString("\t}\n")
+
+	// Published ports are DNATed to the container, so they are matched by the
+	// original destination port in the forward hook
+	b.WriteString("\tchain forward {\n")
+	b.WriteString("\t\ttype filter hook forward priority filter; policy accept;\n")
+	b.WriteString("\t\tct state established,related accept\n")
+	for _, rule := range rs.Rules {
+		if rule.Target == FirewallTargetContainer {
+			writeNftRule(&b, rule, fmt.Sprintf("ct status dnat meta l4proto %s ct original proto-dst %s", rule.Protocol, rule.Ports))
+		}
+	}
+	if rs.RestrictContainers {
+		b.WriteString("\t\tct status dnat drop\n")
+	}
+	b.WriteString("\t}\n")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// writeNftRule writes the accept statements of a rule, one per address family of its sources
+func writeNftRule(b *strings.Builder, rule FirewallRule, match string) {
+	comment := ""
+	if rule.Comment != "" {
+		comment = fmt.Sprintf(" comment %q", rule.Comment)
+	}
+	if len(rule.Sources) == 0 {
+		fmt.Fprintf(b, "\t\t%s accept%s\n", match, comment)
+		return
+	}
+
+	var v4, v6 []string
+	for _, source := range rule.Sources {
+		if prefix, err := netip.ParsePrefix(source); err == nil && prefix.Addr().Is4() {
+			v4 = append(v4, source)
+		} else {
+			v6 = append(v6, source)
+		}
+	}
+	if len(v4) > 0 {
+		fmt.Fprintf(b, "\t\tip saddr { %s } %s accept%s\n", strings.Join(v4, ", "), match, comment)
+	}
+	if len(v6) > 0 {
+		fmt.Fprintf(b, "\t\tip6 saddr { %s } %s accept%s\n", strings.Join(v6, ", "), match, comment)
+	}
+}
+
+// runNft feeds a script to nft; check only validates it
+func runNft(script string, check bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), nftCommandTimeout)
+	defer cancel()
+
+	args := []string{"-f", "-"}
+	if check {
+		args = append([]string{"-c"}, args...)
+	}
+	cmd := exec.CommandContext(ctx, "nft", args...)
+	cmd.Stdin = strings.NewReader(script)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("nft: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
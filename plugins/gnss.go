@@ -0,0 +1,540 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GNSS sources
+const (
+	GNSSSourceGPSD   = "gpsd"
+	GNSSSourceSerial = "serial"
+)
+
+// GNSS fix modes (same values as gpsd and NMEA GSA)
+const (
+	GNSSModeUnknown = 0
+	GNSSModeNoFix   = 1
+	GNSSMode2D      = 2
+	GNSSMode3D      = 3
+)
+
+// GNSS defaults
+const (
+	DefaultGPSDAddress     = "127.0.0.1:2947"
+	DefaultGNSSDevice      = "/dev/ttyAMA0"
+	DefaultGNSSBaudRate    = 9600
+	DefaultGNSSStaleAfter  = 5 // seconds
+	gnssReconnectDelay     = 5 * time.Second
+	gnssStreamMinInterval  = 250 * time.Millisecond
+	gnssFixChangedEvent    = "gnss.fix"
+	gnssEventSource        = "gnss"
+	gnssSerialSetupTimeout = 5 * time.Second
+)
+
+// gnssModeNames maps fix modes to API names
+var gnssModeNames = map[int]string{
+	GNSSModeUnknown: "unknown",
+	GNSSModeNoFix:   "no_fix",
+	GNSSMode2D:      "2d",
+	GNSSMode3D:      "3d",
+}
+
+// GNSSConfig holds GNSS plugin configuration
+type GNSSConfig struct {
+	Source      string `yaml:"source"`       // gpsd or serial
+	GPSDAddress string `yaml:"gpsd_address"` // host:port of gpsd
+	Device      string `yaml:"device"`       // serial device emitting NMEA sentences
+	BaudRate    int    `yaml:"baud_rate"`
+	StaleAfter  int    `yaml:"stale_after"` // seconds without reports before the fix is considered stale
+}
+
+// GNSSFix is the latest position, time and fix quality reported by the receiver
+type GNSSFix struct {
+	Mode              int       `json:"mode"`
+	Fix               string    `json:"fix"`
+	Quality           int       `json:"quality"` // NMEA GGA fix quality (0 invalid, 1 GPS, 2 DGPS, ...)
+	SatellitesUsed    int       `json:"satellites_used"`
+	SatellitesVisible int       `json:"satellites_visible"`
+	HDOP              float64   `json:"hdop,omitempty"`
+	VDOP              float64   `json:"vdop,omitempty"`
+	PDOP              float64   `json:"pdop,omitempty"`
+	Latitude          float64   `json:"latitude"`
+	Longitude         float64   `json:"longitude"`
+	Altitude          float64   `json:"altitude"` // meters above mean sea level
+	Speed             float64   `json:"speed"`    // m/s
+	Course            float64   `json:"course"`   // degrees true
+	Time              time.Time `json:"time"`     // UTC time reported by the receiver
+	Updated           time.Time `json:"updated"`  // local time of the last report
+	Stale             bool      `json:"stale"`
+}
+
+// HasPosition reports whether the fix holds a current 2D or 3D position
+func (f GNSSFix) HasPosition() bool {
+	return f.Mode >= GNSSMode2D && !f.Stale
+}
+
+// GNSSPlugin reads position and time from gpsd or a serial NMEA receiver
+type GNSSPlugin struct {
+	mu        sync.RWMutex
+	config    GNSSConfig
+	fix       GNSSFix
+	connected bool
+	lastError string
+	// Closed and replaced on every position report to wake up streams
+	updated chan struct{}
+
+	stopChan chan struct{}
+	doneChan chan struct{}
+	started  bool // the reader runs, set by Start
+}
+
+// gnssPlugin is the running instance used by CurrentGNSSFix
+var (
+	gnssPlugin   *GNSSPlugin
+	gnssPluginMu sync.RWMutex
+)
+
+// CurrentGNSSFix returns the latest fix for use by other plugins (e.g. to timestamp recordings)
+// ok is false when the gnss plugin is not loaded
+func CurrentGNSSFix() (fix GNSSFix, ok bool) {
+	gnssPluginMu.RLock()
+	p := gnssPlugin
+	gnssPluginMu.RUnlock()
+	if p == nil {
+		return GNSSFix{}, false
+	}
+	return p.getFix(), true
+}
+
+// NewGNSSPlugin creates a new GNSS plugin instance
+func NewGNSSPlugin(cfg GNSSConfig) (*GNSSPlugin, error) {
+	cfg = normalizeGNSSConfig(cfg)
+	if err := validateGNSSConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	p := &GNSSPlugin{
+		config:  cfg,
+		updated: make(chan struct{}),
+	}
+
+	gnssPluginMu.Lock()
+	gnssPlugin = p
+	gnssPluginMu.Unlock()
+
+	return p, nil
+}
+
+// Start begins reading from the source
+func (p *GNSSPlugin) Start() error {
+	p.mu.Lock()
+	p.started = true
+	p.mu.Unlock()
+
+	p.start()
+	return nil
+}
+
+// Name returns the plugin identifier
+func (p *GNSSPlugin) Name() string {
+	return "gnss"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *GNSSPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/gnss")
+
+	api.Get("/status", p.handleStatus)
+	api.Get("/position", p.handlePosition)
+	api.Get("/time", p.handleTime)
+	api.Get("/fix", p.handleFix)
+	api.Get("/stream", p.handleStream)
+}
+
+// Shutdown stops the reader
+func (p *GNSSPlugin) Shutdown() error {
+	p.stop()
+
+	gnssPluginMu.Lock()
+	if gnssPlugin == p {
+		gnssPlugin = nil
+	}
+	gnssPluginMu.Unlock()
+	return nil
+}
+
+// Reload restarts the reader when the source settings change
+func (p *GNSSPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[GNSSConfig]("gnss", config)
+	if err != nil {
+		return err
+	}
+	cfg = normalizeGNSSConfig(cfg)
+	if err := validateGNSSConfig(cfg); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	changed := cfg != p.config
+	p.config = cfg
+	started := p.started
+	p.mu.Unlock()
+
+	if changed && started {
+		p.stop()
+		p.start()
+	}
+
+	slog.Info("GNSS config reloaded",
+		"source", cfg.Source,
+		"gpsd_address", cfg.GPSDAddress,
+		"device", cfg.Device,
+		"baud_rate", cfg.BaudRate,
+		"restarted", changed)
+	return nil
+}
+
+// start launches the reader goroutine
+func (p *GNSSPlugin) start() {
+	p.stopChan = make(chan struct{})
+	p.doneChan = make(chan struct{})
+
+	cfg := p.getConfig()
+	slog.Info("GNSS reader started", "source", cfg.Source)
+	go p.run(cfg, p.stopChan, p.doneChan)
+}
+
+// stop terminates the reader goroutine and waits for it to exit
+func (p *GNSSPlugin) stop() {
+	if p.stopChan == nil {
+		return
+	}
+	close(p.stopChan)
+	<-p.doneChan
+
+	p.mu.Lock()
+	p.connected = false
+	p.mu.Unlock()
+}
+
+// getConfig returns the current configuration
+func (p *GNSSPlugin) getConfig() GNSSConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config
+}
+
+// getFix returns a copy of the current fix with staleness applied
+func (p *GNSSPlugin) getFix() GNSSFix {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	fix := p.fix
+	staleAfter := time.Duration(p.config.StaleAfter) * time.Second
+	fix.Stale = fix.Updated.IsZero() || time.Since(fix.Updated) > staleAfter
+	fix.Fix = gnssModeNames[fix.Mode]
+	return fix
+}
+
+// run reads from the source, reconnecting after errors until stopped
+func (p *GNSSPlugin) run(cfg GNSSConfig, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	var lastErr string
+	for {
+		err := p.readSource(cfg, stop)
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		// Log only changes so a missing receiver does not flood the log
+		if err != nil && err.Error() != lastErr {
+			slog.Warn("GNSS source unavailable", "source", cfg.Source, "error", err)
+			lastErr = err.Error()
+		}
+		p.mu.Lock()
+		p.connected = false
+		if err != nil {
+			p.lastError = err.Error()
+		}
+		p.mu.Unlock()
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(gnssReconnectDelay):
+		}
+	}
+}
+
+// openGNSSSource connects to gpsd or opens the serial device
+func openGNSSSource(cfg GNSSConfig) (io.ReadCloser, error) {
+	if cfg.Source == GNSSSourceGPSD {
+		conn, err := net.DialTimeout("tcp", cfg.GPSDAddress, gnssReconnectDelay)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(conn, gpsdWatchCommand); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+
+	// Configure baud rate and raw mode before reading
+	ctx, cancel := context.WithTimeout(context.Background(), gnssSerialSetupTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "stty", "-F", cfg.Device, strconv.Itoa(cfg.BaudRate), "raw", "-echo").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure %s: %s", cfg.Device, output)
+	}
+	return os.Open(cfg.Device)
+}
+
+// readSource reads reports until the source fails or stop is closed
+func (p *GNSSPlugin) readSource(cfg GNSSConfig, stop <-chan struct{}) error {
+	source, err := openGNSSSource(cfg)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	// Closing the source unblocks the scanner on stop
+	closed := make(chan struct{})
+	defer close(closed)
+	go func() {
+		select {
+		case <-stop:
+			source.Close()
+		case <-closed:
+		}
+	}()
+
+	p.mu.Lock()
+	p.connected = true
+	p.lastError = ""
+	p.mu.Unlock()
+	slog.Info("GNSS source connected", "source", cfg.Source)
+
+	visible := make(map[string]int)
+	scanner := bufio.NewScanner(source)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		p.mu.Lock()
+		previousMode := p.fix.Mode
+		var position bool
+		if cfg.Source == GNSSSourceGPSD {
+			position = p.fix.applyGPSD(line)
+		} else if talker, kind, fields, err := parseNMEA(string(line)); err == nil {
+			position = p.fix.applyNMEA(talker, kind, fields, visible)
+		}
+		if position {
+			p.fix.Updated = time.Now()
+			close(p.updated)
+			p.updated = make(chan struct{})
+		}
+		mode := p.fix.Mode
+		p.mu.Unlock()
+
+		if mode != previousMode {
+			slog.Info("GNSS fix changed", "fix", gnssModeNames[mode])
+			PublishEvent(gnssFixChangedEvent, gnssEventSource, p.getFix())
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("%s source closed", cfg.Source)
+}
+
+// waitUpdate returns a channel closed on the next position report
+func (p *GNSSPlugin) waitUpdate() <-chan struct{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.updated
+}
+
+// handleStatus handles GET /api/gnss/status
+func (p *GNSSPlugin) handleStatus(c *fiber.Ctx) error {
+	cfg := p.getConfig()
+
+	p.mu.RLock()
+	connected := p.connected
+	lastError := p.lastError
+	p.mu.RUnlock()
+
+	status := fiber.Map{
+		"source":     cfg.Source,
+		"connected":  connected,
+		"last_error": lastError,
+		"fix":        p.getFix(),
+	}
+	if cfg.Source == GNSSSourceGPSD {
+		status["gpsd_address"] = cfg.GPSDAddress
+	} else {
+		status["device"] = cfg.Device
+		status["baud_rate"] = cfg.BaudRate
+	}
+	return SendSuccess(c, status, "")
+}
+
+// handlePosition handles GET /api/gnss/position
+// Returns 503 while there is no current 2D/3D fix
+func (p *GNSSPlugin) handlePosition(c *fiber.Ctx) error {
+	fix := p.getFix()
+	if !fix.HasPosition() {
+		return SendErrorMessage(c, 503, "No GNSS position fix")
+	}
+
+	position := fiber.Map{
+		"latitude":  fix.Latitude,
+		"longitude": fix.Longitude,
+		"speed":     fix.Speed,
+		"course":    fix.Course,
+		"fix":       fix.Fix,
+		"time":      fix.Time,
+	}
+	if fix.Mode == GNSSMode3D {
+		position["altitude"] = fix.Altitude
+	}
+	return SendSuccess(c, position, "")
+}
+
+// handleTime handles GET /api/gnss/time
+// offset is the system clock minus GNSS time at the last report, including receiver latency
+func (p *GNSSPlugin) handleTime(c *fiber.Ctx) error {
+	fix := p.getFix()
+	valid := !fix.Time.IsZero() && !fix.Stale && fix.Mode >= GNSSMode2D
+
+	result := fiber.Map{
+		"valid":       valid,
+		"system_time": time.Now().UTC(),
+	}
+	if !fix.Time.IsZero() {
+		offset := fix.Updated.Sub(fix.Time)
+		result["gnss_time"] = fix.Time
+		result["offset"] = offset.String()
+		result["offset_ms"] = float64(offset) / float64(time.Millisecond)
+	}
+	return SendSuccess(c, result, "")
+}
+
+// handleFix handles GET /api/gnss/fix
+func (p *GNSSPlugin) handleFix(c *fiber.Ctx) error {
+	fix := p.getFix()
+	return SendSuccess(c, fiber.Map{
+		"mode":               fix.Mode,
+		"fix":                fix.Fix,
+		"quality":            fix.Quality,
+		"satellites_used":    fix.SatellitesUsed,
+		"satellites_visible": fix.SatellitesVisible,
+		"hdop":               fix.HDOP,
+		"vdop":               fix.VDOP,
+		"pdop":               fix.PDOP,
+		"stale":              fix.Stale,
+		"updated":            fix.Updated,
+	}, "")
+}
+
+// handleStream handles GET /api/gnss/stream
+// Streams the fix as Server-Sent Events, at most every 250ms
+func (p *GNSSPlugin) handleStream(c *fiber.Ctx) error {
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		keepAlive := time.NewTicker(eventKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			// Register for the next report before sending so none is missed
+			update := p.waitUpdate()
+
+			data, _ := json.Marshal(p.getFix())
+			fmt.Fprintf(w, "event: position\ndata: %s\n\n", data)
+			if err := w.Flush(); err != nil {
+				return
+			}
+
+		wait:
+			for {
+				select {
+				case <-update:
+					break wait
+				case <-keepAlive.C:
+					fmt.Fprint(w, ": keep-alive\n\n")
+					if err := w.Flush(); err != nil {
+						return
+					}
+				}
+			}
+			time.Sleep(gnssStreamMinInterval)
+		}
+	})
+
+	return nil
+}
+
+// normalizeGNSSConfig fills in defaults
+func normalizeGNSSConfig(cfg GNSSConfig) GNSSConfig {
+	if cfg.Source == "" {
+		cfg.Source = GNSSSourceGPSD
+	}
+	if cfg.GPSDAddress == "" {
+		cfg.GPSDAddress = DefaultGPSDAddress
+	}
+	if cfg.Device == "" {
+		cfg.Device = DefaultGNSSDevice
+	}
+	if cfg.BaudRate <= 0 {
+		cfg.BaudRate = DefaultGNSSBaudRate
+	}
+	if cfg.StaleAfter <= 0 {
+		cfg.StaleAfter = DefaultGNSSStaleAfter
+	}
+	return cfg
+}
+
+// validateGNSSConfig checks the source selection
+func validateGNSSConfig(cfg GNSSConfig) error {
+	if cfg.Source != GNSSSourceGPSD && cfg.Source != GNSSSourceSerial {
+		return fmt.Errorf("invalid gnss source %q (use gpsd or serial)", cfg.Source)
+	}
+	return nil
+}
+
+// Validate checks the settings after filling in defaults
+func (cfg GNSSConfig) Validate() error {
+	return validateGNSSConfig(normalizeGNSSConfig(cfg))
+}
+
+// Register the plugin
+func init() {
+	Register("gnss", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[GNSSConfig]("gnss", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewGNSSPlugin(cfg)
+	})
+}
@@ -0,0 +1,85 @@
+package plugins
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// gpsdWatchCommand enables JSON reports on a gpsd connection
+const gpsdWatchCommand = "?WATCH={\"enable\":true,\"json\":true}\n"
+
+// gpsdReport is the subset of gpsd TPV and SKY reports used by the plugin
+type gpsdReport struct {
+	Class  string  `json:"class"`
+	Mode   int     `json:"mode"`
+	Status int     `json:"status"`
+	Time   string  `json:"time"`
+	Lat    float64 `json:"lat"`
+	Lon    float64 `json:"lon"`
+	Alt    float64 `json:"alt"`
+	AltMSL float64 `json:"altMSL"`
+	Speed  float64 `json:"speed"`
+	Track  float64 `json:"track"`
+
+	HDOP       float64 `json:"hdop"`
+	PDOP       float64 `json:"pdop"`
+	VDOP       float64 `json:"vdop"`
+	Satellites []struct {
+		Used bool `json:"used"`
+	} `json:"satellites"`
+}
+
+// applyGPSD updates a fix from one line of gpsd JSON output
+// Returns true when the report carried a position (TPV)
+func (f *GNSSFix) applyGPSD(line []byte) bool {
+	var report gpsdReport
+	if err := json.Unmarshal(line, &report); err != nil {
+		return false
+	}
+
+	switch report.Class {
+	case "TPV":
+		f.Mode = report.Mode
+		if t, err := time.Parse(time.RFC3339Nano, report.Time); err == nil {
+			f.Time = t
+		}
+		if report.Mode >= GNSSMode2D {
+			f.Latitude = report.Lat
+			f.Longitude = report.Lon
+			f.Speed = report.Speed
+			f.Course = report.Track
+		}
+		if report.Mode >= GNSSMode3D {
+			// gpsd 3.20+ reports MSL altitude separately; older versions only send alt
+			f.Altitude = report.Alt
+			if report.AltMSL != 0 {
+				f.Altitude = report.AltMSL
+			}
+		}
+		// gpsd status 2 means DGPS, mirroring NMEA GGA quality
+		f.Quality = 0
+		if report.Mode >= GNSSMode2D {
+			f.Quality = 1
+			if report.Status == 2 {
+				f.Quality = 2
+			}
+		}
+		return true
+
+	case "SKY":
+		f.HDOP = report.HDOP
+		f.PDOP = report.PDOP
+		f.VDOP = report.VDOP
+		if report.Satellites != nil {
+			f.SatellitesVisible = len(report.Satellites)
+			used := 0
+			for _, satellite := range report.Satellites {
+				if satellite.Used {
+					used++
+				}
+			}
+			f.SatellitesUsed = used
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package plugins
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Conversion factor from knots (NMEA speed over ground) to m/s
+const knotsToMetersPerSecond = 0.514444
+
+// parseNMEA validates an NMEA 0183 sentence and splits it into talker, type and fields
+// e.g. "$GNRMC,..." returns talker "GN" and type "RMC"
+func parseNMEA(line string) (talker string, kind string, fields []string, err error) {
+	line = strings.TrimSpace(line)
+	if len(line) < 7 || (line[0] != '$' && line[0] != '!') {
+		return "", "", nil, fmt.Errorf("not an NMEA sentence")
+	}
+
+	body := line[1:]
+	if star := strings.LastIndexByte(body, '*'); star >= 0 {
+		expected, err := strconv.ParseUint(body[star+1:], 16, 8)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("invalid checksum field")
+		}
+		body = body[:star]
+
+		var sum byte
+		for i := 0; i < len(body); i++ {
+			sum ^= body[i]
+		}
+		if sum != byte(expected) {
+			return "", "", nil, fmt.Errorf("checksum mismatch")
+		}
+	}
+
+	parts := strings.Split(body, ",")
+	if len(parts[0]) < 5 {
+		return "", "", nil, fmt.Errorf("invalid sentence address %q", parts[0])
+	}
+	address := parts[0]
+	return address[:len(address)-3], address[len(address)-3:], parts[1:], nil
+}
+
+// nmeaField returns field i, or "" when the sentence is too short
+func nmeaField(fields []string, i int) string {
+	if i < len(fields) {
+		return fields[i]
+	}
+	return ""
+}
+
+// parseNMEACoordinate converts ddmm.mmmm / dddmm.mmmm with a hemisphere into decimal degrees
+func parseNMEACoordinate(value string, hemisphere string) (float64, bool) {
+	dot := strings.IndexByte(value, '.')
+	if dot < 0 {
+		dot = len(value)
+	}
+	if dot < 3 {
+		return 0, false
+	}
+
+	degrees, err := strconv.ParseFloat(value[:dot-2], 64)
+	if err != nil {
+		return 0, false
+	}
+	minutes, err := strconv.ParseFloat(value[dot-2:], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	coordinate := degrees + minutes/60
+	switch hemisphere {
+	case "S", "W":
+		coordinate = -coordinate
+	case "N", "E":
+	default:
+		return 0, false
+	}
+	return coordinate, true
+}
+
+// parseNMEATime combines an hhmmss.ss time and a ddmmyy date into a UTC timestamp
+func parseNMEATime(clock string, date string) (time.Time, bool) {
+	if len(clock) < 6 || len(date) != 6 {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("020106 150405", date+" "+clock[:6])
+	if err != nil {
+		return time.Time{}, false
+	}
+	if len(clock) > 7 && clock[6] == '.' {
+		if fraction, err := strconv.ParseFloat("0"+clock[6:], 64); err == nil {
+			t = t.Add(time.Duration(fraction * float64(time.Second)))
+		}
+	}
+	return t, true
+}
+
+// parseNMEAFloat parses an optional numeric field
+func parseNMEAFloat(value string) (float64, bool) {
+	if value == "" {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	return f, err == nil
+}
+
+// applyNMEA updates a fix from one sentence
+// Returns true when the sentence carried a position report
+func (f *GNSSFix) applyNMEA(talker string, kind string, fields []string, visible map[string]int) bool {
+	switch kind {
+	case "RMC":
+		// time, status, lat, N/S, lon, E/W, speed (knots), course, date
+		if nmeaField(fields, 1) != "A" {
+			f.Mode = GNSSModeNoFix
+			return true
+		}
+		if t, ok := parseNMEATime(nmeaField(fields, 0), nmeaField(fields, 8)); ok {
+			f.Time = t
+		}
+		f.setPosition(fields[2:])
+		if speed, ok := parseNMEAFloat(nmeaField(fields, 6)); ok {
+			f.Speed = speed * knotsToMetersPerSecond
+		}
+		if course, ok := parseNMEAFloat(nmeaField(fields, 7)); ok {
+			f.Course = course
+		}
+		if f.Mode < GNSSMode2D {
+			f.Mode = GNSSMode2D
+		}
+		return true
+
+	case "GGA":
+		// time, lat, N/S, lon, E/W, quality, satellites, HDOP, altitude, M
+		quality, _ := strconv.Atoi(nmeaField(fields, 5))
+		f.Quality = quality
+		if satellites, err := strconv.Atoi(nmeaField(fields, 6)); err == nil {
+			f.SatellitesUsed = satellites
+		}
+		if hdop, ok := parseNMEAFloat(nmeaField(fields, 7)); ok {
+			f.HDOP = hdop
+		}
+		if quality == 0 {
+			f.Mode = GNSSModeNoFix
+			return true
+		}
+		f.setPosition(fields[1:])
+		if altitude, ok := parseNMEAFloat(nmeaField(fields, 8)); ok {
+			f.Altitude = altitude
+			if f.Mode < GNSSMode3D {
+				f.Mode = GNSSMode3D
+			}
+		}
+		return true
+
+	case "GSA":
+		// selection mode, fix type (1-3), 12 satellite IDs, PDOP, HDOP, VDOP
+		if mode, err := strconv.Atoi(nmeaField(fields, 1)); err == nil && mode >= GNSSModeNoFix && mode <= GNSSMode3D {
+			f.Mode = mode
+		}
+		if pdop, ok := parseNMEAFloat(nmeaField(fields, 14)); ok {
+			f.PDOP = pdop
+		}
+		if vdop, ok := parseNMEAFloat(nmeaField(fields, 16)); ok {
+			f.VDOP = vdop
+		}
+
+	case "GSV":
+		// message count, message number, satellites in view
+		// Each constellation (talker) reports its own count
+		if inView, err := strconv.Atoi(nmeaField(fields, 2)); err == nil {
+			visible[talker] = inView
+			total := 0
+			for _, count := range visible {
+				total += count
+			}
+			f.SatellitesVisible = total
+		}
+	}
+	return false
+}
+
+// setPosition reads lat, N/S, lon, E/W from the first four fields
+func (f *GNSSFix) setPosition(fields []string) {
+	lat, latOK := parseNMEACoordinate(nmeaField(fields, 0), nmeaField(fields, 1))
+	lon, lonOK := parseNMEACoordinate(nmeaField(fields, 2), nmeaField(fields, 3))
+	if latOK && lonOK {
+		f.Latitude = lat
+		f.Longitude = lon
+	}
+}
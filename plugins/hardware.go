@@ -1,51 +1,62 @@
 package plugins
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
 )
 
-// toUint32 converts various numeric types to uint32
-// Handles int, int64, uint32, and float64 (from JSON unmarshaling)
-func toUint32(v interface{}) (uint32, bool) {
-	switch val := v.(type) {
-	case int:
-		return uint32(val), true
-	case int64:
-		return uint32(val), true
-	case uint32:
-		return val, true
-	case float64:
-		return uint32(val), true
-	default:
-		return 0, false
-	}
-}
-
-// toInt converts various numeric types to int
-func toInt(v interface{}) (int, bool) {
-	switch val := v.(type) {
-	case int:
-		return val, true
-	case int64:
-		return int(val), true
-	case float64:
-		return int(val), true
-	default:
-		return 0, false
-	}
-}
-
 // HardwarePlugin provides SX1255 transceiver control
 // Uses transient connections - initializes and releases for each operation
 type HardwarePlugin struct {
-	config HardwareConfig
+	config  HardwareConfig
+	monitor *HardwareMonitor
+	started bool // background workers are running, set by Start
+	mu      sync.RWMutex
+	busMu   sync.Mutex // serializes transient controller sessions; the SPI bus flock is taken inside it
+
+	shared     *SX1255Controller // kept open while control channels are connected, guarded by busMu
+	sharedRefs int
+
+	capture   *captureSession // current or last I/Q recording
+	captureMu sync.Mutex      // serializes recording starts
+
+	testSignal   *testSignalSession // current or last transmit test
+	testSignalMu sync.Mutex         // serializes test signal starts
+
+	doppler   *dopplerSession // current or last Doppler correction
+	dopplerMu sync.Mutex      // serializes Doppler starts
+
+	txTimer *time.Timer // unkeys the transmitter after the band plan's TX time limit
+
+	bias paBias // external PA bias output
+
+	vswr     *vswrMonitor // directional coupler sampling, nil when no sensor is configured
+	vswrTrip *VSWRTrip    // set while the VSWR protection inhibits keying
+
+	cwid *cwidTimer // station identification while transmitting
+
+	txTime txAccounting // TX time of the last day for the duty cycle limits
+
+	calibration calibrationState // calibration table and the points applied, guarded by calMu
+	calMu       sync.Mutex
+
+	channelsMu sync.Mutex // serializes changes to the channel list
+
+	shadow registerShadow // register values and GPIO levels last seen, the starting point of validate=true
 }
 
 // HardwareConfig holds hardware configuration
 type HardwareConfig struct {
+	Board  string         `yaml:"board"`  // board profile supplying the sx1255 settings not set here
+	Boards []BoardProfile `yaml:"boards"` // board profiles in addition to the shipped ones
 	SX1255 struct {
 		SPIDevice string `yaml:"spi_device"`
 		SPISpeed  uint32 `yaml:"spi_speed"`
@@ -53,18 +64,149 @@ type HardwareConfig struct {
 		ResetPin  int    `yaml:"reset_pin"`
 		TxRxPin   int    `yaml:"tx_rx_pin"`
 		ClockFreq uint32 `yaml:"clock_freq"`
+
+		LockFile    string `yaml:"lock_file"`    // flock shared with other SPI users; empty locks the spidev node, "none" disables
+		LockTimeout int    `yaml:"lock_timeout"` // milliseconds to wait for the lock
 	} `yaml:"sx1255"`
+	Monitor struct {
+		Interval int `yaml:"interval"` // seconds, 0 disables
+		History  int `yaml:"history"`
+	} `yaml:"monitor"`
+	Baseband struct {
+		Device         string `yaml:"device"`          // ALSA capture device carrying I/Q from the SX1255 I2S interface
+		PlaybackDevice string `yaml:"playback_device"` // ALSA playback device feeding I/Q to the TX path
+		SampleRate     int    `yaml:"sample_rate"`
+	} `yaml:"baseband"`
+	Capture struct {
+		Dir         string `yaml:"dir"`
+		MaxDuration int    `yaml:"max_duration"` // seconds
+	} `yaml:"capture"`
+	TestSignal struct {
+		MaxDuration  int     `yaml:"max_duration"`   // seconds
+		MaxLevel     float64 `yaml:"max_level"`      // dBFS peak of the baseband signal
+		MaxMixerGain float64 `yaml:"max_mixer_gain"` // dB
+		AllowPA      bool    `yaml:"allow_pa"`
+	} `yaml:"testsignal"`
+	Temperature struct {
+		Offset  float64 `yaml:"offset"`  // °C added to the sensor reading (one-point calibration)
+		Samples int     `yaml:"samples"` // I/Q samples averaged per reading
+	} `yaml:"temperature"`
+	BandPlan    BandPlanConfig    `yaml:"bandplan"`
+	ChannelPlan ChannelPlanConfig `yaml:"channel_plan"` // raster for tuning by channel number
+	Channels    ChannelsConfig    `yaml:"channels"`     // named channels
+	Sequencing  TxSequencing      `yaml:"sequencing"`
+	PABias      PABiasConfig      `yaml:"pa_bias"`
+	VSWR        VSWRConfig        `yaml:"vswr"`
+	Calibration CalibrationConfig `yaml:"calibration"`
+	Doppler     DopplerConfig     `yaml:"doppler"`
+	CWID        CWIDConfig        `yaml:"cwid"`
+	DutyCycle   DutyCycleConfig   `yaml:"duty_cycle"`
+	Diagnostics struct {
+		RawSPI bool `yaml:"raw_spi"` // allow POST /hardware/spi/transfer
+	} `yaml:"diagnostics"`
 }
 
-// NewHardwarePlugin creates a new hardware plugin instance
-func NewHardwarePlugin(cfg HardwareConfig) (*HardwarePlugin, error) {
-	// Set defaults if not configured
+// DefaultTxRxPin is the GPIO line of the antenna TX/RX switch
+const DefaultTxRxPin = 13
+
+// DefaultHardwareConfig returns the settings used for keys missing from the config file
+func DefaultHardwareConfig() HardwareConfig {
+	var cfg HardwareConfig
+	cfg.SX1255.TxRxPin = DefaultTxRxPin
+	return cfg
+}
+
+// Validate checks the board profiles, band plan ranges, channel plan, Doppler site, station ID, duty cycle limits, keying delays and TX protection outputs
+func (cfg HardwareConfig) Validate() error {
+	for _, band := range cfg.BandPlan.Bands {
+		if band.Start == 0 || band.Stop < band.Start {
+			return fmt.Errorf("hardware.bandplan: band %q must have 0 < start <= stop", band.Name)
+		}
+	}
+	if err := cfg.ChannelPlan.validate(); err != nil {
+		return err
+	}
+	if err := validateBoards(cfg.Boards); err != nil {
+		return err
+	}
+	if _, ok := findBoard(cfg.Board, cfg.Boards); cfg.Board != "" && !ok {
+		return fmt.Errorf("hardware.board: unknown board %q", cfg.Board)
+	}
+	if err := cfg.PABias.validate(); err != nil {
+		return err
+	}
+	if err := cfg.Doppler.validate(); err != nil {
+		return err
+	}
+	if err := cfg.VSWR.validate(); err != nil {
+		return err
+	}
+	if err := cfg.CWID.validate(); err != nil {
+		return err
+	}
+	if err := cfg.DutyCycle.validate(); err != nil {
+		return err
+	}
+	return cfg.Sequencing.validate()
+}
+
+// applyHardwareDefaults sets defaults for unconfigured hardware settings
+func applyHardwareDefaults(cfg *HardwareConfig) {
 	if cfg.SX1255.SPISpeed == 0 {
 		cfg.SX1255.SPISpeed = 500000 // Default 500 kHz
 	}
 	if cfg.SX1255.ClockFreq == 0 {
 		cfg.SX1255.ClockFreq = 32000000 // Default 32 MHz
 	}
+	if cfg.SX1255.LockTimeout <= 0 {
+		cfg.SX1255.LockTimeout = DefaultBusLockTimeout
+	}
+	if cfg.Baseband.Device == "" {
+		cfg.Baseband.Device = DefaultBasebandDevice
+	}
+	if cfg.Baseband.SampleRate <= 0 {
+		cfg.Baseband.SampleRate = DefaultBasebandSampleRate
+	}
+	if cfg.Capture.Dir == "" {
+		cfg.Capture.Dir = DefaultCaptureDir
+	}
+	if cfg.Capture.MaxDuration <= 0 {
+		cfg.Capture.MaxDuration = DefaultCaptureMaxDuration
+	}
+	if cfg.Baseband.PlaybackDevice == "" {
+		cfg.Baseband.PlaybackDevice = cfg.Baseband.Device
+	}
+	if cfg.TestSignal.MaxDuration <= 0 {
+		cfg.TestSignal.MaxDuration = DefaultTestSignalMaxDuration
+	}
+	if cfg.TestSignal.MaxLevel >= 0 {
+		cfg.TestSignal.MaxLevel = DefaultTestSignalMaxLevel
+	}
+	if cfg.TestSignal.MaxMixerGain == 0 {
+		cfg.TestSignal.MaxMixerGain = DefaultTestSignalMaxMixerGain
+	}
+	if cfg.Temperature.Samples <= 0 {
+		cfg.Temperature.Samples = DefaultTemperatureSamples
+	}
+	cfg.TestSignal.MaxMixerGain = math.Max(MinMixerGainDb, math.Min(MaxMixerGainDb, cfg.TestSignal.MaxMixerGain))
+	applyPABiasDefaults(&cfg.PABias)
+	applyVSWRDefaults(&cfg.VSWR)
+	applyCalibrationDefaults(&cfg.Calibration)
+	if cfg.Channels.File == "" {
+		cfg.Channels.File = DefaultChannelsFile
+	}
+	applyDopplerDefaults(&cfg.Doppler)
+	applyCWIDDefaults(&cfg.CWID)
+	applyDutyCycleDefaults(&cfg.DutyCycle)
+}
+
+// NewHardwarePlugin creates a new hardware plugin instance
+func NewHardwarePlugin(cfg HardwareConfig) (*HardwarePlugin, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	// Set defaults if not configured
+	applyHardwareDefaults(&cfg)
 
 	slog.Info("Hardware plugin initializing",
 		"spi_device", cfg.SX1255.SPIDevice,
@@ -73,9 +215,35 @@ func NewHardwarePlugin(cfg HardwareConfig) (*HardwarePlugin, error) {
 		"reset_pin", cfg.SX1255.ResetPin,
 		"clock_freq", cfg.SX1255.ClockFreq)
 
-	return &HardwarePlugin{
+	// Initialize periph.io once up front instead of on the first request
+	if err := initPeriph(); err != nil {
+		slog.Warn("Failed to initialize periph.io host", "error", err)
+	}
+
+	p := &HardwarePlugin{
 		config: cfg,
-	}, nil
+	}
+
+	hardwarePluginMu.Lock()
+	hardwarePlugin = p
+	hardwarePluginMu.Unlock()
+
+	return p, nil
+}
+
+// Start begins the alarm monitor, VSWR sampling, station ID timer and duty cycle polling,
+// which publish hardware events
+func (p *HardwarePlugin) Start() error {
+	p.mu.Lock()
+	cfg := p.config
+	p.started = true
+	p.mu.Unlock()
+
+	p.startMonitor(cfg)
+	p.startVSWRMonitor(cfg)
+	p.startCWID(cfg)
+	p.startDutyCycle(cfg)
+	return nil
 }
 
 // Name returns the plugin identifier
@@ -85,7 +253,8 @@ func (p *HardwarePlugin) Name() string {
 
 // RegisterRoutes adds the plugin's HTTP routes
 func (p *HardwarePlugin) RegisterRoutes(app *fiber.App) {
-	api := app.Group("/api/hardware")
+	api := APIGroup(app, "/hardware")
+	api.Use(p.dryRunMiddleware)
 
 	// Device control endpoints
 	api.Post("/init", p.handleInit)
@@ -97,14 +266,30 @@ func (p *HardwarePlugin) RegisterRoutes(app *fiber.App) {
 	// Register access endpoints
 	api.Get("/register/:addr", p.handleReadRegister)
 	api.Post("/register/:addr", p.handleWriteRegister)
+	api.Get("/register/:addr/decoded", p.handleReadRegisterDecoded)
+	api.Patch("/register/:addr/decoded", p.handleUpdateRegisterFields)
 	api.Get("/registers", p.handleReadAllRegisters)
 	api.Post("/registers/burst", p.handleBurstWrite)
+	api.Post("/spi/transfer", p.handleSPITransfer)
 
 	// High-level control endpoints
 	api.Post("/frequency/rx", p.handleSetRxFrequency)
 	api.Get("/frequency/rx", p.handleGetRxFrequency)
 	api.Post("/frequency/tx", p.handleSetTxFrequency)
 	api.Get("/frequency/tx", p.handleGetTxFrequency)
+	api.Post("/frequency/rx/step", p.handleStepFrequency(false))
+	api.Post("/frequency/tx/step", p.handleStepFrequency(true))
+	api.Get("/frequency/convert", p.handleConvertFrequency)
+
+	api.Post("/configure", p.handleConfigure)
+
+	// Named channels
+	api.Get("/channels", p.handleListChannels)
+	api.Post("/channels", p.handleSaveChannel)
+	api.Get("/channels/:name", p.handleGetChannel)
+	api.Put("/channels/:name", p.handleSaveChannel)
+	api.Delete("/channels/:name", p.handleDeleteChannel)
+	api.Post("/channel/:name/activate", p.handleActivateChannel)
 
 	api.Post("/mode", p.handleSetMode)
 	api.Get("/mode", p.handleGetMode)
@@ -119,24 +304,198 @@ func (p *HardwarePlugin) RegisterRoutes(app *fiber.App) {
 	api.Post("/enable/pa", p.handleEnablePA)
 
 	api.Get("/pll-status", p.handleGetPLLStatus)
+	api.Get("/temperature", p.handleGetTemperature)
+	api.Post("/sweep", p.handleSweep)
+
+	// Alarm monitor
+	api.Get("/alarms", p.handleGetAlarms)
+	api.Delete("/alarms", p.handleClearAlarms)
 
 	// TX/RX switch control
 	api.Post("/txrx-switch", p.handleSetTxRxSwitch)
 	api.Get("/txrx-switch", p.handleGetTxRxSwitch)
 
+	// Reset pin control for board debugging
+	api.Post("/reset-pin", p.handleSetResetPin)
+	api.Get("/reset-pin", p.handleGetResetPin)
+
+	// External PA bias
+	api.Get("/pa-bias", p.handleGetPABias)
+	api.Post("/pa-bias", p.handleSetPABias)
+
+	// Forward/reflected power and VSWR protection
+	api.Get("/vswr", p.handleGetVSWR)
+	api.Delete("/vswr/trip", p.handleClearVSWRTrip)
+
+	// Board profiles
+	api.Get("/boards", p.handleListBoards)
+	api.Post("/boards/defaults", p.handleApplyBoardDefaults)
+	api.Get("/boards/:name", p.handleGetBoard)
+
+	// Front-end calibration
+	api.Get("/calibration", p.handleGetCalibration)
+	api.Get("/calibration/wizard", p.handleCalibrationWizard)
+	api.Post("/calibration/rx", p.handleCalibrateRx)
+	api.Post("/calibration/tx", p.handleCalibrateTx)
+	api.Delete("/calibration/:path/:frequency", p.handleDeleteCalibration)
+
+	// I/Q recording
+	api.Post("/capture/start", p.handleCaptureStart)
+	api.Post("/capture/stop", p.handleCaptureStop)
+	api.Get("/capture/status", p.handleCaptureStatus)
+	api.Get("/captures", p.handleListCaptures)
+	api.Get("/captures/:name/annotations", p.handleGetAnnotations)
+	api.Put("/captures/:name/annotations", p.handleSetAnnotations)
+	api.Post("/captures/:name/annotations", p.handleAddAnnotation)
+
+	// Band plan
+	api.Get("/bandplan", p.handleGetBandPlan)
+
+	// Persistent JSON-RPC control channel
+	api.Get("/ws", websocket.New(p.handleControlChannel))
+
+	// Transmit test signal
+	api.Post("/testsignal", p.handleTestSignal)
+	api.Post("/testsignal/stop", p.handleTestSignalStop)
+	api.Get("/testsignal", p.handleTestSignalStatus)
+
+	// Satellite Doppler correction
+	api.Get("/doppler", p.requireDoppler, p.handleDopplerStatus)
+	api.Post("/doppler/start", p.requireDoppler, p.handleDopplerStart)
+	api.Post("/doppler/stop", p.requireDoppler, p.handleDopplerStop)
+	api.Get("/doppler/passes", p.requireDoppler, p.handleListPasses)
+	api.Get("/doppler/satellites", p.requireDoppler, p.handleListSatellites)
+	api.Put("/doppler/tle", p.requireDoppler, p.handleSetTLEs)
+
+	// Station identification
+	api.Get("/cwid", p.handleGetCWID)
+	api.Put("/cwid", p.handleSetCWID)
+	api.Post("/cwid/send", p.handleSendCWID)
+
+	// TX time accounting
+	api.Get("/duty-cycle", p.handleGetDutyCycle)
+
 	slog.Info("Hardware plugin routes registered")
 }
 
-// Shutdown performs cleanup
+// Shutdown stops the alarm monitor
 func (p *HardwarePlugin) Shutdown() error {
-	// No persistent resources to clean up
+	p.stopMonitor()
+	p.stopVSWRMonitor()
+	p.stopCWID()
+	p.stopDutyCycle()
+	p.stopCapture()
+	p.stopTestSignal()
+	p.stopDoppler()
+	p.stopTxTimeout()
+	p.bias.cancel()
+
+	hardwarePluginMu.Lock()
+	if hardwarePlugin == p {
+		hardwarePlugin = nil
+	}
+	hardwarePluginMu.Unlock()
+	return nil
+}
+
+// Reload applies new device paths and pin assignments at runtime
+// Takes effect on the next operation since controllers are transient
+func (p *HardwarePlugin) Reload(config interface{}) error {
+	cfg, err := configAs[HardwareConfig]("hardware", config)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	applyHardwareDefaults(&cfg)
+
+	p.mu.Lock()
+	previous := p.config
+	p.config = cfg
+	started := p.started
+	p.mu.Unlock()
+
+	if previous.PABias != cfg.PABias {
+		p.bias.reset()
+	}
+	// Workers that have not started yet pick up the new settings in Start
+	if started {
+		p.restartWorkers(previous, cfg)
+	}
+
+	slog.Info("Hardware config reloaded",
+		"spi_device", cfg.SX1255.SPIDevice,
+		"gpio_chip", cfg.SX1255.GPIOChip,
+		"reset_pin", cfg.SX1255.ResetPin,
+		"tx_rx_pin", cfg.SX1255.TxRxPin)
 	return nil
 }
 
+// restartWorkers restarts the background workers whose settings changed
+func (p *HardwarePlugin) restartWorkers(previous, cfg HardwareConfig) {
+	if previous.Monitor != cfg.Monitor {
+		p.stopMonitor()
+		p.startMonitor(cfg)
+	}
+	if previous.VSWR != cfg.VSWR {
+		p.stopVSWRMonitor()
+		p.startVSWRMonitor(cfg)
+	}
+	if previous.CWID != cfg.CWID {
+		p.stopCWID()
+		p.startCWID(cfg)
+	}
+	if previous.DutyCycle != cfg.DutyCycle {
+		p.stopDutyCycle()
+		p.startDutyCycle(cfg)
+	}
+}
+
+// getConfig returns the current hardware configuration
+func (p *HardwarePlugin) getConfig() HardwareConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config
+}
+
+// startMonitor starts the alarm monitor when an interval is configured
+func (p *HardwarePlugin) startMonitor(cfg HardwareConfig) {
+	if cfg.Monitor.Interval <= 0 {
+		slog.Info("Hardware monitor disabled")
+		return
+	}
+
+	monitor := newHardwareMonitor(p, time.Duration(cfg.Monitor.Interval)*time.Second, cfg.Monitor.History)
+	p.mu.Lock()
+	p.monitor = monitor
+	p.mu.Unlock()
+	monitor.Start()
+}
+
+// stopMonitor stops the alarm monitor if it is running
+func (p *HardwarePlugin) stopMonitor() {
+	p.mu.Lock()
+	monitor := p.monitor
+	p.monitor = nil
+	p.mu.Unlock()
+
+	if monitor != nil {
+		monitor.Stop()
+	}
+}
+
+// getMonitor returns the running alarm monitor or nil when disabled
+func (p *HardwarePlugin) getMonitor() *HardwareMonitor {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.monitor
+}
+
 // createController creates a temporary controller for an operation
 func (p *HardwarePlugin) createController() (*SX1255Controller, error) {
-	cfg := p.config.SX1255
-	return NewSX1255Controller(
+	cfg := p.getConfig().SX1255
+	controller, err := NewSX1255Controller(
 		cfg.SPIDevice,
 		cfg.SPISpeed,
 		cfg.GPIOChip,
@@ -144,10 +503,33 @@ func (p *HardwarePlugin) createController() (*SX1255Controller, error) {
 		cfg.TxRxPin,
 		cfg.ClockFreq,
 	)
+	if err != nil {
+		return nil, err
+	}
+	controller.tuned = p.applyCalibration
+	controller.spi.shadow = &p.shadow
+	controller.gpio.shadow = &p.shadow
+	p.shadow.setPin(pinReset, false) // the lines are requested low
+	p.shadow.setPin(pinTxRx, false)
+	return controller, nil
 }
 
 // withController executes a function with a temporary controller
+// The shared controller is used instead while a control channel holds one open
 func (p *HardwarePlugin) withController(fn func(*SX1255Controller) error) error {
+	p.busMu.Lock()
+	defer p.busMu.Unlock()
+
+	lock, err := p.lockBus()
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
+	if p.shared != nil {
+		return fn(p.shared)
+	}
+
 	controller, err := p.createController()
 	if err != nil {
 		return err
@@ -157,13 +539,78 @@ func (p *HardwarePlugin) withController(fn func(*SX1255Controller) error) error
 	return fn(controller)
 }
 
+// acquireController opens the shared controller, or adds a reference to it
+// GPIO lines are requested exclusively, so all sessions must use it while it is open
+func (p *HardwarePlugin) acquireController() error {
+	p.busMu.Lock()
+	defer p.busMu.Unlock()
+
+	if p.shared == nil {
+		controller, err := p.createController()
+		if err != nil {
+			return err
+		}
+		p.shared = controller
+	}
+	p.sharedRefs++
+	return nil
+}
+
+// releaseController drops a reference and closes the shared controller after the last one
+func (p *HardwarePlugin) releaseController() {
+	p.busMu.Lock()
+	defer p.busMu.Unlock()
+
+	p.sharedRefs--
+	if p.sharedRefs > 0 || p.shared == nil {
+		return
+	}
+	if err := p.shared.Close(); err != nil {
+		slog.Warn("Failed to close shared hardware controller", "error", err)
+	}
+	p.shared = nil
+}
+
+// controllerMode reports whether operations use transient or shared controllers
+func (p *HardwarePlugin) controllerMode() string {
+	p.busMu.Lock()
+	defer p.busMu.Unlock()
+	if p.shared != nil {
+		return "shared"
+	}
+	return "transient"
+}
+
+// withSPI executes a function with a temporary SPI connection only
+// Used for read-only polling that must not reinitialize GPIO lines
+func (p *HardwarePlugin) withSPI(fn func(*SPIDevice) error) error {
+	p.busMu.Lock()
+	defer p.busMu.Unlock()
+
+	lock, err := p.lockBus()
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
+	cfg := p.getConfig().SX1255
+	spi, err := NewSPIDevice(cfg.SPIDevice, cfg.SPISpeed)
+	if err != nil {
+		return err
+	}
+	defer spi.Close()
+	spi.shadow = &p.shadow
+
+	return fn(spi)
+}
+
 // Device control handlers
 
 func (p *HardwarePlugin) handleInit(c *fiber.Ctx) error {
 	var version string
 	var info map[string]interface{}
 
-	err := p.withController(func(ctrl *SX1255Controller) error {
+	err := p.withRequestController(c, func(ctrl *SX1255Controller) error {
 		// Verify communication
 		if err := ctrl.Initialize(); err != nil {
 			return err
@@ -182,11 +629,11 @@ func (p *HardwarePlugin) handleInit(c *fiber.Ctx) error {
 	})
 
 	if err != nil {
-		slog.Error("Failed to initialize hardware", "error", err)
+		slog.ErrorContext(c.UserContext(), "Failed to initialize hardware", "error", err)
 		return SendError(c, 500, err)
 	}
 
-	slog.Info("Hardware connection verified", "version", version)
+	slog.InfoContext(c.UserContext(), "Hardware connection verified", "version", version)
 	return SendSuccess(c, map[string]interface{}{
 		"version": version,
 		"info":    info,
@@ -194,16 +641,16 @@ func (p *HardwarePlugin) handleInit(c *fiber.Ctx) error {
 }
 
 func (p *HardwarePlugin) handleReset(c *fiber.Ctx) error {
-	err := p.withController(func(ctrl *SX1255Controller) error {
+	err := p.withRequestController(c, func(ctrl *SX1255Controller) error {
 		return ctrl.Reset()
 	})
 
 	if err != nil {
-		slog.Error("Failed to reset hardware", "error", err)
+		slog.ErrorContext(c.UserContext(), "Failed to reset hardware", "error", err)
 		return SendError(c, 500, err)
 	}
 
-	slog.Info("Hardware reset successful")
+	slog.InfoContext(c.UserContext(), "Hardware reset successful")
 	return SendSuccess(c, nil, "Hardware reset successful")
 }
 
@@ -248,8 +695,8 @@ func (p *HardwarePlugin) handleStatus(c *fiber.Ctx) error {
 
 func (p *HardwarePlugin) handleInfo(c *fiber.Ctx) error {
 	return SendSuccess(c, map[string]interface{}{
-		"config": p.config,
-		"mode":   "transient",
+		"config": p.getConfig(),
+		"mode":   p.controllerMode(),
 	}, "")
 }
 
@@ -298,15 +745,26 @@ func (p *HardwarePlugin) handleWriteRegister(c *fiber.Ctx) error {
 		return SendErrorMessage(c, 400, "Invalid request body")
 	}
 
-	err = p.withController(func(ctrl *SX1255Controller) error {
-		return ctrl.WriteRegister(uint8(addr), req.Value)
+	// Writes to RegMode and the TX frequency word go through the same interlocks,
+	// band plan and TX time limit as keying and tuning
+	keying := uint8(addr) == RegMode && modeEnablesTx(req.Value)
+	if keying {
+		if err := checkTxAllowed(); err != nil {
+			return SendError(c, 423, err)
+		}
+	}
+	override, err := p.bandPlanOverride(c)
+	if err != nil {
+		return SendError(c, 403, err)
+	}
+	err = p.registerSession(c, keying, override, func(*SX1255Controller) ([]registerWrite, error) {
+		return []registerWrite{{Address: uint8(addr), Value: req.Value}}, nil
 	})
-
 	if err != nil {
-		return SendError(c, 500, err)
+		return sendTxError(c, err)
 	}
 
-	slog.Info("Register write", "address", fmt.Sprintf("0x%02X", addr), "value", fmt.Sprintf("0x%02X", req.Value))
+	slog.InfoContext(c.UserContext(), "Register write", "address", fmt.Sprintf("0x%02X", addr), "value", fmt.Sprintf("0x%02X", req.Value))
 	return SendSuccess(c, nil, "Register written successfully")
 }
 
@@ -350,58 +808,86 @@ func (p *HardwarePlugin) handleReadAllRegisters(c *fiber.Ctx) error {
 	}, "")
 }
 
+// registerWrite is one register of a burst write
+type registerWrite struct {
+	Address uint8 `json:"address"`
+	Value   uint8 `json:"value"`
+}
+
+// writeRegisters returns a controller function writing regs in order
+func writeRegisters(regs []registerWrite) func(*SX1255Controller) error {
+	return func(ctrl *SX1255Controller) error {
+		for _, reg := range regs {
+			if err := ctrl.WriteRegister(reg.Address, reg.Value); err != nil {
+				return fmt.Errorf("failed to write register 0x%02X: %w", reg.Address, err)
+			}
+		}
+		return nil
+	}
+}
+
 func (p *HardwarePlugin) handleBurstWrite(c *fiber.Ctx) error {
 	var req struct {
-		Registers []struct {
-			Address uint8 `json:"address"`
-			Value   uint8 `json:"value"`
-		} `json:"registers"`
+		Registers []registerWrite `json:"registers"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
 		return SendErrorMessage(c, 400, "Invalid request body")
 	}
 
-	err := p.withController(func(ctrl *SX1255Controller) error {
-		// Write each register
-		for _, reg := range req.Registers {
-			if err := ctrl.WriteRegister(reg.Address, reg.Value); err != nil {
-				return fmt.Errorf("failed to write register 0x%02X: %w", reg.Address, err)
-			}
+	// The band plan is checked against the TX frequency the whole burst leaves, before
+	// anything is written, so the transmitter must not be retuned after it is keyed
+	keying := false
+	for _, reg := range req.Registers {
+		if keying && isTxFrequencyRegister(reg.Address) {
+			return SendErrorMessage(c, 400, "The TX frequency must be written before the RegMode write that keys the transmitter")
 		}
-		return nil
-	})
+		keying = keying || reg.Address == RegMode && modeEnablesTx(reg.Value)
+	}
+	if keying {
+		if err := checkTxAllowed(); err != nil {
+			return SendError(c, 423, err)
+		}
+	}
+	override, err := p.bandPlanOverride(c)
+	if err != nil {
+		return SendError(c, 403, err)
+	}
 
+	err = p.registerSession(c, keying, override, func(*SX1255Controller) ([]registerWrite, error) {
+		return req.Registers, nil
+	})
 	if err != nil {
-		return SendError(c, 500, err)
+		return sendTxError(c, err)
 	}
 
-	slog.Info("Burst write completed", "count", len(req.Registers))
+	slog.InfoContext(c.UserContext(), "Burst write completed", "count", len(req.Registers))
 	return SendSuccess(c, nil, fmt.Sprintf("Wrote %d registers successfully", len(req.Registers)))
 }
 
 // Frequency control handlers
 
 func (p *HardwarePlugin) handleSetRxFrequency(c *fiber.Ctx) error {
-	var req struct {
-		Frequency uint32 `json:"frequency"`
-	}
+	var req FrequencyRequest
 	if err := c.BodyParser(&req); err != nil {
-		return SendErrorMessage(c, 400, "Invalid request body")
+		return SendErrorMessage(c, 400, "Invalid request body: "+err.Error())
+	}
+	plan := p.getConfig().ChannelPlan
+	freq, err := req.resolve(plan)
+	if err != nil {
+		return SendError(c, 400, err)
 	}
 
-	err := p.withController(func(ctrl *SX1255Controller) error {
-		return ctrl.SetRxFrequency(req.Frequency)
+	_, err = p.tune(c, false, false, func(*SX1255Controller) (uint32, error) {
+		return freq, nil
 	})
 
 	if err != nil {
-		return SendError(c, 500, err)
+		return sendFrequencyError(c, err)
 	}
 
-	slog.Info("RX frequency set", "frequency", req.Frequency)
-	return SendSuccess(c, map[string]interface{}{
-		"frequency": req.Frequency,
-	}, "RX frequency set successfully")
+	slog.InfoContext(c.UserContext(), "RX frequency set", "frequency", freq)
+	return SendSuccess(c, plan.frequencyView(freq), "RX frequency set successfully")
 }
 
 func (p *HardwarePlugin) handleGetRxFrequency(c *fiber.Ctx) error {
@@ -417,31 +903,34 @@ func (p *HardwarePlugin) handleGetRxFrequency(c *fiber.Ctx) error {
 		return SendError(c, 500, err)
 	}
 
-	return SendSuccess(c, map[string]interface{}{
-		"frequency": freq,
-	}, "")
+	return SendSuccess(c, p.getConfig().ChannelPlan.frequencyView(freq), "")
 }
 
 func (p *HardwarePlugin) handleSetTxFrequency(c *fiber.Ctx) error {
-	var req struct {
-		Frequency uint32 `json:"frequency"`
-	}
+	var req FrequencyRequest
 	if err := c.BodyParser(&req); err != nil {
-		return SendErrorMessage(c, 400, "Invalid request body")
+		return SendErrorMessage(c, 400, "Invalid request body: "+err.Error())
+	}
+	plan := p.getConfig().ChannelPlan
+	freq, err := req.resolve(plan)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+	override, err := p.bandPlanOverride(c)
+	if err != nil {
+		return SendError(c, 403, err)
 	}
 
-	err := p.withController(func(ctrl *SX1255Controller) error {
-		return ctrl.SetTxFrequency(req.Frequency)
+	_, err = p.tune(c, true, override, func(*SX1255Controller) (uint32, error) {
+		return freq, nil
 	})
 
 	if err != nil {
-		return SendError(c, 500, err)
+		return sendFrequencyError(c, err)
 	}
 
-	slog.Info("TX frequency set", "frequency", req.Frequency)
-	return SendSuccess(c, map[string]interface{}{
-		"frequency": req.Frequency,
-	}, "TX frequency set successfully")
+	slog.InfoContext(c.UserContext(), "TX frequency set", "frequency", freq)
+	return SendSuccess(c, plan.frequencyView(freq), "TX frequency set successfully")
 }
 
 func (p *HardwarePlugin) handleGetTxFrequency(c *fiber.Ctx) error {
@@ -457,9 +946,7 @@ func (p *HardwarePlugin) handleGetTxFrequency(c *fiber.Ctx) error {
 		return SendError(c, 500, err)
 	}
 
-	return SendSuccess(c, map[string]interface{}{
-		"frequency": freq,
-	}, "")
+	return SendSuccess(c, p.getConfig().ChannelPlan.frequencyView(freq), "")
 }
 
 // Mode control handlers
@@ -472,33 +959,29 @@ func (p *HardwarePlugin) handleSetMode(c *fiber.Ctx) error {
 		return SendErrorMessage(c, 400, "Invalid request body")
 	}
 
-	var modeValue uint8
-	switch req.Mode {
-	case "sleep":
-		modeValue = ModeSleep
-	case "standby":
-		modeValue = ModeStandby
-	case "rx":
-		modeValue = ModeRx
-	case "tx":
-		modeValue = ModeTx
-	case "tx_full":
-		modeValue = ModeTxFull
-	case "full_duplex":
-		modeValue = ModeFullDuplex
-	default:
+	modeValue, ok := parseModeName(req.Mode)
+	if !ok {
 		return SendErrorMessage(c, 400, "Invalid mode. Use: sleep, standby, rx, tx, tx_full, or full_duplex")
 	}
+	if modeEnablesTx(modeValue) {
+		if err := checkTxAllowed(); err != nil {
+			return SendError(c, 423, err)
+		}
+	}
+	override, err := p.bandPlanOverride(c)
+	if err != nil {
+		return SendError(c, 403, err)
+	}
 
-	err := p.withController(func(ctrl *SX1255Controller) error {
+	err = p.withRequestTxController(c, modeEnablesTx(modeValue), override, func(ctrl *SX1255Controller) error {
 		return ctrl.SetMode(modeValue)
 	})
 
 	if err != nil {
-		return SendError(c, 500, err)
+		return sendTxError(c, err)
 	}
 
-	slog.Info("Mode set", "mode", req.Mode)
+	slog.InfoContext(c.UserContext(), "Mode set", "mode", req.Mode)
 	return SendSuccess(c, map[string]interface{}{
 		"mode": req.Mode,
 	}, "Mode set successfully")
@@ -517,28 +1000,55 @@ func (p *HardwarePlugin) handleGetMode(c *fiber.Ctx) error {
 		return SendError(c, 500, err)
 	}
 
-	var modeName string
-	switch modeValue {
+	return SendSuccess(c, map[string]interface{}{
+		"mode":       modeName(modeValue),
+		"mode_value": modeValue,
+	}, "")
+}
+
+// parseModeName converts a mode name to its RegMode value
+func parseModeName(name string) (uint8, bool) {
+	switch name {
+	case "sleep":
+		return ModeSleep, true
+	case "standby":
+		return ModeStandby, true
+	case "rx":
+		return ModeRx, true
+	case "tx":
+		return ModeTx, true
+	case "tx_full":
+		return ModeTxFull, true
+	case "full_duplex":
+		return ModeFullDuplex, true
+	default:
+		return 0, false
+	}
+}
+
+// modeEnablesTx reports whether a RegMode value enables the transmit path or PA
+func modeEnablesTx(value uint8) bool {
+	return value&(ModeBitTxEnable|ModeBitDriverEnable) != 0
+}
+
+// modeName converts a RegMode value to its mode name
+func modeName(value uint8) string {
+	switch value {
 	case ModeSleep:
-		modeName = "sleep"
+		return "sleep"
 	case ModeStandby:
-		modeName = "standby"
+		return "standby"
 	case ModeRx:
-		modeName = "rx"
+		return "rx"
 	case ModeTx:
-		modeName = "tx"
+		return "tx"
 	case ModeTxFull:
-		modeName = "tx_full"
+		return "tx_full"
 	case ModeFullDuplex:
-		modeName = "full_duplex"
+		return "full_duplex"
 	default:
-		modeName = "unknown"
+		return "unknown"
 	}
-
-	return SendSuccess(c, map[string]interface{}{
-		"mode":       modeName,
-		"mode_value": modeValue,
-	}, "")
 }
 
 // Gain control handlers
@@ -551,7 +1061,7 @@ func (p *HardwarePlugin) handleSetLNAGain(c *fiber.Ctx) error {
 		return SendErrorMessage(c, 400, "Invalid request body")
 	}
 
-	err := p.withController(func(ctrl *SX1255Controller) error {
+	err := p.withRequestController(c, func(ctrl *SX1255Controller) error {
 		return ctrl.SetLNAGain(req.Gain)
 	})
 
@@ -559,7 +1069,7 @@ func (p *HardwarePlugin) handleSetLNAGain(c *fiber.Ctx) error {
 		return SendError(c, 500, err)
 	}
 
-	slog.Info("LNA gain set", "gain", req.Gain)
+	slog.InfoContext(c.UserContext(), "LNA gain set", "gain", req.Gain)
 	return SendSuccess(c, nil, "LNA gain set successfully")
 }
 
@@ -571,7 +1081,7 @@ func (p *HardwarePlugin) handleSetPGAGain(c *fiber.Ctx) error {
 		return SendErrorMessage(c, 400, "Invalid request body")
 	}
 
-	err := p.withController(func(ctrl *SX1255Controller) error {
+	err := p.withRequestController(c, func(ctrl *SX1255Controller) error {
 		return ctrl.SetPGAGain(req.Gain)
 	})
 
@@ -579,7 +1089,7 @@ func (p *HardwarePlugin) handleSetPGAGain(c *fiber.Ctx) error {
 		return SendError(c, 500, err)
 	}
 
-	slog.Info("PGA gain set", "gain", req.Gain)
+	slog.InfoContext(c.UserContext(), "PGA gain set", "gain", req.Gain)
 	return SendSuccess(c, nil, "PGA gain set successfully")
 }
 
@@ -591,7 +1101,7 @@ func (p *HardwarePlugin) handleSetDACGain(c *fiber.Ctx) error {
 		return SendErrorMessage(c, 400, "Invalid request body")
 	}
 
-	err := p.withController(func(ctrl *SX1255Controller) error {
+	err := p.withRequestController(c, func(ctrl *SX1255Controller) error {
 		return ctrl.SetDACGain(req.Gain)
 	})
 
@@ -599,7 +1109,7 @@ func (p *HardwarePlugin) handleSetDACGain(c *fiber.Ctx) error {
 		return SendError(c, 500, err)
 	}
 
-	slog.Info("DAC gain set", "gain", req.Gain)
+	slog.InfoContext(c.UserContext(), "DAC gain set", "gain", req.Gain)
 	return SendSuccess(c, nil, "DAC gain set successfully")
 }
 
@@ -611,7 +1121,7 @@ func (p *HardwarePlugin) handleSetMixerGain(c *fiber.Ctx) error {
 		return SendErrorMessage(c, 400, "Invalid request body")
 	}
 
-	err := p.withController(func(ctrl *SX1255Controller) error {
+	err := p.withRequestController(c, func(ctrl *SX1255Controller) error {
 		return ctrl.SetMixerGain(req.Gain)
 	})
 
@@ -619,7 +1129,7 @@ func (p *HardwarePlugin) handleSetMixerGain(c *fiber.Ctx) error {
 		return SendError(c, 500, err)
 	}
 
-	slog.Info("Mixer gain set", "gain", req.Gain)
+	slog.InfoContext(c.UserContext(), "Mixer gain set", "gain", req.Gain)
 	return SendSuccess(c, nil, "Mixer gain set successfully")
 }
 
@@ -633,7 +1143,7 @@ func (p *HardwarePlugin) handleEnableRx(c *fiber.Ctx) error {
 		return SendErrorMessage(c, 400, "Invalid request body")
 	}
 
-	err := p.withController(func(ctrl *SX1255Controller) error {
+	err := p.withRequestController(c, func(ctrl *SX1255Controller) error {
 		return ctrl.EnableRx(req.Enable)
 	})
 
@@ -641,7 +1151,7 @@ func (p *HardwarePlugin) handleEnableRx(c *fiber.Ctx) error {
 		return SendError(c, 500, err)
 	}
 
-	slog.Info("RX enable", "enable", req.Enable)
+	slog.InfoContext(c.UserContext(), "RX enable", "enable", req.Enable)
 	return SendSuccess(c, nil, fmt.Sprintf("RX %s", map[bool]string{true: "enabled", false: "disabled"}[req.Enable]))
 }
 
@@ -652,16 +1162,25 @@ func (p *HardwarePlugin) handleEnableTx(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return SendErrorMessage(c, 400, "Invalid request body")
 	}
+	if req.Enable {
+		if err := checkTxAllowed(); err != nil {
+			return SendError(c, 423, err)
+		}
+	}
+	override, err := p.bandPlanOverride(c)
+	if err != nil {
+		return SendError(c, 403, err)
+	}
 
-	err := p.withController(func(ctrl *SX1255Controller) error {
+	err = p.withRequestTxController(c, req.Enable, override, func(ctrl *SX1255Controller) error {
 		return ctrl.EnableTx(req.Enable)
 	})
 
 	if err != nil {
-		return SendError(c, 500, err)
+		return sendTxError(c, err)
 	}
 
-	slog.Info("TX enable", "enable", req.Enable)
+	slog.InfoContext(c.UserContext(), "TX enable", "enable", req.Enable)
 	return SendSuccess(c, nil, fmt.Sprintf("TX %s", map[bool]string{true: "enabled", false: "disabled"}[req.Enable]))
 }
 
@@ -672,16 +1191,25 @@ func (p *HardwarePlugin) handleEnablePA(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return SendErrorMessage(c, 400, "Invalid request body")
 	}
+	if req.Enable {
+		if err := checkTxAllowed(); err != nil {
+			return SendError(c, 423, err)
+		}
+	}
+	override, err := p.bandPlanOverride(c)
+	if err != nil {
+		return SendError(c, 403, err)
+	}
 
-	err := p.withController(func(ctrl *SX1255Controller) error {
+	err = p.withRequestTxController(c, req.Enable, override, func(ctrl *SX1255Controller) error {
 		return ctrl.EnablePA(req.Enable)
 	})
 
 	if err != nil {
-		return SendError(c, 500, err)
+		return sendTxError(c, err)
 	}
 
-	slog.Info("PA enable", "enable", req.Enable)
+	slog.InfoContext(c.UserContext(), "PA enable", "enable", req.Enable)
 	return SendSuccess(c, nil, fmt.Sprintf("PA %s", map[bool]string{true: "enabled", false: "disabled"}[req.Enable]))
 }
 
@@ -715,13 +1243,23 @@ func (p *HardwarePlugin) handleSetTxRxSwitch(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return SendErrorMessage(c, 400, "Invalid request body")
 	}
+	if req.Tx {
+		if err := checkTxAllowed(); err != nil {
+			return SendError(c, 423, err)
+		}
+	}
+	override, err := p.bandPlanOverride(c)
+	if err != nil {
+		return SendError(c, 403, err)
+	}
 
-	err := p.withController(func(ctrl *SX1255Controller) error {
+	// Switching to TX acts as PTT and is checked against the band plan
+	err = p.withRequestTxController(c, req.Tx, override, func(ctrl *SX1255Controller) error {
 		return ctrl.SetTxRxSwitch(req.Tx)
 	})
 
 	if err != nil {
-		return SendError(c, 500, err)
+		return sendTxError(c, err)
 	}
 
 	mode := "RX"
@@ -729,7 +1267,7 @@ func (p *HardwarePlugin) handleSetTxRxSwitch(c *fiber.Ctx) error {
 		mode = "TX"
 	}
 
-	slog.Info("TX/RX switch set", "mode", mode)
+	slog.InfoContext(c.UserContext(), "TX/RX switch set", "mode", mode)
 	return SendSuccess(c, map[string]interface{}{
 		"tx":   req.Tx,
 		"mode": mode,
@@ -760,38 +1298,95 @@ func (p *HardwarePlugin) handleGetTxRxSwitch(c *fiber.Ctx) error {
 	}, "")
 }
 
-// Register the plugin
-func init() {
-	Register("hardware", func(config interface{}) (Plugin, error) {
-		configMap, ok := config.(map[string]interface{})
-		if !ok {
-			return nil, fmt.Errorf("invalid config for hardware plugin")
+// Reset pin handlers
+
+// errTxActive is returned when the reset pin is changed while transmitting
+var errTxActive = errors.New("transmitter is active; return to RX first")
+
+// resetPinLevel names a reset pin state
+func resetPinLevel(high bool) string {
+	if high {
+		return "high"
+	}
+	return "low"
+}
+
+// handleSetResetPin handles POST /api/hardware/reset-pin {"level": "high"|"low"}
+// High holds the SX1255 in reset, low releases it. Refused while TX or the PA
+// is enabled or the antenna switch is in TX, since a reset mid-transmission
+// leaves the RF chain in an undefined state.
+func (p *HardwarePlugin) handleSetResetPin(c *fiber.Ctx) error {
+	var req struct {
+		Level string `json:"level"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	var high bool
+	switch strings.ToLower(req.Level) {
+	case "high":
+		high = true
+	case "low":
+	default:
+		return SendErrorMessage(c, 400, "level must be high or low")
+	}
+
+	err := p.withRequestController(c, func(ctrl *SX1255Controller) error {
+		mode, err := ctrl.GetMode()
+		if err != nil {
+			return err
+		}
+		tx, err := ctrl.GetTxRxSwitch()
+		if err != nil {
+			return err
+		}
+		if modeEnablesTx(mode) || tx {
+			return errTxActive
+		}
+		return ctrl.SetResetPin(high)
+	})
+	if err != nil {
+		if errors.Is(err, errTxActive) {
+			return SendError(c, 409, err)
 		}
+		return SendError(c, 500, err)
+	}
 
-		var hwConfig HardwareConfig
+	level := resetPinLevel(high)
+	slog.InfoContext(c.UserContext(), "Reset pin set", "level", level)
+	return SendSuccess(c, map[string]interface{}{
+		"high":       high,
+		"level":      level,
+		"controller": p.controllerMode(),
+	}, fmt.Sprintf("Reset pin set %s", level))
+}
 
-		// Parse SX1255 config with helper functions for type conversion
-		if sx1255Cfg, ok := configMap["sx1255"].(map[string]interface{}); ok {
-			if spiDevice, ok := sx1255Cfg["spi_device"].(string); ok {
-				hwConfig.SX1255.SPIDevice = spiDevice
-			}
-			if spiSpeed, ok := toUint32(sx1255Cfg["spi_speed"]); ok {
-				hwConfig.SX1255.SPISpeed = spiSpeed
-			}
-			if gpioChip, ok := sx1255Cfg["gpio_chip"].(string); ok {
-				hwConfig.SX1255.GPIOChip = gpioChip
-			}
-			if resetPin, ok := toInt(sx1255Cfg["reset_pin"]); ok {
-				hwConfig.SX1255.ResetPin = resetPin
-			}
-			if txRxPin, ok := toInt(sx1255Cfg["tx_rx_pin"]); ok {
-				hwConfig.SX1255.TxRxPin = txRxPin
-			} else {
-				hwConfig.SX1255.TxRxPin = 13 // Default TX/RX pin
-			}
-			if clockFreq, ok := toUint32(sx1255Cfg["clock_freq"]); ok {
-				hwConfig.SX1255.ClockFreq = clockFreq
-			}
+func (p *HardwarePlugin) handleGetResetPin(c *fiber.Ctx) error {
+	var high bool
+
+	err := p.withController(func(ctrl *SX1255Controller) error {
+		var err error
+		high, err = ctrl.GetResetPin()
+		return err
+	})
+
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+
+	return SendSuccess(c, map[string]interface{}{
+		"high":       high,
+		"level":      resetPinLevel(high),
+		"controller": p.controllerMode(),
+	}, "")
+}
+
+// Register the plugin
+func init() {
+	Register("hardware", func(config interface{}) (Plugin, error) {
+		hwConfig, err := configAs[HardwareConfig]("hardware", config)
+		if err != nil {
+			return nil, err
 		}
 
 		slog.Info("Hardware plugin config parsed",
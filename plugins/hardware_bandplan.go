@@ -0,0 +1,336 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// txTimeoutEvent is published when the band plan unkeys a transmission that ran too long
+const txTimeoutEvent = "hardware.bandplan.timeout"
+
+// errBandPlan is wrapped by all band plan violations
+var errBandPlan = errors.New("band plan violation")
+
+// BandPlanBand is a TX frequency range in the band plan
+type BandPlanBand struct {
+	Name        string `yaml:"name" json:"name"`
+	Start       uint32 `yaml:"start" json:"start"`               // Hz
+	Stop        uint32 `yaml:"stop" json:"stop"`                 // Hz
+	MaxDuration int    `yaml:"max_duration" json:"max_duration"` // seconds of continuous TX, 0 = unlimited
+	Locked      bool   `yaml:"locked" json:"locked"`             // TX is never allowed, even with override
+}
+
+// contains reports whether freq lies within the band
+func (b BandPlanBand) contains(freq uint32) bool {
+	return freq >= b.Start && freq <= b.Stop
+}
+
+// BandPlanConfig holds the allowed and locked TX ranges
+type BandPlanConfig struct {
+	Enabled       bool           `yaml:"enabled"`
+	AllowOverride bool           `yaml:"allow_override"` // permits ?override=true on TX requests
+	Bands         []BandPlanBand `yaml:"bands"`
+}
+
+// check validates a TX frequency and returns the band it falls in
+// Locked bands always refuse; frequencies outside all bands only pass with override
+func (cfg BandPlanConfig) check(freq uint32, override bool) (*BandPlanBand, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var allowed *BandPlanBand
+	for i := range cfg.Bands {
+		band := cfg.Bands[i]
+		if !band.contains(freq) {
+			continue
+		}
+		if band.Locked {
+			return nil, fmt.Errorf("%w: %d Hz is in locked band %q", errBandPlan, freq, band.Name)
+		}
+		if allowed == nil {
+			allowed = &band
+		}
+	}
+
+	if allowed == nil && !override {
+		return nil, fmt.Errorf("%w: %d Hz is outside the allowed TX bands", errBandPlan, freq)
+	}
+	return allowed, nil
+}
+
+// bandPlanOverride reads the override flag of a request
+// Returns an error when override is requested but not permitted by the configuration
+func (p *HardwarePlugin) bandPlanOverride(c *fiber.Ctx) (bool, error) {
+	if !c.QueryBool("override") {
+		return false, nil
+	}
+	if err := p.checkBandPlanOverride(); err != nil {
+		return false, err
+	}
+	slog.WarnContext(c.UserContext(), "Band plan override requested", "path", c.Path())
+	return true, nil
+}
+
+// checkBandPlanOverride returns an error unless the configuration permits overrides
+func (p *HardwarePlugin) checkBandPlanOverride() error {
+	if !p.getConfig().BandPlan.AllowOverride {
+		return fmt.Errorf("%w: override is disabled (hardware.bandplan.allow_override)", errBandPlan)
+	}
+	return nil
+}
+
+// checkTxBand validates the TX frequency currently set in the transceiver
+func (p *HardwarePlugin) checkTxBand(ctrl *SX1255Controller, override bool) (*BandPlanBand, error) {
+	freq, err := ctrl.GetTxFrequency()
+	if err != nil {
+		return nil, err
+	}
+	return p.getConfig().BandPlan.check(freq, override)
+}
+
+// sendTxError maps band plan violations to 403, the TX interlocks and duty cycle limits to 409 and everything else to 500
+func sendTxError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errBandPlan) {
+		return SendError(c, 403, err)
+	}
+	if errors.Is(err, errPABias) || errors.Is(err, errVSWRTrip) || errors.Is(err, errDutyCycle) {
+		return SendError(c, 409, err)
+	}
+	return SendError(c, 500, err)
+}
+
+// checkTxInterlocks refuses keying while the VSWR protection has tripped, the PA bias is not ready
+// or an enforced duty cycle limit is used up
+func (p *HardwarePlugin) checkTxInterlocks() error {
+	if err := p.checkVSWR(); err != nil {
+		return err
+	}
+	if err := p.checkDutyCycle(); err != nil {
+		return err
+	}
+	return p.checkPABias()
+}
+
+// withTxController runs fn in a controller session guarded by the band plan
+// When keying, the current TX frequency must be allowed; the TX time limit follows the resulting mode
+func (p *HardwarePlugin) withTxController(keying, override bool, fn func(*SX1255Controller) error) error {
+	return p.txSession(p.withController, keying, override, fn)
+}
+
+// txSession runs fn through session after the interlock and band plan checks
+// The TX time limit is only armed for real controllers.
+func (p *HardwarePlugin) txSession(session func(func(*SX1255Controller) error) error, keying, override bool, fn func(*SX1255Controller) error) error {
+	if keying {
+		if err := p.checkTxInterlocks(); err != nil {
+			return err
+		}
+	}
+	return session(func(ctrl *SX1255Controller) error {
+		var band *BandPlanBand
+		if keying {
+			var err error
+			if band, err = p.checkTxBand(ctrl, override); err != nil {
+				return err
+			}
+		}
+		if err := fn(ctrl); err != nil {
+			return err
+		}
+		if ctrl.Simulated() {
+			return nil
+		}
+		mode, err := ctrl.GetMode()
+		if err != nil {
+			return err
+		}
+		p.updateTxTimeout(mode, band, keying && override)
+		return nil
+	})
+}
+
+// isTxFrequencyRegister reports whether addr holds a byte of the TX frequency word
+func isTxFrequencyRegister(addr uint8) bool {
+	return addr >= RegFrfhTx && addr <= RegFrflTx
+}
+
+// txFrequencyAfter returns the TX frequency the transceiver is tuned to once regs are written
+func txFrequencyAfter(ctrl *SX1255Controller, regs []registerWrite) (uint32, error) {
+	word := [3]uint8{}
+	for i := range word {
+		value, err := ctrl.ReadRegister(RegFrfhTx + uint8(i))
+		if err != nil {
+			return 0, err
+		}
+		word[i] = value
+	}
+	for _, reg := range regs {
+		if isTxFrequencyRegister(reg.Address) {
+			word[reg.Address-RegFrfhTx] = reg.Value
+		}
+	}
+	frf := uint32(word[0])<<16 | uint32(word[1])<<8 | uint32(word[2])
+	return ctrl.frequencyFromWord(frf), nil
+}
+
+// registerSession writes the registers returned by plan in one controller session, with the
+// checks of the mode and frequency handlers: writes keying the transmitter pass the interlocks,
+// and when they key TX or change the TX frequency word, the TX frequency they leave must be
+// allowed by the band plan before anything is written. The TX time limit follows the result.
+// plan runs inside the session, so read-modify-write updates see the current registers.
+func (p *HardwarePlugin) registerSession(c *fiber.Ctx, keying, override bool, plan func(*SX1255Controller) ([]registerWrite, error)) error {
+	if keying {
+		if err := p.checkTxInterlocks(); err != nil {
+			return err
+		}
+	}
+	return p.withRequestController(c, func(ctrl *SX1255Controller) error {
+		regs, err := plan(ctrl)
+		if err != nil {
+			return err
+		}
+		retunes, writesMode := false, false
+		for _, reg := range regs {
+			retunes = retunes || isTxFrequencyRegister(reg.Address)
+			writesMode = writesMode || reg.Address == RegMode
+		}
+
+		var band *BandPlanBand
+		checked := keying || retunes
+		if checked {
+			freq, err := txFrequencyAfter(ctrl, regs)
+			if err != nil {
+				return err
+			}
+			if band, err = p.getConfig().BandPlan.check(freq, override); err != nil {
+				return err
+			}
+		}
+		if err := writeRegisters(regs)(ctrl); err != nil {
+			return err
+		}
+		if ctrl.Simulated() || !checked && !writesMode {
+			return nil
+		}
+		mode, err := ctrl.GetMode()
+		if err != nil {
+			return err
+		}
+		p.updateTxTimeout(mode, band, checked && override)
+		return nil
+	})
+}
+
+// updateTxTimeout arms the band's TX time limit while the transmitter is keyed and cancels it otherwise
+// A nil band without override leaves a running limit untouched
+func (p *HardwarePlugin) updateTxTimeout(mode uint8, band *BandPlanBand, override bool) {
+	p.observeTx(mode)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	keyed := modeEnablesTx(mode)
+	if keyed && !override && band == nil {
+		return
+	}
+	if !keyed || override || band.MaxDuration <= 0 {
+		if p.txTimer != nil {
+			p.txTimer.Stop()
+			p.txTimer = nil
+		}
+		return
+	}
+
+	// A running timer is kept so re-keying cannot extend the limit
+	if p.txTimer == nil {
+		limit := time.Duration(band.MaxDuration) * time.Second
+		name := band.Name
+		p.txTimer = time.AfterFunc(limit, func() {
+			p.txTimeout(name, limit)
+		})
+	}
+}
+
+// txTimeout unkeys the transmitter after the band's maximum TX duration
+func (p *HardwarePlugin) txTimeout(band string, limit time.Duration) {
+	p.mu.Lock()
+	p.txTimer = nil
+	p.mu.Unlock()
+
+	seq := p.getConfig().Sequencing
+	err := p.withController(func(ctrl *SX1255Controller) error {
+		mode, err := ctrl.GetMode()
+		if err != nil {
+			return err
+		}
+		mode &^= ModeBitTxEnable | ModeBitDriverEnable
+		rx := false
+		return applyModeAndSwitch(ctrl, &mode, &rx, seq)
+	})
+
+	data := map[string]interface{}{
+		"band":         band,
+		"max_duration": limit.Seconds(),
+	}
+	if err != nil {
+		slog.Error("Failed to unkey transmitter after TX time limit", "band", band, "error", err)
+		data["error"] = err.Error()
+	} else {
+		slog.Warn("Transmitter unkeyed after TX time limit", "band", band, "max_duration", limit)
+	}
+	PublishEvent(txTimeoutEvent, hardwareMonitorEventSource, data)
+}
+
+// stopTxTimeout cancels a pending TX time limit
+func (p *HardwarePlugin) stopTxTimeout() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.txTimer != nil {
+		p.txTimer.Stop()
+		p.txTimer = nil
+	}
+}
+
+// handleGetBandPlan handles GET /api/hardware/bandplan?frequency=
+// Returns the band plan, and the verdict for a frequency when one is given
+func (p *HardwarePlugin) handleGetBandPlan(c *fiber.Ctx) error {
+	cfg := p.getConfig().BandPlan
+	bands := cfg.Bands
+	if bands == nil {
+		bands = []BandPlanBand{}
+	}
+
+	p.mu.RLock()
+	txLimited := p.txTimer != nil
+	p.mu.RUnlock()
+
+	data := fiber.Map{
+		"enabled":        cfg.Enabled,
+		"allow_override": cfg.AllowOverride,
+		"bands":          bands,
+		"tx_limited":     txLimited,
+	}
+
+	if c.Query("frequency") != "" {
+		freq := c.QueryInt("frequency")
+		if freq <= 0 {
+			return SendErrorMessage(c, 400, "Invalid frequency")
+		}
+		band, err := cfg.check(uint32(freq), false)
+		check := fiber.Map{
+			"frequency": freq,
+			"allowed":   err == nil,
+			"band":      band,
+		}
+		if err != nil {
+			check["reason"] = err.Error()
+		}
+		data["check"] = check
+	}
+
+	return SendSuccess(c, data, "")
+}
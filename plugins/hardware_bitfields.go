@@ -0,0 +1,352 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RegisterField is a named bit range of an SX1255 register
+type RegisterField struct {
+	Name        string
+	High        uint8 // most significant bit, inclusive
+	Low         uint8 // least significant bit
+	Description string
+	ReadOnly    bool
+	Values      map[uint8]string   // meanings of enumerated values
+	Format      func(uint8) string // meaning of computed values
+}
+
+// DecodedField is a register field in API responses
+type DecodedField struct {
+	Name        string `json:"name"`
+	Bits        string `json:"bits"`
+	Value       uint8  `json:"value"`
+	Meaning     string `json:"meaning,omitempty"`
+	Description string `json:"description"`
+	ReadOnly    bool   `json:"read_only"`
+}
+
+// mask returns the field mask in register position
+func (f RegisterField) mask() uint8 {
+	width := f.High - f.Low + 1
+	return uint8((uint16(1)<<width)-1) << f.Low
+}
+
+// maxValue returns the largest value the field holds
+func (f RegisterField) maxValue() uint8 {
+	return f.mask() >> f.Low
+}
+
+// decode extracts the field from a register value
+func (f RegisterField) decode(reg uint8) DecodedField {
+	value := (reg & f.mask()) >> f.Low
+	bits := fmt.Sprintf("%d", f.Low)
+	if f.High != f.Low {
+		bits = fmt.Sprintf("%d:%d", f.High, f.Low)
+	}
+
+	meaning := ""
+	if f.Values != nil {
+		meaning = f.Values[value]
+		if meaning == "" {
+			meaning = "reserved"
+		}
+	} else if f.Format != nil {
+		meaning = f.Format(value)
+	}
+
+	return DecodedField{
+		Name:        f.Name,
+		Bits:        bits,
+		Value:       value,
+		Meaning:     meaning,
+		Description: f.Description,
+		ReadOnly:    f.ReadOnly,
+	}
+}
+
+// encode replaces the field in a register value
+func (f RegisterField) encode(reg uint8, value uint8) uint8 {
+	return (reg &^ f.mask()) | ((value << f.Low) & f.mask())
+}
+
+// parseValue accepts a raw number or the meaning of an enumerated value
+func (f RegisterField) parseValue(raw json.RawMessage) (uint8, error) {
+	var number int
+	if err := json.Unmarshal(raw, &number); err == nil {
+		if number < 0 || number > int(f.maxValue()) {
+			return 0, fmt.Errorf("field %s: value %d out of range 0-%d", f.Name, number, f.maxValue())
+		}
+		return uint8(number), nil
+	}
+
+	var name string
+	if err := json.Unmarshal(raw, &name); err != nil {
+		return 0, fmt.Errorf("field %s: value must be a number or a name", f.Name)
+	}
+	for value, meaning := range f.Values {
+		if meaning == name {
+			return value, nil
+		}
+	}
+	return 0, fmt.Errorf("field %s: unknown value %q", f.Name, name)
+}
+
+// Shared value tables
+var (
+	enabledValues = map[uint8]string{0: "disabled", 1: "enabled"}
+	pllBwValues   = map[uint8]string{0: "75 kHz", 1: "150 kHz", 2: "225 kHz", 3: "300 kHz"}
+)
+
+// frfField is the byte of a 24-bit PLL frequency word held by a register
+func frfField(description string) []RegisterField {
+	return []RegisterField{{Name: "frf", High: 7, Low: 0, Description: description}}
+}
+
+// RegisterFields lists the bitfields of each register
+var RegisterFields = map[uint8][]RegisterField{
+	RegMode: {
+		{Name: "driver_enable", High: 3, Low: 3, Description: "PA driver", Values: enabledValues},
+		{Name: "tx_enable", High: 2, Low: 2, Description: "TX path except the PA driver", Values: enabledValues},
+		{Name: "rx_enable", High: 1, Low: 1, Description: "RX path", Values: enabledValues},
+		{Name: "ref_enable", High: 0, Low: 0, Description: "Crystal oscillator and PDS", Values: enabledValues},
+	},
+	RegFrfhRx: frfField("RX frequency word bits 23:16"),
+	RegFrfmRx: frfField("RX frequency word bits 15:8"),
+	RegFrflRx: frfField("RX frequency word bits 7:0"),
+	RegFrfhTx: frfField("TX frequency word bits 23:16"),
+	RegFrfmTx: frfField("TX frequency word bits 15:8"),
+	RegFrflTx: frfField("TX frequency word bits 7:0"),
+	RegVersion: {
+		{Name: "major", High: 7, Low: 4, Description: "Full revision number", ReadOnly: true},
+		{Name: "minor", High: 3, Low: 0, Description: "Metal mask revision number", ReadOnly: true},
+	},
+	RegTxfe1: {
+		{Name: "dac_gain", High: 6, Low: 4, Description: "TX DAC gain", Values: map[uint8]string{
+			DacGainMinus9: "max - 9 dB",
+			DacGainMinus6: "max - 6 dB",
+			DacGainMinus3: "max - 3 dB",
+			DacGainMax:    "max (0 dBFS)",
+		}},
+		{Name: "mixer_gain", High: 3, Low: 0, Description: "TX mixer gain", Format: func(v uint8) string {
+			return fmt.Sprintf("%.1f dB", MinMixerGainDb+2*float64(v))
+		}},
+	},
+	RegTxfe2: {
+		{Name: "mixer_tank_cap", High: 5, Low: 3, Description: "TX mixer tank capacitance"},
+		{Name: "mixer_tank_res", High: 2, Low: 0, Description: "TX mixer tank resistance"},
+	},
+	RegTxfe3: {
+		{Name: "pll_bw", High: 6, Low: 5, Description: "TX PLL bandwidth", Values: pllBwValues},
+		{Name: "filter_bw", High: 4, Low: 0, Description: "TX analog filter bandwidth"},
+	},
+	RegTxfe4: {
+		{Name: "dac_bw", High: 2, Low: 0, Description: "TX DAC FIR bandwidth (number of taps)"},
+	},
+	RegRxfe1: {
+		{Name: "lna_gain", High: 7, Low: 5, Description: "RX LNA gain", Values: map[uint8]string{
+			LnaGainMax:     "max",
+			LnaGainMinus6:  "max - 6 dB",
+			LnaGainMinus12: "max - 12 dB",
+			LnaGainMinus24: "max - 24 dB",
+			LnaGainMinus36: "max - 36 dB",
+			LnaGainMinus48: "max - 48 dB",
+		}},
+		{Name: "pga_gain", High: 4, Low: 1, Description: "RX baseband gain", Format: func(v uint8) string {
+			return fmt.Sprintf("%d dB", 2*int(v))
+		}},
+		{Name: "zin_200", High: 0, Low: 0, Description: "LNA input impedance", Values: map[uint8]string{0: "50 ohm", 1: "200 ohm"}},
+	},
+	RegRxfe2: {
+		{Name: "adc_bw", High: 7, Low: 5, Description: "RX ADC bandwidth"},
+		{Name: "adc_trim", High: 4, Low: 2, Description: "RX ADC trim for the reference clock"},
+		{Name: "pga_bw", High: 1, Low: 0, Description: "RX analog roofing filter bandwidth", Values: map[uint8]string{
+			0: "1500 kHz", 1: "1000 kHz", 2: "750 kHz", 3: "500 kHz",
+		}},
+	},
+	RegRxfe3: {
+		{Name: "pll_bw", High: 2, Low: 1, Description: "RX PLL bandwidth", Values: pllBwValues},
+		{Name: "adc_temp", High: 0, Low: 0, Description: "Temperature sensor on the RX ADC", Values: enabledValues},
+	},
+	RegIoMap: {
+		{Name: "dio0_mapping", High: 7, Low: 6, Description: "DIO0 signal mapping"},
+		{Name: "dio1_mapping", High: 5, Low: 4, Description: "DIO1 signal mapping"},
+		{Name: "dio2_mapping", High: 3, Low: 2, Description: "DIO2 signal mapping"},
+		{Name: "dio3_mapping", High: 1, Low: 0, Description: "DIO3 signal mapping"},
+	},
+	RegCkSel: {
+		{Name: "dig_loopback", High: 3, Low: 3, Description: "Digital loopback", Values: enabledValues},
+		{Name: "rf_loopback", High: 2, Low: 2, Description: "RF loopback", Values: enabledValues},
+		{Name: "ckout_enable", High: 1, Low: 1, Description: "CLK_OUT output", Values: enabledValues},
+		{Name: "tx_dac_clk_sel", High: 0, Low: 0, Description: "TX DAC clock source", Values: map[uint8]string{0: "crystal", 1: "CLK_IN"}},
+	},
+	RegStat: {
+		{Name: "eol", High: 3, Low: 3, Description: "End of life (supply low)", ReadOnly: true},
+		{Name: "xosc_ready", High: 2, Low: 2, Description: "Crystal oscillator ready", ReadOnly: true},
+		{Name: "pll_lock_rx", High: 1, Low: 1, Description: "RX PLL locked", ReadOnly: true},
+		{Name: "pll_lock_tx", High: 0, Low: 0, Description: "TX PLL locked", ReadOnly: true},
+	},
+	RegIism: {
+		{Name: "rx_disable", High: 7, Low: 7, Description: "I/Q interface RX disabled", Values: map[uint8]string{0: "no", 1: "yes"}},
+		{Name: "tx_disable", High: 6, Low: 6, Description: "I/Q interface TX disabled", Values: map[uint8]string{0: "no", 1: "yes"}},
+		{Name: "mode", High: 5, Low: 4, Description: "I/Q interface mode"},
+		{Name: "clk_div", High: 3, Low: 0, Description: "I/Q interface clock divider"},
+	},
+	RegDigBridge: {
+		{Name: "dig_bridge", High: 7, Low: 0, Description: "Digital bridge configuration"},
+	},
+}
+
+// errNoBitfields is returned for registers without a bitfield table
+var errNoBitfields = errors.New("no bitfield table for this register")
+
+// decodeRegister returns the named fields of a register value
+func decodeRegister(addr uint8, value uint8) ([]DecodedField, error) {
+	fields, ok := RegisterFields[addr]
+	if !ok {
+		return nil, errNoBitfields
+	}
+	decoded := make([]DecodedField, 0, len(fields))
+	for _, field := range fields {
+		decoded = append(decoded, field.decode(value))
+	}
+	return decoded, nil
+}
+
+// encodeRegister applies named field values to a register value
+func encodeRegister(addr uint8, reg uint8, values map[string]json.RawMessage) (uint8, error) {
+	fields, ok := RegisterFields[addr]
+	if !ok {
+		return 0, errNoBitfields
+	}
+
+	// Sorted so errors are reported deterministically
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var field *RegisterField
+		for i := range fields {
+			if fields[i].Name == name {
+				field = &fields[i]
+				break
+			}
+		}
+		if field == nil {
+			return 0, fmt.Errorf("unknown field %q", name)
+		}
+		if field.ReadOnly {
+			return 0, fmt.Errorf("field %s is read-only", name)
+		}
+		value, err := field.parseValue(values[name])
+		if err != nil {
+			return 0, err
+		}
+		reg = field.encode(reg, value)
+	}
+	return reg, nil
+}
+
+// decodedRegisterResponse formats a register with its fields
+func decodedRegisterResponse(addr uint8, value uint8, fields []DecodedField) map[string]interface{} {
+	return map[string]interface{}{
+		"address":     fmt.Sprintf("0x%02X", addr),
+		"value":       fmt.Sprintf("0x%02X", value),
+		"value_dec":   value,
+		"description": RegisterDescriptions[addr],
+		"fields":      fields,
+	}
+}
+
+// handleReadRegisterDecoded handles GET /api/hardware/register/:addr/decoded
+func (p *HardwarePlugin) handleReadRegisterDecoded(c *fiber.Ctx) error {
+	addr, err := c.ParamsInt("addr")
+	if err != nil || addr < 0 || addr > 0xFF {
+		return SendErrorMessage(c, 400, "Invalid register address")
+	}
+	if _, ok := RegisterFields[uint8(addr)]; !ok {
+		return SendError(c, 404, errNoBitfields)
+	}
+
+	var value uint8
+	err = p.withController(func(ctrl *SX1255Controller) error {
+		var err error
+		value, err = ctrl.ReadRegister(uint8(addr))
+		return err
+	})
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+
+	fields, _ := decodeRegister(uint8(addr), value)
+	return SendSuccess(c, decodedRegisterResponse(uint8(addr), value, fields), "")
+}
+
+// handleUpdateRegisterFields handles PATCH /api/hardware/register/:addr/decoded
+// Changes only the fields in the body with a read-modify-write; RegMode updates
+// that key the transmitter and TX frequency updates follow the maintenance mode and band plan
+func (p *HardwarePlugin) handleUpdateRegisterFields(c *fiber.Ctx) error {
+	addr, err := c.ParamsInt("addr")
+	if err != nil || addr < 0 || addr > 0xFF {
+		return SendErrorMessage(c, 400, "Invalid register address")
+	}
+	if _, ok := RegisterFields[uint8(addr)]; !ok {
+		return SendError(c, 404, errNoBitfields)
+	}
+
+	var req struct {
+		Fields map[string]json.RawMessage `json:"fields"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	if len(req.Fields) == 0 {
+		return SendErrorMessage(c, 400, "No fields to update")
+	}
+	// Validate the field names and values before touching the device
+	requested, err := encodeRegister(uint8(addr), 0, req.Fields)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+
+	keying := uint8(addr) == RegMode && modeEnablesTx(requested)
+	if keying {
+		if err := checkTxAllowed(); err != nil {
+			return SendError(c, 423, err)
+		}
+	}
+	override, err := p.bandPlanOverride(c)
+	if err != nil {
+		return SendError(c, 403, err)
+	}
+
+	var previous, value uint8
+	err = p.registerSession(c, keying, override, func(ctrl *SX1255Controller) ([]registerWrite, error) {
+		var err error
+		if previous, err = ctrl.ReadRegister(uint8(addr)); err != nil {
+			return nil, err
+		}
+		if value, err = encodeRegister(uint8(addr), previous, req.Fields); err != nil {
+			return nil, err
+		}
+		return []registerWrite{{Address: uint8(addr), Value: value}}, nil
+	})
+	if err != nil {
+		return sendTxError(c, err)
+	}
+
+	slog.InfoContext(c.UserContext(), "Register fields updated",
+		"address", fmt.Sprintf("0x%02X", addr),
+		"previous", fmt.Sprintf("0x%02X", previous),
+		"value", fmt.Sprintf("0x%02X", value))
+	fields, _ := decodeRegister(uint8(addr), value)
+	return SendSuccess(c, decodedRegisterResponse(uint8(addr), value, fields), "Register fields updated")
+}
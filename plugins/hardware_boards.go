@@ -0,0 +1,243 @@
+package plugins
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BoardProfile bundles the wiring of a board revision with its recommended front-end registers
+// Selecting a board with hardware.board fills in the sx1255 settings; keys set in
+// the config file still override the profile.
+type BoardProfile struct {
+	Name        string          `yaml:"name" json:"name"`
+	Description string          `yaml:"description" json:"description"`
+	SPIDevice   string          `yaml:"spi_device" json:"spi_device"`
+	SPISpeed    uint32          `yaml:"spi_speed" json:"spi_speed"`
+	GPIOChip    string          `yaml:"gpio_chip" json:"gpio_chip"`
+	ResetPin    int             `yaml:"reset_pin" json:"reset_pin"`
+	TxRxPin     int             `yaml:"tx_rx_pin" json:"tx_rx_pin"`
+	ClockFreq   uint32          `yaml:"clock_freq" json:"clock_freq"`
+	Registers   map[uint8]uint8 `yaml:"registers" json:"-"` // written by POST /hardware/boards/defaults
+}
+
+// BoardProfiles are the board revisions shipped with the manager
+var BoardProfiles = []BoardProfile{
+	{
+		Name:        "linht-r1",
+		Description: "LinHT handheld, first revision",
+		SPIDevice:   "/dev/spidev0.0",
+		SPISpeed:    500000,
+		GPIOChip:    "/dev/gpiochip0",
+		ResetPin:    22,
+		TxRxPin:     13,
+		ClockFreq:   32000000,
+		Registers: map[uint8]uint8{
+			RegTxfe1: 0x2E, // DAC -3dB, Mixer -9.5dB
+			RegTxfe2: 0x24, // Tank cap/res default
+			RegTxfe3: 0x60, // PLL BW default
+			RegTxfe4: 0x02, // DAC BW 32 taps
+			RegRxfe1: 0x2F, // LNA max, PGA 30dB, 200ohm
+			RegRxfe2: 0xA5, // ADC BW config
+			RegRxfe3: 0x06, // RX PLL BW
+			RegCkSel: 0x02, // CLK_OUT enabled
+		},
+	},
+}
+
+// boardRegisterAllowed reports whether a profile may set a register
+// Mode, frequencies and read-only registers are left to the operator.
+func boardRegisterAllowed(addr uint8) bool {
+	return addr >= RegTxfe1 && addr <= RegDigBridge && addr != RegStat
+}
+
+// validateBoards checks the profiles defined in hardware.boards
+func validateBoards(boards []BoardProfile) error {
+	seen := make(map[string]bool)
+	for _, board := range boards {
+		if board.Name == "" {
+			return fmt.Errorf("hardware.boards: every board needs a name")
+		}
+		if seen[board.Name] {
+			return fmt.Errorf("hardware.boards: board %q is defined twice", board.Name)
+		}
+		seen[board.Name] = true
+		if board.SPIDevice == "" || board.GPIOChip == "" || board.ClockFreq == 0 {
+			return fmt.Errorf("hardware.boards: board %q needs spi_device, gpio_chip and clock_freq", board.Name)
+		}
+		for addr := range board.Registers {
+			if !boardRegisterAllowed(addr) {
+				return fmt.Errorf("hardware.boards: board %q cannot set register 0x%02X", board.Name, addr)
+			}
+		}
+	}
+	return nil
+}
+
+// findBoard returns the named profile, preferring one defined in the config
+func findBoard(name string, custom []BoardProfile) (BoardProfile, bool) {
+	for _, boards := range [][]BoardProfile{custom, BoardProfiles} {
+		for _, board := range boards {
+			if board.Name == name {
+				return board, true
+			}
+		}
+	}
+	return BoardProfile{}, false
+}
+
+// BoardHardwareConfig returns the hardware settings used for keys missing from
+// the config file when board is selected (DefaultHardwareConfig for no board)
+func BoardHardwareConfig(board string, custom []BoardProfile) (HardwareConfig, error) {
+	cfg := DefaultHardwareConfig()
+	if board == "" {
+		return cfg, nil
+	}
+	profile, ok := findBoard(board, custom)
+	if !ok {
+		return cfg, fmt.Errorf("hardware.board: unknown board %q", board)
+	}
+	cfg.SX1255.SPIDevice = profile.SPIDevice
+	cfg.SX1255.SPISpeed = profile.SPISpeed
+	cfg.SX1255.GPIOChip = profile.GPIOChip
+	cfg.SX1255.ResetPin = profile.ResetPin
+	cfg.SX1255.TxRxPin = profile.TxRxPin
+	cfg.SX1255.ClockFreq = profile.ClockFreq
+	return cfg, nil
+}
+
+// boardView formats a profile for the API, with the registers like GET /hardware/registers
+func boardView(board BoardProfile, builtin bool) map[string]interface{} {
+	addrs := make([]int, 0, len(board.Registers))
+	for addr := range board.Registers {
+		addrs = append(addrs, int(addr))
+	}
+	sort.Ints(addrs)
+
+	registers := make([]map[string]interface{}, 0, len(addrs))
+	for _, addr := range addrs {
+		value := board.Registers[uint8(addr)]
+		registers = append(registers, map[string]interface{}{
+			"address":     fmt.Sprintf("0x%02X", addr),
+			"value":       fmt.Sprintf("0x%02X", value),
+			"value_dec":   value,
+			"description": RegisterDescriptions[uint8(addr)],
+		})
+	}
+
+	return map[string]interface{}{
+		"name":        board.Name,
+		"description": board.Description,
+		"builtin":     builtin,
+		"spi_device":  board.SPIDevice,
+		"spi_speed":   board.SPISpeed,
+		"gpio_chip":   board.GPIOChip,
+		"reset_pin":   board.ResetPin,
+		"tx_rx_pin":   board.TxRxPin,
+		"clock_freq":  board.ClockFreq,
+		"registers":   registers,
+	}
+}
+
+// boardOverrides lists the sx1255 keys whose configured value differs from the profile
+func boardOverrides(cfg HardwareConfig, board BoardProfile) []string {
+	overrides := []string{}
+	add := func(key string, differs bool) {
+		if differs {
+			overrides = append(overrides, key)
+		}
+	}
+	add("spi_device", cfg.SX1255.SPIDevice != board.SPIDevice)
+	add("spi_speed", board.SPISpeed != 0 && cfg.SX1255.SPISpeed != board.SPISpeed)
+	add("gpio_chip", cfg.SX1255.GPIOChip != board.GPIOChip)
+	add("reset_pin", cfg.SX1255.ResetPin != board.ResetPin)
+	add("tx_rx_pin", cfg.SX1255.TxRxPin != board.TxRxPin)
+	add("clock_freq", cfg.SX1255.ClockFreq != board.ClockFreq)
+	return overrides
+}
+
+// handleListBoards handles GET /api/hardware/boards
+// Lists the shipped and configured board profiles and the selected one with
+// the sx1255 keys the config file overrides
+func (p *HardwarePlugin) handleListBoards(c *fiber.Ctx) error {
+	cfg := p.getConfig()
+
+	boards := make([]map[string]interface{}, 0, len(cfg.Boards)+len(BoardProfiles))
+	for _, board := range cfg.Boards {
+		boards = append(boards, boardView(board, false))
+	}
+	for _, board := range BoardProfiles {
+		if !isBuiltinOnly(board.Name, cfg.Boards) {
+			continue // replaced by a profile from the config
+		}
+		boards = append(boards, boardView(board, true))
+	}
+
+	result := map[string]interface{}{
+		"selected": cfg.Board,
+		"boards":   boards,
+	}
+	if board, ok := findBoard(cfg.Board, cfg.Boards); ok {
+		result["overrides"] = boardOverrides(cfg, board)
+	}
+	return SendSuccess(c, result, "")
+}
+
+// isBuiltinOnly reports whether name is not defined in the config
+func isBuiltinOnly(name string, custom []BoardProfile) bool {
+	for _, board := range custom {
+		if board.Name == name {
+			return false
+		}
+	}
+	return true
+}
+
+// handleGetBoard handles GET /api/hardware/boards/:name
+func (p *HardwarePlugin) handleGetBoard(c *fiber.Ctx) error {
+	cfg := p.getConfig()
+	name := c.Params("name")
+	board, ok := findBoard(name, cfg.Boards)
+	if !ok {
+		return SendErrorMessage(c, 404, fmt.Sprintf("Unknown board %q", name))
+	}
+	return SendSuccess(c, boardView(board, isBuiltinOnly(name, cfg.Boards)), "")
+}
+
+// handleApplyBoardDefaults handles POST /api/hardware/boards/defaults?board=
+// Writes the recommended front-end registers of the selected board (or ?board=)
+func (p *HardwarePlugin) handleApplyBoardDefaults(c *fiber.Ctx) error {
+	cfg := p.getConfig()
+	name := c.Query("board", cfg.Board)
+	if name == "" {
+		return SendErrorMessage(c, 400, "No board selected (hardware.board or ?board=)")
+	}
+	board, ok := findBoard(name, cfg.Boards)
+	if !ok {
+		return SendErrorMessage(c, 404, fmt.Sprintf("Unknown board %q", name))
+	}
+
+	addrs := make([]int, 0, len(board.Registers))
+	for addr := range board.Registers {
+		addrs = append(addrs, int(addr))
+	}
+	sort.Ints(addrs)
+
+	err := p.withRequestController(c, func(ctrl *SX1255Controller) error {
+		for _, addr := range addrs {
+			if err := ctrl.WriteRegister(uint8(addr), board.Registers[uint8(addr)]); err != nil {
+				return fmt.Errorf("failed to write register 0x%02X: %w", addr, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+
+	slog.InfoContext(c.UserContext(), "Board defaults applied", "board", board.Name, "count", len(addrs))
+	return SendSuccess(c, boardView(board, isBuiltinOnly(name, cfg.Boards)),
+		fmt.Sprintf("Wrote %d registers of board %s", len(addrs), board.Name))
+}
@@ -0,0 +1,38 @@
+package plugins
+
+import (
+	"fmt"
+	"time"
+)
+
+// SPI bus lock defaults
+const (
+	BusLockNone           = "none" // sx1255.lock_file value that disables locking
+	DefaultBusLockTimeout = 1000   // milliseconds
+)
+
+// busLockPath returns the file to lock: the configured lock file, the spidev
+// node by default, or "" when locking is disabled
+// Only a configured lock file is created when missing
+func busLockPath(cfg HardwareConfig) (path string, create bool) {
+	switch cfg.SX1255.LockFile {
+	case BusLockNone:
+		return "", false
+	case "":
+		return cfg.SX1255.SPIDevice, false
+	default:
+		return cfg.SX1255.LockFile, true
+	}
+}
+
+// lockBus takes the SPI bus lock for one controller session, so other
+// processes (such as the modem daemon) cannot interleave transactions with ours
+func (p *HardwarePlugin) lockBus() (*fileLock, error) {
+	cfg := p.getConfig()
+	path, create := busLockPath(cfg)
+	lock, err := lockFile(path, create, time.Duration(cfg.SX1255.LockTimeout)*time.Millisecond)
+	if err != nil {
+		return nil, fmt.Errorf("SPI bus: %w", err)
+	}
+	return lock, nil
+}
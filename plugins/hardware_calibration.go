@@ -0,0 +1,713 @@
+package plugins
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Calibration defaults
+const (
+	DefaultCalibrationFile        = "/var/lib/linht/calibration.json"
+	DefaultCalibrationSamples     = 16384
+	DefaultCalibrationSettle      = 300     // milliseconds per TX trim step
+	DefaultCalibrationMaxDistance = 5000000 // Hz
+	DefaultCalibrationMinLevel    = -50.0   // dBFS
+	MaxCalibrationWizardSteps     = 200
+	calibrationToneOffset         = 10000 // Hz from the carrier, away from the LO leakage
+	calibrationAppliedEvent       = "hardware.calibration.applied"
+)
+
+// Calibration paths
+const (
+	CalibrationRX = "rx"
+	CalibrationTX = "tx"
+)
+
+// errNoPowerSensor is returned when the TX calibration has no forward power reading
+var errNoPowerSensor = errors.New("TX calibration needs the forward power sensor (hardware.vswr)")
+
+// CalibrationConfig holds the calibration table location and measurement settings
+type CalibrationConfig struct {
+	File        string  `yaml:"file"`
+	Samples     int     `yaml:"samples"`      // I/Q samples per RX measurement
+	Settle      int     `yaml:"settle"`       // milliseconds per TX trim step before the power is read
+	MaxDistance uint32  `yaml:"max_distance"` // Hz; table points further from the frequency are not applied
+	MinLevel    float64 `yaml:"min_level"`    // dBFS of signal needed to measure the I/Q imbalance
+}
+
+// applyCalibrationDefaults fills in unset calibration settings
+func applyCalibrationDefaults(cfg *CalibrationConfig) {
+	if cfg.File == "" {
+		cfg.File = DefaultCalibrationFile
+	}
+	if cfg.Samples <= 0 {
+		cfg.Samples = DefaultCalibrationSamples
+	}
+	if cfg.Settle <= 0 {
+		cfg.Settle = DefaultCalibrationSettle
+	}
+	if cfg.MaxDistance == 0 {
+		cfg.MaxDistance = DefaultCalibrationMaxDistance
+	}
+	if cfg.MinLevel == 0 {
+		cfg.MinLevel = DefaultCalibrationMinLevel
+	}
+}
+
+// RxCalibration holds the receive corrections measured at a frequency
+// The SX1255 has no I/Q correction registers, so the values are published
+// for the demodulator to apply to the baseband samples.
+type RxCalibration struct {
+	Frequency  uint32    `json:"frequency"`
+	DCOffsetI  float64   `json:"dc_offset_i"`        // fraction of full scale
+	DCOffsetQ  float64   `json:"dc_offset_q"`        // fraction of full scale
+	IQGain     *float64  `json:"iq_gain,omitempty"`  // dB, I relative to Q
+	IQPhase    *float64  `json:"iq_phase,omitempty"` // degrees from quadrature
+	Level      float64   `json:"level"`              // dBFS of the signal after DC removal
+	Samples    int       `json:"samples"`
+	MeasuredAt time.Time `json:"measured_at"`
+}
+
+// TxCalibration holds the TX mixer tank trim (RegTxfe2) found best at a frequency
+type TxCalibration struct {
+	Frequency  uint32    `json:"frequency"`
+	TankCap    uint8     `json:"tank_cap"`
+	TankRes    uint8     `json:"tank_res"`
+	ForwardDBm float64   `json:"forward_dbm"` // power measured with the chosen trim
+	MeasuredAt time.Time `json:"measured_at"`
+}
+
+// CalibrationTable is the stored calibration, sorted by frequency
+type CalibrationTable struct {
+	RX []RxCalibration `json:"rx"`
+	TX []TxCalibration `json:"tx"`
+}
+
+// ActiveCalibration is the table point applied for the current frequencies
+type ActiveCalibration struct {
+	RX *RxCalibration `json:"rx,omitempty"`
+	TX *TxCalibration `json:"tx,omitempty"`
+}
+
+// nearestRX returns the RX point closest to freq within maxDistance
+func (t *CalibrationTable) nearestRX(freq, maxDistance uint32) *RxCalibration {
+	var best *RxCalibration
+	for i := range t.RX {
+		if distance := freqDistance(t.RX[i].Frequency, freq); distance <= maxDistance &&
+			(best == nil || distance < freqDistance(best.Frequency, freq)) {
+			best = &t.RX[i]
+		}
+	}
+	return best
+}
+
+// nearestTX returns the TX point closest to freq within maxDistance
+func (t *CalibrationTable) nearestTX(freq, maxDistance uint32) *TxCalibration {
+	var best *TxCalibration
+	for i := range t.TX {
+		if distance := freqDistance(t.TX[i].Frequency, freq); distance <= maxDistance &&
+			(best == nil || distance < freqDistance(best.Frequency, freq)) {
+			best = &t.TX[i]
+		}
+	}
+	return best
+}
+
+// freqDistance returns |a - b|
+func freqDistance(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// setRX stores an RX point, replacing one at the same frequency
+func (t *CalibrationTable) setRX(point RxCalibration) {
+	t.RX = append(removeCalibration(t.RX, point.Frequency, func(p RxCalibration) uint32 { return p.Frequency }), point)
+	sort.Slice(t.RX, func(i, j int) bool { return t.RX[i].Frequency < t.RX[j].Frequency })
+}
+
+// setTX stores a TX point, replacing one at the same frequency
+func (t *CalibrationTable) setTX(point TxCalibration) {
+	t.TX = append(removeCalibration(t.TX, point.Frequency, func(p TxCalibration) uint32 { return p.Frequency }), point)
+	sort.Slice(t.TX, func(i, j int) bool { return t.TX[i].Frequency < t.TX[j].Frequency })
+}
+
+// removeCalibration returns points without the one at freq
+func removeCalibration[T any](points []T, freq uint32, frequency func(T) uint32) []T {
+	kept := points[:0]
+	for _, point := range points {
+		if frequency(point) != freq {
+			kept = append(kept, point)
+		}
+	}
+	return kept
+}
+
+// loadCalibrationTable reads the table; a missing file is an empty table
+func loadCalibrationTable(file string) (*CalibrationTable, error) {
+	table := &CalibrationTable{RX: []RxCalibration{}, TX: []TxCalibration{}}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return table, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, table); err != nil {
+		return nil, fmt.Errorf("invalid calibration table %s: %w", file, err)
+	}
+	return table, nil
+}
+
+// save writes the table atomically
+func (t *CalibrationTable) save(file string) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// calibrationState is the loaded table and the points applied to the transceiver
+type calibrationState struct {
+	file   string // the table was loaded from this file
+	table  *CalibrationTable
+	active ActiveCalibration
+}
+
+// calibrationTable returns the table, loading it on first use or after the file changed
+// Caller must hold p.calMu
+func (p *HardwarePlugin) calibrationTable(cfg CalibrationConfig) (*CalibrationTable, error) {
+	if p.calibration.table != nil && p.calibration.file == cfg.File {
+		return p.calibration.table, nil
+	}
+	table, err := loadCalibrationTable(cfg.File)
+	if err != nil {
+		return nil, err
+	}
+	p.calibration = calibrationState{file: cfg.File, table: table}
+	return table, nil
+}
+
+// updateCalibration changes the table and saves it
+func (p *HardwarePlugin) updateCalibration(update func(*CalibrationTable)) error {
+	cfg := p.getConfig().Calibration
+	p.calMu.Lock()
+	defer p.calMu.Unlock()
+	table, err := p.calibrationTable(cfg)
+	if err != nil {
+		return err
+	}
+	update(table)
+	return table.save(cfg.File)
+}
+
+// applyCalibration is called by the controller after a frequency change
+// For TX the mixer tank trim of the nearest point is written to RegTxfe2;
+// RX corrections are published for the demodulator.
+func (p *HardwarePlugin) applyCalibration(ctrl *SX1255Controller, tx bool, freq uint32) error {
+	cfg := p.getConfig().Calibration
+	p.calMu.Lock()
+	table, err := p.calibrationTable(cfg)
+	if err != nil {
+		p.calMu.Unlock()
+		slog.Warn("Failed to load calibration table", "file", cfg.File, "error", err)
+		return nil
+	}
+
+	// Validation previews the trim without changing the applied points
+	if ctrl.Simulated() {
+		point := table.nearestTX(freq, cfg.MaxDistance)
+		var tankCap, tankRes uint8
+		if point != nil {
+			tankCap, tankRes = point.TankCap, point.TankRes
+		}
+		p.calMu.Unlock()
+		if tx && point != nil {
+			return writeTankTrim(ctrl, tankCap, tankRes)
+		}
+		return nil
+	}
+
+	// The applied points are copies; the table may be rewritten by a calibration meanwhile
+	var changed bool
+	var event map[string]interface{}
+	var tank *TxCalibration
+	if tx {
+		var applied *TxCalibration
+		if point := table.nearestTX(freq, cfg.MaxDistance); point != nil {
+			copied := *point
+			applied, tank = &copied, &copied
+			event = map[string]interface{}{"path": CalibrationTX, "frequency": freq, "point": copied}
+		}
+		changed = !sameCalibration(applied, p.calibration.active.TX, func(t *TxCalibration) (uint32, time.Time) { return t.Frequency, t.MeasuredAt })
+		p.calibration.active.TX = applied
+	} else {
+		var applied *RxCalibration
+		if point := table.nearestRX(freq, cfg.MaxDistance); point != nil {
+			copied := *point
+			applied = &copied
+			event = map[string]interface{}{"path": CalibrationRX, "frequency": freq, "point": copied}
+		}
+		changed = !sameCalibration(applied, p.calibration.active.RX, func(r *RxCalibration) (uint32, time.Time) { return r.Frequency, r.MeasuredAt })
+		p.calibration.active.RX = applied
+	}
+	p.calMu.Unlock()
+
+	if tank != nil {
+		if err := writeTankTrim(ctrl, tank.TankCap, tank.TankRes); err != nil {
+			return err
+		}
+	}
+	if changed && event != nil {
+		PublishEvent(calibrationAppliedEvent, hardwareMonitorEventSource, event)
+	}
+	return nil
+}
+
+// sameCalibration reports whether two applied points are the same measurement
+func sameCalibration[T any](a, b *T, key func(*T) (uint32, time.Time)) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	freqA, timeA := key(a)
+	freqB, timeB := key(b)
+	return freqA == freqB && timeA.Equal(timeB)
+}
+
+// writeTankTrim sets the TX mixer tank capacitance and resistance, keeping the other RegTxfe2 bits
+func writeTankTrim(ctrl *SX1255Controller, tankCap, tankRes uint8) error {
+	reg, err := ctrl.ReadRegister(RegTxfe2)
+	if err != nil {
+		return err
+	}
+	value := reg&0xC0 | (tankCap&0x07)<<3 | tankRes&0x07
+	if value == reg {
+		return nil
+	}
+	return ctrl.WriteRegister(RegTxfe2, value)
+}
+
+// measureRxCalibration computes DC offset and I/Q imbalance from interleaved S16_LE I/Q frames
+// The imbalance needs a signal (a tone at the antenna input); below minLevel only the DC offset is kept.
+func measureRxCalibration(buf []byte, samples int, minLevel float64) RxCalibration {
+	var sumI, sumQ float64
+	for i := 0; i < samples; i++ {
+		sumI += float64(int16(binary.LittleEndian.Uint16(buf[i*captureBytesPerSample:])))
+		sumQ += float64(int16(binary.LittleEndian.Uint16(buf[i*captureBytesPerSample+2:])))
+	}
+	meanI, meanQ := sumI/float64(samples), sumQ/float64(samples)
+
+	var powerI, powerQ, cross float64
+	for i := 0; i < samples; i++ {
+		iv := float64(int16(binary.LittleEndian.Uint16(buf[i*captureBytesPerSample:]))) - meanI
+		qv := float64(int16(binary.LittleEndian.Uint16(buf[i*captureBytesPerSample+2:]))) - meanQ
+		powerI += iv * iv
+		powerQ += qv * qv
+		cross += iv * qv
+	}
+	powerI /= float64(samples)
+	powerQ /= float64(samples)
+	cross /= float64(samples)
+
+	result := RxCalibration{
+		DCOffsetI: meanI / 32768,
+		DCOffsetQ: meanQ / 32768,
+		Level:     math.Inf(-1),
+		Samples:   samples,
+	}
+	if total := powerI + powerQ; total > 0 {
+		result.Level = 10 * math.Log10(total/(32768*32768))
+	}
+	if result.Level >= minLevel && powerI > 0 && powerQ > 0 {
+		gain := 10 * math.Log10(powerI/powerQ)
+		phase := math.Asin(math.Max(-1, math.Min(1, cross/math.Sqrt(powerI*powerQ)))) * 180 / math.Pi
+		result.IQGain = &gain
+		result.IQPhase = &phase
+	}
+	if math.IsInf(result.Level, -1) {
+		result.Level = -200 // JSON has no infinity; a dead input reads as far below any signal
+	}
+	return result
+}
+
+// CalibrationWizardStep is one pending or finished step of a calibration plan
+type CalibrationWizardStep struct {
+	Path       string     `json:"path"`
+	Frequency  uint32     `json:"frequency"`
+	Done       bool       `json:"done"`
+	MeasuredAt *time.Time `json:"measured_at,omitempty"`
+	Endpoint   string     `json:"endpoint"`
+}
+
+// handleGetCalibration handles GET /api/hardware/calibration
+// Returns the table and the points applied for the current frequencies
+func (p *HardwarePlugin) handleGetCalibration(c *fiber.Ctx) error {
+	cfg := p.getConfig().Calibration
+	p.calMu.Lock()
+	defer p.calMu.Unlock()
+	table, err := p.calibrationTable(cfg)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	return SendSuccess(c, fiber.Map{
+		"file":         cfg.File,
+		"max_distance": cfg.MaxDistance,
+		"rx":           table.RX,
+		"tx":           table.TX,
+		"active":       p.calibration.active,
+	}, "")
+}
+
+// handleCalibrationWizard handles GET /api/hardware/calibration/wizard?start=&stop=&step=&path=rx,tx
+// Plans the calibration of a frequency range and marks the steps already in the table
+// within step/2, so a UI can walk the user through the remaining ones
+func (p *HardwarePlugin) handleCalibrationWizard(c *fiber.Ctx) error {
+	start, _ := strconv.ParseUint(c.Query("start"), 10, 32)
+	stop, _ := strconv.ParseUint(c.Query("stop"), 10, 32)
+	step, _ := strconv.ParseUint(c.Query("step"), 10, 32)
+	if stop == 0 {
+		stop = start
+	}
+	if start < MinFrequencyHz || stop > MaxFrequencyHz || start > stop {
+		return SendErrorMessage(c, 400, fmt.Sprintf("start and stop must be within %d-%d Hz", MinFrequencyHz, MaxFrequencyHz))
+	}
+	if step == 0 {
+		step = stop - start + 1
+	}
+	if (stop-start)/step+1 > MaxCalibrationWizardSteps {
+		return SendErrorMessage(c, 400, fmt.Sprintf("at most %d frequencies per plan", MaxCalibrationWizardSteps))
+	}
+	paths := []string{CalibrationRX, CalibrationTX}
+	switch c.Query("path") {
+	case "", "rx,tx", "tx,rx":
+	case CalibrationRX:
+		paths = []string{CalibrationRX}
+	case CalibrationTX:
+		paths = []string{CalibrationTX}
+	default:
+		return SendErrorMessage(c, 400, "path must be rx, tx or rx,tx")
+	}
+
+	cfg := p.getConfig().Calibration
+	p.calMu.Lock()
+	table, err := p.calibrationTable(cfg)
+	if err != nil {
+		p.calMu.Unlock()
+		return SendError(c, 500, err)
+	}
+	var steps []CalibrationWizardStep
+	for freq := start; freq <= stop; freq += step {
+		for _, path := range paths {
+			s := CalibrationWizardStep{Path: path, Frequency: uint32(freq), Endpoint: "/api/v1/hardware/calibration/" + path}
+			var measured time.Time
+			if path == CalibrationRX {
+				if point := table.nearestRX(uint32(freq), uint32(step/2)); point != nil {
+					measured = point.MeasuredAt
+				}
+			} else if point := table.nearestTX(uint32(freq), uint32(step/2)); point != nil {
+				measured = point.MeasuredAt
+			}
+			if !measured.IsZero() {
+				s.Done = true
+				s.MeasuredAt = &measured
+			}
+			steps = append(steps, s)
+		}
+	}
+	p.calMu.Unlock()
+
+	var next *CalibrationWizardStep
+	done := 0
+	for i := range steps {
+		if steps[i].Done {
+			done++
+		} else if next == nil {
+			next = &steps[i]
+		}
+	}
+	return SendSuccess(c, fiber.Map{
+		"steps": steps,
+		"done":  done,
+		"total": len(steps),
+		"next":  next,
+	}, "")
+}
+
+// handleCalibrateRx handles POST /api/hardware/calibration/rx {"frequency": 435000000}
+// Tunes the receiver, measures the DC offset and, with a tone at the input, the
+// I/Q imbalance, stores them in the table and restores the RX frequency. The RX
+// path must be enabled.
+func (p *HardwarePlugin) handleCalibrateRx(c *fiber.Ctx) error {
+	var req struct {
+		Frequency Frequency `json:"frequency"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	if req.Frequency < MinFrequencyHz || req.Frequency > MaxFrequencyHz {
+		return SendErrorMessage(c, 400, fmt.Sprintf("frequency must be within %d-%d Hz", MinFrequencyHz, MaxFrequencyHz))
+	}
+	cfg := p.getConfig()
+
+	// The baseband interface is busy during a recording
+	p.captureMu.Lock()
+	defer p.captureMu.Unlock()
+	if current := p.getCapture(); current != nil && current.getStatus().Active {
+		return SendError(c, 409, errCaptureActive)
+	}
+
+	var buf []byte
+	err := p.withRequestController(c, func(ctrl *SX1255Controller) error {
+		mode, err := ctrl.GetMode()
+		if err != nil {
+			return err
+		}
+		if mode&ModeBitRxEnable == 0 {
+			return fiber.NewError(409, "the RX path must be enabled")
+		}
+		previous, err := ctrl.GetRxFrequency()
+		if err != nil {
+			return err
+		}
+		if err := ctrl.SetRxFrequency(uint32(req.Frequency)); err != nil {
+			return err
+		}
+		if !ctrl.Simulated() {
+			buf, err = readBaseband(cfg.Baseband.Device, cfg.Baseband.SampleRate, cfg.Calibration.Samples)
+		}
+		if restoreErr := ctrl.SetRxFrequency(previous); restoreErr != nil && err == nil {
+			err = fmt.Errorf("failed to restore RX frequency: %w", restoreErr)
+		}
+		return err
+	})
+	if err != nil {
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			return SendErrorMessage(c, fiberErr.Code, fiberErr.Message)
+		}
+		return SendError(c, 500, err)
+	}
+	if dryRun(c) != nil {
+		return SendSuccess(c, fiber.Map{"frequency": req.Frequency, "samples": cfg.Calibration.Samples}, "")
+	}
+
+	point := measureRxCalibration(buf, cfg.Calibration.Samples, cfg.Calibration.MinLevel)
+	point.Frequency = uint32(req.Frequency)
+	point.MeasuredAt = time.Now().UTC()
+	if err := p.updateCalibration(func(table *CalibrationTable) { table.setRX(point) }); err != nil {
+		return SendError(c, 500, err)
+	}
+
+	slog.InfoContext(c.UserContext(), "RX calibrated", "frequency", point.Frequency,
+		"dc_i", point.DCOffsetI, "dc_q", point.DCOffsetQ, "level", point.Level, "iq_measured", point.IQGain != nil)
+	message := "RX calibrated"
+	if point.IQGain == nil {
+		message = fmt.Sprintf("DC offset measured; inject a tone above %.0f dBFS to measure the I/Q imbalance", cfg.Calibration.MinLevel)
+	}
+	return SendSuccess(c, point, message)
+}
+
+// handleCalibrateTx handles POST /api/hardware/calibration/tx {"frequency": 435000000, "mixer_gain": -30, "pa": false}
+// Transmits a CW test signal and steps the TX mixer tank capacitance and then
+// resistance (RegTxfe2), keeping the trim with the highest forward power from
+// the coupler. Runs as a job within the test signal limits.
+func (p *HardwarePlugin) handleCalibrateTx(c *fiber.Ctx) error {
+	var body struct {
+		Frequency Frequency `json:"frequency"`
+		MixerGain *float64  `json:"mixer_gain"`
+		PA        bool      `json:"pa"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	if body.Frequency == 0 {
+		return SendErrorMessage(c, 400, "frequency is required")
+	}
+	if err := checkTxAllowed(); err != nil {
+		return SendError(c, 423, err)
+	}
+	cfg := p.getConfig()
+
+	p.mu.RLock()
+	monitor := p.vswr
+	p.mu.RUnlock()
+	if monitor == nil {
+		return SendError(c, 409, errNoPowerSensor)
+	}
+
+	// Two passes of eight trims each, plus time for the signal to start
+	const trims = 16
+	settle := time.Duration(cfg.Calibration.Settle) * time.Millisecond
+	req := TestSignalRequest{
+		Type:      TestSignalCW,
+		Frequency: body.Frequency,
+		Offset:    calibrationToneOffset,
+		MixerGain: body.MixerGain,
+		PA:        body.PA,
+		Duration:  math.Ceil((trims*settle + 2*time.Second).Seconds()),
+	}
+	if err := req.validate(cfg); err != nil {
+		return SendError(c, 400, err)
+	}
+	override, err := p.bandPlanOverride(c)
+	if err != nil {
+		return SendError(c, 403, err)
+	}
+
+	if dryRun(c) != nil {
+		frequency, err := p.previewTestSignal(c, req, override)
+		if err != nil {
+			return sendTestSignalError(c, req, err)
+		}
+		return SendSuccess(c, fiber.Map{"frequency": frequency, "steps": trims, "test_signal": req}, "")
+	}
+
+	ctx := c.UserContext()
+	description := fmt.Sprintf("Calibrate TX at %d Hz", body.Frequency)
+	return runJob(c, "hardware.calibration.tx", description, func(jobCtx context.Context, job *JobHandle) (interface{}, error) {
+		session, err := p.startTestSignal(req, override)
+		if err != nil {
+			if errors.Is(err, errTestSignalActive) || errors.Is(err, errPABias) || errors.Is(err, errVSWRTrip) || errors.Is(err, errDutyCycle) {
+				return nil, fiber.NewError(409, err.Error())
+			}
+			if errors.Is(err, errBandPlan) {
+				return nil, fiber.NewError(403, err.Error())
+			}
+			return nil, err
+		}
+		defer session.stop()
+
+		// measure sets a trim and returns the forward power once a sample taken after the settle time is in
+		measure := func(tankCap, tankRes uint8) (float64, error) {
+			if err := p.withController(func(ctrl *SX1255Controller) error {
+				return writeTankTrim(ctrl, tankCap, tankRes)
+			}); err != nil {
+				return 0, err
+			}
+			changed := time.Now()
+			deadline := changed.Add(settle + time.Second)
+			for wait := settle; ; wait = 10 * time.Millisecond {
+				select {
+				case <-jobCtx.Done():
+					return 0, jobCtx.Err()
+				case <-time.After(wait):
+				}
+				if status := session.getStatus(); !status.Active {
+					if status.Error != "" {
+						return 0, fmt.Errorf("test signal ended: %s", status.Error)
+					}
+					return 0, fmt.Errorf("test signal ended before the calibration finished")
+				}
+				reading, _, sensorErr := monitor.snapshot()
+				if reading != nil && reading.Time.After(changed.Add(settle)) {
+					return reading.ForwardDBm, nil
+				}
+				if time.Now().After(deadline) {
+					if sensorErr != "" {
+						return 0, fmt.Errorf("no forward power reading: %s", sensorErr)
+					}
+					return 0, fmt.Errorf("no forward power reading")
+				}
+			}
+		}
+
+		var current uint8
+		if err := p.withController(func(ctrl *SX1255Controller) error {
+			var err error
+			current, err = ctrl.ReadRegister(RegTxfe2)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+
+		best := TxCalibration{Frequency: uint32(body.Frequency), TankRes: current & 0x07, ForwardDBm: math.Inf(-1)}
+		step := 0
+		sweep := func(set func(value uint8) (uint8, uint8)) error {
+			for value := uint8(0); value < 8; value++ {
+				tankCap, tankRes := set(value)
+				power, err := measure(tankCap, tankRes)
+				if err != nil {
+					return err
+				}
+				step++
+				job.Progress(int64(step), trims, "steps")
+				if power > best.ForwardDBm {
+					best.TankCap, best.TankRes, best.ForwardDBm = tankCap, tankRes, power
+				}
+			}
+			return nil
+		}
+		job.Message("Stepping tank capacitance")
+		if err := sweep(func(value uint8) (uint8, uint8) { return value, best.TankRes }); err != nil {
+			return nil, err
+		}
+		job.Message("Stepping tank resistance")
+		bestCap := best.TankCap
+		if err := sweep(func(value uint8) (uint8, uint8) { return bestCap, value }); err != nil {
+			return nil, err
+		}
+
+		best.MeasuredAt = time.Now().UTC()
+		if err := p.updateCalibration(func(table *CalibrationTable) { table.setTX(best) }); err != nil {
+			return nil, err
+		}
+		slog.InfoContext(ctx, "TX calibrated", "frequency", best.Frequency,
+			"tank_cap", best.TankCap, "tank_res", best.TankRes, "forward_dbm", best.ForwardDBm)
+		return best, nil
+	}, "TX calibrated")
+}
+
+// handleDeleteCalibration handles DELETE /api/hardware/calibration/:path/:frequency
+func (p *HardwarePlugin) handleDeleteCalibration(c *fiber.Ctx) error {
+	path := c.Params("path")
+	if path != CalibrationRX && path != CalibrationTX {
+		return SendErrorMessage(c, 400, "path must be rx or tx")
+	}
+	freq, err := strconv.ParseUint(c.Params("frequency"), 10, 32)
+	if err != nil {
+		return SendErrorMessage(c, 400, "Invalid frequency")
+	}
+
+	found := false
+	err = p.updateCalibration(func(table *CalibrationTable) {
+		if path == CalibrationRX {
+			before := len(table.RX)
+			table.RX = removeCalibration(table.RX, uint32(freq), func(p RxCalibration) uint32 { return p.Frequency })
+			found = len(table.RX) < before
+		} else {
+			before := len(table.TX)
+			table.TX = removeCalibration(table.TX, uint32(freq), func(p TxCalibration) uint32 { return p.Frequency })
+			found = len(table.TX) < before
+		}
+	})
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	if !found {
+		return SendErrorMessage(c, 404, fmt.Sprintf("No %s calibration at %d Hz", path, freq))
+	}
+
+	slog.InfoContext(c.UserContext(), "Calibration point deleted", "path", path, "frequency", freq)
+	return SendSuccess(c, nil, "Calibration point deleted")
+}
@@ -0,0 +1,461 @@
+package plugins
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Capture formats
+const (
+	CaptureFormatCS16  = "cs16"  // interleaved int16 I/Q, little endian
+	CaptureFormatCF32  = "cf32"  // interleaved float32 I/Q, little endian, scaled to [-1, 1)
+	CaptureFormatSigMF = "sigmf" // cs16 data in a conforming SigMF recording (.sigmf-data)
+)
+
+// Baseband and capture defaults
+const (
+	DefaultBasebandDevice     = "hw:0,0"
+	DefaultBasebandSampleRate = 500000
+	DefaultCaptureDir         = "/var/lib/linht/captures"
+	DefaultCaptureMaxDuration = 600 // seconds
+	captureBytesPerSample     = 4   // 16-bit I + 16-bit Q as delivered by the baseband interface
+	captureBufferSize         = 64 * 1024
+	captureStartedEvent       = "hardware.capture.started"
+	captureFinishedEvent      = "hardware.capture.finished"
+)
+
+// captureFileExtensions maps formats to the data file extension
+var captureFileExtensions = map[string]string{
+	CaptureFormatCS16:  ".cs16",
+	CaptureFormatCF32:  ".cf32",
+	CaptureFormatSigMF: ".sigmf-data",
+}
+
+// captureNamePattern restricts recording names to safe file names
+var captureNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// CaptureRequest describes an I/Q recording
+type CaptureRequest struct {
+	Duration   float64 `json:"duration"`    // seconds
+	SampleRate int     `json:"sample_rate"` // defaults to the baseband sample rate
+	Format     string  `json:"format"`      // cs16, cf32 or sigmf
+	Name       string  `json:"name"`        // file name without extension
+}
+
+// CaptureStatus reports the current or last recording
+type CaptureStatus struct {
+	Active     bool       `json:"active"`
+	Name       string     `json:"name"`
+	Format     string     `json:"format"`
+	SampleRate int        `json:"sample_rate"`
+	Duration   float64    `json:"duration"`
+	Frequency  uint32     `json:"frequency"` // RX frequency at the start of the recording
+	Files      []string   `json:"files"`
+	Samples    int64      `json:"samples"`
+	Bytes      int64      `json:"bytes"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Stopped    bool       `json:"stopped"` // ended early by a stop request
+	Error      string     `json:"error,omitempty"`
+}
+
+// CaptureFile is a recording in the capture directory
+type CaptureFile struct {
+	Name        string    `json:"name"`
+	Path        string    `json:"path"`
+	Size        int64     `json:"size"`
+	Modified    time.Time `json:"modified"`
+	DownloadURL string    `json:"download_url"`
+}
+
+// captureSession is a running recording
+type captureSession struct {
+	mu       sync.Mutex
+	status   CaptureStatus
+	cmd      *exec.Cmd
+	hardware map[string]interface{} // transceiver info recorded in the SigMF metadata
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// getStatus returns a copy of the session status
+func (s *captureSession) getStatus() CaptureStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.status
+	status.Files = append([]string(nil), s.status.Files...)
+	return status
+}
+
+// stop ends the recording early by terminating the capture process
+func (s *captureSession) stop() {
+	s.stopOnce.Do(func() {
+		s.mu.Lock()
+		s.status.Stopped = true
+		s.mu.Unlock()
+		if s.cmd.Process != nil {
+			s.cmd.Process.Kill()
+		}
+	})
+	<-s.done
+}
+
+// validate checks the request against the configured limits and fills in defaults
+func (r *CaptureRequest) validate(cfg HardwareConfig) error {
+	if r.SampleRate == 0 {
+		r.SampleRate = cfg.Baseband.SampleRate
+	}
+	if r.Format == "" {
+		r.Format = CaptureFormatCS16
+	}
+	r.Format = strings.ToLower(r.Format)
+	if r.Name == "" {
+		r.Name = "capture-" + time.Now().UTC().Format("20060102-150405")
+	}
+
+	if r.Duration <= 0 || r.Duration > float64(cfg.Capture.MaxDuration) {
+		return fmt.Errorf("duration must be between 0 and %d seconds", cfg.Capture.MaxDuration)
+	}
+	if r.SampleRate <= 0 {
+		return fmt.Errorf("sample_rate must be greater than 0")
+	}
+	if _, ok := captureFileExtensions[r.Format]; !ok {
+		return fmt.Errorf("format must be one of cs16, cf32, sigmf")
+	}
+	if !captureNamePattern.MatchString(r.Name) || r.Name == "." || r.Name == ".." {
+		return fmt.Errorf("name may only contain letters, digits, '.', '_' and '-'")
+	}
+	return nil
+}
+
+// getCapture returns the current or last capture session
+func (p *HardwarePlugin) getCapture() *captureSession {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.capture
+}
+
+// startCapture checks the RX path and starts recording from the baseband interface
+func (p *HardwarePlugin) startCapture(req CaptureRequest) (*captureSession, error) {
+	cfg := p.getConfig()
+
+	p.captureMu.Lock()
+	defer p.captureMu.Unlock()
+
+	if current := p.getCapture(); current != nil && current.getStatus().Active {
+		return nil, errCaptureActive
+	}
+
+	var frequency uint32
+	var hardware map[string]interface{}
+	err := p.withController(func(ctrl *SX1255Controller) error {
+		mode, err := ctrl.GetMode()
+		if err != nil {
+			return err
+		}
+		if mode&ModeBitRxEnable == 0 {
+			return fmt.Errorf("RX path is disabled; set mode to rx or full_duplex before recording")
+		}
+		if frequency, err = ctrl.GetRxFrequency(); err != nil {
+			return err
+		}
+		hardware = ctrl.Info()
+		if version, err := ctrl.GetVersionString(); err == nil {
+			hardware["version"] = version
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(cfg.Capture.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+	dataPath := filepath.Join(cfg.Capture.Dir, req.Name+captureFileExtensions[req.Format])
+	for _, path := range []string{dataPath, sigmfMetaPath(dataPath)} {
+		if _, err := os.Stat(path); err == nil {
+			return nil, fmt.Errorf("%s already exists", path)
+		}
+	}
+	file, err := os.Create(dataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("arecord", "-q",
+		"-D", cfg.Baseband.Device,
+		"-t", "raw",
+		"-f", "S16_LE",
+		"-c", "2",
+		"-r", strconv.Itoa(req.SampleRate))
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		file.Close()
+		os.Remove(dataPath)
+		return nil, err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		file.Close()
+		os.Remove(dataPath)
+		return nil, fmt.Errorf("failed to start arecord: %w", err)
+	}
+
+	session := &captureSession{
+		cmd:      cmd,
+		hardware: hardware,
+		done:     make(chan struct{}),
+		status: CaptureStatus{
+			Active:     true,
+			Name:       req.Name,
+			Format:     req.Format,
+			SampleRate: req.SampleRate,
+			Duration:   req.Duration,
+			Frequency:  frequency,
+			Files:      []string{dataPath},
+			StartedAt:  time.Now().UTC(),
+		},
+	}
+
+	p.mu.Lock()
+	p.capture = session
+	p.mu.Unlock()
+
+	go p.runCapture(session, stdout, file, &stderr)
+
+	PublishEvent(captureStartedEvent, hardwareMonitorEventSource, session.getStatus())
+	return session, nil
+}
+
+// errCaptureActive is returned when a recording is already running
+var errCaptureActive = errors.New("a recording is already in progress")
+
+// runCapture copies samples until the duration is reached or the process ends
+func (p *HardwarePlugin) runCapture(session *captureSession, stdout io.Reader, file *os.File, stderr *strings.Builder) {
+	defer close(session.done)
+
+	status := session.getStatus()
+	target := int64(math.Round(status.Duration * float64(status.SampleRate)))
+	writer := bufio.NewWriterSize(file, captureBufferSize)
+	buf := make([]byte, captureBufferSize)
+	var converted []byte
+	if status.Format == CaptureFormatCF32 {
+		converted = make([]byte, captureBufferSize*2)
+	}
+
+	var samples, written int64
+	var captureErr error
+	for samples < target {
+		want := int64(len(buf) / captureBytesPerSample)
+		if remaining := target - samples; remaining < want {
+			want = remaining
+		}
+		n, err := io.ReadFull(stdout, buf[:want*captureBytesPerSample])
+		chunk := buf[:n-n%captureBytesPerSample]
+
+		if len(chunk) > 0 {
+			out := chunk
+			if converted != nil {
+				out = convertCS16ToCF32(chunk, converted)
+			}
+			if _, werr := writer.Write(out); werr != nil {
+				captureErr = werr
+				break
+			}
+			samples += int64(len(chunk) / captureBytesPerSample)
+			written += int64(len(out))
+
+			session.mu.Lock()
+			session.status.Samples = samples
+			session.status.Bytes = written
+			session.mu.Unlock()
+		}
+
+		if err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				captureErr = err
+			}
+			break
+		}
+	}
+
+	// arecord keeps running until killed once the target is reached
+	session.cmd.Process.Kill()
+	session.cmd.Wait()
+
+	if err := writer.Flush(); err != nil && captureErr == nil {
+		captureErr = err
+	}
+	if err := file.Close(); err != nil && captureErr == nil {
+		captureErr = err
+	}
+
+	session.mu.Lock()
+	if captureErr == nil && samples < target && !session.status.Stopped {
+		captureErr = fmt.Errorf("baseband interface ended early: %s", strings.TrimSpace(stderr.String()))
+	}
+	now := time.Now().UTC()
+	session.status.Active = false
+	session.status.FinishedAt = &now
+	if captureErr != nil {
+		session.status.Error = captureErr.Error()
+	}
+	session.mu.Unlock()
+
+	// Every recording gets SigMF metadata so it opens in standard SDR tooling
+	metaPath, err := writeSigMFMeta(session.getStatus(), session.hardware)
+	session.mu.Lock()
+	if err != nil {
+		if session.status.Error == "" {
+			session.status.Error = err.Error()
+		}
+	} else {
+		session.status.Files = append(session.status.Files, metaPath)
+	}
+	session.mu.Unlock()
+
+	final := session.getStatus()
+	if final.Error != "" {
+		slog.Error("I/Q recording failed", "name", final.Name, "samples", final.Samples, "error", final.Error)
+	} else {
+		slog.Info("I/Q recording finished", "name", final.Name, "samples", final.Samples, "stopped", final.Stopped)
+	}
+	PublishEvent(captureFinishedEvent, hardwareMonitorEventSource, final)
+}
+
+// convertCS16ToCF32 converts interleaved int16 samples into float32 scaled to [-1, 1)
+func convertCS16ToCF32(in []byte, out []byte) []byte {
+	n := len(in) / 2
+	for i := 0; i < n; i++ {
+		value := int16(binary.LittleEndian.Uint16(in[i*2:]))
+		binary.LittleEndian.PutUint32(out[i*4:], math.Float32bits(float32(value)/32768))
+	}
+	return out[:n*4]
+}
+
+// stopCapture ends a running recording and waits for the files to be closed
+func (p *HardwarePlugin) stopCapture() (*captureSession, bool) {
+	session := p.getCapture()
+	if session == nil || !session.getStatus().Active {
+		return nil, false
+	}
+	session.stop()
+	return session, true
+}
+
+// handleCaptureStart handles POST /api/hardware/capture/start
+// Records I/Q samples from the baseband interface for the requested duration
+func (p *HardwarePlugin) handleCaptureStart(c *fiber.Ctx) error {
+	var req CaptureRequest
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	if err := req.validate(p.getConfig()); err != nil {
+		return SendError(c, 400, err)
+	}
+	if dryRun(c) != nil {
+		if current := p.getCapture(); current != nil && current.getStatus().Active {
+			return SendError(c, 409, errCaptureActive)
+		}
+		return SendSuccess(c, req, "")
+	}
+
+	session, err := p.startCapture(req)
+	if err != nil {
+		if errors.Is(err, errCaptureActive) {
+			return SendError(c, 409, err)
+		}
+		slog.ErrorContext(c.UserContext(), "Failed to start I/Q recording", "name", req.Name, "error", err)
+		return SendError(c, 500, err)
+	}
+
+	status := session.getStatus()
+	slog.InfoContext(c.UserContext(), "I/Q recording started",
+		"name", status.Name,
+		"format", status.Format,
+		"sample_rate", status.SampleRate,
+		"duration", status.Duration,
+		"frequency", status.Frequency)
+	return SendSuccess(c, status, "Recording started")
+}
+
+// handleCaptureStop handles POST /api/hardware/capture/stop
+func (p *HardwarePlugin) handleCaptureStop(c *fiber.Ctx) error {
+	if dryRun(c) != nil {
+		if current := p.getCapture(); current != nil && current.getStatus().Active {
+			return SendSuccess(c, current.getStatus(), "")
+		}
+		return SendErrorMessage(c, 404, "No recording in progress")
+	}
+
+	session, ok := p.stopCapture()
+	if !ok {
+		return SendErrorMessage(c, 404, "No recording in progress")
+	}
+
+	slog.InfoContext(c.UserContext(), "I/Q recording stopped", "name", session.getStatus().Name)
+	return SendSuccess(c, session.getStatus(), "Recording stopped")
+}
+
+// handleCaptureStatus handles GET /api/hardware/capture/status
+// Reports the running recording, or the last one when idle
+func (p *HardwarePlugin) handleCaptureStatus(c *fiber.Ctx) error {
+	session := p.getCapture()
+	if session == nil {
+		return SendSuccess(c, fiber.Map{"active": false}, "")
+	}
+	return SendSuccess(c, session.getStatus(), "")
+}
+
+// handleListCaptures handles GET /api/hardware/captures
+// Lists recordings with file manager download links, newest first
+func (p *HardwarePlugin) handleListCaptures(c *fiber.Ctx) error {
+	dir := p.getConfig().Capture.Dir
+
+	files := []CaptureFile{}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return SendError(c, 500, err)
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		files = append(files, CaptureFile{
+			Name:        entry.Name(),
+			Path:        path,
+			Size:        info.Size(),
+			Modified:    info.ModTime(),
+			DownloadURL: APIPath("/filemanager/download") + "?path=" + url.QueryEscape(path),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Modified.After(files[j].Modified)
+	})
+
+	return SendSuccess(c, fiber.Map{
+		"dir":   dir,
+		"files": files,
+	}, "")
+}
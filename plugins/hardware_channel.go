@@ -0,0 +1,538 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+// Control channel defaults
+const (
+	DefaultChannelPollInterval = 250 // milliseconds
+	MinChannelPollInterval     = 50  // milliseconds
+	rpcVersion                 = "2.0"
+)
+
+// JSON-RPC error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+	rpcMaintenance    = -32001 // transmit refused in maintenance mode
+	rpcBandPlan       = -32002 // transmit refused by the band plan
+	rpcReadOnly       = -32003 // change refused in read-only mode
+	rpcPABias         = -32004 // transmit refused by the PA bias interlock
+	rpcVSWR           = -32005 // transmit refused after the VSWR protection tripped
+	rpcDutyCycle      = -32006 // transmit refused by the duty cycle limit
+)
+
+// channelEventFilters selects the bus events forwarded to control channels
+var channelEventFilters = []string{"hardware.", "maintenance."}
+
+// channelReadMethods are the methods still served in read-only mode
+var channelReadMethods = map[string]bool{
+	"status":        true,
+	"read_register": true,
+}
+
+// rpcRequest is a JSON-RPC request; requests without an id get no response
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcError is the error object of a JSON-RPC response
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// rpcResponse is the reply to a request
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcNotification is an asynchronous server message
+type rpcNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// newRPCError maps an error to a JSON-RPC error, keeping transmit refusals distinguishable
+func newRPCError(err error) *rpcError {
+	code := rpcServerError
+	switch {
+	case errors.Is(err, ErrMaintenanceMode):
+		code = rpcMaintenance
+	case errors.Is(err, errBandPlan):
+		code = rpcBandPlan
+	case errors.Is(err, ErrReadOnlyMode):
+		code = rpcReadOnly
+	case errors.Is(err, errPABias):
+		code = rpcPABias
+	case errors.Is(err, errVSWRTrip):
+		code = rpcVSWR
+	case errors.Is(err, errDutyCycle):
+		code = rpcDutyCycle
+	}
+	return &rpcError{Code: code, Message: err.Error()}
+}
+
+// channelState is the transceiver state watched for notifications
+type channelState struct {
+	Mode        uint8  `json:"mode_value"`
+	ModeName    string `json:"mode"`
+	TxLocked    bool   `json:"tx_locked"`
+	RxLocked    bool   `json:"rx_locked"`
+	TxSwitch    bool   `json:"tx_switch"`
+	RxFrequency uint32 `json:"rx_frequency"`
+	TxFrequency uint32 `json:"tx_frequency"`
+}
+
+// controlChannel is one connected JSON-RPC client
+type controlChannel struct {
+	plugin  *HardwarePlugin
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+
+	stateMu sync.Mutex
+	last    *channelState
+	lastErr string
+}
+
+// send writes a message; writes from the poller and the request loop are serialized
+func (ch *controlChannel) send(msg interface{}) error {
+	ch.writeMu.Lock()
+	defer ch.writeMu.Unlock()
+	return ch.conn.WriteJSON(msg)
+}
+
+// notify sends a notification
+func (ch *controlChannel) notify(method string, params interface{}) error {
+	return ch.send(rpcNotification{JSONRPC: rpcVersion, Method: method, Params: params})
+}
+
+// readState reads the watched state in one controller session
+func (ch *controlChannel) readState() (channelState, error) {
+	var state channelState
+	err := ch.plugin.withController(func(ctrl *SX1255Controller) error {
+		var err error
+		if state.Mode, err = ctrl.GetMode(); err != nil {
+			return err
+		}
+		if state.TxLocked, state.RxLocked, err = ctrl.GetPLLStatus(); err != nil {
+			return err
+		}
+		if state.TxSwitch, err = ctrl.GetTxRxSwitch(); err != nil {
+			return err
+		}
+		if state.RxFrequency, err = ctrl.GetRxFrequency(); err != nil {
+			return err
+		}
+		state.TxFrequency, err = ctrl.GetTxFrequency()
+		return err
+	})
+	state.ModeName = modeName(state.Mode)
+	return state, err
+}
+
+// poll reads the state and notifies the client about changes
+// The first poll sends the complete state as a status notification
+func (ch *controlChannel) poll() error {
+	state, err := ch.readState()
+
+	ch.stateMu.Lock()
+	if err != nil {
+		// Report a failing read once instead of on every poll
+		repeated := ch.lastErr == err.Error()
+		ch.lastErr = err.Error()
+		ch.stateMu.Unlock()
+		if repeated {
+			return nil
+		}
+		return ch.notify("error", map[string]string{"message": err.Error()})
+	}
+	last := ch.last
+	ch.last = &state
+	ch.lastErr = ""
+	ch.stateMu.Unlock()
+
+	if last == nil {
+		return ch.notify("status", state)
+	}
+	if state.Mode != last.Mode {
+		if err := ch.notify("mode", map[string]interface{}{"mode": state.ModeName, "mode_value": state.Mode}); err != nil {
+			return err
+		}
+	}
+	if state.TxLocked != last.TxLocked || state.RxLocked != last.RxLocked {
+		if err := ch.notify("pll", map[string]bool{"tx_locked": state.TxLocked, "rx_locked": state.RxLocked}); err != nil {
+			return err
+		}
+	}
+	if state.TxSwitch != last.TxSwitch {
+		if err := ch.notify("switch", map[string]bool{"tx": state.TxSwitch}); err != nil {
+			return err
+		}
+	}
+	if state.RxFrequency != last.RxFrequency || state.TxFrequency != last.TxFrequency {
+		if err := ch.notify("frequency", map[string]uint32{"rx": state.RxFrequency, "tx": state.TxFrequency}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watch polls the transceiver and forwards hardware events until done is closed
+func (ch *controlChannel) watch(interval time.Duration, done <-chan struct{}) {
+	events, unsubscribe := Events.Subscribe()
+	defer unsubscribe()
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		var err error
+		select {
+		case <-done:
+			return
+		case <-tick:
+			err = ch.poll()
+		case event := <-events:
+			if matchesEventFilter(event.Type, channelEventFilters) {
+				err = ch.notify("event", event)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// rpcParams decodes request parameters, reporting failures as invalid params
+func rpcParams(raw json.RawMessage, v interface{}) *rpcError {
+	if len(raw) == 0 {
+		return &rpcError{Code: rpcInvalidParams, Message: "params required"}
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// channelOverride validates a requested band plan override
+func (ch *controlChannel) channelOverride(requested bool) (bool, *rpcError) {
+	if !requested {
+		return false, nil
+	}
+	if err := ch.plugin.checkBandPlanOverride(); err != nil {
+		return false, newRPCError(err)
+	}
+	slog.Warn("Band plan override requested", "path", "hardware control channel")
+	return true, nil
+}
+
+// call executes one method
+// Transmit methods apply the same maintenance and band plan checks as the REST endpoints,
+// and only status and read_register are served in read-only mode
+func (ch *controlChannel) call(method string, raw json.RawMessage) (interface{}, *rpcError) {
+	p := ch.plugin
+
+	if ReadOnlyMode() && !channelReadMethods[method] {
+		return nil, newRPCError(ErrReadOnlyMode)
+	}
+
+	switch method {
+	case "status":
+		state, err := ch.readState()
+		if err != nil {
+			return nil, newRPCError(err)
+		}
+		return state, nil
+
+	case "set_mode":
+		var params struct {
+			Mode     string `json:"mode"`
+			Override bool   `json:"override"`
+		}
+		if rerr := rpcParams(raw, &params); rerr != nil {
+			return nil, rerr
+		}
+		value, ok := parseModeName(params.Mode)
+		if !ok {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "mode must be one of sleep, standby, rx, tx, tx_full, full_duplex"}
+		}
+		keying := modeEnablesTx(value)
+		if keying {
+			if err := checkTxAllowed(); err != nil {
+				return nil, newRPCError(err)
+			}
+		}
+		override, rerr := ch.channelOverride(params.Override)
+		if rerr != nil {
+			return nil, rerr
+		}
+		err := p.withTxController(keying, override, func(ctrl *SX1255Controller) error {
+			return ctrl.SetMode(value)
+		})
+		if err != nil {
+			return nil, newRPCError(err)
+		}
+		return map[string]interface{}{"mode": params.Mode}, nil
+
+	case "set_rx_frequency":
+		var params struct {
+			Frequency Frequency `json:"frequency"`
+		}
+		if rerr := rpcParams(raw, &params); rerr != nil {
+			return nil, rerr
+		}
+		freq := uint32(params.Frequency)
+		err := p.withController(func(ctrl *SX1255Controller) error {
+			return ctrl.SetRxFrequency(freq)
+		})
+		if err != nil {
+			return nil, newRPCError(err)
+		}
+		return p.getConfig().ChannelPlan.frequencyView(freq), nil
+
+	case "set_tx_frequency":
+		var params struct {
+			Frequency Frequency `json:"frequency"`
+			Override  bool      `json:"override"`
+		}
+		if rerr := rpcParams(raw, &params); rerr != nil {
+			return nil, rerr
+		}
+		freq := uint32(params.Frequency)
+		override, rerr := ch.channelOverride(params.Override)
+		if rerr != nil {
+			return nil, rerr
+		}
+		band, err := p.getConfig().BandPlan.check(freq, override)
+		if err != nil {
+			return nil, newRPCError(err)
+		}
+		err = p.withController(func(ctrl *SX1255Controller) error {
+			if err := ctrl.SetTxFrequency(freq); err != nil {
+				return err
+			}
+			mode, err := ctrl.GetMode()
+			if err != nil {
+				return err
+			}
+			p.updateTxTimeout(mode, band, override)
+			return nil
+		})
+		if err != nil {
+			return nil, newRPCError(err)
+		}
+		return p.getConfig().ChannelPlan.frequencyView(freq), nil
+
+	case "set_gain":
+		var params struct {
+			Stage string  `json:"stage"` // lna, pga, dac or mixer
+			Gain  float64 `json:"gain"`
+		}
+		if rerr := rpcParams(raw, &params); rerr != nil {
+			return nil, rerr
+		}
+		var set func(*SX1255Controller) error
+		switch params.Stage {
+		case "lna":
+			set = func(ctrl *SX1255Controller) error { return ctrl.SetLNAGain(uint8(params.Gain)) }
+		case "pga":
+			set = func(ctrl *SX1255Controller) error { return ctrl.SetPGAGain(uint8(params.Gain)) }
+		case "dac":
+			set = func(ctrl *SX1255Controller) error { return ctrl.SetDACGain(int8(params.Gain)) }
+		case "mixer":
+			set = func(ctrl *SX1255Controller) error { return ctrl.SetMixerGain(float32(params.Gain)) }
+		default:
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "stage must be one of lna, pga, dac, mixer"}
+		}
+		if err := p.withController(set); err != nil {
+			return nil, newRPCError(err)
+		}
+		return map[string]interface{}{"stage": params.Stage, "gain": params.Gain}, nil
+
+	case "enable":
+		var params struct {
+			Path     string `json:"path"` // rx, tx or pa
+			Enable   bool   `json:"enable"`
+			Override bool   `json:"override"`
+		}
+		if rerr := rpcParams(raw, &params); rerr != nil {
+			return nil, rerr
+		}
+		var set func(*SX1255Controller) error
+		switch params.Path {
+		case "rx":
+			set = func(ctrl *SX1255Controller) error { return ctrl.EnableRx(params.Enable) }
+		case "tx":
+			set = func(ctrl *SX1255Controller) error { return ctrl.EnableTx(params.Enable) }
+		case "pa":
+			set = func(ctrl *SX1255Controller) error { return ctrl.EnablePA(params.Enable) }
+		default:
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "path must be one of rx, tx, pa"}
+		}
+		keying := params.Enable && params.Path != "rx"
+		if keying {
+			if err := checkTxAllowed(); err != nil {
+				return nil, newRPCError(err)
+			}
+		}
+		override, rerr := ch.channelOverride(params.Override)
+		if rerr != nil {
+			return nil, rerr
+		}
+		if err := p.withTxController(keying, override, set); err != nil {
+			return nil, newRPCError(err)
+		}
+		return map[string]interface{}{"path": params.Path, "enable": params.Enable}, nil
+
+	case "set_txrx_switch":
+		var params struct {
+			Tx       bool `json:"tx"`
+			Override bool `json:"override"`
+		}
+		if rerr := rpcParams(raw, &params); rerr != nil {
+			return nil, rerr
+		}
+		if params.Tx {
+			if err := checkTxAllowed(); err != nil {
+				return nil, newRPCError(err)
+			}
+		}
+		override, rerr := ch.channelOverride(params.Override)
+		if rerr != nil {
+			return nil, rerr
+		}
+		err := p.withTxController(params.Tx, override, func(ctrl *SX1255Controller) error {
+			return ctrl.SetTxRxSwitch(params.Tx)
+		})
+		if err != nil {
+			return nil, newRPCError(err)
+		}
+		return map[string]bool{"tx": params.Tx}, nil
+
+	case "read_register":
+		var params struct {
+			Address uint8 `json:"address"`
+		}
+		if rerr := rpcParams(raw, &params); rerr != nil {
+			return nil, rerr
+		}
+		var value uint8
+		err := p.withController(func(ctrl *SX1255Controller) error {
+			var err error
+			value, err = ctrl.ReadRegister(params.Address)
+			return err
+		})
+		if err != nil {
+			return nil, newRPCError(err)
+		}
+		return map[string]interface{}{
+			"address":     fmt.Sprintf("0x%02X", params.Address),
+			"value":       fmt.Sprintf("0x%02X", value),
+			"value_dec":   value,
+			"description": RegisterDescriptions[params.Address],
+		}, nil
+	}
+
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("method %q not found", method)}
+}
+
+// handle processes one client message
+func (ch *controlChannel) handle(msg []byte) error {
+	var req rpcRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return ch.send(rpcResponse{JSONRPC: rpcVersion, ID: json.RawMessage("null"),
+			Error: &rpcError{Code: rpcParseError, Message: "invalid JSON"}})
+	}
+	if req.JSONRPC != rpcVersion || req.Method == "" {
+		return ch.send(rpcResponse{JSONRPC: rpcVersion, ID: idOrNull(req.ID),
+			Error: &rpcError{Code: rpcInvalidRequest, Message: "expected a JSON-RPC 2.0 request with a method"}})
+	}
+
+	result, rerr := ch.call(req.Method, req.Params)
+	if rerr == nil && req.Method != "status" {
+		slog.Info("Hardware channel command", "method", req.Method, "params", string(req.Params))
+		// Push resulting state changes before the reply so clients see them in order
+		if err := ch.poll(); err != nil {
+			return err
+		}
+	}
+
+	if len(req.ID) == 0 {
+		return nil
+	}
+	return ch.send(rpcResponse{JSONRPC: rpcVersion, ID: req.ID, Result: result, Error: rerr})
+}
+
+// idOrNull returns the request id, or null when it is missing
+func idOrNull(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+// handleControlChannel handles GET /api/hardware/ws?interval=250 (WebSocket)
+// Keeps one controller open for the connection, answers JSON-RPC 2.0 requests
+// and notifies about mode, PLL lock, switch and frequency changes
+func (p *HardwarePlugin) handleControlChannel(c *websocket.Conn) {
+	interval := DefaultChannelPollInterval
+	if value, err := strconv.Atoi(c.Query("interval")); err == nil && value >= 0 {
+		interval = value
+	}
+	if interval != 0 && interval < MinChannelPollInterval {
+		interval = MinChannelPollInterval
+	}
+
+	ch := &controlChannel{plugin: p, conn: c}
+	if err := p.acquireController(); err != nil {
+		ch.notify("error", map[string]string{"message": err.Error()})
+		c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "hardware unavailable"))
+		return
+	}
+	defer p.releaseController()
+
+	slog.Info("Hardware control channel connected", "remote", c.RemoteAddr().String(), "interval_ms", interval)
+	defer slog.Info("Hardware control channel disconnected", "remote", c.RemoteAddr().String())
+
+	if err := ch.poll(); err != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go ch.watch(time.Duration(interval)*time.Millisecond, done)
+
+	for {
+		_, msg, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err := ch.handle(msg); err != nil {
+			return
+		}
+	}
+}
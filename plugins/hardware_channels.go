@@ -0,0 +1,320 @@
+package plugins
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/gofiber/fiber/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultChannelsFile stores the named channels
+const DefaultChannelsFile = "/var/lib/linht/channels.yaml"
+
+// channelActivatedEvent is published when a channel is applied to the transceiver
+const channelActivatedEvent = "hardware.channel.activated"
+
+// channelNamePattern restricts channel names to what fits in a URL path segment
+var channelNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,31}$`)
+
+// ChannelsConfig holds the location of the channel list
+type ChannelsConfig struct {
+	File string `yaml:"file"`
+}
+
+// RadioChannel is a named transceiver setting: an RX/TX frequency pair with gains and mode
+// Omitted gains are left as they are when the channel is activated.
+type RadioChannel struct {
+	Name        string    `yaml:"name" json:"name"`
+	Description string    `yaml:"description,omitempty" json:"description,omitempty"`
+	RxFrequency Frequency `yaml:"rx_frequency" json:"rx_frequency"`
+	TxFrequency Frequency `yaml:"tx_frequency" json:"tx_frequency"` // defaults to rx_frequency (simplex)
+	LNAGain     *uint8    `yaml:"lna_gain,omitempty" json:"lna_gain,omitempty"`
+	PGAGain     *uint8    `yaml:"pga_gain,omitempty" json:"pga_gain,omitempty"`
+	DACGain     *int8     `yaml:"dac_gain,omitempty" json:"dac_gain,omitempty"`
+	MixerGain   *float32  `yaml:"mixer_gain,omitempty" json:"mixer_gain,omitempty"`
+	Mode        string    `yaml:"mode" json:"mode"` // defaults to rx
+}
+
+// state returns the hardware state applied by activating the channel
+// Receive-only modes return the antenna switch to RX.
+func (ch RadioChannel) state() HardwareState {
+	rx, tx, mode := ch.RxFrequency, ch.TxFrequency, ch.Mode
+	state := HardwareState{
+		RxFrequency: &rx,
+		TxFrequency: &tx,
+		LNAGain:     ch.LNAGain,
+		PGAGain:     ch.PGAGain,
+		DACGain:     ch.DACGain,
+		MixerGain:   ch.MixerGain,
+		Mode:        &mode,
+	}
+	if value, ok := parseModeName(mode); ok && !modeEnablesTx(value) {
+		txSwitch := false
+		state.TxSwitch = &txSwitch
+	}
+	return state
+}
+
+// normalize fills in the defaults and validates the channel
+func (ch *RadioChannel) normalize() error {
+	if !channelNamePattern.MatchString(ch.Name) {
+		return fmt.Errorf("channel name must be 1-32 letters, digits, '.', '_' or '-'")
+	}
+	if ch.RxFrequency == 0 {
+		return fmt.Errorf("rx_frequency is required")
+	}
+	if ch.TxFrequency == 0 {
+		ch.TxFrequency = ch.RxFrequency
+	}
+	if ch.Mode == "" {
+		ch.Mode = "rx"
+	}
+	state := ch.state()
+	return state.validate()
+}
+
+// channelView formats a channel for the API with display frequencies
+func channelView(ch RadioChannel, plan ChannelPlanConfig) map[string]interface{} {
+	view := map[string]interface{}{
+		"name":         ch.Name,
+		"rx_frequency": ch.RxFrequency,
+		"tx_frequency": ch.TxFrequency,
+		"rx":           plan.frequencyView(uint32(ch.RxFrequency)),
+		"tx":           plan.frequencyView(uint32(ch.TxFrequency)),
+		"shift":        int64(ch.TxFrequency) - int64(ch.RxFrequency),
+		"mode":         ch.Mode,
+	}
+	if ch.Description != "" {
+		view["description"] = ch.Description
+	}
+	if ch.LNAGain != nil {
+		view["lna_gain"] = *ch.LNAGain
+	}
+	if ch.PGAGain != nil {
+		view["pga_gain"] = *ch.PGAGain
+	}
+	if ch.DACGain != nil {
+		view["dac_gain"] = *ch.DACGain
+	}
+	if ch.MixerGain != nil {
+		view["mixer_gain"] = *ch.MixerGain
+	}
+	return view
+}
+
+// channelFile is the layout of the channel list on disk
+type channelFile struct {
+	Channels []RadioChannel `yaml:"channels"`
+}
+
+// loadChannels reads the channel list; a missing file is an empty list
+func loadChannels(file string) ([]RadioChannel, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []RadioChannel{}, nil
+		}
+		return nil, err
+	}
+	var list channelFile
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid channel list %s: %w", file, err)
+	}
+	if list.Channels == nil {
+		list.Channels = []RadioChannel{}
+	}
+	return list.Channels, nil
+}
+
+// saveChannels writes the channel list atomically
+func saveChannels(file string, channels []RadioChannel) error {
+	data, err := yaml.Marshal(channelFile{Channels: channels})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// findChannel returns the index of the named channel, -1 if there is none
+func findChannel(channels []RadioChannel, name string) int {
+	for i, ch := range channels {
+		if ch.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// getChannel returns the named channel from the list on disk
+func (p *HardwarePlugin) getChannel(name string) (RadioChannel, bool, error) {
+	p.channelsMu.Lock()
+	defer p.channelsMu.Unlock()
+	channels, err := loadChannels(p.getConfig().Channels.File)
+	if err != nil {
+		return RadioChannel{}, false, err
+	}
+	if i := findChannel(channels, name); i >= 0 {
+		return channels[i], true, nil
+	}
+	return RadioChannel{}, false, nil
+}
+
+// updateChannels changes the channel list and saves it
+// A non-nil *fiber.Error from update is returned unchanged and nothing is saved.
+func (p *HardwarePlugin) updateChannels(update func([]RadioChannel) ([]RadioChannel, error)) error {
+	file := p.getConfig().Channels.File
+	p.channelsMu.Lock()
+	defer p.channelsMu.Unlock()
+	channels, err := loadChannels(file)
+	if err != nil {
+		return err
+	}
+	if channels, err = update(channels); err != nil {
+		return err
+	}
+	return saveChannels(file, channels)
+}
+
+// sendChannelError maps *fiber.Error to its status and everything else to 500
+func sendChannelError(c *fiber.Ctx, err error) error {
+	if fe, ok := err.(*fiber.Error); ok {
+		return SendErrorMessage(c, fe.Code, fe.Message)
+	}
+	return SendError(c, 500, err)
+}
+
+// channelWarning reports a TX frequency the band plan refuses without override
+func (p *HardwarePlugin) channelWarning(ch RadioChannel, view map[string]interface{}) {
+	if _, err := p.getConfig().BandPlan.check(uint32(ch.TxFrequency), false); err != nil {
+		view["warning"] = err.Error()
+	}
+}
+
+// handleListChannels handles GET /api/hardware/channels
+func (p *HardwarePlugin) handleListChannels(c *fiber.Ctx) error {
+	cfg := p.getConfig()
+	p.channelsMu.Lock()
+	channels, err := loadChannels(cfg.Channels.File)
+	p.channelsMu.Unlock()
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+
+	views := make([]map[string]interface{}, 0, len(channels))
+	for _, ch := range channels {
+		views = append(views, channelView(ch, cfg.ChannelPlan))
+	}
+	return SendSuccess(c, fiber.Map{"channels": views}, "")
+}
+
+// handleGetChannel handles GET /api/hardware/channels/:name
+func (p *HardwarePlugin) handleGetChannel(c *fiber.Ctx) error {
+	name := c.Params("name")
+	ch, ok, err := p.getChannel(name)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	if !ok {
+		return SendErrorMessage(c, 404, fmt.Sprintf("Unknown channel %q", name))
+	}
+	return SendSuccess(c, channelView(ch, p.getConfig().ChannelPlan), "")
+}
+
+// handleSaveChannel handles POST /api/hardware/channels (create) and PUT /api/hardware/channels/:name (create or replace)
+func (p *HardwarePlugin) handleSaveChannel(c *fiber.Ctx) error {
+	var ch RadioChannel
+	if err := c.BodyParser(&ch); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body: "+err.Error())
+	}
+	replace := c.Method() == fiber.MethodPut
+	if replace {
+		ch.Name = c.Params("name")
+	}
+	if err := ch.normalize(); err != nil {
+		return SendError(c, 400, err)
+	}
+
+	view := channelView(ch, p.getConfig().ChannelPlan)
+	p.channelWarning(ch, view)
+	if dryRun(c) != nil {
+		return SendSuccess(c, view, "")
+	}
+
+	err := p.updateChannels(func(channels []RadioChannel) ([]RadioChannel, error) {
+		i := findChannel(channels, ch.Name)
+		switch {
+		case i < 0:
+			return append(channels, ch), nil
+		case replace:
+			channels[i] = ch
+			return channels, nil
+		}
+		return nil, fiber.NewError(409, fmt.Sprintf("Channel %q already exists", ch.Name))
+	})
+	if err != nil {
+		return sendChannelError(c, err)
+	}
+
+	slog.InfoContext(c.UserContext(), "Channel saved", "name", ch.Name,
+		"rx_frequency", ch.RxFrequency, "tx_frequency", ch.TxFrequency, "mode", ch.Mode)
+	return SendSuccess(c, view, fmt.Sprintf("Channel %s saved", ch.Name))
+}
+
+// handleDeleteChannel handles DELETE /api/hardware/channels/:name
+func (p *HardwarePlugin) handleDeleteChannel(c *fiber.Ctx) error {
+	name := c.Params("name")
+	err := p.updateChannels(func(channels []RadioChannel) ([]RadioChannel, error) {
+		i := findChannel(channels, name)
+		if i < 0 {
+			return nil, fiber.NewError(404, fmt.Sprintf("Unknown channel %q", name))
+		}
+		return append(channels[:i], channels[i+1:]...), nil
+	})
+	if err != nil {
+		return sendChannelError(c, err)
+	}
+
+	slog.InfoContext(c.UserContext(), "Channel deleted", "name", name)
+	return SendSuccess(c, nil, fmt.Sprintf("Channel %s deleted", name))
+}
+
+// handleActivateChannel handles POST /api/hardware/channel/:name/activate
+// Applies the frequencies, gains and mode of the channel like POST /hardware/configure,
+// rolling back on failure. Honors ?override=true and ?validate=true.
+func (p *HardwarePlugin) handleActivateChannel(c *fiber.Ctx) error {
+	name := c.Params("name")
+	ch, ok, err := p.getChannel(name)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	if !ok {
+		return SendErrorMessage(c, 404, fmt.Sprintf("Unknown channel %q", name))
+	}
+	if err := ch.normalize(); err != nil {
+		return SendError(c, 400, fmt.Errorf("channel %s: %w", name, err))
+	}
+
+	return p.configure(c, ch.state(), fmt.Sprintf("Channel %s activated", name), func(result map[string]interface{}) {
+		result["channel"] = name
+		if dryRun(c) != nil {
+			return
+		}
+		slog.InfoContext(c.UserContext(), "Channel activated", "name", name)
+		PublishEvent(channelActivatedEvent, hardwareMonitorEventSource, channelView(ch, p.getConfig().ChannelPlan))
+	})
+}
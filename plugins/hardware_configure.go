@@ -0,0 +1,486 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HardwareState describes a desired transceiver state
+// Omitted fields are left unchanged
+type HardwareState struct {
+	RxFrequency *Frequency `json:"rx_frequency,omitempty"`
+	TxFrequency *Frequency `json:"tx_frequency,omitempty"`
+	LNAGain     *uint8     `json:"lna_gain,omitempty"`
+	PGAGain     *uint8     `json:"pga_gain,omitempty"`
+	DACGain     *int8      `json:"dac_gain,omitempty"`
+	MixerGain   *float32   `json:"mixer_gain,omitempty"`
+	Mode        *string    `json:"mode,omitempty"`
+	TxSwitch    *bool      `json:"tx_switch,omitempty"`
+}
+
+// hardwareSnapshot holds the registers and switch state touched by a configure
+type hardwareSnapshot struct {
+	registers map[uint8]uint8
+	txSwitch  bool
+}
+
+// snapshotRegisters lists the registers saved before a configure, in restore order
+var snapshotRegisters = []uint8{
+	RegFrfhRx, RegFrfmRx, RegFrflRx,
+	RegFrfhTx, RegFrfmTx, RegFrflTx,
+	RegRxfe1, RegTxfe1,
+}
+
+// validate checks the desired state as a whole and returns all problems found
+func (s *HardwareState) validate() error {
+	var problems []string
+
+	if s.RxFrequency == nil && s.TxFrequency == nil && s.LNAGain == nil && s.PGAGain == nil &&
+		s.DACGain == nil && s.MixerGain == nil && s.Mode == nil && s.TxSwitch == nil {
+		problems = append(problems, "no settings given")
+	}
+
+	if s.RxFrequency != nil && (*s.RxFrequency < MinFrequencyHz || *s.RxFrequency > MaxFrequencyHz) {
+		problems = append(problems, fmt.Sprintf("rx_frequency %d Hz out of range (400-510 MHz)", *s.RxFrequency))
+	}
+	if s.TxFrequency != nil && (*s.TxFrequency < MinFrequencyHz || *s.TxFrequency > MaxFrequencyHz) {
+		problems = append(problems, fmt.Sprintf("tx_frequency %d Hz out of range (400-510 MHz)", *s.TxFrequency))
+	}
+	if s.LNAGain != nil && *s.LNAGain > 48 {
+		problems = append(problems, "lna_gain must be between 0 and 48 dB")
+	}
+	if s.PGAGain != nil && (*s.PGAGain > 30 || *s.PGAGain%2 != 0) {
+		problems = append(problems, "pga_gain must be between 0 and 30 dB in 2 dB steps")
+	}
+	if s.DACGain != nil {
+		switch *s.DACGain {
+		case 0, -3, -6, -9:
+		default:
+			problems = append(problems, "dac_gain must be one of 0, -3, -6, -9 dB")
+		}
+	}
+	if s.MixerGain != nil && (*s.MixerGain < -37.5 || *s.MixerGain > -7.5) {
+		problems = append(problems, "mixer_gain must be between -37.5 and -7.5 dB")
+	}
+
+	if s.Mode != nil {
+		modeValue, ok := parseModeName(*s.Mode)
+		if !ok {
+			problems = append(problems, "mode must be one of sleep, standby, rx, tx, tx_full, full_duplex")
+		} else if s.TxSwitch != nil {
+			// Keep the antenna switch consistent with the transmit path
+			if *s.TxSwitch && modeValue&ModeBitTxEnable == 0 {
+				problems = append(problems, fmt.Sprintf("tx_switch requires a transmit mode (mode is %s)", *s.Mode))
+			}
+			if !*s.TxSwitch && modeValue&ModeBitDriverEnable != 0 {
+				problems = append(problems, fmt.Sprintf("mode %s enables the PA while tx_switch selects RX", *s.Mode))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// enablesTx reports whether applying the state would enable the transmitter
+func (s *HardwareState) enablesTx() bool {
+	if s.TxSwitch != nil && *s.TxSwitch {
+		return true
+	}
+	if s.Mode != nil {
+		modeValue, ok := parseModeName(*s.Mode)
+		return ok && modeEnablesTx(modeValue)
+	}
+	return false
+}
+
+// takeSnapshot saves the current register values and switch state
+func takeSnapshot(ctrl *SX1255Controller) (*hardwareSnapshot, error) {
+	snap := &hardwareSnapshot{
+		registers: make(map[uint8]uint8, len(snapshotRegisters)+1),
+	}
+
+	for _, addr := range append([]uint8{RegMode}, snapshotRegisters...) {
+		value, err := ctrl.ReadRegister(addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read register 0x%02X: %w", addr, err)
+		}
+		snap.registers[addr] = value
+	}
+
+	txSwitch, err := ctrl.GetTxRxSwitch()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TX/RX switch: %w", err)
+	}
+	snap.txSwitch = txSwitch
+
+	return snap, nil
+}
+
+// TxSequencing holds the settling delays between keying steps, in milliseconds
+type TxSequencing struct {
+	SwitchDelay int `yaml:"switch_delay"` // after the antenna switch moves to TX, and before it returns to RX
+	PADelay     int `yaml:"pa_delay"`     // between the TX path and the PA driver
+	ModeDelay   int `yaml:"mode_delay"`   // after the final mode change
+}
+
+// MaxSequencingDelay is the longest allowed keying delay in milliseconds
+const MaxSequencingDelay = 5000
+
+// validate checks the delays are within 0..MaxSequencingDelay
+func (s TxSequencing) validate() error {
+	for name, delay := range map[string]int{"switch_delay": s.SwitchDelay, "pa_delay": s.PADelay, "mode_delay": s.ModeDelay} {
+		if delay < 0 || delay > MaxSequencingDelay {
+			return fmt.Errorf("hardware.sequencing.%s must be between 0 and %d ms", name, MaxSequencingDelay)
+		}
+	}
+	return nil
+}
+
+// wait sleeps for a delay in milliseconds, or records it on a simulated controller
+func (s TxSequencing) wait(ctrl *SX1255Controller, delay int) {
+	if delay <= 0 {
+		return
+	}
+	if ctrl.Simulated() {
+		ctrl.spi.sim.wait(delay)
+		return
+	}
+	time.Sleep(time.Duration(delay) * time.Millisecond)
+}
+
+// applyModeAndSwitch sets the mode and antenna switch in a safe order
+// Keying moves the switch to TX, then enables the TX path and then the PA;
+// unkeying disables the PA, then the TX path and returns the switch to RX last.
+// The configured delays between the steps give external PAs time to settle.
+func applyModeAndSwitch(ctrl *SX1255Controller, mode *uint8, txSwitch *bool, seq TxSequencing) error {
+	current, err := ctrl.GetMode()
+	if err != nil {
+		return fmt.Errorf("failed to read mode: %w", err)
+	}
+	setMode := func(value uint8, delay int) error {
+		if err := ctrl.SetMode(value); err != nil {
+			return fmt.Errorf("failed to set mode: %w", err)
+		}
+		current = value
+		seq.wait(ctrl, delay)
+		return nil
+	}
+	setSwitch := func(tx bool) error {
+		if err := ctrl.SetTxRxSwitch(tx); err != nil {
+			return fmt.Errorf("failed to set TX/RX switch: %w", err)
+		}
+		return nil
+	}
+
+	if txSwitch != nil && *txSwitch {
+		if err := setSwitch(true); err != nil {
+			return err
+		}
+		seq.wait(ctrl, seq.SwitchDelay)
+	}
+
+	if mode != nil {
+		paOff := current&ModeBitDriverEnable != 0 && *mode&ModeBitDriverEnable == 0
+		paOn := current&ModeBitDriverEnable == 0 && *mode&ModeBitDriverEnable != 0
+		switch {
+		case paOff && current&^ModeBitDriverEnable != *mode:
+			if err := setMode(current&^ModeBitDriverEnable, seq.PADelay); err != nil {
+				return err
+			}
+		case paOn && *mode&^ModeBitDriverEnable != current:
+			if err := setMode(*mode&^ModeBitDriverEnable, seq.PADelay); err != nil {
+				return err
+			}
+		}
+		if err := setMode(*mode, seq.ModeDelay); err != nil {
+			return err
+		}
+	}
+
+	if txSwitch != nil && !*txSwitch {
+		if switched, err := ctrl.GetTxRxSwitch(); err == nil && switched {
+			seq.wait(ctrl, seq.SwitchDelay)
+		}
+		return setSwitch(false)
+	}
+	return nil
+}
+
+// applyState writes the desired state: frequencies, then gains, then mode and switch
+func applyState(ctrl *SX1255Controller, state HardwareState, seq TxSequencing) error {
+	if state.RxFrequency != nil {
+		if err := ctrl.SetRxFrequency(uint32(*state.RxFrequency)); err != nil {
+			return fmt.Errorf("failed to set RX frequency: %w", err)
+		}
+	}
+	if state.TxFrequency != nil {
+		if err := ctrl.SetTxFrequency(uint32(*state.TxFrequency)); err != nil {
+			return fmt.Errorf("failed to set TX frequency: %w", err)
+		}
+	}
+	if state.LNAGain != nil {
+		if err := ctrl.SetLNAGain(*state.LNAGain); err != nil {
+			return fmt.Errorf("failed to set LNA gain: %w", err)
+		}
+	}
+	if state.PGAGain != nil {
+		if err := ctrl.SetPGAGain(*state.PGAGain); err != nil {
+			return fmt.Errorf("failed to set PGA gain: %w", err)
+		}
+	}
+	if state.DACGain != nil {
+		if err := ctrl.SetDACGain(*state.DACGain); err != nil {
+			return fmt.Errorf("failed to set DAC gain: %w", err)
+		}
+	}
+	if state.MixerGain != nil {
+		if err := ctrl.SetMixerGain(*state.MixerGain); err != nil {
+			return fmt.Errorf("failed to set mixer gain: %w", err)
+		}
+	}
+
+	var mode *uint8
+	if state.Mode != nil {
+		value, _ := parseModeName(*state.Mode)
+		mode = &value
+	}
+	return applyModeAndSwitch(ctrl, mode, state.TxSwitch, seq)
+}
+
+// verifyState reads back the device and compares it with the desired state
+func verifyState(ctrl *SX1255Controller, state HardwareState, clockFreq uint32) error {
+	// One synthesizer step; readback is quantized to this resolution
+	tolerance := int64(clockFreq>>20) + 1
+
+	checkFreq := func(name string, want *Frequency, get func() (uint32, error)) error {
+		if want == nil {
+			return nil
+		}
+		got, err := get()
+		if err != nil {
+			return err
+		}
+		if diff := int64(got) - int64(*want); diff > tolerance || diff < -tolerance {
+			return fmt.Errorf("%s readback %d Hz does not match %d Hz", name, got, *want)
+		}
+		return nil
+	}
+	if err := checkFreq("rx_frequency", state.RxFrequency, ctrl.GetRxFrequency); err != nil {
+		return err
+	}
+	if err := checkFreq("tx_frequency", state.TxFrequency, ctrl.GetTxFrequency); err != nil {
+		return err
+	}
+
+	if state.LNAGain != nil || state.PGAGain != nil {
+		reg, err := ctrl.ReadRegister(RegRxfe1)
+		if err != nil {
+			return err
+		}
+		if state.LNAGain != nil && (reg>>5)&0x07 != lnaGainSettingFor(*state.LNAGain) {
+			return fmt.Errorf("lna_gain readback mismatch (RXFE1=0x%02X)", reg)
+		}
+		if state.PGAGain != nil && (reg>>1)&0x0F != pgaGainSettingFor(*state.PGAGain) {
+			return fmt.Errorf("pga_gain readback mismatch (RXFE1=0x%02X)", reg)
+		}
+	}
+
+	if state.DACGain != nil || state.MixerGain != nil {
+		reg, err := ctrl.ReadRegister(RegTxfe1)
+		if err != nil {
+			return err
+		}
+		if state.DACGain != nil && (reg>>4)&0x07 != dacGainSettingFor(*state.DACGain) {
+			return fmt.Errorf("dac_gain readback mismatch (TXFE1=0x%02X)", reg)
+		}
+		if state.MixerGain != nil && reg&0x0F != mixerGainSettingFor(*state.MixerGain) {
+			return fmt.Errorf("mixer_gain readback mismatch (TXFE1=0x%02X)", reg)
+		}
+	}
+
+	if state.Mode != nil {
+		want, _ := parseModeName(*state.Mode)
+		got, err := ctrl.GetMode()
+		if err != nil {
+			return err
+		}
+		if got != want {
+			return fmt.Errorf("mode readback %s does not match %s", modeName(got), *state.Mode)
+		}
+	}
+
+	if state.TxSwitch != nil {
+		got, err := ctrl.GetTxRxSwitch()
+		if err != nil {
+			return err
+		}
+		if got != *state.TxSwitch {
+			return fmt.Errorf("tx_switch readback %t does not match %t", got, *state.TxSwitch)
+		}
+	}
+
+	return nil
+}
+
+// restoreSnapshot writes back a saved snapshot
+func restoreSnapshot(ctrl *SX1255Controller, snap *hardwareSnapshot, seq TxSequencing) error {
+	for _, addr := range snapshotRegisters {
+		if err := ctrl.WriteRegister(addr, snap.registers[addr]); err != nil {
+			return fmt.Errorf("failed to restore register 0x%02X: %w", addr, err)
+		}
+	}
+
+	mode := snap.registers[RegMode]
+	return applyModeAndSwitch(ctrl, &mode, &snap.txSwitch, seq)
+}
+
+// readState returns the current device state after a configure
+func readState(ctrl *SX1255Controller) (map[string]interface{}, error) {
+	rxFreq, err := ctrl.GetRxFrequency()
+	if err != nil {
+		return nil, err
+	}
+	txFreq, err := ctrl.GetTxFrequency()
+	if err != nil {
+		return nil, err
+	}
+	mode, err := ctrl.GetMode()
+	if err != nil {
+		return nil, err
+	}
+	txSwitch, err := ctrl.GetTxRxSwitch()
+	if err != nil {
+		return nil, err
+	}
+	rxfe1, err := ctrl.ReadRegister(RegRxfe1)
+	if err != nil {
+		return nil, err
+	}
+	txfe1, err := ctrl.ReadRegister(RegTxfe1)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"rx_frequency": rxFreq,
+		"tx_frequency": txFreq,
+		"mode":         modeName(mode),
+		"tx_switch":    txSwitch,
+		"rxfe1":        fmt.Sprintf("0x%02X", rxfe1),
+		"txfe1":        fmt.Sprintf("0x%02X", txfe1),
+	}, nil
+}
+
+// handleConfigure handles POST /api/hardware/configure
+// Validates the whole desired state, applies it in one controller session,
+// verifies by readback and rolls back to the previous state on any failure
+func (p *HardwarePlugin) handleConfigure(c *fiber.Ctx) error {
+	var state HardwareState
+	if err := c.BodyParser(&state); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	return p.configure(c, state, "Hardware configuration applied", nil)
+}
+
+// configure validates and applies a desired state and sends the answer
+// applied may add to the resulting state before it is sent.
+func (p *HardwarePlugin) configure(c *fiber.Ctx, state HardwareState, message string, applied func(map[string]interface{})) error {
+	if err := state.validate(); err != nil {
+		return SendError(c, 400, err)
+	}
+	if state.enablesTx() {
+		if err := checkTxAllowed(); err != nil {
+			return SendError(c, 423, err)
+		}
+		if err := p.checkTxInterlocks(); err != nil {
+			return SendError(c, 409, err)
+		}
+	}
+	override, err := p.bandPlanOverride(c)
+	if err != nil {
+		return SendError(c, 403, err)
+	}
+
+	ctx := c.UserContext()
+	cfg := p.getConfig()
+	clockFreq := cfg.SX1255.ClockFreq
+
+	var result map[string]interface{}
+	var applyErr, rollbackErr error
+	rolledBack := false
+
+	var band *BandPlanBand
+	err = p.withRequestController(c, func(ctrl *SX1255Controller) error {
+		// Validate the resulting TX frequency before anything is changed
+		if state.enablesTx() || state.TxFrequency != nil {
+			var err error
+			if state.TxFrequency != nil {
+				band, err = cfg.BandPlan.check(uint32(*state.TxFrequency), override)
+			} else {
+				band, err = p.checkTxBand(ctrl, override)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		snap, err := takeSnapshot(ctrl)
+		if err != nil {
+			return err
+		}
+
+		applyErr = applyState(ctrl, state, cfg.Sequencing)
+		if applyErr == nil {
+			applyErr = verifyState(ctrl, state, clockFreq)
+		}
+		if applyErr != nil {
+			slog.WarnContext(ctx, "Hardware configure failed, rolling back", "error", applyErr)
+			rollbackErr = restoreSnapshot(ctrl, snap, cfg.Sequencing)
+			rolledBack = rollbackErr == nil
+			return nil
+		}
+
+		mode, err := ctrl.GetMode()
+		if err != nil {
+			return err
+		}
+		if !ctrl.Simulated() {
+			p.updateTxTimeout(mode, band, override)
+		}
+
+		result, err = readState(ctrl)
+		return err
+	})
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Hardware configure failed", "error", err)
+		return sendTxError(c, err)
+	}
+
+	if applyErr != nil {
+		data := fiber.Map{"rolled_back": rolledBack}
+		if rollbackErr != nil {
+			slog.ErrorContext(ctx, "Hardware configure rollback failed", "error", rollbackErr)
+			data["rollback_error"] = rollbackErr.Error()
+		}
+		return c.Status(500).JSON(APIResponse{
+			Success: false,
+			Data:    data,
+			Error:   applyErr.Error(),
+		})
+	}
+
+	slog.InfoContext(ctx, "Hardware configuration applied", "state", result)
+	if applied != nil {
+		applied(result)
+	}
+	return SendSuccess(c, result, message)
+}
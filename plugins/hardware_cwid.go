@@ -0,0 +1,623 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Station ID defaults and limits
+const (
+	DefaultCWIDStateFile = "/var/lib/linht/cwid.json"
+	DefaultCWIDWPM       = 20
+	DefaultCWIDInterval  = 600 // seconds
+	DefaultCWIDTone      = 800 // Hz from the carrier
+	DefaultCWIDLevel     = -6.0
+	minCWIDWPM           = 5
+	maxCWIDWPM           = 40
+	minCWIDInterval      = 30
+	maxCWIDInterval      = 3600
+	cwidPollInterval     = time.Second
+	cwidRetryDelay       = 30 * time.Second // after a failed ID
+	cwidCommandTimeout   = 30 * time.Second
+	cwidRampTime         = 5 * time.Millisecond // rise and fall of each element, avoids key clicks
+	cwidSentEvent        = "hardware.cwid.sent"
+)
+
+// morseCode maps the characters a station ID may contain to dots and dashes
+var morseCode = map[rune]string{
+	'A': ".-", 'B': "-...", 'C': "-.-.", 'D': "-..", 'E': ".", 'F': "..-.",
+	'G': "--.", 'H': "....", 'I': "..", 'J': ".---", 'K': "-.-", 'L': ".-..",
+	'M': "--", 'N': "-.", 'O': "---", 'P': ".--.", 'Q': "--.-", 'R': ".-.",
+	'S': "...", 'T': "-", 'U': "..-", 'V': "...-", 'W': ".--", 'X': "-..-",
+	'Y': "-.--", 'Z': "--..",
+	'0': "-----", '1': ".----", '2': "..---", '3': "...--", '4': "....-",
+	'5': ".....", '6': "-....", '7': "--...", '8': "---..", '9': "----.",
+	'/': "-..-.", '-': "-....-", '=': "-...-", '?': "..--..", '.': ".-.-.-", ',': "--..--",
+}
+
+// errCWIDNotKeyed is returned for a manual ID while the transmitter is off
+var errCWIDNotKeyed = errors.New("the transmitter is not keyed")
+
+// CWIDConfig holds the station identification settings
+// enabled, callsign, wpm and interval can be changed through the API; those changes
+// are kept in state_file and take precedence over the config file.
+type CWIDConfig struct {
+	Enabled   bool    `yaml:"enabled"`
+	Callsign  string  `yaml:"callsign"`
+	WPM       int     `yaml:"wpm"`      // words per minute (PARIS)
+	Interval  int     `yaml:"interval"` // seconds between IDs while transmitting
+	Tone      float64 `yaml:"tone"`     // Hz from the carrier
+	Level     float64 `yaml:"level"`    // dBFS peak, limited to hardware.testsignal.max_level
+	Command   string  `yaml:"command"`  // run instead of keying CW, e.g. to have the modem send the ID
+	StateFile string  `yaml:"state_file"`
+}
+
+// CWIDSettings are the station ID settings changeable through the API
+type CWIDSettings struct {
+	Enabled  bool   `json:"enabled"`
+	Callsign string `json:"callsign"`
+	WPM      int    `json:"wpm"`
+	Interval int    `json:"interval"`
+}
+
+// applyCWIDDefaults fills in defaults
+func applyCWIDDefaults(cfg *CWIDConfig) {
+	if cfg.StateFile == "" {
+		cfg.StateFile = DefaultCWIDStateFile
+	}
+	if cfg.WPM <= 0 {
+		cfg.WPM = DefaultCWIDWPM
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultCWIDInterval
+	}
+	if cfg.Tone == 0 {
+		cfg.Tone = DefaultCWIDTone
+	}
+	if cfg.Level >= 0 {
+		cfg.Level = DefaultCWIDLevel
+	}
+	cfg.Callsign = strings.ToUpper(strings.TrimSpace(cfg.Callsign))
+}
+
+// settings returns the API-changeable part of the configuration
+func (cfg CWIDConfig) settings() CWIDSettings {
+	return CWIDSettings{Enabled: cfg.Enabled, Callsign: cfg.Callsign, WPM: cfg.WPM, Interval: cfg.Interval}
+}
+
+// validate checks the settings; a callsign is only required while enabled
+func (s *CWIDSettings) validate() error {
+	s.Callsign = strings.ToUpper(strings.TrimSpace(s.Callsign))
+	if s.Enabled && s.Callsign == "" {
+		return fmt.Errorf("callsign is required to enable the station ID")
+	}
+	for _, r := range s.Callsign {
+		if _, ok := morseCode[r]; !ok && r != ' ' {
+			return fmt.Errorf("callsign: %q has no Morse code", r)
+		}
+	}
+	if s.WPM < minCWIDWPM || s.WPM > maxCWIDWPM {
+		return fmt.Errorf("wpm must be within %d-%d", minCWIDWPM, maxCWIDWPM)
+	}
+	if s.Interval < minCWIDInterval || s.Interval > maxCWIDInterval {
+		return fmt.Errorf("interval must be within %d-%d seconds", minCWIDInterval, maxCWIDInterval)
+	}
+	return nil
+}
+
+// validate checks the configured settings and tone
+func (cfg CWIDConfig) validate() error {
+	c := cfg
+	applyCWIDDefaults(&c)
+	settings := c.settings()
+	if err := settings.validate(); err != nil {
+		return fmt.Errorf("hardware.cwid: %w", err)
+	}
+	if c.Tone < 0 {
+		return fmt.Errorf("hardware.cwid: tone must be positive")
+	}
+	return nil
+}
+
+// morse returns the dots and dashes of a text, characters separated by spaces and words by " / "
+func morse(text string) string {
+	words := strings.Fields(text)
+	coded := make([]string, 0, len(words))
+	for _, word := range words {
+		chars := make([]string, 0, len(word))
+		for _, r := range word {
+			chars = append(chars, morseCode[r])
+		}
+		coded = append(coded, strings.Join(chars, " "))
+	}
+	return strings.Join(coded, " / ")
+}
+
+// cwElement is a keyed (on) or silent stretch of a Morse transmission in dot units
+type cwElement struct {
+	on    bool
+	units int
+}
+
+// morseElements returns the keying of a text: dot 1, dash 3, gaps of 1 within
+// a character, 3 between characters and 7 between words
+func morseElements(text string) []cwElement {
+	var elements []cwElement
+	for w, word := range strings.Fields(text) {
+		if w > 0 {
+			elements = append(elements, cwElement{units: 7})
+		}
+		for c, r := range word {
+			if c > 0 {
+				elements = append(elements, cwElement{units: 3})
+			}
+			for s, symbol := range morseCode[r] {
+				if s > 0 {
+					elements = append(elements, cwElement{units: 1})
+				}
+				units := 1
+				if symbol == '-' {
+					units = 3
+				}
+				elements = append(elements, cwElement{on: true, units: units})
+			}
+		}
+	}
+	return elements
+}
+
+// morseDuration returns the time a text takes at a speed; a dot lasts 1.2/wpm seconds
+func morseDuration(text string, wpm int) time.Duration {
+	units := 0
+	for _, element := range morseElements(text) {
+		units += element.units
+	}
+	return time.Duration(units) * time.Duration(1.2/float64(wpm)*float64(time.Second))
+}
+
+// writeMorse writes the keyed tone as interleaved int16 I/Q samples
+// Every element rises and falls with a raised cosine over cwidRampTime.
+func writeMorse(w io.Writer, text string, wpm int, tone, levelDb float64, sampleRate int) error {
+	unit := int(math.Round(1.2 / float64(wpm) * float64(sampleRate)))
+	ramp := int(cwidRampTime.Seconds() * float64(sampleRate))
+	if ramp > unit/2 {
+		ramp = unit / 2
+	}
+	amplitude := 32767 * math.Pow(10, levelDb/20)
+	step := 2 * math.Pi * tone / float64(sampleRate)
+
+	writer := bufio.NewWriter(w)
+	sample := make([]byte, 4)
+	var index int64
+	for _, element := range morseElements(text) {
+		n := element.units * unit
+		for i := 0; i < n; i++ {
+			var iValue, qValue float64
+			if element.on {
+				envelope := 1.0
+				if edge := min(i, n-1-i); edge < ramp {
+					envelope = 0.5 - 0.5*math.Cos(math.Pi*float64(edge)/float64(ramp))
+				}
+				phase := math.Mod(step*float64(index), 2*math.Pi)
+				iValue = amplitude * envelope * math.Cos(phase)
+				qValue = amplitude * envelope * math.Sin(phase)
+			}
+			binary.LittleEndian.PutUint16(sample, uint16(int16(math.Round(iValue))))
+			binary.LittleEndian.PutUint16(sample[2:], uint16(int16(math.Round(qValue))))
+			if _, err := writer.Write(sample); err != nil {
+				return err
+			}
+			index++
+		}
+	}
+	return writer.Flush()
+}
+
+// CWIDStatus reports the station ID timer
+type CWIDStatus struct {
+	CWIDSettings
+	Method    string     `json:"method"` // cw or command
+	Morse     string     `json:"morse"`
+	Duration  float64    `json:"duration"` // seconds one ID takes in CW
+	Keyed     bool       `json:"keyed"`    // transmitter seen keyed at the last poll
+	Sending   bool       `json:"sending"`
+	LastID    *time.Time `json:"last_id,omitempty"`
+	NextID    *time.Time `json:"next_id,omitempty"` // while keyed
+	Sent      int        `json:"sent"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// cwidTimer identifies the station at the configured interval while the transmitter is keyed
+type cwidTimer struct {
+	plugin   *HardwarePlugin
+	config   CWIDConfig
+	stopChan chan struct{}
+	doneChan chan struct{}
+	sendMu   sync.Mutex // one ID at a time
+
+	mu        sync.Mutex
+	settings  CWIDSettings
+	keyed     bool
+	sending   bool
+	lastID    time.Time
+	retryAt   time.Time
+	sent      int
+	lastError string
+}
+
+// newCWIDTimer creates a timer, restoring the settings saved through the API
+func newCWIDTimer(plugin *HardwarePlugin, cfg CWIDConfig) *cwidTimer {
+	t := &cwidTimer{
+		plugin:   plugin,
+		config:   cfg,
+		settings: cfg.settings(),
+		stopChan: make(chan struct{}),
+		doneChan: make(chan struct{}),
+	}
+	if err := t.loadSettings(); err != nil {
+		slog.Warn("Failed to load station ID settings", "file", cfg.StateFile, "error", err)
+	}
+	return t
+}
+
+// loadSettings reads the settings saved through the API
+func (t *cwidTimer) loadSettings() error {
+	data, err := os.ReadFile(t.config.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var settings CWIDSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return fmt.Errorf("invalid station ID state file: %w", err)
+	}
+	if err := settings.validate(); err != nil {
+		return fmt.Errorf("invalid station ID state file: %w", err)
+	}
+	t.settings = settings
+	return nil
+}
+
+// saveSettings applies and persists settings changed through the API
+func (t *cwidTimer) saveSettings(settings CWIDSettings) error {
+	data, _ := json.MarshalIndent(settings, "", "  ")
+	if err := os.MkdirAll(filepath.Dir(t.config.StateFile), 0755); err != nil {
+		return fmt.Errorf("failed to persist station ID settings: %w", err)
+	}
+	if err := writeFileAtomic(t.config.StateFile, data); err != nil {
+		return fmt.Errorf("failed to persist station ID settings: %w", err)
+	}
+	t.mu.Lock()
+	t.settings = settings
+	t.mu.Unlock()
+	return nil
+}
+
+// getSettings returns the current settings
+func (t *cwidTimer) getSettings() CWIDSettings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.settings
+}
+
+// Start begins watching the transmitter
+func (t *cwidTimer) Start() {
+	go func() {
+		defer close(t.doneChan)
+
+		ticker := time.NewTicker(cwidPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.stopChan:
+				return
+			case <-ticker.C:
+			}
+			if t.due() {
+				t.send(context.Background())
+			}
+		}
+	}()
+}
+
+// Stop ends the timer and waits for a running ID to finish
+func (t *cwidTimer) Stop() {
+	close(t.stopChan)
+	<-t.doneChan
+}
+
+// due polls the mode register and reports whether the station has to identify
+// It is due when the transmitter is keyed and the last ID is older than the interval.
+func (t *cwidTimer) due() bool {
+	if !t.getSettings().Enabled {
+		t.mu.Lock()
+		t.keyed = false
+		t.mu.Unlock()
+		return false
+	}
+
+	var mode uint8
+	err := t.plugin.withSPI(func(spi *SPIDevice) error {
+		var err error
+		mode, err = spi.ReadRegister(RegMode)
+		return err
+	})
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.keyed = err == nil && mode&ModeBitTxEnable != 0
+	if !t.keyed || time.Now().Before(t.retryAt) {
+		return false
+	}
+	return t.lastID.IsZero() || time.Since(t.lastID) >= time.Duration(t.settings.Interval)*time.Second
+}
+
+// nextID returns when the next ID is due while keyed
+// Called with t.mu held.
+func (t *cwidTimer) nextID() *time.Time {
+	if !t.keyed || !t.settings.Enabled {
+		return nil
+	}
+	next := time.Now()
+	if !t.lastID.IsZero() {
+		if due := t.lastID.Add(time.Duration(t.settings.Interval) * time.Second); due.After(next) {
+			next = due
+		}
+	}
+	if t.retryAt.After(next) {
+		next = t.retryAt
+	}
+	return &next
+}
+
+// status reports the timer state
+func (t *cwidTimer) status() CWIDStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := CWIDStatus{
+		CWIDSettings: t.settings,
+		Method:       "cw",
+		Morse:        morse(t.settings.Callsign),
+		Duration:     morseDuration(t.settings.Callsign, t.settings.WPM).Seconds(),
+		Keyed:        t.keyed,
+		Sending:      t.sending,
+		NextID:       t.nextID(),
+		Sent:         t.sent,
+		LastError:    t.lastError,
+	}
+	if t.config.Command != "" {
+		status.Method = "command"
+	}
+	if !t.lastID.IsZero() {
+		last := t.lastID
+		status.LastID = &last
+	}
+	return status
+}
+
+// send identifies the station now, through the command or as CW on the baseband interface
+func (t *cwidTimer) send(ctx context.Context) error {
+	t.sendMu.Lock()
+	defer t.sendMu.Unlock()
+
+	settings := t.getSettings()
+	t.mu.Lock()
+	t.sending = true
+	t.mu.Unlock()
+
+	var err error
+	method := "cw"
+	if t.config.Command != "" {
+		method = "command"
+		err = t.runCommand(ctx, settings)
+	} else {
+		err = t.playCW(settings)
+	}
+
+	t.mu.Lock()
+	t.sending = false
+	now := time.Now()
+	event := fiber.Map{"callsign": settings.Callsign, "method": method}
+	if err != nil {
+		t.lastError = err.Error()
+		t.retryAt = now.Add(cwidRetryDelay)
+		event["error"] = err.Error()
+	} else {
+		t.lastError = ""
+		t.lastID = now
+		t.sent++
+	}
+	t.mu.Unlock()
+
+	if err != nil {
+		slog.Error("Station ID failed", "callsign", settings.Callsign, "method", method, "error", err)
+	} else {
+		slog.Info("Station ID sent", "callsign", settings.Callsign, "method", method)
+	}
+	PublishEvent(cwidSentEvent, hardwareMonitorEventSource, event)
+	return err
+}
+
+// runCommand runs the configured command with the callsign and speed in its environment
+func (t *cwidTimer) runCommand(ctx context.Context, settings CWIDSettings) error {
+	ctx, cancel := context.WithTimeout(ctx, cwidCommandTimeout)
+	defer cancel()
+
+	args := strings.Fields(t.config.Command)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(),
+		"LINHT_CWID_CALLSIGN="+settings.Callsign,
+		"LINHT_CWID_WPM="+strconv.Itoa(settings.WPM))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// playCW plays the callsign as a keyed tone on the baseband playback device
+// The transmitter is already keyed; the settings of the transceiver are not touched.
+func (t *cwidTimer) playCW(settings CWIDSettings) error {
+	cfg := t.plugin.getConfig()
+	if session := t.plugin.getTestSignal(); session != nil && session.getStatus().Active {
+		return errTestSignalActive
+	}
+	if nyquist := float64(cfg.Baseband.SampleRate) / 2; t.config.Tone >= nyquist {
+		return fmt.Errorf("tone at %.0f Hz is outside the baseband bandwidth (±%.0f Hz)", t.config.Tone, nyquist)
+	}
+	level := math.Min(t.config.Level, cfg.TestSignal.MaxLevel)
+
+	cmd := exec.Command("aplay", "-q",
+		"-D", cfg.Baseband.PlaybackDevice,
+		"-t", "raw",
+		"-f", "S16_LE",
+		"-c", "2",
+		"-r", strconv.Itoa(cfg.Baseband.SampleRate))
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start aplay: %w", err)
+	}
+
+	// Playback must not outlast the ID; a blocked device is killed
+	limit := morseDuration(settings.Callsign, settings.WPM) + testSignalGrace
+	killTimer := time.AfterFunc(limit, func() {
+		cmd.Process.Kill()
+	})
+	defer killTimer.Stop()
+
+	writeErr := writeMorse(stdin, settings.Callsign, settings.WPM, t.config.Tone, level, cfg.Baseband.SampleRate)
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("baseband playback failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	return writeErr
+}
+
+// startCWID starts the station ID timer
+func (p *HardwarePlugin) startCWID(cfg HardwareConfig) {
+	timer := newCWIDTimer(p, cfg.CWID)
+	p.mu.Lock()
+	p.cwid = timer
+	p.mu.Unlock()
+	timer.Start()
+}
+
+// stopCWID stops the station ID timer
+func (p *HardwarePlugin) stopCWID() {
+	p.mu.Lock()
+	timer := p.cwid
+	p.cwid = nil
+	p.mu.Unlock()
+
+	if timer != nil {
+		timer.Stop()
+	}
+}
+
+// getCWID returns the station ID timer
+func (p *HardwarePlugin) getCWID() *cwidTimer {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.cwid
+}
+
+// handleGetCWID handles GET /api/hardware/cwid
+func (p *HardwarePlugin) handleGetCWID(c *fiber.Ctx) error {
+	timer := p.getCWID()
+	if timer == nil {
+		return SendErrorMessage(c, 503, "Station ID is not running")
+	}
+	return SendSuccess(c, timer.status(), "")
+}
+
+// handleSetCWID handles PUT /api/hardware/cwid {"enabled": true, "callsign": "OE3XYZ", "wpm": 20, "interval": 600}
+// Omitted fields keep their value; the settings are saved across restarts
+func (p *HardwarePlugin) handleSetCWID(c *fiber.Ctx) error {
+	timer := p.getCWID()
+	if timer == nil {
+		return SendErrorMessage(c, 503, "Station ID is not running")
+	}
+	settings := timer.getSettings()
+	if err := json.Unmarshal(c.Body(), &settings); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body: "+err.Error())
+	}
+	if err := settings.validate(); err != nil {
+		return SendError(c, 400, err)
+	}
+	if dryRun(c) != nil {
+		return SendSuccess(c, settings, "")
+	}
+
+	if err := timer.saveSettings(settings); err != nil {
+		return SendError(c, 500, err)
+	}
+	slog.InfoContext(c.UserContext(), "Station ID settings changed",
+		"enabled", settings.Enabled,
+		"callsign", settings.Callsign,
+		"wpm", settings.WPM,
+		"interval", settings.Interval)
+	return SendSuccess(c, timer.status(), "Station ID settings saved")
+}
+
+// handleSendCWID handles POST /api/hardware/cwid/send
+// Identifies immediately; the transmitter has to be keyed
+func (p *HardwarePlugin) handleSendCWID(c *fiber.Ctx) error {
+	timer := p.getCWID()
+	if timer == nil {
+		return SendErrorMessage(c, 503, "Station ID is not running")
+	}
+	if err := checkTxAllowed(); err != nil {
+		return SendError(c, 423, err)
+	}
+	settings := timer.getSettings()
+	if settings.Callsign == "" {
+		return SendErrorMessage(c, 400, "No callsign configured")
+	}
+
+	var mode uint8
+	err := p.withRequestController(c, func(ctrl *SX1255Controller) error {
+		var err error
+		mode, err = ctrl.GetMode()
+		return err
+	})
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	if !modeEnablesTx(mode) {
+		return SendError(c, 409, errCWIDNotKeyed)
+	}
+	if dryRun(c) != nil {
+		return SendSuccess(c, timer.status(), "")
+	}
+
+	if err := timer.send(c.UserContext()); err != nil {
+		if errors.Is(err, errTestSignalActive) {
+			return SendError(c, 409, err)
+		}
+		return SendError(c, 500, err)
+	}
+	return SendSuccess(c, timer.status(), "Station ID sent")
+}
@@ -0,0 +1,643 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Doppler correction defaults and limits
+const (
+	DefaultDopplerTLEFile    = "/var/lib/linht/tle.txt"
+	DefaultDopplerInterval   = 1000 // milliseconds
+	DefaultDopplerResolution = 50   // Hz
+	DefaultDopplerPassHours  = 24
+	MaxDopplerPassHours      = 72
+	DefaultDopplerPassLimit  = 20
+	MaxDopplerPassLimit      = 200
+	dopplerStartedEvent      = "hardware.doppler.started"
+	dopplerFinishedEvent     = "hardware.doppler.finished"
+)
+
+// Doppler finish reasons
+const (
+	dopplerStopped = "stopped"
+	dopplerLOS     = "los"
+	dopplerError   = "error"
+)
+
+var (
+	errDopplerActive   = errors.New("Doppler correction is already running")
+	errDopplerDisabled = errors.New("Doppler correction is disabled (hardware.doppler.enabled)")
+	errNoObserver      = errors.New("no site position: set hardware.doppler latitude and longitude or provide a GNSS fix")
+	errUnknownSat      = errors.New("unknown satellite")
+)
+
+// DopplerConfig holds the site and the satellite elements for Doppler correction
+type DopplerConfig struct {
+	Enabled      bool    `yaml:"enabled"`
+	TLEFile      string  `yaml:"tle_file"`      // two- or three-line element sets
+	Latitude     float64 `yaml:"latitude"`      // degrees; with longitude 0 as well the GNSS fix is used
+	Longitude    float64 `yaml:"longitude"`     // degrees
+	Altitude     float64 `yaml:"altitude"`      // meters
+	Interval     int     `yaml:"interval"`      // milliseconds between corrections
+	Resolution   uint32  `yaml:"resolution"`    // Hz; smaller changes are not written
+	MinElevation float64 `yaml:"min_elevation"` // degrees; passes start and end here
+}
+
+// applyDopplerDefaults fills in unset Doppler settings
+func applyDopplerDefaults(cfg *DopplerConfig) {
+	if cfg.TLEFile == "" {
+		cfg.TLEFile = DefaultDopplerTLEFile
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultDopplerInterval
+	}
+	if cfg.Resolution == 0 {
+		cfg.Resolution = DefaultDopplerResolution
+	}
+}
+
+// validate checks the site coordinates
+func (cfg DopplerConfig) validate() error {
+	if cfg.Latitude < -90 || cfg.Latitude > 90 || cfg.Longitude < -180 || cfg.Longitude > 180 {
+		return fmt.Errorf("hardware.doppler: latitude must be within ±90 and longitude within ±180 degrees")
+	}
+	if cfg.MinElevation < 0 || cfg.MinElevation >= 90 {
+		return fmt.Errorf("hardware.doppler: min_elevation must be between 0 and 90 degrees")
+	}
+	return nil
+}
+
+// observer returns the site from the config, or the GNSS position when none is configured
+func (cfg DopplerConfig) observer() (Observer, error) {
+	if cfg.Latitude != 0 || cfg.Longitude != 0 {
+		return Observer{Latitude: cfg.Latitude, Longitude: cfg.Longitude, Altitude: cfg.Altitude, Source: "config"}, nil
+	}
+	if fix, ok := CurrentGNSSFix(); ok && fix.HasPosition() {
+		return Observer{Latitude: fix.Latitude, Longitude: fix.Longitude, Altitude: fix.Altitude, Source: "gnss"}, nil
+	}
+	return Observer{}, errNoObserver
+}
+
+// loadTLEs reads the element sets; a missing file has none
+func loadTLEs(file string) ([]TLE, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []TLE{}, nil
+		}
+		return nil, err
+	}
+	tles, err := ParseTLEs(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLE file %s: %w", file, err)
+	}
+	return tles, nil
+}
+
+// findTLE returns the element set of a satellite by name (case-insensitive) or catalog number
+func findTLE(tles []TLE, satellite string) (TLE, bool) {
+	for _, tle := range tles {
+		if strings.EqualFold(tle.Name, satellite) || strconv.Itoa(tle.Catalog) == satellite {
+			return tle, true
+		}
+	}
+	return TLE{}, false
+}
+
+// satelliteModel loads the elements of a satellite and initializes its orbit model
+func (p *HardwarePlugin) satelliteModel(cfg DopplerConfig, satellite string) (*sgp4, error) {
+	tles, err := loadTLEs(cfg.TLEFile)
+	if err != nil {
+		return nil, err
+	}
+	tle, ok := findTLE(tles, satellite)
+	if !ok {
+		return nil, fmt.Errorf("%w %q (PUT /hardware/doppler/tle)", errUnknownSat, satellite)
+	}
+	return newSGP4(tle)
+}
+
+// DopplerRequest starts Doppler correction for a satellite
+// The nominal frequencies come from downlink and uplink or from a named channel.
+type DopplerRequest struct {
+	Satellite string     `json:"satellite"`
+	Downlink  *Frequency `json:"downlink"` // corrected into the RX frequency
+	Uplink    *Frequency `json:"uplink"`   // corrected into the TX frequency
+	Channel   string     `json:"channel"`  // named channel supplying rx_frequency and tx_frequency
+}
+
+// DopplerStatus reports a Doppler correction session
+type DopplerStatus struct {
+	Active      bool       `json:"active"`
+	Satellite   string     `json:"satellite"`
+	Catalog     int        `json:"catalog"`
+	Observer    Observer   `json:"observer"`
+	Downlink    uint32     `json:"downlink,omitempty"`     // nominal Hz
+	Uplink      uint32     `json:"uplink,omitempty"`       // nominal Hz
+	RxFrequency uint32     `json:"rx_frequency,omitempty"` // corrected, as last written
+	TxFrequency uint32     `json:"tx_frequency,omitempty"` // corrected, as last written
+	RxShift     int64      `json:"rx_shift"`               // Hz from the nominal downlink
+	TxShift     int64      `json:"tx_shift"`               // Hz from the nominal uplink
+	Look        LookAngles `json:"look"`
+	Pass        *Pass      `json:"pass,omitempty"` // current or next pass
+	Updates     int        `json:"updates"`        // frequency writes
+	Started     time.Time  `json:"started"`
+	Updated     time.Time  `json:"updated"`
+	Finished    *time.Time `json:"finished,omitempty"`
+	Reason      string     `json:"reason,omitempty"` // stopped, los or error
+	Error       string     `json:"error,omitempty"`
+}
+
+// dopplerSession follows a satellite and keeps the transceiver frequencies corrected
+type dopplerSession struct {
+	mu       sync.Mutex
+	status   DopplerStatus
+	model    *sgp4
+	override bool
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	done     chan struct{}
+}
+
+// getStatus returns a copy of the session status
+func (s *dopplerSession) getStatus() DopplerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// stop ends the correction and waits for the loop to finish
+func (s *dopplerSession) stop() {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+	<-s.done
+}
+
+// getDoppler returns the current or last Doppler session
+func (p *HardwarePlugin) getDoppler() *dopplerSession {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.doppler
+}
+
+// stopDoppler stops an active Doppler session
+func (p *HardwarePlugin) stopDoppler() (*dopplerSession, bool) {
+	session := p.getDoppler()
+	if session == nil || !session.getStatus().Active {
+		return nil, false
+	}
+	session.stop()
+	return session, true
+}
+
+// correctedFrequencies returns the RX and TX frequencies for the look angles
+// The satellite hears the uplink shifted as well, so it is pre-corrected the other way.
+func correctedFrequencies(look LookAngles, downlink, uplink uint32) (rx, tx uint32) {
+	factor := 1 + look.dopplerFactor()
+	if downlink != 0 {
+		rx = uint32(math.Round(float64(downlink) * factor))
+	}
+	if uplink != 0 {
+		tx = uint32(math.Round(float64(uplink) / factor))
+	}
+	return rx, tx
+}
+
+// newDopplerSession resolves a request into a session that is not yet running
+func (p *HardwarePlugin) newDopplerSession(req DopplerRequest, override bool) (*dopplerSession, error) {
+	cfg := p.getConfig()
+	if req.Satellite == "" {
+		return nil, fiber.NewError(400, "satellite is required")
+	}
+	status := DopplerStatus{Active: true, Satellite: req.Satellite}
+	if req.Downlink != nil {
+		status.Downlink = uint32(*req.Downlink)
+	}
+	if req.Uplink != nil {
+		status.Uplink = uint32(*req.Uplink)
+	}
+	if req.Channel != "" {
+		if status.Downlink != 0 || status.Uplink != 0 {
+			return nil, fiber.NewError(400, "give either channel or downlink/uplink")
+		}
+		ch, ok, err := p.getChannel(req.Channel)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fiber.NewError(404, fmt.Sprintf("Unknown channel %q", req.Channel))
+		}
+		status.Downlink, status.Uplink = uint32(ch.RxFrequency), uint32(ch.TxFrequency)
+	}
+	if status.Downlink == 0 && status.Uplink == 0 {
+		return nil, fiber.NewError(400, "downlink, uplink or channel is required")
+	}
+	for _, freq := range []uint32{status.Downlink, status.Uplink} {
+		if freq != 0 && (freq < MinFrequencyHz || freq > MaxFrequencyHz) {
+			return nil, fiber.NewError(400, fmt.Sprintf("%s is outside the tuning range (%s-%s)",
+				FormatFrequency(freq), FormatFrequency(MinFrequencyHz), FormatFrequency(MaxFrequencyHz)))
+		}
+	}
+
+	observer, err := cfg.Doppler.observer()
+	if err != nil {
+		return nil, err
+	}
+	model, err := p.satelliteModel(cfg.Doppler, req.Satellite)
+	if err != nil {
+		return nil, err
+	}
+	status.Satellite = model.tle.Name
+	status.Catalog = model.tle.Catalog
+	status.Observer = observer
+
+	now := time.Now()
+	look, err := model.look(observer, now)
+	if err != nil {
+		return nil, err
+	}
+	status.Look = look
+	if passes, err := model.passes(observer, now, now.Add(DefaultDopplerPassHours*time.Hour), cfg.Doppler.MinElevation, 1); err == nil && len(passes) > 0 {
+		status.Pass = &passes[0]
+	}
+	if _, tx := correctedFrequencies(look, status.Downlink, status.Uplink); tx != 0 {
+		if _, err := cfg.BandPlan.check(tx, override); err != nil {
+			return nil, err
+		}
+	}
+
+	return &dopplerSession{
+		status:   status,
+		model:    model,
+		override: override,
+		stopCh:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// startDoppler begins correcting the transceiver frequencies
+func (p *HardwarePlugin) startDoppler(req DopplerRequest, override bool) (*dopplerSession, error) {
+	p.dopplerMu.Lock()
+	defer p.dopplerMu.Unlock()
+
+	if current := p.getDoppler(); current != nil && current.getStatus().Active {
+		return nil, errDopplerActive
+	}
+	session, err := p.newDopplerSession(req, override)
+	if err != nil {
+		return nil, err
+	}
+	session.status.Started = time.Now()
+
+	// The first correction is written before answering so errors reach the caller
+	if err := p.correctDoppler(session, session.status.Look); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.doppler = session
+	p.mu.Unlock()
+
+	go p.runDoppler(session)
+	PublishEvent(dopplerStartedEvent, hardwareMonitorEventSource, session.getStatus())
+	return session, nil
+}
+
+// correctDoppler writes the corrected frequencies when they moved by the configured resolution
+func (p *HardwarePlugin) correctDoppler(s *dopplerSession, look LookAngles) error {
+	cfg := p.getConfig()
+	s.mu.Lock()
+	status := s.status
+	s.mu.Unlock()
+
+	rx, tx := correctedFrequencies(look, status.Downlink, status.Uplink)
+	moved := func(next, last uint32) bool {
+		return next != 0 && (last == 0 || math.Abs(float64(next)-float64(last)) >= float64(cfg.Doppler.Resolution))
+	}
+	writeRx, writeTx := moved(rx, status.RxFrequency), moved(tx, status.TxFrequency)
+
+	if writeRx || writeTx {
+		var band *BandPlanBand
+		if writeTx {
+			var err error
+			if band, err = cfg.BandPlan.check(tx, s.override); err != nil {
+				return err
+			}
+		}
+		err := p.withController(func(ctrl *SX1255Controller) error {
+			if writeRx {
+				if err := ctrl.SetRxFrequency(rx); err != nil {
+					return err
+				}
+			}
+			if !writeTx {
+				return nil
+			}
+			if err := ctrl.SetTxFrequency(tx); err != nil {
+				return err
+			}
+			mode, err := ctrl.GetMode()
+			if err != nil {
+				return err
+			}
+			p.updateTxTimeout(mode, band, s.override)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Look = look
+	s.status.Updated = time.Now()
+	if writeRx {
+		s.status.RxFrequency = rx
+		s.status.RxShift = int64(rx) - int64(status.Downlink)
+		s.status.Updates++
+	}
+	if writeTx {
+		s.status.TxFrequency = tx
+		s.status.TxShift = int64(tx) - int64(status.Uplink)
+		s.status.Updates++
+	}
+	return nil
+}
+
+// runDoppler corrects the frequencies until stopped, after the pass or on an error
+func (p *HardwarePlugin) runDoppler(s *dopplerSession) {
+	defer close(s.done)
+	cfg := p.getConfig().Doppler
+	ticker := time.NewTicker(time.Duration(cfg.Interval) * time.Millisecond)
+	defer ticker.Stop()
+
+	reason := dopplerStopped
+	var failure error
+	seen := false
+loop:
+	for {
+		select {
+		case <-s.stopCh:
+			break loop
+		case <-ticker.C:
+		}
+
+		look, err := s.model.look(s.getStatus().Observer, time.Now())
+		if err == nil {
+			err = p.correctDoppler(s, look)
+		}
+		if err != nil {
+			reason, failure = dopplerError, err
+			break loop
+		}
+		if look.Elevation >= cfg.MinElevation {
+			seen = true
+		} else if seen {
+			reason = dopplerLOS
+			break loop
+		}
+	}
+
+	finished := time.Now()
+	s.mu.Lock()
+	s.status.Active = false
+	s.status.Finished = &finished
+	s.status.Reason = reason
+	if failure != nil {
+		s.status.Error = failure.Error()
+	}
+	final := s.status
+	s.mu.Unlock()
+
+	if failure != nil {
+		slog.Error("Doppler correction failed", "satellite", final.Satellite, "error", failure)
+	} else {
+		slog.Info("Doppler correction finished", "satellite", final.Satellite, "reason", reason, "updates", final.Updates)
+	}
+	PublishEvent(dopplerFinishedEvent, hardwareMonitorEventSource, final)
+}
+
+// sendDopplerError maps Doppler errors to HTTP status codes
+func sendDopplerError(c *fiber.Ctx, err error) error {
+	var fe *fiber.Error
+	switch {
+	case errors.As(err, &fe):
+		return SendErrorMessage(c, fe.Code, fe.Message)
+	case errors.Is(err, errUnknownSat):
+		return SendError(c, 404, err)
+	case errors.Is(err, errDopplerActive), errors.Is(err, errNoObserver):
+		return SendError(c, 409, err)
+	}
+	return sendTxError(c, err)
+}
+
+// requireDoppler refuses requests while the subsystem is disabled
+func (p *HardwarePlugin) requireDoppler(c *fiber.Ctx) error {
+	if !p.getConfig().Doppler.Enabled {
+		return SendError(c, 404, errDopplerDisabled)
+	}
+	return c.Next()
+}
+
+// handleDopplerStart handles POST /api/hardware/doppler/start
+// {"satellite": "ISS", "downlink": "437.800 MHz", "uplink": "437.800 MHz"} or {"satellite": "ISS", "channel": "iss"}
+func (p *HardwarePlugin) handleDopplerStart(c *fiber.Ctx) error {
+	var req DopplerRequest
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body: "+err.Error())
+	}
+	override, err := p.bandPlanOverride(c)
+	if err != nil {
+		return SendError(c, 403, err)
+	}
+
+	if dryRun(c) != nil {
+		session, err := p.newDopplerSession(req, override)
+		if err != nil {
+			return sendDopplerError(c, err)
+		}
+		status := session.status
+		status.Active = false
+		status.RxFrequency, status.TxFrequency = correctedFrequencies(status.Look, status.Downlink, status.Uplink)
+		if status.RxFrequency != 0 {
+			status.RxShift = int64(status.RxFrequency) - int64(status.Downlink)
+		}
+		if status.TxFrequency != 0 {
+			status.TxShift = int64(status.TxFrequency) - int64(status.Uplink)
+		}
+		return SendSuccess(c, status, "")
+	}
+
+	session, err := p.startDoppler(req, override)
+	if err != nil {
+		return sendDopplerError(c, err)
+	}
+
+	status := session.getStatus()
+	slog.InfoContext(c.UserContext(), "Doppler correction started",
+		"satellite", status.Satellite,
+		"downlink", status.Downlink,
+		"uplink", status.Uplink,
+		"observer", status.Observer.Source)
+	return SendSuccess(c, status, fmt.Sprintf("Doppler correction for %s started", status.Satellite))
+}
+
+// handleDopplerStop handles POST /api/hardware/doppler/stop
+// The last corrected frequencies stay set.
+func (p *HardwarePlugin) handleDopplerStop(c *fiber.Ctx) error {
+	if dryRun(c) != nil {
+		if current := p.getDoppler(); current != nil && current.getStatus().Active {
+			return SendSuccess(c, current.getStatus(), "")
+		}
+		return SendErrorMessage(c, 404, "No Doppler correction active")
+	}
+	session, ok := p.stopDoppler()
+	if !ok {
+		return SendErrorMessage(c, 404, "No Doppler correction active")
+	}
+
+	slog.InfoContext(c.UserContext(), "Doppler correction stopped", "satellite", session.getStatus().Satellite)
+	return SendSuccess(c, session.getStatus(), "Doppler correction stopped")
+}
+
+// handleDopplerStatus handles GET /api/hardware/doppler
+// Reports the active correction, or the last one when idle
+func (p *HardwarePlugin) handleDopplerStatus(c *fiber.Ctx) error {
+	data := fiber.Map{"active": false}
+	if observer, err := p.getConfig().Doppler.observer(); err == nil {
+		data["observer"] = observer
+	}
+	if session := p.getDoppler(); session != nil {
+		status := session.getStatus()
+		data["active"] = status.Active
+		data["session"] = status
+	}
+	return SendSuccess(c, data, "")
+}
+
+// handleListSatellites handles GET /api/hardware/doppler/satellites
+func (p *HardwarePlugin) handleListSatellites(c *fiber.Ctx) error {
+	tles, err := loadTLEs(p.getConfig().Doppler.TLEFile)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	satellites := make([]fiber.Map, 0, len(tles))
+	for _, tle := range tles {
+		satellite := fiber.Map{
+			"name":        tle.Name,
+			"catalog":     tle.Catalog,
+			"epoch":       tle.Epoch,
+			"age_days":    math.Round(time.Since(tle.Epoch).Hours()/24*10) / 10,
+			"inclination": tle.Inclination,
+			"period":      math.Round(tle.Period*10) / 10,
+		}
+		if _, err := newSGP4(tle); err != nil {
+			satellite["error"] = err.Error()
+		}
+		satellites = append(satellites, satellite)
+	}
+	return SendSuccess(c, fiber.Map{"satellites": satellites}, "")
+}
+
+// handleSetTLEs handles PUT /api/hardware/doppler/tle with element sets in the body
+// Replaces the TLE file after checking every set
+func (p *HardwarePlugin) handleSetTLEs(c *fiber.Ctx) error {
+	tles, err := ParseTLEs(string(c.Body()))
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+	if len(tles) == 0 {
+		return SendErrorMessage(c, 400, "No element sets found")
+	}
+	if dryRun(c) != nil {
+		return SendSuccess(c, fiber.Map{"count": len(tles)}, "")
+	}
+
+	file := p.getConfig().Doppler.TLEFile
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return SendError(c, 500, err)
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, c.Body(), 0644); err != nil {
+		return SendError(c, 500, err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return SendError(c, 500, err)
+	}
+
+	slog.InfoContext(c.UserContext(), "TLEs updated", "count", len(tles))
+	return SendSuccess(c, fiber.Map{"count": len(tles)}, fmt.Sprintf("Stored %d element sets", len(tles)))
+}
+
+// handleListPasses handles GET /api/hardware/doppler/passes?satellite=&hours=24&min_elevation=&limit=20
+// Predicts the passes of one satellite, or of all in the TLE file, in order of AOS
+func (p *HardwarePlugin) handleListPasses(c *fiber.Ctx) error {
+	cfg := p.getConfig().Doppler
+	hours := c.QueryInt("hours", DefaultDopplerPassHours)
+	if hours <= 0 || hours > MaxDopplerPassHours {
+		return SendErrorMessage(c, 400, fmt.Sprintf("hours must be between 1 and %d", MaxDopplerPassHours))
+	}
+	limit := c.QueryInt("limit", DefaultDopplerPassLimit)
+	if limit <= 0 || limit > MaxDopplerPassLimit {
+		return SendErrorMessage(c, 400, fmt.Sprintf("limit must be between 1 and %d", MaxDopplerPassLimit))
+	}
+	minElevation := cfg.MinElevation
+	if value := c.Query("min_elevation"); value != "" {
+		var err error
+		if minElevation, err = strconv.ParseFloat(value, 64); err != nil || minElevation < 0 || minElevation >= 90 {
+			return SendErrorMessage(c, 400, "min_elevation must be between 0 and 90 degrees")
+		}
+	}
+	observer, err := cfg.observer()
+	if err != nil {
+		return SendError(c, 409, err)
+	}
+
+	tles, err := loadTLEs(cfg.TLEFile)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	if satellite := c.Query("satellite"); satellite != "" {
+		tle, ok := findTLE(tles, satellite)
+		if !ok {
+			return SendError(c, 404, fmt.Errorf("%w %q", errUnknownSat, satellite))
+		}
+		tles = []TLE{tle}
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	passes := []Pass{}
+	for _, tle := range tles {
+		model, err := newSGP4(tle)
+		if err != nil {
+			continue // deep-space objects are listed with their error in /satellites
+		}
+		found, err := model.passes(observer, now, now.Add(time.Duration(hours)*time.Hour), minElevation, limit)
+		if err != nil {
+			slog.WarnContext(c.UserContext(), "Pass prediction stopped early", "satellite", tle.Name, "error", err)
+		}
+		for _, pass := range found {
+			pass.Satellite = tle.Name
+			passes = append(passes, pass)
+		}
+	}
+	sort.Slice(passes, func(i, j int) bool { return passes[i].AOS.Before(passes[j].AOS) })
+	if len(passes) > limit {
+		passes = passes[:limit]
+	}
+
+	return SendSuccess(c, fiber.Map{"observer": observer, "passes": passes}, "")
+}
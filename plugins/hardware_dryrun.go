@@ -0,0 +1,309 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// dryRunLocal is the request local holding the simulated transceiver of a validate=true request
+const dryRunLocal = "hardware.dry_run"
+
+// GPIO outputs tracked by the register shadow and the simulation
+const (
+	pinReset = "reset"
+	pinTxRx  = "tx_rx"
+)
+
+// registerShadow remembers the register values and GPIO levels last seen on the bus
+// Validation starts from it so previews show the values they would replace without reading SPI.
+type registerShadow struct {
+	mu   sync.Mutex
+	regs map[uint8]uint8
+	pins map[string]bool
+}
+
+// observe records the registers written or read by an SX1255 transfer
+// The first byte addresses the first register; the following bytes address consecutive ones.
+func (s *registerShadow) observe(tx, rx []byte) {
+	if len(tx) < 2 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.regs == nil {
+		s.regs = make(map[uint8]uint8)
+	}
+	write := tx[0]&0x80 != 0
+	for i := 1; i < len(tx); i++ {
+		addr := (tx[0]&0x7F + uint8(i-1)) & 0x7F
+		if write {
+			s.regs[addr] = tx[i]
+		} else {
+			s.regs[addr] = rx[i]
+		}
+	}
+}
+
+// setPin records a GPIO output level
+func (s *registerShadow) setPin(name string, high bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pins == nil {
+		s.pins = make(map[string]bool)
+	}
+	s.pins[name] = high
+}
+
+// forget drops the register values, after a chip reset
+func (s *registerShadow) forget() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.regs = nil
+}
+
+// DryRunStep is one bus action a request would perform
+type DryRunStep struct {
+	Action      string `json:"action"`                // write, pin or wait
+	Address     string `json:"address,omitempty"`     // write: register address
+	Value       string `json:"value,omitempty"`       // write: value written
+	Previous    string `json:"previous,omitempty"`    // write: value it replaces
+	Description string `json:"description,omitempty"` // write: register name
+	Pin         string `json:"pin,omitempty"`         // pin: reset or tx_rx
+	Level       string `json:"level,omitempty"`       // pin: high or low
+	Delay       int    `json:"delay,omitempty"`       // wait: milliseconds
+}
+
+// DryRunRegister is the final value of a register changed by a request
+type DryRunRegister struct {
+	Address     string `json:"address"`
+	Value       string `json:"value"`
+	ValueDec    uint8  `json:"value_dec"`
+	Previous    string `json:"previous"`
+	Description string `json:"description"`
+}
+
+// DryRunResult answers a request made with validate=true
+type DryRunResult struct {
+	Valid     bool             `json:"valid"`
+	Steps     []DryRunStep     `json:"steps"`             // bus actions in order
+	Registers []DryRunRegister `json:"registers"`         // registers whose value would change
+	Assumed   []string         `json:"assumed,omitempty"` // registers never seen on the bus; defaults were assumed
+	Result    json.RawMessage  `json:"result,omitempty"`  // the endpoint's answer had it run
+}
+
+// registerSim stands in for the SX1255 and its GPIO lines during validation
+type registerSim struct {
+	mu      sync.Mutex
+	regs    [0x80]uint8
+	initial [0x80]uint8
+	known   [0x80]bool // value seen on the bus rather than assumed
+	assumed map[uint8]bool
+	pins    map[string]bool
+	steps   []DryRunStep
+}
+
+// newRegisterSim starts from the shadowed values, then the recommended defaults
+// sharedPins keeps the shadowed GPIO levels; a new session drives both outputs low.
+func newRegisterSim(shadow *registerShadow, sharedPins bool) *registerSim {
+	sim := &registerSim{assumed: make(map[uint8]bool), pins: map[string]bool{pinReset: false, pinTxRx: false}}
+	for addr, value := range DefaultRegisterValues {
+		sim.regs[addr] = value
+	}
+	shadow.mu.Lock()
+	for addr, value := range shadow.regs {
+		sim.regs[addr] = value
+		sim.known[addr] = true
+	}
+	if sharedPins {
+		for name, high := range shadow.pins {
+			sim.pins[name] = high
+		}
+	}
+	shadow.mu.Unlock()
+	sim.initial = sim.regs
+	return sim
+}
+
+// transfer emulates an SX1255 SPI transfer on the simulated registers
+func (s *registerSim) transfer(tx, rx []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(rx) > 0 {
+		rx[0] = 0
+	}
+	if len(tx) < 2 {
+		return nil
+	}
+	write := tx[0]&0x80 != 0
+	for i := 1; i < len(tx); i++ {
+		addr := (tx[0]&0x7F + uint8(i-1)) & 0x7F
+		if !write {
+			if !s.known[addr] {
+				s.assumed[addr] = true
+			}
+			rx[i] = s.regs[addr]
+			continue
+		}
+		s.steps = append(s.steps, DryRunStep{
+			Action:      "write",
+			Address:     fmt.Sprintf("0x%02X", addr),
+			Value:       fmt.Sprintf("0x%02X", tx[i]),
+			Previous:    fmt.Sprintf("0x%02X", s.regs[addr]),
+			Description: RegisterDescriptions[addr],
+		})
+		s.regs[addr] = tx[i]
+		s.known[addr] = true
+		rx[i] = 0
+	}
+	return nil
+}
+
+// setPin records a GPIO output change
+func (s *registerSim) setPin(name string, high bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pins[name] = high
+	s.steps = append(s.steps, DryRunStep{Action: "pin", Pin: name, Level: resetPinLevel(high)})
+}
+
+// pin returns a simulated GPIO output level
+func (s *registerSim) pin(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pins[name]
+}
+
+// wait records a delay of a keying sequence
+func (s *registerSim) wait(delay int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.steps = append(s.steps, DryRunStep{Action: "wait", Delay: delay})
+}
+
+// reset records a chip reset pulse; the registers return to their defaults
+func (s *registerSim) reset() {
+	s.setPin(pinReset, true)
+	s.setPin(pinReset, false)
+	s.wait(5)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.regs = [0x80]uint8{}
+	s.known = [0x80]bool{}
+	for addr, value := range DefaultRegisterValues {
+		s.regs[addr] = value
+	}
+}
+
+// result summarizes the simulated actions
+func (s *registerSim) result(data json.RawMessage) DryRunResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := DryRunResult{Valid: true, Steps: s.steps, Registers: []DryRunRegister{}, Result: data}
+	if result.Steps == nil {
+		result.Steps = []DryRunStep{}
+	}
+	for addr := range s.regs {
+		if s.regs[addr] == s.initial[addr] {
+			continue
+		}
+		result.Registers = append(result.Registers, DryRunRegister{
+			Address:     fmt.Sprintf("0x%02X", addr),
+			Value:       fmt.Sprintf("0x%02X", s.regs[addr]),
+			ValueDec:    s.regs[addr],
+			Previous:    fmt.Sprintf("0x%02X", s.initial[addr]),
+			Description: RegisterDescriptions[uint8(addr)],
+		})
+	}
+	assumed := make([]int, 0, len(s.assumed))
+	for addr := range s.assumed {
+		assumed = append(assumed, int(addr))
+	}
+	sort.Ints(assumed)
+	for _, addr := range assumed {
+		result.Assumed = append(result.Assumed, fmt.Sprintf("0x%02X", addr))
+	}
+	return result
+}
+
+// dryRun returns the simulation of a validate=true request, nil for a real request
+func dryRun(c *fiber.Ctx) *registerSim {
+	sim, _ := c.Locals(dryRunLocal).(*registerSim)
+	return sim
+}
+
+// newDryRunController returns a controller working on simulated registers
+func (p *HardwarePlugin) newDryRunController(sim *registerSim) *SX1255Controller {
+	cfg := p.getConfig().SX1255
+	return &SX1255Controller{
+		spi:         &SPIDevice{device: cfg.SPIDevice, sim: sim},
+		gpio:        &GPIOController{chipPath: cfg.GPIOChip, resetPin: cfg.ResetPin, txRxPin: cfg.TxRxPin, sim: sim},
+		clockFreq:   cfg.ClockFreq,
+		initialized: true,
+		tuned:       p.applyCalibration,
+	}
+}
+
+// withRequestController runs fn in a controller session, on simulated registers for validate=true
+func (p *HardwarePlugin) withRequestController(c *fiber.Ctx, fn func(*SX1255Controller) error) error {
+	if sim := dryRun(c); sim != nil {
+		return fn(p.newDryRunController(sim))
+	}
+	return p.withController(fn)
+}
+
+// withRequestTxController is withTxController on simulated registers for validate=true
+// The interlocks and the band plan are checked as for a real request.
+func (p *HardwarePlugin) withRequestTxController(c *fiber.Ctx, keying, override bool, fn func(*SX1255Controller) error) error {
+	if sim := dryRun(c); sim != nil {
+		return p.txSession(func(fn func(*SX1255Controller) error) error {
+			return fn(p.newDryRunController(sim))
+		}, keying, override, fn)
+	}
+	return p.withTxController(keying, override, fn)
+}
+
+// dryRunMiddleware answers POST, PUT and PATCH requests with validate=true without touching the bus
+// The handler runs its usual checks against simulated registers; its answer is
+// replaced by the bus actions it would have performed.
+func (p *HardwarePlugin) dryRunMiddleware(c *fiber.Ctx) error {
+	if !c.QueryBool("validate") {
+		return c.Next()
+	}
+	switch c.Method() {
+	case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch:
+	case fiber.MethodGet, fiber.MethodHead:
+		return c.Next()
+	default:
+		return SendErrorMessage(c, 400, "validate=true is not supported for "+c.Method()+" requests")
+	}
+
+	p.busMu.Lock()
+	shared := p.shared != nil
+	p.busMu.Unlock()
+	sim := newRegisterSim(&p.shadow, shared)
+	c.Locals(dryRunLocal, sim)
+	c.SetUserContext(WithDryRun(c.UserContext()))
+
+	if err := c.Next(); err != nil {
+		return err
+	}
+	if c.Response().StatusCode() >= 300 {
+		return nil // the checks failed; the handler's error stands
+	}
+
+	var response struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(c.Response().Body(), &response); err != nil {
+		return SendError(c, 500, err)
+	}
+	if string(response.Data) == "null" {
+		response.Data = nil
+	}
+	return SendSuccess(c, sim.result(response.Data), "Validation passed; nothing was changed")
+}
@@ -0,0 +1,400 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Duty cycle defaults and events
+const (
+	DefaultDutyCycleStateFile    = "/var/lib/linht/txtime.json"
+	DefaultDutyCyclePollInterval = 1 // seconds
+	dutyCycleSaveInterval        = 5 * time.Minute
+	dutyCycleExceededEvent       = "hardware.duty_cycle.exceeded"
+	dutyCycleRestoredEvent       = "hardware.duty_cycle.restored"
+)
+
+// Accounting windows
+const (
+	dutyCycleHour = time.Hour
+	dutyCycleDay  = 24 * time.Hour
+)
+
+// errDutyCycle is returned when keying would exceed a duty cycle limit
+var errDutyCycle = errors.New("duty cycle limit")
+
+// DutyCycleConfig holds the transmit time limits
+// TX time is always accounted; the limits are only enforced with enforce set.
+type DutyCycleConfig struct {
+	Enforce      bool    `yaml:"enforce"`
+	MaxHour      float64 `yaml:"max_hour"`      // percent of the last 60 minutes, 0 = unlimited
+	MaxDay       float64 `yaml:"max_day"`       // percent of the last 24 hours, 0 = unlimited
+	PollInterval int     `yaml:"poll_interval"` // seconds between mode reads catching keying outside the API, 0 disables
+	StateFile    string  `yaml:"state_file"`    // TX periods of the last 24 hours, kept across restarts
+}
+
+// applyDutyCycleDefaults fills in defaults
+func applyDutyCycleDefaults(cfg *DutyCycleConfig) {
+	if cfg.StateFile == "" {
+		cfg.StateFile = DefaultDutyCycleStateFile
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = DefaultDutyCyclePollInterval
+	}
+}
+
+// validate checks the limits
+func (cfg DutyCycleConfig) validate() error {
+	if cfg.MaxHour < 0 || cfg.MaxHour > 100 || cfg.MaxDay < 0 || cfg.MaxDay > 100 {
+		return fmt.Errorf("hardware.duty_cycle: max_hour and max_day must be within 0-100 percent")
+	}
+	return nil
+}
+
+// txPeriod is a stretch of time the transmitter was keyed
+type txPeriod struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// txAccounting records when the transmitter was keyed
+// Periods older than a day are dropped; total counts since the state file was created.
+type txAccounting struct {
+	mu          sync.Mutex
+	periods     []txPeriod
+	keyedSince  time.Time // zero while unkeyed
+	total       time.Duration
+	exceeded    bool // a limit was exceeded at the last check, for the events
+	dirty       bool
+	lastSave    time.Time
+	poller      chan struct{}
+	pollerDone  chan struct{}
+	stateFile   string
+	stateLoaded bool
+}
+
+// txTimeState is the state file layout
+type txTimeState struct {
+	Periods []txPeriod `json:"periods"`
+	Total   float64    `json:"total"` // seconds
+}
+
+// observe records the keying state at a point in time
+func (a *txAccounting) observe(keyed bool, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch {
+	case keyed && a.keyedSince.IsZero():
+		a.keyedSince = now
+	case !keyed && !a.keyedSince.IsZero():
+		a.periods = append(a.periods, txPeriod{Start: a.keyedSince, End: now})
+		a.total += now.Sub(a.keyedSince)
+		a.keyedSince = time.Time{}
+		a.dirty = true
+		a.prune(now)
+	}
+}
+
+// prune drops periods that ended more than a day ago
+// Called with a.mu held.
+func (a *txAccounting) prune(now time.Time) {
+	cutoff := now.Add(-dutyCycleDay)
+	i := 0
+	for i < len(a.periods) && a.periods[i].End.Before(cutoff) {
+		i++
+	}
+	a.periods = a.periods[i:]
+}
+
+// airtime returns the time keyed within [from, to), including a running transmission
+// Called with a.mu held.
+func (a *txAccounting) airtime(from, to time.Time) time.Duration {
+	var sum time.Duration
+	add := func(start, end time.Time) {
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			sum += end.Sub(start)
+		}
+	}
+	for _, period := range a.periods {
+		add(period.Start, period.End)
+	}
+	if !a.keyedSince.IsZero() {
+		add(a.keyedSince, to)
+	}
+	return sum
+}
+
+// load reads the periods kept across a restart
+// A transmission running at shutdown was closed by save, so nothing is keyed.
+func (a *txAccounting) load(path string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.stateLoaded && a.stateFile == path {
+		return nil
+	}
+	a.stateFile, a.stateLoaded = path, true
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state txTimeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("invalid TX time state file: %w", err)
+	}
+	a.periods = state.Periods
+	a.total = time.Duration(state.Total * float64(time.Second))
+	a.prune(time.Now())
+	return nil
+}
+
+// save writes the periods to the state file, closing a running transmission at now
+// Nothing is written when nothing was transmitted since the last save.
+func (a *txAccounting) save(now time.Time) error {
+	a.mu.Lock()
+	if !a.dirty && a.keyedSince.IsZero() {
+		a.mu.Unlock()
+		return nil
+	}
+	state := txTimeState{Periods: append([]txPeriod{}, a.periods...), Total: a.total.Seconds()}
+	if !a.keyedSince.IsZero() {
+		state.Periods = append(state.Periods, txPeriod{Start: a.keyedSince, End: now})
+		state.Total += now.Sub(a.keyedSince).Seconds()
+	}
+	path := a.stateFile
+	a.dirty = false
+	a.lastSave = now
+	a.mu.Unlock()
+
+	if path == "" {
+		return nil
+	}
+	data, _ := json.MarshalIndent(state, "", "  ")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// DutyCycleWindow reports the TX time within an accounting window
+type DutyCycleWindow struct {
+	Seconds   float64  `json:"seconds"`
+	Percent   float64  `json:"percent"`
+	Limit     float64  `json:"limit,omitempty"`     // percent, 0 = unlimited
+	Available *float64 `json:"available,omitempty"` // seconds left before the limit is reached
+}
+
+// DutyCycleHour is the TX time of one clock hour
+type DutyCycleHour struct {
+	Start   time.Time `json:"start"`
+	Seconds float64   `json:"seconds"`
+}
+
+// DutyCycleStatus reports the TX time accounting
+type DutyCycleStatus struct {
+	Keyed      bool            `json:"keyed"`
+	KeyedSince *time.Time      `json:"keyed_since,omitempty"`
+	LastHour   DutyCycleWindow `json:"last_hour"`
+	LastDay    DutyCycleWindow `json:"last_day"`
+	Hours      []DutyCycleHour `json:"hours"` // clock hours of the last day, oldest first
+	Total      float64         `json:"total"` // seconds since accounting started
+	Enforced   bool            `json:"enforced"`
+	Exceeded   bool            `json:"exceeded"`
+}
+
+// window reports the TX time of the window ending at now against a limit in percent
+// Called with a.mu held.
+func (a *txAccounting) window(now time.Time, length time.Duration, limit float64) (DutyCycleWindow, bool) {
+	used := a.airtime(now.Add(-length), now)
+	w := DutyCycleWindow{
+		Seconds: used.Seconds(),
+		Percent: 100 * used.Seconds() / length.Seconds(),
+		Limit:   limit,
+	}
+	if limit <= 0 {
+		return w, false
+	}
+	available := limit/100*length.Seconds() - w.Seconds
+	if available < 0 {
+		available = 0
+	}
+	w.Available = &available
+	return w, available == 0
+}
+
+// status reports the accounting against the configured limits
+func (a *txAccounting) status(cfg DutyCycleConfig, now time.Time) DutyCycleStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	status := DutyCycleStatus{
+		Keyed:    !a.keyedSince.IsZero(),
+		Total:    a.total.Seconds(),
+		Enforced: cfg.Enforce,
+	}
+	if status.Keyed {
+		since := a.keyedSince
+		status.KeyedSince = &since
+		status.Total += now.Sub(since).Seconds()
+	}
+	var hourExceeded, dayExceeded bool
+	status.LastHour, hourExceeded = a.window(now, dutyCycleHour, cfg.MaxHour)
+	status.LastDay, dayExceeded = a.window(now, dutyCycleDay, cfg.MaxDay)
+	status.Exceeded = hourExceeded || dayExceeded
+
+	start := now.Truncate(time.Hour).Add(-23 * time.Hour)
+	for i := 0; i < 24; i++ {
+		from := start.Add(time.Duration(i) * time.Hour)
+		status.Hours = append(status.Hours, DutyCycleHour{
+			Start:   from,
+			Seconds: a.airtime(from, from.Add(time.Hour)).Seconds(),
+		})
+	}
+	return status
+}
+
+// check returns errDutyCycle when a limit is used up and the limits are enforced
+func (a *txAccounting) check(cfg DutyCycleConfig) error {
+	if !cfg.Enforce {
+		return nil
+	}
+	status := a.status(cfg, time.Now())
+	for _, w := range []struct {
+		name   string
+		window DutyCycleWindow
+	}{{"hour", status.LastHour}, {"24 hours", status.LastDay}} {
+		if w.window.Available != nil && *w.window.Available == 0 {
+			return fmt.Errorf("%w: transmitted %.0f s (%.1f%%) in the last %s, limit %.1f%%",
+				errDutyCycle, w.window.Seconds, w.window.Percent, w.name, w.window.Limit)
+		}
+	}
+	return nil
+}
+
+// updateExceeded records whether a limit is used up and reports a change
+func (a *txAccounting) updateExceeded(exceeded bool) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	changed := a.exceeded != exceeded
+	a.exceeded = exceeded
+	return changed
+}
+
+// checkDutyCycle refuses keying while a duty cycle limit is used up
+func (p *HardwarePlugin) checkDutyCycle() error {
+	return p.txTime.check(p.getConfig().DutyCycle)
+}
+
+// observeTx records the keying state after a mode change
+func (p *HardwarePlugin) observeTx(mode uint8) {
+	p.txTime.observe(modeEnablesTx(mode), time.Now())
+}
+
+// startDutyCycle loads the accounting state and starts polling the mode register
+func (p *HardwarePlugin) startDutyCycle(cfg HardwareConfig) {
+	if err := p.txTime.load(cfg.DutyCycle.StateFile); err != nil {
+		slog.Warn("Failed to load TX time accounting", "file", cfg.DutyCycle.StateFile, "error", err)
+	}
+	if cfg.DutyCycle.PollInterval <= 0 {
+		return
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	p.txTime.mu.Lock()
+	p.txTime.poller, p.txTime.pollerDone = stop, done
+	p.txTime.mu.Unlock()
+
+	interval := time.Duration(cfg.DutyCycle.PollInterval) * time.Second
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			p.pollDutyCycle()
+		}
+	}()
+}
+
+// pollDutyCycle reads the mode register, publishes limit changes and saves the state now and then
+// Read errors leave the keying state as it was.
+func (p *HardwarePlugin) pollDutyCycle() {
+	var mode uint8
+	err := p.withSPI(func(spi *SPIDevice) error {
+		var err error
+		mode, err = spi.ReadRegister(RegMode)
+		return err
+	})
+	now := time.Now()
+	if err == nil {
+		p.txTime.observe(modeEnablesTx(mode), now)
+	}
+
+	cfg := p.getConfig().DutyCycle
+	status := p.txTime.status(cfg, now)
+	if p.txTime.updateExceeded(status.Exceeded) {
+		data := fiber.Map{"last_hour": status.LastHour, "last_day": status.LastDay, "enforced": cfg.Enforce}
+		if status.Exceeded {
+			slog.Warn("TX duty cycle limit reached",
+				"last_hour_percent", status.LastHour.Percent,
+				"last_day_percent", status.LastDay.Percent,
+				"enforced", cfg.Enforce)
+			PublishEvent(dutyCycleExceededEvent, hardwareMonitorEventSource, data)
+		} else {
+			slog.Info("TX duty cycle available again")
+			PublishEvent(dutyCycleRestoredEvent, hardwareMonitorEventSource, data)
+		}
+	}
+
+	p.txTime.mu.Lock()
+	save := p.txTime.dirty && now.Sub(p.txTime.lastSave) >= dutyCycleSaveInterval
+	p.txTime.mu.Unlock()
+	if save {
+		if err := p.txTime.save(now); err != nil {
+			slog.Warn("Failed to save TX time accounting", "error", err)
+		}
+	}
+}
+
+// stopDutyCycle stops polling and saves the accounting state
+func (p *HardwarePlugin) stopDutyCycle() {
+	p.txTime.mu.Lock()
+	stop, done := p.txTime.poller, p.txTime.pollerDone
+	p.txTime.poller, p.txTime.pollerDone = nil, nil
+	p.txTime.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	if err := p.txTime.save(time.Now()); err != nil {
+		slog.Warn("Failed to save TX time accounting", "error", err)
+	}
+}
+
+// handleGetDutyCycle handles GET /api/hardware/duty-cycle
+func (p *HardwarePlugin) handleGetDutyCycle(c *fiber.Ctx) error {
+	return SendSuccess(c, p.txTime.status(p.getConfig().DutyCycle, time.Now()), "")
+}
@@ -15,6 +15,9 @@ type GPIOController struct {
 	chipPath  string
 	resetPin  int
 	txRxPin   int
+
+	sim    *registerSim    // validation: outputs are simulated
+	shadow *registerShadow // remembers the output levels set
 }
 
 // NewGPIOController creates a new GPIO controller
@@ -98,6 +101,10 @@ func (g *GPIOController) Close() error {
 // - Release to LOW
 // - Wait 5ms before further operations
 func (g *GPIOController) Reset() error {
+	if g.sim != nil {
+		g.sim.reset()
+		return nil
+	}
 	if g.resetLine == nil {
 		return fmt.Errorf("reset line not initialized")
 	}
@@ -118,11 +125,20 @@ func (g *GPIOController) Reset() error {
 	// Wait 5ms for chip to be ready (per datasheet)
 	time.Sleep(5 * time.Millisecond)
 
+	if g.shadow != nil {
+		g.shadow.setPin(pinReset, false)
+		g.shadow.forget()
+	}
+
 	return nil
 }
 
 // SetResetPin manually controls the reset pin state
 func (g *GPIOController) SetResetPin(high bool) error {
+	if g.sim != nil {
+		g.sim.setPin(pinReset, high)
+		return nil
+	}
 	if g.resetLine == nil {
 		return fmt.Errorf("reset line not initialized")
 	}
@@ -135,12 +151,18 @@ func (g *GPIOController) SetResetPin(high bool) error {
 	if err := g.resetLine.SetValue(value); err != nil {
 		return fmt.Errorf("failed to set reset pin to %v: %w", high, err)
 	}
+	if g.shadow != nil {
+		g.shadow.setPin(pinReset, high)
+	}
 
 	return nil
 }
 
 // GetResetPin reads the current state of the reset pin
 func (g *GPIOController) GetResetPin() (bool, error) {
+	if g.sim != nil {
+		return g.sim.pin(pinReset), nil
+	}
 	if g.resetLine == nil {
 		return false, fmt.Errorf("reset line not initialized")
 	}
@@ -156,6 +178,10 @@ func (g *GPIOController) GetResetPin() (bool, error) {
 // SetTxRxPin controls the TX/RX switch pin
 // true = TX mode, false = RX mode
 func (g *GPIOController) SetTxRxPin(tx bool) error {
+	if g.sim != nil {
+		g.sim.setPin(pinTxRx, tx)
+		return nil
+	}
 	if g.txRxLine == nil {
 		return fmt.Errorf("TX/RX line not initialized")
 	}
@@ -168,12 +194,18 @@ func (g *GPIOController) SetTxRxPin(tx bool) error {
 	if err := g.txRxLine.SetValue(value); err != nil {
 		return fmt.Errorf("failed to set TX/RX pin to %v: %w", tx, err)
 	}
+	if g.shadow != nil {
+		g.shadow.setPin(pinTxRx, tx)
+	}
 
 	return nil
 }
 
 // GetTxRxPin reads the current state of the TX/RX switch pin
 func (g *GPIOController) GetTxRxPin() (bool, error) {
+	if g.sim != nil {
+		return g.sim.pin(pinTxRx), nil
+	}
 	if g.txRxLine == nil {
 		return false, fmt.Errorf("TX/RX line not initialized")
 	}
@@ -0,0 +1,380 @@
+package plugins
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Hardware alarm conditions
+const (
+	AlarmPLLUnlockRx  = "pll_unlock_rx"
+	AlarmPLLUnlockTx  = "pll_unlock_tx"
+	AlarmXoscNotReady = "xosc_not_ready"
+	AlarmEOL          = "eol"
+	AlarmReadError    = "read_error"
+)
+
+// Hardware alarm severities
+const (
+	AlarmSeverityWarning  = "warning"
+	AlarmSeverityCritical = "critical"
+)
+
+// Hardware monitor defaults
+const (
+	DefaultMonitorInterval     = 5 // seconds
+	DefaultAlarmHistory        = 100
+	hardwareAlarmRaisedEvent   = "hardware.alarm.raised"
+	hardwareAlarmClearedEvent  = "hardware.alarm.cleared"
+	hardwareMonitorEventSource = "hardware"
+)
+
+// HardwareAlarm represents a raised (and possibly cleared) hardware alarm
+type HardwareAlarm struct {
+	ID        uint64     `json:"id"`
+	Condition string     `json:"condition"`
+	Severity  string     `json:"severity"`
+	Message   string     `json:"message"`
+	Active    bool       `json:"active"`
+	RaisedAt  time.Time  `json:"raised_at"`
+	ClearedAt *time.Time `json:"cleared_at,omitempty"`
+}
+
+// alarmCondition describes a condition evaluated on each monitor poll
+type alarmCondition struct {
+	name     string
+	severity string
+	message  string
+}
+
+// HardwareStatus is a register-level snapshot of the transceiver used for telemetry
+type HardwareStatus struct {
+	Mode        string          `json:"mode"`
+	ModeValue   uint8           `json:"mode_value"`
+	RxPLLLocked bool            `json:"rx_pll_locked"`
+	TxPLLLocked bool            `json:"tx_pll_locked"`
+	XoscReady   bool            `json:"xosc_ready"`
+	EOL         bool            `json:"eol"`
+	Maintenance bool            `json:"maintenance"`
+	Alarms      []HardwareAlarm `json:"alarms"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// hardwarePlugin is the running instance used by CurrentHardwareStatus
+var (
+	hardwarePlugin   *HardwarePlugin
+	hardwarePluginMu sync.RWMutex
+)
+
+// CurrentHardwareStatus reads the mode and status registers for use by other plugins (e.g. telemetry)
+// ok is false when the hardware plugin is not loaded; read failures are reported in Error
+func CurrentHardwareStatus() (status HardwareStatus, ok bool) {
+	hardwarePluginMu.RLock()
+	p := hardwarePlugin
+	hardwarePluginMu.RUnlock()
+	if p == nil {
+		return HardwareStatus{}, false
+	}
+
+	var mode, stat uint8
+	err := p.withSPI(func(spi *SPIDevice) error {
+		var err error
+		if mode, err = spi.ReadRegister(RegMode); err != nil {
+			return err
+		}
+		stat, err = spi.ReadRegister(RegStat)
+		return err
+	})
+
+	status = HardwareStatus{
+		Maintenance: MaintenanceMode(),
+		Alarms:      []HardwareAlarm{},
+	}
+	if monitor := p.getMonitor(); monitor != nil {
+		status.Alarms, _, _ = monitor.Snapshot()
+	}
+	if err != nil {
+		status.Error = err.Error()
+		return status, true
+	}
+
+	status.Mode = modeName(mode)
+	status.ModeValue = mode
+	status.RxPLLLocked = stat&StatPllLockRx != 0
+	status.TxPLLLocked = stat&StatPllLockTx != 0
+	status.XoscReady = stat&StatXoscReady != 0
+	status.EOL = stat&StatEol != 0
+	return status, true
+}
+
+// RecentHardwareAlarms returns up to limit alarms of the history, newest first, for use by other plugins
+// ok is false when the hardware plugin or its monitor is not running
+func RecentHardwareAlarms(limit int) (alarms []HardwareAlarm, ok bool) {
+	hardwarePluginMu.RLock()
+	p := hardwarePlugin
+	hardwarePluginMu.RUnlock()
+	if p == nil {
+		return nil, false
+	}
+	monitor := p.getMonitor()
+	if monitor == nil {
+		return nil, false
+	}
+
+	_, history, _ := monitor.Snapshot()
+	if len(history) > limit {
+		history = history[:limit]
+	}
+	return history, true
+}
+
+// HardwareMonitor polls RegStat in the background and tracks alarms
+type HardwareMonitor struct {
+	plugin   *HardwarePlugin
+	interval time.Duration
+	stopChan chan struct{}
+	doneChan chan struct{}
+
+	mu         sync.Mutex
+	active     map[string]*HardwareAlarm
+	history    []HardwareAlarm
+	maxHistory int
+	nextID     uint64
+	pllLocked  map[string]bool
+	lastPoll   time.Time
+}
+
+// newHardwareMonitor creates a monitor for the given plugin
+func newHardwareMonitor(plugin *HardwarePlugin, interval time.Duration, maxHistory int) *HardwareMonitor {
+	if maxHistory <= 0 {
+		maxHistory = DefaultAlarmHistory
+	}
+	return &HardwareMonitor{
+		plugin:     plugin,
+		interval:   interval,
+		stopChan:   make(chan struct{}),
+		doneChan:   make(chan struct{}),
+		active:     make(map[string]*HardwareAlarm),
+		maxHistory: maxHistory,
+		pllLocked:  make(map[string]bool),
+	}
+}
+
+// Start runs the polling loop in a goroutine
+func (m *HardwareMonitor) Start() {
+	slog.Info("Hardware monitor started", "interval", m.interval)
+
+	go func() {
+		defer close(m.doneChan)
+
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.stopChan:
+				return
+			case <-ticker.C:
+				m.poll()
+			}
+		}
+	}()
+}
+
+// Stop terminates the polling loop and waits for it to exit
+func (m *HardwareMonitor) Stop() {
+	close(m.stopChan)
+	<-m.doneChan
+	slog.Info("Hardware monitor stopped")
+}
+
+// poll reads the mode and status registers and updates alarm state
+// Only the SPI bus is opened so GPIO lines (reset, TX/RX switch) are left untouched
+func (m *HardwareMonitor) poll() {
+	var mode, stat uint8
+	err := m.plugin.withSPI(func(spi *SPIDevice) error {
+		var err error
+		if mode, err = spi.ReadRegister(RegMode); err != nil {
+			return err
+		}
+		stat, err = spi.ReadRegister(RegStat)
+		return err
+	})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastPoll = time.Now()
+
+	if err != nil {
+		slog.Debug("Hardware monitor poll failed", "error", err)
+		m.setCondition(alarmCondition{AlarmReadError, AlarmSeverityWarning, fmt.Sprintf("Failed to read status register: %v", err)}, true)
+		return
+	}
+	m.setCondition(alarmCondition{name: AlarmReadError}, false)
+
+	// PLL alarms fire only on loss of a previously acquired lock while the path is enabled
+	m.checkPLL(AlarmPLLUnlockRx, "RX PLL lock lost", mode&ModeBitRxEnable != 0, stat&StatPllLockRx != 0)
+	m.checkPLL(AlarmPLLUnlockTx, "TX PLL lock lost", mode&ModeBitTxEnable != 0, stat&StatPllLockTx != 0)
+
+	// XOSC is only expected to be ready when the reference is enabled
+	xoscFault := mode&ModeBitRefEnable != 0 && stat&StatXoscReady == 0
+	m.setCondition(alarmCondition{AlarmXoscNotReady, AlarmSeverityCritical, "Crystal oscillator not ready"}, xoscFault)
+
+	m.setCondition(alarmCondition{AlarmEOL, AlarmSeverityWarning, "End of life bit set (supply voltage low)"}, stat&StatEol != 0)
+}
+
+// checkPLL tracks PLL lock transitions for one path
+// Caller must hold m.mu
+func (m *HardwareMonitor) checkPLL(name string, message string, enabled bool, locked bool) {
+	if !enabled {
+		// Path disabled - forget lock state and clear any alarm
+		delete(m.pllLocked, name)
+		m.setCondition(alarmCondition{name: name}, false)
+		return
+	}
+
+	if locked {
+		m.pllLocked[name] = true
+		m.setCondition(alarmCondition{name: name}, false)
+		return
+	}
+
+	if m.pllLocked[name] {
+		m.setCondition(alarmCondition{name, AlarmSeverityCritical, message}, true)
+	}
+}
+
+// setCondition raises or clears an alarm on state transitions
+// Caller must hold m.mu
+func (m *HardwareMonitor) setCondition(cond alarmCondition, active bool) {
+	alarm, isActive := m.active[cond.name]
+
+	switch {
+	case active && !isActive:
+		m.nextID++
+		alarm = &HardwareAlarm{
+			ID:        m.nextID,
+			Condition: cond.name,
+			Severity:  cond.severity,
+			Message:   cond.message,
+			Active:    true,
+			RaisedAt:  time.Now(),
+		}
+		m.active[cond.name] = alarm
+		m.appendHistory(*alarm)
+
+		slog.Warn("Hardware alarm raised", "condition", cond.name, "message", cond.message)
+		PublishEvent(hardwareAlarmRaisedEvent, hardwareMonitorEventSource, *alarm)
+
+	case !active && isActive:
+		now := time.Now()
+		alarm.Active = false
+		alarm.ClearedAt = &now
+		delete(m.active, cond.name)
+		m.updateHistory(*alarm)
+
+		if cond.name == AlarmPLLUnlockRx || cond.name == AlarmPLLUnlockTx {
+			delete(m.pllLocked, cond.name)
+		}
+
+		slog.Info("Hardware alarm cleared", "condition", cond.name)
+		PublishEvent(hardwareAlarmClearedEvent, hardwareMonitorEventSource, *alarm)
+	}
+}
+
+// appendHistory adds an alarm to the bounded history
+// Caller must hold m.mu
+func (m *HardwareMonitor) appendHistory(alarm HardwareAlarm) {
+	m.history = append(m.history, alarm)
+	if len(m.history) > m.maxHistory {
+		m.history = m.history[len(m.history)-m.maxHistory:]
+	}
+}
+
+// updateHistory replaces the history entry of a cleared alarm
+// Caller must hold m.mu
+func (m *HardwareMonitor) updateHistory(alarm HardwareAlarm) {
+	for i := len(m.history) - 1; i >= 0; i-- {
+		if m.history[i].ID == alarm.ID {
+			m.history[i] = alarm
+			return
+		}
+	}
+}
+
+// Snapshot returns the active alarms and history, newest first
+func (m *HardwareMonitor) Snapshot() (active []HardwareAlarm, history []HardwareAlarm, lastPoll time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	active = make([]HardwareAlarm, 0, len(m.active))
+	for _, alarm := range m.active {
+		active = append(active, *alarm)
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].ID > active[j].ID
+	})
+
+	history = make([]HardwareAlarm, 0, len(m.history))
+	for i := len(m.history) - 1; i >= 0; i-- {
+		history = append(history, m.history[i])
+	}
+	return active, history, m.lastPoll
+}
+
+// ClearHistory removes cleared alarms from the history
+func (m *HardwareMonitor) ClearHistory() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.history[:0]
+	for _, alarm := range m.history {
+		if alarm.Active {
+			kept = append(kept, alarm)
+		}
+	}
+	m.history = kept
+}
+
+// handleGetAlarms handles GET /api/hardware/alarms
+func (p *HardwarePlugin) handleGetAlarms(c *fiber.Ctx) error {
+	monitor := p.getMonitor()
+	if monitor == nil {
+		return SendSuccess(c, fiber.Map{
+			"enabled": false,
+			"active":  []HardwareAlarm{},
+			"history": []HardwareAlarm{},
+		}, "")
+	}
+
+	active, history, lastPoll := monitor.Snapshot()
+	result := fiber.Map{
+		"enabled":  true,
+		"interval": monitor.interval.String(),
+		"active":   active,
+		"history":  history,
+	}
+	if !lastPoll.IsZero() {
+		result["last_poll"] = lastPoll
+	}
+	return SendSuccess(c, result, "")
+}
+
+// handleClearAlarms handles DELETE /api/hardware/alarms
+// Removes cleared alarms from the history; active alarms are kept
+func (p *HardwarePlugin) handleClearAlarms(c *fiber.Ctx) error {
+	monitor := p.getMonitor()
+	if monitor == nil {
+		return SendErrorMessage(c, 404, "Hardware monitor is disabled")
+	}
+
+	monitor.ClearHistory()
+	slog.InfoContext(c.UserContext(), "Hardware alarm history cleared")
+	return SendSuccess(c, nil, "Alarm history cleared")
+}
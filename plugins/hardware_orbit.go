@@ -0,0 +1,592 @@
+package plugins
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SGP4 constants (WGS-72, as used to generate TLEs)
+const (
+	sgp4EarthRadius = 6378.135 // km
+	sgp4Mu          = 398600.8 // km³/s²
+	sgp4J2          = 0.001082616
+	sgp4J3          = -0.00000253881
+	sgp4J4          = -0.00000165597
+	sgp4DeepSpace   = 225.0       // minutes; longer periods need the deep-space model
+	earthRotation   = 7.292115e-5 // rad/s
+	speedOfLight    = 299792.458  // km/s
+	wgs84Radius     = 6378.137    // km
+	wgs84Flattening = 1 / 298.257223563
+)
+
+// sgp4XKE is sqrt(mu) in Earth radii^1.5 per minute
+var sgp4XKE = 60.0 / math.Sqrt(sgp4EarthRadius*sgp4EarthRadius*sgp4EarthRadius/sgp4Mu)
+
+// errDecayed is returned when the propagated orbit is no longer physical
+var errDecayed = errors.New("satellite orbit has decayed")
+
+// TLE is a parsed two-line element set
+type TLE struct {
+	Name        string    `json:"name"`
+	Catalog     int       `json:"catalog"`
+	Epoch       time.Time `json:"epoch"`
+	Inclination float64   `json:"inclination"`     // degrees
+	MeanMotion  float64   `json:"mean_motion"`     // revolutions per day
+	Period      float64   `json:"period"`          // minutes
+	Line1       string    `json:"line1,omitempty"` // as given
+	Line2       string    `json:"line2,omitempty"`
+
+	bstar, ecco, argpo, inclo, mo, no, nodeo float64
+}
+
+// tleChecksum verifies the modulo 10 checksum in the last column of a TLE line
+func tleChecksum(line string) bool {
+	sum := 0
+	for _, r := range line[:68] {
+		switch {
+		case r >= '0' && r <= '9':
+			sum += int(r - '0')
+		case r == '-':
+			sum++
+		}
+	}
+	return line[68] == byte('0'+sum%10)
+}
+
+// tleField parses columns from-to (1-based, inclusive) of a TLE line as a float
+func tleField(line string, from, to int) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(line[from-1:to]), 64)
+}
+
+// tleExponent parses a TLE field with an implied leading decimal point and exponent ("-11606-4")
+func tleExponent(field string) (float64, error) {
+	field = strings.TrimSpace(field)
+	if field == "" {
+		return 0, nil
+	}
+	sign := 1.0
+	if field[0] == '-' || field[0] == '+' {
+		if field[0] == '-' {
+			sign = -1
+		}
+		field = field[1:]
+	}
+	split := strings.LastIndexAny(field, "+-")
+	if split <= 0 {
+		return 0, fmt.Errorf("invalid exponent field %q", field)
+	}
+	mantissa, err := strconv.ParseFloat("0."+strings.TrimSpace(field[:split]), 64)
+	if err != nil {
+		return 0, err
+	}
+	exponent, err := strconv.Atoi(field[split:])
+	if err != nil {
+		return 0, err
+	}
+	return sign * mantissa * math.Pow(10, float64(exponent)), nil
+}
+
+// ParseTLE parses an element set; name may be empty to use the catalog number
+func ParseTLE(name, line1, line2 string) (TLE, error) {
+	line1 = strings.TrimRight(line1, " \r")
+	line2 = strings.TrimRight(line2, " \r")
+	if len(line1) != 69 || len(line2) != 69 || line1[0] != '1' || line2[0] != '2' {
+		return TLE{}, fmt.Errorf("TLE lines must be 69 characters starting with 1 and 2")
+	}
+	if !tleChecksum(line1) || !tleChecksum(line2) {
+		return TLE{}, fmt.Errorf("TLE checksum mismatch")
+	}
+
+	tle := TLE{Name: strings.TrimSpace(strings.TrimPrefix(name, "0 ")), Line1: line1, Line2: line2}
+	var err error
+	fail := func(field string) (TLE, error) {
+		return TLE{}, fmt.Errorf("invalid TLE %s: %v", field, err)
+	}
+	if tle.Catalog, err = strconv.Atoi(strings.TrimSpace(line1[2:7])); err != nil {
+		return fail("catalog number")
+	}
+	if tle.Name == "" {
+		tle.Name = strconv.Itoa(tle.Catalog)
+	}
+
+	year, err := strconv.Atoi(line1[18:20])
+	if err != nil {
+		return fail("epoch year")
+	}
+	if year < 57 {
+		year += 2000
+	} else {
+		year += 1900
+	}
+	day, err := tleField(line1, 21, 32)
+	if err != nil {
+		return fail("epoch day")
+	}
+	tle.Epoch = time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration((day - 1) * 24 * float64(time.Hour)))
+	if tle.bstar, err = tleExponent(line1[53:61]); err != nil {
+		return fail("drag term")
+	}
+
+	if tle.Inclination, err = tleField(line2, 9, 16); err != nil {
+		return fail("inclination")
+	}
+	if tle.nodeo, err = tleField(line2, 18, 25); err != nil {
+		return fail("right ascension")
+	}
+	if tle.ecco, err = strconv.ParseFloat("0."+strings.TrimSpace(line2[26:33]), 64); err != nil {
+		return fail("eccentricity")
+	}
+	if tle.argpo, err = tleField(line2, 35, 42); err != nil {
+		return fail("argument of perigee")
+	}
+	if tle.mo, err = tleField(line2, 44, 51); err != nil {
+		return fail("mean anomaly")
+	}
+	if tle.MeanMotion, err = tleField(line2, 53, 63); err != nil || tle.MeanMotion <= 0 {
+		return fail("mean motion")
+	}
+
+	const deg = math.Pi / 180
+	tle.inclo = tle.Inclination * deg
+	tle.nodeo *= deg
+	tle.argpo *= deg
+	tle.mo *= deg
+	tle.no = tle.MeanMotion * 2 * math.Pi / 1440 // rad/min
+	tle.Period = 1440 / tle.MeanMotion
+	return tle, nil
+}
+
+// ParseTLEs parses element sets in two- or three-line format
+func ParseTLEs(text string) ([]TLE, error) {
+	var tles []TLE
+	var name, line1 string
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimRight(scanner.Text(), " \r\t")
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "1 ") && line1 == "":
+			line1 = line
+		case strings.HasPrefix(line, "2 ") && line1 != "":
+			tle, err := ParseTLE(name, line1, line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			tles = append(tles, tle)
+			name, line1 = "", ""
+		case line1 != "":
+			return nil, fmt.Errorf("line %d: expected TLE line 2", n)
+		default:
+			name = strings.TrimSpace(line)
+		}
+	}
+	if line1 != "" {
+		return nil, fmt.Errorf("line %d: TLE line 2 is missing", n)
+	}
+	return tles, scanner.Err()
+}
+
+// sgp4 holds the near-Earth SGP4 model initialized from a TLE
+// Follows Vallado et al., "Revisiting Spacetrack Report #3" (2006).
+type sgp4 struct {
+	tle                                        TLE
+	isimp                                      bool
+	aycof, con41, cc1, cc4, cc5, d2, d3, d4    float64
+	delmo, eta, argpdot, omgcof, sinmao, t2cof float64
+	t3cof, t4cof, t5cof, x1mth2, x7thm1, mdot  float64
+	nodedot, xlcof, xmcof, nodecf, noUnkozai   float64
+}
+
+// newSGP4 initializes the model; deep-space orbits are refused
+func newSGP4(tle TLE) (*sgp4, error) {
+	s := &sgp4{tle: tle}
+	const x2o3 = 2.0 / 3.0
+	j3oj2 := sgp4J3 / sgp4J2
+	ss := 78/sgp4EarthRadius + 1
+	qzms2t := math.Pow((120-78)/sgp4EarthRadius, 4)
+
+	ecco, inclo := tle.ecco, tle.inclo
+	eccsq := ecco * ecco
+	omeosq := 1 - eccsq
+	rteosq := math.Sqrt(omeosq)
+	cosio := math.Cos(inclo)
+	cosio2 := cosio * cosio
+
+	// Recover the original mean motion and semi-major axis from the Kozai mean motion
+	ak := math.Pow(sgp4XKE/tle.no, x2o3)
+	d1 := 0.75 * sgp4J2 * (3*cosio2 - 1) / (rteosq * omeosq)
+	del := d1 / (ak * ak)
+	adel := ak * (1 - del*del - del*(1.0/3.0+134*del*del/81))
+	del = d1 / (adel * adel)
+	s.noUnkozai = tle.no / (1 + del)
+	if 2*math.Pi/s.noUnkozai >= sgp4DeepSpace {
+		return nil, fmt.Errorf("%s: orbital period of %.0f minutes needs the deep-space model, which is not supported", tle.Name, 2*math.Pi/s.noUnkozai)
+	}
+
+	ao := math.Pow(sgp4XKE/s.noUnkozai, x2o3)
+	sinio := math.Sin(inclo)
+	po := ao * omeosq
+	con42 := 1 - 5*cosio2
+	s.con41 = -con42 - cosio2 - cosio2
+	posq := po * po
+	rp := ao * (1 - ecco)
+
+	s.isimp = rp < 220/sgp4EarthRadius+1
+	sfour := ss
+	qzms24 := qzms2t
+	if perige := (rp - 1) * sgp4EarthRadius; perige < 156 {
+		sfour = perige - 78
+		if perige < 98 {
+			sfour = 20
+		}
+		qzms24 = math.Pow((120-sfour)/sgp4EarthRadius, 4)
+		sfour = sfour/sgp4EarthRadius + 1
+	}
+	pinvsq := 1 / posq
+
+	tsi := 1 / (ao - sfour)
+	s.eta = ao * ecco * tsi
+	etasq := s.eta * s.eta
+	eeta := ecco * s.eta
+	psisq := math.Abs(1 - etasq)
+	coef := qzms24 * math.Pow(tsi, 4)
+	coef1 := coef / math.Pow(psisq, 3.5)
+	cc2 := coef1 * s.noUnkozai * (ao*(1+1.5*etasq+eeta*(4+etasq)) +
+		0.375*sgp4J2*tsi/psisq*s.con41*(8+3*etasq*(8+etasq)))
+	s.cc1 = tle.bstar * cc2
+	cc3 := 0.0
+	if ecco > 1e-4 {
+		cc3 = -2 * coef * tsi * j3oj2 * s.noUnkozai * sinio / ecco
+	}
+	s.x1mth2 = 1 - cosio2
+	s.cc4 = 2 * s.noUnkozai * coef1 * ao * omeosq *
+		(s.eta*(2+0.5*etasq) + ecco*(0.5+2*etasq) -
+			sgp4J2*tsi/(ao*psisq)*(-3*s.con41*(1-2*eeta+etasq*(1.5-0.5*eeta))+
+				0.75*s.x1mth2*(2*etasq-eeta*(1+etasq))*math.Cos(2*tle.argpo)))
+	s.cc5 = 2 * coef1 * ao * omeosq * (1 + 2.75*(etasq+eeta) + eeta*etasq)
+
+	cosio4 := cosio2 * cosio2
+	temp1 := 1.5 * sgp4J2 * pinvsq * s.noUnkozai
+	temp2 := 0.5 * temp1 * sgp4J2 * pinvsq
+	temp3 := -0.46875 * sgp4J4 * pinvsq * pinvsq * s.noUnkozai
+	s.mdot = s.noUnkozai + 0.5*temp1*rteosq*s.con41 + 0.0625*temp2*rteosq*(13-78*cosio2+137*cosio4)
+	s.argpdot = -0.5*temp1*con42 + 0.0625*temp2*(7-114*cosio2+395*cosio4) + temp3*(3-36*cosio2+49*cosio4)
+	xhdot1 := -temp1 * cosio
+	s.nodedot = xhdot1 + (0.5*temp2*(4-19*cosio2)+2*temp3*(3-7*cosio2))*cosio
+	s.omgcof = tle.bstar * cc3 * math.Cos(tle.argpo)
+	if ecco > 1e-4 {
+		s.xmcof = -x2o3 * coef * tle.bstar / eeta
+	}
+	s.nodecf = 3.5 * omeosq * xhdot1 * s.cc1
+	s.t2cof = 1.5 * s.cc1
+	if math.Abs(cosio+1) > 1.5e-12 {
+		s.xlcof = -0.25 * j3oj2 * sinio * (3 + 5*cosio) / (1 + cosio)
+	} else {
+		s.xlcof = -0.25 * j3oj2 * sinio * (3 + 5*cosio) / 1.5e-12
+	}
+	s.aycof = -0.5 * j3oj2 * sinio
+	s.delmo = math.Pow(1+s.eta*math.Cos(tle.mo), 3)
+	s.sinmao = math.Sin(tle.mo)
+	s.x7thm1 = 7*cosio2 - 1
+
+	if !s.isimp {
+		cc1sq := s.cc1 * s.cc1
+		s.d2 = 4 * ao * tsi * cc1sq
+		temp := s.d2 * tsi * s.cc1 / 3
+		s.d3 = (17*ao + sfour) * temp
+		s.d4 = 0.5 * temp * ao * tsi * (221*ao + 31*sfour) * s.cc1
+		s.t3cof = s.d2 + 2*cc1sq
+		s.t4cof = 0.25 * (3*s.d3 + s.cc1*(12*s.d2+10*cc1sq))
+		s.t5cof = 0.2 * (3*s.d4 + 12*s.cc1*s.d3 + 6*s.d2*s.d2 + 15*cc1sq*(2*s.d2+cc1sq))
+	}
+	return s, nil
+}
+
+// propagate returns the TEME position (km) and velocity (km/s) at t
+func (s *sgp4) propagate(t time.Time) (r, v [3]float64, err error) {
+	const x2o3 = 2.0 / 3.0
+	const twoPi = 2 * math.Pi
+	tle := s.tle
+	tsince := t.Sub(tle.Epoch).Minutes()
+
+	// Secular gravity and atmospheric drag
+	xmdf := tle.mo + s.mdot*tsince
+	argpdf := tle.argpo + s.argpdot*tsince
+	nodedf := tle.nodeo + s.nodedot*tsince
+	argpm := argpdf
+	mm := xmdf
+	t2 := tsince * tsince
+	nodem := nodedf + s.nodecf*t2
+	tempa := 1 - s.cc1*tsince
+	tempe := tle.bstar * s.cc4 * tsince
+	templ := s.t2cof * t2
+	if !s.isimp {
+		delomg := s.omgcof * tsince
+		delm := s.xmcof * (math.Pow(1+s.eta*math.Cos(xmdf), 3) - s.delmo)
+		temp := delomg + delm
+		mm = xmdf + temp
+		argpm = argpdf - temp
+		t3 := t2 * tsince
+		t4 := t3 * tsince
+		tempa = tempa - s.d2*t2 - s.d3*t3 - s.d4*t4
+		tempe += tle.bstar * s.cc5 * (math.Sin(mm) - s.sinmao)
+		templ += s.t3cof*t3 + t4*(s.t4cof+tsince*s.t5cof)
+	}
+
+	am := math.Pow(sgp4XKE/s.noUnkozai, x2o3) * tempa * tempa
+	nm := sgp4XKE / math.Pow(am, 1.5)
+	em := tle.ecco - tempe
+	if em >= 1 || em < -0.001 || am < 0.95 {
+		return r, v, errDecayed
+	}
+	if em < 1e-6 {
+		em = 1e-6
+	}
+	mm += s.noUnkozai * templ
+	xlm := mm + argpm + nodem
+	nodem = math.Mod(nodem, twoPi)
+	argpm = math.Mod(argpm, twoPi)
+	xlm = math.Mod(xlm, twoPi)
+	mm = math.Mod(xlm-argpm-nodem, twoPi)
+	sinim, cosim := math.Sin(tle.inclo), math.Cos(tle.inclo)
+
+	// Long-period periodics
+	axnl := em * math.Cos(argpm)
+	temp := 1 / (am * (1 - em*em))
+	aynl := em*math.Sin(argpm) + temp*s.aycof
+	xl := mm + argpm + nodem + temp*s.xlcof*axnl
+
+	// Kepler's equation
+	u := math.Mod(xl-nodem, twoPi)
+	eo1 := u
+	var sineo1, coseo1 float64
+	for i, tem5 := 0, 1.0; math.Abs(tem5) >= 1e-12 && i < 10; i++ {
+		sineo1, coseo1 = math.Sin(eo1), math.Cos(eo1)
+		tem5 = 1 - coseo1*axnl - sineo1*aynl
+		tem5 = (u - aynl*coseo1 + axnl*sineo1 - eo1) / tem5
+		tem5 = math.Max(-0.95, math.Min(0.95, tem5))
+		eo1 += tem5
+	}
+
+	// Short-period periodics
+	ecose := axnl*coseo1 + aynl*sineo1
+	esine := axnl*sineo1 - aynl*coseo1
+	el2 := axnl*axnl + aynl*aynl
+	pl := am * (1 - el2)
+	if pl < 0 {
+		return r, v, errDecayed
+	}
+	rl := am * (1 - ecose)
+	rdotl := math.Sqrt(am) * esine / rl
+	rvdotl := math.Sqrt(pl) / rl
+	betal := math.Sqrt(1 - el2)
+	temp = esine / (1 + betal)
+	sinu := am / rl * (sineo1 - aynl - axnl*temp)
+	cosu := am / rl * (coseo1 - axnl + aynl*temp)
+	su := math.Atan2(sinu, cosu)
+	sin2u := (cosu + cosu) * sinu
+	cos2u := 1 - 2*sinu*sinu
+	temp = 1 / pl
+	temp1 := 0.5 * sgp4J2 * temp
+	temp2 := temp1 * temp
+
+	mrt := rl*(1-1.5*temp2*betal*s.con41) + 0.5*temp1*s.x1mth2*cos2u
+	if mrt < 1 {
+		return r, v, errDecayed
+	}
+	su -= 0.25 * temp2 * s.x7thm1 * sin2u
+	xnode := nodem + 1.5*temp2*cosim*sin2u
+	xinc := tle.inclo + 1.5*temp2*cosim*sinim*cos2u
+	mvt := rdotl - nm*temp1*s.x1mth2*sin2u/sgp4XKE
+	rvdot := rvdotl + nm*temp1*(s.x1mth2*cos2u+1.5*s.con41)/sgp4XKE
+
+	sinsu, cossu := math.Sin(su), math.Cos(su)
+	snod, cnod := math.Sin(xnode), math.Cos(xnode)
+	sini, cosi := math.Sin(xinc), math.Cos(xinc)
+	xmx := -snod * cosi
+	xmy := cnod * cosi
+	ux := [3]float64{xmx*sinsu + cnod*cossu, xmy*sinsu + snod*cossu, sini * sinsu}
+	vx := [3]float64{xmx*cossu - cnod*sinsu, xmy*cossu - snod*sinsu, sini * cossu}
+	vkmpersec := sgp4EarthRadius * sgp4XKE / 60
+	for i := range r {
+		r[i] = mrt * ux[i] * sgp4EarthRadius
+		v[i] = (mvt*ux[i] + rvdot*vx[i]) * vkmpersec
+	}
+	return r, v, nil
+}
+
+// gmst returns the Greenwich mean sidereal time in radians
+func gmst(t time.Time) float64 {
+	jd := float64(t.UnixNano())/86400e9 + 2440587.5
+	tut1 := (jd - 2451545) / 36525
+	seconds := -6.2e-6*tut1*tut1*tut1 + 0.093104*tut1*tut1 + (876600*3600+8640184.812866)*tut1 + 67310.54841
+	angle := math.Mod(seconds*math.Pi/180/240, 2*math.Pi)
+	if angle < 0 {
+		angle += 2 * math.Pi
+	}
+	return angle
+}
+
+// Observer is a ground station position
+type Observer struct {
+	Latitude  float64 `json:"latitude"`  // degrees
+	Longitude float64 `json:"longitude"` // degrees
+	Altitude  float64 `json:"altitude"`  // meters
+	Source    string  `json:"source"`    // config or gnss
+}
+
+// ecef returns the observer's Earth-fixed position in km (WGS-84)
+func (o Observer) ecef() [3]float64 {
+	lat := o.Latitude * math.Pi / 180
+	lon := o.Longitude * math.Pi / 180
+	h := o.Altitude / 1000
+	e2 := wgs84Flattening * (2 - wgs84Flattening)
+	n := wgs84Radius / math.Sqrt(1-e2*math.Sin(lat)*math.Sin(lat))
+	return [3]float64{
+		(n + h) * math.Cos(lat) * math.Cos(lon),
+		(n + h) * math.Cos(lat) * math.Sin(lon),
+		(n*(1-e2) + h) * math.Sin(lat),
+	}
+}
+
+// LookAngles is the satellite seen from the observer
+type LookAngles struct {
+	Azimuth   float64 `json:"azimuth"`    // degrees from north
+	Elevation float64 `json:"elevation"`  // degrees
+	Range     float64 `json:"range"`      // km
+	RangeRate float64 `json:"range_rate"` // km/s, positive when receding
+}
+
+// dopplerFactor returns the fraction by which a frequency shifts on the way down
+// A downlink is received at f·(1+factor); an uplink must be sent at f/(1+factor).
+func (l LookAngles) dopplerFactor() float64 {
+	return -l.RangeRate / speedOfLight
+}
+
+// look returns the look angles from the observer at t
+func (s *sgp4) look(o Observer, t time.Time) (LookAngles, error) {
+	r, v, err := s.propagate(t)
+	if err != nil {
+		return LookAngles{}, err
+	}
+
+	// TEME to Earth-fixed, ignoring polar motion
+	g := gmst(t)
+	cg, sg := math.Cos(g), math.Sin(g)
+	re := [3]float64{cg*r[0] + sg*r[1], -sg*r[0] + cg*r[1], r[2]}
+	ve := [3]float64{cg*v[0] + sg*v[1] + earthRotation*re[1], -sg*v[0] + cg*v[1] - earthRotation*re[0], v[2]}
+
+	obs := o.ecef()
+	d := [3]float64{re[0] - obs[0], re[1] - obs[1], re[2] - obs[2]}
+	rng := math.Sqrt(d[0]*d[0] + d[1]*d[1] + d[2]*d[2])
+
+	lat := o.Latitude * math.Pi / 180
+	lon := o.Longitude * math.Pi / 180
+	sl, cl := math.Sin(lat), math.Cos(lat)
+	so, co := math.Sin(lon), math.Cos(lon)
+	south := sl*co*d[0] + sl*so*d[1] - cl*d[2]
+	east := -so*d[0] + co*d[1]
+	zenith := cl*co*d[0] + cl*so*d[1] + sl*d[2]
+
+	az := math.Atan2(east, -south) * 180 / math.Pi
+	if az < 0 {
+		az += 360
+	}
+	return LookAngles{
+		Azimuth:   az,
+		Elevation: math.Asin(zenith/rng) * 180 / math.Pi,
+		Range:     rng,
+		RangeRate: (d[0]*ve[0] + d[1]*ve[1] + d[2]*ve[2]) / rng,
+	}, nil
+}
+
+// Pass is a satellite pass over the observer
+type Pass struct {
+	Satellite    string    `json:"satellite,omitempty"`
+	AOS          time.Time `json:"aos"` // acquisition of signal
+	LOS          time.Time `json:"los"` // loss of signal
+	MaxElevation float64   `json:"max_elevation"`
+	MaxAt        time.Time `json:"max_at"`
+	AOSAzimuth   float64   `json:"aos_azimuth"`
+	LOSAzimuth   float64   `json:"los_azimuth"`
+	Duration     int       `json:"duration"` // seconds
+}
+
+// passes predicts the passes above minElevation between from and to
+// A pass in progress at from starts at from.
+func (s *sgp4) passes(o Observer, from, to time.Time, minElevation float64, limit int) ([]Pass, error) {
+	const step = 20 * time.Second
+	above := func(t time.Time) (bool, LookAngles, error) {
+		look, err := s.look(o, t)
+		return look.Elevation >= minElevation, look, err
+	}
+	// crossing finds the rise or set time between a and b to the second
+	crossing := func(a, b time.Time, rising bool) (time.Time, error) {
+		for b.Sub(a) > time.Second {
+			mid := a.Add(b.Sub(a) / 2)
+			up, _, err := above(mid)
+			if err != nil {
+				return mid, err
+			}
+			if up == rising {
+				b = mid
+			} else {
+				a = mid
+			}
+		}
+		return b, nil
+	}
+
+	passes := []Pass{}
+	var current *Pass
+	up, look, err := above(from)
+	if err != nil {
+		return nil, err
+	}
+	if up {
+		current = &Pass{AOS: from, AOSAzimuth: look.Azimuth, MaxElevation: look.Elevation, MaxAt: from}
+	}
+	for t := from.Add(step); !t.After(to.Add(step)) && len(passes) < limit; t = t.Add(step) {
+		nowUp, look, err := above(t)
+		if err != nil {
+			return passes, err
+		}
+		switch {
+		case nowUp && current == nil:
+			if t.After(to) {
+				return passes, nil
+			}
+			aos, err := crossing(t.Add(-step), t, true)
+			if err != nil {
+				return passes, err
+			}
+			start, _ := s.look(o, aos)
+			current = &Pass{AOS: aos, AOSAzimuth: start.Azimuth, MaxElevation: look.Elevation, MaxAt: t}
+		case nowUp:
+			if look.Elevation > current.MaxElevation {
+				current.MaxElevation, current.MaxAt = look.Elevation, t
+			}
+		case current != nil:
+			los, err := crossing(t.Add(-step), t, false)
+			if err != nil {
+				return passes, err
+			}
+			end, _ := s.look(o, los)
+			current.LOS, current.LOSAzimuth = los, end.Azimuth
+			current.Duration = int(los.Sub(current.AOS).Seconds())
+			current.MaxElevation = math.Round(current.MaxElevation*10) / 10
+			current.AOSAzimuth = math.Round(current.AOSAzimuth)
+			current.LOSAzimuth = math.Round(current.LOSAzimuth)
+			passes = append(passes, *current)
+			current = nil
+		}
+	}
+	return passes, nil
+}
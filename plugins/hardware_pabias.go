@@ -0,0 +1,437 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"periph.io/x/conn/v3/i2c"
+	"periph.io/x/conn/v3/i2c/i2creg"
+)
+
+// PA bias output types
+const (
+	PABiasMCP4725 = "mcp4725" // 12-bit I2C DAC
+	PABiasPWM     = "pwm"     // sysfs PWM channel, filtered to a voltage on the board
+)
+
+// PA bias defaults
+const (
+	DefaultPABiasI2CAddress = 0x60
+	DefaultPABiasFullScale  = 3.3    // volts
+	DefaultPABiasPWMPeriod  = 100000 // nanoseconds (10 kHz)
+	paBiasRampStep          = 20 * time.Millisecond
+)
+
+// paBiasEvent is published when a bias ramp finishes or fails
+const paBiasEvent = "hardware.pa_bias"
+
+// errPABias is returned when the PA bias interlock refuses keying
+var errPABias = errors.New("PA bias interlock")
+
+// PABiasConfig selects the output driving the external PA bias
+type PABiasConfig struct {
+	Type       string  `yaml:"type"`        // "" disables, mcp4725 or pwm
+	I2CBus     string  `yaml:"i2c_bus"`     // periph.io bus name, e.g. "1" or "/dev/i2c-1"
+	I2CAddress uint16  `yaml:"i2c_address"` // DAC address (default 0x60)
+	PWMChip    int     `yaml:"pwm_chip"`    // /sys/class/pwm/pwmchip<N>
+	PWMChannel int     `yaml:"pwm_channel"`
+	PWMPeriod  int     `yaml:"pwm_period"` // nanoseconds
+	FullScale  float64 `yaml:"full_scale"` // volts at full DAC code or 100% duty cycle
+	Max        float64 `yaml:"max"`        // highest setpoint in volts
+	RampRate   float64 `yaml:"ramp_rate"`  // volts per second, 0 steps immediately
+	Interlock  bool    `yaml:"interlock"`  // refuse keying unless the bias has reached a non-zero setpoint
+}
+
+// validate checks the output settings
+func (cfg PABiasConfig) validate() error {
+	switch cfg.Type {
+	case "":
+		return nil
+	case PABiasMCP4725:
+		if cfg.I2CBus == "" {
+			return fmt.Errorf("hardware.pa_bias.i2c_bus is required for %s", cfg.Type)
+		}
+	case PABiasPWM:
+		if cfg.PWMChip < 0 || cfg.PWMChannel < 0 {
+			return fmt.Errorf("hardware.pa_bias.pwm_chip and pwm_channel must not be negative")
+		}
+	default:
+		return fmt.Errorf("hardware.pa_bias.type must be %s or %s", PABiasMCP4725, PABiasPWM)
+	}
+	if cfg.FullScale < 0 || cfg.Max < 0 || cfg.RampRate < 0 {
+		return fmt.Errorf("hardware.pa_bias: full_scale, max and ramp_rate must not be negative")
+	}
+	if cfg.FullScale > 0 && cfg.Max > cfg.FullScale {
+		return fmt.Errorf("hardware.pa_bias.max must not exceed full_scale")
+	}
+	return nil
+}
+
+// applyPABiasDefaults fills in unset output settings
+func applyPABiasDefaults(cfg *PABiasConfig) {
+	if cfg.I2CAddress == 0 {
+		cfg.I2CAddress = DefaultPABiasI2CAddress
+	}
+	if cfg.PWMPeriod <= 0 {
+		cfg.PWMPeriod = DefaultPABiasPWMPeriod
+	}
+	if cfg.FullScale == 0 {
+		cfg.FullScale = DefaultPABiasFullScale
+	}
+	if cfg.Max == 0 {
+		cfg.Max = cfg.FullScale
+	}
+}
+
+// paBiasOutput drives the bias voltage as a fraction of full scale
+type paBiasOutput interface {
+	set(fraction float64) error
+	Close() error
+}
+
+// openPABiasOutput opens the configured output
+func openPABiasOutput(cfg PABiasConfig) (paBiasOutput, error) {
+	switch cfg.Type {
+	case PABiasMCP4725:
+		return openMCP4725(cfg.I2CBus, cfg.I2CAddress)
+	case PABiasPWM:
+		return openSysfsPWM(cfg.PWMChip, cfg.PWMChannel, cfg.PWMPeriod)
+	default:
+		return nil, fmt.Errorf("PA bias output is not configured (hardware.pa_bias.type)")
+	}
+}
+
+// mcp4725 is a 12-bit I2C DAC
+type mcp4725 struct {
+	bus i2c.BusCloser
+	dev *i2c.Dev
+}
+
+// openMCP4725 opens the DAC on an I2C bus
+func openMCP4725(bus string, addr uint16) (*mcp4725, error) {
+	if err := initPeriph(); err != nil {
+		return nil, fmt.Errorf("failed to initialize periph.io: %w", err)
+	}
+	b, err := i2creg.Open(bus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open I2C bus %s: %w", bus, err)
+	}
+	return &mcp4725{bus: b, dev: &i2c.Dev{Bus: b, Addr: addr}}, nil
+}
+
+// set writes the DAC register with a fast write command (power-down bits cleared)
+func (d *mcp4725) set(fraction float64) error {
+	code := uint16(math.Round(fraction * 4095))
+	if err := d.dev.Tx([]byte{byte(code >> 8 & 0x0F), byte(code)}, nil); err != nil {
+		return fmt.Errorf("PA bias DAC write failed: %w", err)
+	}
+	return nil
+}
+
+func (d *mcp4725) Close() error {
+	return d.bus.Close()
+}
+
+// sysfsPWM is a PWM channel under /sys/class/pwm
+type sysfsPWM struct {
+	dir    string
+	period int
+}
+
+// openSysfsPWM exports and enables a PWM channel
+func openSysfsPWM(chip, channel, period int) (*sysfsPWM, error) {
+	chipDir := fmt.Sprintf("/sys/class/pwm/pwmchip%d", chip)
+	dir := filepath.Join(chipDir, fmt.Sprintf("pwm%d", channel))
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.WriteFile(filepath.Join(chipDir, "export"), []byte(strconv.Itoa(channel)), 0); err != nil {
+			return nil, fmt.Errorf("failed to export PWM channel %d of pwmchip%d: %w", channel, chip, err)
+		}
+		// udev applies permissions to the new channel asynchronously
+		for i := 0; i < 50; i++ {
+			if _, err := os.Stat(filepath.Join(dir, "period")); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	pwm := &sysfsPWM{dir: dir, period: period}
+	if err := pwm.write("period", period); err != nil {
+		return nil, err
+	}
+	if err := pwm.write("enable", 1); err != nil {
+		return nil, err
+	}
+	return pwm, nil
+}
+
+func (p *sysfsPWM) write(name string, value int) error {
+	if err := os.WriteFile(filepath.Join(p.dir, name), []byte(strconv.Itoa(value)), 0); err != nil {
+		return fmt.Errorf("failed to set PWM %s: %w", name, err)
+	}
+	return nil
+}
+
+func (p *sysfsPWM) set(fraction float64) error {
+	return p.write("duty_cycle", int(math.Round(fraction*float64(p.period))))
+}
+
+// Close leaves the channel enabled so the bias holds
+func (p *sysfsPWM) Close() error {
+	return nil
+}
+
+// PABiasStatus reports the bias output state
+type PABiasStatus struct {
+	Type      string    `json:"type"`
+	Output    float64   `json:"output"`   // volts currently applied
+	Setpoint  float64   `json:"setpoint"` // volts being ramped to
+	RampRate  float64   `json:"ramp_rate"`
+	Max       float64   `json:"max"`
+	Ramping   bool      `json:"ramping"`
+	Ready     bool      `json:"ready"` // setpoint reached and non-zero
+	Interlock bool      `json:"interlock"`
+	Error     string    `json:"error,omitempty"`
+	Updated   time.Time `json:"updated"`
+}
+
+// paBias tracks the output voltage and runs ramps
+type paBias struct {
+	mu       sync.Mutex
+	output   float64
+	setpoint float64
+	rampRate *float64 // overrides the configured rate when set through the API
+	ramping  bool
+	err      string
+	updated  time.Time
+	stop     chan struct{} // closes to cancel the running ramp
+	done     chan struct{} // closed when the running ramp has finished
+}
+
+// rate returns the ramp rate in volts per second; called with mu held
+func (b *paBias) rate(cfg PABiasConfig) float64 {
+	if b.rampRate != nil {
+		return *b.rampRate
+	}
+	return cfg.RampRate
+}
+
+// status returns the current state for a configuration
+func (b *paBias) status(cfg PABiasConfig) PABiasStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return PABiasStatus{
+		Type:      cfg.Type,
+		Output:    b.output,
+		Setpoint:  b.setpoint,
+		RampRate:  b.rate(cfg),
+		Max:       cfg.Max,
+		Ramping:   b.ramping,
+		Ready:     !b.ramping && b.err == "" && b.setpoint > 0 && b.output == b.setpoint,
+		Interlock: cfg.Interlock,
+		Error:     b.err,
+		Updated:   b.updated,
+	}
+}
+
+// cancel stops a running ramp and waits for it to exit
+func (b *paBias) cancel() {
+	b.mu.Lock()
+	stop, done := b.stop, b.done
+	b.stop, b.done = nil, nil
+	b.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// start ramps the output from its current value to setpoint in the background
+func (b *paBias) start(cfg PABiasConfig, setpoint float64) {
+	b.cancel()
+
+	b.mu.Lock()
+	rate := b.rate(cfg)
+	from := b.output
+	stop, done := make(chan struct{}), make(chan struct{})
+	b.stop, b.done = stop, done
+	b.setpoint = setpoint
+	b.ramping = true
+	b.err = ""
+	b.mu.Unlock()
+
+	go b.ramp(cfg, from, setpoint, rate, stop, done)
+}
+
+// ramp steps the output towards the setpoint at rate volts per second
+func (b *paBias) ramp(cfg PABiasConfig, from, to, rate float64, stop, done chan struct{}) {
+	defer close(done)
+
+	err := func() error {
+		out, err := openPABiasOutput(cfg)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		step := math.Inf(1)
+		if rate > 0 {
+			step = rate * paBiasRampStep.Seconds()
+		}
+		ticker := time.NewTicker(paBiasRampStep)
+		defer ticker.Stop()
+
+		value := from
+		for {
+			if to > value {
+				value = math.Min(to, value+step)
+			} else {
+				value = math.Max(to, value-step)
+			}
+			if err := out.set(value / cfg.FullScale); err != nil {
+				return err
+			}
+			b.mu.Lock()
+			b.output = value
+			b.updated = time.Now()
+			b.mu.Unlock()
+			if value == to {
+				return nil
+			}
+			select {
+			case <-stop:
+				return nil
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	b.mu.Lock()
+	b.ramping = false
+	if err != nil {
+		b.err = err.Error()
+	}
+	status := PABiasStatus{Type: cfg.Type, Output: b.output, Setpoint: b.setpoint, Error: b.err, Updated: b.updated}
+	b.mu.Unlock()
+
+	select {
+	case <-stop:
+		return // superseded by a new setpoint
+	default:
+	}
+	if err != nil {
+		slog.Error("PA bias ramp failed", "setpoint", to, "output", status.Output, "error", err)
+	} else {
+		slog.Info("PA bias set", "output", status.Output)
+	}
+	PublishEvent(paBiasEvent, hardwareMonitorEventSource, status)
+}
+
+// reset cancels a running ramp and drops the API ramp rate after a configuration change
+func (b *paBias) reset() {
+	b.cancel()
+	b.mu.Lock()
+	b.rampRate = nil
+	b.mu.Unlock()
+}
+
+// checkPABias refuses keying while the interlock is enabled and the bias is not ready
+func (p *HardwarePlugin) checkPABias() error {
+	cfg := p.getConfig().PABias
+	if cfg.Type == "" || !cfg.Interlock {
+		return nil
+	}
+	status := p.bias.status(cfg)
+	switch {
+	case status.Error != "":
+		return fmt.Errorf("%w: bias output failed: %s", errPABias, status.Error)
+	case status.Ramping:
+		return fmt.Errorf("%w: bias is ramping to %.3f V", errPABias, status.Setpoint)
+	case !status.Ready:
+		return fmt.Errorf("%w: bias is off", errPABias)
+	}
+	return nil
+}
+
+// handleGetPABias handles GET /api/hardware/pa-bias
+func (p *HardwarePlugin) handleGetPABias(c *fiber.Ctx) error {
+	cfg := p.getConfig().PABias
+	if cfg.Type == "" {
+		return SendErrorMessage(c, 404, "PA bias output is not configured (hardware.pa_bias.type)")
+	}
+	return SendSuccess(c, p.bias.status(cfg), "")
+}
+
+// handleSetPABias handles POST /api/hardware/pa-bias {"setpoint": 1.8, "ramp_rate": 0.5}
+// Starts ramping the bias to the setpoint in volts; ramp_rate (V/s) replaces the
+// configured rate until the next reload. Refused while the transmitter is keyed.
+func (p *HardwarePlugin) handleSetPABias(c *fiber.Ctx) error {
+	cfg := p.getConfig().PABias
+	if cfg.Type == "" {
+		return SendErrorMessage(c, 404, "PA bias output is not configured (hardware.pa_bias.type)")
+	}
+
+	var req struct {
+		Setpoint *float64 `json:"setpoint"`
+		RampRate *float64 `json:"ramp_rate"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	if req.Setpoint == nil && req.RampRate == nil {
+		return SendErrorMessage(c, 400, "setpoint or ramp_rate is required")
+	}
+	if req.Setpoint != nil && (*req.Setpoint < 0 || *req.Setpoint > cfg.Max) {
+		return SendErrorMessage(c, 400, fmt.Sprintf("setpoint must be between 0 and %.3f V", cfg.Max))
+	}
+	if req.RampRate != nil && *req.RampRate < 0 {
+		return SendErrorMessage(c, 400, "ramp_rate must not be negative")
+	}
+
+	if req.Setpoint != nil {
+		// Changing the bias under drive can damage the PA
+		err := p.withRequestController(c, func(ctrl *SX1255Controller) error {
+			mode, err := ctrl.GetMode()
+			if err != nil {
+				return err
+			}
+			tx, err := ctrl.GetTxRxSwitch()
+			if err != nil {
+				return err
+			}
+			if modeEnablesTx(mode) || tx {
+				return errTxActive
+			}
+			return nil
+		})
+		if err != nil {
+			if errors.Is(err, errTxActive) {
+				return SendError(c, 409, err)
+			}
+			return SendError(c, 500, err)
+		}
+	}
+	if dryRun(c) != nil {
+		return SendSuccess(c, fiber.Map{"setpoint": req.Setpoint, "ramp_rate": req.RampRate}, "")
+	}
+
+	if req.RampRate != nil {
+		p.bias.mu.Lock()
+		p.bias.rampRate = req.RampRate
+		p.bias.mu.Unlock()
+	}
+	if req.Setpoint != nil {
+		p.bias.start(cfg, *req.Setpoint)
+		slog.InfoContext(c.UserContext(), "PA bias ramp started", "setpoint", *req.Setpoint)
+	}
+
+	return SendSuccess(c, p.bias.status(cfg), "")
+}
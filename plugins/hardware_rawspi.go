@@ -0,0 +1,104 @@
+package plugins
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxSPITransfer is the longest raw transfer, spidev's default buffer size
+const maxSPITransfer = 4096
+
+// SPITransferResult is the outcome of a raw SPI transfer
+type SPITransferResult struct {
+	Device string `json:"device"`
+	Length int    `json:"length"`
+	TX     string `json:"tx"` // hex bytes sent
+	RX     string `json:"rx"` // hex bytes clocked in at the same time
+}
+
+// parseHexBytes decodes hex bytes, optionally separated by spaces, colons or
+// commas and prefixed with 0x: "8a01", "8a 01" and "0x8a,0x01" are the same
+func parseHexBytes(s string) ([]byte, error) {
+	s = strings.NewReplacer("0x", "", "0X", "", " ", "", ":", "", ",", "", "\t", "", "\n", "").Replace(s)
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex bytes: %w", err)
+	}
+	return data, nil
+}
+
+// rawSPIModeWrite returns the RegMode value written by a raw SX1255 transfer
+// A transfer starting with a write address writes consecutive registers from it.
+func rawSPIModeWrite(tx []byte) (uint8, bool) {
+	if len(tx) < 2 || tx[0]&0x80 == 0 {
+		return 0, false
+	}
+	start := tx[0] & 0x7F
+	if start > RegMode || int(RegMode-start)+1 >= len(tx) {
+		return 0, false
+	}
+	return tx[RegMode-start+1], true
+}
+
+// handleSPITransfer handles POST /api/hardware/spi/transfer {"tx": "0x07 0x00"}
+// Clocks the bytes out on the transceiver's SPI bus and returns the bytes read
+// back, for bring-up of new boards. Disabled unless hardware.diagnostics.raw_spi is set.
+func (p *HardwarePlugin) handleSPITransfer(c *fiber.Ctx) error {
+	cfg := p.getConfig()
+	if !cfg.Diagnostics.RawSPI {
+		return SendErrorMessage(c, 403, "Raw SPI transfers are disabled (hardware.diagnostics.raw_spi)")
+	}
+
+	var req struct {
+		TX string `json:"tx"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	tx, err := parseHexBytes(req.TX)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+	if len(tx) == 0 || len(tx) > maxSPITransfer {
+		return SendErrorMessage(c, 400, fmt.Sprintf("tx must have 1 to %d bytes", maxSPITransfer))
+	}
+
+	// Writes to RegMode follow the same transmit rules as register writes
+	mode, writesMode := rawSPIModeWrite(tx)
+	keying := writesMode && modeEnablesTx(mode)
+	if keying {
+		if err := checkTxAllowed(); err != nil {
+			return SendError(c, 423, err)
+		}
+	}
+	override, err := p.bandPlanOverride(c)
+	if err != nil {
+		return SendError(c, 403, err)
+	}
+
+	rx := make([]byte, len(tx))
+	transfer := func(ctrl *SX1255Controller) error {
+		return ctrl.spi.Transfer(tx, rx)
+	}
+	if writesMode {
+		err = p.withRequestTxController(c, keying, override, transfer)
+	} else {
+		err = p.withRequestController(c, transfer)
+	}
+	if err != nil {
+		return sendTxError(c, err)
+	}
+
+	result := SPITransferResult{
+		Device: cfg.SX1255.SPIDevice,
+		Length: len(tx),
+		TX:     hex.EncodeToString(tx),
+		RX:     hex.EncodeToString(rx),
+	}
+	slog.InfoContext(c.UserContext(), "Raw SPI transfer", "device", result.Device, "tx", result.TX, "rx", result.RX)
+	return SendSuccess(c, result, "")
+}
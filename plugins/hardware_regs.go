@@ -69,6 +69,11 @@ const (
 	StatPllLockTx = 1 << 0 // TX PLL locked
 )
 
+// RegRxfe3 (0x0E) bits
+const (
+	Rxfe3AdcTemp = 1 << 0 // Route the temperature sensor to the RX ADC
+)
+
 // Register descriptions for UI
 var RegisterDescriptions = map[uint8]string{
 	RegMode:      "MODE - Operating mode control",
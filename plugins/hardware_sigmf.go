@@ -0,0 +1,278 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SigMF constants
+const (
+	SigMFVersion       = "1.0.0"
+	sigmfMetaExtension = ".sigmf-meta"
+)
+
+// sigmfDatatypes maps capture formats to SigMF dataset formats
+var sigmfDatatypes = map[string]string{
+	CaptureFormatCS16:  "ci16_le",
+	CaptureFormatCF32:  "cf32_le",
+	CaptureFormatSigMF: "ci16_le",
+}
+
+// SigMFAnnotation is an annotation segment in a recording
+// Sample positions are relative to the start of the dataset
+type SigMFAnnotation struct {
+	SampleStart   int64    `json:"core:sample_start"`
+	SampleCount   *int64   `json:"core:sample_count,omitempty"`
+	FreqLowerEdge *float64 `json:"core:freq_lower_edge,omitempty"`
+	FreqUpperEdge *float64 `json:"core:freq_upper_edge,omitempty"`
+	Label         string   `json:"core:label,omitempty"`
+	Comment       string   `json:"core:comment,omitempty"`
+}
+
+// validate checks an annotation against the recording length
+func (a *SigMFAnnotation) validate(samples int64) error {
+	if a.SampleStart < 0 || (samples > 0 && a.SampleStart >= samples) {
+		return fmt.Errorf("sample_start %d is outside the recording (%d samples)", a.SampleStart, samples)
+	}
+	if a.SampleCount != nil && (*a.SampleCount <= 0 || (samples > 0 && a.SampleStart+*a.SampleCount > samples)) {
+		return fmt.Errorf("sample_count %d exceeds the recording", *a.SampleCount)
+	}
+	if a.FreqLowerEdge != nil && a.FreqUpperEdge != nil && *a.FreqLowerEdge > *a.FreqUpperEdge {
+		return fmt.Errorf("freq_lower_edge must not be greater than freq_upper_edge")
+	}
+	return nil
+}
+
+// sigmfMetaPath returns the metadata path for a recording data file
+// Conforming recordings share the base name; other formats keep their extension in the dataset field
+func sigmfMetaPath(dataPath string) string {
+	return strings.TrimSuffix(dataPath, filepath.Ext(dataPath)) + sigmfMetaExtension
+}
+
+// writeSigMFMeta writes SigMF metadata next to a recording
+// Recordings in cs16/cf32 are referenced as non-conforming datasets via core:dataset
+func writeSigMFMeta(status CaptureStatus, hardware map[string]interface{}) (string, error) {
+	dataPath := status.Files[0]
+	metaPath := sigmfMetaPath(dataPath)
+
+	global := map[string]interface{}{
+		"core:datatype":     sigmfDatatypes[status.Format],
+		"core:sample_rate":  status.SampleRate,
+		"core:version":      SigMFVersion,
+		"core:hw":           "SX1255",
+		"core:recorder":     "linht-web-manager",
+		"core:num_channels": 1,
+		"core:extensions": []map[string]interface{}{
+			{"name": "linht", "version": "1.0.0", "optional": true},
+		},
+	}
+	if status.Format != CaptureFormatSigMF {
+		global["core:dataset"] = filepath.Base(dataPath)
+	}
+	if version, ok := hardware["version"].(string); ok {
+		global["core:hw"] = "SX1255 " + version
+	}
+	if len(hardware) > 0 {
+		global["linht:hardware"] = hardware
+	}
+	if fix, ok := CurrentGNSSFix(); ok && fix.HasPosition() {
+		coordinates := []float64{fix.Longitude, fix.Latitude}
+		if fix.Mode == GNSSMode3D {
+			coordinates = append(coordinates, fix.Altitude)
+		}
+		global["core:geolocation"] = map[string]interface{}{
+			"type":        "Point",
+			"coordinates": coordinates,
+		}
+	}
+
+	capture := map[string]interface{}{
+		"core:sample_start": 0,
+		"core:frequency":    status.Frequency,
+		"core:datetime":     status.StartedAt.Format(time.RFC3339Nano),
+	}
+
+	meta := map[string]interface{}{
+		"global":      global,
+		"captures":    []map[string]interface{}{capture},
+		"annotations": []SigMFAnnotation{},
+	}
+	if err := writeSigMFFile(metaPath, meta); err != nil {
+		return "", fmt.Errorf("failed to write SigMF metadata: %w", err)
+	}
+	return metaPath, nil
+}
+
+// readSigMFFile loads a metadata file, keeping fields this plugin does not know about
+func readSigMFFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var meta map[string]interface{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("invalid SigMF metadata: %w", err)
+	}
+	return meta, nil
+}
+
+// writeSigMFFile writes metadata via a temporary file so readers never see a partial file
+func writeSigMFFile(path string, meta map[string]interface{}) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// recordingSamples returns the number of samples in the dataset described by meta
+func recordingSamples(metaPath string, meta map[string]interface{}) int64 {
+	global, _ := meta["global"].(map[string]interface{})
+	dataPath := strings.TrimSuffix(metaPath, sigmfMetaExtension) + ".sigmf-data"
+	if dataset, ok := global["core:dataset"].(string); ok {
+		dataPath = filepath.Join(filepath.Dir(metaPath), filepath.Base(dataset))
+	}
+
+	info, err := os.Stat(dataPath)
+	if err != nil {
+		return 0
+	}
+	bytesPerSample := int64(4)
+	if global["core:datatype"] == "cf32_le" {
+		bytesPerSample = 8
+	}
+	return info.Size() / bytesPerSample
+}
+
+// captureMetaPath resolves the metadata file of a recording by name
+func (p *HardwarePlugin) captureMetaPath(name string) (string, error) {
+	if !captureNamePattern.MatchString(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid recording name")
+	}
+	return filepath.Join(p.getConfig().Capture.Dir, name+sigmfMetaExtension), nil
+}
+
+// handleGetAnnotations handles GET /api/hardware/captures/:name/annotations
+func (p *HardwarePlugin) handleGetAnnotations(c *fiber.Ctx) error {
+	metaPath, err := p.captureMetaPath(c.Params("name"))
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+	meta, err := readSigMFFile(metaPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return SendErrorMessage(c, 404, "Recording metadata not found")
+		}
+		return SendError(c, 500, err)
+	}
+
+	annotations, ok := meta["annotations"]
+	if !ok || annotations == nil {
+		annotations = []interface{}{}
+	}
+	return SendSuccess(c, fiber.Map{
+		"meta":        metaPath,
+		"samples":     recordingSamples(metaPath, meta),
+		"annotations": annotations,
+	}, "")
+}
+
+// handleSetAnnotations handles PUT /api/hardware/captures/:name/annotations
+// Replaces all annotations; they are stored sorted by sample_start as SigMF requires
+func (p *HardwarePlugin) handleSetAnnotations(c *fiber.Ctx) error {
+	var annotations []SigMFAnnotation
+	if err := c.BodyParser(&annotations); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body, expected an array of annotations")
+	}
+	return p.updateAnnotations(c, func([]SigMFAnnotation) []SigMFAnnotation {
+		return annotations
+	})
+}
+
+// handleAddAnnotation handles POST /api/hardware/captures/:name/annotations
+// Adds a single annotation to the existing ones
+func (p *HardwarePlugin) handleAddAnnotation(c *fiber.Ctx) error {
+	var annotation SigMFAnnotation
+	if err := c.BodyParser(&annotation); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	return p.updateAnnotations(c, func(existing []SigMFAnnotation) []SigMFAnnotation {
+		return append(existing, annotation)
+	})
+}
+
+// updateAnnotations validates and stores the annotations returned by update
+func (p *HardwarePlugin) updateAnnotations(c *fiber.Ctx, update func([]SigMFAnnotation) []SigMFAnnotation) error {
+	name := c.Params("name")
+	metaPath, err := p.captureMetaPath(name)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+
+	// Recordings in progress get their metadata when they finish
+	if session := p.getCapture(); session != nil {
+		if status := session.getStatus(); status.Active && status.Name == name {
+			return SendErrorMessage(c, 409, "Recording is still in progress")
+		}
+	}
+
+	p.captureMu.Lock()
+	defer p.captureMu.Unlock()
+
+	meta, err := readSigMFFile(metaPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return SendErrorMessage(c, 404, "Recording metadata not found")
+		}
+		return SendError(c, 500, err)
+	}
+
+	var existing []SigMFAnnotation
+	if raw, err := json.Marshal(meta["annotations"]); err == nil {
+		json.Unmarshal(raw, &existing)
+	}
+
+	annotations := update(existing)
+	samples := recordingSamples(metaPath, meta)
+	for i := range annotations {
+		if err := annotations[i].validate(samples); err != nil {
+			return SendError(c, 400, fmt.Errorf("annotation %d: %w", i, err))
+		}
+	}
+	sort.SliceStable(annotations, func(i, j int) bool {
+		return annotations[i].SampleStart < annotations[j].SampleStart
+	})
+	if annotations == nil {
+		annotations = []SigMFAnnotation{}
+	}
+
+	if dryRun(c) != nil {
+		return SendSuccess(c, fiber.Map{
+			"meta":        metaPath,
+			"annotations": annotations,
+		}, "")
+	}
+
+	meta["annotations"] = annotations
+	if err := writeSigMFFile(metaPath, meta); err != nil {
+		return SendError(c, 500, err)
+	}
+
+	slog.InfoContext(c.UserContext(), "Recording annotations updated", "name", name, "count", len(annotations))
+	return SendSuccess(c, fiber.Map{
+		"meta":        metaPath,
+		"annotations": annotations,
+	}, "Annotations saved")
+}
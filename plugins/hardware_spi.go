@@ -2,6 +2,8 @@ package plugins
 
 import (
 	"fmt"
+	"log/slog"
+	"sync"
 	"time"
 
 	"periph.io/x/conn/v3/physic"
@@ -10,18 +12,47 @@ import (
 	"periph.io/x/host/v3"
 )
 
+// periph.io host initialization state, shared by all transient controllers
+var (
+	periphMu          sync.Mutex
+	periphInitialized bool
+)
+
+// initPeriph initializes the periph.io host drivers once per process
+// Driver registration is expensive on slow boots and need not be repeated per request.
+// A failed initialization is not remembered, so the next call tries again.
+func initPeriph() error {
+	periphMu.Lock()
+	defer periphMu.Unlock()
+	if periphInitialized {
+		return nil
+	}
+
+	start := time.Now()
+	_, err := host.Init()
+	slog.Debug("periph.io host initialized", "duration", time.Since(start), "error", err)
+	if err != nil {
+		return err
+	}
+	periphInitialized = true
+	return nil
+}
+
 // SPIDevice represents an SPI device using periph.io
 type SPIDevice struct {
 	conn   spi.Conn
 	port   spi.PortCloser
 	device string
 	speed  physic.Frequency
+
+	sim    *registerSim    // validation: transfers go to simulated registers
+	shadow *registerShadow // remembers the registers seen in transfers
 }
 
 // NewSPIDevice opens and initializes an SPI device using periph.io
 func NewSPIDevice(device string, speed uint32) (*SPIDevice, error) {
-	// Initialize periph.io host
-	if _, err := host.Init(); err != nil {
+	// Initialize periph.io host (no-op after the first call)
+	if err := initPeriph(); err != nil {
 		return nil, fmt.Errorf("failed to initialize periph.io: %w", err)
 	}
 
@@ -61,6 +92,10 @@ func (s *SPIDevice) Transfer(tx []byte, rx []byte) error {
 		return fmt.Errorf("tx and rx buffers must be the same length")
 	}
 
+	if s.sim != nil {
+		return s.sim.transfer(tx, rx)
+	}
+
 	if s.conn == nil {
 		return fmt.Errorf("SPI device not open")
 	}
@@ -70,6 +105,9 @@ func (s *SPIDevice) Transfer(tx []byte, rx []byte) error {
 		return fmt.Errorf("SPI transfer failed: %w", err)
 	}
 
+	if s.shadow != nil {
+		s.shadow.observe(tx, rx)
+	}
 	return nil
 }
 
@@ -198,7 +236,7 @@ func (s *SPIDevice) Reopen() error {
 // ValidateSPIDevice checks if the device can be opened
 func ValidateSPIDevice(device string) error {
 	// Initialize periph.io
-	if _, err := host.Init(); err != nil {
+	if err := initPeriph(); err != nil {
 		return fmt.Errorf("failed to initialize periph.io: %w", err)
 	}
 
@@ -0,0 +1,33 @@
+package plugins
+
+import (
+	"testing"
+
+	"periph.io/x/host/v3"
+)
+
+// BenchmarkPeriphInitPerRequest is the host initialization every transient
+// controller ran before initPeriph
+// host.Init keeps its state after the first call, so the two benchmarks only
+// differ by the driver registration of the first request, which initPeriph
+// moves to plugin startup.
+func BenchmarkPeriphInitPerRequest(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := host.Init(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkPeriphInitCached is the host initialization of a transient controller now
+func BenchmarkPeriphInitCached(b *testing.B) {
+	if err := initPeriph(); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := initPeriph(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Frequency sweep limits
+const (
+	DefaultSweepDwellMs = 10
+	MaxSweepDwellMs     = 10000
+	MaxSweepSteps       = 2000
+	MaxSweepDuration    = 5 * time.Minute
+)
+
+// SweepRequest describes an RX frequency sweep
+type SweepRequest struct {
+	Start   Frequency `json:"start"`
+	Stop    Frequency `json:"stop"`
+	Step    Frequency `json:"step"`
+	DwellMs int       `json:"dwell_ms"` // settle time before sampling lock status
+}
+
+// SweepStep is the result of a single sweep point
+type SweepStep struct {
+	Frequency uint32 `json:"frequency"`
+	Locked    bool   `json:"locked"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SweepSummary aggregates a completed sweep
+type SweepSummary struct {
+	Steps         int    `json:"steps"`
+	Locked        int    `json:"locked"`
+	Unlocked      int    `json:"unlocked"`
+	Completed     bool   `json:"completed"`
+	Duration      string `json:"duration"`
+	RestoredFreq  uint32 `json:"restored_frequency"`
+	RSSIAvailable bool   `json:"rssi_available"`
+}
+
+// validate checks the sweep parameters and returns the number of steps
+func (r *SweepRequest) validate() (int, error) {
+	if r.DwellMs == 0 {
+		r.DwellMs = DefaultSweepDwellMs
+	}
+	if r.Start < MinFrequencyHz || r.Stop > MaxFrequencyHz {
+		return 0, fmt.Errorf("sweep range must be within %d-%d Hz", MinFrequencyHz, MaxFrequencyHz)
+	}
+	if r.Start > r.Stop {
+		return 0, fmt.Errorf("start must not be greater than stop")
+	}
+	if r.Step == 0 {
+		return 0, fmt.Errorf("step must be greater than 0")
+	}
+	if r.DwellMs < 0 || r.DwellMs > MaxSweepDwellMs {
+		return 0, fmt.Errorf("dwell_ms must be between 0 and %d", MaxSweepDwellMs)
+	}
+
+	steps := int((r.Stop-r.Start)/r.Step) + 1
+	if steps > MaxSweepSteps {
+		return 0, fmt.Errorf("sweep has %d steps (maximum %d)", steps, MaxSweepSteps)
+	}
+	if time.Duration(steps)*time.Duration(r.DwellMs)*time.Millisecond > MaxSweepDuration {
+		return 0, fmt.Errorf("sweep would take longer than %s", MaxSweepDuration)
+	}
+	return steps, nil
+}
+
+// runSweep steps the RX frequency across the requested range in one controller session
+// emit is called after each step; returning false aborts the sweep
+// The original RX frequency is restored afterwards
+func (p *HardwarePlugin) runSweep(req SweepRequest, emit func(SweepStep) bool) (SweepSummary, error) {
+	var summary SweepSummary
+	start := time.Now()
+	dwell := time.Duration(req.DwellMs) * time.Millisecond
+
+	err := p.withController(func(ctrl *SX1255Controller) error {
+		mode, err := ctrl.GetMode()
+		if err != nil {
+			return err
+		}
+		if mode&ModeBitRxEnable == 0 {
+			return fmt.Errorf("RX path is disabled; set mode to rx or full_duplex before sweeping")
+		}
+
+		original, err := ctrl.GetRxFrequency()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := ctrl.SetRxFrequency(original); err != nil {
+				slog.Error("Failed to restore RX frequency after sweep", "frequency", original, "error", err)
+				return
+			}
+			summary.RestoredFreq = original
+		}()
+
+		summary.Completed = true
+		for freq := uint64(req.Start); freq <= uint64(req.Stop); freq += uint64(req.Step) {
+			step := SweepStep{Frequency: uint32(freq)}
+
+			if err := ctrl.SetRxFrequency(step.Frequency); err != nil {
+				step.Error = err.Error()
+			} else {
+				time.Sleep(dwell)
+				_, rxLocked, err := ctrl.GetPLLStatus()
+				if err != nil {
+					step.Error = err.Error()
+				}
+				step.Locked = rxLocked
+			}
+
+			summary.Steps++
+			if step.Locked {
+				summary.Locked++
+			} else {
+				summary.Unlocked++
+			}
+
+			if !emit(step) {
+				summary.Completed = false
+				break
+			}
+		}
+		return nil
+	})
+
+	summary.Duration = time.Since(start).String()
+	return summary, err
+}
+
+// handleSweep handles POST /api/hardware/sweep?stream=false&async=false
+// Returns all steps at once, or streams each step via SSE when stream=true.
+// Without stream the sweep runs as a job; async=true answers with the job at once.
+// The SX1255 has no RSSI register, so only PLL lock is recorded per step
+func (p *HardwarePlugin) handleSweep(c *fiber.Ctx) error {
+	var req SweepRequest
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+
+	steps, err := req.validate()
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+	if dryRun(c) != nil {
+		return SendSuccess(c, fiber.Map{"request": req, "steps": steps}, "")
+	}
+
+	ctx := c.UserContext()
+	slog.InfoContext(ctx, "Frequency sweep started",
+		"start", req.Start,
+		"stop", req.Stop,
+		"step", req.Step,
+		"dwell_ms", req.DwellMs,
+		"steps", steps)
+
+	if !c.QueryBool("stream") {
+		description := fmt.Sprintf("Sweep %d-%d Hz in %d steps", req.Start, req.Stop, steps)
+		return runJob(c, "hardware.sweep", description, func(jobCtx context.Context, job *JobHandle) (interface{}, error) {
+			results := make([]SweepStep, 0, steps)
+			summary, err := p.runSweep(req, func(step SweepStep) bool {
+				results = append(results, step)
+				job.Progress(int64(len(results)), int64(steps), "steps")
+				return jobCtx.Err() == nil
+			})
+			if err == nil {
+				err = jobCtx.Err()
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "Frequency sweep failed", "error", err)
+				return nil, err
+			}
+
+			slog.InfoContext(ctx, "Frequency sweep completed", "locked", summary.Locked, "unlocked", summary.Unlocked)
+			return fiber.Map{
+				"summary": summary,
+				"results": results,
+			}, nil
+		}, "")
+	}
+
+	// Set SSE headers
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		summary, err := p.runSweep(req, func(step SweepStep) bool {
+			data, _ := json.Marshal(step)
+			fmt.Fprintf(w, "event: step\ndata: %s\n\n", data)
+			return w.Flush() == nil
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Frequency sweep failed", "error", err)
+			data, _ := json.Marshal(fiber.Map{"error": err.Error()})
+			fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+			w.Flush()
+			return
+		}
+
+		slog.InfoContext(ctx, "Frequency sweep completed",
+			"locked", summary.Locked,
+			"unlocked", summary.Unlocked,
+			"completed", summary.Completed)
+		data, _ := json.Marshal(summary)
+		fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
+		w.Flush()
+	})
+
+	return nil
+}
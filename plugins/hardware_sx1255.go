@@ -3,6 +3,13 @@ package plugins
 import (
 	"fmt"
 	"math"
+	"time"
+)
+
+// Supported RF frequency range (400-510 MHz per datasheet)
+const (
+	MinFrequencyHz = 400000000
+	MaxFrequencyHz = 510000000
 )
 
 // SX1255Controller provides high-level control of the SX1255 transceiver
@@ -11,6 +18,9 @@ type SX1255Controller struct {
 	gpio        *GPIOController
 	clockFreq   uint32
 	initialized bool
+
+	// tuned is called after a frequency change, with tx set for the TX synthesizer
+	tuned func(ctrl *SX1255Controller, tx bool, freqHz uint32) error
 }
 
 // NewSX1255Controller creates a new SX1255 controller
@@ -160,7 +170,7 @@ func (s *SX1255Controller) SetRxFrequency(freqHz uint32) error {
 	}
 
 	// Validate frequency range (400-510 MHz per datasheet)
-	if freqHz < 400000000 || freqHz > 510000000 {
+	if freqHz < MinFrequencyHz || freqHz > MaxFrequencyHz {
 		return fmt.Errorf("frequency %d Hz out of range (400-510 MHz)", freqHz)
 	}
 
@@ -185,6 +195,9 @@ func (s *SX1255Controller) SetRxFrequency(freqHz uint32) error {
 		return fmt.Errorf("failed to write RX frequency LSB: %w", err)
 	}
 
+	if s.tuned != nil {
+		return s.tuned(s, false, freqHz)
+	}
 	return nil
 }
 
@@ -225,7 +238,7 @@ func (s *SX1255Controller) SetTxFrequency(freqHz uint32) error {
 	}
 
 	// Validate frequency range (400-510 MHz per datasheet)
-	if freqHz < 400000000 || freqHz > 510000000 {
+	if freqHz < MinFrequencyHz || freqHz > MaxFrequencyHz {
 		return fmt.Errorf("frequency %d Hz out of range (400-510 MHz)", freqHz)
 	}
 
@@ -248,9 +261,17 @@ func (s *SX1255Controller) SetTxFrequency(freqHz uint32) error {
 		return fmt.Errorf("failed to write TX frequency LSB: %w", err)
 	}
 
+	if s.tuned != nil {
+		return s.tuned(s, true, freqHz)
+	}
 	return nil
 }
 
+// frequencyFromWord converts a 24-bit PLL frequency word to Hz
+func (s *SX1255Controller) frequencyFromWord(frf uint32) uint32 {
+	return uint32(math.Round(float64(s.clockFreq) * float64(frf) / math.Pow(2, 20)))
+}
+
 // GetTxFrequency reads the TX frequency in Hz
 func (s *SX1255Controller) GetTxFrequency() (uint32, error) {
 	if !s.initialized {
@@ -319,27 +340,121 @@ func (s *SX1255Controller) GetStatus() (map[string]bool, error) {
 	return status, nil
 }
 
+// temperatureSettleTime is the wait after switching the ADC to the temperature sensor
+const temperatureSettleTime = 10 * time.Millisecond
+
+// ReadTemperature measures the die temperature
+// The sensor is routed to the RX ADC (RegRxfe3 adc_temp, RX path enabled) and
+// sample reads the mean I level from the baseband interface. RegMode and
+// RegRxfe3 are restored afterwards. Returns the temperature in °C with
+// offset applied and the raw ADC level.
+func (s *SX1255Controller) ReadTemperature(offset float64, sample func() (float64, error)) (celsius float64, raw float64, err error) {
+	if !s.initialized {
+		return 0, 0, fmt.Errorf("controller not initialized")
+	}
+
+	mode, err := s.spi.ReadRegister(RegMode)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read MODE register: %w", err)
+	}
+	rxfe3, err := s.spi.ReadRegister(RegRxfe3)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read RXFE3 register: %w", err)
+	}
+
+	// Restore the prior state whatever happens below
+	defer func() {
+		restoreErr := s.spi.WriteRegister(RegRxfe3, rxfe3)
+		if modeErr := s.spi.WriteRegister(RegMode, mode); restoreErr == nil {
+			restoreErr = modeErr
+		}
+		if restoreErr != nil && err == nil {
+			err = fmt.Errorf("failed to restore registers after temperature measurement: %w", restoreErr)
+		}
+	}()
+
+	if err = s.spi.WriteRegister(RegRxfe3, rxfe3|Rxfe3AdcTemp); err != nil {
+		return 0, 0, fmt.Errorf("failed to enable temperature sensor: %w", err)
+	}
+	// The ADC only runs with the reference and RX path enabled
+	if err = s.spi.WriteRegister(RegMode, mode|ModeBitRefEnable|ModeBitRxEnable); err != nil {
+		return 0, 0, fmt.Errorf("failed to enable RX path: %w", err)
+	}
+	time.Sleep(temperatureSettleTime)
+
+	if raw, err = sample(); err != nil {
+		return 0, 0, fmt.Errorf("failed to read temperature sensor: %w", err)
+	}
+	return temperatureFromADC(raw) + offset, raw, nil
+}
+
+// temperatureFromADC converts the mean 16-bit ADC level in temperature mode
+// to °C: 1 °C per 256 counts around 25 °C at mid-scale. The absolute value
+// varies between devices and needs the configured calibration offset.
+func temperatureFromADC(raw float64) float64 {
+	return 25 + raw/256
+}
+
+// lnaGainSettingFor maps an LNA gain in dB to the RegRxfe1 field value
+func lnaGainSettingFor(gainDb uint8) uint8 {
+	switch {
+	case gainDb > 45:
+		return LnaGainMax // 0 dB
+	case gainDb > 39:
+		return LnaGainMinus6 // -6 dB
+	case gainDb > 30:
+		return LnaGainMinus12 // -12 dB
+	case gainDb > 18:
+		return LnaGainMinus24 // -24 dB
+	case gainDb > 6:
+		return LnaGainMinus36 // -36 dB
+	default:
+		return LnaGainMinus48 // -48 dB
+	}
+}
+
+// pgaGainSettingFor maps a PGA gain in dB to the RegRxfe1 field value (2 dB steps)
+func pgaGainSettingFor(gainDb uint8) uint8 {
+	if gainDb > 30 {
+		gainDb = 30
+	}
+	return gainDb / 2
+}
+
+// dacGainSettingFor maps a DAC gain in dB to the RegTxfe1 field value
+func dacGainSettingFor(gainDb int8) uint8 {
+	switch gainDb {
+	case 0:
+		return DacGainMax
+	case -3:
+		return DacGainMinus3
+	case -6:
+		return DacGainMinus6
+	case -9:
+		return DacGainMinus9
+	default:
+		return DacGainMinus3 // Default to -3 dB
+	}
+}
+
+// mixerGainSettingFor maps a mixer gain in dB to the RegTxfe1 field value (clamped)
+func mixerGainSettingFor(gainDb float32) uint8 {
+	if gainDb < -37.5 {
+		gainDb = -37.5
+	}
+	if gainDb > -7.5 {
+		gainDb = -7.5
+	}
+	return uint8(math.Round(float64(gainDb+37.5)/2.0)) & 0x0F
+}
+
 // SetLNAGain sets the LNA gain (0-48 dB range)
 func (s *SX1255Controller) SetLNAGain(gainDb uint8) error {
 	if !s.initialized {
 		return fmt.Errorf("controller not initialized")
 	}
 
-	// Determine LNA gain setting based on dB value
-	var lnaGainSetting uint8
-	if gainDb > 45 {
-		lnaGainSetting = LnaGainMax // 0 dB
-	} else if gainDb > 39 {
-		lnaGainSetting = LnaGainMinus6 // -6 dB
-	} else if gainDb > 30 {
-		lnaGainSetting = LnaGainMinus12 // -12 dB
-	} else if gainDb > 18 {
-		lnaGainSetting = LnaGainMinus24 // -24 dB
-	} else if gainDb > 6 {
-		lnaGainSetting = LnaGainMinus36 // -36 dB
-	} else {
-		lnaGainSetting = LnaGainMinus48 // -48 dB
-	}
+	lnaGainSetting := lnaGainSettingFor(gainDb)
 
 	// Read current register value
 	reg, err := s.spi.ReadRegister(RegRxfe1)
@@ -359,12 +474,7 @@ func (s *SX1255Controller) SetPGAGain(gainDb uint8) error {
 		return fmt.Errorf("controller not initialized")
 	}
 
-	if gainDb > 30 {
-		gainDb = 30
-	}
-
-	// PGA gain in 2 dB steps
-	pgaGainSetting := gainDb / 2
+	pgaGainSetting := pgaGainSettingFor(gainDb)
 
 	// Read current register value
 	reg, err := s.spi.ReadRegister(RegRxfe1)
@@ -384,19 +494,7 @@ func (s *SX1255Controller) SetDACGain(gainDb int8) error {
 		return fmt.Errorf("controller not initialized")
 	}
 
-	var dacGainSetting uint8
-	switch gainDb {
-	case 0:
-		dacGainSetting = DacGainMax
-	case -3:
-		dacGainSetting = DacGainMinus3
-	case -6:
-		dacGainSetting = DacGainMinus6
-	case -9:
-		dacGainSetting = DacGainMinus9
-	default:
-		dacGainSetting = DacGainMinus3 // Default to -3 dB
-	}
+	dacGainSetting := dacGainSettingFor(gainDb)
 
 	// Read current register value
 	reg, err := s.spi.ReadRegister(RegTxfe1)
@@ -416,16 +514,7 @@ func (s *SX1255Controller) SetMixerGain(gainDb float32) error {
 		return fmt.Errorf("controller not initialized")
 	}
 
-	// Clamp to valid range
-	if gainDb < -37.5 {
-		gainDb = -37.5
-	}
-	if gainDb > -7.5 {
-		gainDb = -7.5
-	}
-
-	// Calculate mixer gain setting
-	mixerGainSetting := uint8(math.Round(float64(gainDb+37.5) / 2.0))
+	mixerGainSetting := mixerGainSettingFor(gainDb)
 
 	// Read current register value
 	reg, err := s.spi.ReadRegister(RegTxfe1)
@@ -434,7 +523,7 @@ func (s *SX1255Controller) SetMixerGain(gainDb float32) error {
 	}
 
 	// Clear mixer gain bits (3:0) and set new value
-	reg = (reg & 0xF0) | (mixerGainSetting & 0x0F)
+	reg = (reg & 0xF0) | mixerGainSetting
 
 	return s.spi.WriteRegister(RegTxfe1, reg)
 }
@@ -518,6 +607,24 @@ func (s *SX1255Controller) GetTxRxSwitch() (bool, error) {
 	return s.gpio.GetTxRxPin()
 }
 
+// SetResetPin drives the reset pin; high holds the chip in reset
+func (s *SX1255Controller) SetResetPin(high bool) error {
+	if !s.initialized {
+		return fmt.Errorf("controller not initialized")
+	}
+
+	return s.gpio.SetResetPin(high)
+}
+
+// GetResetPin reads the current reset pin state
+func (s *SX1255Controller) GetResetPin() (bool, error) {
+	if !s.initialized {
+		return false, fmt.Errorf("controller not initialized")
+	}
+
+	return s.gpio.GetResetPin()
+}
+
 // Initialize performs basic initialization without modifying the device
 // Only verifies SPI communication by reading the version register
 func (s *SX1255Controller) Initialize() error {
@@ -553,6 +660,11 @@ func (s *SX1255Controller) InitializeWithDefaults() error {
 	return nil
 }
 
+// Simulated reports whether the controller works on simulated registers (validate=true)
+func (s *SX1255Controller) Simulated() bool {
+	return s.spi != nil && s.spi.sim != nil
+}
+
 // IsInitialized returns true if the controller is initialized
 func (s *SX1255Controller) IsInitialized() bool {
 	return s.initialized
@@ -0,0 +1,130 @@
+package plugins
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Temperature measurement defaults
+const (
+	DefaultTemperatureSamples = 4096 // I/Q samples averaged per reading
+	temperatureReadTimeout    = 5 * time.Second
+)
+
+// TemperatureReading is the result of a temperature measurement
+type TemperatureReading struct {
+	Celsius    float64   `json:"celsius"`
+	Raw        float64   `json:"raw"`    // mean I level of the ADC in temperature mode
+	Offset     float64   `json:"offset"` // calibration offset in °C
+	Samples    int       `json:"samples"`
+	MeasuredAt time.Time `json:"measured_at"`
+}
+
+// readTemperature runs the SX1255 temperature measurement
+// The baseband interface is busy during a recording, so none may be running
+func (p *HardwarePlugin) readTemperature() (TemperatureReading, error) {
+	cfg := p.getConfig()
+
+	// Keep recordings from starting while the ADC is in temperature mode
+	p.captureMu.Lock()
+	defer p.captureMu.Unlock()
+	if current := p.getCapture(); current != nil && current.getStatus().Active {
+		return TemperatureReading{}, errCaptureActive
+	}
+
+	reading := TemperatureReading{
+		Offset:  cfg.Temperature.Offset,
+		Samples: cfg.Temperature.Samples,
+	}
+	err := p.withController(func(ctrl *SX1255Controller) error {
+		var err error
+		reading.Celsius, reading.Raw, err = ctrl.ReadTemperature(cfg.Temperature.Offset, func() (float64, error) {
+			return readBasebandLevel(cfg.Baseband.Device, cfg.Baseband.SampleRate, cfg.Temperature.Samples)
+		})
+		return err
+	})
+	if err != nil {
+		return TemperatureReading{}, err
+	}
+
+	reading.Celsius = math.Round(reading.Celsius*10) / 10
+	reading.MeasuredAt = time.Now().UTC()
+	return reading, nil
+}
+
+// readBasebandLevel records a short burst from the baseband interface and
+// returns the mean level of the I channel
+func readBasebandLevel(device string, sampleRate int, samples int) (float64, error) {
+	buf, err := readBaseband(device, sampleRate, samples)
+	if err != nil {
+		return 0, err
+	}
+
+	var sum float64
+	for i := 0; i < samples; i++ {
+		sum += float64(int16(binary.LittleEndian.Uint16(buf[i*captureBytesPerSample:])))
+	}
+	return sum / float64(samples), nil
+}
+
+// readBaseband records samples interleaved S16_LE I/Q frames from the baseband interface
+func readBaseband(device string, sampleRate int, samples int) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), temperatureReadTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "arecord", "-q",
+		"-D", device,
+		"-t", "raw",
+		"-f", "S16_LE",
+		"-c", "2",
+		"-r", strconv.Itoa(sampleRate),
+		"-s", strconv.Itoa(samples))
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start arecord: %w", err)
+	}
+
+	buf := make([]byte, samples*captureBytesPerSample)
+	n, readErr := io.ReadFull(stdout, buf)
+	waitErr := cmd.Wait()
+	if readErr != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("arecord: %s", msg)
+		}
+		if waitErr != nil {
+			return nil, fmt.Errorf("arecord: %w", waitErr)
+		}
+		return nil, fmt.Errorf("arecord returned %d of %d bytes", n, len(buf))
+	}
+	return buf, nil
+}
+
+// handleGetTemperature handles GET /api/hardware/temperature
+// Reads the SX1255 temperature sensor; the transceiver state is restored afterwards
+func (p *HardwarePlugin) handleGetTemperature(c *fiber.Ctx) error {
+	reading, err := p.readTemperature()
+	if err != nil {
+		if errors.Is(err, errCaptureActive) {
+			return SendError(c, 409, err)
+		}
+		slog.ErrorContext(c.UserContext(), "Temperature measurement failed", "error", err)
+		return SendError(c, 500, err)
+	}
+	return SendSuccess(c, reading, "")
+}
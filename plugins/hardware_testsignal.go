@@ -0,0 +1,559 @@
+package plugins
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Test signal types
+const (
+	TestSignalCW      = "cw"       // single tone at the carrier plus offset
+	TestSignalTwoTone = "two_tone" // two equal tones spaced around the offset
+)
+
+// Test signal defaults and limits
+const (
+	DefaultTestSignalMaxDuration  = 10   // seconds
+	DefaultTestSignalMaxLevel     = -6.0 // dBFS peak
+	DefaultTestSignalMaxMixerGain = -21.5
+	DefaultTestSignalSpacing      = 1000 // Hz between the two tones
+	MinMixerGainDb                = -37.5
+	MaxMixerGainDb                = -7.5
+	testSignalChunkFrames         = 4096
+	testSignalGrace               = 2 * time.Second // allowed for playback to drain before it is killed
+	testSignalStartedEvent        = "hardware.testsignal.started"
+	testSignalFinishedEvent       = "hardware.testsignal.finished"
+)
+
+// TestSignalRequest describes a transmit test
+type TestSignalRequest struct {
+	Type      string    `json:"type"`       // cw or two_tone
+	Duration  float64   `json:"duration"`   // seconds
+	Frequency Frequency `json:"frequency"`  // TX frequency, 0 keeps the current one
+	Offset    float64   `json:"offset"`     // Hz from the carrier
+	Spacing   float64   `json:"spacing"`    // Hz between tones for two_tone
+	Level     *float64  `json:"level"`      // dBFS peak, defaults to the configured maximum
+	MixerGain *float64  `json:"mixer_gain"` // dB, defaults to the configured maximum
+	PA        bool      `json:"pa"`         // enable the PA driver
+}
+
+// TestSignalStatus reports the current or last test signal
+type TestSignalStatus struct {
+	Active     bool       `json:"active"`
+	Type       string     `json:"type"`
+	Frequency  uint32     `json:"frequency"`
+	Offset     float64    `json:"offset"`
+	Spacing    float64    `json:"spacing,omitempty"`
+	Level      float64    `json:"level"`
+	MixerGain  float64    `json:"mixer_gain"`
+	PA         bool       `json:"pa"`
+	SampleRate int        `json:"sample_rate"`
+	Duration   float64    `json:"duration"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Stopped    bool       `json:"stopped"` // ended early by a stop request
+	Error      string     `json:"error,omitempty"`
+}
+
+// testSignalState is the transceiver state restored after a test
+type testSignalState struct {
+	mode      uint8
+	txfe1     uint8
+	frequency uint32
+	txSwitch  bool
+}
+
+// testSignalSession is a running test signal
+type testSignalSession struct {
+	mu       sync.Mutex
+	status   TestSignalStatus
+	cmd      *exec.Cmd
+	saved    testSignalState
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	done     chan struct{}
+}
+
+// getStatus returns a copy of the session status
+func (s *testSignalSession) getStatus() TestSignalStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// stop ends the test signal early and waits for the transceiver to be restored
+func (s *testSignalSession) stop() {
+	s.stopOnce.Do(func() {
+		s.mu.Lock()
+		s.status.Stopped = true
+		s.mu.Unlock()
+		close(s.stopCh)
+	})
+	<-s.done
+}
+
+// validate checks the request against the configured limits and fills in defaults
+func (r *TestSignalRequest) validate(cfg HardwareConfig) error {
+	limits := cfg.TestSignal
+	if r.Type == "" {
+		r.Type = TestSignalCW
+	}
+	r.Type = strings.ToLower(r.Type)
+	if r.Type == TestSignalTwoTone && r.Spacing == 0 {
+		r.Spacing = DefaultTestSignalSpacing
+	}
+	if r.Level == nil {
+		level := limits.MaxLevel
+		r.Level = &level
+	}
+	if r.MixerGain == nil {
+		gain := limits.MaxMixerGain
+		r.MixerGain = &gain
+	}
+
+	if r.Type != TestSignalCW && r.Type != TestSignalTwoTone {
+		return fmt.Errorf("type must be one of cw, two_tone")
+	}
+	if r.Duration <= 0 || r.Duration > float64(limits.MaxDuration) {
+		return fmt.Errorf("duration must be between 0 and %d seconds", limits.MaxDuration)
+	}
+	if r.Frequency != 0 && (r.Frequency < MinFrequencyHz || r.Frequency > MaxFrequencyHz) {
+		return fmt.Errorf("frequency must be within %d-%d Hz", MinFrequencyHz, MaxFrequencyHz)
+	}
+	if *r.Level > limits.MaxLevel {
+		return fmt.Errorf("level must not exceed %.1f dBFS", limits.MaxLevel)
+	}
+	if *r.MixerGain < MinMixerGainDb || *r.MixerGain > limits.MaxMixerGain {
+		return fmt.Errorf("mixer_gain must be between %.1f and %.1f dB", MinMixerGainDb, limits.MaxMixerGain)
+	}
+	if r.PA && !limits.AllowPA {
+		return fmt.Errorf("PA is not allowed for test signals (hardware.testsignal.allow_pa)")
+	}
+	if r.Type == TestSignalTwoTone && r.Spacing <= 0 {
+		return fmt.Errorf("spacing must be greater than 0")
+	}
+
+	nyquist := float64(cfg.Baseband.SampleRate) / 2
+	for _, tone := range r.tones() {
+		if math.Abs(tone) >= nyquist {
+			return fmt.Errorf("tone at %.0f Hz offset is outside the baseband bandwidth (±%.0f Hz)", tone, nyquist)
+		}
+	}
+	return nil
+}
+
+// tones returns the tone offsets from the carrier in Hz
+func (r *TestSignalRequest) tones() []float64 {
+	if r.Type == TestSignalTwoTone {
+		return []float64{r.Offset - r.Spacing/2, r.Offset + r.Spacing/2}
+	}
+	return []float64{r.Offset}
+}
+
+// toneGenerator produces interleaved int16 I/Q samples for a set of complex tones
+type toneGenerator struct {
+	steps     []float64 // phase increment per sample for each tone
+	amplitude float64   // per tone, so the peak of the sum matches the requested level
+	index     int64
+}
+
+// newToneGenerator creates a generator with the given peak level in dBFS
+func newToneGenerator(tones []float64, levelDb float64, sampleRate int) *toneGenerator {
+	g := &toneGenerator{
+		amplitude: 32767 * math.Pow(10, levelDb/20) / float64(len(tones)),
+	}
+	for _, tone := range tones {
+		g.steps = append(g.steps, 2*math.Pi*tone/float64(sampleRate))
+	}
+	return g
+}
+
+// fill writes the next frames into buf and returns the bytes used
+func (g *toneGenerator) fill(buf []byte, frames int) []byte {
+	for i := 0; i < frames; i++ {
+		var iSum, qSum float64
+		for _, step := range g.steps {
+			phase := math.Mod(step*float64(g.index), 2*math.Pi)
+			iSum += math.Cos(phase)
+			qSum += math.Sin(phase)
+		}
+		binary.LittleEndian.PutUint16(buf[i*4:], uint16(int16(math.Round(iSum*g.amplitude))))
+		binary.LittleEndian.PutUint16(buf[i*4+2:], uint16(int16(math.Round(qSum*g.amplitude))))
+		g.index++
+	}
+	return buf[:frames*4]
+}
+
+// errTestSignalActive is returned when a test signal is already running
+var errTestSignalActive = errors.New("a test signal is already active")
+
+// getTestSignal returns the current or last test signal session
+func (p *HardwarePlugin) getTestSignal() *testSignalSession {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.testSignal
+}
+
+// startTestSignal keys the transmitter and starts playing the test signal on the baseband interface
+func (p *HardwarePlugin) startTestSignal(req TestSignalRequest, override bool) (*testSignalSession, error) {
+	cfg := p.getConfig()
+
+	p.testSignalMu.Lock()
+	defer p.testSignalMu.Unlock()
+
+	if current := p.getTestSignal(); current != nil && current.getStatus().Active {
+		return nil, errTestSignalActive
+	}
+	if err := p.checkTxInterlocks(); err != nil {
+		return nil, err
+	}
+
+	var saved testSignalState
+	var frequency uint32
+	var keyed bool // transceiver settings may have been changed
+	err := p.withController(func(ctrl *SX1255Controller) error {
+		var err error
+		frequency, err = p.keyTestSignal(ctrl, req, override, &saved, &keyed)
+		return err
+	})
+	if err != nil {
+		if keyed {
+			p.restoreTestSignalState(saved)
+		}
+		return nil, err
+	}
+
+	cmd := exec.Command("aplay", "-q",
+		"-D", cfg.Baseband.PlaybackDevice,
+		"-t", "raw",
+		"-f", "S16_LE",
+		"-c", "2",
+		"-r", strconv.Itoa(cfg.Baseband.SampleRate))
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		p.restoreTestSignalState(saved)
+		return nil, err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		p.restoreTestSignalState(saved)
+		return nil, fmt.Errorf("failed to start aplay: %w", err)
+	}
+
+	session := &testSignalSession{
+		cmd:    cmd,
+		saved:  saved,
+		stopCh: make(chan struct{}),
+		done:   make(chan struct{}),
+		status: TestSignalStatus{
+			Active:     true,
+			Type:       req.Type,
+			Frequency:  frequency,
+			Offset:     req.Offset,
+			Spacing:    req.Spacing,
+			Level:      *req.Level,
+			MixerGain:  *req.MixerGain,
+			PA:         req.PA,
+			SampleRate: cfg.Baseband.SampleRate,
+			Duration:   req.Duration,
+			StartedAt:  time.Now().UTC(),
+		},
+	}
+
+	p.mu.Lock()
+	p.testSignal = session
+	p.mu.Unlock()
+
+	go p.runTestSignal(session, newToneGenerator(req.tones(), *req.Level, cfg.Baseband.SampleRate), stdin, &stderr)
+
+	PublishEvent(testSignalStartedEvent, hardwareMonitorEventSource, session.getStatus())
+	return session, nil
+}
+
+// keyTestSignal saves the transceiver settings into saved and keys it for the test signal
+// keyed is set once settings may have been changed; returns the TX frequency.
+func (p *HardwarePlugin) keyTestSignal(ctrl *SX1255Controller, req TestSignalRequest, override bool, saved *testSignalState, keyed *bool) (uint32, error) {
+	cfg := p.getConfig()
+	var err error
+	if saved.mode, err = ctrl.GetMode(); err != nil {
+		return 0, err
+	}
+	if saved.txfe1, err = ctrl.ReadRegister(RegTxfe1); err != nil {
+		return 0, err
+	}
+	if saved.frequency, err = ctrl.GetTxFrequency(); err != nil {
+		return 0, err
+	}
+	if saved.txSwitch, err = ctrl.GetTxRxSwitch(); err != nil {
+		return 0, err
+	}
+
+	frequency := saved.frequency
+	if req.Frequency != 0 {
+		frequency = uint32(req.Frequency)
+	}
+	band, err := cfg.BandPlan.check(frequency, override)
+	if err != nil {
+		return 0, err
+	}
+	if band != nil && band.MaxDuration > 0 && req.Duration > float64(band.MaxDuration) {
+		return 0, fmt.Errorf("%w: duration exceeds the %d s TX limit of band %q", errBandPlan, band.MaxDuration, band.Name)
+	}
+
+	*keyed = true
+	if req.Frequency != 0 {
+		if err := ctrl.SetTxFrequency(uint32(req.Frequency)); err != nil {
+			return 0, err
+		}
+	}
+	// DAC at its -3 dB default; output power is set by the mixer gain and the sample level
+	txfe1 := (saved.txfe1 & 0x80) | (DacGainMinus3 << 4) | mixerGainSettingFor(float32(*req.MixerGain))
+	if err := ctrl.WriteRegister(RegTxfe1, txfe1); err != nil {
+		return 0, err
+	}
+	mode := saved.mode | ModeBitRefEnable | ModeBitTxEnable
+	if req.PA {
+		mode |= ModeBitDriverEnable
+	} else {
+		mode &= ^uint8(ModeBitDriverEnable)
+	}
+	tx := true
+	return frequency, applyModeAndSwitch(ctrl, &mode, &tx, cfg.Sequencing)
+}
+
+// previewTestSignal runs the checks and keying of a test signal on simulated registers
+func (p *HardwarePlugin) previewTestSignal(c *fiber.Ctx, req TestSignalRequest, override bool) (uint32, error) {
+	if current := p.getTestSignal(); current != nil && current.getStatus().Active {
+		return 0, errTestSignalActive
+	}
+	if err := p.checkTxInterlocks(); err != nil {
+		return 0, err
+	}
+	var frequency uint32
+	err := p.withRequestController(c, func(ctrl *SX1255Controller) error {
+		var saved testSignalState
+		var keyed bool
+		var err error
+		frequency, err = p.keyTestSignal(ctrl, req, override, &saved, &keyed)
+		return err
+	})
+	return frequency, err
+}
+
+// runTestSignal feeds samples until the duration is reached, then unkeys the transmitter
+func (p *HardwarePlugin) runTestSignal(session *testSignalSession, gen *toneGenerator, stdin io.WriteCloser, stderr *strings.Builder) {
+	defer close(session.done)
+
+	status := session.getStatus()
+	target := int64(math.Round(status.Duration * float64(status.SampleRate)))
+
+	// Hard limit in case playback blocks; the transmitter must never outlive the configured duration
+	limit := time.Duration(status.Duration*float64(time.Second)) + testSignalGrace
+	killTimer := time.AfterFunc(limit, func() {
+		session.cmd.Process.Kill()
+	})
+
+	writer := bufio.NewWriter(stdin)
+	buf := make([]byte, testSignalChunkFrames*4)
+	var sent int64
+	var signalErr error
+loop:
+	for sent < target {
+		select {
+		case <-session.stopCh:
+			break loop
+		default:
+		}
+		if MaintenanceMode() {
+			signalErr = ErrMaintenanceMode
+			break
+		}
+
+		frames := int64(testSignalChunkFrames)
+		if remaining := target - sent; remaining < frames {
+			frames = remaining
+		}
+		if _, err := writer.Write(gen.fill(buf, int(frames))); err != nil {
+			signalErr = err
+			break
+		}
+		sent += frames
+	}
+
+	if signalErr == nil && sent >= target {
+		// Let aplay drain its buffer and exit on EOF
+		if err := writer.Flush(); err != nil {
+			signalErr = err
+		}
+		stdin.Close()
+		waitErr := session.cmd.Wait()
+		if waitErr != nil && signalErr == nil {
+			signalErr = fmt.Errorf("baseband playback failed: %s", strings.TrimSpace(stderr.String()))
+		}
+	} else {
+		session.cmd.Process.Kill()
+		stdin.Close()
+		session.cmd.Wait()
+	}
+	killTimer.Stop()
+
+	if err := p.restoreTestSignalState(session.saved); err != nil && signalErr == nil {
+		signalErr = fmt.Errorf("failed to restore transceiver state: %w", err)
+	}
+
+	session.mu.Lock()
+	now := time.Now().UTC()
+	session.status.Active = false
+	session.status.FinishedAt = &now
+	if signalErr != nil {
+		session.status.Error = signalErr.Error()
+	}
+	session.mu.Unlock()
+
+	final := session.getStatus()
+	if final.Error != "" {
+		slog.Error("Test signal failed", "type", final.Type, "frequency", final.Frequency, "error", final.Error)
+	} else {
+		slog.Info("Test signal finished", "type", final.Type, "frequency", final.Frequency, "stopped", final.Stopped)
+	}
+	PublishEvent(testSignalFinishedEvent, hardwareMonitorEventSource, final)
+}
+
+// restoreTestSignalState unkeys the transmitter and restores the saved settings
+// The mode is restored first so the PA is off before the switch returns to RX
+func (p *HardwarePlugin) restoreTestSignalState(saved testSignalState) error {
+	seq := p.getConfig().Sequencing
+	return p.withController(func(ctrl *SX1255Controller) error {
+		var errs []error
+		if err := applyModeAndSwitch(ctrl, &saved.mode, &saved.txSwitch, seq); err != nil {
+			errs = append(errs, err)
+		}
+		if err := ctrl.WriteRegister(RegTxfe1, saved.txfe1); err != nil {
+			errs = append(errs, err)
+		}
+		if saved.frequency != 0 {
+			if err := ctrl.SetTxFrequency(saved.frequency); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// stopTestSignal ends a running test signal and waits for the transceiver to be restored
+func (p *HardwarePlugin) stopTestSignal() (*testSignalSession, bool) {
+	session := p.getTestSignal()
+	if session == nil || !session.getStatus().Active {
+		return nil, false
+	}
+	session.stop()
+	return session, true
+}
+
+// handleTestSignal handles POST /api/hardware/testsignal
+// Transmits a CW or two-tone signal for antenna and VSWR testing within the configured limits
+func (p *HardwarePlugin) handleTestSignal(c *fiber.Ctx) error {
+	var req TestSignalRequest
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	if err := checkTxAllowed(); err != nil {
+		return SendError(c, 423, err)
+	}
+	if err := req.validate(p.getConfig()); err != nil {
+		return SendError(c, 400, err)
+	}
+	override, err := p.bandPlanOverride(c)
+	if err != nil {
+		return SendError(c, 403, err)
+	}
+
+	if dryRun(c) != nil {
+		frequency, err := p.previewTestSignal(c, req, override)
+		if err != nil {
+			return sendTestSignalError(c, req, err)
+		}
+		return SendSuccess(c, fiber.Map{"frequency": frequency, "request": req}, "")
+	}
+
+	session, err := p.startTestSignal(req, override)
+	if err != nil {
+		return sendTestSignalError(c, req, err)
+	}
+
+	status := session.getStatus()
+	slog.InfoContext(c.UserContext(), "Test signal started",
+		"type", status.Type,
+		"frequency", status.Frequency,
+		"offset", status.Offset,
+		"level", status.Level,
+		"mixer_gain", status.MixerGain,
+		"pa", status.PA,
+		"duration", status.Duration)
+	return SendSuccess(c, status, "Test signal started")
+}
+
+// sendTestSignalError maps a refused test signal to 409 for a busy transmitter or
+// interlock, 403 for the band plan and 500 otherwise
+func sendTestSignalError(c *fiber.Ctx, req TestSignalRequest, err error) error {
+	if errors.Is(err, errTestSignalActive) || errors.Is(err, errPABias) || errors.Is(err, errVSWRTrip) ||
+		errors.Is(err, errDutyCycle) {
+		return SendError(c, 409, err)
+	}
+	if errors.Is(err, errBandPlan) {
+		return SendError(c, 403, err)
+	}
+	slog.ErrorContext(c.UserContext(), "Failed to start test signal", "type", req.Type, "error", err)
+	return SendError(c, 500, err)
+}
+
+// handleTestSignalStop handles POST /api/hardware/testsignal/stop
+func (p *HardwarePlugin) handleTestSignalStop(c *fiber.Ctx) error {
+	if dryRun(c) != nil {
+		if current := p.getTestSignal(); current != nil && current.getStatus().Active {
+			return SendSuccess(c, current.getStatus(), "")
+		}
+		return SendErrorMessage(c, 404, "No test signal active")
+	}
+	session, ok := p.stopTestSignal()
+	if !ok {
+		return SendErrorMessage(c, 404, "No test signal active")
+	}
+
+	slog.InfoContext(c.UserContext(), "Test signal stopped", "type", session.getStatus().Type)
+	return SendSuccess(c, session.getStatus(), "Test signal stopped")
+}
+
+// handleTestSignalStatus handles GET /api/hardware/testsignal
+// Reports the active test signal, or the last one when idle, together with the configured limits
+func (p *HardwarePlugin) handleTestSignalStatus(c *fiber.Ctx) error {
+	limits := p.getConfig().TestSignal
+	data := fiber.Map{
+		"active": false,
+		"limits": fiber.Map{
+			"max_duration":   limits.MaxDuration,
+			"max_level":      limits.MaxLevel,
+			"max_mixer_gain": limits.MaxMixerGain,
+			"allow_pa":       limits.AllowPA,
+		},
+	}
+	if session := p.getTestSignal(); session != nil {
+		status := session.getStatus()
+		data["active"] = status.Active
+		data["signal"] = status
+	}
+	return SendSuccess(c, data, "")
+}
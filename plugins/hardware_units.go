@@ -0,0 +1,332 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// errFrequency is wrapped by frequencies and channels that cannot be resolved
+var errFrequency = errors.New("invalid frequency")
+
+// frequencyUnits maps the accepted unit suffixes (lower case) to their multiplier
+var frequencyUnits = map[string]float64{
+	"":    1,
+	"hz":  1,
+	"k":   1e3,
+	"khz": 1e3,
+	"m":   1e6,
+	"mhz": 1e6,
+	"g":   1e9,
+	"ghz": 1e9,
+}
+
+// Frequency is a frequency in Hz
+// JSON and YAML accept a number of Hz or a string with a unit ("433.5 MHz", "435000 kHz").
+type Frequency uint32
+
+// ParseFrequency parses a frequency with an optional Hz, kHz, MHz or GHz unit
+func ParseFrequency(s string) (uint32, error) {
+	s = strings.TrimSpace(s)
+	split := strings.LastIndexAny(s, "0123456789.") + 1
+	number := strings.TrimSpace(s[:split])
+	unit := strings.ToLower(strings.TrimSpace(s[split:]))
+
+	multiplier, ok := frequencyUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("%w %q: unknown unit %q (use Hz, kHz, MHz or GHz)", errFrequency, s, s[split:])
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("%w %q", errFrequency, s)
+	}
+	hz := math.Round(value * multiplier)
+	if hz > math.MaxUint32 {
+		return 0, fmt.Errorf("%w %q: too large", errFrequency, s)
+	}
+	return uint32(hz), nil
+}
+
+// UnmarshalJSON accepts a whole number of Hz or a string with a unit
+func (f *Frequency) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("%w: expected a number of Hz or a string with a unit", errFrequency)
+		}
+		s = n.String()
+		if strings.ContainsAny(s, ".eE") {
+			return fmt.Errorf("%w %s: numbers are Hz; add a unit for fractions (\"%s MHz\")", errFrequency, s, s)
+		}
+	}
+	hz, err := ParseFrequency(s)
+	if err != nil {
+		return err
+	}
+	*f = Frequency(hz)
+	return nil
+}
+
+// UnmarshalYAML accepts a number of Hz or a string with a unit
+func (f *Frequency) UnmarshalYAML(node *yaml.Node) error {
+	hz, err := ParseFrequency(node.Value)
+	if err != nil {
+		return err
+	}
+	*f = Frequency(hz)
+	return nil
+}
+
+// MarshalYAML writes the frequency with its unit, so files stay readable
+func (f Frequency) MarshalYAML() (interface{}, error) {
+	return f.String(), nil
+}
+
+// String formats the frequency like FormatFrequency
+func (f Frequency) String() string {
+	return FormatFrequency(uint32(f))
+}
+
+// FormatFrequency formats a frequency for display: MHz with at least three and up
+// to six decimals, kHz and Hz below 1 MHz
+func FormatFrequency(hz uint32) string {
+	switch {
+	case hz >= 1000000:
+		text := strings.TrimRight(fmt.Sprintf("%d.%06d", hz/1000000, hz%1000000), "0")
+		if decimals := len(text) - strings.IndexByte(text, '.') - 1; decimals < 3 {
+			text += strings.Repeat("0", 3-decimals)
+		}
+		return text + " MHz"
+	case hz >= 1000:
+		return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%d.%03d", hz/1000, hz%1000), "0"), ".") + " kHz"
+	default:
+		return fmt.Sprintf("%d Hz", hz)
+	}
+}
+
+// ChannelPlanConfig is a raster of evenly spaced channels for tuning by channel number
+// Channel First is at Base; the following ones are Spacing apart.
+type ChannelPlanConfig struct {
+	Base     Frequency `yaml:"base" json:"base"`         // frequency of the first channel
+	Spacing  Frequency `yaml:"spacing" json:"spacing"`   // channel spacing, 0 disables the plan
+	First    int       `yaml:"first" json:"first"`       // number of the first channel
+	Channels int       `yaml:"channels" json:"channels"` // number of channels, 0 = up to the end of the tuning range
+}
+
+// enabled reports whether a channel plan is configured
+func (cp ChannelPlanConfig) enabled() bool {
+	return cp.Spacing != 0
+}
+
+// validate checks that the plan lies within the tuning range
+func (cp ChannelPlanConfig) validate() error {
+	if !cp.enabled() {
+		return nil
+	}
+	if cp.Base < MinFrequencyHz || cp.Base > MaxFrequencyHz {
+		return fmt.Errorf("hardware.channel_plan: base must be within %d-%d Hz", MinFrequencyHz, MaxFrequencyHz)
+	}
+	if cp.Channels < 0 {
+		return fmt.Errorf("hardware.channel_plan: channels must not be negative")
+	}
+	if cp.Channels > 0 && uint64(cp.Base)+uint64(cp.Channels-1)*uint64(cp.Spacing) > MaxFrequencyHz {
+		return fmt.Errorf("hardware.channel_plan: the last channel is above %d Hz", MaxFrequencyHz)
+	}
+	return nil
+}
+
+// last returns the number of the highest channel
+func (cp ChannelPlanConfig) last() int {
+	if cp.Channels > 0 {
+		return cp.First + cp.Channels - 1
+	}
+	return cp.First + int((MaxFrequencyHz-uint32(cp.Base))/uint32(cp.Spacing))
+}
+
+// frequency returns the frequency of a channel
+func (cp ChannelPlanConfig) frequency(channel int) (uint32, error) {
+	if !cp.enabled() {
+		return 0, fmt.Errorf("%w: no channel plan configured (hardware.channel_plan)", errFrequency)
+	}
+	if channel < cp.First || channel > cp.last() {
+		return 0, fmt.Errorf("%w: channel %d is outside the plan (%d-%d)", errFrequency, channel, cp.First, cp.last())
+	}
+	return uint32(cp.Base) + uint32(channel-cp.First)*uint32(cp.Spacing), nil
+}
+
+// channel returns the channel a frequency is tuned to, false when it is off the raster
+func (cp ChannelPlanConfig) channel(freq uint32) (int, bool) {
+	if !cp.enabled() || freq < uint32(cp.Base) || (freq-uint32(cp.Base))%uint32(cp.Spacing) != 0 {
+		return 0, false
+	}
+	channel := cp.First + int((freq-uint32(cp.Base))/uint32(cp.Spacing))
+	return channel, channel <= cp.last()
+}
+
+// frequencyView formats a frequency for the API: Hz, display text and channel number
+func (cp ChannelPlanConfig) frequencyView(freq uint32) map[string]interface{} {
+	view := map[string]interface{}{
+		"frequency": freq,
+		"formatted": FormatFrequency(freq),
+	}
+	if channel, ok := cp.channel(freq); ok {
+		view["channel"] = channel
+	}
+	return view
+}
+
+// FrequencyRequest selects a frequency directly or by channel number
+type FrequencyRequest struct {
+	Frequency *Frequency `json:"frequency"`
+	Channel   *int       `json:"channel"`
+}
+
+// resolve returns the requested frequency
+func (r FrequencyRequest) resolve(cp ChannelPlanConfig) (uint32, error) {
+	switch {
+	case r.Frequency != nil && r.Channel != nil:
+		return 0, fmt.Errorf("%w: give either frequency or channel", errFrequency)
+	case r.Channel != nil:
+		return cp.frequency(*r.Channel)
+	case r.Frequency != nil:
+		return uint32(*r.Frequency), nil
+	}
+	return 0, fmt.Errorf("%w: frequency or channel is required", errFrequency)
+}
+
+// sendFrequencyError maps unresolvable frequencies to 400 and the rest like sendTxError
+func sendFrequencyError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errFrequency) {
+		return SendError(c, 400, err)
+	}
+	return sendTxError(c, err)
+}
+
+// tune sets the RX or TX frequency to the one next returns for the transceiver
+// A TX frequency must pass the band plan; the TX time limit follows the new band.
+func (p *HardwarePlugin) tune(c *fiber.Ctx, tx, override bool, next func(*SX1255Controller) (uint32, error)) (uint32, error) {
+	cfg := p.getConfig()
+	var freq uint32
+	err := p.withRequestController(c, func(ctrl *SX1255Controller) error {
+		var err error
+		if freq, err = next(ctrl); err != nil {
+			return err
+		}
+		if !tx {
+			return ctrl.SetRxFrequency(freq)
+		}
+		band, err := cfg.BandPlan.check(freq, override)
+		if err != nil {
+			return err
+		}
+		if err := ctrl.SetTxFrequency(freq); err != nil {
+			return err
+		}
+		if ctrl.Simulated() {
+			return nil
+		}
+		mode, err := ctrl.GetMode()
+		if err != nil {
+			return err
+		}
+		p.updateTxTimeout(mode, band, override)
+		return nil
+	})
+	return freq, err
+}
+
+// handleStepFrequency handles POST /api/hardware/frequency/{rx,tx}/step {"steps": -1, "step": "25 kHz"}
+// Moves the frequency by a number of steps, of the channel plan spacing unless step is given
+func (p *HardwarePlugin) handleStepFrequency(tx bool) fiber.Handler {
+	path := "RX"
+	if tx {
+		path = "TX"
+	}
+	return func(c *fiber.Ctx) error {
+		var req struct {
+			Steps int        `json:"steps"`
+			Step  *Frequency `json:"step"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return SendErrorMessage(c, 400, "Invalid request body: "+err.Error())
+		}
+		plan := p.getConfig().ChannelPlan
+		step := int64(plan.Spacing)
+		if req.Step != nil {
+			step = int64(*req.Step)
+		}
+		if step == 0 {
+			return SendErrorMessage(c, 400, "step is required without a channel plan (hardware.channel_plan)")
+		}
+		override := false
+		if tx {
+			var err error
+			if override, err = p.bandPlanOverride(c); err != nil {
+				return SendError(c, 403, err)
+			}
+		}
+
+		freq, err := p.tune(c, tx, override, func(ctrl *SX1255Controller) (uint32, error) {
+			get := ctrl.GetRxFrequency
+			if tx {
+				get = ctrl.GetTxFrequency
+			}
+			current, err := get()
+			if err != nil {
+				return 0, err
+			}
+			next := int64(current) + int64(req.Steps)*step
+			if next < MinFrequencyHz || next > MaxFrequencyHz {
+				return 0, fmt.Errorf("%w: stepping %s by %d leaves the tuning range", errFrequency, FormatFrequency(current), req.Steps)
+			}
+			return uint32(next), nil
+		})
+		if err != nil {
+			return sendFrequencyError(c, err)
+		}
+
+		slog.InfoContext(c.UserContext(), path+" frequency stepped", "frequency", freq, "steps", req.Steps)
+		return SendSuccess(c, plan.frequencyView(freq), path+" frequency set to "+FormatFrequency(freq))
+	}
+}
+
+// handleConvertFrequency handles GET /api/hardware/frequency/convert?frequency=433.5MHz or ?channel=12
+// Converts between units and channel numbers without touching the transceiver
+func (p *HardwarePlugin) handleConvertFrequency(c *fiber.Ctx) error {
+	plan := p.getConfig().ChannelPlan
+	var req FrequencyRequest
+	if value := c.Query("frequency"); value != "" {
+		hz, err := ParseFrequency(value)
+		if err != nil {
+			return SendError(c, 400, err)
+		}
+		f := Frequency(hz)
+		req.Frequency = &f
+	}
+	if value := c.Query("channel"); value != "" {
+		channel, err := strconv.Atoi(value)
+		if err != nil {
+			return SendErrorMessage(c, 400, "channel must be a number")
+		}
+		req.Channel = &channel
+	}
+	freq, err := req.resolve(plan)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+
+	result := plan.frequencyView(freq)
+	result["in_range"] = freq >= MinFrequencyHz && freq <= MaxFrequencyHz
+	if plan.enabled() {
+		result["channel_plan"] = plan
+	}
+	return SendSuccess(c, result, "")
+}
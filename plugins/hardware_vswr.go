@@ -0,0 +1,518 @@
+package plugins
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"periph.io/x/conn/v3/i2c"
+	"periph.io/x/conn/v3/i2c/i2creg"
+)
+
+// Power sensor types
+const (
+	VSWRSourceIIO     = "iio"     // Linux IIO ADC channels
+	VSWRSourceADS1115 = "ads1115" // 16-bit I2C ADC
+)
+
+// VSWR monitor defaults
+const (
+	DefaultVSWRInterval    = 200 // milliseconds
+	DefaultVSWRIIODevice   = "iio:device0"
+	DefaultVSWRI2CAddress  = 0x48
+	DefaultVSWRMinForward  = 20.0 // dBm
+	maxReportedVSWR        = 99.9 // reported when the reflected power reaches the forward power
+	vswrTripEvent          = "hardware.vswr.trip"
+	vswrClearedEvent       = "hardware.vswr.cleared"
+	ads1115ConversionDelay = 2 * time.Millisecond // one conversion at 860 SPS
+)
+
+// errVSWRTrip is returned while the VSWR protection inhibits keying
+var errVSWRTrip = errors.New("VSWR protection")
+
+// VSWRChannel converts a coupler detector voltage to power
+// Log detectors are linear in dB: dBm = volts / slope + intercept + coupling
+type VSWRChannel struct {
+	Channel   int     `yaml:"channel"`   // ADC channel (IIO in_voltage<N> or ADS1115 AIN<N>)
+	Slope     float64 `yaml:"slope"`     // detector slope in volts per dB
+	Intercept float64 `yaml:"intercept"` // dBm at the detector input for 0 V
+	Coupling  float64 `yaml:"coupling"`  // coupler factor in dB added to the detector power
+}
+
+// dBm converts a detector voltage to power at the coupler
+func (ch VSWRChannel) dBm(volts float64) float64 {
+	return volts/ch.Slope + ch.Intercept + ch.Coupling
+}
+
+// VSWRConfig holds the directional coupler sampling and protection settings
+type VSWRConfig struct {
+	Source     string      `yaml:"source"`      // "" disables, iio or ads1115
+	IIODevice  string      `yaml:"iio_device"`  // name under /sys/bus/iio/devices or an absolute path
+	I2CBus     string      `yaml:"i2c_bus"`     // ads1115: periph.io bus name
+	I2CAddress uint16      `yaml:"i2c_address"` // ads1115: ADC address (default 0x48)
+	Forward    VSWRChannel `yaml:"forward"`
+	Reflected  VSWRChannel `yaml:"reflected"`
+	Interval   int         `yaml:"interval"`    // milliseconds between samples
+	MaxVSWR    float64     `yaml:"max_vswr"`    // trips above this ratio, 0 only measures
+	MinForward float64     `yaml:"min_forward"` // dBm of forward power below which VSWR is not evaluated
+}
+
+// validate checks the sensor and protection settings
+func (cfg VSWRConfig) validate() error {
+	switch cfg.Source {
+	case "":
+		return nil
+	case VSWRSourceIIO:
+	case VSWRSourceADS1115:
+		if cfg.I2CBus == "" {
+			return fmt.Errorf("hardware.vswr.i2c_bus is required for %s", cfg.Source)
+		}
+		if cfg.Forward.Channel > 3 || cfg.Reflected.Channel > 3 {
+			return fmt.Errorf("hardware.vswr: %s channels must be 0 to 3", cfg.Source)
+		}
+	default:
+		return fmt.Errorf("hardware.vswr.source must be %s or %s", VSWRSourceIIO, VSWRSourceADS1115)
+	}
+	if cfg.Forward.Channel < 0 || cfg.Reflected.Channel < 0 {
+		return fmt.Errorf("hardware.vswr: channels must not be negative")
+	}
+	if cfg.Forward.Slope <= 0 || cfg.Reflected.Slope <= 0 {
+		return fmt.Errorf("hardware.vswr: forward.slope and reflected.slope must be positive")
+	}
+	if cfg.MaxVSWR != 0 && cfg.MaxVSWR <= 1 {
+		return fmt.Errorf("hardware.vswr.max_vswr must be above 1 (or 0 to only measure)")
+	}
+	return nil
+}
+
+// applyVSWRDefaults fills in unset sampling settings
+func applyVSWRDefaults(cfg *VSWRConfig) {
+	if cfg.IIODevice == "" {
+		cfg.IIODevice = DefaultVSWRIIODevice
+	}
+	if cfg.I2CAddress == 0 {
+		cfg.I2CAddress = DefaultVSWRI2CAddress
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultVSWRInterval
+	}
+	if cfg.MinForward == 0 {
+		cfg.MinForward = DefaultVSWRMinForward
+	}
+}
+
+// powerSensor reads detector voltages
+type powerSensor interface {
+	volts(channel int) (float64, error)
+	Close() error
+}
+
+// openPowerSensor opens the configured ADC
+func openPowerSensor(cfg VSWRConfig) (powerSensor, error) {
+	switch cfg.Source {
+	case VSWRSourceIIO:
+		dir := cfg.IIODevice
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join("/sys/bus/iio/devices", dir)
+		}
+		return &iioADC{dir: dir}, nil
+	case VSWRSourceADS1115:
+		return openADS1115(cfg.I2CBus, cfg.I2CAddress)
+	default:
+		return nil, fmt.Errorf("power sensor is not configured (hardware.vswr.source)")
+	}
+}
+
+// iioADC reads voltage channels of a Linux IIO device
+type iioADC struct {
+	dir string
+}
+
+// readFloat reads a numeric sysfs attribute
+func (a *iioADC) readFloat(name string) (float64, error) {
+	data, err := os.ReadFile(filepath.Join(a.dir, name))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+}
+
+// volts returns raw * scale; IIO scales voltages to millivolts
+func (a *iioADC) volts(channel int) (float64, error) {
+	raw, err := a.readFloat(fmt.Sprintf("in_voltage%d_raw", channel))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read ADC channel %d: %w", channel, err)
+	}
+	scale, err := a.readFloat(fmt.Sprintf("in_voltage%d_scale", channel))
+	if err != nil {
+		if scale, err = a.readFloat("in_voltage_scale"); err != nil {
+			return 0, fmt.Errorf("failed to read ADC scale: %w", err)
+		}
+	}
+	return raw * scale / 1000, nil
+}
+
+func (a *iioADC) Close() error {
+	return nil
+}
+
+// ads1115 is a 16-bit I2C ADC read in single-shot mode
+type ads1115 struct {
+	bus i2c.BusCloser
+	dev *i2c.Dev
+}
+
+// openADS1115 opens the ADC on an I2C bus
+func openADS1115(bus string, addr uint16) (*ads1115, error) {
+	if err := initPeriph(); err != nil {
+		return nil, fmt.Errorf("failed to initialize periph.io: %w", err)
+	}
+	b, err := i2creg.Open(bus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open I2C bus %s: %w", bus, err)
+	}
+	return &ads1115{bus: b, dev: &i2c.Dev{Bus: b, Addr: addr}}, nil
+}
+
+// volts converts AIN<channel> against GND with the ±4.096 V range at 860 SPS
+func (a *ads1115) volts(channel int) (float64, error) {
+	config := uint16(0x8000) | // start a single conversion
+		uint16(0x4+channel)<<12 | // AINx vs GND
+		0x1<<9 | // ±4.096 V
+		0x0100 | // single-shot
+		0x7<<5 | // 860 SPS
+		0x0003 // comparator disabled
+	if err := a.dev.Tx([]byte{0x01, byte(config >> 8), byte(config)}, nil); err != nil {
+		return 0, fmt.Errorf("ADC write failed: %w", err)
+	}
+	time.Sleep(ads1115ConversionDelay)
+
+	buf := make([]byte, 2)
+	if err := a.dev.Tx([]byte{0x00}, buf); err != nil {
+		return 0, fmt.Errorf("ADC read failed: %w", err)
+	}
+	return float64(int16(binary.BigEndian.Uint16(buf))) * 4.096 / 32768, nil
+}
+
+func (a *ads1115) Close() error {
+	return a.bus.Close()
+}
+
+// VSWRReading is one sample of the directional coupler
+type VSWRReading struct {
+	Time           time.Time `json:"time"`
+	ForwardDBm     float64   `json:"forward_dbm"`
+	ForwardW       float64   `json:"forward_w"`
+	ReflectedDBm   float64   `json:"reflected_dbm"`
+	ReflectedW     float64   `json:"reflected_w"`
+	ReturnLoss     float64   `json:"return_loss"`   // dB
+	VSWR           float64   `json:"vswr"`          // capped at 99.9
+	Evaluated      bool      `json:"evaluated"`     // forward power was above min_forward
+	ForwardVolts   float64   `json:"forward_volts"` // detector voltages for calibration
+	ReflectedVolts float64   `json:"reflected_volts"`
+}
+
+// VSWRTrip records why the transmitter was inhibited
+type VSWRTrip struct {
+	Time    time.Time   `json:"time"`
+	Reading VSWRReading `json:"reading"`
+	MaxVSWR float64     `json:"max_vswr"`
+	Unkeyed bool        `json:"unkeyed"` // the transmitter was switched off
+	Error   string      `json:"error,omitempty"`
+}
+
+// VSWRStatus is returned by GET /api/hardware/vswr
+type VSWRStatus struct {
+	Source   string       `json:"source"`
+	MaxVSWR  float64      `json:"max_vswr"`
+	Reading  *VSWRReading `json:"reading,omitempty"`
+	Peak     *VSWRReading `json:"peak,omitempty"` // highest evaluated VSWR since the last clear
+	Tripped  bool         `json:"tripped"`
+	Trip     *VSWRTrip    `json:"trip,omitempty"`
+	Error    string       `json:"error,omitempty"`
+	Interval int          `json:"interval"`
+}
+
+// dBmToWatts converts a power level
+func dBmToWatts(dbm float64) float64 {
+	return math.Pow(10, (dbm-30)/10)
+}
+
+// newVSWRReading computes the power figures for a pair of detector voltages
+func newVSWRReading(cfg VSWRConfig, forwardVolts, reflectedVolts float64) VSWRReading {
+	reading := VSWRReading{
+		Time:           time.Now(),
+		ForwardVolts:   forwardVolts,
+		ReflectedVolts: reflectedVolts,
+		ForwardDBm:     cfg.Forward.dBm(forwardVolts),
+		ReflectedDBm:   cfg.Reflected.dBm(reflectedVolts),
+	}
+	reading.ForwardW = dBmToWatts(reading.ForwardDBm)
+	reading.ReflectedW = dBmToWatts(reading.ReflectedDBm)
+	reading.ReturnLoss = reading.ForwardDBm - reading.ReflectedDBm
+	reading.Evaluated = reading.ForwardDBm >= cfg.MinForward
+
+	reading.VSWR = maxReportedVSWR
+	if gamma := math.Sqrt(reading.ReflectedW / reading.ForwardW); gamma < 1 {
+		reading.VSWR = math.Min(maxReportedVSWR, (1+gamma)/(1-gamma))
+	}
+	return reading
+}
+
+// vswrMonitor samples the coupler and trips the protection
+type vswrMonitor struct {
+	plugin   *HardwarePlugin
+	config   VSWRConfig
+	stopChan chan struct{}
+	doneChan chan struct{}
+
+	mu      sync.Mutex
+	reading *VSWRReading
+	peak    *VSWRReading
+	err     string
+}
+
+// newVSWRMonitor creates a monitor for a sensor configuration
+func newVSWRMonitor(plugin *HardwarePlugin, cfg VSWRConfig) *vswrMonitor {
+	return &vswrMonitor{
+		plugin:   plugin,
+		config:   cfg,
+		stopChan: make(chan struct{}),
+		doneChan: make(chan struct{}),
+	}
+}
+
+// Start samples in a goroutine, keeping the sensor open between samples
+func (m *vswrMonitor) Start() {
+	slog.Info("VSWR monitor started", "source", m.config.Source, "interval", m.config.Interval, "max_vswr", m.config.MaxVSWR)
+
+	go func() {
+		defer close(m.doneChan)
+
+		var sensor powerSensor
+		defer func() {
+			if sensor != nil {
+				sensor.Close()
+			}
+		}()
+
+		ticker := time.NewTicker(time.Duration(m.config.Interval) * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.stopChan:
+				return
+			case <-ticker.C:
+			}
+
+			if sensor == nil {
+				var err error
+				if sensor, err = openPowerSensor(m.config); err != nil {
+					m.setError(err)
+					continue
+				}
+			}
+			if err := m.sample(sensor); err != nil {
+				// Reopen on the next tick, e.g. after an I2C bus error
+				m.setError(err)
+				sensor.Close()
+				sensor = nil
+			}
+		}
+	}()
+}
+
+// Stop terminates the sampling loop and waits for it to exit
+func (m *vswrMonitor) Stop() {
+	close(m.stopChan)
+	<-m.doneChan
+	slog.Info("VSWR monitor stopped")
+}
+
+// setError records a sensor failure, logging only the first of a series
+func (m *vswrMonitor) setError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err == "" {
+		slog.Warn("VSWR sensor read failed", "source", m.config.Source, "error", err)
+	}
+	m.err = err.Error()
+}
+
+// sample reads both detectors and trips the protection on high VSWR
+func (m *vswrMonitor) sample(sensor powerSensor) error {
+	forward, err := sensor.volts(m.config.Forward.Channel)
+	if err != nil {
+		return err
+	}
+	reflected, err := sensor.volts(m.config.Reflected.Channel)
+	if err != nil {
+		return err
+	}
+	reading := newVSWRReading(m.config, forward, reflected)
+
+	m.mu.Lock()
+	m.reading = &reading
+	m.err = ""
+	if reading.Evaluated && (m.peak == nil || reading.VSWR > m.peak.VSWR) {
+		m.peak = &reading
+	}
+	m.mu.Unlock()
+
+	if m.config.MaxVSWR > 0 && reading.Evaluated && reading.VSWR > m.config.MaxVSWR {
+		m.plugin.tripVSWR(reading, m.config.MaxVSWR)
+	}
+	return nil
+}
+
+// snapshot returns the latest and peak readings and the sensor error
+func (m *vswrMonitor) snapshot() (reading, peak *VSWRReading, errText string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reading, m.peak, m.err
+}
+
+// clearPeak forgets the highest reading
+func (m *vswrMonitor) clearPeak() {
+	m.mu.Lock()
+	m.peak = nil
+	m.mu.Unlock()
+}
+
+// startVSWRMonitor starts sampling when a sensor is configured
+func (p *HardwarePlugin) startVSWRMonitor(cfg HardwareConfig) {
+	if cfg.VSWR.Source == "" {
+		return
+	}
+	monitor := newVSWRMonitor(p, cfg.VSWR)
+	p.mu.Lock()
+	p.vswr = monitor
+	p.mu.Unlock()
+	monitor.Start()
+}
+
+// stopVSWRMonitor stops sampling if it is running
+func (p *HardwarePlugin) stopVSWRMonitor() {
+	p.mu.Lock()
+	monitor := p.vswr
+	p.vswr = nil
+	p.mu.Unlock()
+
+	if monitor != nil {
+		monitor.Stop()
+	}
+}
+
+// tripVSWR latches the TX inhibit and unkeys the transmitter
+// The inhibit stays until it is cleared through the API, so a reconnected
+// antenna is checked by someone before the next transmission.
+func (p *HardwarePlugin) tripVSWR(reading VSWRReading, maxVSWR float64) {
+	p.mu.Lock()
+	if p.vswrTrip != nil {
+		p.mu.Unlock()
+		return
+	}
+	trip := &VSWRTrip{Time: time.Now(), Reading: reading, MaxVSWR: maxVSWR}
+	p.vswrTrip = trip
+	p.mu.Unlock()
+
+	p.stopTestSignal()
+	seq := p.getConfig().Sequencing
+	err := p.withController(func(ctrl *SX1255Controller) error {
+		mode, err := ctrl.GetMode()
+		if err != nil {
+			return err
+		}
+		mode &^= ModeBitTxEnable | ModeBitDriverEnable
+		rx := false
+		return applyModeAndSwitch(ctrl, &mode, &rx, seq)
+	})
+
+	p.mu.Lock()
+	trip.Unkeyed = err == nil
+	if err != nil {
+		trip.Error = err.Error()
+	}
+	event := *trip
+	p.mu.Unlock()
+
+	if err != nil {
+		slog.Error("VSWR protection tripped but the transmitter could not be unkeyed", "vswr", reading.VSWR, "error", err)
+	} else {
+		slog.Warn("VSWR protection tripped, transmitter unkeyed", "vswr", reading.VSWR, "max_vswr", maxVSWR,
+			"forward_dbm", reading.ForwardDBm, "reflected_dbm", reading.ReflectedDBm)
+	}
+	PublishEvent(vswrTripEvent, hardwareMonitorEventSource, event)
+}
+
+// checkVSWR refuses keying while the VSWR protection has tripped
+func (p *HardwarePlugin) checkVSWR() error {
+	p.mu.RLock()
+	trip := p.vswrTrip
+	p.mu.RUnlock()
+	if trip != nil {
+		return fmt.Errorf("%w: VSWR %.1f exceeded %.1f at %s; clear the trip after checking the antenna",
+			errVSWRTrip, trip.Reading.VSWR, trip.MaxVSWR, trip.Time.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// handleGetVSWR handles GET /api/hardware/vswr
+func (p *HardwarePlugin) handleGetVSWR(c *fiber.Ctx) error {
+	cfg := p.getConfig().VSWR
+	if cfg.Source == "" {
+		return SendErrorMessage(c, 404, "Power sensor is not configured (hardware.vswr.source)")
+	}
+
+	p.mu.RLock()
+	monitor := p.vswr
+	var trip *VSWRTrip
+	if p.vswrTrip != nil {
+		copied := *p.vswrTrip
+		trip = &copied
+	}
+	p.mu.RUnlock()
+
+	status := VSWRStatus{
+		Source:   cfg.Source,
+		MaxVSWR:  cfg.MaxVSWR,
+		Interval: cfg.Interval,
+		Tripped:  trip != nil,
+		Trip:     trip,
+	}
+	if monitor != nil {
+		status.Reading, status.Peak, status.Error = monitor.snapshot()
+	}
+	return SendSuccess(c, status, "")
+}
+
+// handleClearVSWRTrip handles DELETE /api/hardware/vswr/trip
+// Releases the TX inhibit and resets the peak reading
+func (p *HardwarePlugin) handleClearVSWRTrip(c *fiber.Ctx) error {
+	p.mu.Lock()
+	trip := p.vswrTrip
+	p.vswrTrip = nil
+	monitor := p.vswr
+	p.mu.Unlock()
+
+	if monitor != nil {
+		monitor.clearPeak()
+	}
+	if trip == nil {
+		return SendSuccess(c, nil, "VSWR protection is not tripped")
+	}
+
+	slog.InfoContext(c.UserContext(), "VSWR trip cleared", "vswr", trip.Reading.VSWR)
+	PublishEvent(vswrClearedEvent, hardwareMonitorEventSource, trip)
+	return SendSuccess(c, nil, "VSWR trip cleared")
+}
@@ -0,0 +1,358 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Health status values
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthFail     = "fail"
+)
+
+// Health probe constants
+const (
+	DefaultHealthProbeTimeout = 5 * time.Second
+	DefaultHealthMinFreeMB    = 100
+	healthEventSource         = "health"
+)
+
+// ComponentHealth represents the result of a single dependency probe
+type ComponentHealth struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Message  string `json:"message,omitempty"`
+	Latency  string `json:"latency"`
+}
+
+// HealthReport represents the aggregated health of the manager
+type HealthReport struct {
+	Status     string            `json:"status"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Components []ComponentHealth `json:"components"`
+}
+
+// HealthConfig holds the health section of the configuration
+type HealthConfig struct {
+	DiskPaths []string `yaml:"disk_paths"`
+	MinFreeMB uint64   `yaml:"min_free_mb"`
+	Watchdog  bool     `yaml:"watchdog"`
+	Interval  int      `yaml:"interval"` // seconds between background checks, 0 = only on request
+
+	// Probe targets taken from the docker, hardware and cps sections
+	DockerClient *client.Client `yaml:"-"`
+	SPIDevice    string         `yaml:"-"`
+	GPIOChip     string         `yaml:"-"`
+	SettingsPath string         `yaml:"-"`
+}
+
+// HealthPlugin actively probes the manager's dependencies
+type HealthPlugin struct {
+	config   HealthConfig
+	stopChan chan struct{}
+	stopOnce sync.Once
+
+	mu      sync.Mutex
+	failing map[string]bool
+}
+
+// NewHealthPlugin creates a new health plugin instance
+func NewHealthPlugin(cfg HealthConfig) (*HealthPlugin, error) {
+	if len(cfg.DiskPaths) == 0 {
+		cfg.DiskPaths = []string{"/"}
+	}
+	if cfg.MinFreeMB == 0 {
+		cfg.MinFreeMB = DefaultHealthMinFreeMB
+	}
+
+	p := &HealthPlugin{
+		config:   cfg,
+		stopChan: make(chan struct{}),
+		failing:  make(map[string]bool),
+	}
+	return p, nil
+}
+
+// Start begins the watchdog and the background checks publishing health events
+func (p *HealthPlugin) Start() error {
+	if p.config.Watchdog {
+		p.startWatchdog()
+	}
+	if p.config.Interval > 0 {
+		p.startChecks(time.Duration(p.config.Interval) * time.Second)
+	}
+	return nil
+}
+
+// Name returns the plugin identifier
+func (p *HealthPlugin) Name() string {
+	return "health"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *HealthPlugin) RegisterRoutes(app *fiber.App) {
+	APIGroup(app, "").Get("/health", p.handleHealth)
+}
+
+// Shutdown stops the watchdog loop
+func (p *HealthPlugin) Shutdown() error {
+	p.stopOnce.Do(func() {
+		close(p.stopChan)
+	})
+	return nil
+}
+
+// handleHealth handles GET /api/health
+// Returns 200 when healthy or degraded and 503 when a critical component fails
+func (p *HealthPlugin) handleHealth(c *fiber.Ctx) error {
+	report := p.Check(context.Background())
+
+	if report.Status == HealthFail {
+		return c.Status(503).JSON(APIResponse{
+			Success: false,
+			Data:    report,
+			Error:   "unhealthy",
+		})
+	}
+
+	return SendSuccess(c, report, "")
+}
+
+// Check runs all probes and aggregates the result
+func (p *HealthPlugin) Check(ctx context.Context) HealthReport {
+	probes := []struct {
+		name     string
+		critical bool
+		enabled  bool
+		fn       func(context.Context) error
+	}{
+		{"docker", true, p.config.DockerClient != nil, p.probeDocker},
+		{"spi", false, p.config.SPIDevice != "", p.probeSPI},
+		{"gpio", false, p.config.GPIOChip != "", p.probeGPIO},
+		{"settings", false, p.config.SettingsPath != "", p.probeSettings},
+	}
+
+	results := make([]ComponentHealth, 0, len(probes)+len(p.config.DiskPaths))
+	for _, probe := range probes {
+		if !probe.enabled {
+			continue
+		}
+		results = append(results, runProbe(ctx, probe.name, probe.critical, probe.fn))
+	}
+
+	for _, path := range p.config.DiskPaths {
+		path := path
+		results = append(results, runProbe(ctx, "disk:"+path, true, func(context.Context) error {
+			return p.probeDisk(path)
+		}))
+	}
+
+	status := HealthOK
+	for _, result := range results {
+		if result.Status == HealthOK {
+			continue
+		}
+		if result.Critical {
+			status = HealthFail
+			break
+		}
+		status = HealthDegraded
+	}
+
+	p.publishTransitions(results)
+
+	return HealthReport{
+		Status:     status,
+		Timestamp:  time.Now(),
+		Components: results,
+	}
+}
+
+// publishTransitions publishes health.<component>.failed and .recovered events when a probe changes state
+// Disk probes are published as health.disk.* with the path in the component name
+func (p *HealthPlugin) publishTransitions(results []ComponentHealth) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, result := range results {
+		failing := result.Status != HealthOK
+		if failing == p.failing[result.Name] {
+			continue
+		}
+		p.failing[result.Name] = failing
+
+		kind, _, _ := strings.Cut(result.Name, ":")
+		if failing {
+			slog.Warn("Health probe failed", "component", result.Name, "message", result.Message)
+			PublishEvent("health."+kind+".failed", healthEventSource, result)
+		} else {
+			slog.Info("Health probe recovered", "component", result.Name)
+			PublishEvent("health."+kind+".recovered", healthEventSource, result)
+		}
+	}
+}
+
+// runProbe executes a single probe with a timeout
+func runProbe(ctx context.Context, name string, critical bool, fn func(context.Context) error) ComponentHealth {
+	ctx, cancel := context.WithTimeout(ctx, DefaultHealthProbeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := fn(ctx)
+
+	result := ComponentHealth{
+		Name:     name,
+		Status:   HealthOK,
+		Critical: critical,
+		Latency:  time.Since(start).String(),
+	}
+	if err != nil {
+		result.Status = HealthFail
+		result.Message = err.Error()
+	}
+	return result
+}
+
+// probeDocker checks that the Docker daemon socket is reachable
+func (p *HealthPlugin) probeDocker(ctx context.Context) error {
+	_, err := p.config.DockerClient.Ping(ctx)
+	return err
+}
+
+// probeSPI checks that the SPI device node exists and is a character device
+func (p *HealthPlugin) probeSPI(ctx context.Context) error {
+	info, err := os.Stat(p.config.SPIDevice)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("%s is not a character device", p.config.SPIDevice)
+	}
+	return nil
+}
+
+// probeGPIO checks that the GPIO chip can be opened
+func (p *HealthPlugin) probeGPIO(ctx context.Context) error {
+	return ValidateGPIOChip(p.config.GPIOChip)
+}
+
+// probeSettings checks that the CPS settings file is readable
+func (p *HealthPlugin) probeSettings(ctx context.Context) error {
+	f, err := os.Open(p.config.SettingsPath)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// probeDisk checks that a filesystem has enough free space
+func (p *HealthPlugin) probeDisk(path string) error {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return err
+	}
+
+	freeMB := stat.Bavail * uint64(stat.Bsize) / 1024 / 1024
+	if freeMB < p.config.MinFreeMB {
+		return fmt.Errorf("only %d MB free (minimum %d MB)", freeMB, p.config.MinFreeMB)
+	}
+	return nil
+}
+
+// startWatchdog pings the systemd watchdog while the manager is healthy
+// Only active when started by systemd with WatchdogSec set
+func (p *HealthPlugin) startWatchdog() {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if socket == "" || err != nil || usec <= 0 {
+		slog.Info("Systemd watchdog not configured, skipping")
+		return
+	}
+
+	// Notify at half the watchdog interval as recommended by sd_watchdog_enabled(3)
+	interval := time.Duration(usec) * time.Microsecond / 2
+	slog.Info("Systemd watchdog enabled", "interval", interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.stopChan:
+				return
+			case <-ticker.C:
+				report := p.Check(context.Background())
+				if report.Status == HealthFail {
+					slog.Warn("Health check failed, withholding watchdog notification")
+					continue
+				}
+				if err := sdNotify(socket, "WATCHDOG=1"); err != nil {
+					slog.Error("Failed to notify systemd watchdog", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// startChecks runs the probes periodically so failures are published without a client polling
+func (p *HealthPlugin) startChecks(interval time.Duration) {
+	slog.Info("Health checks scheduled", "interval", interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.stopChan:
+				return
+			case <-ticker.C:
+				p.Check(context.Background())
+			}
+		}
+	}()
+}
+
+// sdNotify sends a state string to the systemd notification socket
+func sdNotify(socket string, state string) error {
+	// Abstract namespace sockets are prefixed with '@'
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// Register the plugin
+func init() {
+	Register("health", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[HealthConfig]("health", config)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewHealthPlugin(cfg)
+	}, "dockerclient")
+}
@@ -0,0 +1,448 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Job states
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCanceled  = "canceled"
+)
+
+// Job bookkeeping
+const (
+	JobRetention        = time.Hour // finished jobs stay listed this long
+	MaxFinishedJobs     = 100
+	jobProgressInterval = 250 * time.Millisecond // at most one progress event per interval
+	jobEventSource      = "jobs"
+)
+
+// ErrJobCanceled is the error of a job that was canceled through the API
+var ErrJobCanceled = errors.New("job canceled")
+
+// JobProgress reports how far a job has come
+type JobProgress struct {
+	Done    int64   `json:"done"`
+	Total   int64   `json:"total,omitempty"` // 0 while unknown
+	Unit    string  `json:"unit,omitempty"`  // bytes, steps, files
+	Percent float64 `json:"percent,omitempty"`
+	Message string  `json:"message,omitempty"`
+}
+
+// Job describes a long-running operation
+type Job struct {
+	ID          string      `json:"id"`
+	Type        string      `json:"type"` // e.g. image.import or hardware.sweep
+	Description string      `json:"description"`
+	State       string      `json:"state"`
+	Progress    JobProgress `json:"progress"`
+	Result      interface{} `json:"result,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	FinishedAt  *time.Time  `json:"finished_at,omitempty"`
+}
+
+// JobFunc does the work of a job and returns its result
+// It must return soon after ctx is canceled. A *fiber.Error sets the status
+// a waiting request is answered with.
+type JobFunc func(ctx context.Context, job *JobHandle) (interface{}, error)
+
+// JobHandle lets a running job report its progress
+type JobHandle struct {
+	entry *jobEntry
+}
+
+// jobEntry is a job with its control state
+type jobEntry struct {
+	mu      sync.Mutex
+	job     Job
+	status  int // HTTP status of a failed job that returned a *fiber.Error
+	cancel  context.CancelFunc
+	changed chan struct{} // closed and replaced on every change
+	done    chan struct{}
+}
+
+// Jobs shared by all plugins
+var (
+	jobs   = make(map[string]*jobEntry)
+	jobsMu sync.Mutex
+)
+
+// StartJob runs fn in the background and returns the new job
+func StartJob(jobType, description string, fn JobFunc) Job {
+	return startJob(jobType, description, fn).snapshot()
+}
+
+// startJob registers and starts a job
+func startJob(jobType, description string, fn JobFunc) *jobEntry {
+	ctx, cancel := context.WithCancel(context.Background())
+	entry := &jobEntry{
+		job: Job{
+			ID:          uuid.New().String(),
+			Type:        jobType,
+			Description: description,
+			State:       JobRunning,
+			CreatedAt:   time.Now(),
+		},
+		cancel:  cancel,
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	jobsMu.Lock()
+	pruneJobs()
+	jobs[entry.job.ID] = entry
+	jobsMu.Unlock()
+
+	slog.Info("Job started", "job", entry.job.ID, "type", jobType, "description", description)
+	PublishEvent("job.started", jobEventSource, entry.snapshot())
+
+	go func() {
+		defer cancel()
+		result, err := fn(ctx, &JobHandle{entry: entry})
+		entry.finish(ctx, result, err)
+	}()
+	return entry
+}
+
+// finish records the outcome of the job's function
+func (e *jobEntry) finish(ctx context.Context, result interface{}, err error) {
+	now := time.Now()
+	e.mu.Lock()
+	switch {
+	case err != nil && ctx.Err() != nil:
+		e.job.State = JobCanceled
+		e.job.Error = ErrJobCanceled.Error()
+	case err != nil:
+		e.job.State = JobFailed
+		e.job.Error = err.Error()
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			e.status = fiberErr.Code
+		}
+	default:
+		e.job.State = JobSucceeded
+		e.job.Result = result
+		if e.job.Progress.Total > 0 {
+			e.job.Progress.Done = e.job.Progress.Total
+			e.job.Progress.Percent = 100
+		}
+	}
+	e.job.FinishedAt = &now
+	job := e.job
+	e.notify()
+	e.mu.Unlock()
+	close(e.done)
+
+	slog.Info("Job finished", "job", job.ID, "type", job.Type, "state", job.State, "error", job.Error,
+		"duration", now.Sub(job.CreatedAt))
+	PublishEvent("job.finished", jobEventSource, job)
+}
+
+// notify wakes up the progress streams; called with e.mu held
+func (e *jobEntry) notify() {
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+// snapshot returns a copy of the job
+func (e *jobEntry) snapshot() Job {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.job
+}
+
+// Progress sets the progress in the given unit; total is 0 while unknown
+func (h *JobHandle) Progress(done, total int64, unit string) {
+	e := h.entry
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.job.Progress.Done, e.job.Progress.Total, e.job.Progress.Unit = done, total, unit
+	e.job.Progress.Percent = 0
+	if total > 0 {
+		e.job.Progress.Percent = float64(done) * 100 / float64(total)
+	}
+	e.notify()
+}
+
+// Message sets a short description of the current step
+func (h *JobHandle) Message(message string) {
+	e := h.entry
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.job.Progress.Message = message
+	e.notify()
+}
+
+// Reader counts bytes read from r as the job's progress
+func (h *JobHandle) Reader(r io.Reader, total int64) io.Reader {
+	return &jobProgressReader{r: r, job: h, total: total}
+}
+
+// jobProgressReader reports the bytes read through it, at most every progress interval
+type jobProgressReader struct {
+	r        io.Reader
+	job      *JobHandle
+	total    int64
+	done     int64
+	reported time.Time
+}
+
+func (r *jobProgressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.done += int64(n)
+	if time.Since(r.reported) >= jobProgressInterval || err != nil {
+		r.job.Progress(r.done, r.total, "bytes")
+		r.reported = time.Now()
+	}
+	return n, err
+}
+
+// getJob returns a job by ID
+func getJob(id string) (*jobEntry, bool) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	entry, ok := jobs[id]
+	return entry, ok
+}
+
+// removeJob forgets a finished job; called with jobsMu held
+func removeJob(entry *jobEntry) {
+	delete(jobs, entry.job.ID)
+}
+
+// pruneJobs removes finished jobs past their retention, oldest first
+// beyond MaxFinishedJobs; called with jobsMu held
+func pruneJobs() {
+	var finished []*jobEntry
+	for _, entry := range jobs {
+		job := entry.snapshot()
+		if job.FinishedAt == nil {
+			continue
+		}
+		if time.Since(*job.FinishedAt) > JobRetention {
+			removeJob(entry)
+			continue
+		}
+		finished = append(finished, entry)
+	}
+	if len(finished) <= MaxFinishedJobs {
+		return
+	}
+	sortJobs(finished)
+	for _, entry := range finished[:len(finished)-MaxFinishedJobs] {
+		removeJob(entry)
+	}
+}
+
+// sortJobs orders jobs by creation time, which never changes
+func sortJobs(entries []*jobEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].job.CreatedAt.Before(entries[j].job.CreatedAt)
+	})
+}
+
+// CancelJobs cancels every running job and waits for them to stop, or until ctx ends
+func CancelJobs(ctx context.Context) {
+	jobsMu.Lock()
+	var running []*jobEntry
+	for _, entry := range jobs {
+		entry.cancel()
+		running = append(running, entry)
+	}
+	jobsMu.Unlock()
+
+	for _, entry := range running {
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runJob starts a job for a request and answers when it is done, or at once
+// with 202 and the job when the request sets ?async=true
+// Either way the job shows up in /api/jobs and can be canceled there; a
+// client that disconnects while waiting cancels it.
+func runJob(c *fiber.Ctx, jobType, description string, fn JobFunc, message string) error {
+	return waitJob(c, startJob(jobType, description, fn), message)
+}
+
+// waitJob answers a request with the outcome of a started job, as runJob does
+func waitJob(c *fiber.Ctx, entry *jobEntry, message string) error {
+	if c.QueryBool("async") {
+		return c.Status(202).JSON(APIResponse{
+			Success: true,
+			Data:    entry.snapshot(),
+			Message: "Job started",
+		})
+	}
+
+	ctx, cancel := RequestContext(c)
+	defer cancel()
+	select {
+	case <-entry.done:
+	case <-ctx.Done():
+		// Nobody is waiting for the result any more
+		entry.cancel()
+		<-entry.done
+	}
+	entry.mu.Lock()
+	job, status := entry.job, entry.status
+	entry.mu.Unlock()
+	switch {
+	case job.State == JobSucceeded:
+		return SendSuccess(c, job.Result, message)
+	case job.State == JobCanceled:
+		return SendErrorMessage(c, 409, job.Error)
+	case status != 0:
+		return SendErrorMessage(c, status, job.Error)
+	}
+	return SendErrorMessage(c, 500, job.Error)
+}
+
+// jobListSpec names the job fields for sorting and filtering the job list
+var jobListSpec = listSpec[Job]{
+	key: "id",
+	fields: map[string]func(Job) interface{}{
+		"id":         func(job Job) interface{} { return job.ID },
+		"type":       func(job Job) interface{} { return job.Type },
+		"state":      func(job Job) interface{} { return job.State },
+		"created_at": func(job Job) interface{} { return job.CreatedAt },
+	},
+}
+
+// HandleListJobs handles GET /api/jobs
+// Lists running and recently finished jobs, oldest first
+func HandleListJobs(c *fiber.Ctx) error {
+	jobsMu.Lock()
+	pruneJobs()
+	entries := make([]*jobEntry, 0, len(jobs))
+	for _, entry := range jobs {
+		entries = append(entries, entry)
+	}
+	jobsMu.Unlock()
+
+	sortJobs(entries)
+	list := make([]Job, len(entries))
+	for i, entry := range entries {
+		list[i] = entry.snapshot()
+	}
+	list, meta, err := pageList(c, list, jobListSpec)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+	return SendList(c, list, meta)
+}
+
+// HandleGetJob handles GET /api/jobs/:id
+func HandleGetJob(c *fiber.Ctx) error {
+	entry, ok := getJob(c.Params("id"))
+	if !ok {
+		return SendErrorMessage(c, 404, "Job not found")
+	}
+	return SendSuccess(c, entry.snapshot(), "")
+}
+
+// HandleCancelJob handles POST /api/jobs/:id/cancel
+func HandleCancelJob(c *fiber.Ctx) error {
+	entry, ok := getJob(c.Params("id"))
+	if !ok {
+		return SendErrorMessage(c, 404, "Job not found")
+	}
+	if entry.snapshot().State != JobRunning {
+		return SendErrorMessage(c, 409, "Job has already finished")
+	}
+	entry.cancel()
+	slog.InfoContext(c.UserContext(), "Job cancel requested", "job", entry.job.ID, "client", c.IP())
+
+	// Jobs stop at their next check; give them a moment so the answer shows the outcome
+	select {
+	case <-entry.done:
+	case <-time.After(2 * time.Second):
+	}
+	return SendSuccess(c, entry.snapshot(), "Job canceled")
+}
+
+// HandleDeleteJob handles DELETE /api/jobs/:id
+// Removes a finished job
+func HandleDeleteJob(c *fiber.Ctx) error {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	entry, ok := jobs[c.Params("id")]
+	if !ok {
+		return SendErrorMessage(c, 404, "Job not found")
+	}
+	if entry.snapshot().State == JobRunning {
+		return SendErrorMessage(c, 409, "Job is still running, cancel it first")
+	}
+	removeJob(entry)
+	return SendSuccess(c, nil, "Job removed")
+}
+
+// HandleJobEvents handles GET /api/jobs/:id/events
+// Streams the job via SSE: progress events while it runs, then one done event
+func HandleJobEvents(c *fiber.Ctx) error {
+	entry, ok := getJob(c.Params("id"))
+	if !ok {
+		return SendErrorMessage(c, 404, "Job not found")
+	}
+
+	// Set SSE headers
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		keepAlive := time.NewTicker(eventKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			entry.mu.Lock()
+			job, changed := entry.job, entry.changed
+			entry.mu.Unlock()
+
+			event := "progress"
+			if job.State != JobRunning {
+				event = "done"
+			}
+			data, _ := json.Marshal(job)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+			if err := w.Flush(); err != nil || event == "done" {
+				return
+			}
+
+			for waiting := true; waiting; {
+				select {
+				case <-changed:
+					waiting = false
+				case <-keepAlive.C:
+					fmt.Fprint(w, ": keep-alive\n\n")
+					if err := w.Flush(); err != nil {
+						return
+					}
+				}
+			}
+			time.Sleep(jobProgressInterval)
+		}
+	})
+
+	return nil
+}
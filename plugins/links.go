@@ -0,0 +1,234 @@
+package plugins
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Signed link defaults
+const (
+	signedLinkPath       = "/links"
+	DefaultSignedLinkTTL = 300   // seconds
+	MaxSignedLinkTTL     = 86400 // seconds
+)
+
+// Signed link errors
+var (
+	errSignedLinkInvalid = errors.New("invalid download link")
+	errSignedLinkExpired = errors.New("download link has expired")
+)
+
+// Signed link state shared by all plugins
+// The key is random per process, so links end with the manager session.
+var (
+	signedLinkKey     = newSignedLinkKey()
+	signedLinkTargets []string // GET route patterns below the API prefix, e.g. "/images/:id/export"
+	signedLinkMu      sync.RWMutex
+)
+
+// signedLinkClaims is the signed part of a link
+type signedLinkClaims struct {
+	Target  string `json:"t"` // versioned API path with query string
+	Expires int64  `json:"e"` // unix seconds
+}
+
+// SignedLink is a minted download link
+type SignedLink struct {
+	URL       string    `json:"url"`
+	Target    string    `json:"target"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// newSignedLinkKey returns a random HMAC key
+func newSignedLinkKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("failed to generate signed link key: %v", err))
+	}
+	return key
+}
+
+// AllowSignedLinks lets signed links point at a GET route
+// Patterns are relative to the API prefix; ":name" segments match any value.
+func AllowSignedLinks(pattern string) {
+	signedLinkMu.Lock()
+	defer signedLinkMu.Unlock()
+	for _, existing := range signedLinkTargets {
+		if existing == pattern {
+			return
+		}
+	}
+	signedLinkTargets = append(signedLinkTargets, pattern)
+}
+
+// signedLinkAllowed reports whether a versioned API path matches an allowed route
+func signedLinkAllowed(path string) bool {
+	rest, ok := strings.CutPrefix(path, APIPath(""))
+	if !ok {
+		return false
+	}
+
+	signedLinkMu.RLock()
+	defer signedLinkMu.RUnlock()
+	for _, pattern := range signedLinkTargets {
+		if matchRoutePattern(pattern, rest) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRoutePattern matches a path against a route pattern with ":name" segments
+func matchRoutePattern(pattern, path string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternSegments) != len(pathSegments) {
+		return false
+	}
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, ":") {
+			if pathSegments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// signLink returns the token for a target valid until expires
+func signLink(claims signedLinkClaims) string {
+	payload, _ := json.Marshal(claims)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(linkSignature(encoded))
+}
+
+// linkSignature returns the HMAC of an encoded payload
+func linkSignature(encoded string) []byte {
+	mac := hmac.New(sha256.New, signedLinkKey)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// verifyLink checks a token's signature and expiry and returns its claims
+func verifyLink(token string) (signedLinkClaims, error) {
+	var claims signedLinkClaims
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return claims, errSignedLinkInvalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, linkSignature(encoded)) {
+		return claims, errSignedLinkInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || json.Unmarshal(payload, &claims) != nil {
+		return claims, errSignedLinkInvalid
+	}
+	if time.Now().Unix() > claims.Expires {
+		return claims, errSignedLinkExpired
+	}
+	return claims, nil
+}
+
+// SignedLinkMiddleware serves GET /api/links/<token> as the request the link was minted for
+// Must be registered after LegacyAPIRewrite so paths are versioned. The token
+// never reaches the request log, which shows the target path instead.
+func SignedLinkMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, ok := strings.CutPrefix(c.Path(), APIPath(signedLinkPath)+"/")
+		if !ok || (c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead) {
+			return c.Next()
+		}
+
+		claims, err := verifyLink(token)
+		if errors.Is(err, errSignedLinkExpired) {
+			return SendError(c, 410, err)
+		}
+		if err != nil {
+			return SendError(c, 403, err)
+		}
+
+		path, query, _ := strings.Cut(claims.Target, "?")
+		c.Path(path)
+		c.Request().URI().SetQueryString(query)
+		slog.InfoContext(c.UserContext(), "Signed link used", "target", path, "client", c.IP())
+		return c.Next()
+	}
+}
+
+// HandleSignedLinkTargets handles GET /api/links
+// Lists the routes links can be minted for
+func HandleSignedLinkTargets(c *fiber.Ctx) error {
+	signedLinkMu.RLock()
+	targets := make([]string, 0, len(signedLinkTargets))
+	for _, pattern := range signedLinkTargets {
+		targets = append(targets, APIPath(pattern))
+	}
+	signedLinkMu.RUnlock()
+
+	return SendSuccess(c, fiber.Map{
+		"targets":     targets,
+		"default_ttl": DefaultSignedLinkTTL,
+		"max_ttl":     MaxSignedLinkTTL,
+	}, "")
+}
+
+// HandleCreateSignedLink handles POST /api/links
+// Mints a link for {"url": "/api/v1/filemanager/download?path=...", "ttl": 300}.
+// The link works without further credentials until it expires or the manager restarts.
+func HandleCreateSignedLink(c *fiber.Ctx) error {
+	var req struct {
+		URL string `json:"url"`
+		TTL int    `json:"ttl"` // seconds
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	if req.TTL == 0 {
+		req.TTL = DefaultSignedLinkTTL
+	}
+	if req.TTL < 1 || req.TTL > MaxSignedLinkTTL {
+		return SendErrorMessage(c, 400, fmt.Sprintf("ttl must be between 1 and %d seconds", MaxSignedLinkTTL))
+	}
+
+	target, err := url.Parse(req.URL)
+	if err != nil || target.Scheme != "" || target.Host != "" || !strings.HasPrefix(target.Path, "/api/") {
+		return SendErrorMessage(c, 400, "url must be an API path such as /api/v1/filemanager/download?path=/tmp/file")
+	}
+	path := target.Path
+	if segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/api/"), "/"); !versionSegment.MatchString(segment) {
+		path = APIPath(strings.TrimPrefix(path, "/api"))
+	}
+	if !signedLinkAllowed(path) {
+		return SendErrorMessage(c, 400, fmt.Sprintf("Links cannot be created for %s", path))
+	}
+
+	claims := signedLinkClaims{Target: path, Expires: time.Now().Add(time.Duration(req.TTL) * time.Second).Unix()}
+	if target.RawQuery != "" {
+		claims.Target += "?" + target.RawQuery
+	}
+	link := SignedLink{
+		URL:       APIPath(signedLinkPath) + "/" + signLink(claims),
+		Target:    claims.Target,
+		ExpiresAt: time.Unix(claims.Expires, 0),
+	}
+
+	slog.InfoContext(c.UserContext(), "Signed link created", "target", path, "ttl", req.TTL, "client", c.IP())
+	return SendSuccess(c, link, "")
+}
@@ -0,0 +1,292 @@
+package plugins
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MaxListLimit caps the page size of list endpoints
+const MaxListLimit = 1000
+
+// listFilterPattern splits a filter into field, operator and value
+var listFilterPattern = regexp.MustCompile(`^([a-z_]+)([:~<>])(.*)$`)
+
+// ListMeta describes the page returned by a list endpoint
+type ListMeta struct {
+	Total      int    `json:"total"` // items matching the filters
+	Offset     int    `json:"offset"`
+	Limit      int    `json:"limit,omitempty"`       // 0 when the list is not limited
+	NextCursor string `json:"next_cursor,omitempty"` // set when more items follow
+}
+
+// listSpec describes the items of a list endpoint for sorting and filtering
+// Field values are string, []string, int64, float64, bool or time.Time.
+type listSpec[T any] struct {
+	key    string // field unique per item, remembered by cursors
+	fields map[string]func(T) interface{}
+}
+
+// listSort is one sort key
+type listSort struct {
+	field string
+	desc  bool
+}
+
+// listFilter is one filter condition; all conditions must match
+type listFilter struct {
+	field string
+	op    byte // ':' equals, '~' contains, '<' and '>' compare
+	value string
+}
+
+// listCursor points after the last item of a page
+type listCursor struct {
+	Key    string `json:"k"`
+	Offset int    `json:"o"` // used when the item is gone
+}
+
+// listQuery holds the shared list query parameters:
+// ?limit=&offset= or ?limit=&cursor=, ?sort=name,-size and repeated ?filter=state:running
+type listQuery struct {
+	limit   int
+	offset  int
+	cursor  *listCursor
+	sort    []listSort
+	filters []listFilter
+}
+
+// parseListQuery reads and checks the list parameters of a request
+func parseListQuery[T any](c *fiber.Ctx, spec listSpec[T]) (listQuery, error) {
+	var q listQuery
+	var err error
+	if value := c.Query("limit"); value != "" {
+		if q.limit, err = strconv.Atoi(value); err != nil || q.limit < 1 || q.limit > MaxListLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", MaxListLimit)
+		}
+	}
+	if value := c.Query("offset"); value != "" {
+		if q.offset, err = strconv.Atoi(value); err != nil || q.offset < 0 {
+			return q, fmt.Errorf("offset must not be negative")
+		}
+	}
+	if value := c.Query("cursor"); value != "" {
+		if c.Query("offset") != "" {
+			return q, fmt.Errorf("use either offset or cursor")
+		}
+		q.cursor = &listCursor{}
+		data, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || json.Unmarshal(data, q.cursor) != nil {
+			return q, fmt.Errorf("invalid cursor")
+		}
+	}
+
+	if value := c.Query("sort"); value != "" {
+		for _, field := range strings.Split(value, ",") {
+			key := listSort{field: strings.TrimPrefix(field, "-"), desc: strings.HasPrefix(field, "-")}
+			if _, ok := spec.fields[key.field]; !ok {
+				return q, fmt.Errorf("cannot sort by %q, expected one of %s", key.field, spec.fieldNames())
+			}
+			q.sort = append(q.sort, key)
+		}
+	}
+
+	for _, value := range c.Context().QueryArgs().PeekMulti("filter") {
+		match := listFilterPattern.FindStringSubmatch(string(value))
+		if match == nil {
+			return q, fmt.Errorf("invalid filter %q, expected field:value, field~text, field<value or field>value", value)
+		}
+		if _, ok := spec.fields[match[1]]; !ok {
+			return q, fmt.Errorf("cannot filter by %q, expected one of %s", match[1], spec.fieldNames())
+		}
+		q.filters = append(q.filters, listFilter{field: match[1], op: match[2][0], value: match[3]})
+	}
+	return q, nil
+}
+
+// fieldNames lists the fields for error messages
+func (spec listSpec[T]) fieldNames() string {
+	names := make([]string, 0, len(spec.fields))
+	for name := range spec.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// apply filters, sorts and pages items
+// Without a sort parameter the endpoint's own order is kept.
+func (spec listSpec[T]) apply(items []T, q listQuery) ([]T, *ListMeta, error) {
+	matched := make([]T, 0, len(items))
+	for _, item := range items {
+		ok, err := spec.matches(item, q.filters)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			matched = append(matched, item)
+		}
+	}
+
+	if len(q.sort) > 0 {
+		keyField := spec.fields[spec.key]
+		sort.SliceStable(matched, func(i, j int) bool {
+			for _, key := range q.sort {
+				field := spec.fields[key.field]
+				if result := compareListValues(field(matched[i]), field(matched[j])); result != 0 {
+					return (result < 0) != key.desc
+				}
+			}
+			return compareListValues(keyField(matched[i]), keyField(matched[j])) < 0
+		})
+	}
+
+	start := q.offset
+	if q.cursor != nil {
+		start = q.cursor.Offset
+		for i, item := range matched {
+			if fmt.Sprint(spec.fields[spec.key](item)) == q.cursor.Key {
+				start = i + 1
+				break
+			}
+		}
+	}
+	start = min(start, len(matched))
+	end := len(matched)
+	if q.limit > 0 {
+		end = min(start+q.limit, len(matched))
+	}
+
+	meta := &ListMeta{Total: len(matched), Offset: start, Limit: q.limit}
+	if q.limit > 0 && end < len(matched) {
+		data, _ := json.Marshal(listCursor{Key: fmt.Sprint(spec.fields[spec.key](matched[end-1])), Offset: end})
+		meta.NextCursor = base64.RawURLEncoding.EncodeToString(data)
+	}
+	return matched[start:end], meta, nil
+}
+
+// matches reports whether an item meets every filter
+func (spec listSpec[T]) matches(item T, filters []listFilter) (bool, error) {
+	for _, filter := range filters {
+		ok, err := matchListValue(spec.fields[filter.field](item), filter)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchListValue checks one field value against a filter
+// List values match when any of their elements does.
+func matchListValue(value interface{}, filter listFilter) (bool, error) {
+	if values, ok := value.([]string); ok {
+		for _, element := range values {
+			if ok, err := matchListValue(element, filter); ok || err != nil {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+
+	if text, ok := value.(string); ok {
+		switch filter.op {
+		case ':':
+			return strings.EqualFold(text, filter.value), nil
+		case '~':
+			return strings.Contains(strings.ToLower(text), strings.ToLower(filter.value)), nil
+		}
+	} else if filter.op == '~' {
+		return false, fmt.Errorf("%s is not a text field, use %s:value", filter.field, filter.field)
+	}
+
+	var want interface{}
+	var err error
+	switch value.(type) {
+	case string:
+		want = filter.value
+	case int64:
+		want, err = strconv.ParseInt(filter.value, 10, 64)
+	case float64:
+		want, err = strconv.ParseFloat(filter.value, 64)
+	case bool:
+		want, err = strconv.ParseBool(filter.value)
+	case time.Time:
+		want, err = time.Parse(time.RFC3339, filter.value)
+		if err != nil {
+			want, err = time.ParseInLocation("2006-01-02", filter.value, time.Local)
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("invalid value %q for filter on %s", filter.value, filter.field)
+	}
+	if _, ok := value.(bool); ok && filter.op != ':' {
+		return false, fmt.Errorf("%s can only be compared with %s:true or %s:false", filter.field, filter.field, filter.field)
+	}
+
+	result := compareListValues(value, want)
+	switch filter.op {
+	case '<':
+		return result < 0, nil
+	case '>':
+		return result > 0, nil
+	}
+	return result == 0, nil
+}
+
+// compareListValues orders two values of the same field type
+// Text is compared case-insensitively; lists by their first element.
+func compareListValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		b, _ := b.(string)
+		if result := strings.Compare(strings.ToLower(a), strings.ToLower(b)); result != 0 {
+			return result
+		}
+		return strings.Compare(a, b)
+	case []string:
+		b, _ := b.([]string)
+		first := func(values []string) string {
+			if len(values) == 0 {
+				return ""
+			}
+			return values[0]
+		}
+		return compareListValues(first(a), first(b))
+	case int64:
+		b, _ := b.(int64)
+		return cmp.Compare(a, b)
+	case float64:
+		b, _ := b.(float64)
+		return cmp.Compare(a, b)
+	case bool:
+		b, _ := b.(bool)
+		if a == b {
+			return 0
+		}
+		if !a {
+			return -1
+		}
+		return 1
+	case time.Time:
+		b, _ := b.(time.Time)
+		return a.Compare(b)
+	}
+	return 0
+}
+
+// pageList applies the request's list parameters to items
+func pageList[T any](c *fiber.Ctx, items []T, spec listSpec[T]) ([]T, *ListMeta, error) {
+	q, err := parseListQuery(c, spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	return spec.apply(items, q)
+}
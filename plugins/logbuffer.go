@@ -0,0 +1,164 @@
+package plugins
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLogBufferSize is the number of log records kept in memory
+const DefaultLogBufferSize = 5000
+
+// LogEntry is a log record kept in memory
+type LogEntry struct {
+	Seq     int64                  `json:"seq"` // increases by one per record
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Module  string                 `json:"module,omitempty"` // part of the manager that logged it, e.g. docker or hardware
+	Message string                 `json:"message"`
+	Attrs   map[string]interface{} `json:"attrs,omitempty"`
+}
+
+// LogBuffer keeps the most recent log records
+type LogBuffer struct {
+	mu      sync.Mutex
+	entries []LogEntry
+	next    int // slot of the next record
+	full    bool
+	seq     int64
+}
+
+// NewLogBuffer creates a buffer of size records (DefaultLogBufferSize when size <= 0)
+func NewLogBuffer(size int) *LogBuffer {
+	if size <= 0 {
+		size = DefaultLogBufferSize
+	}
+	return &LogBuffer{entries: make([]LogEntry, size)}
+}
+
+// add stores a record, replacing the oldest when the buffer is full
+func (b *LogBuffer) add(entry LogEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	entry.Seq = b.seq
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % len(b.entries)
+	b.full = b.full || b.next == 0
+}
+
+// Entries returns the kept records, newest first
+func (b *LogBuffer) Entries() []LogEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := b.next
+	if b.full {
+		count = len(b.entries)
+	}
+	entries := make([]LogEntry, 0, count)
+	for i := 1; i <= count; i++ {
+		entries = append(entries, b.entries[(b.next-i+len(b.entries))%len(b.entries)])
+	}
+	return entries
+}
+
+// Handler returns a slog handler that stores records at or above level
+func (b *LogBuffer) Handler(level slog.Leveler) slog.Handler {
+	return &logBufferHandler{buffer: b, level: level}
+}
+
+// logBufferHandler stores records in a LogBuffer
+type logBufferHandler struct {
+	buffer *LogBuffer
+	level  slog.Leveler
+	attrs  []slog.Attr // from WithAttrs, keys already prefixed with their groups
+	prefix string      // groups from WithGroup, joined with dots
+}
+
+func (h *logBufferHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *logBufferHandler) Handle(_ context.Context, r slog.Record) error {
+	entry := LogEntry{
+		Time:    r.Time,
+		Level:   strings.ToLower(r.Level.String()),
+		Module:  logModule(r.PC),
+		Message: r.Message,
+	}
+	if len(h.attrs) > 0 || r.NumAttrs() > 0 {
+		entry.Attrs = make(map[string]interface{}, len(h.attrs)+r.NumAttrs())
+		for _, a := range h.attrs {
+			addLogAttr(entry.Attrs, "", a)
+		}
+		r.Attrs(func(a slog.Attr) bool {
+			addLogAttr(entry.Attrs, h.prefix, a)
+			return true
+		})
+	}
+	h.buffer.add(entry)
+	return nil
+}
+
+func (h *logBufferHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handler := *h
+	handler.attrs = append([]slog.Attr{}, h.attrs...)
+	for _, a := range attrs {
+		a.Key = h.prefix + a.Key
+		handler.attrs = append(handler.attrs, a)
+	}
+	return &handler
+}
+
+func (h *logBufferHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	handler := *h
+	handler.prefix = h.prefix + name + "."
+	return &handler
+}
+
+// addLogAttr adds an attribute to a map, flattening groups into dotted keys
+// Numbers, booleans and times keep their type; everything else is stored as text.
+func addLogAttr(attrs map[string]interface{}, prefix string, a slog.Attr) {
+	value := a.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, child := range value.Group() {
+			addLogAttr(attrs, prefix, child)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	switch value.Kind() {
+	case slog.KindString, slog.KindInt64, slog.KindUint64, slog.KindFloat64, slog.KindBool, slog.KindTime:
+		attrs[prefix+a.Key] = value.Any()
+	default:
+		attrs[prefix+a.Key] = value.String()
+	}
+}
+
+// logModule names the part of the manager a record comes from after the
+// source file of the logging call: hardware_spi.go is hardware, main.go main
+func logModule(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	if frame.File == "" {
+		return ""
+	}
+	name := strings.TrimSuffix(filepath.Base(frame.File), ".go")
+	module, _, _ := strings.Cut(name, "_")
+	return module
+}
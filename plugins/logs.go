@@ -0,0 +1,293 @@
+package plugins
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Log viewer constants
+const (
+	DefaultSelfLogLines = 100
+	MaxSelfLogLines     = 10000
+	logFollowInterval   = 500 * time.Millisecond
+	logTailChunkSize    = 8 * 1024
+)
+
+// LogsConfig holds the logs plugin settings
+type LogsConfig struct {
+	File   string     // the manager's log file, empty when logging to stdout only
+	Buffer *LogBuffer // recent records kept in memory
+}
+
+// LogsPlugin exposes the manager's own log output
+type LogsPlugin struct {
+	logFile string
+	buffer  *LogBuffer
+}
+
+// NewLogsPlugin creates a new logs plugin instance
+// logFile may be empty when file logging is disabled
+func NewLogsPlugin(logFile string, buffer *LogBuffer) (*LogsPlugin, error) {
+	return &LogsPlugin{
+		logFile: logFile,
+		buffer:  buffer,
+	}, nil
+}
+
+// Name returns the plugin identifier
+func (p *LogsPlugin) Name() string {
+	return "logs"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *LogsPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/logs")
+
+	api.Get("/self", p.handleSelf)
+	api.Get("/recent", p.handleRecent)
+}
+
+// Shutdown performs cleanup
+func (p *LogsPlugin) Shutdown() error {
+	return nil
+}
+
+// handleSelf handles GET /api/logs/self?lines=100&follow=false
+// Returns the last lines of the manager log, or streams it via SSE when following
+func (p *LogsPlugin) handleSelf(c *fiber.Ctx) error {
+	if p.logFile == "" {
+		return SendErrorMessage(c, 404, "File logging is not configured (set logging.file)")
+	}
+
+	lines := c.QueryInt("lines", DefaultSelfLogLines)
+	if lines <= 0 || lines > MaxSelfLogLines {
+		return SendErrorMessage(c, 400, fmt.Sprintf("lines must be between 1 and %d", MaxSelfLogLines))
+	}
+
+	tail, err := tailFile(p.logFile, lines)
+	if err != nil {
+		return SendError(c, 500, fmt.Errorf("failed to read log file: %w", err))
+	}
+
+	if !c.QueryBool("follow") {
+		return SendSuccess(c, fiber.Map{
+			"file":  p.logFile,
+			"lines": tail,
+		}, "")
+	}
+
+	// Set SSE headers
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	logFile := p.logFile
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		for _, line := range tail {
+			fmt.Fprintf(w, "data: %s\n\n", line)
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+		followFile(logFile, w)
+	})
+
+	return nil
+}
+
+// logListSpec names the record fields for sorting and filtering recent logs
+var logListSpec = listSpec[LogEntry]{
+	key: "seq",
+	fields: map[string]func(LogEntry) interface{}{
+		"seq":     func(entry LogEntry) interface{} { return entry.Seq },
+		"time":    func(entry LogEntry) interface{} { return entry.Time },
+		"level":   func(entry LogEntry) interface{} { return entry.Level },
+		"module":  func(entry LogEntry) interface{} { return entry.Module },
+		"message": func(entry LogEntry) interface{} { return entry.Message },
+	},
+}
+
+// handleRecent handles GET /api/logs/recent?level=warn&module=docker,hardware&q=&after=
+// Returns the records kept in memory, newest first, at or above level, from
+// the listed modules, containing q in the message or attributes and newer
+// than the sequence number after; supports the list query parameters
+func (p *LogsPlugin) handleRecent(c *fiber.Ctx) error {
+	if p.buffer == nil {
+		return SendErrorMessage(c, 404, "Log buffer is not available")
+	}
+
+	minLevel := slog.LevelDebug
+	if value := c.Query("level"); value != "" {
+		if err := minLevel.UnmarshalText([]byte(value)); err != nil {
+			return SendErrorMessage(c, 400, "level must be debug, info, warn or error")
+		}
+	}
+	modules := make(map[string]bool)
+	for _, module := range strings.Split(c.Query("module"), ",") {
+		if module = strings.TrimSpace(module); module != "" {
+			modules[module] = true
+		}
+	}
+	search := strings.ToLower(c.Query("q"))
+	after := int64(c.QueryInt("after"))
+
+	entries := []LogEntry{}
+	for _, entry := range p.buffer.Entries() {
+		var level slog.Level
+		level.UnmarshalText([]byte(entry.Level))
+		switch {
+		case entry.Seq <= after, level < minLevel:
+			continue
+		case len(modules) > 0 && !modules[entry.Module]:
+			continue
+		case search != "" && !logEntryContains(entry, search):
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	items, meta, err := pageList(c, entries, logListSpec)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+	return SendList(c, items, meta)
+}
+
+// logEntryContains reports whether the message or an attribute contains a lowercase text
+func logEntryContains(entry LogEntry, search string) bool {
+	if strings.Contains(strings.ToLower(entry.Message), search) {
+		return true
+	}
+	for key, value := range entry.Attrs {
+		if strings.Contains(strings.ToLower(key+"="+fmt.Sprint(value)), search) {
+			return true
+		}
+	}
+	return false
+}
+
+// followFile streams lines appended to a file until the client disconnects
+// Reopens the file when it is rotated or truncated
+func followFile(path string, w *bufio.Writer) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { file.Close() }()
+
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return
+	}
+
+	reader := bufio.NewReader(file)
+	var partial []byte
+	for {
+		chunk, err := reader.ReadBytes('\n')
+		if len(chunk) > 0 {
+			offset += int64(len(chunk))
+			partial = append(partial, chunk...)
+		}
+		if err == nil {
+			fmt.Fprintf(w, "data: %s\n\n", bytes.TrimRight(partial, "\r\n"))
+			partial = partial[:0]
+			if err := w.Flush(); err != nil {
+				return
+			}
+			continue
+		}
+
+		time.Sleep(logFollowInterval)
+
+		// Detect rotation or truncation
+		info, statErr := os.Stat(path)
+		if statErr != nil {
+			continue
+		}
+		if info.Size() < offset {
+			newFile, openErr := os.Open(path)
+			if openErr != nil {
+				continue
+			}
+			file.Close()
+			file = newFile
+			offset = 0
+			partial = partial[:0]
+			reader.Reset(file)
+		}
+
+		// Keep-alive comment so disconnected clients are detected
+		fmt.Fprint(w, ": keep-alive\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// tailFile returns up to n trailing lines of a file, reading backwards in chunks
+func tailFile(path string, n int) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	offset := info.Size()
+	for offset > 0 && bytes.Count(data, []byte("\n")) <= n {
+		size := int64(logTailChunkSize)
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+
+		chunk := make([]byte, size)
+		if _, err := file.ReadAt(chunk, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		data = append(chunk, data...)
+	}
+
+	lines := bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n"))
+	if offset > 0 && len(lines) > 0 {
+		// First line may be partial
+		lines = lines[1:]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if len(line) > 0 {
+			result = append(result, string(line))
+		}
+	}
+	return result, nil
+}
+
+// Register the plugin
+func init() {
+	Register("logs", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[LogsConfig]("logs", config)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewLogsPlugin(cfg.File, cfg.Buffer)
+	})
+}
@@ -0,0 +1,652 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Metrics history defaults
+const (
+	DefaultMetricsDir       = "/var/lib/linht/metrics"
+	DefaultMetricsInterval  = 60 // seconds between samples
+	DefaultMetricsRetention = 7  // days
+	metricsCollectTimeout   = 20 * time.Second
+	metricsPruneInterval    = time.Hour
+	metricsDefaultRange     = time.Hour
+	metricsMaxPoints        = 2000 // per series before a query is downsampled automatically
+	metricsMaxEvents        = 1000 // per events query
+)
+
+// MetricsConfig holds metrics history configuration
+type MetricsConfig struct {
+	Dir        string   `yaml:"dir"`
+	Interval   int      `yaml:"interval"`   // seconds between samples
+	Retention  int      `yaml:"retention"`  // days of history kept
+	DiskPaths  []string `yaml:"disk_paths"` // defaults to health.disk_paths
+	Containers bool     `yaml:"containers"` // sample CPU and memory of each running container
+	Events     []string `yaml:"events"`     // bus event type prefixes recorded for annotations (empty = all)
+
+	DockerClient *client.Client `yaml:"-"`
+}
+
+// cpuTimes are the jiffies of the cpu line of /proc/stat
+type cpuTimes struct {
+	busy, total uint64
+}
+
+// containerCPU is the CPU usage of a container at the previous sample
+type containerCPU struct {
+	usage, system uint64
+}
+
+// netCounters are the byte counters of an interface at the previous sample
+type netCounters struct {
+	rx, tx uint64
+	at     time.Time
+}
+
+// MetricsPlugin records system, Docker and hardware metrics for offline history
+type MetricsPlugin struct {
+	config   MetricsConfig
+	store    *metricsStore
+	stopChan chan struct{}
+	doneChan chan struct{}
+	started  bool // the sampling loop runs, set by Start
+
+	// Subscribed for the lifetime of the plugin, so events published before Start are recorded
+	events      <-chan Event
+	unsubscribe func()
+
+	mu        sync.RWMutex
+	samples   uint64
+	lastErr   string
+	lastAt    time.Time
+	lastPrune time.Time
+
+	// Counters of the previous sample, used only by the collection loop
+	cpu        *cpuTimes
+	containers map[string]containerCPU
+	network    map[string]netCounters
+}
+
+// NewMetricsPlugin creates a new metrics plugin instance
+func NewMetricsPlugin(cfg MetricsConfig) (*MetricsPlugin, error) {
+	cfg = normalizeMetricsConfig(cfg)
+	if err := validateMetricsConfig(cfg); err != nil {
+		return nil, err
+	}
+	store, err := newMetricsStore(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	events, unsubscribe := Events.Subscribe()
+	return &MetricsPlugin{
+		config:      cfg,
+		store:       store,
+		events:      events,
+		unsubscribe: unsubscribe,
+	}, nil
+}
+
+// Start begins sampling and recording bus events
+func (p *MetricsPlugin) Start() error {
+	p.mu.Lock()
+	p.started = true
+	p.mu.Unlock()
+
+	p.start()
+	return nil
+}
+
+// Name returns the plugin identifier
+func (p *MetricsPlugin) Name() string {
+	return "metrics"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *MetricsPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/metrics")
+
+	api.Get("/status", p.handleStatus)
+	api.Get("/names", p.handleNames)
+	api.Get("/history", p.handleHistory)
+	api.Get("/events", p.handleEvents)
+
+	// Grafana JSON datasource
+	grafana := APIGroup(app, "/metrics/grafana")
+	grafana.Get("/", p.handleGrafanaTest)
+	grafana.Post("/search", p.handleGrafanaSearch)
+	grafana.Post("/metrics", p.handleGrafanaMetrics)
+	grafana.Post("/query", p.handleGrafanaQuery)
+	grafana.Post("/annotations", p.handleGrafanaAnnotations)
+}
+
+// Shutdown stops sampling and closes the store
+func (p *MetricsPlugin) Shutdown() error {
+	p.stop()
+	p.unsubscribe()
+	p.store.close()
+	return nil
+}
+
+// Reload applies the new settings, restarting the sampling loop when they changed
+func (p *MetricsPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[MetricsConfig]("metrics", config)
+	if err != nil {
+		return err
+	}
+	cfg = normalizeMetricsConfig(cfg)
+	if err := validateMetricsConfig(cfg); err != nil {
+		return err
+	}
+
+	previous := p.getConfig()
+	changed := !reflect.DeepEqual(cfg, previous)
+	if changed {
+		store := p.getStore()
+		if cfg.Dir != previous.Dir {
+			if store, err = newMetricsStore(cfg.Dir); err != nil {
+				return err
+			}
+		}
+		p.stop()
+		if old := p.getStore(); old != store {
+			old.close()
+		}
+		p.mu.Lock()
+		p.config = cfg
+		p.store = store
+		started := p.started
+		p.mu.Unlock()
+		if started {
+			p.start()
+		}
+	}
+
+	slog.Info("Metrics config reloaded",
+		"dir", cfg.Dir,
+		"interval", cfg.Interval,
+		"retention", cfg.Retention,
+		"restarted", changed)
+	return nil
+}
+
+// getConfig returns the current configuration
+func (p *MetricsPlugin) getConfig() MetricsConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config
+}
+
+// getStore returns the current store
+func (p *MetricsPlugin) getStore() *metricsStore {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.store
+}
+
+// start launches the sampling loop
+func (p *MetricsPlugin) start() {
+	cfg := p.getConfig()
+	stopChan := make(chan struct{})
+	doneChan := make(chan struct{})
+
+	p.mu.Lock()
+	p.stopChan = stopChan
+	p.doneChan = doneChan
+	p.cpu = nil
+	p.containers = map[string]containerCPU{}
+	p.network = map[string]netCounters{}
+	p.mu.Unlock()
+
+	slog.Info("Metrics history started", "dir", cfg.Dir, "interval", cfg.Interval)
+	go p.run(cfg, stopChan, doneChan)
+}
+
+// stop terminates the sampling loop
+func (p *MetricsPlugin) stop() {
+	p.mu.RLock()
+	stopChan, doneChan := p.stopChan, p.doneChan
+	p.mu.RUnlock()
+	if stopChan == nil {
+		return
+	}
+
+	close(stopChan)
+	<-doneChan
+}
+
+// run samples at the configured interval and records bus events until stopped
+// The first sample is taken right away, so rates and CPU usage start with the second.
+func (p *MetricsPlugin) run(cfg MetricsConfig, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(time.Duration(cfg.Interval) * time.Second)
+	defer ticker.Stop()
+
+	p.sample(cfg)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.sample(cfg)
+		case event := <-p.events:
+			if !matchesEventFilter(event.Type, cfg.Events) {
+				continue
+			}
+			if err := p.getStore().appendEvent(event); err != nil {
+				slog.Debug("Failed to record event", "type", event.Type, "error", err)
+			}
+		}
+	}
+}
+
+// sample collects all metrics, appends them to the store and prunes old days now and then
+func (p *MetricsPlugin) sample(cfg MetricsConfig) {
+	now := time.Now()
+	values := p.collect(cfg, now)
+	err := p.getStore().append(metricsSample{Time: now.Unix(), Values: values})
+
+	p.mu.Lock()
+	p.lastAt = now
+	if err != nil {
+		if p.lastErr != err.Error() {
+			slog.Error("Failed to store metrics sample", "error", err)
+		}
+		p.lastErr = err.Error()
+	} else {
+		p.lastErr = ""
+		p.samples++
+	}
+	prune := now.Sub(p.lastPrune) >= metricsPruneInterval
+	if prune {
+		p.lastPrune = now
+	}
+	p.mu.Unlock()
+
+	if prune {
+		removed, err := p.getStore().prune(time.Duration(cfg.Retention) * 24 * time.Hour)
+		if err != nil {
+			slog.Warn("Failed to prune metrics history", "error", err)
+		} else if removed > 0 {
+			slog.Info("Pruned metrics history", "days", removed)
+		}
+	}
+}
+
+// collect reads the current values; sources that are unavailable are left out
+func (p *MetricsPlugin) collect(cfg MetricsConfig, now time.Time) map[string]float64 {
+	ctx, cancel := context.WithTimeout(context.Background(), metricsCollectTimeout)
+	defer cancel()
+
+	values := map[string]float64{}
+
+	// System
+	if load, ok := readLoadAverage(); ok {
+		values["system.load1"] = load
+	}
+	if times, ok := readCPUTimes(); ok {
+		if prev := p.cpu; prev != nil && times.total > prev.total {
+			values["system.cpu_percent"] = 100 * float64(times.busy-prev.busy) / float64(times.total-prev.total)
+		}
+		p.cpu = &times
+	}
+	if total, available, ok := readMemInfo(); ok {
+		values["system.memory_used_percent"] = 100 * float64(total-available) / float64(total)
+		values["system.memory_available_mb"] = float64(available) / 1024
+	}
+	for _, path := range cfg.DiskPaths {
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(path, &stat); err != nil || stat.Blocks == 0 {
+			continue
+		}
+		values["disk."+path+".free_mb"] = float64(stat.Bavail*uint64(stat.Bsize)) / 1024 / 1024
+		values["disk."+path+".used_percent"] = 100 * float64(stat.Blocks-stat.Bfree) / float64(stat.Blocks-stat.Bfree+stat.Bavail)
+	}
+	seen := map[string]bool{}
+	for _, zone := range readThermalZones() {
+		name := zone.Type
+		if name == "" || seen[name] {
+			name = zone.Name
+		}
+		seen[name] = true
+		values["thermal."+name] = zone.Temperature
+	}
+	if counters, err := readNetDev(); err == nil {
+		for iface, current := range counters {
+			current.at = now
+			if prev, ok := p.network[iface]; ok && current.rx >= prev.rx && current.tx >= prev.tx {
+				seconds := now.Sub(prev.at).Seconds()
+				values["net."+iface+".rx_bytes_per_sec"] = float64(current.rx-prev.rx) / seconds
+				values["net."+iface+".tx_bytes_per_sec"] = float64(current.tx-prev.tx) / seconds
+			}
+			p.network[iface] = current
+		}
+	}
+
+	// Docker
+	if cfg.DockerClient != nil {
+		if err := p.collectContainers(ctx, cfg, values); err != nil {
+			slog.Debug("Metrics container collection failed", "error", err)
+		}
+	}
+
+	// Hardware
+	if status, ok := CurrentHardwareStatus(); ok {
+		available := status.Error == ""
+		values["hardware.available"] = boolMetric(available)
+		if available {
+			values["hardware.mode"] = float64(status.ModeValue)
+			values["hardware.rx_pll_locked"] = boolMetric(status.RxPLLLocked)
+			values["hardware.tx_pll_locked"] = boolMetric(status.TxPLLLocked)
+			values["hardware.xosc_ready"] = boolMetric(status.XoscReady)
+		}
+		active := 0
+		for _, alarm := range status.Alarms {
+			if alarm.Active {
+				active++
+			}
+		}
+		values["hardware.alarms"] = float64(active)
+	}
+	values["system.maintenance"] = boolMetric(MaintenanceMode())
+	return values
+}
+
+// collectContainers adds the container counts and, when enabled, per-container CPU and memory
+func (p *MetricsPlugin) collectContainers(ctx context.Context, cfg MetricsConfig, values map[string]float64) error {
+	containers, err := cfg.DockerClient.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return err
+	}
+	running := 0
+	current := map[string]containerCPU{}
+	for _, cont := range containers {
+		if cont.State != "running" {
+			continue
+		}
+		running++
+		if !cfg.Containers {
+			continue
+		}
+		name := cont.ID[:12]
+		if len(cont.Names) > 0 {
+			name = strings.TrimPrefix(cont.Names[0], "/")
+		}
+		stats, err := containerStats(ctx, cfg.DockerClient, cont.ID)
+		if err != nil {
+			slog.Debug("Metrics container stats failed", "container", name, "error", err)
+			continue
+		}
+
+		usage := containerCPU{usage: stats.CPUStats.CPUUsage.TotalUsage, system: stats.CPUStats.SystemUsage}
+		if prev, ok := p.containers[cont.ID]; ok && usage.system > prev.system && usage.usage >= prev.usage {
+			cpus := float64(stats.CPUStats.OnlineCPUs)
+			if cpus == 0 {
+				cpus = 1
+			}
+			values["docker."+name+".cpu_percent"] = 100 * cpus * float64(usage.usage-prev.usage) / float64(usage.system-prev.system)
+		}
+		current[cont.ID] = usage
+
+		// Page cache is reclaimable, so it is not counted like docker stats does
+		memory := stats.MemoryStats.Usage
+		if cache := stats.MemoryStats.Stats["inactive_file"]; cache < memory {
+			memory -= cache
+		}
+		values["docker."+name+".memory_mb"] = float64(memory) / 1024 / 1024
+	}
+	p.containers = current
+
+	values["docker.containers"] = float64(len(containers))
+	values["docker.running"] = float64(running)
+	return nil
+}
+
+// containerStats reads a single stats snapshot of a container
+func containerStats(ctx context.Context, cli *client.Client, id string) (container.StatsResponse, error) {
+	var stats container.StatsResponse
+	resp, err := cli.ContainerStatsOneShot(ctx, id)
+	if err != nil {
+		return stats, err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(&stats)
+	return stats, err
+}
+
+// boolMetric converts a flag to 1 or 0
+func boolMetric(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// readCPUTimes reads the busy and total jiffies of all CPUs
+func readCPUTimes() (cpuTimes, bool) {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return cpuTimes{}, false
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return cpuTimes{}, false
+	}
+	var times cpuTimes
+	for i, field := range fields[1:] {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return cpuTimes{}, false
+		}
+		// guest times are already included in user and nice
+		if i >= 8 {
+			break
+		}
+		times.total += value
+		if i != 3 && i != 4 { // idle, iowait
+			times.busy += value
+		}
+	}
+	return times, true
+}
+
+// readNetDev reads the byte counters of all interfaces except loopback
+func readNetDev() (map[string]netCounters, error) {
+	f, err := os.Open("/proc/net/dev")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	counters := map[string]netCounters{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		iface, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		iface = strings.TrimSpace(iface)
+		fields := strings.Fields(rest)
+		if iface == "lo" || len(fields) < 9 {
+			continue
+		}
+		rx, err1 := strconv.ParseUint(fields[0], 10, 64)
+		tx, err2 := strconv.ParseUint(fields[8], 10, 64)
+		if err1 == nil && err2 == nil {
+			counters[iface] = netCounters{rx: rx, tx: tx}
+		}
+	}
+	return counters, scanner.Err()
+}
+
+// parseMetricsTime parses an RFC 3339 timestamp, Unix seconds, or a duration before now ("6h")
+func parseMetricsTime(value string, now time.Time, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if d, err := time.ParseDuration(strings.TrimPrefix(value, "-")); err == nil {
+		return now.Add(-d), nil
+	}
+	return parseSearchTime(value)
+}
+
+// historyRange parses from, to and step of a query; without step, long ranges are
+// downsampled so a series stays within metricsMaxPoints points
+func (p *MetricsPlugin) historyRange(c *fiber.Ctx) (from, to time.Time, step int64, err error) {
+	now := time.Now()
+	if to, err = parseMetricsTime(c.Query("to"), now, now); err != nil {
+		return from, to, 0, fmt.Errorf("invalid to: %w", err)
+	}
+	if from, err = parseMetricsTime(c.Query("from"), now, to.Add(-metricsDefaultRange)); err != nil {
+		return from, to, 0, fmt.Errorf("invalid from: %w", err)
+	}
+	if !from.Before(to) {
+		return from, to, 0, fmt.Errorf("from must be before to")
+	}
+
+	span := int64(to.Sub(from).Seconds())
+	if c.Query("step") != "" {
+		step = int64(c.QueryInt("step"))
+		if step <= 0 {
+			return from, to, 0, fmt.Errorf("step must be a positive number of seconds")
+		}
+	} else if interval := int64(p.getConfig().Interval); span/interval > metricsMaxPoints {
+		step = (span + metricsMaxPoints - 1) / metricsMaxPoints
+	}
+	return from, to, step, nil
+}
+
+// handleStatus handles GET /api/metrics/status
+func (p *MetricsPlugin) handleStatus(c *fiber.Ctx) error {
+	cfg := p.getConfig()
+	days, size := p.getStore().usage()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	data := fiber.Map{
+		"dir":       cfg.Dir,
+		"interval":  cfg.Interval,
+		"retention": cfg.Retention,
+		"samples":   p.samples,
+		"days":      days,
+		"size":      size,
+	}
+	if !p.lastAt.IsZero() {
+		data["last_sample"] = p.lastAt
+	}
+	if p.lastErr != "" {
+		data["error"] = p.lastErr
+	}
+	return SendSuccess(c, data, "")
+}
+
+// handleNames handles GET /api/metrics/names
+// Lists the metrics of the most recent sample
+func (p *MetricsPlugin) handleNames(c *fiber.Ctx) error {
+	return SendSuccess(c, p.getStore().names(), "")
+}
+
+// handleHistory handles GET /api/metrics/history?metrics=system.*,docker.running&from=6h&to=&step=
+// from and to are RFC 3339, Unix seconds or a duration before now (default: the last hour).
+// With step the values are averaged over step seconds.
+func (p *MetricsPlugin) handleHistory(c *fiber.Ctx) error {
+	patterns := strings.Split(c.Query("metrics", "*"), ",")
+	from, to, step, err := p.historyRange(c)
+	if err != nil {
+		return SendErrorMessage(c, 400, err.Error())
+	}
+
+	series, err := p.getStore().query(from, to, patterns, step)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	return SendSuccess(c, fiber.Map{
+		"from":   from.Unix(),
+		"to":     to.Unix(),
+		"step":   step,
+		"series": series,
+	}, "")
+}
+
+// handleEvents handles GET /api/metrics/events?type=hardware.,docker.&from=24h&to=&limit=500
+// Returns the recorded bus events, oldest first
+func (p *MetricsPlugin) handleEvents(c *fiber.Ctx) error {
+	var filters []string
+	if typeParam := c.Query("type"); typeParam != "" {
+		filters = strings.Split(typeParam, ",")
+	}
+	from, to, _, err := p.historyRange(c)
+	if err != nil {
+		return SendErrorMessage(c, 400, err.Error())
+	}
+	limit := c.QueryInt("limit", metricsMaxEvents)
+	if limit <= 0 || limit > metricsMaxEvents {
+		limit = metricsMaxEvents
+	}
+
+	events, err := p.getStore().queryEvents(from, to, filters, limit)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	return SendSuccess(c, events, "")
+}
+
+// normalizeMetricsConfig fills in defaults
+func normalizeMetricsConfig(cfg MetricsConfig) MetricsConfig {
+	if cfg.Dir == "" {
+		cfg.Dir = DefaultMetricsDir
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultMetricsInterval
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = DefaultMetricsRetention
+	}
+	if len(cfg.DiskPaths) == 0 {
+		cfg.DiskPaths = []string{"/"}
+	}
+	return cfg
+}
+
+// validateMetricsConfig checks the sampling interval
+func validateMetricsConfig(cfg MetricsConfig) error {
+	if cfg.Interval < 5 {
+		return fmt.Errorf("metrics.interval must be at least 5 seconds")
+	}
+	return nil
+}
+
+// Validate checks the settings after filling in defaults
+func (cfg MetricsConfig) Validate() error {
+	return validateMetricsConfig(normalizeMetricsConfig(cfg))
+}
+
+// Register the plugin
+func init() {
+	Register("metrics", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[MetricsConfig]("metrics", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewMetricsPlugin(cfg)
+	}, "dockerclient")
+}
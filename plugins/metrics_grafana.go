@@ -0,0 +1,190 @@
+package plugins
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// grafanaRange is the time range of a Grafana request
+type grafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// grafanaQuery is the body of POST /query
+type grafanaQuery struct {
+	Range         grafanaRange `json:"range"`
+	IntervalMs    int64        `json:"intervalMs"`
+	MaxDataPoints int64        `json:"maxDataPoints"`
+	Targets       []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+		Hide   bool   `json:"hide"`
+	} `json:"targets"`
+}
+
+// grafanaSeries is a time series in the datasource response, datapoints as [value, unix ms]
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	RefID      string       `json:"refId,omitempty"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// grafanaAnnotation is an event in the annotations response
+type grafanaAnnotation struct {
+	Annotation json.RawMessage `json:"annotation"`
+	Time       int64           `json:"time"` // unix ms
+	Title      string          `json:"title"`
+	Text       string          `json:"text"`
+	Tags       []string        `json:"tags"`
+}
+
+// grafanaStep returns the averaging step for a Grafana query in seconds
+// The panel interval is used when it is longer than the sampling interval, and
+// widened when the range would still have more points than the panel can show.
+func (p *MetricsPlugin) grafanaStep(query grafanaQuery) int64 {
+	var step int64
+	resolution := int64(p.getConfig().Interval)
+	if seconds := query.IntervalMs / 1000; seconds > resolution {
+		step, resolution = seconds, seconds
+	}
+	maxPoints := query.MaxDataPoints
+	if maxPoints <= 0 || maxPoints > metricsMaxPoints {
+		maxPoints = metricsMaxPoints
+	}
+	if span := int64(query.Range.To.Sub(query.Range.From).Seconds()); span/resolution > maxPoints {
+		step = (span + maxPoints - 1) / maxPoints
+	}
+	return step
+}
+
+// handleGrafanaTest handles GET /api/metrics/grafana/, the datasource connection test
+func (p *MetricsPlugin) handleGrafanaTest(c *fiber.Ctx) error {
+	return c.SendString("OK")
+}
+
+// searchMetrics returns the metric names containing text, or matching it when it has a *
+func (p *MetricsPlugin) searchMetrics(text string) []string {
+	names := p.getStore().names()
+	if text == "" {
+		return names
+	}
+	found := []string{}
+	for _, name := range names {
+		if strings.Contains(text, "*") && matchMetric(text, name) || strings.Contains(name, text) {
+			found = append(found, name)
+		}
+	}
+	return found
+}
+
+// handleGrafanaSearch handles POST /api/metrics/grafana/search {"target": "system."}
+func (p *MetricsPlugin) handleGrafanaSearch(c *fiber.Ctx) error {
+	var req struct {
+		Target string `json:"target"`
+	}
+	if len(c.Body()) > 0 {
+		if err := json.Unmarshal(c.Body(), &req); err != nil {
+			return SendErrorMessage(c, 400, "Invalid request body: "+err.Error())
+		}
+	}
+	return c.JSON(p.searchMetrics(req.Target))
+}
+
+// handleGrafanaMetrics handles POST /api/metrics/grafana/metrics {"metric": ""}
+// Lists the metrics for the newer JSON datasource protocol
+func (p *MetricsPlugin) handleGrafanaMetrics(c *fiber.Ctx) error {
+	type option struct {
+		Label string `json:"label"`
+		Value string `json:"value"`
+	}
+	names := p.getStore().names()
+	options := make([]option, len(names))
+	for i, name := range names {
+		options[i] = option{Label: name, Value: name}
+	}
+	return c.JSON(options)
+}
+
+// handleGrafanaQuery handles POST /api/metrics/grafana/query
+// Targets may use * to select several metrics; every matching metric becomes a series
+func (p *MetricsPlugin) handleGrafanaQuery(c *fiber.Ctx) error {
+	var query grafanaQuery
+	if err := json.Unmarshal(c.Body(), &query); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body: "+err.Error())
+	}
+	if !query.Range.From.Before(query.Range.To) {
+		return SendErrorMessage(c, 400, "range.from must be before range.to")
+	}
+	step := p.grafanaStep(query)
+
+	result := []grafanaSeries{}
+	for _, target := range query.Targets {
+		if target.Hide || target.Target == "" {
+			continue
+		}
+		series, err := p.getStore().query(query.Range.From, query.Range.To, []string{target.Target}, step)
+		if err != nil {
+			return SendError(c, 500, err)
+		}
+		names := make([]string, 0, len(series))
+		for name := range series {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			points := series[name]
+			datapoints := make([][2]float64, len(points))
+			for i, point := range points {
+				datapoints[i] = [2]float64{point.Value, float64(point.Time * 1000)}
+			}
+			result = append(result, grafanaSeries{Target: name, RefID: target.RefID, Datapoints: datapoints})
+		}
+	}
+	return c.JSON(result)
+}
+
+// handleGrafanaAnnotations handles POST /api/metrics/grafana/annotations
+// The annotation query is a comma-separated list of event type prefixes (empty = all recorded events)
+func (p *MetricsPlugin) handleGrafanaAnnotations(c *fiber.Ctx) error {
+	var req struct {
+		Range      grafanaRange    `json:"range"`
+		Annotation json.RawMessage `json:"annotation"`
+	}
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body: "+err.Error())
+	}
+	var annotation struct {
+		Query string `json:"query"`
+	}
+	json.Unmarshal(req.Annotation, &annotation)
+	var filters []string
+	if annotation.Query != "" {
+		filters = strings.Split(annotation.Query, ",")
+	}
+
+	events, err := p.getStore().queryEvents(req.Range.From, req.Range.To, filters, metricsMaxEvents)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	result := make([]grafanaAnnotation, len(events))
+	for i, event := range events {
+		text := ""
+		if event.Data != nil {
+			data, _ := json.Marshal(event.Data)
+			text = string(data)
+		}
+		result[i] = grafanaAnnotation{
+			Annotation: req.Annotation,
+			Time:       event.Time.UnixMilli(),
+			Title:      event.Type,
+			Text:       text,
+			Tags:       []string{event.Source, event.Type},
+		}
+	}
+	return c.JSON(result)
+}
@@ -0,0 +1,337 @@
+package plugins
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics store layout: append-only files of JSON lines, one per UTC day and kind
+const (
+	metricsFilePrefix = "metrics-"
+	eventsFilePrefix  = "events-"
+	metricsFileSuffix = ".jsonl"
+	metricsFileDay    = "2006-01-02"
+	metricsMaxLine    = 1 << 20
+)
+
+// metricsSample is one line of a metrics day file
+type metricsSample struct {
+	Time   int64              `json:"t"` // unix seconds
+	Values map[string]float64 `json:"v"`
+}
+
+// MetricsPoint is a value of a series at a point in time
+type MetricsPoint struct {
+	Time  int64   `json:"t"` // unix seconds, the start of the bucket when downsampled
+	Value float64 `json:"v"`
+}
+
+// dayFiles appends lines to one file per UTC day
+type dayFiles struct {
+	dir    string
+	prefix string
+	file   *os.File
+	day    string
+}
+
+// path returns the file holding a day's lines
+func (d *dayFiles) path(day string) string {
+	return filepath.Join(d.dir, d.prefix+day+metricsFileSuffix)
+}
+
+// append writes a line to the file of the day t falls on
+func (d *dayFiles) append(t time.Time, line []byte) error {
+	day := t.UTC().Format(metricsFileDay)
+	if d.file == nil || d.day != day {
+		d.close()
+		file, err := os.OpenFile(d.path(day), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		d.file, d.day = file, day
+	}
+	_, err := d.file.Write(append(line, '\n'))
+	return err
+}
+
+// close closes the current day file
+func (d *dayFiles) close() {
+	if d.file != nil {
+		d.file.Close()
+		d.file = nil
+	}
+}
+
+// days returns the days with a file, oldest first
+func (d *dayFiles) days() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(d.dir, d.prefix+"*"+metricsFileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	days := make([]string, 0, len(paths))
+	for _, path := range paths {
+		day := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), d.prefix), metricsFileSuffix)
+		if _, err := time.Parse(metricsFileDay, day); err == nil {
+			days = append(days, day)
+		}
+	}
+	sort.Strings(days)
+	return days, nil
+}
+
+// prune removes the files of days before cutoff
+func (d *dayFiles) prune(cutoff string) (int, error) {
+	days, err := d.days()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, day := range days {
+		if day >= cutoff {
+			break
+		}
+		if err := os.Remove(d.path(day)); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// scan calls fn with every line of the files of the days from..to
+func (d *dayFiles) scan(from, to time.Time, fn func([]byte)) error {
+	for day := from.UTC().Truncate(24 * time.Hour); !day.After(to); day = day.Add(24 * time.Hour) {
+		f, err := os.Open(d.path(day.Format(metricsFileDay)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), metricsMaxLine)
+		for scanner.Scan() {
+			fn(scanner.Bytes())
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// metricsStore keeps the samples and the recorded bus events
+type metricsStore struct {
+	mu      sync.Mutex
+	samples dayFiles
+	events  dayFiles
+	last    metricsSample // most recent sample, for the list of metric names
+}
+
+// newMetricsStore creates the store directory
+func newMetricsStore(dir string) (*metricsStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create metrics directory: %w", err)
+	}
+	return &metricsStore{
+		samples: dayFiles{dir: dir, prefix: metricsFilePrefix},
+		events:  dayFiles{dir: dir, prefix: eventsFilePrefix},
+	}, nil
+}
+
+// append writes a sample to the file of its day
+func (s *metricsStore) append(sample metricsSample) error {
+	line, err := json.Marshal(sample)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = sample
+	return s.samples.append(time.Unix(sample.Time, 0), line)
+}
+
+// appendEvent records a bus event
+func (s *metricsStore) appendEvent(event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.events.append(event.Time, line)
+}
+
+// close closes the current day files
+func (s *metricsStore) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples.close()
+	s.events.close()
+}
+
+// names returns the metric names of the most recent sample
+func (s *metricsStore) names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.last.Values))
+	for name := range s.last.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// prune removes the day files older than the retention
+func (s *metricsStore) prune(retention time.Duration) (int, error) {
+	cutoff := time.Now().UTC().Add(-retention).Format(metricsFileDay)
+	removed, err := s.samples.prune(cutoff)
+	if err != nil {
+		return removed, err
+	}
+	n, err := s.events.prune(cutoff)
+	return removed + n, err
+}
+
+// usage returns the number of days with samples and the total size of all files in bytes
+func (s *metricsStore) usage() (int, int64) {
+	var size int64
+	count := 0
+	for _, files := range []*dayFiles{&s.samples, &s.events} {
+		days, _ := files.days()
+		if files == &s.samples {
+			count = len(days)
+		}
+		for _, day := range days {
+			if info, err := os.Stat(files.path(day)); err == nil {
+				size += info.Size()
+			}
+		}
+	}
+	return count, size
+}
+
+// matchMetric matches a metric name against a pattern where * matches any text
+func matchMetric(pattern, name string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == name
+	}
+	if !strings.HasPrefix(name, parts[0]) {
+		return false
+	}
+	name = name[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(name, part)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(part):]
+	}
+	return strings.HasSuffix(name, parts[len(parts)-1])
+}
+
+// metricsAccumulator averages the samples of a series falling into one bucket
+type metricsAccumulator struct {
+	bucket int64
+	sum    float64
+	count  int
+}
+
+// query returns the series matching any of the patterns within [from, to]
+// With step > 0 the samples are averaged in buckets of step seconds.
+func (s *metricsStore) query(from, to time.Time, patterns []string, step int64) (map[string][]MetricsPoint, error) {
+	series := map[string][]MetricsPoint{}
+	pending := map[string]*metricsAccumulator{}
+	flush := func(name string, acc *metricsAccumulator) {
+		series[name] = append(series[name], MetricsPoint{Time: acc.bucket, Value: acc.sum / float64(acc.count)})
+	}
+	matched := map[string]bool{}
+	matches := func(name string) bool {
+		ok, seen := matched[name]
+		if !seen {
+			for _, pattern := range patterns {
+				if ok = matchMetric(strings.TrimSpace(pattern), name); ok {
+					break
+				}
+			}
+			matched[name] = ok
+		}
+		return ok
+	}
+
+	start, end := from.Unix(), to.Unix()
+	err := s.samples.scan(from, to, func(line []byte) {
+		var sample metricsSample
+		// A line cut short by a power loss is skipped
+		if err := json.Unmarshal(line, &sample); err != nil {
+			return
+		}
+		if sample.Time < start || sample.Time > end {
+			return
+		}
+		for name, value := range sample.Values {
+			if math.IsNaN(value) || !matches(name) {
+				continue
+			}
+			if step <= 0 {
+				series[name] = append(series[name], MetricsPoint{Time: sample.Time, Value: value})
+				continue
+			}
+			bucket := sample.Time - (sample.Time-start)%step
+			acc := pending[name]
+			if acc != nil && acc.bucket != bucket {
+				flush(name, acc)
+				acc = nil
+			}
+			if acc == nil {
+				acc = &metricsAccumulator{bucket: bucket}
+				pending[name] = acc
+			}
+			acc.sum += value
+			acc.count++
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for name, acc := range pending {
+		flush(name, acc)
+	}
+	return series, nil
+}
+
+// queryEvents returns the recorded events within [from, to] matching the type prefixes
+func (s *metricsStore) queryEvents(from, to time.Time, filters []string, limit int) ([]Event, error) {
+	events := []Event{}
+	err := s.events.scan(from, to, func(line []byte) {
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			return
+		}
+		if event.Time.Before(from) || event.Time.After(to) || !matchesEventFilter(event.Type, filters) {
+			return
+		}
+		events = append(events, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The most recent events are kept
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return events, nil
+}
@@ -0,0 +1,648 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gofiber/fiber/v2"
+)
+
+// MQTT defaults
+const (
+	DefaultMQTTBroker      = "tcp://localhost:1883"
+	DefaultMQTTTopicPrefix = "linht"
+	DefaultMQTTInterval    = 30 // seconds between telemetry publications
+	mqttConnectTimeout     = 10 * time.Second
+	mqttCommandTimeout     = 30 * time.Second
+	mqttStatusOnline       = "online"
+	mqttStatusOffline      = "offline"
+)
+
+// MQTT command names
+const (
+	MQTTCommandContainerStart   = "container.start"
+	MQTTCommandContainerStop    = "container.stop"
+	MQTTCommandContainerRestart = "container.restart"
+	MQTTCommandServiceStart     = "service.start"
+	MQTTCommandServiceStop      = "service.stop"
+	MQTTCommandServiceRestart   = "service.restart"
+	MQTTCommandTelemetryRefresh = "telemetry.refresh"
+)
+
+// mqttCommandFunc executes a command and returns its result
+type mqttCommandFunc func(p *MQTTPlugin, ctx context.Context, cmd mqttCommand) (interface{}, error)
+
+// mqttCommands lists every command the bridge can accept; only those enabled in mqtt.commands are subscribed
+var mqttCommands = map[string]mqttCommandFunc{
+	MQTTCommandContainerStart:   (*MQTTPlugin).containerCommand,
+	MQTTCommandContainerStop:    (*MQTTPlugin).containerCommand,
+	MQTTCommandContainerRestart: (*MQTTPlugin).containerCommand,
+	MQTTCommandServiceStart:     (*MQTTPlugin).serviceCommand,
+	MQTTCommandServiceStop:      (*MQTTPlugin).serviceCommand,
+	MQTTCommandServiceRestart:   (*MQTTPlugin).serviceCommand,
+	MQTTCommandTelemetryRefresh: (*MQTTPlugin).refreshCommand,
+}
+
+// MQTTConfig holds MQTT bridge configuration
+type MQTTConfig struct {
+	Broker      string   `yaml:"broker"`
+	ClientID    string   `yaml:"client_id"`
+	Username    string   `yaml:"username"`
+	Password    string   `yaml:"password"`
+	TopicPrefix string   `yaml:"topic_prefix"`
+	Interval    int      `yaml:"interval"`
+	QoS         int      `yaml:"qos"`
+	Retain      bool     `yaml:"retain"`
+	Events      []string `yaml:"events"`   // bus event type prefixes to forward (empty = all)
+	Commands    []string `yaml:"commands"` // accepted commands
+
+	// Command targets taken from the docker and services sections
+	DockerClient         *client.Client `yaml:"-"`
+	ContainerStopTimeout int            `yaml:"-"`
+	ServicePrefix        string         `yaml:"-"`
+}
+
+// mqttCommand is the JSON payload of a command message
+type mqttCommand struct {
+	Name    string          `json:"name"`
+	ID      json.RawMessage `json:"id,omitempty"` // echoed in the result for correlation
+	command string
+}
+
+// mqttCommandResult is published to <prefix>/command/<name>/result
+type mqttCommandResult struct {
+	Command string          `json:"command"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Success bool            `json:"success"`
+	Data    interface{}     `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Time    time.Time       `json:"time"`
+}
+
+// ThermalZone is a temperature reading from the kernel thermal framework
+type ThermalZone struct {
+	Name        string  `json:"name"`
+	Type        string  `json:"type"`
+	Temperature float64 `json:"temperature"` // °C
+}
+
+// MQTTPlugin bridges telemetry and a restricted command set to an MQTT broker
+type MQTTPlugin struct {
+	config   MQTTConfig
+	services *ServicesPlugin
+	client   mqtt.Client
+	refresh  chan struct{}
+	stopChan chan struct{}
+	doneChan chan struct{}
+	started  bool // the bridge runs, set by Start
+
+	// Subscribed for the lifetime of the plugin, so events published before Start or during a reconnect are forwarded
+	events      <-chan Event
+	unsubscribe func()
+
+	mu          sync.RWMutex
+	lastPublish time.Time
+	lastErr     string
+	published   uint64
+	commands    uint64
+}
+
+// NewMQTTPlugin creates a new MQTT plugin instance
+func NewMQTTPlugin(cfg MQTTConfig) (*MQTTPlugin, error) {
+	cfg = normalizeMQTTConfig(cfg)
+	if err := validateMQTTConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	services, err := NewServicesPlugin(cfg.ServicePrefix, "")
+	if err != nil {
+		return nil, err
+	}
+
+	events, unsubscribe := Events.Subscribe()
+	return &MQTTPlugin{
+		config:      cfg,
+		services:    services,
+		events:      events,
+		unsubscribe: unsubscribe,
+	}, nil
+}
+
+// Start connects in the background and begins forwarding telemetry and bus events
+func (p *MQTTPlugin) Start() error {
+	p.mu.Lock()
+	p.started = true
+	p.mu.Unlock()
+
+	p.start()
+	return nil
+}
+
+// Name returns the plugin identifier
+func (p *MQTTPlugin) Name() string {
+	return "mqtt"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *MQTTPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/mqtt")
+
+	api.Get("/status", p.handleStatus)
+	api.Post("/publish", p.handlePublish)
+}
+
+// Shutdown publishes the offline status and disconnects
+func (p *MQTTPlugin) Shutdown() error {
+	p.stop()
+	p.unsubscribe()
+	return nil
+}
+
+// Reload reconnects with the new broker, topic and command settings
+func (p *MQTTPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[MQTTConfig]("mqtt", config)
+	if err != nil {
+		return err
+	}
+	cfg = normalizeMQTTConfig(cfg)
+	if err := validateMQTTConfig(cfg); err != nil {
+		return err
+	}
+
+	if err := p.services.Reload(ServicesConfig{Prefix: cfg.ServicePrefix}); err != nil {
+		return err
+	}
+
+	// Every reload reaches all plugins, so only reconnect when the settings changed
+	changed := !reflect.DeepEqual(cfg, p.getConfig())
+	if changed {
+		p.stop()
+		p.mu.Lock()
+		p.config = cfg
+		started := p.started
+		p.mu.Unlock()
+		if started {
+			p.start()
+		}
+	}
+
+	slog.Info("MQTT config reloaded",
+		"broker", cfg.Broker,
+		"topic_prefix", cfg.TopicPrefix,
+		"interval", cfg.Interval,
+		"commands", cfg.Commands,
+		"restarted", changed)
+	return nil
+}
+
+// getConfig returns the current configuration
+func (p *MQTTPlugin) getConfig() MQTTConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config
+}
+
+// start creates the client and launches the telemetry loop
+// The client keeps retrying so a broker that is down at boot is picked up later
+func (p *MQTTPlugin) start() {
+	cfg := p.getConfig()
+	statusTopic := cfg.TopicPrefix + "/status"
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetWill(statusTopic, mqttStatusOffline, byte(cfg.QoS), true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOrderMatters(false).
+		SetOnConnectHandler(func(c mqtt.Client) {
+			slog.Info("MQTT connected", "broker", cfg.Broker)
+			p.setError(nil)
+			c.Publish(statusTopic, byte(cfg.QoS), true, mqttStatusOnline)
+			p.subscribeCommands(c, cfg)
+			p.requestRefresh()
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			slog.Warn("MQTT connection lost", "broker", cfg.Broker, "error", err)
+			p.setError(err)
+		})
+
+	client := mqtt.NewClient(opts)
+	refresh := make(chan struct{}, 1)
+	stopChan := make(chan struct{})
+	doneChan := make(chan struct{})
+
+	p.mu.Lock()
+	p.client = client
+	p.refresh = refresh
+	p.stopChan = stopChan
+	p.doneChan = doneChan
+	p.mu.Unlock()
+
+	client.Connect()
+	slog.Info("MQTT bridge started", "broker", cfg.Broker, "topic_prefix", cfg.TopicPrefix)
+	go p.run(cfg, client, refresh, stopChan, doneChan)
+}
+
+// stop terminates the telemetry loop and disconnects cleanly
+func (p *MQTTPlugin) stop() {
+	p.mu.RLock()
+	client, stopChan, doneChan := p.client, p.stopChan, p.doneChan
+	p.mu.RUnlock()
+	if stopChan == nil {
+		return
+	}
+
+	close(stopChan)
+	<-doneChan
+
+	// The will is only sent on unexpected disconnects, so announce a clean shutdown
+	if client.IsConnectionOpen() {
+		cfg := p.getConfig()
+		client.Publish(cfg.TopicPrefix+"/status", byte(cfg.QoS), true, mqttStatusOffline).WaitTimeout(mqttConnectTimeout)
+	}
+	client.Disconnect(250)
+	slog.Info("MQTT bridge stopped")
+}
+
+// run publishes telemetry periodically and forwards bus events until stopped
+func (p *MQTTPlugin) run(cfg MQTTConfig, client mqtt.Client, refresh <-chan struct{}, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(time.Duration(cfg.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.publishTelemetry(cfg, client)
+		case <-refresh:
+			p.publishTelemetry(cfg, client)
+		case event := <-p.events:
+			if !client.IsConnectionOpen() || !matchesEventFilter(event.Type, cfg.Events) {
+				continue
+			}
+			p.publish(cfg, client, "events/"+event.Type, event, false)
+		}
+	}
+}
+
+// requestRefresh schedules an immediate telemetry publication
+func (p *MQTTPlugin) requestRefresh() {
+	p.mu.RLock()
+	refresh := p.refresh
+	p.mu.RUnlock()
+
+	select {
+	case refresh <- struct{}{}:
+	default:
+	}
+}
+
+// publishTelemetry collects and publishes all telemetry topics
+// Sources that are unavailable are skipped
+func (p *MQTTPlugin) publishTelemetry(cfg MQTTConfig, client mqtt.Client) {
+	if !client.IsConnectionOpen() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mqttCommandTimeout)
+	defer cancel()
+
+	if cfg.DockerClient != nil {
+		if containers, err := p.containerTelemetry(ctx, cfg.DockerClient); err != nil {
+			slog.Debug("MQTT container telemetry failed", "error", err)
+		} else {
+			p.publish(cfg, client, "telemetry/containers", containers, cfg.Retain)
+		}
+	}
+
+	if services, err := p.services.list(ctx); err != nil {
+		slog.Debug("MQTT service telemetry failed", "error", err)
+	} else {
+		p.publish(cfg, client, "telemetry/services", services, cfg.Retain)
+	}
+
+	if status, ok := CurrentHardwareStatus(); ok {
+		p.publish(cfg, client, "telemetry/hardware", status, cfg.Retain)
+	}
+
+	sensors := map[string]interface{}{
+		"thermal": readThermalZones(),
+	}
+	if fix, ok := CurrentGNSSFix(); ok {
+		sensors["gnss"] = fix
+	}
+	p.publish(cfg, client, "telemetry/sensors", sensors, cfg.Retain)
+}
+
+// containerTelemetry returns the state of all containers
+func (p *MQTTPlugin) containerTelemetry(ctx context.Context, cli *client.Client) ([]map[string]interface{}, error) {
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]map[string]interface{}, len(containers))
+	for i, cont := range containers {
+		name := cont.ID[:12]
+		if len(cont.Names) > 0 {
+			name = strings.TrimPrefix(cont.Names[0], "/")
+		}
+		result[i] = map[string]interface{}{
+			"id":     cont.ID,
+			"name":   name,
+			"image":  cont.Image,
+			"state":  cont.State,
+			"status": cont.Status,
+			"health": healthFromStatus(cont.Status),
+		}
+	}
+	return result, nil
+}
+
+// publish marshals data and publishes it below the topic prefix
+func (p *MQTTPlugin) publish(cfg MQTTConfig, client mqtt.Client, topic string, data interface{}, retain bool) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("Failed to encode MQTT payload", "topic", topic, "error", err)
+		return
+	}
+
+	token := client.Publish(cfg.TopicPrefix+"/"+topic, byte(cfg.QoS), retain, payload)
+	if token.WaitTimeout(mqttConnectTimeout) && token.Error() != nil {
+		slog.Warn("MQTT publish failed", "topic", topic, "error", token.Error())
+		p.setError(token.Error())
+		return
+	}
+
+	p.mu.Lock()
+	p.lastPublish = time.Now()
+	p.published++
+	p.mu.Unlock()
+}
+
+// subscribeCommands subscribes to the command topics enabled in the configuration
+func (p *MQTTPlugin) subscribeCommands(client mqtt.Client, cfg MQTTConfig) {
+	for _, name := range cfg.Commands {
+		name := name
+		topic := cfg.TopicPrefix + "/command/" + name
+		token := client.Subscribe(topic, byte(cfg.QoS), func(c mqtt.Client, msg mqtt.Message) {
+			p.handleCommand(cfg, c, name, msg.Payload())
+		})
+		if token.WaitTimeout(mqttConnectTimeout) && token.Error() != nil {
+			slog.Error("MQTT subscribe failed", "topic", topic, "error", token.Error())
+		}
+	}
+}
+
+// handleCommand executes a command message and publishes the result
+func (p *MQTTPlugin) handleCommand(cfg MQTTConfig, client mqtt.Client, name string, payload []byte) {
+	var cmd mqttCommand
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &cmd); err != nil {
+			p.publishResult(cfg, client, name, cmd, nil, fmt.Errorf("invalid command payload: %w", err))
+			return
+		}
+	}
+	cmd.command = name
+
+	p.mu.Lock()
+	p.commands++
+	p.mu.Unlock()
+
+	// Only the telemetry refresh is served in read-only mode, as on the REST API
+	if ReadOnlyMode() && name != MQTTCommandTelemetryRefresh {
+		slog.Warn("MQTT command refused", "command", name, "name", cmd.Name, "error", ErrReadOnlyMode)
+		p.publishResult(cfg, client, name, cmd, nil, ErrReadOnlyMode)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mqttCommandTimeout)
+	defer cancel()
+
+	data, err := mqttCommands[name](p, ctx, cmd)
+	if err != nil {
+		slog.Warn("MQTT command failed", "command", name, "name", cmd.Name, "error", err)
+	} else {
+		slog.Info("MQTT command executed", "command", name, "name", cmd.Name)
+	}
+	p.publishResult(cfg, client, name, cmd, data, err)
+}
+
+// publishResult publishes the outcome of a command
+func (p *MQTTPlugin) publishResult(cfg MQTTConfig, client mqtt.Client, name string, cmd mqttCommand, data interface{}, err error) {
+	result := mqttCommandResult{
+		Command: name,
+		ID:      cmd.ID,
+		Success: err == nil,
+		Data:    data,
+		Time:    time.Now(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	p.publish(cfg, client, "command/"+name+"/result", result, false)
+}
+
+// containerCommand starts, stops or restarts a container by name or ID
+func (p *MQTTPlugin) containerCommand(ctx context.Context, cmd mqttCommand) (interface{}, error) {
+	cfg := p.getConfig()
+	if cfg.DockerClient == nil {
+		return nil, fmt.Errorf("docker is not available")
+	}
+	if cmd.Name == "" {
+		return nil, fmt.Errorf("container name is required")
+	}
+
+	timeout := cfg.ContainerStopTimeout
+	var err error
+	switch cmd.command {
+	case MQTTCommandContainerStart:
+		err = cfg.DockerClient.ContainerStart(ctx, cmd.Name, container.StartOptions{})
+	case MQTTCommandContainerStop:
+		err = cfg.DockerClient.ContainerStop(ctx, cmd.Name, container.StopOptions{Timeout: &timeout})
+	case MQTTCommandContainerRestart:
+		err = cfg.DockerClient.ContainerRestart(ctx, cmd.Name, container.StopOptions{Timeout: &timeout})
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.requestRefresh()
+	return nil, nil
+}
+
+// serviceCommand starts, stops or restarts a systemd unit matching the services prefix
+func (p *MQTTPlugin) serviceCommand(ctx context.Context, cmd mqttCommand) (interface{}, error) {
+	if err := p.services.validateServiceName(cmd.Name); err != nil {
+		return nil, err
+	}
+
+	action := strings.TrimPrefix(cmd.command, "service.")
+	output, err := exec.CommandContext(ctx, "systemctl", action, unitName(cmd.Name)).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s service: %s", action, strings.TrimSpace(string(output)))
+	}
+	p.requestRefresh()
+
+	// Report the resulting state; the command itself already succeeded
+	info, err := p.services.getServiceInfo(ctx, cmd.Name)
+	if err != nil {
+		return nil, nil
+	}
+	return info, nil
+}
+
+// refreshCommand publishes all telemetry immediately
+func (p *MQTTPlugin) refreshCommand(ctx context.Context, cmd mqttCommand) (interface{}, error) {
+	p.requestRefresh()
+	return nil, nil
+}
+
+// setError records the last connection or publish error
+func (p *MQTTPlugin) setError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil {
+		p.lastErr = ""
+		return
+	}
+	p.lastErr = err.Error()
+}
+
+// handleStatus handles GET /api/mqtt/status
+func (p *MQTTPlugin) handleStatus(c *fiber.Ctx) error {
+	cfg := p.getConfig()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	status := fiber.Map{
+		"connected":    p.client.IsConnectionOpen(),
+		"broker":       cfg.Broker,
+		"client_id":    cfg.ClientID,
+		"topic_prefix": cfg.TopicPrefix,
+		"interval":     cfg.Interval,
+		"commands":     cfg.Commands,
+		"published":    p.published,
+		"received":     p.commands,
+	}
+	if !p.lastPublish.IsZero() {
+		status["last_publish"] = p.lastPublish
+	}
+	if p.lastErr != "" {
+		status["error"] = p.lastErr
+	}
+	return SendSuccess(c, status, "")
+}
+
+// handlePublish handles POST /api/mqtt/publish
+// Publishes all telemetry immediately
+func (p *MQTTPlugin) handlePublish(c *fiber.Ctx) error {
+	p.mu.RLock()
+	connected := p.client.IsConnectionOpen()
+	p.mu.RUnlock()
+
+	if !connected {
+		return SendErrorMessage(c, 503, "Not connected to the MQTT broker")
+	}
+	p.requestRefresh()
+	return SendSuccess(c, nil, "Telemetry publication requested")
+}
+
+// readThermalZones reads all kernel thermal zones, skipping unreadable ones
+func readThermalZones() []ThermalZone {
+	zones := []ThermalZone{}
+	paths, _ := filepath.Glob("/sys/class/thermal/thermal_zone*")
+	for _, path := range paths {
+		raw, err := os.ReadFile(filepath.Join(path, "temp"))
+		if err != nil {
+			continue
+		}
+		milli, err := strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64)
+		if err != nil {
+			continue
+		}
+		zoneType, _ := os.ReadFile(filepath.Join(path, "type"))
+		zones = append(zones, ThermalZone{
+			Name:        filepath.Base(path),
+			Type:        strings.TrimSpace(string(zoneType)),
+			Temperature: float64(milli) / 1000,
+		})
+	}
+	return zones
+}
+
+// normalizeMQTTConfig fills in defaults
+func normalizeMQTTConfig(cfg MQTTConfig) MQTTConfig {
+	if cfg.Broker == "" {
+		cfg.Broker = DefaultMQTTBroker
+	}
+	if cfg.ClientID == "" {
+		hostname, _ := os.Hostname()
+		cfg.ClientID = "linht-web-" + hostname
+	}
+	cfg.TopicPrefix = strings.Trim(cfg.TopicPrefix, "/")
+	if cfg.TopicPrefix == "" {
+		cfg.TopicPrefix = DefaultMQTTTopicPrefix
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultMQTTInterval
+	}
+	if cfg.ContainerStopTimeout <= 0 {
+		cfg.ContainerStopTimeout = 10
+	}
+	if cfg.Commands == nil {
+		cfg.Commands = []string{MQTTCommandTelemetryRefresh}
+	}
+	return cfg
+}
+
+// validateMQTTConfig checks the QoS level, topic prefix and command names
+func validateMQTTConfig(cfg MQTTConfig) error {
+	if cfg.QoS < 0 || cfg.QoS > 2 {
+		return fmt.Errorf("invalid mqtt qos %d (use 0, 1 or 2)", cfg.QoS)
+	}
+	if strings.ContainsAny(cfg.TopicPrefix, "+#") {
+		return fmt.Errorf("mqtt topic_prefix must not contain wildcards")
+	}
+	for _, name := range cfg.Commands {
+		if _, ok := mqttCommands[name]; !ok {
+			return fmt.Errorf("unknown mqtt command %q", name)
+		}
+	}
+	return nil
+}
+
+// Validate checks the settings after filling in defaults
+func (cfg MQTTConfig) Validate() error {
+	return validateMQTTConfig(normalizeMQTTConfig(cfg))
+}
+
+// Register the plugin
+func init() {
+	Register("mqtt", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[MQTTConfig]("mqtt", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewMQTTPlugin(cfg)
+	}, "dockerclient")
+}
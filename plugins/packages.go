@@ -0,0 +1,437 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Package manager names
+const (
+	PackageManagerOpkg = "opkg"
+	PackageManagerApt  = "apt"
+)
+
+// Package plugin defaults
+const (
+	DefaultPackageInstallTimeout = 1800 // seconds
+	packageQueryTimeout          = 60 * time.Second
+	packageRefreshTimeout        = 5 * time.Minute
+	packageEventSource           = "packages"
+)
+
+// packageNamePattern restricts package names; a leading dash would be read as an option
+var packageNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9+._:-]*$`)
+
+// errPackageBusy is returned while another package operation holds the package database
+var errPackageBusy = errors.New("another package operation is running")
+
+// PackagesConfig holds packages plugin configuration
+type PackagesConfig struct {
+	Manager        string `yaml:"manager"`         // opkg or apt (empty = detect)
+	InstallTimeout int    `yaml:"install_timeout"` // seconds an install may run
+}
+
+// Package is an installed package
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// PackageUpdate is an installed package with a newer version available
+type PackageUpdate struct {
+	Name             string `json:"name"`
+	InstalledVersion string `json:"installed_version"`
+	Version          string `json:"version"`
+}
+
+// packageBackend holds the commands and output parsers of one package manager
+type packageBackend struct {
+	installed  []string
+	refresh    []string
+	upgradable []string
+	install    []string // package names are appended
+	env        []string
+
+	parseInstalled  func(output string) []Package
+	parseUpgradable func(output string) []PackageUpdate
+}
+
+// packageBackends lists the supported package managers
+var packageBackends = map[string]packageBackend{
+	PackageManagerOpkg: {
+		installed:       []string{"opkg", "list-installed"},
+		refresh:         []string{"opkg", "update"},
+		upgradable:      []string{"opkg", "list-upgradable"},
+		install:         []string{"opkg", "install"},
+		parseInstalled:  parseOpkgInstalled,
+		parseUpgradable: parseOpkgUpgradable,
+	},
+	PackageManagerApt: {
+		installed:       []string{"dpkg-query", "-W", "-f=${Package}\\t${Version}\\n"},
+		refresh:         []string{"apt-get", "update"},
+		upgradable:      []string{"apt", "list", "--upgradable"},
+		install:         []string{"apt-get", "install", "-y", "--no-install-recommends", "--"},
+		env:             []string{"DEBIAN_FRONTEND=noninteractive"},
+		parseInstalled:  parseDpkgInstalled,
+		parseUpgradable: parseAptUpgradable,
+	},
+}
+
+// PackagesPlugin lists, checks and installs system packages
+type PackagesPlugin struct {
+	config PackagesConfig
+	mu     sync.RWMutex
+	// Serializes refreshes and installs, which lock the package database
+	opMu sync.Mutex
+}
+
+// NewPackagesPlugin creates a new packages plugin instance
+func NewPackagesPlugin(cfg PackagesConfig) (*PackagesPlugin, error) {
+	cfg = normalizePackagesConfig(cfg)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Manager == "" {
+		slog.Warn("No supported package manager found", "supported", []string{PackageManagerOpkg, PackageManagerApt})
+	} else {
+		slog.Info("Package manager selected", "manager", cfg.Manager)
+	}
+	return &PackagesPlugin{config: cfg}, nil
+}
+
+// Name returns the plugin identifier
+func (p *PackagesPlugin) Name() string {
+	return "packages"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *PackagesPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/packages")
+
+	api.Get("/", p.handleList)
+	api.Get("/updates", p.handleUpdates)
+	api.Post("/install", p.handleInstall)
+}
+
+// Shutdown has nothing to release; a running install is left to finish
+func (p *PackagesPlugin) Shutdown() error {
+	return nil
+}
+
+// Reload applies a new package manager and timeout at runtime
+func (p *PackagesPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[PackagesConfig]("packages", config)
+	if err != nil {
+		return err
+	}
+	cfg = normalizePackagesConfig(cfg)
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.config = cfg
+	p.mu.Unlock()
+
+	slog.Info("Packages config reloaded", "manager", cfg.Manager, "install_timeout", cfg.InstallTimeout)
+	return nil
+}
+
+// Validate checks the package manager name
+func (cfg PackagesConfig) Validate() error {
+	if _, ok := packageBackends[cfg.Manager]; cfg.Manager != "" && !ok {
+		return fmt.Errorf("packages.manager must be %s or %s", PackageManagerOpkg, PackageManagerApt)
+	}
+	return nil
+}
+
+// backend returns the commands of the configured package manager
+func (p *PackagesPlugin) backend() (packageBackend, error) {
+	p.mu.RLock()
+	manager := p.config.Manager
+	p.mu.RUnlock()
+
+	backend, ok := packageBackends[manager]
+	if !ok {
+		return packageBackend{}, fmt.Errorf("no supported package manager found")
+	}
+	return backend, nil
+}
+
+// handleList handles GET /api/packages
+// Lists the installed packages; ?search= filters by name
+func (p *PackagesPlugin) handleList(c *fiber.Ctx) error {
+	backend, err := p.backend()
+	if err != nil {
+		return SendError(c, 503, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), packageQueryTimeout)
+	defer cancel()
+
+	output, err := runPackageCommand(ctx, backend, backend.installed)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+
+	search := strings.ToLower(c.Query("search"))
+	packages := []Package{}
+	for _, pkg := range backend.parseInstalled(output) {
+		if search == "" || strings.Contains(strings.ToLower(pkg.Name), search) {
+			packages = append(packages, pkg)
+		}
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Name < packages[j].Name })
+	return SendSuccess(c, packages, "")
+}
+
+// handleUpdates handles GET /api/packages/updates
+// Lists the packages with newer versions; ?refresh=true downloads the package lists first
+func (p *PackagesPlugin) handleUpdates(c *fiber.Ctx) error {
+	backend, err := p.backend()
+	if err != nil {
+		return SendError(c, 503, err)
+	}
+
+	if c.QueryBool("refresh") {
+		if !p.opMu.TryLock() {
+			return SendError(c, 409, errPackageBusy)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), packageRefreshTimeout)
+		_, err := runPackageCommand(ctx, backend, backend.refresh)
+		cancel()
+		p.opMu.Unlock()
+		if err != nil {
+			return SendError(c, 500, fmt.Errorf("failed to refresh package lists: %w", err))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), packageQueryTimeout)
+	defer cancel()
+
+	output, err := runPackageCommand(ctx, backend, backend.upgradable)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	updates := backend.parseUpgradable(output)
+	sort.Slice(updates, func(i, j int) bool { return updates[i].Name < updates[j].Name })
+	return SendSuccess(c, updates, "")
+}
+
+// handleInstall handles POST /api/packages/install
+// Installs or upgrades {"packages": [...]} and streams the package manager output via SSE
+// The install keeps running if the client disconnects, so the package database is not left half-done.
+func (p *PackagesPlugin) handleInstall(c *fiber.Ctx) error {
+	var req struct {
+		Packages []string `json:"packages"`
+	}
+	if err := c.BodyParser(&req); err != nil || len(req.Packages) == 0 {
+		return SendErrorMessage(c, 400, "Request body must list \"packages\"")
+	}
+	for _, name := range req.Packages {
+		if !packageNamePattern.MatchString(name) {
+			return SendErrorMessage(c, 400, fmt.Sprintf("Invalid package name %q", name))
+		}
+	}
+
+	backend, err := p.backend()
+	if err != nil {
+		return SendError(c, 503, err)
+	}
+	if !p.opMu.TryLock() {
+		return SendError(c, 409, errPackageBusy)
+	}
+
+	p.mu.RLock()
+	timeout := time.Duration(p.config.InstallTimeout) * time.Second
+	p.mu.RUnlock()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+
+	// Interleave stdout and stderr as the user would see them in a terminal
+	reader, writer := io.Pipe()
+	args := append(append([]string{}, backend.install[1:]...), req.Packages...)
+	cmd := exec.CommandContext(ctx, backend.install[0], args...)
+	cmd.Env = append(os.Environ(), backend.env...)
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if err := cmd.Start(); err != nil {
+		cancel()
+		p.opMu.Unlock()
+		return SendError(c, 500, fmt.Errorf("failed to start %s: %w", backend.install[0], err))
+	}
+
+	requestCtx := c.UserContext()
+	slog.InfoContext(requestCtx, "Package install started", "packages", req.Packages)
+	startTime := time.Now()
+
+	done := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		writer.Close()
+		cancel()
+		p.opMu.Unlock()
+		done <- err
+	}()
+
+	// Set SSE headers
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// Keep draining after the client is gone so the command never blocks on output
+		connected := true
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			if !connected {
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", scanner.Text())
+			if err := w.Flush(); err != nil {
+				connected = false
+			}
+		}
+		io.Copy(io.Discard, reader)
+
+		installErr := <-done
+		result := fiber.Map{"packages": req.Packages, "success": installErr == nil}
+		if installErr != nil {
+			result["error"] = installErr.Error()
+			slog.ErrorContext(requestCtx, "Package install failed",
+				"packages", req.Packages,
+				"error", installErr,
+				"duration", time.Since(startTime))
+		} else {
+			slog.InfoContext(requestCtx, "Package install completed",
+				"packages", req.Packages,
+				"duration", time.Since(startTime))
+		}
+		PublishEvent("packages.installed", packageEventSource, result)
+
+		event := "done"
+		if installErr != nil {
+			event = "error"
+		}
+		data, _ := json.Marshal(result)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		w.Flush()
+	})
+
+	return nil
+}
+
+// runPackageCommand runs a package manager query and returns its output
+func runPackageCommand(ctx context.Context, backend packageBackend, command []string) (string, error) {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(os.Environ(), backend.env...)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("%s failed: %s", command[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("%s failed: %w", command[0], err)
+	}
+	return string(output), nil
+}
+
+// parseOpkgInstalled parses "name - version" lines
+func parseOpkgInstalled(output string) []Package {
+	var packages []Package
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, " - ")
+		if len(fields) < 2 {
+			continue
+		}
+		packages = append(packages, Package{Name: fields[0], Version: fields[1]})
+	}
+	return packages
+}
+
+// parseOpkgUpgradable parses "name - installed - available" lines
+func parseOpkgUpgradable(output string) []PackageUpdate {
+	updates := []PackageUpdate{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, " - ")
+		if len(fields) < 3 {
+			continue
+		}
+		updates = append(updates, PackageUpdate{Name: fields[0], InstalledVersion: fields[1], Version: fields[2]})
+	}
+	return updates
+}
+
+// parseDpkgInstalled parses the tab separated dpkg-query output
+func parseDpkgInstalled(output string) []Package {
+	var packages []Package
+	for _, line := range strings.Split(output, "\n") {
+		name, version, ok := strings.Cut(line, "\t")
+		if !ok || name == "" {
+			continue
+		}
+		packages = append(packages, Package{Name: name, Version: version})
+	}
+	return packages
+}
+
+// parseAptUpgradable parses "name/suite version arch [upgradable from: installed]" lines
+func parseAptUpgradable(output string) []PackageUpdate {
+	updates := []PackageUpdate{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		name, _, ok := strings.Cut(fields[0], "/")
+		if !ok {
+			continue
+		}
+		update := PackageUpdate{Name: name, Version: fields[1]}
+		if _, installed, ok := strings.Cut(line, "[upgradable from: "); ok {
+			update.InstalledVersion = strings.TrimSuffix(installed, "]")
+		}
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+// normalizePackagesConfig fills in defaults and detects the package manager
+func normalizePackagesConfig(cfg PackagesConfig) PackagesConfig {
+	if cfg.InstallTimeout <= 0 {
+		cfg.InstallTimeout = DefaultPackageInstallTimeout
+	}
+	if cfg.Manager == "" {
+		for _, manager := range []string{PackageManagerOpkg, PackageManagerApt} {
+			if _, err := exec.LookPath(packageBackends[manager].install[0]); err == nil {
+				cfg.Manager = manager
+				break
+			}
+		}
+	}
+	return cfg
+}
+
+// Register the plugin
+func init() {
+	Register("packages", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[PackagesConfig]("packages", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewPackagesPlugin(cfg)
+	})
+}
@@ -1,8 +1,18 @@
 package plugins
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
 
 // Plugin interface that all plugins must implement
+//
+// Plugins go through three phases: the factory initializes the plugin and its
+// routes are registered (Init), Start is called on plugins implementing Starter
+// once every plugin is initialized, and Shutdown stops the plugin (Stop).
+// Start runs in dependency order and Shutdown in reverse dependency order.
 type Plugin interface {
 	// Name returns the plugin identifier
 	Name() string
@@ -14,18 +24,113 @@ type Plugin interface {
 	Shutdown() error
 }
 
-// PluginFactory creates a new plugin instance
+// Starter is implemented by plugins with background work that should only
+// begin after all plugins are initialized, e.g. so no bus event is published
+// before every subscriber exists
+//
+// Factories start no goroutines: background work, including anything that
+// publishes bus events, begins in Start. Plugins consuming bus events subscribe
+// in their factory and only read the subscription once started. Shutdown must
+// work whether or not Start was called, and Reload must not start work that
+// Start has not started yet.
+type Starter interface {
+	// Start begins the plugin's background work
+	Start() error
+}
+
+// PluginFactory creates a new plugin instance from its typed config struct
 type PluginFactory func(config interface{}) (Plugin, error)
 
-var registry = make(map[string]PluginFactory)
+// registration is a registered plugin factory with its dependencies
+type registration struct {
+	factory   PluginFactory
+	dependsOn []string
+}
+
+var registry = make(map[string]registration)
 
 // Register adds a plugin factory to the registry
-func Register(name string, factory PluginFactory) {
-	registry[name] = factory
+// dependsOn names plugins that must be initialized and started first; they are
+// loaded automatically when not enabled in the config
+func Register(name string, factory PluginFactory, dependsOn ...string) {
+	registry[name] = registration{factory: factory, dependsOn: dependsOn}
 }
 
 // Get retrieves a plugin factory by name
 func Get(name string) (PluginFactory, bool) {
-	factory, exists := registry[name]
-	return factory, exists
+	reg, exists := registry[name]
+	return reg.factory, exists
+}
+
+// Dependencies returns the plugins a plugin depends on
+func Dependencies(name string) []string {
+	return registry[name].dependsOn
+}
+
+// LoadOrder returns the enabled plugins and their dependencies, each plugin
+// after the plugins it depends on
+// Plugins keep their configured order where dependencies allow
+func LoadOrder(names []string) ([]string, error) {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	order := []string{}
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("plugin dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		}
+		reg, exists := registry[name]
+		if !exists {
+			if len(path) > 0 {
+				return fmt.Errorf("unknown plugin %s required by %s", name, path[len(path)-1])
+			}
+			return fmt.Errorf("unknown plugin: %s", name)
+		}
+
+		state[name] = visiting
+		for _, dependency := range reg.dependsOn {
+			if err := visit(dependency, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		order = append(order, name)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// Reloadable is implemented by plugins that can apply configuration changes at runtime
+type Reloadable interface {
+	// Reload applies a new plugin configuration without restarting
+	Reload(config interface{}) error
+}
+
+// ConfigValidator is implemented by plugin config structs that can check their
+// settings before the configuration is saved
+type ConfigValidator interface {
+	// Validate returns an error describing the first invalid setting
+	Validate() error
+}
+
+// configAs returns the typed config struct passed to a plugin factory or Reload
+func configAs[T any](plugin string, config interface{}) (T, error) {
+	cfg, ok := config.(T)
+	if !ok {
+		return cfg, fmt.Errorf("invalid config for %s plugin: expected %T, got %T", plugin, cfg, config)
+	}
+	return cfg, nil
 }
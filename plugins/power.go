@@ -0,0 +1,445 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Power actions
+const (
+	PowerActionReboot   = "reboot"
+	PowerActionPoweroff = "poweroff"
+)
+
+// Power defaults
+const (
+	DefaultPowerDelay       = 30 // seconds before a scheduled action runs
+	MaxPowerDelay           = 24 * 60 * 60
+	DefaultRebootCommand    = "systemctl reboot"
+	DefaultPoweroffCommand  = "systemctl poweroff"
+	DefaultMaintenanceFile  = "/var/lib/linht/maintenance.json"
+	powerCommandTimeout     = 30 * time.Second
+	powerEventSource        = "power"
+	maintenanceChangedEvent = "maintenance.changed"
+)
+
+// powerActionNames are the display names used in responses
+var powerActionNames = map[string]string{
+	PowerActionReboot:   "Reboot",
+	PowerActionPoweroff: "Poweroff",
+}
+
+// ErrMaintenanceMode is returned when a transmit operation is refused during maintenance
+var ErrMaintenanceMode = errors.New("maintenance mode is active, transmit operations are blocked")
+
+// PowerConfig holds power plugin configuration
+type PowerConfig struct {
+	DefaultDelay    int    `yaml:"default_delay"`    // seconds
+	RebootCommand   string `yaml:"reboot_command"`   // command run to reboot
+	PoweroffCommand string `yaml:"poweroff_command"` // command run to power off
+	MaintenanceFile string `yaml:"maintenance_file"` // persists maintenance mode across restarts
+}
+
+// PowerAction is a scheduled reboot or poweroff
+type PowerAction struct {
+	Action      string    `json:"action"`
+	Delay       int       `json:"delay"`
+	Reason      string    `json:"reason,omitempty"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	ExecuteAt   time.Time `json:"execute_at"`
+}
+
+// MaintenanceState describes the maintenance mode flag
+type MaintenanceState struct {
+	Enabled bool       `json:"enabled"`
+	Reason  string     `json:"reason,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+// Maintenance mode shared with the hardware plugin
+var (
+	maintenance   MaintenanceState
+	maintenanceMu sync.RWMutex
+)
+
+// MaintenanceMode reports whether maintenance mode is active
+func MaintenanceMode() bool {
+	maintenanceMu.RLock()
+	defer maintenanceMu.RUnlock()
+	return maintenance.Enabled
+}
+
+// checkTxAllowed returns ErrMaintenanceMode while maintenance mode is active
+func checkTxAllowed() error {
+	if MaintenanceMode() {
+		return ErrMaintenanceMode
+	}
+	return nil
+}
+
+// getMaintenance returns a copy of the maintenance state
+func getMaintenance() MaintenanceState {
+	maintenanceMu.RLock()
+	defer maintenanceMu.RUnlock()
+	return maintenance
+}
+
+// PowerPlugin schedules reboots and poweroffs and manages maintenance mode
+type PowerPlugin struct {
+	mu      sync.Mutex
+	config  PowerConfig
+	pending *PowerAction
+	timer   *time.Timer
+}
+
+// NewPowerPlugin creates a new power plugin instance
+// Maintenance mode is restored from the maintenance file
+func NewPowerPlugin(cfg PowerConfig) (*PowerPlugin, error) {
+	p := &PowerPlugin{config: normalizePowerConfig(cfg)}
+
+	if err := p.loadMaintenance(); err != nil {
+		slog.Warn("Failed to load maintenance state", "file", p.config.MaintenanceFile, "error", err)
+	}
+	if MaintenanceMode() {
+		slog.Warn("Maintenance mode is active, transmit operations are blocked", "reason", getMaintenance().Reason)
+	}
+
+	return p, nil
+}
+
+// Name returns the plugin identifier
+func (p *PowerPlugin) Name() string {
+	return "power"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *PowerPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/power")
+
+	api.Get("/status", p.handleStatus)
+	api.Post("/reboot", p.handleReboot)
+	api.Post("/shutdown", p.handleShutdown)
+	api.Post("/abort", p.handleAbort)
+	api.Get("/maintenance", p.handleGetMaintenance)
+	api.Post("/maintenance", p.handleSetMaintenance)
+}
+
+// Shutdown cancels a pending action so it does not fire during a manager restart
+func (p *PowerPlugin) Shutdown() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.timer != nil {
+		p.timer.Stop()
+		slog.Warn("Pending power action cancelled by shutdown", "action", p.pending.Action)
+		p.timer = nil
+		p.pending = nil
+	}
+	return nil
+}
+
+// Reload applies new commands and delays at runtime
+// An action that is already scheduled keeps its original command
+func (p *PowerPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[PowerConfig]("power", config)
+	if err != nil {
+		return err
+	}
+	cfg = normalizePowerConfig(cfg)
+
+	p.mu.Lock()
+	p.config = cfg
+	p.mu.Unlock()
+
+	slog.Info("Power config reloaded",
+		"default_delay", cfg.DefaultDelay,
+		"reboot_command", cfg.RebootCommand,
+		"poweroff_command", cfg.PoweroffCommand)
+	return nil
+}
+
+// schedule arms a timer for a reboot or poweroff
+func (p *PowerPlugin) schedule(action string, delay int, reason string) (PowerAction, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pending != nil {
+		return *p.pending, fmt.Errorf("a %s is already scheduled for %s; abort it first",
+			p.pending.Action, p.pending.ExecuteAt.Format(time.RFC3339))
+	}
+
+	command := p.config.RebootCommand
+	if action == PowerActionPoweroff {
+		command = p.config.PoweroffCommand
+	}
+
+	now := time.Now()
+	pending := &PowerAction{
+		Action:      action,
+		Delay:       delay,
+		Reason:      reason,
+		ScheduledAt: now,
+		ExecuteAt:   now.Add(time.Duration(delay) * time.Second),
+	}
+	p.pending = pending
+	p.timer = time.AfterFunc(time.Duration(delay)*time.Second, func() {
+		p.execute(pending, command)
+	})
+
+	return *pending, nil
+}
+
+// execute runs the power command if the action has not been aborted meanwhile
+func (p *PowerPlugin) execute(action *PowerAction, command string) {
+	p.mu.Lock()
+	if p.pending != action {
+		p.mu.Unlock()
+		return
+	}
+	p.mu.Unlock()
+
+	slog.Warn("Executing power action", "action", action.Action, "command", command, "reason", action.Reason)
+	PublishEvent("power."+action.Action, powerEventSource, action)
+
+	ctx, cancel := context.WithTimeout(context.Background(), powerCommandTimeout)
+	defer cancel()
+
+	args := strings.Fields(command)
+	output, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+
+	p.mu.Lock()
+	if p.pending == action {
+		p.pending = nil
+		p.timer = nil
+	}
+	p.mu.Unlock()
+
+	if err != nil {
+		slog.Error("Power action failed", "action", action.Action, "error", err, "output", strings.TrimSpace(string(output)))
+		PublishEvent("power.failed", powerEventSource, fiber.Map{
+			"action": action.Action,
+			"error":  err.Error(),
+		})
+	}
+}
+
+// abort cancels the pending action
+func (p *PowerPlugin) abort() (*PowerAction, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pending == nil {
+		return nil, false
+	}
+	p.timer.Stop()
+	aborted := p.pending
+	p.pending = nil
+	p.timer = nil
+	return aborted, true
+}
+
+// getPending returns a copy of the pending action, if any
+func (p *PowerPlugin) getPending() *PowerAction {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pending == nil {
+		return nil
+	}
+	pending := *p.pending
+	return &pending
+}
+
+// loadMaintenance restores maintenance mode from the maintenance file
+func (p *PowerPlugin) loadMaintenance() error {
+	data, err := os.ReadFile(p.config.MaintenanceFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var state MaintenanceState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("invalid maintenance file: %w", err)
+	}
+	state.Enabled = true
+
+	maintenanceMu.Lock()
+	maintenance = state
+	maintenanceMu.Unlock()
+	return nil
+}
+
+// setMaintenance updates maintenance mode and persists it
+// The file exists only while maintenance mode is enabled
+func (p *PowerPlugin) setMaintenance(enabled bool, reason string) (MaintenanceState, error) {
+	p.mu.Lock()
+	file := p.config.MaintenanceFile
+	p.mu.Unlock()
+
+	state := MaintenanceState{Enabled: enabled}
+	if enabled {
+		now := time.Now()
+		state.Reason = reason
+		state.Since = &now
+
+		data, _ := json.MarshalIndent(state, "", "  ")
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return state, fmt.Errorf("failed to persist maintenance mode: %w", err)
+		}
+		if err := os.WriteFile(file, data, 0644); err != nil {
+			return state, fmt.Errorf("failed to persist maintenance mode: %w", err)
+		}
+	} else if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return state, fmt.Errorf("failed to clear maintenance mode: %w", err)
+	}
+
+	maintenanceMu.Lock()
+	maintenance = state
+	maintenanceMu.Unlock()
+
+	PublishEvent(maintenanceChangedEvent, powerEventSource, state)
+	return state, nil
+}
+
+// handleStatus handles GET /api/power/status
+func (p *PowerPlugin) handleStatus(c *fiber.Ctx) error {
+	status := fiber.Map{
+		"pending":     nil,
+		"maintenance": getMaintenance(),
+	}
+	if pending := p.getPending(); pending != nil {
+		status["pending"] = pending
+		status["remaining"] = int(time.Until(pending.ExecuteAt).Seconds())
+	}
+	return SendSuccess(c, status, "")
+}
+
+// handleReboot handles POST /api/power/reboot
+func (p *PowerPlugin) handleReboot(c *fiber.Ctx) error {
+	return p.handleSchedule(c, PowerActionReboot)
+}
+
+// handleShutdown handles POST /api/power/shutdown
+func (p *PowerPlugin) handleShutdown(c *fiber.Ctx) error {
+	return p.handleSchedule(c, PowerActionPoweroff)
+}
+
+// handleSchedule schedules an action after an optional delay (seconds) so it can still be aborted
+func (p *PowerPlugin) handleSchedule(c *fiber.Ctx, action string) error {
+	var req struct {
+		Delay  *int   `json:"delay"`
+		Reason string `json:"reason"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return SendErrorMessage(c, 400, "Invalid request body")
+		}
+	}
+
+	p.mu.Lock()
+	delay := p.config.DefaultDelay
+	p.mu.Unlock()
+	if req.Delay != nil {
+		delay = *req.Delay
+	}
+	if delay < 0 || delay > MaxPowerDelay {
+		return SendErrorMessage(c, 400, fmt.Sprintf("delay must be between 0 and %d seconds", MaxPowerDelay))
+	}
+
+	scheduled, err := p.schedule(action, delay, req.Reason)
+	if err != nil {
+		return SendErrorMessage(c, 409, err.Error())
+	}
+
+	slog.WarnContext(c.UserContext(), "Power action scheduled",
+		"action", action,
+		"delay", delay,
+		"reason", req.Reason,
+		"execute_at", scheduled.ExecuteAt)
+	PublishEvent("power.scheduled", powerEventSource, scheduled)
+
+	return SendSuccess(c, scheduled, fmt.Sprintf("%s scheduled in %d seconds", powerActionNames[action], delay))
+}
+
+// handleAbort handles POST /api/power/abort
+func (p *PowerPlugin) handleAbort(c *fiber.Ctx) error {
+	aborted, ok := p.abort()
+	if !ok {
+		return SendErrorMessage(c, 404, "No power action is scheduled")
+	}
+
+	slog.InfoContext(c.UserContext(), "Power action aborted", "action", aborted.Action)
+	PublishEvent("power.aborted", powerEventSource, aborted)
+
+	return SendSuccess(c, aborted, fmt.Sprintf("%s aborted", powerActionNames[aborted.Action]))
+}
+
+// handleGetMaintenance handles GET /api/power/maintenance
+func (p *PowerPlugin) handleGetMaintenance(c *fiber.Ctx) error {
+	return SendSuccess(c, getMaintenance(), "")
+}
+
+// handleSetMaintenance handles POST /api/power/maintenance
+// While enabled, hardware operations that would enable the transmitter are refused
+func (p *PowerPlugin) handleSetMaintenance(c *fiber.Ctx) error {
+	var req struct {
+		Enabled bool   `json:"enabled"`
+		Reason  string `json:"reason"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+
+	state, err := p.setMaintenance(req.Enabled, req.Reason)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+
+	slog.WarnContext(c.UserContext(), "Maintenance mode changed", "enabled", state.Enabled, "reason", state.Reason)
+	if state.Enabled {
+		return SendSuccess(c, state, "Maintenance mode enabled")
+	}
+	return SendSuccess(c, state, "Maintenance mode disabled")
+}
+
+// normalizePowerConfig fills in defaults
+func normalizePowerConfig(cfg PowerConfig) PowerConfig {
+	if cfg.DefaultDelay <= 0 {
+		cfg.DefaultDelay = DefaultPowerDelay
+	}
+	if strings.TrimSpace(cfg.RebootCommand) == "" {
+		cfg.RebootCommand = DefaultRebootCommand
+	}
+	if strings.TrimSpace(cfg.PoweroffCommand) == "" {
+		cfg.PoweroffCommand = DefaultPoweroffCommand
+	}
+	if cfg.MaintenanceFile == "" {
+		cfg.MaintenanceFile = DefaultMaintenanceFile
+	}
+	return cfg
+}
+
+// Register the plugin
+func init() {
+	Register("power", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[PowerConfig]("power", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewPowerPlugin(cfg)
+	})
+}
@@ -0,0 +1,433 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Process viewer defaults
+const (
+	DefaultProcessInterval = 500  // milliseconds between the two CPU samples
+	MaxProcessInterval     = 5000 // milliseconds
+	clockTicks             = 100  // USER_HZ, the unit of the CPU times in /proc/<pid>/stat
+	processEventSource     = "processes"
+)
+
+// processSignals lists the signals the kill endpoint may send
+var processSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"TERM": syscall.SIGTERM,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"STOP": syscall.SIGSTOP,
+	"CONT": syscall.SIGCONT,
+}
+
+// processSortKeys orders the listing, largest first except for pid and name
+var processSortKeys = map[string]func(a, b ProcessInfo) bool{
+	"cpu":    func(a, b ProcessInfo) bool { return a.CPU > b.CPU },
+	"memory": func(a, b ProcessInfo) bool { return a.RSS > b.RSS },
+	"pid":    func(a, b ProcessInfo) bool { return a.PID < b.PID },
+	"name":   func(a, b ProcessInfo) bool { return a.Name < b.Name },
+}
+
+// ProcessesConfig holds processes plugin configuration
+// Kill and renice are disabled while auth is none.
+type ProcessesConfig struct {
+	RouteAuth `yaml:",inline"`
+}
+
+// Validate checks the auth settings
+func (cfg ProcessesConfig) Validate() error {
+	if err := cfg.RouteAuth.validate(); err != nil {
+		return fmt.Errorf("processes: %w", err)
+	}
+	return nil
+}
+
+// ProcessInfo is one line of the process listing
+type ProcessInfo struct {
+	PID     int     `json:"pid"`
+	PPID    int     `json:"ppid"`
+	User    string  `json:"user"`
+	State   string  `json:"state"`
+	Nice    int     `json:"nice"`
+	Threads int     `json:"threads"`
+	CPU     float64 `json:"cpu_percent"` // of one core over the sample interval
+	RSS     uint64  `json:"rss"`         // bytes
+	Name    string  `json:"name"`
+	Command string  `json:"command"`
+
+	cpuTicks   uint64
+	startTicks uint64
+}
+
+// ProcessesPlugin lists host processes and signals or renices them
+type ProcessesPlugin struct {
+	mu     sync.RWMutex
+	config ProcessesConfig
+}
+
+// NewProcessesPlugin creates a new processes plugin instance
+func NewProcessesPlugin(cfg ProcessesConfig) (*ProcessesPlugin, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg.RouteAuth = cfg.RouteAuth.normalize()
+	if cfg.Auth == RouteAuthNone {
+		slog.Info("Process control disabled, set processes.auth to allow kill and renice")
+	}
+	return &ProcessesPlugin{config: cfg}, nil
+}
+
+// Name returns the plugin identifier
+func (p *ProcessesPlugin) Name() string {
+	return "processes"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *ProcessesPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/processes")
+
+	api.Get("/", p.handleList)
+	api.Post("/:pid/kill", p.requireAuth, p.handleKill)
+	api.Post("/:pid/renice", p.requireAuth, p.handleRenice)
+}
+
+// Shutdown has nothing to release
+func (p *ProcessesPlugin) Shutdown() error {
+	return nil
+}
+
+// Reload applies new auth settings at runtime
+func (p *ProcessesPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[ProcessesConfig]("processes", config)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	cfg.RouteAuth = cfg.RouteAuth.normalize()
+
+	p.mu.Lock()
+	p.config = cfg
+	p.mu.Unlock()
+
+	slog.Info("Processes config reloaded", "control_enabled", cfg.Auth != RouteAuthNone)
+	return nil
+}
+
+// getConfig returns the current configuration
+func (p *ProcessesPlugin) getConfig() ProcessesConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config
+}
+
+// requireAuth lets a request through only with the configured credentials
+func (p *ProcessesPlugin) requireAuth(c *fiber.Ctx) error {
+	auth := p.getConfig().RouteAuth
+	if auth.Auth == RouteAuthNone {
+		return SendErrorMessage(c, 403, "Process control is disabled; set processes.auth to enable it")
+	}
+	if !auth.authorized(c, "processes") {
+		return SendErrorMessage(c, 401, "Valid credentials are required")
+	}
+	return c.Next()
+}
+
+// handleList handles GET /api/processes
+// Query: sort=cpu (default), memory, pid or name; limit=N; user=name;
+// interval=ms between the CPU samples
+func (p *ProcessesPlugin) handleList(c *fiber.Ctx) error {
+	less, ok := processSortKeys[c.Query("sort", "cpu")]
+	if !ok {
+		return SendErrorMessage(c, 400, "sort must be cpu, memory, pid or name")
+	}
+	interval := c.QueryInt("interval", DefaultProcessInterval)
+	if interval < 0 || interval > MaxProcessInterval {
+		return SendErrorMessage(c, 400, fmt.Sprintf("interval must be between 0 and %d ms", MaxProcessInterval))
+	}
+	limit := c.QueryInt("limit", 0)
+	filterUser := c.Query("user")
+
+	processes, err := sampleProcesses(time.Duration(interval) * time.Millisecond)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+
+	if filterUser != "" {
+		filtered := processes[:0]
+		for _, proc := range processes {
+			if proc.User == filterUser {
+				filtered = append(filtered, proc)
+			}
+		}
+		processes = filtered
+	}
+	sort.SliceStable(processes, func(i, j int) bool {
+		return less(processes[i], processes[j])
+	})
+	if limit > 0 && len(processes) > limit {
+		processes = processes[:limit]
+	}
+	return SendSuccess(c, processes, "")
+}
+
+// handleKill handles POST /api/processes/:pid/kill
+// Body: {"signal": "TERM"}; TERM when omitted
+func (p *ProcessesPlugin) handleKill(c *fiber.Ctx) error {
+	pid, err := targetPID(c)
+	if err != nil {
+		return SendErrorMessage(c, 400, err.Error())
+	}
+
+	var req struct {
+		Signal string `json:"signal"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return SendErrorMessage(c, 400, "Invalid request body")
+		}
+	}
+	name := strings.TrimPrefix(strings.ToUpper(req.Signal), "SIG")
+	if name == "" {
+		name = "TERM"
+	}
+	signal, ok := processSignals[name]
+	if !ok {
+		return SendErrorMessage(c, 400, fmt.Sprintf("unsupported signal %q", req.Signal))
+	}
+
+	command := processName(pid)
+	if err := syscall.Kill(pid, signal); err != nil {
+		return sendProcessError(c, pid, err)
+	}
+
+	slog.Warn("Process signalled", "pid", pid, "name", command, "signal", name, "client", c.IP())
+	PublishEvent("process.signalled", processEventSource, fiber.Map{"pid": pid, "name": command, "signal": name})
+	return SendSuccess(c, fiber.Map{"pid": pid, "signal": name}, fmt.Sprintf("Sent SIG%s to process %d", name, pid))
+}
+
+// handleRenice handles POST /api/processes/:pid/renice
+// Body: {"nice": 10}; -20 (highest priority) to 19
+func (p *ProcessesPlugin) handleRenice(c *fiber.Ctx) error {
+	pid, err := targetPID(c)
+	if err != nil {
+		return SendErrorMessage(c, 400, err.Error())
+	}
+
+	var req struct {
+		Nice *int `json:"nice"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Nice == nil {
+		return SendErrorMessage(c, 400, "Request body must contain \"nice\"")
+	}
+	if *req.Nice < -20 || *req.Nice > 19 {
+		return SendErrorMessage(c, 400, "nice must be between -20 and 19")
+	}
+
+	if err := syscall.Setpriority(syscall.PRIO_PROCESS, pid, *req.Nice); err != nil {
+		return sendProcessError(c, pid, err)
+	}
+
+	command := processName(pid)
+	slog.Info("Process reniced", "pid", pid, "name", command, "nice", *req.Nice, "client", c.IP())
+	PublishEvent("process.reniced", processEventSource, fiber.Map{"pid": pid, "name": command, "nice": *req.Nice})
+	return SendSuccess(c, fiber.Map{"pid": pid, "nice": *req.Nice}, fmt.Sprintf("Process %d reniced to %d", pid, *req.Nice))
+}
+
+// targetPID parses the pid parameter, refusing init and the manager itself
+func targetPID(c *fiber.Ctx) (int, error) {
+	pid, err := strconv.Atoi(c.Params("pid"))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid process ID")
+	}
+	if pid == 1 || pid == os.Getpid() {
+		return 0, fmt.Errorf("refusing to change process %d", pid)
+	}
+	return pid, nil
+}
+
+// sendProcessError maps kill and setpriority errors to HTTP statuses
+func sendProcessError(c *fiber.Ctx, pid int, err error) error {
+	switch {
+	case errors.Is(err, syscall.ESRCH):
+		return SendErrorMessage(c, 404, fmt.Sprintf("process %d not found", pid))
+	case errors.Is(err, syscall.EPERM), errors.Is(err, syscall.EACCES):
+		return SendErrorMessage(c, 403, fmt.Sprintf("not permitted to change process %d", pid))
+	}
+	return SendError(c, 500, err)
+}
+
+// sampleProcesses reads all processes twice, interval apart, to compute their CPU usage
+// With a zero interval the CPU usage is averaged over each process's lifetime instead.
+func sampleProcesses(interval time.Duration) ([]ProcessInfo, error) {
+	users := make(map[uint32]string)
+	if interval == 0 {
+		uptime, err := readUptime()
+		if err != nil {
+			return nil, err
+		}
+		processes := readProcesses(users)
+		for i := range processes {
+			if elapsed := uptime - processes[i].startedAfter(); elapsed > 0 {
+				processes[i].CPU = cpuPercent(processes[i].cpuTicks, elapsed)
+			}
+		}
+		return processes, nil
+	}
+
+	before := make(map[int]uint64)
+	for _, proc := range readProcesses(users) {
+		before[proc.PID] = proc.cpuTicks
+	}
+	start := time.Now()
+	time.Sleep(interval)
+
+	processes := readProcesses(users)
+	elapsed := time.Since(start).Seconds()
+	for i := range processes {
+		if ticks, ok := before[processes[i].PID]; ok && processes[i].cpuTicks >= ticks {
+			processes[i].CPU = cpuPercent(processes[i].cpuTicks-ticks, elapsed)
+		}
+	}
+	return processes, nil
+}
+
+// cpuPercent converts CPU ticks used over elapsed seconds to percent of one core
+func cpuPercent(ticks uint64, elapsed float64) float64 {
+	percent := float64(ticks) / clockTicks / elapsed * 100
+	return float64(int(percent*10+0.5)) / 10
+}
+
+// readProcesses reads every process in /proc; processes that exit meanwhile are skipped
+// users caches the user names by uid.
+func readProcesses(users map[uint32]string) []ProcessInfo {
+	dirs, _ := filepath.Glob("/proc/[0-9]*")
+	processes := make([]ProcessInfo, 0, len(dirs))
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(filepath.Base(dir))
+		if err != nil {
+			continue
+		}
+		proc, err := readProcess(pid, users)
+		if err != nil {
+			continue
+		}
+		processes = append(processes, proc)
+	}
+	return processes
+}
+
+// readProcess reads one process from /proc/<pid>
+func readProcess(pid int, users map[uint32]string) (ProcessInfo, error) {
+	dir := fmt.Sprintf("/proc/%d", pid)
+	data, err := os.ReadFile(dir + "/stat")
+	if err != nil {
+		return ProcessInfo{}, err
+	}
+
+	// The name is in parentheses and may itself contain spaces and parentheses
+	stat := string(data)
+	open, end := strings.IndexByte(stat, '('), strings.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return ProcessInfo{}, fmt.Errorf("malformed %s/stat", dir)
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 22 {
+		return ProcessInfo{}, fmt.Errorf("malformed %s/stat", dir)
+	}
+
+	// Fields after the name, numbered from 0: state, ppid, ..., utime (11),
+	// stime (12), nice (16), num_threads (17), starttime (19), rss (21)
+	proc := ProcessInfo{PID: pid, Name: stat[open+1 : end], State: fields[0]}
+	proc.PPID, _ = strconv.Atoi(fields[1])
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	proc.cpuTicks = utime + stime
+	proc.Nice, _ = strconv.Atoi(fields[16])
+	proc.Threads, _ = strconv.Atoi(fields[17])
+	rss, _ := strconv.ParseUint(fields[21], 10, 64)
+	proc.RSS = rss * uint64(os.Getpagesize())
+	proc.startTicks, _ = strconv.ParseUint(fields[19], 10, 64)
+
+	if info, err := os.Stat(dir); err == nil {
+		if st, ok := info.Sys().(*syscall.Stat_t); ok {
+			proc.User = userName(st.Uid, users)
+		}
+	}
+
+	// Kernel threads have no command line
+	proc.Command = "[" + proc.Name + "]"
+	if cmdline, err := os.ReadFile(dir + "/cmdline"); err == nil && len(cmdline) > 0 {
+		proc.Command = strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+	}
+	return proc, nil
+}
+
+// startedAfter returns the seconds after boot at which the process started
+func (proc ProcessInfo) startedAfter() float64 {
+	return float64(proc.startTicks) / clockTicks
+}
+
+// processName returns the name of a process, or "" when it is gone
+func processName(pid int) string {
+	comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(comm))
+}
+
+// userName resolves a uid, falling back to the number
+func userName(uid uint32, cache map[uint32]string) string {
+	if name, ok := cache[uid]; ok {
+		return name
+	}
+	name := strconv.FormatUint(uint64(uid), 10)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	cache[uid] = name
+	return name
+}
+
+// readUptime returns the seconds since boot
+func readUptime() (float64, error) {
+	data, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("malformed /proc/uptime")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// Register the plugin
+func init() {
+	Register("processes", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[ProcessesConfig]("processes", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewProcessesPlugin(cfg)
+	})
+}
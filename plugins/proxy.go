@@ -0,0 +1,509 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// Proxy defaults
+const (
+	DefaultProxyTimeout = 60 // seconds a backend may take to answer
+	proxyDialTimeout    = 10 * time.Second
+	proxyResolveTTL     = 10 * time.Second // container addresses are looked up again after this
+)
+
+// proxyHopHeaders apply to a single connection and are not forwarded
+var proxyHopHeaders = []string{"Keep-Alive", "Proxy-Connection", "Proxy-Authorization", "TE", "Trailer", "Upgrade"}
+
+// ProxyRoute forwards the requests below a URL prefix to a service
+// Exactly one of container, target and socket names the backend.
+type ProxyRoute struct {
+	Path       string `yaml:"path"`        // URL prefix, e.g. /proxy/modem
+	Container  string `yaml:"container"`   // container name or ID, reached at its network address
+	Port       int    `yaml:"port"`        // container port
+	Target     string `yaml:"target"`      // host:port, e.g. 127.0.0.1:8081
+	Socket     string `yaml:"socket"`      // unix socket path
+	KeepPrefix bool   `yaml:"keep_prefix"` // forward the full path instead of stripping the prefix
+	RouteAuth  `yaml:",inline"`
+}
+
+// ProxyConfig holds proxy plugin configuration
+type ProxyConfig struct {
+	Routes  []ProxyRoute `yaml:"routes"`
+	Timeout int          `yaml:"timeout"` // seconds, default 60
+
+	// Shared Docker client, set by the manager
+	DockerClient *client.Client `yaml:"-"`
+}
+
+// ProxyRouteStatus describes a route and where it currently leads
+type ProxyRouteStatus struct {
+	Path       string `json:"path"`
+	Backend    string `json:"backend"`           // container:port, host:port or unix socket as configured
+	Address    string `json:"address,omitempty"` // resolved container address
+	Auth       string `json:"auth"`
+	KeepPrefix bool   `json:"keep_prefix"`
+	Error      string `json:"error,omitempty"`
+}
+
+// proxyAddress is a resolved container address
+type proxyAddress struct {
+	addr string
+	at   time.Time
+}
+
+// ProxyPlugin forwards URL prefixes to container and host services, including WebSockets
+type ProxyPlugin struct {
+	mu     sync.RWMutex
+	config ProxyConfig
+
+	clientsMu sync.Mutex
+	clients   map[string]*fasthttp.HostClient // by network and address
+	resolved  map[string]proxyAddress         // by container
+}
+
+// NewProxyPlugin creates a new proxy plugin instance
+func NewProxyPlugin(cfg ProxyConfig) (*ProxyPlugin, error) {
+	cfg = normalizeProxyConfig(cfg)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &ProxyPlugin{
+		config:   cfg,
+		clients:  make(map[string]*fasthttp.HostClient),
+		resolved: make(map[string]proxyAddress),
+	}, nil
+}
+
+// Name returns the plugin identifier
+func (p *ProxyPlugin) Name() string {
+	return "proxy"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+// Proxied prefixes are matched by a middleware, so reloaded routes apply at once.
+func (p *ProxyPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/proxy")
+	api.Get("/", p.listRoutes)
+
+	app.Use(p.handle)
+}
+
+// Shutdown closes idle backend connections
+func (p *ProxyPlugin) Shutdown() error {
+	p.resetClients()
+	return nil
+}
+
+// Reload applies new routes; open WebSocket connections stay up
+func (p *ProxyPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[ProxyConfig]("proxy", config)
+	if err != nil {
+		return err
+	}
+	cfg = normalizeProxyConfig(cfg)
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.config = cfg
+	p.mu.Unlock()
+	p.resetClients()
+
+	slog.Info("Proxy config reloaded", "routes", len(cfg.Routes))
+	return nil
+}
+
+// Validate checks every route
+func (cfg ProxyConfig) Validate() error {
+	if cfg.Timeout < 0 {
+		return fmt.Errorf("proxy.timeout must not be negative")
+	}
+	paths := make(map[string]bool)
+	for i, route := range cfg.Routes {
+		if err := route.validate(); err != nil {
+			return fmt.Errorf("proxy.routes[%d]: %w", i, err)
+		}
+		if paths[route.Path] {
+			return fmt.Errorf("proxy.routes[%d]: duplicate path %s", i, route.Path)
+		}
+		paths[route.Path] = true
+	}
+	return nil
+}
+
+// validate checks a route's path, backend and auth settings
+func (r ProxyRoute) validate() error {
+	if !staticPathPattern.MatchString(r.Path) || r.Path == "/api" || strings.HasPrefix(r.Path, "/api/") {
+		return fmt.Errorf("invalid path %q, expected e.g. /proxy/modem outside /api", r.Path)
+	}
+	backends := 0
+	for _, set := range []bool{r.Container != "", r.Target != "", r.Socket != ""} {
+		if set {
+			backends++
+		}
+	}
+	if backends != 1 {
+		return fmt.Errorf("%s: set exactly one of container, target and socket", r.Path)
+	}
+	switch {
+	case r.Container != "":
+		if r.Port < 1 || r.Port > 65535 {
+			return fmt.Errorf("%s: container needs a port", r.Path)
+		}
+	case r.Target != "":
+		host, port, err := net.SplitHostPort(r.Target)
+		if err != nil || host == "" || port == "" {
+			return fmt.Errorf("%s: target must be host:port", r.Path)
+		}
+	case r.Socket != "":
+		if !strings.HasPrefix(r.Socket, "/") {
+			return fmt.Errorf("%s: socket must be an absolute path", r.Path)
+		}
+	}
+	if err := r.RouteAuth.validate(); err != nil {
+		return fmt.Errorf("%s: %w", r.Path, err)
+	}
+	return nil
+}
+
+// backend returns the route's backend as configured
+func (r ProxyRoute) backend() string {
+	switch {
+	case r.Container != "":
+		return r.Container + ":" + strconv.Itoa(r.Port)
+	case r.Socket != "":
+		return "unix:" + r.Socket
+	}
+	return r.Target
+}
+
+// matchProxyRoute returns the route with the longest prefix matching a path
+func matchProxyRoute(routes []ProxyRoute, path string) (ProxyRoute, bool) {
+	var best ProxyRoute
+	found := false
+	for _, route := range routes {
+		if path != route.Path && !strings.HasPrefix(path, route.Path+"/") {
+			continue
+		}
+		if !found || len(route.Path) > len(best.Path) {
+			best, found = route, true
+		}
+	}
+	return best, found
+}
+
+// handle forwards requests below a route's prefix and passes all others on
+func (p *ProxyPlugin) handle(c *fiber.Ctx) error {
+	cfg := p.getConfig()
+	route, ok := matchProxyRoute(cfg.Routes, c.Path())
+	if !ok {
+		return c.Next()
+	}
+	if !route.authorized(c, route.Path) {
+		return c.Status(401).SendString("Unauthorized")
+	}
+
+	// Relative links of the proxied pages only resolve below the prefix with a trailing slash
+	if c.Path() == route.Path && !route.KeepPrefix && (c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead) {
+		location := route.Path + "/"
+		if query := c.Request().URI().QueryString(); len(query) > 0 {
+			location += "?" + string(query)
+		}
+		return c.Redirect(location, fiber.StatusFound)
+	}
+
+	network, addr, err := p.resolve(c.UserContext(), route, cfg.DockerClient)
+	if err != nil {
+		return proxyError(c, 502, err)
+	}
+	p.prepareRequest(c, route)
+
+	if strings.EqualFold(c.Get(fiber.HeaderUpgrade), "websocket") {
+		return p.proxyWebSocket(c, route, network, addr)
+	}
+	for _, header := range proxyHopHeaders {
+		c.Request().Header.Del(header)
+	}
+
+	resp := c.Response()
+	if err := p.client(network, addr, cfg.Timeout).Do(c.Request(), resp); err != nil {
+		p.forget(route)
+		resp.Reset()
+		var netErr net.Error
+		if errors.Is(err, fasthttp.ErrTimeout) || (errors.As(err, &netErr) && netErr.Timeout()) {
+			return proxyError(c, 504, fmt.Errorf("%s did not answer in time", route.backend()))
+		}
+		return proxyError(c, 502, err)
+	}
+	p.rewriteLocation(resp, route, addr)
+	return nil
+}
+
+// prepareRequest points the request at the backend path and adds the forwarding headers
+func (p *ProxyPlugin) prepareRequest(c *fiber.Ctx, route ProxyRoute) {
+	req := c.Request()
+	path := c.Path()
+	if !route.KeepPrefix {
+		path = strings.TrimPrefix(path, route.Path)
+		if path == "" {
+			path = "/"
+		}
+		req.Header.Set("X-Forwarded-Prefix", route.Path)
+	}
+	if query := req.URI().QueryString(); len(query) > 0 {
+		path += "?" + string(query)
+	}
+	req.SetRequestURI(path)
+
+	// Credentials for the route are meant for the manager, not the service
+	if route.Auth != RouteAuthNone {
+		req.Header.Del(fiber.HeaderAuthorization)
+	}
+
+	forwardedFor := c.Context().RemoteIP().String()
+	if prior := c.Get(fiber.HeaderXForwardedFor); prior != "" {
+		forwardedFor = prior + ", " + forwardedFor
+	}
+	req.Header.Set(fiber.HeaderXForwardedFor, forwardedFor)
+	req.Header.Set(fiber.HeaderXForwardedHost, string(req.Host()))
+	req.Header.Set(fiber.HeaderXForwardedProto, c.Protocol())
+}
+
+// rewriteLocation keeps redirects of the service below the route's prefix
+func (p *ProxyPlugin) rewriteLocation(resp *fasthttp.Response, route ProxyRoute, addr string) {
+	location := string(resp.Header.Peek(fiber.HeaderLocation))
+	if location == "" || route.KeepPrefix {
+		return
+	}
+	if strings.HasPrefix(location, "/") && !strings.HasPrefix(location, "//") {
+		resp.Header.Set(fiber.HeaderLocation, route.Path+location)
+		return
+	}
+	// Absolute redirects to the backend's own address are unreachable for clients
+	if u, err := url.Parse(location); err == nil && u.Host == addr {
+		resp.Header.Set(fiber.HeaderLocation, route.Path+u.RequestURI())
+	}
+}
+
+// proxyWebSocket forwards an upgrade request and then copies frames both ways
+// The backend's handshake answer reaches the client unchanged.
+func (p *ProxyPlugin) proxyWebSocket(c *fiber.Ctx, route ProxyRoute, network, addr string) error {
+	backend, err := net.DialTimeout(network, addr, proxyDialTimeout)
+	if err != nil {
+		p.forget(route)
+		return proxyError(c, 502, err)
+	}
+
+	w := bufio.NewWriter(backend)
+	err = c.Request().Write(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		backend.Close()
+		return proxyError(c, 502, err)
+	}
+
+	slog.InfoContext(c.UserContext(), "WebSocket proxied", "path", route.Path, "backend", route.backend(), "client", c.IP())
+	c.Status(fiber.StatusSwitchingProtocols)
+	c.Context().HijackSetNoResponse(true)
+	c.Context().Hijack(func(conn net.Conn) {
+		defer backend.Close()
+		// The server's read and write timeouts must not end long-lived sessions
+		conn.SetDeadline(time.Time{})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			io.Copy(backend, conn)
+			backend.Close()
+		}()
+		io.Copy(conn, backend)
+		// Unblock the reader; the connection itself is closed once this returns
+		conn.SetReadDeadline(time.Now())
+		<-done
+	})
+	return nil
+}
+
+// proxyError answers with a plain text error, as browsers show it to the user
+func proxyError(c *fiber.Ctx, code int, err error) error {
+	slog.WarnContext(c.UserContext(), "Proxy request failed", "path", c.Path(), "status", code, "error", err)
+	return c.Status(code).SendString(fmt.Sprintf("%s: %v", fasthttp.StatusMessage(code), err))
+}
+
+// resolve returns the network and address to dial for a route
+func (p *ProxyPlugin) resolve(ctx context.Context, route ProxyRoute, cli *client.Client) (string, string, error) {
+	switch {
+	case route.Socket != "":
+		return "unix", route.Socket, nil
+	case route.Target != "":
+		return "tcp", route.Target, nil
+	}
+
+	p.clientsMu.Lock()
+	cached, ok := p.resolved[route.Container]
+	p.clientsMu.Unlock()
+	if ok && time.Since(cached.at) < proxyResolveTTL {
+		return "tcp", net.JoinHostPort(cached.addr, strconv.Itoa(route.Port)), nil
+	}
+
+	host, err := containerAddress(ctx, cli, route.Container)
+	if err != nil {
+		return "", "", err
+	}
+	p.clientsMu.Lock()
+	p.resolved[route.Container] = proxyAddress{addr: host, at: time.Now()}
+	p.clientsMu.Unlock()
+	return "tcp", net.JoinHostPort(host, strconv.Itoa(route.Port)), nil
+}
+
+// containerAddress returns the IP address at which the host reaches a running container
+func containerAddress(ctx context.Context, cli *client.Client, name string) (string, error) {
+	if cli == nil {
+		return "", fmt.Errorf("docker is not available")
+	}
+	info, err := cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if info.State == nil || !info.State.Running {
+		return "", fmt.Errorf("container %s is not running", name)
+	}
+	if info.HostConfig != nil && info.HostConfig.NetworkMode.IsHost() {
+		return "127.0.0.1", nil
+	}
+	if info.NetworkSettings != nil {
+		networks := make([]string, 0, len(info.NetworkSettings.Networks))
+		for network := range info.NetworkSettings.Networks {
+			networks = append(networks, network)
+		}
+		sort.Strings(networks)
+		for _, network := range networks {
+			if settings := info.NetworkSettings.Networks[network]; settings != nil && settings.IPAddress != "" {
+				return settings.IPAddress, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("container %s has no network address", name)
+}
+
+// forget drops a container's cached address after a failed connection, as a
+// restarted container may have a new one
+func (p *ProxyPlugin) forget(route ProxyRoute) {
+	if route.Container == "" {
+		return
+	}
+	p.clientsMu.Lock()
+	defer p.clientsMu.Unlock()
+	delete(p.resolved, route.Container)
+}
+
+// client returns the connection pool for a backend
+func (p *ProxyPlugin) client(network, addr string, timeout int) *fasthttp.HostClient {
+	p.clientsMu.Lock()
+	defer p.clientsMu.Unlock()
+
+	key := network + ":" + addr
+	if hc, ok := p.clients[key]; ok {
+		return hc
+	}
+	hc := &fasthttp.HostClient{
+		Addr:                          addr,
+		ReadTimeout:                   time.Duration(timeout) * time.Second,
+		StreamResponseBody:            true,
+		DisablePathNormalizing:        true,
+		DisableHeaderNamesNormalizing: true,
+		NoDefaultUserAgentHeader:      true,
+		Dial: func(addr string) (net.Conn, error) {
+			return net.DialTimeout(network, addr, proxyDialTimeout)
+		},
+	}
+	p.clients[key] = hc
+	return hc
+}
+
+// resetClients closes idle backend connections and forgets container addresses
+func (p *ProxyPlugin) resetClients() {
+	p.clientsMu.Lock()
+	defer p.clientsMu.Unlock()
+	for _, hc := range p.clients {
+		hc.CloseIdleConnections()
+	}
+	p.clients = make(map[string]*fasthttp.HostClient)
+	p.resolved = make(map[string]proxyAddress)
+}
+
+// listRoutes handles GET /api/proxy
+// Container routes are resolved, so the list shows which backends are reachable.
+func (p *ProxyPlugin) listRoutes(c *fiber.Ctx) error {
+	cfg := p.getConfig()
+	routes := make([]ProxyRouteStatus, 0, len(cfg.Routes))
+	for _, route := range cfg.Routes {
+		status := ProxyRouteStatus{
+			Path:       route.Path,
+			Backend:    route.backend(),
+			Auth:       route.Auth,
+			KeepPrefix: route.KeepPrefix,
+		}
+		if route.Container != "" {
+			if _, addr, err := p.resolve(c.UserContext(), route, cfg.DockerClient); err != nil {
+				status.Error = err.Error()
+			} else {
+				status.Address = addr
+			}
+		}
+		routes = append(routes, status)
+	}
+	return SendSuccess(c, routes, "")
+}
+
+// getConfig returns the current configuration
+func (p *ProxyPlugin) getConfig() ProxyConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config
+}
+
+// normalizeProxyConfig fills in defaults
+func normalizeProxyConfig(cfg ProxyConfig) ProxyConfig {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultProxyTimeout
+	}
+	routes := make([]ProxyRoute, len(cfg.Routes))
+	for i, route := range cfg.Routes {
+		route.RouteAuth = route.RouteAuth.normalize()
+		routes[i] = route
+	}
+	cfg.Routes = routes
+	return cfg
+}
+
+// Register the plugin
+func init() {
+	Register("proxy", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[ProxyConfig]("proxy", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewProxyPlugin(cfg)
+	}, "dockerclient")
+}
@@ -0,0 +1,157 @@
+package plugins
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Read-only mode event
+const (
+	readOnlyChangedEvent = "readonly.changed"
+	readOnlyEventSource  = "server"
+)
+
+// ErrReadOnlyMode is returned when a mutating operation is refused in read-only mode
+var ErrReadOnlyMode = errors.New("read-only mode is active")
+
+// readOnlyWritePaths lists GET routes that give write access and are blocked in read-only mode
+var readOnlyWritePaths = []string{
+	"/webshell/ws",
+}
+
+// readOnlyQueryPaths lists POST routes that only read and stay reachable in read-only mode
+var readOnlyQueryPaths = []string{
+	"/metrics/grafana/search",
+	"/metrics/grafana/metrics",
+	"/metrics/grafana/query",
+	"/metrics/grafana/annotations",
+}
+
+// readOnlyTogglePath is the toggle endpoint, which stays reachable in read-only mode
+const readOnlyTogglePath = "/readonly"
+
+// ReadOnlyState describes the read-only mode flag
+type ReadOnlyState struct {
+	Enabled bool       `json:"enabled"`
+	Reason  string     `json:"reason,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+// Read-only mode shared by all plugins
+var (
+	readOnly   ReadOnlyState
+	readOnlyMu sync.RWMutex
+)
+
+// ReadOnlyMode reports whether read-only mode is active
+func ReadOnlyMode() bool {
+	readOnlyMu.RLock()
+	defer readOnlyMu.RUnlock()
+	return readOnly.Enabled
+}
+
+// getReadOnly returns a copy of the read-only state
+func getReadOnly() ReadOnlyState {
+	readOnlyMu.RLock()
+	defer readOnlyMu.RUnlock()
+	return readOnly
+}
+
+// SetReadOnly switches read-only mode and publishes the change
+func SetReadOnly(enabled bool, reason string) ReadOnlyState {
+	state := ReadOnlyState{Enabled: enabled}
+	if enabled {
+		now := time.Now()
+		state.Reason = reason
+		state.Since = &now
+	}
+
+	readOnlyMu.Lock()
+	changed := readOnly.Enabled != enabled
+	readOnly = state
+	readOnlyMu.Unlock()
+
+	if changed {
+		slog.Info("Read-only mode changed", "enabled", enabled, "reason", reason)
+		PublishEvent(readOnlyChangedEvent, readOnlyEventSource, state)
+	}
+	return state
+}
+
+// isMutatingRequest reports whether an API request can change state
+func isMutatingRequest(method string, path string) bool {
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		for _, writePath := range readOnlyWritePaths {
+			if path == APIPath(writePath) {
+				return true
+			}
+		}
+		return false
+	case fiber.MethodPost:
+		for _, queryPath := range readOnlyQueryPaths {
+			if path == APIPath(queryPath) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ReadOnlyMiddleware refuses mutating API requests with 403 while read-only mode is active
+// Must be registered after LegacyAPIRewrite so paths are versioned
+func ReadOnlyMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !ReadOnlyMode() {
+			return c.Next()
+		}
+		path := strings.TrimSuffix(c.Path(), "/")
+		// Minting a download link changes nothing
+		if !strings.HasPrefix(path, "/api/") || path == APIPath(readOnlyTogglePath) || path == APIPath(signedLinkPath) {
+			return c.Next()
+		}
+		if isMutatingRequest(c.Method(), path) {
+			return SendError(c, 403, ErrReadOnlyMode)
+		}
+		return c.Next()
+	}
+}
+
+// isLoopback reports whether a client address is local to the device
+func isLoopback(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && parsed.IsLoopback()
+}
+
+// HandleReadOnlyStatus handles GET /api/readonly
+func HandleReadOnlyStatus(c *fiber.Ctx) error {
+	return SendSuccess(c, getReadOnly(), "")
+}
+
+// HandleReadOnlyToggle handles PUT /api/readonly
+// Anyone can enable read-only mode; while it is active only local clients can disable it
+func HandleReadOnlyToggle(c *fiber.Ctx) error {
+	var req struct {
+		Enabled *bool  `json:"enabled"`
+		Reason  string `json:"reason"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Enabled == nil {
+		return SendErrorMessage(c, 400, "Request body must contain \"enabled\"")
+	}
+
+	if !*req.Enabled && ReadOnlyMode() && !isLoopback(c.IP()) {
+		return SendErrorMessage(c, 403, "Read-only mode can only be disabled from the device itself")
+	}
+
+	state := SetReadOnly(*req.Enabled, req.Reason)
+	if state.Enabled {
+		return SendSuccess(c, state, "Read-only mode enabled")
+	}
+	return SendSuccess(c, state, "Read-only mode disabled")
+}
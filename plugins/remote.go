@@ -0,0 +1,271 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Remote storage types
+const (
+	RemoteSFTP = "sftp"
+	RemoteS3   = "s3"
+)
+
+// RemoteConfig describes a storage target outside the device
+type RemoteConfig struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"` // sftp or s3
+	Path string `yaml:"path"` // base directory (sftp) or key prefix (s3)
+
+	// sftp, through the system sftp client with key login
+	Host         string `yaml:"host"`
+	Port         int    `yaml:"port"`
+	User         string `yaml:"user"`
+	IdentityFile string `yaml:"identity_file"`
+
+	// s3 and compatible stores (MinIO, Garage), addressed path-style
+	Endpoint  string `yaml:"endpoint"` // e.g. https://s3.eu-central-1.amazonaws.com
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+}
+
+// RemoteEntry is a file or directory on a remote
+type RemoteEntry struct {
+	Name     string    `json:"name"`
+	IsDir    bool      `json:"isDir"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// remoteStore stores files below the base path of a remote or local directory
+// Names are slash-separated and relative to the base path.
+type remoteStore interface {
+	// put uploads a local file to name, replacing an existing file
+	// The local store moves the file instead of copying it.
+	put(ctx context.Context, localPath, name string, t *transfer) error
+	// get downloads name to a local file
+	get(ctx context.Context, name, localPath string, t *transfer) error
+	// list returns the entries of a directory; a missing directory is empty
+	list(ctx context.Context, dir string) ([]RemoteEntry, error)
+	// remove deletes a file
+	remove(ctx context.Context, name string) error
+}
+
+// transfer reports the bytes copied by several puts or gets as one job's progress
+type transfer struct {
+	job      *JobHandle
+	done     int64
+	total    int64
+	reported time.Time
+}
+
+// add counts n copied bytes; a nil transfer ignores them
+func (t *transfer) add(n int64) {
+	if t == nil {
+		return
+	}
+	t.done += n
+	if time.Since(t.reported) >= jobProgressInterval || t.done == t.total {
+		t.job.Progress(t.done, t.total, "bytes")
+		t.reported = time.Now()
+	}
+}
+
+// reader counts the bytes read from r
+func (t *transfer) reader(r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	return &transferReader{r: r, t: t}
+}
+
+// transferReader counts the bytes read through it
+type transferReader struct {
+	r io.Reader
+	t *transfer
+}
+
+func (r *transferReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.t.add(int64(n))
+	return n, err
+}
+
+// remoteName matches remote names
+var remoteName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// ValidateRemotes checks the configured remotes
+func ValidateRemotes(remotes []RemoteConfig) error {
+	seen := make(map[string]bool)
+	for i, remote := range remotes {
+		if !remoteName.MatchString(remote.Name) {
+			return fmt.Errorf("remotes[%d]: name %q must be lowercase letters, digits, - or _", i, remote.Name)
+		}
+		if seen[remote.Name] {
+			return fmt.Errorf("remotes[%d]: name %q is used twice", i, remote.Name)
+		}
+		seen[remote.Name] = true
+		if err := validRemotePath(remote.Path); err != nil {
+			return fmt.Errorf("remotes[%d]: %w", i, err)
+		}
+
+		switch remote.Type {
+		case RemoteSFTP:
+			if remote.Host == "" || strings.HasPrefix(remote.Host, "-") || strings.ContainsAny(remote.Host, "@ /") {
+				return fmt.Errorf("remotes[%d]: sftp needs a valid host", i)
+			}
+			if remote.User != "" && (strings.HasPrefix(remote.User, "-") || strings.ContainsAny(remote.User, "@ /")) {
+				return fmt.Errorf("remotes[%d]: invalid sftp user %q", i, remote.User)
+			}
+			if remote.Port < 0 || remote.Port > 65535 {
+				return fmt.Errorf("remotes[%d]: invalid port %d", i, remote.Port)
+			}
+		case RemoteS3:
+			if !strings.HasPrefix(remote.Endpoint, "https://") && !strings.HasPrefix(remote.Endpoint, "http://") {
+				return fmt.Errorf("remotes[%d]: s3 endpoint must be an http or https URL", i)
+			}
+			if remote.Bucket == "" || strings.Contains(remote.Bucket, "/") {
+				return fmt.Errorf("remotes[%d]: s3 needs a bucket", i)
+			}
+			if remote.AccessKey == "" || remote.SecretKey == "" {
+				return fmt.Errorf("remotes[%d]: s3 needs access_key and secret_key", i)
+			}
+		default:
+			return fmt.Errorf("remotes[%d]: type must be sftp or s3, got %q", i, remote.Type)
+		}
+	}
+	return nil
+}
+
+// validRemotePath refuses paths the sftp batch syntax cannot quote
+func validRemotePath(p string) error {
+	if strings.ContainsAny(p, "\"\n\r\\") {
+		return fmt.Errorf("path %q must not contain quotes, backslashes or line breaks", p)
+	}
+	return nil
+}
+
+// findRemote returns a remote by name
+func findRemote(remotes []RemoteConfig, name string) (RemoteConfig, bool) {
+	for _, remote := range remotes {
+		if remote.Name == name {
+			return remote, true
+		}
+	}
+	return RemoteConfig{}, false
+}
+
+// newRemoteStore returns the store of a remote
+func newRemoteStore(remote RemoteConfig) remoteStore {
+	if remote.Type == RemoteS3 {
+		return newS3Store(remote)
+	}
+	return &sftpStore{remote: remote}
+}
+
+// parseTarget splits a target into a remote and a directory
+// Targets are local directories (/media/usb0/backups) or remote:dir (nas:linht).
+func parseTarget(target string, remotes []RemoteConfig) (*RemoteConfig, string, error) {
+	if strings.HasPrefix(target, "/") {
+		return nil, filepath.Clean(target), nil
+	}
+	name, dir, ok := strings.Cut(target, ":")
+	if !ok {
+		return nil, "", fmt.Errorf("target %q must be an absolute directory or remote:path", target)
+	}
+	remote, ok := findRemote(remotes, name)
+	if !ok {
+		return nil, "", fmt.Errorf("target %q names an unknown remote", target)
+	}
+	if err := validRemotePath(dir); err != nil {
+		return nil, "", err
+	}
+	return &remote, path.Clean("/" + dir)[1:], nil
+}
+
+// localStore keeps files in a local directory, e.g. on a USB disk
+type localStore struct {
+	dir string
+}
+
+func (s *localStore) put(ctx context.Context, localPath, name string, t *transfer) error {
+	dest := filepath.Join(s.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(localPath, dest); err == nil {
+		t.add(info.Size())
+		return nil
+	}
+
+	// Different filesystem: copy next to the destination, then rename
+	if err := copyLocalFile(ctx, localPath, dest, t); err != nil {
+		return err
+	}
+	return os.Remove(localPath)
+}
+
+func (s *localStore) get(ctx context.Context, name, localPath string, t *transfer) error {
+	return copyLocalFile(ctx, filepath.Join(s.dir, filepath.FromSlash(name)), localPath, t)
+}
+
+// copyLocalFile copies a file through a temporary file next to dest
+func copyLocalFile(ctx context.Context, srcPath, dest string, t *transfer) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".part-*")
+	if err != nil {
+		return err
+	}
+	tmp.Chmod(0644)
+	_, err = copyContext(ctx, tmp, t.reader(src))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dest)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func (s *localStore) list(ctx context.Context, dir string) ([]RemoteEntry, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, filepath.FromSlash(dir)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := make([]RemoteEntry, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		list = append(list, RemoteEntry{Name: entry.Name(), IsDir: entry.IsDir(), Size: info.Size(), Modified: info.ModTime()})
+	}
+	return list, nil
+}
+
+func (s *localStore) remove(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(s.dir, filepath.FromSlash(name)))
+}
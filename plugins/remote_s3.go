@@ -0,0 +1,268 @@
+package plugins
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 limits
+const (
+	s3MaxPutSize    = 5 * 1024 * 1024 * 1024 // bytes of a single PUT
+	s3EmptyPayload  = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	s3Unsigned      = "UNSIGNED-PAYLOAD"
+	s3DefaultRegion = "us-east-1"
+)
+
+// s3Store talks to S3 compatible object stores with signature version 4
+// Keys are addressed path-style (endpoint/bucket/key), which MinIO and other
+// self-hosted stores require and AWS still accepts.
+type s3Store struct {
+	remote RemoteConfig
+	client *http.Client
+}
+
+// newS3Store creates the store of an s3 remote
+func newS3Store(remote RemoteConfig) *s3Store {
+	if remote.Region == "" {
+		remote.Region = s3DefaultRegion
+	}
+	return &s3Store{remote: remote, client: &http.Client{}}
+}
+
+// key returns the object key of a name below the remote's prefix
+func (s *s3Store) key(name string) string {
+	return strings.TrimPrefix(path.Join(s.remote.Path, name), "/")
+}
+
+// s3Escape encodes a string as S3 expects in canonical requests
+func s3Escape(value string, keepSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// request builds a signed request for a key ("" for the bucket)
+func (s *s3Store) request(ctx context.Context, method, key string, query url.Values, body io.Reader, payloadHash string) (*http.Request, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(s.remote.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	canonicalURI := endpoint.Path + "/" + s3Escape(s.remote.Bucket, false)
+	if key != "" {
+		canonicalURI += "/" + s3Escape(key, true)
+	}
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	params := make([]string, 0, len(keys))
+	for _, k := range keys {
+		params = append(params, s3Escape(k, false)+"="+s3Escape(query.Get(k), false))
+	}
+	canonicalQuery := strings.Join(params, "&")
+
+	target := endpoint.Scheme + "://" + endpoint.Host + canonicalURI
+	if canonicalQuery != "" {
+		target += "?" + canonicalQuery
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalURI,
+		canonicalQuery,
+		"host:" + endpoint.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		"host;x-amz-content-sha256;x-amz-date",
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.remote.Region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	signingKey := []byte("AWS4" + s.remote.SecretKey)
+	for _, part := range []string{date, s.remote.Region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=%s",
+		s.remote.AccessKey, scope, signature))
+	return req, nil
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// do sends a request and turns error answers into errors
+func (s *s3Store) do(req *http.Request) (*http.Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var answer struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		xml.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&answer)
+		if answer.Code == "" {
+			answer.Code = resp.Status
+		}
+		return nil, fmt.Errorf("s3 %s: %s %s", s.remote.Name, answer.Code, answer.Message)
+	}
+	return resp, nil
+}
+
+func (s *s3Store) put(ctx context.Context, localPath, name string, t *transfer) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > s3MaxPutSize {
+		return fmt.Errorf("s3 %s: %s is larger than the 5 GB a single upload can take", s.remote.Name, name)
+	}
+
+	// The payload is sent unsigned, so the file is read only once
+	req, err := s.request(ctx, http.MethodPut, s.key(name), nil, t.reader(file), s3Unsigned)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *s3Store) get(ctx context.Context, name, localPath string, t *transfer) error {
+	req, err := s.request(ctx, http.MethodGet, s.key(name), nil, nil, s3EmptyPayload)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	file, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = copyContext(ctx, file, t.reader(resp.Body))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (s *s3Store) list(ctx context.Context, dir string) ([]RemoteEntry, error) {
+	prefix := s.key(dir)
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var entries []RemoteEntry
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}, "delimiter": {"/"}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := s.request(ctx, http.MethodGet, "", query, nil, s3EmptyPayload)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			CommonPrefixes []struct {
+				Prefix string `xml:"Prefix"`
+			} `xml:"CommonPrefixes"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(io.LimitReader(resp.Body, 16*1024*1024)).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3 %s: %w", s.remote.Name, err)
+		}
+
+		for _, prefix := range result.CommonPrefixes {
+			entries = append(entries, RemoteEntry{Name: path.Base(prefix.Prefix), IsDir: true})
+		}
+		for _, object := range result.Contents {
+			if strings.HasSuffix(object.Key, "/") {
+				continue // directory marker
+			}
+			entries = append(entries, RemoteEntry{Name: path.Base(object.Key), Size: object.Size, Modified: object.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return entries, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *s3Store) remove(ctx context.Context, name string) error {
+	req, err := s.request(ctx, http.MethodDelete, s.key(name), nil, nil, s3EmptyPayload)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
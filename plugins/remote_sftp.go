@@ -0,0 +1,188 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sftpListLine matches a line of `ls -ln`: type, size, date and name
+var sftpListLine = regexp.MustCompile(`^([dl-])\S*\s+\S+\s+\S+\s+\S+\s+(\d+)\s+(\w{3}\s+\d+\s+[\d:]+)\s+(.+)$`)
+
+// sftpStore uses the system sftp client in batch mode
+// Logins must work without a prompt (keys and known_hosts).
+type sftpStore struct {
+	remote RemoteConfig
+}
+
+// path returns a name below the remote's base path
+func (s *sftpStore) path(name string) string {
+	if s.remote.Path == "" {
+		return name
+	}
+	return path.Join(s.remote.Path, name)
+}
+
+// run executes batch commands; a command failing ends the batch with an error
+func (s *sftpStore) run(ctx context.Context, commands ...string) (string, error) {
+	args := []string{"-b", "-", "-o", "BatchMode=yes", "-o", "ConnectTimeout=10"}
+	if s.remote.Port != 0 {
+		args = append(args, "-P", strconv.Itoa(s.remote.Port))
+	}
+	if s.remote.IdentityFile != "" {
+		args = append(args, "-i", s.remote.IdentityFile)
+	}
+	destination := s.remote.Host
+	if s.remote.User != "" {
+		destination = s.remote.User + "@" + destination
+	}
+	args = append(args, "--", destination)
+
+	cmd := exec.CommandContext(ctx, "sftp", args...)
+	cmd.Stdin = strings.NewReader(strings.Join(commands, "\n") + "\n")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return stdout.String(), fmt.Errorf("sftp %s: %s", s.remote.Name, message)
+		}
+		return stdout.String(), fmt.Errorf("sftp %s: %w", s.remote.Name, err)
+	}
+	return stdout.String(), nil
+}
+
+// sftpQuote quotes a path for the batch syntax; paths with quotes are refused by validRemotePath
+func sftpQuote(p string) string {
+	return `"` + p + `"`
+}
+
+func (s *sftpStore) put(ctx context.Context, localPath, name string, t *transfer) error {
+	if err := validRemotePath(localPath); err != nil {
+		return err
+	}
+	if err := validRemotePath(name); err != nil {
+		return err
+	}
+	dest := s.path(name)
+
+	// Create the directories, ignoring those that exist, then upload under a
+	// temporary name so an interrupted upload never looks complete
+	var commands []string
+	dir := path.Dir(dest)
+	for _, parent := range parentDirs(dir) {
+		commands = append(commands, "-mkdir "+sftpQuote(parent))
+	}
+	commands = append(commands,
+		"put "+sftpQuote(localPath)+" "+sftpQuote(dest+".part"),
+		"rename "+sftpQuote(dest+".part")+" "+sftpQuote(dest))
+	if _, err := s.run(ctx, commands...); err != nil {
+		return err
+	}
+	if info, err := os.Stat(localPath); err == nil {
+		t.add(info.Size())
+	}
+	return nil
+}
+
+// get downloads to the local path; the sftp client reports no progress,
+// so the size is counted when the file is complete
+func (s *sftpStore) get(ctx context.Context, name, localPath string, t *transfer) error {
+	if err := validRemotePath(name); err != nil {
+		return err
+	}
+	if err := validRemotePath(localPath); err != nil {
+		return err
+	}
+	if _, err := s.run(ctx, "get "+sftpQuote(s.path(name))+" "+sftpQuote(localPath)); err != nil {
+		return err
+	}
+	if info, err := os.Stat(localPath); err == nil {
+		t.add(info.Size())
+	}
+	return nil
+}
+
+// parentDirs returns dir and its parents, outermost first
+func parentDirs(dir string) []string {
+	var dirs []string
+	for dir != "." && dir != "/" && dir != "" {
+		dirs = append([]string{dir}, dirs...)
+		dir = path.Dir(dir)
+	}
+	return dirs
+}
+
+func (s *sftpStore) list(ctx context.Context, dir string) ([]RemoteEntry, error) {
+	if err := validRemotePath(dir); err != nil {
+		return nil, err
+	}
+	target := s.path(dir)
+	if target == "" {
+		target = "."
+	}
+	out, err := s.run(ctx, "ls -ln "+sftpQuote(target))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "No such file") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []RemoteEntry
+	now := time.Now()
+	for _, line := range strings.Split(out, "\n") {
+		match := sftpListLine.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		name := path.Base(match[4])
+		if name == "." || name == ".." {
+			continue
+		}
+		size, _ := strconv.ParseInt(match[2], 10, 64)
+		entries = append(entries, RemoteEntry{
+			Name:     name,
+			IsDir:    match[1] == "d",
+			Size:     size,
+			Modified: parseListTime(match[3], now),
+		})
+	}
+	return entries, nil
+}
+
+// parseListTime parses the date of an ls listing: "Oct 16 03:00" within the
+// last half year, otherwise "Oct 16  2024"
+func parseListTime(value string, now time.Time) time.Time {
+	value = strings.Join(strings.Fields(value), " ")
+	if t, err := time.ParseInLocation("Jan 2 2006", value, time.Local); err == nil {
+		return t
+	}
+	t, err := time.ParseInLocation("Jan 2 15:04", value, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	t = t.AddDate(now.Year(), 0, 0)
+	if t.After(now.AddDate(0, 0, 1)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
+
+func (s *sftpStore) remove(ctx context.Context, name string) error {
+	if err := validRemotePath(name); err != nil {
+		return err
+	}
+	_, err := s.run(ctx, "rm "+sftpQuote(s.path(name)))
+	return err
+}
@@ -7,7 +7,9 @@ type APIResponse struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	Code    string      `json:"code,omitempty"` // machine-readable error code, e.g. docker_unavailable
 	Message string      `json:"message,omitempty"`
+	Meta    *ListMeta   `json:"meta,omitempty"` // paging of list responses
 }
 
 // SendSuccess sends a successful response
@@ -19,6 +21,15 @@ func SendSuccess(c *fiber.Ctx, data interface{}, message string) error {
 	})
 }
 
+// SendList sends a page of a list with its paging details
+func SendList(c *fiber.Ctx, data interface{}, meta *ListMeta) error {
+	return c.JSON(APIResponse{
+		Success: true,
+		Data:    data,
+		Meta:    meta,
+	})
+}
+
 // SendError sends an error response
 func SendError(c *fiber.Ctx, status int, err error) error {
 	return c.Status(status).JSON(APIResponse{
@@ -0,0 +1,96 @@
+package plugins
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Auth modes of routes served outside the API
+const (
+	RouteAuthNone  = "none"
+	RouteAuthBasic = "basic" // username and password, prompted by browsers
+	RouteAuthToken = "token" // Authorization: Bearer <token>, for tools
+)
+
+// RouteAuth protects static mounts and proxy routes
+// Embedded inline, so its settings sit next to the route's own.
+type RouteAuth struct {
+	Auth     string `yaml:"auth"` // none (default), basic or token
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Token    string `yaml:"token"`
+}
+
+// normalize fills in the default mode
+func (a RouteAuth) normalize() RouteAuth {
+	if a.Auth == "" {
+		a.Auth = RouteAuthNone
+	}
+	return a
+}
+
+// validate checks that the mode has the credentials it needs
+func (a RouteAuth) validate() error {
+	switch a.normalize().Auth {
+	case RouteAuthNone:
+	case RouteAuthBasic:
+		if a.Username == "" || a.Password == "" {
+			return fmt.Errorf("basic auth needs username and password")
+		}
+	case RouteAuthToken:
+		if a.Token == "" {
+			return fmt.Errorf("token auth needs a token")
+		}
+	default:
+		return fmt.Errorf("auth must be none, basic or token")
+	}
+	return nil
+}
+
+// authorized checks a request's credentials
+// On failure the WWW-Authenticate challenge for realm is set on the response.
+func (a RouteAuth) authorized(c *fiber.Ctx, realm string) bool {
+	switch a.Auth {
+	case RouteAuthBasic:
+		username, password, ok := basicAuth(c)
+		if !ok || subtle.ConstantTimeCompare([]byte(username), []byte(a.Username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(a.Password)) != 1 {
+			c.Set(fiber.HeaderWWWAuthenticate, fmt.Sprintf("Basic realm=%q", realm))
+			return false
+		}
+	case RouteAuthToken:
+		given, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(a.Token)) != 1 {
+			c.Set(fiber.HeaderWWWAuthenticate, fmt.Sprintf("Bearer realm=%q", realm))
+			return false
+		}
+	}
+	return true
+}
+
+// handler enforces the auth mode in front of other handlers
+func (a RouteAuth) handler(realm string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !a.authorized(c, realm) {
+			return c.Status(401).SendString("Unauthorized")
+		}
+		return c.Next()
+	}
+}
+
+// basicAuth returns the credentials of an Authorization: Basic header
+func basicAuth(c *fiber.Ctx) (string, string, bool) {
+	encoded, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Basic ")
+	if !ok {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
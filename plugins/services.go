@@ -4,27 +4,58 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"log/slog"
+	"math"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-// ServiceInfo represents information about a systemd service
+// Supported systemd unit types
+const (
+	UnitTypeService = "service"
+	UnitTypeTimer   = "timer"
+	UnitTypeSocket  = "socket"
+)
+
+// supportedUnitTypes lists the unit types managed by the services plugin
+var supportedUnitTypes = []string{UnitTypeService, UnitTypeTimer, UnitTypeSocket}
+
+// ServiceInfo represents information about a systemd unit
 type ServiceInfo struct {
 	Name        string `json:"name"`
+	Unit        string `json:"unit"`
+	Type        string `json:"type"`
 	Description string `json:"description"`
 	ActiveState string `json:"active_state"`
 	UnitState   string `json:"unit_state"`
 	IsActive    bool   `json:"is_active"`
 	IsEnabled   bool   `json:"is_enabled"`
+	NextElapse  string `json:"next_elapse,omitempty"`
+	LastTrigger string `json:"last_trigger,omitempty"`
+
+	// Resource accounting; omitted when systemd does not track it for the unit
+	MemoryCurrent uint64 `json:"memory_current,omitempty"` // bytes
+	CPUUsageNSec  uint64 `json:"cpu_usage_nsec,omitempty"`
+	TasksCurrent  uint64 `json:"tasks_current,omitempty"`
+	Restarts      uint64 `json:"restarts"` // automatic restarts by systemd (Restart=)
+}
+
+// ServicesConfig holds the services section of the configuration
+type ServicesConfig struct {
+	Prefix          string `yaml:"prefix"` // only units with this prefix are managed
+	DefaultLogLines string `yaml:"default_log_lines"`
 }
 
 type ServicesPlugin struct {
 	prefix          string
 	defaultLogLines string
+	mu              sync.RWMutex
 }
 
 func NewServicesPlugin(prefix string, defaultLogLines string) (*ServicesPlugin, error) {
@@ -48,10 +79,36 @@ func (p *ServicesPlugin) Shutdown() error {
 	return nil
 }
 
+// Reload applies a new prefix and log line default at runtime
+func (p *ServicesPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[ServicesConfig]("services", config)
+	if err != nil {
+		return err
+	}
+	prefix, defaultLogLines := normalizeServicesConfig(cfg)
+
+	p.mu.Lock()
+	p.prefix = prefix
+	p.defaultLogLines = defaultLogLines
+	p.mu.Unlock()
+
+	slog.Info("Services config reloaded", "prefix", prefix, "default_log_lines", defaultLogLines)
+	return nil
+}
+
+// settings returns the current prefix and default log line count
+func (p *ServicesPlugin) settings() (string, string) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.prefix, p.defaultLogLines
+}
+
 func (p *ServicesPlugin) RegisterRoutes(app *fiber.App) {
-	api := app.Group("/api/services")
+	api := APIGroup(app, "/services")
 
 	api.Get("/", p.listServices)
+	api.Get("/top", p.handleTop)
+	api.Get("/dependencies", p.handleDependencies)
 	api.Post("/:name/start", p.startService)
 	api.Post("/:name/stop", p.stopService)
 	api.Post("/:name/enable", p.enableService)
@@ -59,57 +116,89 @@ func (p *ServicesPlugin) RegisterRoutes(app *fiber.App) {
 	api.Get("/:name/logs", p.streamLogs)
 }
 
+// splitUnitName splits a unit name into its base name and type
+// Names without a known suffix are treated as services
+func splitUnitName(name string) (string, string) {
+	for _, unitType := range supportedUnitTypes {
+		if base, ok := strings.CutSuffix(name, "."+unitType); ok {
+			return base, unitType
+		}
+	}
+	return name, UnitTypeService
+}
+
+// unitName returns the full systemd unit name for a service name
+func unitName(name string) string {
+	base, unitType := splitUnitName(name)
+	return base + "." + unitType
+}
+
 // validateServiceName ensures the service name is safe and has the correct prefix
 func (p *ServicesPlugin) validateServiceName(name string) error {
+	base, _ := splitUnitName(name)
+
 	// Check for valid characters (alphanumeric, dash, underscore, @)
 	validName := regexp.MustCompile(`^[a-zA-Z0-9_@-]+$`)
-	if !validName.MatchString(name) {
+	if !validName.MatchString(base) {
 		return fmt.Errorf("invalid service name: contains invalid characters")
 	}
 
 	// Ensure the service has the required prefix
-	if !strings.HasPrefix(name, p.prefix) {
-		return fmt.Errorf("service must start with prefix '%s'", p.prefix)
+	prefix, _ := p.settings()
+	if !strings.HasPrefix(name, prefix) {
+		return fmt.Errorf("service must start with prefix '%s'", prefix)
 	}
 
 	return nil
 }
 
-// listServices returns all services matching the prefix
+// serviceListSpec names the unit fields for sorting and filtering the service list
+var serviceListSpec = listSpec[ServiceInfo]{
+	key: "unit",
+	fields: map[string]func(ServiceInfo) interface{}{
+		"name":           func(info ServiceInfo) interface{} { return info.Name },
+		"unit":           func(info ServiceInfo) interface{} { return info.Unit },
+		"type":           func(info ServiceInfo) interface{} { return info.Type },
+		"description":    func(info ServiceInfo) interface{} { return info.Description },
+		"active_state":   func(info ServiceInfo) interface{} { return info.ActiveState },
+		"unit_state":     func(info ServiceInfo) interface{} { return info.UnitState },
+		"is_active":      func(info ServiceInfo) interface{} { return info.IsActive },
+		"is_enabled":     func(info ServiceInfo) interface{} { return info.IsEnabled },
+		"memory_current": func(info ServiceInfo) interface{} { return int64(info.MemoryCurrent) },
+		"cpu_usage_nsec": func(info ServiceInfo) interface{} { return int64(info.CPUUsageNSec) },
+		"tasks_current":  func(info ServiceInfo) interface{} { return int64(info.TasksCurrent) },
+		"restarts":       func(info ServiceInfo) interface{} { return int64(info.Restarts) },
+	},
+}
+
+// listServices returns all services, timers and sockets matching the prefix
+// Accepts the shared list parameters.
 func (p *ServicesPlugin) listServices(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// List all units matching the prefix
-	pattern := p.prefix + "*"
-	cmd := exec.CommandContext(ctx, "systemctl", "list-units", "--type=service", "--all", "--no-legend", "--no-pager", pattern)
-	output, err := cmd.Output()
+	services, err := p.list(ctx)
 	if err != nil {
-		// If no services found, return empty list
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-			return SendSuccess(c, []ServiceInfo{}, "")
-		}
-		return SendError(c, 500, fmt.Errorf("failed to list services: %w", err))
+		return SendError(c, 500, err)
 	}
+	services, meta, err := pageList(c, services, serviceListSpec)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+	return SendList(c, services, meta)
+}
 
-	services := []ServiceInfo{}
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-
-		// Parse the systemctl output
-		// Format: UNIT LOAD ACTIVE SUB DESCRIPTION
-		fields := strings.Fields(line)
-		if len(fields) < 4 {
-			continue
-		}
+// list queries systemd for all units matching the prefix
+func (p *ServicesPlugin) list(ctx context.Context) ([]ServiceInfo, error) {
+	units, err := p.unitNames(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-		unitName := fields[0]
-		// Remove .service suffix for cleaner display
-		serviceName := strings.TrimSuffix(unitName, ".service")
+	services := []ServiceInfo{}
+	for _, unit := range units {
+		// Remove .service suffix for cleaner display, keep it for other unit types
+		serviceName := strings.TrimSuffix(unit, ".service")
 
 		// Get detailed info for this service
 		info, err := p.getServiceInfo(ctx, serviceName)
@@ -121,15 +210,51 @@ func (p *ServicesPlugin) listServices(c *fiber.Ctx) error {
 		services = append(services, info)
 	}
 
-	return SendSuccess(c, services, "")
+	return services, nil
+}
+
+// unitNames lists the full names of all units matching the prefix
+func (p *ServicesPlugin) unitNames(ctx context.Context) ([]string, error) {
+	prefix, _ := p.settings()
+	pattern := prefix + "*"
+	cmd := exec.CommandContext(ctx, "systemctl", "list-units", "--type="+strings.Join(supportedUnitTypes, ","), "--all", "--no-legend", "--no-pager", "--plain", pattern)
+	output, err := cmd.Output()
+	if err != nil {
+		// If no units found, return empty list
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	units := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		// Format: UNIT LOAD ACTIVE SUB DESCRIPTION
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		units = append(units, fields[0])
+	}
+	return units, nil
 }
 
-// getServiceInfo retrieves detailed information about a service
+// getServiceInfo retrieves detailed information about a unit
 func (p *ServicesPlugin) getServiceInfo(ctx context.Context, name string) (ServiceInfo, error) {
-	info := ServiceInfo{Name: name}
+	_, unitType := splitUnitName(name)
+	info := ServiceInfo{
+		Name: name,
+		Unit: unitName(name),
+		Type: unitType,
+	}
+
+	properties := "ActiveState,UnitFileState,Description,MemoryCurrent,CPUUsageNSec,TasksCurrent,NRestarts"
+	if unitType == UnitTypeTimer {
+		properties += ",NextElapseUSecRealtime,LastTriggerUSec"
+	}
 
-	// Get service properties
-	cmd := exec.CommandContext(ctx, "systemctl", "show", "-p", "ActiveState,UnitFileState,Description", name+".service")
+	// Get unit properties
+	cmd := exec.CommandContext(ctx, "systemctl", "show", "-p", properties, info.Unit)
 	output, err := cmd.Output()
 	if err != nil {
 		return info, err
@@ -154,12 +279,42 @@ func (p *ServicesPlugin) getServiceInfo(ctx context.Context, name string) (Servi
 			info.IsEnabled = value == "enabled"
 		case "Description":
 			info.Description = value
+		case "NextElapseUSecRealtime":
+			info.NextElapse = timerTimestamp(value)
+		case "LastTriggerUSec":
+			info.LastTrigger = timerTimestamp(value)
+		case "MemoryCurrent":
+			info.MemoryCurrent = systemdCounter(value)
+		case "CPUUsageNSec":
+			info.CPUUsageNSec = systemdCounter(value)
+		case "TasksCurrent":
+			info.TasksCurrent = systemdCounter(value)
+		case "NRestarts":
+			info.Restarts = systemdCounter(value)
 		}
 	}
 
 	return info, nil
 }
 
+// timerTimestamp normalizes a systemd timer timestamp, dropping unset values
+func timerTimestamp(value string) string {
+	if value == "" || value == "n/a" || value == "0" {
+		return ""
+	}
+	return value
+}
+
+// systemdCounter parses an accounting value, treating "[not set]" and the
+// unset marker (UINT64_MAX) as zero
+func systemdCounter(value string) uint64 {
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || n == math.MaxUint64 {
+		return 0
+	}
+	return n
+}
+
 // startService starts a systemd service
 func (p *ServicesPlugin) startService(c *fiber.Ctx) error {
 	name := c.Params("name")
@@ -171,7 +326,7 @@ func (p *ServicesPlugin) startService(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "systemctl", "start", name+".service")
+	cmd := exec.CommandContext(ctx, "systemctl", "start", unitName(name))
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return SendErrorMessage(c, 500, fmt.Sprintf("failed to start service: %s", string(output)))
 	}
@@ -190,7 +345,7 @@ func (p *ServicesPlugin) stopService(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "systemctl", "stop", name+".service")
+	cmd := exec.CommandContext(ctx, "systemctl", "stop", unitName(name))
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return SendErrorMessage(c, 500, fmt.Sprintf("failed to stop service: %s", string(output)))
 	}
@@ -209,7 +364,7 @@ func (p *ServicesPlugin) enableService(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "systemctl", "enable", name+".service")
+	cmd := exec.CommandContext(ctx, "systemctl", "enable", unitName(name))
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return SendErrorMessage(c, 500, fmt.Sprintf("failed to enable service: %s", string(output)))
 	}
@@ -228,7 +383,7 @@ func (p *ServicesPlugin) disableService(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "systemctl", "disable", name+".service")
+	cmd := exec.CommandContext(ctx, "systemctl", "disable", unitName(name))
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return SendErrorMessage(c, 500, fmt.Sprintf("failed to disable service: %s", string(output)))
 	}
@@ -254,7 +409,8 @@ func (p *ServicesPlugin) streamLogs(c *fiber.Ctx) error {
 	ctx := c.Context()
 
 	// Start journalctl with follow mode
-	cmd := exec.Command("journalctl", "-u", name+".service", "-f", "-n", p.defaultLogLines, "--no-pager", "-o", "short-iso")
+	_, defaultLogLines := p.settings()
+	cmd := exec.Command("journalctl", "-u", unitName(name), "-f", "-n", defaultLogLines, "--no-pager", "-o", "short-iso")
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -286,20 +442,27 @@ func (p *ServicesPlugin) streamLogs(c *fiber.Ctx) error {
 	return nil
 }
 
+// normalizeServicesConfig returns the prefix and log line default, filling in defaults
+func normalizeServicesConfig(cfg ServicesConfig) (string, string) {
+	prefix := "linht-"
+	defaultLogLines := "100"
+
+	if cfg.Prefix != "" {
+		prefix = cfg.Prefix
+	}
+	if cfg.DefaultLogLines != "" {
+		defaultLogLines = cfg.DefaultLogLines
+	}
+	return prefix, defaultLogLines
+}
+
 // Register the plugin
 func init() {
 	Register("services", func(config interface{}) (Plugin, error) {
-		prefix := "linht-"
-		defaultLogLines := "100"
-
-		if cfg, ok := config.(map[string]interface{}); ok {
-			if p, ok := cfg["prefix"].(string); ok && p != "" {
-				prefix = p
-			}
-			if lines, ok := cfg["default_log_lines"].(string); ok && lines != "" {
-				defaultLogLines = lines
-			}
+		cfg, err := configAs[ServicesConfig]("services", config)
+		if err != nil {
+			return nil, err
 		}
-		return NewServicesPlugin(prefix, defaultLogLines)
+		return NewServicesPlugin(normalizeServicesConfig(cfg))
 	})
 }
\ No newline at end of file
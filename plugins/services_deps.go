@@ -0,0 +1,159 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// serviceDependencyProperties maps the systemd dependency properties to the
+// relation types reported in the graph
+var serviceDependencyProperties = []struct {
+	property string
+	kind     string
+}{
+	{"Requires", "requires"},
+	{"Requisite", "requisite"},
+	{"BindsTo", "binds_to"},
+	{"Wants", "wants"},
+	{"After", "after"},
+}
+
+// strongDependencies are the relations a unit cannot run without
+var strongDependencies = map[string]bool{"requires": true, "requisite": true, "binds_to": true}
+
+// ServiceDependency is a relation from one managed unit to another
+type ServiceDependency struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type"` // requires, requisite, binds_to, wants or after
+}
+
+// ServiceGraphUnit is a managed unit with its current state
+type ServiceGraphUnit struct {
+	Unit        string   `json:"unit"`
+	ActiveState string   `json:"active_state"`
+	SubState    string   `json:"sub_state"`
+	Result      string   `json:"result,omitempty"`
+	BlockedBy   []string `json:"blocked_by,omitempty"` // required units that are not active, directly or further down
+}
+
+// ServiceGraph is the dependency graph among the managed units
+type ServiceGraph struct {
+	Units        []ServiceGraphUnit  `json:"units"`
+	Dependencies []ServiceDependency `json:"dependencies"`
+}
+
+// handleDependencies handles GET /api/services/dependencies
+// Shows how the managed units depend on each other and which of them keep
+// others from starting
+func (p *ServicesPlugin) handleDependencies(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	graph, err := p.dependencyGraph(ctx)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	return SendSuccess(c, graph, "")
+}
+
+// dependencyGraph queries systemd for the states and relations of all units
+// matching the prefix; relations to other units are left out
+func (p *ServicesPlugin) dependencyGraph(ctx context.Context) (ServiceGraph, error) {
+	graph := ServiceGraph{Units: []ServiceGraphUnit{}, Dependencies: []ServiceDependency{}}
+
+	units, err := p.unitNames(ctx)
+	if err != nil || len(units) == 0 {
+		return graph, err
+	}
+
+	properties := []string{"Id", "ActiveState", "SubState", "Result"}
+	for _, dep := range serviceDependencyProperties {
+		properties = append(properties, dep.property)
+	}
+	args := append([]string{"show", "-p", strings.Join(properties, ",")}, units...)
+	output, err := exec.CommandContext(ctx, "systemctl", args...).Output()
+	if err != nil {
+		return graph, fmt.Errorf("failed to query unit dependencies: %w", err)
+	}
+
+	managed := make(map[string]bool, len(units))
+	for _, unit := range units {
+		managed[unit] = true
+	}
+
+	// systemctl show separates the units with an empty line
+	states := make(map[string]string, len(units))
+	required := make(map[string][]string)
+	for _, block := range strings.Split(strings.TrimSpace(string(output)), "\n\n") {
+		values := unitProperties(block)
+		unit := ServiceGraphUnit{
+			Unit:        values["Id"],
+			ActiveState: values["ActiveState"],
+			SubState:    values["SubState"],
+			Result:      values["Result"],
+		}
+		if unit.Unit == "" {
+			continue
+		}
+
+		for _, dep := range serviceDependencyProperties {
+			for _, target := range strings.Fields(values[dep.property]) {
+				if !managed[target] || target == unit.Unit {
+					continue
+				}
+				graph.Dependencies = append(graph.Dependencies, ServiceDependency{From: unit.Unit, To: target, Type: dep.kind})
+				if strongDependencies[dep.kind] {
+					required[unit.Unit] = append(required[unit.Unit], target)
+				}
+			}
+		}
+
+		states[unit.Unit] = unit.ActiveState
+		graph.Units = append(graph.Units, unit)
+	}
+
+	for i := range graph.Units {
+		graph.Units[i].BlockedBy = blockedBy(graph.Units[i].Unit, required, states)
+	}
+	return graph, nil
+}
+
+// unitProperties parses the KEY=VALUE lines of systemctl show
+func unitProperties(output string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if ok {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return values
+}
+
+// blockedBy follows the required units of unit breadth-first and returns
+// those that are not active, nearest first
+func blockedBy(unit string, required map[string][]string, states map[string]string) []string {
+	var blocked []string
+	seen := map[string]bool{unit: true}
+	queue := append([]string(nil), required[unit]...)
+	for len(queue) > 0 {
+		dep := queue[0]
+		queue = queue[1:]
+		if seen[dep] {
+			continue
+		}
+		seen[dep] = true
+
+		if states[dep] != "active" {
+			blocked = append(blocked, dep)
+		}
+		queue = append(queue, required[dep]...)
+	}
+	return blocked
+}
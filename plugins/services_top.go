@@ -0,0 +1,47 @@
+package plugins
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// serviceTopKeys maps the sort orders of the top endpoint to the usage they compare
+var serviceTopKeys = map[string]func(ServiceInfo) uint64{
+	"memory":   func(s ServiceInfo) uint64 { return s.MemoryCurrent },
+	"cpu":      func(s ServiceInfo) uint64 { return s.CPUUsageNSec },
+	"tasks":    func(s ServiceInfo) uint64 { return s.TasksCurrent },
+	"restarts": func(s ServiceInfo) uint64 { return s.Restarts },
+}
+
+// handleTop handles GET /api/services/top
+// Lists the managed units by resource usage, largest first
+// Query: sort=memory (default), cpu, tasks or restarts; limit=N
+func (p *ServicesPlugin) handleTop(c *fiber.Ctx) error {
+	key, ok := serviceTopKeys[c.Query("sort", "memory")]
+	if !ok {
+		return SendErrorMessage(c, 400, "sort must be memory, cpu, tasks or restarts")
+	}
+	limit := c.QueryInt("limit", 0)
+	if limit < 0 {
+		return SendErrorMessage(c, 400, "limit must not be negative")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	services, err := p.list(ctx)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+
+	sort.SliceStable(services, func(i, j int) bool {
+		return key(services[i]) > key(services[j])
+	})
+	if limit > 0 && len(services) > limit {
+		services = services[:limit]
+	}
+	return SendSuccess(c, services, "")
+}
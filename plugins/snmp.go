@@ -0,0 +1,739 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gosnmp/gosnmp"
+)
+
+// SNMP defaults
+const (
+	DefaultSNMPListen    = ":161"
+	DefaultSNMPCommunity = "public"
+	DefaultSNMPCacheTTL  = 5 // seconds a collected MIB snapshot is reused
+	// DefaultSNMPBaseOID is below netSnmpPlaypen, reserved for local experimental MIBs
+	DefaultSNMPBaseOID = ".1.3.6.1.4.1.8072.9999.9999.1"
+	snmpMaxRepetitions = 64
+	snmpMaxPacketSize  = 65507
+	snmpCollectTimeout = 10 * time.Second
+)
+
+// MIB-2 system group
+const (
+	oidSysDescr    = ".1.3.6.1.2.1.1.1.0"
+	oidSysObjectID = ".1.3.6.1.2.1.1.2.0"
+	oidSysUpTime   = ".1.3.6.1.2.1.1.3.0"
+	oidSysContact  = ".1.3.6.1.2.1.1.4.0"
+	oidSysName     = ".1.3.6.1.2.1.1.5.0"
+	oidSysLocation = ".1.3.6.1.2.1.1.6.0"
+	oidSysServices = ".1.3.6.1.2.1.1.7.0"
+)
+
+// SNMP TruthValue encoding (SNMPv2-TC)
+const (
+	snmpTrue  = 1
+	snmpFalse = 2
+)
+
+// SNMPConfig holds SNMP agent configuration
+type SNMPConfig struct {
+	Listen    string   `yaml:"listen"`
+	Community string   `yaml:"community"`
+	BaseOID   string   `yaml:"base_oid"`
+	Location  string   `yaml:"location"`
+	Contact   string   `yaml:"contact"`
+	CacheTTL  int      `yaml:"cache_ttl"`
+	DiskPaths []string `yaml:"disk_paths"` // defaults to health.disk_paths
+
+	DockerClient *client.Client `yaml:"-"`
+}
+
+// snmpVariable is a MIB entry with its parsed OID for ordering
+type snmpVariable struct {
+	oid []int
+	pdu gosnmp.SnmpPDU
+}
+
+// snmpMIB is an immutable, sorted snapshot of all exposed variables
+type snmpMIB struct {
+	vars      []snmpVariable
+	collected time.Time
+}
+
+// get returns the variable with exactly the given OID
+func (m *snmpMIB) get(oid []int) (gosnmp.SnmpPDU, bool) {
+	i := sort.Search(len(m.vars), func(i int) bool {
+		return compareOID(m.vars[i].oid, oid) >= 0
+	})
+	if i < len(m.vars) && compareOID(m.vars[i].oid, oid) == 0 {
+		return m.vars[i].pdu, true
+	}
+	return gosnmp.SnmpPDU{}, false
+}
+
+// next returns the first variable following the given OID in lexicographic order
+func (m *snmpMIB) next(oid []int) (gosnmp.SnmpPDU, bool) {
+	i := sort.Search(len(m.vars), func(i int) bool {
+		return compareOID(m.vars[i].oid, oid) > 0
+	})
+	if i < len(m.vars) {
+		return m.vars[i].pdu, true
+	}
+	return gosnmp.SnmpPDU{}, false
+}
+
+// snmpMIBBuilder collects variables for a snapshot
+type snmpMIBBuilder struct {
+	base string
+	vars []snmpVariable
+}
+
+// add appends a variable; oid is absolute when it starts with a dot and relative to the base otherwise
+func (b *snmpMIBBuilder) add(oid string, asnType gosnmp.Asn1BER, value interface{}) {
+	if !strings.HasPrefix(oid, ".") {
+		oid = b.base + "." + oid
+	}
+	parsed, err := parseOID(oid)
+	if err != nil {
+		slog.Error("Invalid SNMP OID", "oid", oid, "error", err)
+		return
+	}
+	b.vars = append(b.vars, snmpVariable{
+		oid: parsed,
+		pdu: gosnmp.SnmpPDU{Name: oid, Type: asnType, Value: value},
+	})
+}
+
+// truth appends a TruthValue
+func (b *snmpMIBBuilder) truth(oid string, value bool) {
+	if value {
+		b.add(oid, gosnmp.Integer, snmpTrue)
+		return
+	}
+	b.add(oid, gosnmp.Integer, snmpFalse)
+}
+
+// gauge appends a Gauge32, clamping values that do not fit
+func (b *snmpMIBBuilder) gauge(oid string, value uint64) {
+	if value > 0xFFFFFFFF {
+		value = 0xFFFFFFFF
+	}
+	b.add(oid, gosnmp.Gauge32, uint32(value))
+}
+
+// build sorts the variables into a snapshot
+func (b *snmpMIBBuilder) build() *snmpMIB {
+	sort.Slice(b.vars, func(i, j int) bool {
+		return compareOID(b.vars[i].oid, b.vars[j].oid) < 0
+	})
+	return &snmpMIB{vars: b.vars, collected: time.Now()}
+}
+
+// SNMPPlugin is a read-only SNMP v1/v2c agent exposing system, Docker and radio health
+type SNMPPlugin struct {
+	config    SNMPConfig
+	startedAt time.Time
+	conn      net.PacketConn
+	doneChan  chan struct{}
+	started   bool // the agent runs, set by Start
+
+	mu           sync.RWMutex
+	mibMu        sync.Mutex
+	mib          *snmpMIB
+	requests     uint64
+	badCommunity uint64
+}
+
+// NewSNMPPlugin creates a new SNMP plugin instance
+func NewSNMPPlugin(cfg SNMPConfig) (*SNMPPlugin, error) {
+	cfg = normalizeSNMPConfig(cfg)
+	if err := validateSNMPConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	return &SNMPPlugin{config: cfg}, nil
+}
+
+// Start opens the socket and begins answering requests
+func (p *SNMPPlugin) Start() error {
+	p.startedAt = time.Now()
+	if err := p.start(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.started = true
+	p.mu.Unlock()
+	return nil
+}
+
+// Name returns the plugin identifier
+func (p *SNMPPlugin) Name() string {
+	return "snmp"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *SNMPPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/snmp")
+
+	api.Get("/status", p.handleStatus)
+	api.Get("/walk", p.handleWalk)
+	api.Get("/get", p.handleGet)
+}
+
+// Shutdown stops the agent
+func (p *SNMPPlugin) Shutdown() error {
+	p.stop()
+	return nil
+}
+
+// Reload applies the new settings, restarting the agent when the listen address changes
+func (p *SNMPPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[SNMPConfig]("snmp", config)
+	if err != nil {
+		return err
+	}
+	cfg = normalizeSNMPConfig(cfg)
+	if err := validateSNMPConfig(cfg); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	restart := cfg.Listen != p.config.Listen && p.started
+	p.config = cfg
+	p.mu.Unlock()
+
+	p.mibMu.Lock()
+	p.mib = nil
+	p.mibMu.Unlock()
+
+	if restart {
+		p.stop()
+		if err := p.start(); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("SNMP config reloaded",
+		"listen", cfg.Listen,
+		"base_oid", cfg.BaseOID,
+		"cache_ttl", cfg.CacheTTL,
+		"restarted", restart)
+	return nil
+}
+
+// getConfig returns the current configuration
+func (p *SNMPPlugin) getConfig() SNMPConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config
+}
+
+// start opens the UDP socket and launches the request loop
+func (p *SNMPPlugin) start() error {
+	cfg := p.getConfig()
+	conn, err := net.ListenPacket("udp", cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", cfg.Listen, err)
+	}
+
+	doneChan := make(chan struct{})
+	p.mu.Lock()
+	p.conn = conn
+	p.doneChan = doneChan
+	p.mu.Unlock()
+
+	slog.Info("SNMP agent started", "listen", conn.LocalAddr().String(), "base_oid", cfg.BaseOID)
+	go p.serve(conn, doneChan)
+	return nil
+}
+
+// stop closes the socket and waits for the request loop to exit
+func (p *SNMPPlugin) stop() {
+	p.mu.RLock()
+	conn, doneChan := p.conn, p.doneChan
+	p.mu.RUnlock()
+	if conn == nil {
+		return
+	}
+
+	conn.Close()
+	<-doneChan
+	slog.Info("SNMP agent stopped")
+}
+
+// serve answers requests until the socket is closed
+func (p *SNMPPlugin) serve(conn net.PacketConn, done chan<- struct{}) {
+	defer close(done)
+
+	buf := make([]byte, snmpMaxPacketSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			slog.Warn("SNMP read failed", "error", err)
+			continue
+		}
+
+		response, err := p.handlePacket(buf[:n])
+		if err != nil {
+			slog.Debug("SNMP request dropped", "remote", addr.String(), "error", err)
+			continue
+		}
+		if _, err := conn.WriteTo(response, addr); err != nil {
+			slog.Debug("SNMP response failed", "remote", addr.String(), "error", err)
+		}
+	}
+}
+
+// handlePacket decodes a request and builds the encoded response
+// Requests with an unsupported version or wrong community are dropped as RFC 3584 suggests
+func (p *SNMPPlugin) handlePacket(data []byte) ([]byte, error) {
+	request, err := (&gosnmp.GoSNMP{}).SnmpDecodePacket(data)
+	if err != nil {
+		return nil, err
+	}
+	if request.Version != gosnmp.Version1 && request.Version != gosnmp.Version2c {
+		return nil, fmt.Errorf("unsupported SNMP version %s", request.Version)
+	}
+
+	cfg := p.getConfig()
+	p.mu.Lock()
+	p.requests++
+	if request.Community != cfg.Community {
+		p.badCommunity++
+		p.mu.Unlock()
+		return nil, fmt.Errorf("wrong community")
+	}
+	p.mu.Unlock()
+
+	response := &gosnmp.SnmpPacket{
+		Version:   request.Version,
+		Community: request.Community,
+		PDUType:   gosnmp.GetResponse,
+		RequestID: request.RequestID,
+	}
+
+	switch request.PDUType {
+	case gosnmp.GetRequest, gosnmp.GetNextRequest:
+		mib := p.snapshot()
+		response.Variables, response.Error, response.ErrorIndex = p.resolve(mib, request.Version, request.PDUType, request.Variables)
+	case gosnmp.GetBulkRequest:
+		if request.Version == gosnmp.Version1 {
+			return nil, fmt.Errorf("GetBulk is not part of SNMPv1")
+		}
+		response.Variables = p.bulk(p.snapshot(), request)
+	case gosnmp.SetRequest:
+		// The agent is read-only
+		response.Variables = request.Variables
+		response.Error = gosnmp.NotWritable
+		if request.Version == gosnmp.Version1 {
+			response.Error = gosnmp.NoSuchName
+		}
+		response.ErrorIndex = 1
+	default:
+		return nil, fmt.Errorf("unsupported PDU type %s", request.PDUType)
+	}
+
+	encoded, err := response.MarshalMsg()
+	if err != nil {
+		return nil, err
+	}
+	if len(encoded) > snmpMaxPacketSize {
+		response.Variables = request.Variables
+		response.Error = gosnmp.TooBig
+		response.ErrorIndex = 0
+		return response.MarshalMsg()
+	}
+	return encoded, nil
+}
+
+// resolve answers Get and GetNext requests
+// SNMPv1 reports missing variables with noSuchName, v2c with exception values
+func (p *SNMPPlugin) resolve(mib *snmpMIB, version gosnmp.SnmpVersion, pduType gosnmp.PDUType, requested []gosnmp.SnmpPDU) ([]gosnmp.SnmpPDU, gosnmp.SNMPError, uint8) {
+	result := make([]gosnmp.SnmpPDU, len(requested))
+	for i, v := range requested {
+		oid, err := parseOID(v.Name)
+		var pdu gosnmp.SnmpPDU
+		found := false
+		if err == nil {
+			if pduType == gosnmp.GetRequest {
+				pdu, found = mib.get(oid)
+			} else {
+				pdu, found = mib.next(oid)
+			}
+		}
+
+		if found {
+			result[i] = pdu
+			continue
+		}
+		if version == gosnmp.Version1 {
+			return requested, gosnmp.NoSuchName, uint8(i + 1)
+		}
+		exception := gosnmp.NoSuchObject
+		if pduType == gosnmp.GetNextRequest {
+			exception = gosnmp.EndOfMibView
+		}
+		result[i] = gosnmp.SnmpPDU{Name: v.Name, Type: exception}
+	}
+	return result, gosnmp.NoError, 0
+}
+
+// bulk answers GetBulk requests as defined in RFC 3416 section 4.2.3
+func (p *SNMPPlugin) bulk(mib *snmpMIB, request *gosnmp.SnmpPacket) []gosnmp.SnmpPDU {
+	nonRepeaters := int(request.NonRepeaters)
+	if nonRepeaters > len(request.Variables) {
+		nonRepeaters = len(request.Variables)
+	}
+	repetitions := int(request.MaxRepetitions)
+	if repetitions > snmpMaxRepetitions {
+		repetitions = snmpMaxRepetitions
+	}
+
+	result, _, _ := p.resolve(mib, gosnmp.Version2c, gosnmp.GetNextRequest, request.Variables[:nonRepeaters])
+
+	cursors := append([]gosnmp.SnmpPDU(nil), request.Variables[nonRepeaters:]...)
+	for r := 0; r < repetitions && len(cursors) > 0; r++ {
+		row, _, _ := p.resolve(mib, gosnmp.Version2c, gosnmp.GetNextRequest, cursors)
+		result = append(result, row...)
+
+		done := true
+		for i, v := range row {
+			if v.Type != gosnmp.EndOfMibView {
+				done = false
+			}
+			cursors[i] = gosnmp.SnmpPDU{Name: v.Name}
+		}
+		if done {
+			break
+		}
+	}
+	return result
+}
+
+// snapshot returns the cached MIB, collecting a new one when it is older than the cache TTL
+func (p *SNMPPlugin) snapshot() *snmpMIB {
+	cfg := p.getConfig()
+
+	p.mibMu.Lock()
+	defer p.mibMu.Unlock()
+
+	if p.mib != nil && time.Since(p.mib.collected) < time.Duration(cfg.CacheTTL)*time.Second {
+		return p.mib
+	}
+	p.mib = p.collect(cfg)
+	return p.mib
+}
+
+// collect reads all values exposed by the agent
+func (p *SNMPPlugin) collect(cfg SNMPConfig) *snmpMIB {
+	ctx, cancel := context.WithTimeout(context.Background(), snmpCollectTimeout)
+	defer cancel()
+
+	b := &snmpMIBBuilder{base: cfg.BaseOID}
+	hostname, _ := os.Hostname()
+
+	// MIB-2 system group
+	b.add(oidSysDescr, gosnmp.OctetString, systemDescription())
+	b.add(oidSysObjectID, gosnmp.ObjectIdentifier, cfg.BaseOID)
+	b.add(oidSysUpTime, gosnmp.TimeTicks, uint32(time.Since(p.startedAt)/(10*time.Millisecond)))
+	b.add(oidSysContact, gosnmp.OctetString, cfg.Contact)
+	b.add(oidSysName, gosnmp.OctetString, hostname)
+	b.add(oidSysLocation, gosnmp.OctetString, cfg.Location)
+	b.add(oidSysServices, gosnmp.Integer, 72) // applications and end-to-end layers
+
+	// <base>.1 system health
+	if load, ok := readLoadAverage(); ok {
+		b.gauge("1.1.0", uint64(load*100))
+	}
+	if total, available, ok := readMemInfo(); ok {
+		b.gauge("1.2.0", total)
+		b.gauge("1.3.0", available)
+	}
+	for i, path := range cfg.DiskPaths {
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(path, &stat); err != nil {
+			continue
+		}
+		index := strconv.Itoa(i + 1)
+		b.add("1.4.1.1."+index, gosnmp.Integer, i+1)
+		b.add("1.4.1.2."+index, gosnmp.OctetString, path)
+		b.gauge("1.4.1.3."+index, stat.Blocks*uint64(stat.Bsize)/1024/1024)
+		b.gauge("1.4.1.4."+index, stat.Bavail*uint64(stat.Bsize)/1024/1024)
+	}
+	for i, zone := range readThermalZones() {
+		index := strconv.Itoa(i + 1)
+		b.add("1.5.1.1."+index, gosnmp.Integer, i+1)
+		b.add("1.5.1.2."+index, gosnmp.OctetString, zone.Type)
+		b.add("1.5.1.3."+index, gosnmp.Integer, int(zone.Temperature*1000))
+	}
+	b.truth("1.6.0", MaintenanceMode())
+
+	// <base>.2 Docker
+	dockerOK := false
+	containers := []types.Container{}
+	if cfg.DockerClient != nil {
+		list, err := cfg.DockerClient.ContainerList(ctx, container.ListOptions{All: true})
+		if err != nil {
+			slog.Debug("SNMP container collection failed", "error", err)
+		} else {
+			dockerOK = true
+			containers = list
+		}
+	}
+	b.truth("2.1.0", dockerOK)
+	running := 0
+	for i, cont := range containers {
+		if cont.State == "running" {
+			running++
+		}
+		name := cont.ID[:12]
+		if len(cont.Names) > 0 {
+			name = strings.TrimPrefix(cont.Names[0], "/")
+		}
+		index := strconv.Itoa(i + 1)
+		b.add("2.4.1.1."+index, gosnmp.Integer, i+1)
+		b.add("2.4.1.2."+index, gosnmp.OctetString, name)
+		b.add("2.4.1.3."+index, gosnmp.OctetString, cont.Image)
+		b.add("2.4.1.4."+index, gosnmp.OctetString, cont.State)
+		b.add("2.4.1.5."+index, gosnmp.OctetString, healthFromStatus(cont.Status))
+	}
+	b.gauge("2.2.0", uint64(len(containers)))
+	b.gauge("2.3.0", uint64(running))
+
+	// <base>.3 radio
+	status, ok := CurrentHardwareStatus()
+	b.truth("3.1.0", ok && status.Error == "")
+	if ok && status.Error == "" {
+		b.add("3.2.0", gosnmp.Integer, int(status.ModeValue))
+		b.add("3.3.0", gosnmp.OctetString, status.Mode)
+		b.truth("3.4.0", status.RxPLLLocked)
+		b.truth("3.5.0", status.TxPLLLocked)
+		b.truth("3.6.0", status.XoscReady)
+		b.truth("3.7.0", status.EOL)
+	}
+	b.gauge("3.8.0", uint64(len(status.Alarms)))
+	for i, alarm := range status.Alarms {
+		index := strconv.Itoa(i + 1)
+		b.add("3.9.1.1."+index, gosnmp.Integer, i+1)
+		b.add("3.9.1.2."+index, gosnmp.OctetString, alarm.Condition)
+		b.add("3.9.1.3."+index, gosnmp.OctetString, alarm.Severity)
+		b.add("3.9.1.4."+index, gosnmp.OctetString, alarm.Message)
+	}
+
+	return b.build()
+}
+
+// handleStatus handles GET /api/snmp/status
+func (p *SNMPPlugin) handleStatus(c *fiber.Ctx) error {
+	cfg := p.getConfig()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return SendSuccess(c, fiber.Map{
+		"listen":          p.conn.LocalAddr().String(),
+		"base_oid":        cfg.BaseOID,
+		"cache_ttl":       cfg.CacheTTL,
+		"requests":        p.requests,
+		"bad_communities": p.badCommunity,
+		"uptime":          time.Since(p.startedAt).Round(time.Second).String(),
+	}, "")
+}
+
+// handleWalk handles GET /api/snmp/walk?oid=
+// Returns all variables below an OID (default: everything) for NMS systems that poll over HTTP
+func (p *SNMPPlugin) handleWalk(c *fiber.Ctx) error {
+	root, err := parseOID(c.Query("oid", ".1"))
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+
+	mib := p.snapshot()
+	result := []fiber.Map{}
+	for _, v := range mib.vars {
+		if hasOIDPrefix(v.oid, root) {
+			result = append(result, snmpVariableJSON(v.pdu))
+		}
+	}
+	return SendSuccess(c, result, "")
+}
+
+// handleGet handles GET /api/snmp/get?oid=
+// Accepts a comma-separated list of OIDs
+func (p *SNMPPlugin) handleGet(c *fiber.Ctx) error {
+	if c.Query("oid") == "" {
+		return SendErrorMessage(c, 400, "oid is required")
+	}
+
+	mib := p.snapshot()
+	result := []fiber.Map{}
+	for _, name := range strings.Split(c.Query("oid"), ",") {
+		oid, err := parseOID(strings.TrimSpace(name))
+		if err != nil {
+			return SendError(c, 400, err)
+		}
+		pdu, ok := mib.get(oid)
+		if !ok {
+			return SendErrorMessage(c, 404, fmt.Sprintf("No such object: %s", name))
+		}
+		result = append(result, snmpVariableJSON(pdu))
+	}
+	return SendSuccess(c, result, "")
+}
+
+// snmpVariableJSON renders a variable for the HTTP endpoints
+func snmpVariableJSON(pdu gosnmp.SnmpPDU) fiber.Map {
+	return fiber.Map{
+		"oid":   pdu.Name,
+		"type":  pdu.Type.String(),
+		"value": pdu.Value,
+	}
+}
+
+// parseOID parses a dotted OID with optional leading dot
+func parseOID(oid string) ([]int, error) {
+	parts := strings.Split(strings.TrimPrefix(oid, "."), ".")
+	parsed := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid OID %q", oid)
+		}
+		parsed[i] = n
+	}
+	return parsed, nil
+}
+
+// compareOID orders OIDs lexicographically by sub-identifier
+func compareOID(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
+
+// hasOIDPrefix reports whether oid lies in the subtree rooted at prefix
+func hasOIDPrefix(oid, prefix []int) bool {
+	return len(oid) >= len(prefix) && compareOID(oid[:len(prefix)], prefix) == 0
+}
+
+// systemDescription returns sysDescr
+func systemDescription() string {
+	release, _ := os.ReadFile("/proc/sys/kernel/osrelease")
+	return fmt.Sprintf("LinHT Web Manager, Linux %s %s", strings.TrimSpace(string(release)), runtime.GOARCH)
+}
+
+// readLoadAverage returns the one minute load average
+func readLoadAverage() (float64, bool) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, false
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	return load, err == nil
+}
+
+// readMemInfo returns total and available memory in KiB
+func readMemInfo() (total, available uint64, ok bool) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total = value
+		case "MemAvailable:":
+			available = value
+		}
+	}
+	return total, available, total > 0
+}
+
+// normalizeSNMPConfig fills in defaults
+func normalizeSNMPConfig(cfg SNMPConfig) SNMPConfig {
+	if cfg.Listen == "" {
+		cfg.Listen = DefaultSNMPListen
+	}
+	if cfg.Community == "" {
+		cfg.Community = DefaultSNMPCommunity
+	}
+	if cfg.BaseOID == "" {
+		cfg.BaseOID = DefaultSNMPBaseOID
+	}
+	if !strings.HasPrefix(cfg.BaseOID, ".") {
+		cfg.BaseOID = "." + cfg.BaseOID
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = DefaultSNMPCacheTTL
+	}
+	if len(cfg.DiskPaths) == 0 {
+		cfg.DiskPaths = []string{"/"}
+	}
+	return cfg
+}
+
+// validateSNMPConfig checks the base OID
+func validateSNMPConfig(cfg SNMPConfig) error {
+	if _, err := parseOID(cfg.BaseOID); err != nil {
+		return fmt.Errorf("invalid snmp base_oid: %w", err)
+	}
+	return nil
+}
+
+// Validate checks the settings after filling in defaults
+func (cfg SNMPConfig) Validate() error {
+	return validateSNMPConfig(normalizeSNMPConfig(cfg))
+}
+
+// Register the plugin
+func init() {
+	Register("snmp", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[SNMPConfig]("snmp", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewSNMPPlugin(cfg)
+	}, "dockerclient")
+}
@@ -0,0 +1,100 @@
+package plugins
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// staticPathPattern restricts mount paths to plain URL segments
+var staticPathPattern = regexp.MustCompile(`^(/[A-Za-z0-9._-]+)+$`)
+
+// StaticMount serves a directory of a site-specific web app below a URL path
+type StaticMount struct {
+	Path      string `yaml:"path"` // URL prefix, e.g. /apps/dashboard
+	Dir       string `yaml:"dir"`
+	Index     string `yaml:"index"` // default index.html
+	SPA       bool   `yaml:"spa"`   // serve the index for paths without a file (client-side routing)
+	RouteAuth `yaml:",inline"`
+}
+
+// normalize fills in defaults
+func (m StaticMount) normalize() StaticMount {
+	m.RouteAuth = m.RouteAuth.normalize()
+	if m.Index == "" {
+		m.Index = "index.html"
+	}
+	return m
+}
+
+// validate checks a mount and its auth settings
+func (m StaticMount) validate() error {
+	m = m.normalize()
+	if !staticPathPattern.MatchString(m.Path) || m.Path == "/api" || strings.HasPrefix(m.Path, "/api/") {
+		return fmt.Errorf("invalid path %q, expected e.g. /apps/dashboard outside /api", m.Path)
+	}
+	if !filepath.IsAbs(m.Dir) {
+		return fmt.Errorf("%s: dir must be an absolute path", m.Path)
+	}
+	if strings.ContainsAny(m.Index, "/\\") {
+		return fmt.Errorf("%s: index must be a file name", m.Path)
+	}
+	if err := m.RouteAuth.validate(); err != nil {
+		return fmt.Errorf("%s: %w", m.Path, err)
+	}
+	return nil
+}
+
+// ValidateStaticMounts checks the server.static mounts
+func ValidateStaticMounts(mounts []StaticMount) error {
+	paths := make(map[string]bool)
+	for i, m := range mounts {
+		if err := m.validate(); err != nil {
+			return fmt.Errorf("server.static[%d]: %w", i, err)
+		}
+		if paths[m.Path] {
+			return fmt.Errorf("server.static[%d]: duplicate path %s", i, m.Path)
+		}
+		paths[m.Path] = true
+	}
+	return nil
+}
+
+// MountStatic serves the configured directories
+// Files and directories starting with a dot are never served.
+func MountStatic(app *fiber.App, mounts []StaticMount) {
+	for _, m := range mounts {
+		m = m.normalize()
+		if info, err := os.Stat(m.Dir); err != nil || !info.IsDir() {
+			slog.Warn("Static directory not found, requests will fail until it exists", "path", m.Path, "dir", m.Dir)
+		}
+
+		app.Use(m.Path, m.RouteAuth.handler(m.Path))
+		app.Static(m.Path, m.Dir, fiber.Static{
+			Index:     m.Index,
+			ByteRange: true,
+			Next:      hiddenStaticPath,
+		})
+		if m.SPA {
+			index := filepath.Join(m.Dir, m.Index)
+			app.Get(m.Path+"/*", func(c *fiber.Ctx) error {
+				if hiddenStaticPath(c) {
+					return c.Next()
+				}
+				return c.SendFile(index)
+			})
+		}
+
+		slog.Info("Static directory mounted", "path", m.Path, "dir", m.Dir, "auth", m.Auth, "spa", m.SPA)
+	}
+}
+
+// hiddenStaticPath reports whether a request names a dot file or directory
+func hiddenStaticPath(c *fiber.Ctx) bool {
+	return strings.Contains(c.Path(), "/.")
+}
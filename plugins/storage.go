@@ -0,0 +1,473 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Storage constants
+const (
+	DefaultStorageMountRoot    = "/media"
+	DefaultStorageMountOptions = "noatime,nodev,nosuid"
+	storageCommandTimeout      = 30 * time.Second
+)
+
+// storageNamePattern restricts mountpoint names below the mount root
+var storageNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// StorageConfig holds storage plugin configuration
+type StorageConfig struct {
+	MountRoot    string `yaml:"mount_root"`    // removable media are mounted below this directory
+	MountOptions string `yaml:"mount_options"` // default mount options
+	AllowFixed   bool   `yaml:"allow_fixed"`   // allow mounting non-removable devices
+}
+
+// BlockDevice represents a disk or partition reported by lsblk
+type BlockDevice struct {
+	Name       string        `json:"name"`
+	Path       string        `json:"path"`
+	Size       int64         `json:"size"`
+	Type       string        `json:"type"`
+	FSType     string        `json:"fstype"`
+	Label      string        `json:"label"`
+	UUID       string        `json:"uuid"`
+	Mountpoint string        `json:"mountpoint"`
+	Removable  bool          `json:"removable"`
+	ReadOnly   bool          `json:"read_only"`
+	Model      string        `json:"model"`
+	Transport  string        `json:"transport"`
+	Managed    bool          `json:"managed"` // mounted below the mount root by this plugin
+	Children   []BlockDevice `json:"children,omitempty"`
+}
+
+// StorageMount is removable media mounted by the storage plugin
+type StorageMount struct {
+	Name       string `json:"name"`
+	Device     string `json:"device"`
+	Label      string `json:"label"`
+	Mountpoint string `json:"mountpoint"`
+}
+
+// Mounted media shared with the file manager
+var (
+	storageMounts   []StorageMount
+	storageMountsMu sync.RWMutex
+)
+
+// StorageMounts returns the removable media currently mounted by the storage plugin
+func StorageMounts() []StorageMount {
+	storageMountsMu.RLock()
+	defer storageMountsMu.RUnlock()
+	return append([]StorageMount{}, storageMounts...)
+}
+
+// StoragePlugin lists block devices and mounts removable media
+type StoragePlugin struct {
+	config StorageConfig
+	mu     sync.RWMutex
+	// Serializes mount and unmount operations
+	opMu sync.Mutex
+}
+
+// NewStoragePlugin creates a new storage plugin instance
+func NewStoragePlugin(cfg StorageConfig) (*StoragePlugin, error) {
+	cfg = normalizeStorageConfig(cfg)
+	if err := validateStorageConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	p := &StoragePlugin{config: cfg}
+
+	// Pick up media mounted before a restart
+	if _, err := p.listDevices(context.Background()); err != nil {
+		slog.Warn("Failed to list block devices", "error", err)
+	}
+
+	return p, nil
+}
+
+// Name returns the plugin identifier
+func (p *StoragePlugin) Name() string {
+	return "storage"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *StoragePlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/storage")
+
+	api.Get("/devices", p.handleListDevices)
+	api.Post("/mount", p.handleMount)
+	api.Post("/unmount", p.handleUnmount)
+}
+
+// Shutdown performs cleanup
+// Media stay mounted so captures in progress are not interrupted
+func (p *StoragePlugin) Shutdown() error {
+	return nil
+}
+
+// Reload applies a new mount root and options at runtime
+func (p *StoragePlugin) Reload(config interface{}) error {
+	cfg, err := configAs[StorageConfig]("storage", config)
+	if err != nil {
+		return err
+	}
+	cfg = normalizeStorageConfig(cfg)
+	if err := validateStorageConfig(cfg); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.config = cfg
+	p.mu.Unlock()
+
+	slog.Info("Storage config reloaded",
+		"mount_root", cfg.MountRoot,
+		"mount_options", cfg.MountOptions,
+		"allow_fixed", cfg.AllowFixed)
+	return nil
+}
+
+// getConfig returns the current configuration
+func (p *StoragePlugin) getConfig() StorageConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config
+}
+
+// lsblkDevice mirrors the lsblk JSON output
+// Older lsblk versions report flags as "0"/"1" strings
+type lsblkDevice struct {
+	Name       string        `json:"name"`
+	Path       string        `json:"path"`
+	Size       int64         `json:"size"`
+	Type       string        `json:"type"`
+	FSType     string        `json:"fstype"`
+	Label      string        `json:"label"`
+	UUID       string        `json:"uuid"`
+	Mountpoint string        `json:"mountpoint"`
+	RM         lsblkFlag     `json:"rm"`
+	Hotplug    lsblkFlag     `json:"hotplug"`
+	RO         lsblkFlag     `json:"ro"`
+	Model      string        `json:"model"`
+	Tran       string        `json:"tran"`
+	Children   []lsblkDevice `json:"children"`
+}
+
+// lsblkFlag accepts both JSON booleans and "0"/"1" strings
+type lsblkFlag bool
+
+// UnmarshalJSON implements json.Unmarshaler
+func (f *lsblkFlag) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	*f = lsblkFlag(value == "true" || value == "1")
+	return nil
+}
+
+// listDevices runs lsblk and refreshes the shared list of managed mounts
+func (p *StoragePlugin) listDevices(ctx context.Context) ([]BlockDevice, error) {
+	ctx, cancel := context.WithTimeout(ctx, storageCommandTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "lsblk", "-J", "-b",
+		"-o", "NAME,PATH,SIZE,TYPE,FSTYPE,LABEL,UUID,MOUNTPOINT,RM,HOTPLUG,RO,MODEL,TRAN").Output()
+	if err != nil {
+		return nil, fmt.Errorf("lsblk failed: %w", err)
+	}
+
+	var parsed struct {
+		BlockDevices []lsblkDevice `json:"blockdevices"`
+	}
+	if err := json.Unmarshal(output, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse lsblk output: %w", err)
+	}
+
+	mountRoot := p.getConfig().MountRoot
+	var mounts []StorageMount
+	devices := make([]BlockDevice, 0, len(parsed.BlockDevices))
+	for _, raw := range parsed.BlockDevices {
+		// Compressed swap and loop devices are never user media
+		if raw.Type == "loop" || strings.HasPrefix(raw.Name, "zram") || strings.HasPrefix(raw.Name, "ram") {
+			continue
+		}
+		devices = append(devices, convertBlockDevice(raw, false, mountRoot, &mounts))
+	}
+
+	storageMountsMu.Lock()
+	storageMounts = mounts
+	storageMountsMu.Unlock()
+
+	return devices, nil
+}
+
+// convertBlockDevice converts lsblk output, inheriting removability from the parent disk
+func convertBlockDevice(raw lsblkDevice, parentRemovable bool, mountRoot string, mounts *[]StorageMount) BlockDevice {
+	device := BlockDevice{
+		Name:       raw.Name,
+		Path:       raw.Path,
+		Size:       raw.Size,
+		Type:       raw.Type,
+		FSType:     raw.FSType,
+		Label:      raw.Label,
+		UUID:       raw.UUID,
+		Mountpoint: raw.Mountpoint,
+		Removable:  parentRemovable || bool(raw.RM) || bool(raw.Hotplug),
+		ReadOnly:   bool(raw.RO),
+		Model:      strings.TrimSpace(raw.Model),
+		Transport:  raw.Tran,
+	}
+	if device.Path == "" {
+		device.Path = "/dev/" + raw.Name
+	}
+
+	if device.Mountpoint != "" && filepath.Dir(device.Mountpoint) == mountRoot {
+		device.Managed = true
+		*mounts = append(*mounts, StorageMount{
+			Name:       filepath.Base(device.Mountpoint),
+			Device:     device.Path,
+			Label:      device.Label,
+			Mountpoint: device.Mountpoint,
+		})
+	}
+
+	for _, child := range raw.Children {
+		device.Children = append(device.Children, convertBlockDevice(child, device.Removable, mountRoot, mounts))
+	}
+	return device
+}
+
+// findBlockDevice searches the device tree by path or name
+func findBlockDevice(devices []BlockDevice, device string) (BlockDevice, bool) {
+	for _, d := range devices {
+		if d.Path == device || d.Name == device {
+			return d, true
+		}
+		if found, ok := findBlockDevice(d.Children, device); ok {
+			return found, true
+		}
+	}
+	return BlockDevice{}, false
+}
+
+// runStorageCommand runs mount or umount and includes its output in errors
+func runStorageCommand(ctx context.Context, name string, args ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, storageCommandTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("%s failed: %s", name, msg)
+		}
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	return nil
+}
+
+// handleListDevices handles GET /api/storage/devices?removable=true
+func (p *StoragePlugin) handleListDevices(c *fiber.Ctx) error {
+	devices, err := p.listDevices(c.UserContext())
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+
+	if c.QueryBool("removable") {
+		removable := make([]BlockDevice, 0, len(devices))
+		for _, device := range devices {
+			if device.Removable {
+				removable = append(removable, device)
+			}
+		}
+		devices = removable
+	}
+
+	return SendSuccess(c, fiber.Map{
+		"mount_root": p.getConfig().MountRoot,
+		"devices":    devices,
+		"mounts":     StorageMounts(),
+	}, "")
+}
+
+// handleMount handles POST /api/storage/mount
+// Mounts a partition at <mount_root>/<name>; name defaults to the filesystem label or device name
+func (p *StoragePlugin) handleMount(c *fiber.Ctx) error {
+	var req struct {
+		Device   string `json:"device"`
+		Name     string `json:"name"`
+		Options  string `json:"options"`
+		ReadOnly bool   `json:"read_only"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	if req.Device == "" {
+		return SendErrorMessage(c, 400, "Device required")
+	}
+
+	p.opMu.Lock()
+	defer p.opMu.Unlock()
+
+	ctx := c.UserContext()
+	cfg := p.getConfig()
+
+	devices, err := p.listDevices(ctx)
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	device, ok := findBlockDevice(devices, req.Device)
+	if !ok {
+		return SendErrorMessage(c, 404, "Device not found")
+	}
+	if !device.Removable && !cfg.AllowFixed {
+		return SendErrorMessage(c, 403, "Only removable devices can be mounted")
+	}
+	if device.FSType == "" {
+		return SendErrorMessage(c, 400, "Device has no recognized filesystem")
+	}
+	if device.Mountpoint != "" {
+		return SendErrorMessage(c, 409, fmt.Sprintf("Device is already mounted at %s", device.Mountpoint))
+	}
+
+	name := req.Name
+	if name == "" {
+		name = device.Label
+	}
+	if name == "" {
+		name = device.Name
+	}
+	name = strings.ReplaceAll(name, " ", "_")
+	if !storageNamePattern.MatchString(name) || name == "." || name == ".." {
+		return SendErrorMessage(c, 400, "Invalid mount name")
+	}
+
+	mountpoint := filepath.Join(cfg.MountRoot, name)
+	if entries, err := os.ReadDir(mountpoint); err == nil && len(entries) > 0 {
+		return SendErrorMessage(c, 409, fmt.Sprintf("%s is not empty", mountpoint))
+	}
+	if err := os.MkdirAll(mountpoint, 0755); err != nil {
+		return SendError(c, 500, err)
+	}
+
+	options := req.Options
+	if options == "" {
+		options = cfg.MountOptions
+	}
+	if req.ReadOnly {
+		options += ",ro"
+	}
+
+	slog.InfoContext(ctx, "Mounting storage device",
+		"device", device.Path,
+		"fstype", device.FSType,
+		"mountpoint", mountpoint,
+		"options", options)
+
+	if err := runStorageCommand(ctx, "mount", "-o", options, device.Path, mountpoint); err != nil {
+		os.Remove(mountpoint)
+		slog.ErrorContext(ctx, "Failed to mount storage device", "device", device.Path, "error", err)
+		return SendError(c, 500, err)
+	}
+
+	p.listDevices(ctx)
+	mount := StorageMount{Name: name, Device: device.Path, Label: device.Label, Mountpoint: mountpoint}
+	PublishEvent("storage.mounted", "storage", mount)
+
+	return SendSuccess(c, mount, "Device mounted")
+}
+
+// handleUnmount handles POST /api/storage/unmount
+// Accepts a device or mountpoint; only media mounted below the mount root can be unmounted
+func (p *StoragePlugin) handleUnmount(c *fiber.Ctx) error {
+	var req struct {
+		Device     string `json:"device"`
+		Mountpoint string `json:"mountpoint"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	if req.Device == "" && req.Mountpoint == "" {
+		return SendErrorMessage(c, 400, "Device or mountpoint required")
+	}
+
+	p.opMu.Lock()
+	defer p.opMu.Unlock()
+
+	ctx := c.UserContext()
+	if _, err := p.listDevices(ctx); err != nil {
+		return SendError(c, 500, err)
+	}
+
+	var mount *StorageMount
+	for _, m := range StorageMounts() {
+		if (req.Device != "" && (m.Device == req.Device || filepath.Base(m.Device) == req.Device)) ||
+			(req.Mountpoint != "" && m.Mountpoint == filepath.Clean(req.Mountpoint)) {
+			m := m
+			mount = &m
+			break
+		}
+	}
+	if mount == nil {
+		return SendErrorMessage(c, 404, "No managed mount found for that device")
+	}
+
+	slog.InfoContext(ctx, "Unmounting storage device", "device", mount.Device, "mountpoint", mount.Mountpoint)
+
+	// umount flushes pending writes, so the media is safe to remove afterwards
+	if err := runStorageCommand(ctx, "umount", mount.Mountpoint); err != nil {
+		slog.ErrorContext(ctx, "Failed to unmount storage device", "device", mount.Device, "error", err)
+		return SendError(c, 500, err)
+	}
+	os.Remove(mount.Mountpoint)
+
+	p.listDevices(ctx)
+	PublishEvent("storage.unmounted", "storage", mount)
+
+	return SendSuccess(c, mount, "Device unmounted, it is safe to remove")
+}
+
+// normalizeStorageConfig fills in defaults
+func normalizeStorageConfig(cfg StorageConfig) StorageConfig {
+	if cfg.MountRoot == "" {
+		cfg.MountRoot = DefaultStorageMountRoot
+	}
+	cfg.MountRoot = filepath.Clean(cfg.MountRoot)
+	if cfg.MountOptions == "" {
+		cfg.MountOptions = DefaultStorageMountOptions
+	}
+	return cfg
+}
+
+// validateStorageConfig checks the mount root
+func validateStorageConfig(cfg StorageConfig) error {
+	if !filepath.IsAbs(cfg.MountRoot) {
+		return fmt.Errorf("storage mount_root must be an absolute path")
+	}
+	return nil
+}
+
+// Validate checks the settings after filling in defaults
+func (cfg StorageConfig) Validate() error {
+	return validateStorageConfig(normalizeStorageConfig(cfg))
+}
+
+// Register the plugin
+func init() {
+	Register("storage", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[StorageConfig]("storage", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewStoragePlugin(cfg)
+	})
+}
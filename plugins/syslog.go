@@ -0,0 +1,422 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Syslog forwarding constants
+const (
+	syslogQueueSize    = 1024 // messages waiting to be sent; further messages are dropped
+	syslogDialTimeout  = 5 * time.Second
+	syslogWriteTimeout = 5 * time.Second
+	syslogRetryDelay   = 10 * time.Second // between connection attempts while the collector is down
+	syslogFlushTimeout = 2 * time.Second  // to send queued messages on close
+	syslogMaxDatagram  = 8192             // bytes of a UDP message, longer ones are cut
+	syslogFacilityLog  = 13               // log audit
+)
+
+// syslogFacilities maps facility names to codes
+var syslogFacilities = map[string]int{
+	"user": 1, "daemon": 3, "auth": 4, "authpriv": 10,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// SyslogConfig describes forwarding of the manager log to a syslog collector
+type SyslogConfig struct {
+	Address  string `yaml:"address"`  // host[:port] of the collector, empty disables forwarding
+	Network  string `yaml:"network"`  // udp (default), tcp or tls
+	Level    string `yaml:"level"`    // lowest forwarded level, default info
+	Facility string `yaml:"facility"` // default local0
+	AppName  string `yaml:"app_name"` // default linht-web
+	Hostname string `yaml:"hostname"` // default: the system host name
+	Audit    bool   `yaml:"audit"`    // also send a record for every API request that can change something
+	CAFile   string `yaml:"ca_file"`  // tls: CA of the collector certificate (default: system roots)
+}
+
+// normalizeSyslogConfig fills in defaults
+func normalizeSyslogConfig(cfg SyslogConfig) SyslogConfig {
+	if cfg.Network == "" {
+		cfg.Network = "udp"
+	}
+	if cfg.Level == "" {
+		cfg.Level = "info"
+	}
+	if cfg.Facility == "" {
+		cfg.Facility = "local0"
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = "linht-web"
+	}
+	return cfg
+}
+
+// Validate checks the syslog settings
+func (cfg SyslogConfig) Validate() error {
+	if cfg.Address == "" {
+		return nil
+	}
+	cfg = normalizeSyslogConfig(cfg)
+	switch cfg.Network {
+	case "udp", "tcp", "tls":
+	default:
+		return fmt.Errorf("logging.syslog.network must be udp, tcp or tls, got %q", cfg.Network)
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return fmt.Errorf("invalid logging.syslog.level: %w", err)
+	}
+	if _, ok := syslogFacilities[cfg.Facility]; !ok {
+		return fmt.Errorf("logging.syslog.facility %q is unknown (use user, daemon, auth, authpriv or local0-7)", cfg.Facility)
+	}
+	if cfg.CAFile != "" && cfg.Network != "tls" {
+		return fmt.Errorf("logging.syslog.ca_file needs network tls")
+	}
+	return nil
+}
+
+// SyslogClient sends RFC 5424 messages to a collector
+// Messages are queued and sent in the background so logging never waits for
+// the network; while the collector is unreachable they are dropped and counted.
+type SyslogClient struct {
+	network  string
+	address  string
+	tls      *tls.Config
+	level    slog.Level
+	facility int
+	appName  string
+	hostname string
+	procID   string
+	audit    bool
+
+	queue   chan []byte
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Int64
+}
+
+// NewSyslogClient creates a client and starts sending
+func NewSyslogClient(cfg SyslogConfig) (*SyslogClient, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg = normalizeSyslogConfig(cfg)
+
+	s := &SyslogClient{
+		network:  cfg.Network,
+		facility: syslogFacilities[cfg.Facility],
+		appName:  syslogField(cfg.AppName, 48),
+		hostname: cfg.Hostname,
+		procID:   strconv.Itoa(os.Getpid()),
+		audit:    cfg.Audit,
+		queue:    make(chan []byte, syslogQueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err := s.level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, err
+	}
+	if s.hostname == "" {
+		s.hostname, _ = os.Hostname()
+	}
+	s.hostname = syslogField(s.hostname, 255)
+
+	s.address = cfg.Address
+	if _, _, err := net.SplitHostPort(s.address); err != nil {
+		port := "514"
+		if s.network == "tls" {
+			port = "6514"
+		}
+		s.address = net.JoinHostPort(s.address, port)
+	}
+	if s.network == "tls" {
+		host, _, _ := net.SplitHostPort(s.address)
+		s.tls = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+		if cfg.CAFile != "" {
+			pem, err := os.ReadFile(cfg.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("logging.syslog.ca_file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("logging.syslog.ca_file: no certificates in %s", cfg.CAFile)
+			}
+			s.tls.RootCAs = pool
+		}
+	}
+
+	go s.run()
+	return s, nil
+}
+
+// Close sends the queued messages, waiting at most syslogFlushTimeout
+func (s *SyslogClient) Close() error {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+	select {
+	case <-s.done:
+	case <-time.After(syslogFlushTimeout):
+	}
+	return nil
+}
+
+// Dropped returns the number of messages that could not be sent
+func (s *SyslogClient) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// send queues a message with the given facility, severity and message ID
+func (s *SyslogClient) send(facility, severity int, t time.Time, msgID, msg string) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s %s - %s",
+		facility*8+severity, t.Format("2006-01-02T15:04:05.000000Z07:00"),
+		s.hostname, s.appName, s.procID, msgID, msg)
+	select {
+	case s.queue <- b.Bytes():
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// run sends queued messages, reconnecting after errors
+func (s *SyslogClient) run() {
+	defer close(s.done)
+
+	var conn net.Conn
+	var retryAt time.Time
+	var failing bool
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	deliver := func(msg []byte) {
+		// One reconnect per message: a collector restart loses nothing
+		for attempt := 0; attempt < 2; attempt++ {
+			if conn != nil && !s.alive(conn) {
+				conn.Close()
+				conn = nil
+			}
+			if conn == nil {
+				if time.Now().Before(retryAt) {
+					break
+				}
+				var err error
+				if conn, err = s.dial(); err != nil {
+					retryAt = time.Now().Add(syslogRetryDelay)
+					if !failing {
+						// Not through slog, which would feed the messages back here
+						fmt.Fprintf(os.Stderr, "syslog: cannot reach %s: %v\n", s.address, err)
+						failing = true
+					}
+					break
+				}
+			}
+			conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+			if _, err := conn.Write(s.frame(msg)); err == nil {
+				failing = false
+				return
+			}
+			conn.Close()
+			conn = nil
+		}
+		s.dropped.Add(1)
+	}
+
+	for {
+		select {
+		case msg := <-s.queue:
+			deliver(msg)
+		case <-s.stop:
+			for {
+				select {
+				case msg := <-s.queue:
+					deliver(msg)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// dial connects to the collector
+func (s *SyslogClient) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: syslogDialTimeout}
+	if s.network == "tls" {
+		return tls.DialWithDialer(dialer, "tcp", s.address, s.tls)
+	}
+	return dialer.Dial(s.network, s.address)
+}
+
+// alive reports whether the collector kept a stream connection open
+// A write to a connection the collector has closed still succeeds once, so
+// that message would be lost without this check.
+func (s *SyslogClient) alive(conn net.Conn) bool {
+	if s.network == "udp" {
+		return true
+	}
+	var b [1]byte
+	conn.SetReadDeadline(time.Now())
+	_, err := conn.Read(b[:])
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// frame returns a message as sent over the connection: one datagram over UDP,
+// octet counting over TCP and TLS (RFC 6587, RFC 5425)
+func (s *SyslogClient) frame(msg []byte) []byte {
+	if s.network == "udp" {
+		if len(msg) > syslogMaxDatagram {
+			msg = msg[:syslogMaxDatagram]
+		}
+		return msg
+	}
+	return append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+}
+
+// syslogField makes a header field printable ASCII without spaces
+func syslogField(value string, max int) string {
+	value = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, value)
+	if value == "" {
+		return "-"
+	}
+	if len(value) > max {
+		value = value[:max]
+	}
+	return value
+}
+
+// syslogSeverity maps a slog level to a syslog severity
+func syslogSeverity(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3
+	case level >= slog.LevelWarn:
+		return 4
+	case level >= slog.LevelInfo:
+		return 6
+	}
+	return 7
+}
+
+// Handler returns a slog handler that forwards records at or above the configured level
+// The message is followed by the attributes in key=value form.
+func (s *SyslogClient) Handler() slog.Handler {
+	out := &syslogOutput{client: s}
+	inner := slog.NewTextHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: syslogAttrsOnly})
+	return &syslogHandler{inner: inner, out: out}
+}
+
+// syslogAttrsOnly drops the time, level and message, which go into the syslog header
+func syslogAttrsOnly(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey || a.Key == slog.MessageKey) {
+		return slog.Attr{}
+	}
+	return a
+}
+
+// syslogOutput receives the attributes of one record from the text handler
+type syslogOutput struct {
+	client *SyslogClient
+	mu     sync.Mutex
+	record slog.Record // record being formatted, guarded by mu
+}
+
+func (o *syslogOutput) Write(b []byte) (int, error) {
+	msg := o.record.Message
+	if attrs := strings.TrimSpace(string(b)); attrs != "" {
+		msg += " " + attrs
+	}
+	o.client.send(o.client.facility, syslogSeverity(o.record.Level), o.record.Time, "-", msg)
+	return len(b), nil
+}
+
+// syslogHandler formats records for a SyslogClient
+type syslogHandler struct {
+	inner slog.Handler
+	out   *syslogOutput
+}
+
+func (h *syslogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.out.client.level
+}
+
+func (h *syslogHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+	h.out.record = r
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &syslogHandler{inner: h.inner.WithAttrs(attrs), out: h.out}
+}
+
+func (h *syslogHandler) WithGroup(name string) slog.Handler {
+	return &syslogHandler{inner: h.inner.WithGroup(name), out: h.out}
+}
+
+// AuditMiddleware sends a record with message ID "audit" and the log audit
+// facility for every API request other than GET, HEAD and OPTIONS
+// It is a no-op unless logging.syslog.audit is set.
+func (s *SyslogClient) AuditMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !s.audit || !strings.HasPrefix(c.Path(), APIPath("")) {
+			return c.Next()
+		}
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			return c.Next()
+		}
+
+		start := time.Now()
+		err := c.Next()
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+		}
+
+		var b bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&b, &slog.HandlerOptions{ReplaceAttr: syslogAttrsOnly}))
+		logger.Info("",
+			"status", status,
+			"remote", c.IP(),
+			"duration", time.Since(start).Round(time.Millisecond),
+			"request_id", RequestIDFromContext(c.UserContext()))
+
+		severity := 6
+		if status >= 400 {
+			severity = 5 // notice: refused or failed change
+		}
+		s.send(syslogFacilityLog, severity, start, "audit", c.Method()+" "+c.Path()+" "+strings.TrimSpace(b.String()))
+		return err
+	}
+}
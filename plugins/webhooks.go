@@ -0,0 +1,513 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Webhook defaults
+const (
+	DefaultWebhookRetryDelay = 10 // seconds, doubled after each attempt
+	DefaultWebhookTimeout    = 10 // seconds per attempt
+	DefaultWebhookHistory    = 200
+	webhookQueueSize         = 256
+	webhookWorkers           = 2
+	webhookTestEvent         = "webhook.test"
+	webhookSignatureHeader   = "X-Linht-Signature-256"
+)
+
+// Webhook delivery states
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookConfig describes a single outbound webhook
+type WebhookConfig struct {
+	Name   string   `yaml:"name" json:"name"`
+	URL    string   `yaml:"url" json:"url"`
+	Secret string   `yaml:"secret" json:"-"`      // HMAC-SHA256 key for the signature header
+	Events []string `yaml:"events" json:"events"` // event type prefixes, empty = all
+}
+
+// WebhooksConfig holds webhook plugin configuration
+type WebhooksConfig struct {
+	Retries    int             `yaml:"retries"`
+	RetryDelay int             `yaml:"retry_delay"`
+	Timeout    int             `yaml:"timeout"`
+	History    int             `yaml:"history"`
+	Hooks      []WebhookConfig `yaml:"hooks"`
+}
+
+// WebhookDelivery records the delivery of one event to one webhook
+type WebhookDelivery struct {
+	ID           string     `json:"id"`
+	Webhook      string     `json:"webhook"`
+	URL          string     `json:"url"`
+	EventID      uint64     `json:"event_id"`
+	EventType    string     `json:"event_type"`
+	Status       string     `json:"status"`
+	Attempts     int        `json:"attempts"`
+	ResponseCode int        `json:"response_code,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	DeliveredAt  *time.Time `json:"delivered_at,omitempty"`
+
+	event Event
+}
+
+// WebhooksPlugin posts matching bus events to configured URLs
+type WebhooksPlugin struct {
+	config   WebhooksConfig
+	client   *http.Client
+	queue    chan *WebhookDelivery
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+
+	// Subscribed when the plugin is created, so events published before Start are delivered
+	events      <-chan Event
+	unsubscribe func()
+
+	mu         sync.RWMutex
+	deliveries []*WebhookDelivery
+}
+
+// NewWebhooksPlugin creates a new webhooks plugin instance and subscribes to the event bus
+func NewWebhooksPlugin(cfg WebhooksConfig) (*WebhooksPlugin, error) {
+	cfg = normalizeWebhooksConfig(cfg)
+	if err := validateWebhooksConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	events, unsubscribe := Events.Subscribe()
+	return &WebhooksPlugin{
+		config:      cfg,
+		client:      &http.Client{},
+		queue:       make(chan *WebhookDelivery, webhookQueueSize),
+		stopChan:    make(chan struct{}),
+		events:      events,
+		unsubscribe: unsubscribe,
+	}, nil
+}
+
+// Start launches the dispatcher and the delivery workers
+func (p *WebhooksPlugin) Start() error {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.dispatch(p.events)
+	}()
+	for i := 0; i < webhookWorkers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.work()
+		}()
+	}
+
+	slog.Info("Webhooks started", "hooks", len(p.getConfig().Hooks))
+	return nil
+}
+
+// Name returns the plugin identifier
+func (p *WebhooksPlugin) Name() string {
+	return "webhooks"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *WebhooksPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/webhooks")
+
+	api.Get("/", p.handleList)
+	api.Post("/:name/test", p.handleTest)
+	api.Get("/deliveries", p.handleListDeliveries)
+	api.Get("/deliveries/:id", p.handleGetDelivery)
+	api.Post("/deliveries/:id/redeliver", p.handleRedeliver)
+}
+
+// Shutdown stops dispatching; pending retries are abandoned
+func (p *WebhooksPlugin) Shutdown() error {
+	close(p.stopChan)
+	p.wg.Wait()
+	p.unsubscribe()
+	return nil
+}
+
+// Reload applies new hooks and retry settings; queued deliveries keep their target
+func (p *WebhooksPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[WebhooksConfig]("webhooks", config)
+	if err != nil {
+		return err
+	}
+	cfg = normalizeWebhooksConfig(cfg)
+	if err := validateWebhooksConfig(cfg); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.config = cfg
+	p.mu.Unlock()
+
+	slog.Info("Webhooks config reloaded", "hooks", len(cfg.Hooks), "retries", cfg.Retries)
+	return nil
+}
+
+// getConfig returns the current configuration
+func (p *WebhooksPlugin) getConfig() WebhooksConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config
+}
+
+// findHook returns the configured webhook with the given name
+func (p *WebhooksPlugin) findHook(name string) (WebhookConfig, bool) {
+	for _, hook := range p.getConfig().Hooks {
+		if hook.Name == name {
+			return hook, true
+		}
+	}
+	return WebhookConfig{}, false
+}
+
+// dispatch queues a delivery for every webhook matching each bus event
+func (p *WebhooksPlugin) dispatch(events <-chan Event) {
+	for {
+		select {
+		case <-p.stopChan:
+			return
+		case event := <-events:
+			for _, hook := range p.getConfig().Hooks {
+				if matchesEventFilter(event.Type, hook.Events) {
+					p.enqueue(hook, event)
+				}
+			}
+		}
+	}
+}
+
+// enqueue records a delivery and hands it to the workers
+// When the queue is full the delivery is marked failed instead of blocking the bus
+func (p *WebhooksPlugin) enqueue(hook WebhookConfig, event Event) *WebhookDelivery {
+	delivery := &WebhookDelivery{
+		ID:        uuid.New().String(),
+		Webhook:   hook.Name,
+		URL:       hook.URL,
+		EventID:   event.ID,
+		EventType: event.Type,
+		Status:    DeliveryPending,
+		CreatedAt: time.Now(),
+		event:     event,
+	}
+	p.record(delivery)
+
+	select {
+	case p.queue <- delivery:
+	default:
+		p.update(delivery, func(d *WebhookDelivery) {
+			d.Status = DeliveryFailed
+			d.Error = "delivery queue full"
+		})
+		slog.Warn("Webhook queue full, dropping delivery", "webhook", hook.Name, "event", event.Type)
+	}
+	return delivery
+}
+
+// record appends a delivery to the log, dropping the oldest entries beyond the history size
+func (p *WebhooksPlugin) record(delivery *WebhookDelivery) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.deliveries = append(p.deliveries, delivery)
+	if excess := len(p.deliveries) - p.config.History; excess > 0 {
+		p.deliveries = append([]*WebhookDelivery(nil), p.deliveries[excess:]...)
+	}
+}
+
+// update modifies a delivery under the lock
+func (p *WebhooksPlugin) update(delivery *WebhookDelivery, fn func(*WebhookDelivery)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fn(delivery)
+}
+
+// getDelivery returns a copy of a logged delivery
+func (p *WebhooksPlugin) getDelivery(id string) (*WebhookDelivery, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, delivery := range p.deliveries {
+		if delivery.ID == id {
+			copied := *delivery
+			return &copied, true
+		}
+	}
+	return nil, false
+}
+
+// work sends queued deliveries until stopped
+func (p *WebhooksPlugin) work() {
+	for {
+		select {
+		case <-p.stopChan:
+			return
+		case delivery := <-p.queue:
+			p.deliver(delivery)
+		}
+	}
+}
+
+// deliver makes one attempt and schedules a retry after network errors, 429 and 5xx responses
+// Retries back off exponentially without occupying a worker
+func (p *WebhooksPlugin) deliver(delivery *WebhookDelivery) {
+	cfg := p.getConfig()
+	hook, ok := p.findHook(delivery.Webhook)
+	if !ok {
+		p.update(delivery, func(d *WebhookDelivery) {
+			d.Status = DeliveryFailed
+			d.Error = "webhook no longer configured"
+		})
+		return
+	}
+
+	body, err := json.Marshal(delivery.event)
+	if err != nil {
+		p.update(delivery, func(d *WebhookDelivery) {
+			d.Status = DeliveryFailed
+			d.Error = err.Error()
+		})
+		return
+	}
+
+	code, err := p.post(hook, delivery, body, time.Duration(cfg.Timeout)*time.Second)
+	delivered := err == nil && code >= 200 && code <= 299
+	retry := !delivered && (err != nil || code == http.StatusTooManyRequests || code >= 500)
+
+	var attempt int
+	p.update(delivery, func(d *WebhookDelivery) {
+		d.Attempts++
+		attempt = d.Attempts
+		d.ResponseCode = code
+		d.Error = ""
+		switch {
+		case delivered:
+			now := time.Now()
+			d.Status = DeliveryDelivered
+			d.DeliveredAt = &now
+		case err != nil:
+			d.Error = err.Error()
+		default:
+			d.Error = http.StatusText(code)
+		}
+		if !delivered && (!retry || d.Attempts > cfg.Retries) {
+			d.Status = DeliveryFailed
+		}
+	})
+
+	switch {
+	case delivered:
+		slog.Debug("Webhook delivered", "webhook", hook.Name, "event", delivery.EventType, "attempts", attempt)
+	case retry && attempt <= cfg.Retries:
+		delay := time.Duration(cfg.RetryDelay) * time.Second << (attempt - 1)
+		time.AfterFunc(delay, func() {
+			select {
+			case p.queue <- delivery:
+			case <-p.stopChan:
+			}
+		})
+	default:
+		slog.Warn("Webhook delivery failed", "webhook", hook.Name, "event", delivery.EventType, "url", hook.URL, "attempts", attempt)
+	}
+}
+
+// post sends a single attempt and returns the response status code
+// The body is signed with HMAC-SHA256 of the webhook secret when one is configured
+func (p *WebhooksPlugin) post(hook WebhookConfig, delivery *WebhookDelivery, body []byte, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "linht-web-manager")
+	req.Header.Set("X-Linht-Event", delivery.EventType)
+	req.Header.Set("X-Linht-Delivery", delivery.ID)
+	if hook.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(hook.Secret))
+		mac.Write(body)
+		req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	return resp.StatusCode, nil
+}
+
+// handleList handles GET /api/webhooks
+// Secrets are never returned
+func (p *WebhooksPlugin) handleList(c *fiber.Ctx) error {
+	cfg := p.getConfig()
+	hooks := make([]fiber.Map, len(cfg.Hooks))
+	for i, hook := range cfg.Hooks {
+		events := hook.Events
+		if events == nil {
+			events = []string{}
+		}
+		hooks[i] = fiber.Map{
+			"name":       hook.Name,
+			"url":        hook.URL,
+			"events":     events,
+			"has_secret": hook.Secret != "",
+		}
+	}
+	return SendSuccess(c, fiber.Map{
+		"hooks":       hooks,
+		"retries":     cfg.Retries,
+		"retry_delay": cfg.RetryDelay,
+		"timeout":     cfg.Timeout,
+	}, "")
+}
+
+// handleTest handles POST /api/webhooks/:name/test
+// Queues a webhook.test event for a single webhook regardless of its filters
+func (p *WebhooksPlugin) handleTest(c *fiber.Ctx) error {
+	hook, ok := p.findHook(c.Params("name"))
+	if !ok {
+		return SendErrorMessage(c, 404, "Webhook not found")
+	}
+
+	queued := p.enqueue(hook, Event{
+		Type:   webhookTestEvent,
+		Source: "webhooks",
+		Time:   time.Now(),
+		Data:   fiber.Map{"webhook": hook.Name},
+	})
+	delivery, _ := p.getDelivery(queued.ID)
+	return SendSuccess(c, delivery, "Test delivery queued")
+}
+
+// handleListDeliveries handles GET /api/webhooks/deliveries?webhook=&status=&limit=
+// Returns the delivery log, newest first
+func (p *WebhooksPlugin) handleListDeliveries(c *fiber.Ctx) error {
+	webhook := c.Query("webhook")
+	status := c.Query("status")
+	limit := c.QueryInt("limit", 50)
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := []WebhookDelivery{}
+	for i := len(p.deliveries) - 1; i >= 0 && len(result) < limit; i-- {
+		delivery := p.deliveries[i]
+		if webhook != "" && delivery.Webhook != webhook {
+			continue
+		}
+		if status != "" && delivery.Status != status {
+			continue
+		}
+		result = append(result, *delivery)
+	}
+	return SendSuccess(c, result, "")
+}
+
+// handleGetDelivery handles GET /api/webhooks/deliveries/:id
+// Includes the event payload that was sent
+func (p *WebhooksPlugin) handleGetDelivery(c *fiber.Ctx) error {
+	delivery, ok := p.getDelivery(c.Params("id"))
+	if !ok {
+		return SendErrorMessage(c, 404, "Delivery not found")
+	}
+	return SendSuccess(c, fiber.Map{
+		"delivery": delivery,
+		"event":    delivery.event,
+	}, "")
+}
+
+// handleRedeliver handles POST /api/webhooks/deliveries/:id/redeliver
+// Queues a new delivery of the same event to the same webhook
+func (p *WebhooksPlugin) handleRedeliver(c *fiber.Ctx) error {
+	delivery, ok := p.getDelivery(c.Params("id"))
+	if !ok {
+		return SendErrorMessage(c, 404, "Delivery not found")
+	}
+	hook, ok := p.findHook(delivery.Webhook)
+	if !ok {
+		return SendErrorMessage(c, 404, "Webhook no longer configured")
+	}
+
+	queued := p.enqueue(hook, delivery.event)
+	delivery, _ = p.getDelivery(queued.ID)
+	return SendSuccess(c, delivery, "Redelivery queued")
+}
+
+// normalizeWebhooksConfig fills in defaults
+func normalizeWebhooksConfig(cfg WebhooksConfig) WebhooksConfig {
+	if cfg.Retries < 0 {
+		cfg.Retries = 0
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = DefaultWebhookRetryDelay
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultWebhookTimeout
+	}
+	if cfg.History <= 0 {
+		cfg.History = DefaultWebhookHistory
+	}
+	return cfg
+}
+
+// validateWebhooksConfig checks names and URLs
+func validateWebhooksConfig(cfg WebhooksConfig) error {
+	names := make(map[string]bool)
+	for _, hook := range cfg.Hooks {
+		if hook.Name == "" {
+			return fmt.Errorf("webhook name is required")
+		}
+		if names[hook.Name] {
+			return fmt.Errorf("duplicate webhook name %q", hook.Name)
+		}
+		names[hook.Name] = true
+
+		u, err := url.Parse(hook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook %q: invalid url %q", hook.Name, hook.URL)
+		}
+	}
+	return nil
+}
+
+// Validate checks the settings after filling in defaults
+func (cfg WebhooksConfig) Validate() error {
+	return validateWebhooksConfig(normalizeWebhooksConfig(cfg))
+}
+
+// Register the plugin
+func init() {
+	Register("webhooks", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[WebhooksConfig]("webhooks", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewWebhooksPlugin(cfg)
+	})
+}
@@ -2,14 +2,15 @@ package plugins
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/creack/pty"
-	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/gofiber/fiber/v2"
@@ -25,47 +26,170 @@ const (
 
 // WebShellPlugin provides terminal access to host and containers
 type WebShellPlugin struct {
-	dockerClient *client.Client
-	sessions     map[string]*Session
-	sessionsMu   sync.RWMutex
-	defaultShell string
+	dockerClient    *client.Client
+	sessions        map[string]*Session
+	sessionsMu      sync.RWMutex
+	defaultShell    string
+	reattachGrace   time.Duration
+	scrollbackBytes int
+	idleTimeout     time.Duration
+	maxLifetime     time.Duration
+	timeoutWarning  time.Duration
+	transferDir     string
+	flowWindow      int
+	terminal        TerminalConfig
+	containerShells []string
+	stopChan        chan struct{}
+	stopOnce        sync.Once
 }
 
-// Session represents an active terminal session
-type Session struct {
-	ID           string
-	Type         string
-	ContainerID  string
-	PTY          *os.File
-	Cmd          *exec.Cmd
-	ExecID       string
-	HijackedResp types.HijackedResponse
-	Closed       bool
-	mu           sync.Mutex
+// ShellSessionCounts summarizes the open terminal sessions
+type ShellSessionCounts struct {
+	Total     int `json:"total"`
+	Attached  int `json:"attached"` // with a connected client
+	Host      int `json:"host"`
+	Container int `json:"container"`
 }
 
-// ResizeMessage represents a terminal resize request
-type ResizeMessage struct {
-	Type string `json:"type"`
-	Rows uint16 `json:"rows"`
-	Cols uint16 `json:"cols"`
+// webShellPlugin is the running instance used by CurrentShellSessions
+var (
+	webShellPlugin   *WebShellPlugin
+	webShellPluginMu sync.RWMutex
+)
+
+// CurrentShellSessions counts the open terminal sessions for use by other plugins (e.g. the dashboard)
+// ok is false when the webshell plugin is not loaded
+func CurrentShellSessions() (counts ShellSessionCounts, ok bool) {
+	webShellPluginMu.RLock()
+	p := webShellPlugin
+	webShellPluginMu.RUnlock()
+	if p == nil {
+		return ShellSessionCounts{}, false
+	}
+
+	p.sessionsMu.RLock()
+	defer p.sessionsMu.RUnlock()
+	for _, session := range p.sessions {
+		counts.Total++
+		if attached, _ := session.attached(); attached {
+			counts.Attached++
+		}
+		if session.Type == SessionTypeContainer {
+			counts.Container++
+		} else {
+			counts.Host++
+		}
+	}
+	return counts, true
+}
+
+// WebShellConfig holds the webshell section of the configuration
+// Durations are in seconds
+type WebShellConfig struct {
+	Shell           string         `yaml:"shell"`
+	ReattachGrace   int            `yaml:"reattach_grace"` // 0 closes dropped sessions immediately
+	Scrollback      int            `yaml:"scrollback"`     // bytes replayed on reattach
+	IdleTimeout     int            `yaml:"idle_timeout"`   // 0 disables
+	MaxLifetime     int            `yaml:"max_lifetime"`   // 0 disables
+	TimeoutWarning  int            `yaml:"timeout_warning"`
+	TransferDir     string         `yaml:"transfer_dir"`     // ZMODEM transfer directory, defaults to the home directory
+	FlowWindow      int            `yaml:"flow_window"`      // bytes of unacknowledged output, 0 disables flow control
+	ContainerShells []string       `yaml:"container_shells"` // tried in order for container sessions
+	Terminal        TerminalConfig `yaml:"terminal"`
+
+	Client *client.Client `yaml:"-"`
+}
+
+// DefaultWebShellConfig returns the settings used for keys missing from the config file
+func DefaultWebShellConfig() WebShellConfig {
+	return WebShellConfig{
+		ReattachGrace: int(DefaultReattachGrace / time.Second),
+		FlowWindow:    DefaultFlowWindow,
+		Terminal:      TerminalConfig{Term: DefaultTerm, Locale: DefaultLocale},
+	}
+}
+
+// Validate checks the webshell settings
+func (cfg WebShellConfig) Validate() error {
+	for _, shell := range cfg.ContainerShells {
+		if err := validateShellPath(shell); err != nil {
+			return fmt.Errorf("container_shells: %w", err)
+		}
+	}
+	if err := cfg.Terminal.Validate(); err != nil {
+		return fmt.Errorf("terminal: %w", err)
+	}
+	return nil
 }
 
 // NewWebShellPlugin creates a new WebShell plugin instance
-func NewWebShellPlugin(dockerClient *client.Client, defaultShell string) (*WebShellPlugin, error) {
+func NewWebShellPlugin(dockerClient *client.Client, cfg WebShellConfig) (*WebShellPlugin, error) {
 	if dockerClient == nil {
 		return nil, fmt.Errorf("docker client cannot be nil")
 	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 
-	if defaultShell == "" {
-		defaultShell = "/bin/sh"
+	if cfg.Shell == "" {
+		cfg.Shell = "/bin/sh"
 	}
+	if len(cfg.ContainerShells) == 0 {
+		cfg.ContainerShells = DefaultContainerShells
+	}
+	if cfg.Terminal.Term == "" {
+		cfg.Terminal.Term = DefaultTerm
+	}
+	if cfg.ReattachGrace < 0 {
+		cfg.ReattachGrace = 0
+	}
+	if cfg.Scrollback <= 0 {
+		cfg.Scrollback = DefaultScrollbackBytes
+	}
+	if cfg.FlowWindow < 0 {
+		cfg.FlowWindow = 0
+	}
+	timeoutWarning := time.Duration(cfg.TimeoutWarning) * time.Second
+	if timeoutWarning <= 0 {
+		timeoutWarning = DefaultTimeoutWarning
+	}
+	if cfg.TransferDir == "" {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			cfg.TransferDir = homeDir
+		} else {
+			cfg.TransferDir = os.TempDir()
+		}
+	}
+
+	p := &WebShellPlugin{
+		dockerClient:    dockerClient,
+		sessions:        make(map[string]*Session),
+		defaultShell:    cfg.Shell,
+		reattachGrace:   time.Duration(cfg.ReattachGrace) * time.Second,
+		scrollbackBytes: cfg.Scrollback,
+		idleTimeout:     time.Duration(cfg.IdleTimeout) * time.Second,
+		maxLifetime:     time.Duration(cfg.MaxLifetime) * time.Second,
+		timeoutWarning:  timeoutWarning,
+		transferDir:     cfg.TransferDir,
+		flowWindow:      cfg.FlowWindow,
+		terminal:        cfg.Terminal,
+		containerShells: cfg.ContainerShells,
+		stopChan:        make(chan struct{}),
+	}
+
+	webShellPluginMu.Lock()
+	webShellPlugin = p
+	webShellPluginMu.Unlock()
 
-	return &WebShellPlugin{
-		dockerClient: dockerClient,
-		sessions:     make(map[string]*Session),
-		defaultShell: defaultShell,
-	}, nil
+	return p, nil
+}
+
+// Start begins enforcing the idle and absolute session timeouts
+func (p *WebShellPlugin) Start() error {
+	if p.idleTimeout > 0 || p.maxLifetime > 0 {
+		go p.reapSessions()
+	}
+	return nil
 }
 
 // Name returns the plugin identifier
@@ -75,17 +199,31 @@ func (p *WebShellPlugin) Name() string {
 
 // RegisterRoutes adds the plugin's HTTP routes
 func (p *WebShellPlugin) RegisterRoutes(app *fiber.App) {
-	api := app.Group("/api/webshell")
+	api := APIGroup(app, "/webshell")
 
 	// WebSocket endpoint for terminal
-	api.Get("/ws", websocket.New(p.handleWebSocket))
+	api.Get("/ws", p.parseTerminalOptions, websocket.New(p.handleWebSocket))
 
 	// REST endpoint to list running containers
-	api.Get("/containers", p.listContainers)
+	api.Get("/containers", RequireDocker(), p.listContainers)
+
+	// REST endpoints for session management
+	api.Get("/sessions", p.listSessions)
+	api.Delete("/sessions/:id", p.deleteSession)
 }
 
 // Shutdown performs cleanup
 func (p *WebShellPlugin) Shutdown() error {
+	p.stopOnce.Do(func() {
+		close(p.stopChan)
+	})
+
+	webShellPluginMu.Lock()
+	if webShellPlugin == p {
+		webShellPlugin = nil
+	}
+	webShellPluginMu.Unlock()
+
 	p.sessionsMu.Lock()
 	defer p.sessionsMu.Unlock()
 
@@ -99,50 +237,180 @@ func (p *WebShellPlugin) Shutdown() error {
 }
 
 // handleWebSocket handles WebSocket connections for terminal I/O
+// Pass ?session=<id> to reattach to a detached session within the grace period;
+// the terminal options (?term=, ?locale=, ?env=, ?command=) apply to new sessions only.
+// Container sessions start the first working shell of container_shells unless ?shell= names one.
+// Messages in both directions are binary frames: a data frame (0x00) carries
+// terminal bytes, a control frame (0x01) a JSON object. Clients acknowledge
+// processed output with {"type":"ack","bytes":n} and may pause and resume it.
 func (p *WebShellPlugin) handleWebSocket(c *websocket.Conn) {
 	sessionType := c.Query("type")
 	containerID := c.Query("container")
+	reattachID := c.Query("session")
+	opts := p.terminalOptions(c)
 
 	var session *Session
 	var err error
 
-	// Create appropriate session
-	switch sessionType {
-	case SessionTypeHost:
-		session, err = p.createHostSession()
-	case SessionTypeContainer:
+	// Reattach or create appropriate session
+	switch {
+	case reattachID != "":
+		session = p.getSession(reattachID)
+		if session == nil {
+			writeErrorFrame(c, "Session not found or expired")
+			c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "session not found"))
+			return
+		}
+	case sessionType == SessionTypeHost:
+		session, err = p.createHostSession(opts)
+	case sessionType == SessionTypeContainer:
 		if containerID == "" {
-			c.WriteJSON(fiber.Map{"error": "Container ID required"})
+			writeErrorFrame(c, "Container ID required")
 			return
 		}
-		session, err = p.createContainerSession(containerID)
+		shell := c.Query("shell")
+		if shell != "" {
+			if err := validateShellPath(shell); err != nil {
+				writeErrorFrame(c, err.Error())
+				return
+			}
+		}
+		session, err = p.createContainerSession(containerID, shell, opts)
 	default:
-		c.WriteJSON(fiber.Map{"error": "Invalid session type. Use 'host' or 'container'"})
+		writeErrorFrame(c, "Invalid session type. Use 'host' or 'container'")
 		return
 	}
 
 	if err != nil {
-		c.WriteJSON(fiber.Map{"error": err.Error()})
+		writeErrorFrame(c, err.Error())
 		return
 	}
 
-	defer p.CloseSession(session.ID)
+	if err := session.attach(c, reattachID != ""); err != nil {
+		p.detachSession(c, session)
+		return
+	}
+	if reattachID != "" {
+		slog.Info("Webshell session reattached", "session", session.ID)
+	} else if opts.Command != "" {
+		// Typed like user input, so the shell's own startup files have run first
+		session.input().Write([]byte(opts.Command + "\n"))
+	}
 
-	// Handle I/O
-	if session.Type == SessionTypeHost {
-		p.handleHostSession(c, session)
-	} else {
-		p.handleContainerSession(c, session)
+	// Handle input until the client goes away
+	p.handleInput(c, session)
+	p.detachSession(c, session)
+}
+
+// detachSession releases the client and closes the session unless it can be reattached
+func (p *WebShellPlugin) detachSession(c *websocket.Conn, session *Session) {
+	if !session.detach(c, p.reattachGrace, func() {
+		slog.Info("Webshell session reattach grace period expired", "session", session.ID)
+		p.CloseSession(session.ID)
+	}) {
+		return
+	}
+
+	if p.reattachGrace <= 0 {
+		p.CloseSession(session.ID)
+		return
+	}
+	slog.Info("Webshell session detached", "session", session.ID, "grace", p.reattachGrace)
+}
+
+// reapSessions enforces idle and absolute timeouts, warning the user before termination
+func (p *WebShellPlugin) reapSessions() {
+	ticker := time.NewTicker(sessionReapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopChan:
+			return
+		case <-ticker.C:
+		}
+
+		p.sessionsMu.RLock()
+		sessions := make([]*Session, 0, len(p.sessions))
+		for _, session := range p.sessions {
+			sessions = append(sessions, session)
+		}
+		p.sessionsMu.RUnlock()
+
+		now := time.Now()
+		for _, session := range sessions {
+			deadline, reason := p.sessionDeadline(session)
+			if deadline.IsZero() {
+				continue
+			}
+
+			remaining := deadline.Sub(now)
+			if remaining <= 0 {
+				slog.Info("Webshell session timed out", "session", session.ID, "reason", reason)
+				session.notice("Session closed: " + reason)
+				p.CloseSession(session.ID)
+				continue
+			}
+
+			if remaining <= p.timeoutWarning && !session.warnedFor.Equal(deadline) {
+				session.warnedFor = deadline
+				session.notice(fmt.Sprintf("Session will be closed in %s (%s)", remaining.Round(time.Second), reason))
+			}
+		}
 	}
 }
 
+// sessionDeadline returns the earliest enforced deadline for a session and its reason
+func (p *WebShellPlugin) sessionDeadline(session *Session) (time.Time, string) {
+	var deadline time.Time
+	var reason string
+
+	if p.idleTimeout > 0 {
+		deadline = session.idleSince().Add(p.idleTimeout)
+		reason = "idle timeout"
+	}
+	if p.maxLifetime > 0 {
+		lifetimeEnd := session.Created.Add(p.maxLifetime)
+		if deadline.IsZero() || lifetimeEnd.Before(deadline) {
+			deadline = lifetimeEnd
+			reason = "maximum session lifetime reached"
+		}
+	}
+	return deadline, reason
+}
+
+// getSession returns an open session by ID
+func (p *WebShellPlugin) getSession(sessionID string) *Session {
+	p.sessionsMu.RLock()
+	defer p.sessionsMu.RUnlock()
+	return p.sessions[sessionID]
+}
+
+// addSession registers a session and starts its output pump
+func (p *WebShellPlugin) addSession(session *Session) {
+	session.Created = time.Now()
+	session.touch()
+	session.transferDir = p.transferDir
+	session.scrollback = newScrollbackBuffer(p.scrollbackBytes)
+	session.flowCond = sync.NewCond(&session.outMu)
+	session.flowWindow = p.flowWindow
+
+	p.sessionsMu.Lock()
+	p.sessions[session.ID] = session
+	p.sessionsMu.Unlock()
+
+	go session.pump(func() {
+		p.CloseSession(session.ID)
+	})
+}
+
 // createHostSession creates a new host shell session
-func (p *WebShellPlugin) createHostSession() (*Session, error) {
+func (p *WebShellPlugin) createHostSession(opts TerminalConfig) (*Session, error) {
 	sessionID := uuid.New().String()
 
 	// Start shell with PTY
 	cmd := exec.Command(p.defaultShell)
-	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+	cmd.Env = opts.hostEnviron()
 
 	// Set initial directory to home directory
 	homeDir, err := os.UserHomeDir()
@@ -161,26 +429,29 @@ func (p *WebShellPlugin) createHostSession() (*Session, error) {
 		PTY:  ptmx,
 		Cmd:  cmd,
 	}
-
-	p.sessionsMu.Lock()
-	p.sessions[sessionID] = session
-	p.sessionsMu.Unlock()
+	p.addSession(session)
 
 	return session, nil
 }
 
 // createContainerSession creates a new container shell session
-func (p *WebShellPlugin) createContainerSession(containerID string) (*Session, error) {
+func (p *WebShellPlugin) createContainerSession(containerID string, shell string, opts TerminalConfig) (*Session, error) {
 	ctx := context.Background()
 	sessionID := uuid.New().String()
 
+	shell, err := p.containerShell(containerID, shell)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create exec instance
 	execConfig := container.ExecOptions{
 		AttachStdin:  true,
 		AttachStdout: true,
 		AttachStderr: true,
 		Tty:          true,
-		Cmd:          []string{"/bin/sh"},
+		Env:          opts.environ(),
+		Cmd:          []string{shell},
 	}
 
 	execIDResp, err := p.dockerClient.ContainerExecCreate(ctx, containerID, execConfig)
@@ -200,97 +471,70 @@ func (p *WebShellPlugin) createContainerSession(containerID string) (*Session, e
 		ID:           sessionID,
 		Type:         SessionTypeContainer,
 		ContainerID:  containerID,
+		Shell:        shell,
 		ExecID:       execIDResp.ID,
 		HijackedResp: resp,
 	}
-
-	p.sessionsMu.Lock()
-	p.sessions[sessionID] = session
-	p.sessionsMu.Unlock()
+	p.addSession(session)
 
 	return session, nil
 }
 
-// handleHostSession handles I/O for host shell sessions
-func (p *WebShellPlugin) handleHostSession(c *websocket.Conn, session *Session) {
-	// Goroutine: Read from PTY and send to WebSocket
-	go func() {
-		buf := make([]byte, 4096)
-		for {
-			n, err := session.PTY.Read(buf)
-			if err != nil {
-				return
-			}
-			if err := c.WriteMessage(websocket.TextMessage, buf[:n]); err != nil {
-				return
-			}
-		}
-	}()
-
-	// Read from WebSocket and write to PTY
+// handleInput reads from the WebSocket and writes to the session
+func (p *WebShellPlugin) handleInput(c *websocket.Conn, session *Session) {
 	for {
-		_, msg, err := c.ReadMessage()
+		messageType, msg, err := c.ReadMessage()
 		if err != nil {
 			return
 		}
 
-		// Check if this is a resize message
-		var resizeMsg ResizeMessage
-		if err := json.Unmarshal(msg, &resizeMsg); err == nil && resizeMsg.Type == "resize" {
-			pty.Setsize(session.PTY, &pty.Winsize{
-				Rows: resizeMsg.Rows,
-				Cols: resizeMsg.Cols,
-			})
-			continue
-		}
-
-		// Regular input - write to PTY
-		if _, err := session.PTY.Write(msg); err != nil {
-			return
-		}
-	}
-}
-
-// handleContainerSession handles I/O for container shell sessions
-func (p *WebShellPlugin) handleContainerSession(c *websocket.Conn, session *Session) {
-	// Goroutine: Read from container and send to WebSocket
-	go func() {
-		buf := make([]byte, 4096)
-		for {
-			n, err := session.HijackedResp.Reader.Read(buf)
-			if err != nil {
-				return
+		if messageType == websocket.BinaryMessage {
+			if len(msg) == 0 {
+				continue
 			}
-			if err := c.WriteMessage(websocket.TextMessage, buf[:n]); err != nil {
-				return
+			switch msg[0] {
+			case frameData:
+				msg = msg[1:]
+			case frameControl:
+				p.handleControl(session, msg[1:])
+				continue
+			default:
+				continue
 			}
-		}
-	}()
-
-	// Read from WebSocket and write to container
-	for {
-		_, msg, err := c.ReadMessage()
-		if err != nil {
-			return
+		} else if p.handleControl(session, msg) {
+			// Text messages are accepted from older clients: a JSON control message or input
+			continue
 		}
 
-		// Check if this is a resize message
-		var resizeMsg ResizeMessage
-		if err := json.Unmarshal(msg, &resizeMsg); err == nil && resizeMsg.Type == "resize" {
-			p.dockerClient.ContainerExecResize(context.Background(), session.ExecID, container.ResizeOptions{
-				Height: uint(resizeMsg.Rows),
-				Width:  uint(resizeMsg.Cols),
-			})
+		// Input while a transfer is running
+		if session.interceptTransferInput(msg) {
 			continue
 		}
 
-		// Regular input - write to container
-		if _, err := session.HijackedResp.Conn.Write(msg); err != nil {
+		// Regular input - write to PTY or container
+		session.touch()
+		if _, err := session.input().Write(msg); err != nil {
 			return
 		}
 	}
 }
 
+// resizeSession applies a terminal size to the session
+func (p *WebShellPlugin) resizeSession(session *Session, rows uint16, cols uint16) {
+	if session.Type == SessionTypeHost {
+		pty.Setsize(session.PTY, &pty.Winsize{
+			Rows: rows,
+			Cols: cols,
+		})
+		return
+	}
+
+	p.dockerClient.ContainerExecResize(context.Background(), session.ExecID, container.ResizeOptions{
+		Height: uint(rows),
+		Width:  uint(cols),
+	})
+}
+
 // CloseSession closes a session and cleans up resources
 func (p *WebShellPlugin) CloseSession(sessionID string) error {
 	p.sessionsMu.Lock()
@@ -313,6 +557,9 @@ func (p *WebShellPlugin) closeSessionUnsafe(sessionID string) error {
 	}
 
 	session.Closed = true
+	session.stopGraceTimer()
+	session.stopOutput()
+	session.abortTransfer()
 
 	switch session.Type {
 	case SessionTypeHost:
@@ -330,6 +577,51 @@ func (p *WebShellPlugin) closeSessionUnsafe(sessionID string) error {
 	return nil
 }
 
+// listSessions returns open terminal sessions
+func (p *WebShellPlugin) listSessions(c *fiber.Ctx) error {
+	p.sessionsMu.RLock()
+	sessions := make([]*Session, 0, len(p.sessions))
+	for _, session := range p.sessions {
+		sessions = append(sessions, session)
+	}
+	p.sessionsMu.RUnlock()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Created.Before(sessions[j].Created)
+	})
+
+	result := make([]fiber.Map, len(sessions))
+	for i, session := range sessions {
+		attached, detachedAt := session.attached()
+		entry := fiber.Map{
+			"id":        session.ID,
+			"type":      session.Type,
+			"container": session.ContainerID,
+			"shell":     session.Shell,
+			"created":   session.Created,
+			"attached":  attached,
+		}
+		if !attached && !detachedAt.IsZero() {
+			entry["detached_at"] = detachedAt
+		}
+		result[i] = entry
+	}
+
+	return SendSuccess(c, result, "")
+}
+
+// deleteSession terminates a session
+func (p *WebShellPlugin) deleteSession(c *fiber.Ctx) error {
+	sessionID := c.Params("id")
+	if p.getSession(sessionID) == nil {
+		return SendErrorMessage(c, 404, "Session not found")
+	}
+
+	p.CloseSession(sessionID)
+	slog.InfoContext(c.UserContext(), "Webshell session terminated", "session", sessionID)
+	return SendSuccess(c, nil, "Session terminated")
+}
+
 // listContainers returns running containers for shell access
 func (p *WebShellPlugin) listContainers(c *fiber.Ctx) error {
 	ctx := context.Background()
@@ -364,18 +656,11 @@ func (p *WebShellPlugin) listContainers(c *fiber.Ctx) error {
 // Register the plugin
 func init() {
 	Register("webshell", func(config interface{}) (Plugin, error) {
-		configMap, ok := config.(map[string]interface{})
-		if !ok {
-			return nil, fmt.Errorf("invalid config for webshell plugin: expected map[string]interface{}")
-		}
-
-		dockerClient, ok := configMap["client"].(*client.Client)
-		if !ok {
-			return nil, fmt.Errorf("invalid config for webshell plugin: client must be *client.Client")
+		cfg, err := configAs[WebShellConfig]("webshell", config)
+		if err != nil {
+			return nil, err
 		}
 
-		shell, _ := configMap["shell"].(string)
-
-		return NewWebShellPlugin(dockerClient, shell)
-	})
+		return NewWebShellPlugin(cfg.Client, cfg)
+	}, "dockerclient")
 }
@@ -0,0 +1,137 @@
+package plugins
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// Terminal defaults
+const (
+	DefaultTerm   = "xterm-256color"
+	DefaultLocale = "C.UTF-8"
+)
+
+// terminalLocal is the request local holding the terminal options of a WebSocket connection
+const terminalLocal = "webshell.terminal"
+
+var (
+	terminalTermPattern   = regexp.MustCompile(`^[A-Za-z0-9._+-]{1,64}$`)
+	terminalLocalePattern = regexp.MustCompile(`^[A-Za-z0-9._@-]{1,64}$`)
+	terminalEnvPattern    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// TerminalConfig holds the environment of new terminal sessions
+// Everything except the size can be overridden per connection.
+type TerminalConfig struct {
+	Rows    int               `yaml:"rows"`
+	Cols    int               `yaml:"cols"`
+	Term    string            `yaml:"term"`    // TERM, default xterm-256color
+	Locale  string            `yaml:"locale"`  // LANG and LC_ALL, empty keeps the manager's locale
+	Env     map[string]string `yaml:"env"`     // extra environment variables
+	Command string            `yaml:"command"` // typed into the shell once it has started
+}
+
+// Validate checks the terminal settings
+func (cfg TerminalConfig) Validate() error {
+	if cfg.Term != "" && !terminalTermPattern.MatchString(cfg.Term) {
+		return fmt.Errorf("invalid term %q", cfg.Term)
+	}
+	if cfg.Locale != "" && !terminalLocalePattern.MatchString(cfg.Locale) {
+		return fmt.Errorf("invalid locale %q", cfg.Locale)
+	}
+	for name, value := range cfg.Env {
+		if !terminalEnvPattern.MatchString(name) {
+			return fmt.Errorf("invalid environment variable name %q", name)
+		}
+		if strings.ContainsRune(value, 0) {
+			return fmt.Errorf("environment variable %s contains a NUL byte", name)
+		}
+	}
+	if strings.ContainsAny(cfg.Command, "\r\n") {
+		return fmt.Errorf("command must be a single line")
+	}
+	return nil
+}
+
+// environ returns the variables set for a session, sorted by name
+// TERM and the locale come first so env entries can still override them.
+func (cfg TerminalConfig) environ() []string {
+	vars := map[string]string{}
+	if cfg.Term != "" {
+		vars["TERM"] = cfg.Term
+	}
+	if cfg.Locale != "" {
+		vars["LANG"] = cfg.Locale
+		vars["LC_ALL"] = cfg.Locale
+	}
+	for name, value := range cfg.Env {
+		vars[name] = value
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	env := make([]string, len(names))
+	for i, name := range names {
+		env[i] = name + "=" + vars[name]
+	}
+	return env
+}
+
+// hostEnviron returns the environment of a host shell: the manager's own plus the session's
+// exec.Cmd keeps the last value of a duplicated name, so the session's variables win.
+func (cfg TerminalConfig) hostEnviron() []string {
+	return append(os.Environ(), cfg.environ()...)
+}
+
+// parseTerminalOptions applies the query overrides ?term=, ?locale=, ?env=NAME=value
+// (repeatable) and ?command= to the configured terminal settings before the upgrade,
+// so invalid options are answered with 400 instead of a WebSocket that closes at once.
+func (p *WebShellPlugin) parseTerminalOptions(c *fiber.Ctx) error {
+	opts := p.terminal
+	if term := c.Query("term"); term != "" {
+		opts.Term = term
+	}
+	if locale := c.Query("locale"); locale != "" {
+		opts.Locale = locale
+	}
+	if command := c.Query("command"); command != "" {
+		opts.Command = command
+	}
+	if values := c.Context().QueryArgs().PeekMulti("env"); len(values) > 0 {
+		env := make(map[string]string, len(opts.Env)+len(values))
+		for name, value := range opts.Env {
+			env[name] = value
+		}
+		for _, value := range values {
+			name, value, ok := strings.Cut(string(value), "=")
+			if !ok {
+				return SendErrorMessage(c, 400, fmt.Sprintf("invalid env %q, expected NAME=value", name))
+			}
+			env[name] = value
+		}
+		opts.Env = env
+	}
+	if err := opts.Validate(); err != nil {
+		return SendErrorMessage(c, 400, err.Error())
+	}
+
+	c.Locals(terminalLocal, opts)
+	return c.Next()
+}
+
+// terminalOptions returns the terminal settings of a WebSocket connection
+func (p *WebShellPlugin) terminalOptions(c *websocket.Conn) TerminalConfig {
+	if opts, ok := c.Locals(terminalLocal).(TerminalConfig); ok {
+		return opts
+	}
+	return p.terminal
+}
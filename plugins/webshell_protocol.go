@@ -0,0 +1,138 @@
+package plugins
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// Terminal frame kinds, the first byte of every binary WebSocket message
+// The rest of a data frame is raw terminal bytes; the rest of a control frame is a JSON object.
+const (
+	frameData    byte = 0x00
+	frameControl byte = 0x01
+)
+
+// Flow control defaults
+const (
+	DefaultFlowWindow = 256 * 1024
+)
+
+// ControlMessage is a control frame sent by the client
+type ControlMessage struct {
+	Type string `json:"type"` // resize, ack, pause, resume or transfer
+
+	// resize
+	Rows uint16 `json:"rows"`
+	Cols uint16 `json:"cols"`
+	// ack: terminal output bytes the client has processed
+	Bytes int `json:"bytes"`
+	// transfer
+	Path   string `json:"path"`
+	Cancel bool   `json:"cancel"`
+}
+
+// writeFrame sends one binary frame to a WebSocket
+func writeFrame(c *websocket.Conn, kind byte, payload []byte) error {
+	frame := make([]byte, 1+len(payload))
+	frame[0] = kind
+	copy(frame[1:], payload)
+	c.SetWriteDeadline(time.Now().Add(sessionWriteTimeout))
+	return c.WriteMessage(websocket.BinaryMessage, frame)
+}
+
+// writeControlFrame sends a JSON control message to a WebSocket
+func writeControlFrame(c *websocket.Conn, msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return writeFrame(c, frameControl, data)
+}
+
+// writeErrorFrame reports an error that prevents the session from starting
+func writeErrorFrame(c *websocket.Conn, message string) {
+	writeControlFrame(c, fiber.Map{"type": "error", "error": message})
+}
+
+// handleControl applies a control message from the client
+// Returns false when msg is not a known control message
+func (p *WebShellPlugin) handleControl(session *Session, msg []byte) bool {
+	var control ControlMessage
+	if err := json.Unmarshal(msg, &control); err != nil {
+		return false
+	}
+
+	switch control.Type {
+	case "resize":
+		p.resizeSession(session, control.Rows, control.Cols)
+	case "ack":
+		session.acknowledge(control.Bytes)
+	case "pause":
+		session.setPaused(true)
+	case "resume":
+		session.setPaused(false)
+	case "transfer":
+		session.handleTransferMessage(TransferMessage{Type: control.Type, Path: control.Path, Cancel: control.Cancel})
+	default:
+		return false
+	}
+	return true
+}
+
+// waitForClient blocks the output pump while the client is paused or has
+// too much unacknowledged output, so a fast producer waits on the PTY instead
+// of filling the browser's memory. Output of detached sessions only goes to
+// the bounded scrollback and is never held back.
+func (s *Session) waitForClient() {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+
+	for !s.outputDone && s.client != nil &&
+		(s.paused || s.flowWindow > 0 && s.unacked >= s.flowWindow) {
+		s.flowCond.Wait()
+	}
+}
+
+// acknowledge releases output the client has processed
+func (s *Session) acknowledge(n int) {
+	if n <= 0 {
+		return
+	}
+
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+
+	s.unacked -= n
+	if s.unacked < 0 {
+		s.unacked = 0
+	}
+	s.flowCond.Broadcast()
+}
+
+// setPaused stops or restarts reading terminal output for the attached client
+func (s *Session) setPaused(paused bool) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+
+	s.paused = paused
+	s.flowCond.Broadcast()
+}
+
+// resetFlowLocked starts flow control over for a new or departed client
+func (s *Session) resetFlowLocked() {
+	s.unacked = 0
+	s.paused = false
+	s.flowCond.Broadcast()
+}
+
+// stopOutput releases a pump waiting for the client when the session closes
+func (s *Session) stopOutput() {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+
+	s.outputDone = true
+	s.flowCond.Broadcast()
+}
@@ -0,0 +1,269 @@
+package plugins
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/gofiber/websocket/v2"
+)
+
+// Session defaults
+const (
+	DefaultReattachGrace   = 60 * time.Second
+	DefaultScrollbackBytes = 64 * 1024
+	DefaultTimeoutWarning  = 60 * time.Second
+	sessionWriteTimeout    = 10 * time.Second
+	sessionReapInterval    = 5 * time.Second
+)
+
+// Session represents an active terminal session
+// The session outlives its WebSocket so a dropped client can reattach
+type Session struct {
+	ID           string
+	Type         string
+	ContainerID  string
+	Shell        string
+	PTY          *os.File
+	Cmd          *exec.Cmd
+	ExecID       string
+	HijackedResp types.HijackedResponse
+	Created      time.Time
+	Closed       bool
+	mu           sync.Mutex
+
+	// Last input or output, in Unix nanoseconds
+	lastActivity atomic.Int64
+	// Deadline the user has already been warned about
+	warnedFor time.Time
+
+	// ZMODEM transfer state, guarded by transferMu
+	transferMu  sync.Mutex
+	transfer    *fileTransfer
+	transferDir string
+
+	// Output state, guarded by outMu
+	outMu      sync.Mutex
+	client     *websocket.Conn
+	scrollback *scrollbackBuffer
+	detachedAt time.Time
+	graceTimer *time.Timer
+
+	// Flow control, guarded by outMu; flowCond wakes the pump
+	flowCond   *sync.Cond
+	flowWindow int  // unacknowledged bytes sent before output is held back, 0 disables
+	unacked    int  // output bytes the client has not acknowledged yet
+	paused     bool // client asked to stop output
+	outputDone bool // session closed
+}
+
+// SessionControl is sent to the client as a control frame when a client attaches
+type SessionControl struct {
+	Type       string `json:"type"`
+	ID         string `json:"id"`
+	Shell      string `json:"shell,omitempty"`
+	Reattached bool   `json:"reattached"`
+	FlowWindow int    `json:"flow_window"` // unacknowledged output bytes sent before waiting, 0 = no flow control
+}
+
+// output returns the reader producing terminal output
+func (s *Session) output() io.Reader {
+	if s.Type == SessionTypeHost {
+		return s.PTY
+	}
+	return s.HijackedResp.Reader
+}
+
+// input returns the writer receiving terminal input
+func (s *Session) input() io.Writer {
+	if s.Type == SessionTypeHost {
+		return s.PTY
+	}
+	return s.HijackedResp.Conn
+}
+
+// pump copies terminal output into the scrollback and the attached client
+// Runs for the lifetime of the session and calls onExit when the shell ends
+func (s *Session) pump(onExit func()) {
+	buf := make([]byte, 4096)
+	for {
+		s.waitForClient()
+		n, err := s.output().Read(buf)
+		if n > 0 {
+			s.touch()
+			if !s.interceptTransfer(buf[:n]) {
+				s.emit(buf[:n])
+			}
+		}
+		if err != nil {
+			s.outMu.Lock()
+			if s.client != nil {
+				// Normal closure tells the client not to reattach
+				s.client.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "session ended"))
+				s.client.Close()
+				s.client = nil
+			}
+			s.outMu.Unlock()
+			onExit()
+			return
+		}
+	}
+}
+
+// touch records terminal activity
+func (s *Session) touch() {
+	s.lastActivity.Store(time.Now().UnixNano())
+}
+
+// idleSince returns the time of the last input or output
+func (s *Session) idleSince() time.Time {
+	return time.Unix(0, s.lastActivity.Load())
+}
+
+// notice writes a highlighted message into the terminal output
+func (s *Session) notice(message string) {
+	s.emit([]byte("\r\n\x1b[33m*** " + message + " ***\x1b[0m\r\n"))
+}
+
+// emit records output and forwards it to the attached client, if any
+func (s *Session) emit(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+
+	s.scrollback.Write(data)
+	if s.client == nil {
+		return
+	}
+
+	s.unacked += len(data)
+	if err := writeFrame(s.client, frameData, data); err != nil {
+		// Treat a failed write as a dropped connection; the reader loop detaches it
+		s.client.Close()
+	}
+}
+
+// attach binds a WebSocket to the session, replacing any previous client
+// Sends the session ID and replays the scrollback when reattaching
+func (s *Session) attach(c *websocket.Conn, reattached bool) error {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+
+	if s.graceTimer != nil {
+		s.graceTimer.Stop()
+		s.graceTimer = nil
+	}
+	if s.client != nil && s.client != c {
+		s.client.Close()
+	}
+	s.client = c
+	s.resetFlowLocked()
+
+	if err := writeControlFrame(c, SessionControl{
+		Type:       "session",
+		ID:         s.ID,
+		Shell:      s.Shell,
+		Reattached: reattached,
+		FlowWindow: s.flowWindow,
+	}); err != nil {
+		return err
+	}
+
+	if reattached {
+		if replay := s.scrollback.Bytes(); len(replay) > 0 {
+			s.unacked += len(replay)
+			return writeFrame(c, frameData, replay)
+		}
+	}
+	return nil
+}
+
+// detach unbinds a WebSocket and schedules the session to close after the grace period
+// Returns false when another client has already taken over the session
+func (s *Session) detach(c *websocket.Conn, grace time.Duration, expire func()) bool {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+
+	if s.client != c {
+		return false
+	}
+	s.client = nil
+	s.resetFlowLocked()
+	s.detachedAt = time.Now()
+	if grace > 0 {
+		s.graceTimer = time.AfterFunc(grace, expire)
+	}
+	return true
+}
+
+// stopGraceTimer cancels a pending expiry
+func (s *Session) stopGraceTimer() {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+
+	if s.graceTimer != nil {
+		s.graceTimer.Stop()
+		s.graceTimer = nil
+	}
+}
+
+// attached reports whether a client is connected and since when the session is detached
+func (s *Session) attached() (bool, time.Time) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	return s.client != nil, s.detachedAt
+}
+
+// scrollbackBuffer keeps the most recent terminal output up to a fixed size
+type scrollbackBuffer struct {
+	data []byte
+	size int
+}
+
+// newScrollbackBuffer creates a buffer holding at most size bytes
+func newScrollbackBuffer(size int) *scrollbackBuffer {
+	return &scrollbackBuffer{
+		data: make([]byte, 0, size),
+		size: size,
+	}
+}
+
+// Write appends output, discarding the oldest bytes when full
+func (b *scrollbackBuffer) Write(p []byte) {
+	if b.size <= 0 {
+		return
+	}
+	overflow := len(b.data) + len(p) - b.size
+	if overflow <= 0 {
+		b.data = append(b.data, p...)
+		return
+	}
+
+	if len(p) >= b.size {
+		b.data = append(b.data[:0], p[len(p)-b.size:]...)
+	} else {
+		b.data = append(b.data[:0], b.data[overflow:]...)
+		b.data = append(b.data, p...)
+	}
+
+	// Never start the replay in the middle of a UTF-8 sequence
+	start := 0
+	for start < len(b.data) && start < 4 && b.data[start]&0xC0 == 0x80 {
+		start++
+	}
+	if start > 0 {
+		b.data = append(b.data[:0], b.data[start:]...)
+	}
+}
+
+// Bytes returns a copy of the buffered output
+func (b *scrollbackBuffer) Bytes() []byte {
+	return append([]byte(nil), b.data...)
+}
@@ -0,0 +1,98 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+)
+
+// Container shell probing
+const (
+	shellProbeTimeout  = 5 * time.Second
+	shellProbeInterval = 100 * time.Millisecond
+)
+
+// DefaultContainerShells is the order in which container shells are tried
+var DefaultContainerShells = []string{"/bin/bash", "/bin/ash", "/bin/sh"}
+
+var shellPathPattern = regexp.MustCompile(`^/[A-Za-z0-9._/+-]+$`)
+
+// validateShellPath checks that a shell is given as an absolute path
+func validateShellPath(shell string) error {
+	if !shellPathPattern.MatchString(shell) {
+		return fmt.Errorf("invalid shell %q, expected an absolute path", shell)
+	}
+	return nil
+}
+
+// probeContainerShell runs "<shell> -c 'exit 0'" in the container and checks
+// its exit status with exec inspect, so a missing shell is found before the
+// interactive exec is attached
+func (p *WebShellPlugin) probeContainerShell(ctx context.Context, containerID string, shell string) error {
+	execResp, err := p.dockerClient.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd: []string{shell, "-c", "exit 0"},
+	})
+	if err != nil {
+		return err
+	}
+	if err := p.dockerClient.ContainerExecStart(ctx, execResp.ID, container.ExecStartOptions{Detach: true}); err != nil {
+		return err
+	}
+
+	for {
+		inspect, err := p.dockerClient.ContainerExecInspect(ctx, execResp.ID)
+		if err != nil {
+			return err
+		}
+		if !inspect.Running {
+			if inspect.ExitCode != 0 {
+				return fmt.Errorf("exited with status %d", inspect.ExitCode)
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(shellProbeInterval):
+		}
+	}
+}
+
+// containerShell returns the shell to start in a container
+// An explicit shell is only checked; otherwise the first working shell of the chain is used.
+func (p *WebShellPlugin) containerShell(containerID string, explicit string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), shellProbeTimeout)
+	defer cancel()
+
+	if explicit != "" {
+		if err := p.probeContainerShell(ctx, containerID, explicit); err != nil {
+			return "", fmt.Errorf("shell %s is not usable in the container: %w", explicit, err)
+		}
+		return explicit, nil
+	}
+
+	var failures []string
+	for _, shell := range p.containerShells {
+		err := p.probeContainerShell(ctx, containerID, shell)
+		if err == nil {
+			return shell, nil
+		}
+		// The container itself is gone or stopped; no other shell will do better
+		if ctx.Err() != nil || isContainerUnavailable(err) {
+			return "", err
+		}
+		failures = append(failures, shell+": "+err.Error())
+	}
+	return "", fmt.Errorf("no shell found in the container, the image may not include one (%s)", strings.Join(failures, "; "))
+}
+
+// isContainerUnavailable reports whether an exec failed because of the container rather than the command
+// A missing executable is reported as not found as well, so a missing container is told apart by its message.
+func isContainerUnavailable(err error) bool {
+	return errdefs.IsConflict(err) || strings.Contains(err.Error(), "No such container")
+}
@@ -0,0 +1,342 @@
+package plugins
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Terminal file transfer directions
+const (
+	TransferDownload = "download" // remote sz -> manager
+	TransferUpload   = "upload"   // manager -> remote rz
+)
+
+// Terminal transfer escape sequences
+var (
+	// ZRQINIT hex header: remote sz is offering files
+	zmodemOfferHeader = []byte("**\x18B00")
+	// ZRINIT hex header: remote rz is waiting for files
+	zmodemReceiveHeader = []byte("**\x18B01")
+	// trzsz announces transfers with this prefix
+	trzszMagic = []byte("::TRZSZ:TRANSFER:")
+	// Eight CAN followed by eight backspaces aborts a ZMODEM session
+	zmodemAbort = []byte("\x18\x18\x18\x18\x18\x18\x18\x18\x08\x08\x08\x08\x08\x08\x08\x08")
+)
+
+// TransferMessage is sent by the client to answer a transfer prompt
+type TransferMessage struct {
+	Type   string `json:"type"`
+	Path   string `json:"path"`
+	Cancel bool   `json:"cancel"`
+}
+
+// TransferControl is sent to the client to report transfer progress
+type TransferControl struct {
+	Type      string   `json:"type"`
+	Status    string   `json:"status"` // select, started, complete, failed, cancelled
+	Direction string   `json:"direction"`
+	Dir       string   `json:"dir,omitempty"`
+	Files     []string `json:"files,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// fileTransfer bridges a ZMODEM exchange in the terminal to a local lrzsz process
+type fileTransfer struct {
+	direction string
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	started   time.Time
+}
+
+// pending reports whether an upload is still waiting for the user to pick a file
+func (t *fileTransfer) pending() bool {
+	return t.cmd == nil
+}
+
+// interceptTransfer routes terminal output to an active transfer or starts a new one
+// Returns true when the output was consumed and must not be shown in the terminal
+func (s *Session) interceptTransfer(data []byte) bool {
+	s.transferMu.Lock()
+	defer s.transferMu.Unlock()
+
+	if s.transfer != nil {
+		if !s.transfer.pending() {
+			s.transfer.stdin.Write(data)
+		}
+		return true
+	}
+
+	if idx := bytes.Index(data, zmodemOfferHeader); idx >= 0 {
+		s.emit(data[:idx])
+		s.startTransferLocked(TransferDownload, "", data[idx:])
+		return true
+	}
+
+	if idx := bytes.Index(data, zmodemReceiveHeader); idx >= 0 {
+		s.emit(data[:idx])
+		s.transfer = &fileTransfer{direction: TransferUpload}
+		s.control(TransferControl{
+			Type:      "transfer",
+			Status:    "select",
+			Direction: TransferUpload,
+			Dir:       s.transferDir,
+		})
+		return true
+	}
+
+	if bytes.Contains(data, trzszMagic) {
+		s.emit(data)
+		s.notice("trzsz transfers are not supported, press Ctrl+C and use sz/rz instead")
+		return true
+	}
+
+	return false
+}
+
+// handleTransferMessage answers a transfer prompt: sends the chosen file or cancels
+func (s *Session) handleTransferMessage(msg TransferMessage) {
+	if msg.Cancel {
+		s.cancelTransfer()
+		return
+	}
+	s.selectUploadFile(msg.Path)
+}
+
+// interceptTransferInput handles client input while a transfer is active
+// Returns true when the input was consumed
+func (s *Session) interceptTransferInput(msg []byte) bool {
+	s.transferMu.Lock()
+	active := s.transfer != nil
+	s.transferMu.Unlock()
+	if !active {
+		return false
+	}
+
+	// Keystrokes would corrupt the transfer; Ctrl+C aborts it
+	if bytes.IndexByte(msg, 0x03) >= 0 {
+		s.cancelTransfer()
+	}
+	return true
+}
+
+// selectUploadFile starts sending the chosen file to the waiting remote rz
+func (s *Session) selectUploadFile(path string) {
+	s.transferMu.Lock()
+	defer s.transferMu.Unlock()
+
+	if s.transfer == nil || !s.transfer.pending() {
+		return
+	}
+
+	cleanPath, err := sanitizePath(path)
+	if err == nil {
+		var info os.FileInfo
+		info, err = os.Stat(cleanPath)
+		if err == nil && !info.Mode().IsRegular() {
+			err = fmt.Errorf("%s is not a regular file", cleanPath)
+		}
+	}
+	if err != nil {
+		s.failTransferLocked(err)
+		return
+	}
+
+	s.transfer = nil
+	s.startTransferLocked(TransferUpload, cleanPath, nil)
+}
+
+// startTransferLocked launches the local rz or sz process
+// Caller must hold s.transferMu
+func (s *Session) startTransferLocked(direction string, path string, initial []byte) {
+	program, args := "rz", []string{"-b", "-E"}
+	if direction == TransferUpload {
+		program, args = "sz", []string{"-b", path}
+	}
+
+	transfer := &fileTransfer{
+		direction: direction,
+		started:   time.Now(),
+	}
+	s.transfer = transfer
+
+	binary, err := exec.LookPath(program)
+	if err != nil {
+		s.failTransferLocked(fmt.Errorf("%s (lrzsz) is not installed on the manager host", program))
+		return
+	}
+
+	if err := os.MkdirAll(s.transferDir, 0755); err != nil {
+		s.failTransferLocked(fmt.Errorf("failed to create transfer directory: %w", err))
+		return
+	}
+
+	cmd := exec.Command(binary, args...)
+	cmd.Dir = s.transferDir
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		s.failTransferLocked(err)
+		return
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		s.failTransferLocked(err)
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		s.failTransferLocked(fmt.Errorf("failed to start %s: %w", program, err))
+		return
+	}
+
+	transfer.cmd = cmd
+	transfer.stdin = stdin
+
+	slog.Info("Webshell file transfer started", "session", s.ID, "direction", direction, "dir", s.transferDir, "path", path)
+	s.control(TransferControl{
+		Type:      "transfer",
+		Status:    "started",
+		Direction: direction,
+		Dir:       s.transferDir,
+	})
+
+	// Local ZMODEM output goes to the remote side of the terminal
+	go io.Copy(s.input(), stdout)
+
+	if len(initial) > 0 {
+		stdin.Write(initial)
+	}
+
+	go s.waitTransfer(transfer)
+}
+
+// waitTransfer reports the result once the local process exits
+func (s *Session) waitTransfer(transfer *fileTransfer) {
+	err := transfer.cmd.Wait()
+
+	s.transferMu.Lock()
+	defer s.transferMu.Unlock()
+
+	if s.transfer != transfer {
+		// Cancelled or superseded
+		return
+	}
+	s.transfer = nil
+
+	result := TransferControl{
+		Type:      "transfer",
+		Status:    "complete",
+		Direction: transfer.direction,
+		Dir:       s.transferDir,
+	}
+	if transfer.direction == TransferDownload {
+		result.Files = filesModifiedSince(s.transferDir, transfer.started)
+	}
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+		s.notice("File transfer failed: " + err.Error())
+	} else if transfer.direction == TransferDownload {
+		s.notice(fmt.Sprintf("Received %d file(s) into %s", len(result.Files), s.transferDir))
+	} else {
+		s.notice("File sent")
+	}
+
+	slog.Info("Webshell file transfer finished", "session", s.ID, "direction", transfer.direction, "status", result.Status, "files", result.Files)
+	s.control(result)
+}
+
+// failTransferLocked aborts the remote side and reports an error
+// Caller must hold s.transferMu
+func (s *Session) failTransferLocked(err error) {
+	direction := ""
+	if s.transfer != nil {
+		direction = s.transfer.direction
+	}
+	s.transfer = nil
+
+	s.input().Write(zmodemAbort)
+	s.notice("File transfer failed: " + err.Error())
+	s.control(TransferControl{
+		Type:      "transfer",
+		Status:    "failed",
+		Direction: direction,
+		Error:     err.Error(),
+	})
+	slog.Warn("Webshell file transfer failed", "session", s.ID, "error", err)
+}
+
+// cancelTransfer stops an active transfer and aborts the remote side
+func (s *Session) cancelTransfer() {
+	s.transferMu.Lock()
+	defer s.transferMu.Unlock()
+
+	transfer := s.transfer
+	if transfer == nil {
+		return
+	}
+	s.transfer = nil
+
+	if transfer.cmd != nil && transfer.cmd.Process != nil {
+		transfer.cmd.Process.Kill()
+	}
+	s.input().Write(zmodemAbort)
+	s.notice("File transfer cancelled")
+	s.control(TransferControl{
+		Type:      "transfer",
+		Status:    "cancelled",
+		Direction: transfer.direction,
+	})
+}
+
+// abortTransfer kills a running transfer process when the session closes
+func (s *Session) abortTransfer() {
+	s.transferMu.Lock()
+	defer s.transferMu.Unlock()
+
+	if s.transfer != nil && s.transfer.cmd != nil && s.transfer.cmd.Process != nil {
+		s.transfer.cmd.Process.Kill()
+	}
+	s.transfer = nil
+}
+
+// control sends a control message to the attached client as a control frame
+func (s *Session) control(msg interface{}) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+
+	if s.client == nil {
+		return
+	}
+	writeFrame(s.client, frameControl, data)
+}
+
+// filesModifiedSince lists regular files in dir modified at or after since
+func filesModifiedSince(dir string, since time.Time) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if !info.ModTime().Before(since.Truncate(time.Second)) {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files
+}
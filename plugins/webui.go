@@ -0,0 +1,240 @@
+package plugins
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Web UI cache policies
+const (
+	webCacheRevalidate = "no-cache"
+	webCacheImmutable  = "public, max-age=31536000, immutable"
+	webVersionParam    = "v"
+)
+
+// webAssetRef matches local script and stylesheet references in HTML pages
+var webAssetRef = regexp.MustCompile(`(src|href)="(/[^"?#]+\.(?:js|css))"`)
+
+// webAsset is a file of the web UI held in memory
+type webAsset struct {
+	data        []byte
+	etag        string
+	contentType string
+}
+
+// WebUIVersion describes the served web UI
+type WebUIVersion struct {
+	Version  string    `json:"version"`
+	Source   string    `json:"source"` // "embedded" or the override directory
+	Files    int       `json:"files"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// WebUI serves the web interface from the copy embedded in the binary or,
+// for development, from a directory on disk that is reloaded when it changes
+//
+// HTML pages reference scripts and stylesheets with ?v=<version>, so those
+// can be cached forever; pages are revalidated with their ETag.
+type WebUI struct {
+	files fs.FS
+	dir   string // override directory, empty for the embedded copy
+
+	mu          sync.RWMutex
+	assets      map[string]webAsset
+	version     WebUIVersion
+	fingerprint string
+}
+
+// NewWebUI loads the web UI from the embedded files, or from dir when set
+func NewWebUI(embedded fs.FS, dir string) (*WebUI, error) {
+	w := &WebUI{files: embedded, dir: dir}
+	if dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, fmt.Errorf("web directory: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("web directory %s is not a directory", dir)
+		}
+		w.files = os.DirFS(dir)
+	}
+	if err := w.load(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// load reads all files, computes the asset version and stamps it into the HTML pages
+func (w *WebUI) load() error {
+	fingerprint, err := w.scan()
+	if err != nil {
+		return err
+	}
+
+	assets := make(map[string]webAsset)
+	var names []string
+	err = fs.WalkDir(w.files, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(w.files, name)
+		if err != nil {
+			return err
+		}
+		assets[name] = webAsset{
+			data:        data,
+			etag:        contentETag(data),
+			contentType: mime.TypeByExtension(path.Ext(name)),
+		}
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load web UI: %w", err)
+	}
+
+	// The version covers every file, so any change busts the cached assets
+	sort.Strings(names)
+	hash := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(hash, "%s %s\n", name, assets[name].etag)
+	}
+	version := hex.EncodeToString(hash.Sum(nil))[:12]
+
+	for _, name := range names {
+		if path.Ext(name) != ".html" {
+			continue
+		}
+		asset := assets[name]
+		asset.data = webAssetRef.ReplaceAll(asset.data, []byte(`$1="$2?`+webVersionParam+`=`+version+`"`))
+		asset.etag = contentETag(asset.data)
+		assets[name] = asset
+	}
+
+	source := "embedded"
+	if w.dir != "" {
+		source = w.dir
+	}
+
+	w.mu.Lock()
+	w.assets = assets
+	w.fingerprint = fingerprint
+	w.version = WebUIVersion{
+		Version:  version,
+		Source:   source,
+		Files:    len(names),
+		LoadedAt: time.Now(),
+	}
+	w.mu.Unlock()
+	return nil
+}
+
+// scan returns a fingerprint of the file names, sizes and modification times
+func (w *WebUI) scan() (string, error) {
+	var buf bytes.Buffer
+	err := fs.WalkDir(w.files, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "%s %d %d\n", name, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to scan web UI: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// refresh reloads an override directory whose contents changed
+func (w *WebUI) refresh() {
+	if w.dir == "" {
+		return
+	}
+	fingerprint, err := w.scan()
+	if err != nil {
+		return
+	}
+	w.mu.RLock()
+	changed := fingerprint != w.fingerprint
+	w.mu.RUnlock()
+	if changed {
+		w.load()
+	}
+}
+
+// contentETag returns a strong ETag for file contents
+func contentETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// Version returns the version of the served web UI
+func (w *WebUI) Version() WebUIVersion {
+	w.refresh()
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.version
+}
+
+// Handler serves GET and HEAD requests for web UI files and passes everything else on
+func (w *WebUI) Handler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+			return c.Next()
+		}
+		name := strings.TrimPrefix(path.Clean("/"+c.Path()), "/")
+		if name == "" || strings.HasSuffix(c.Path(), "/") {
+			name = path.Join(name, "index.html")
+		}
+		if strings.HasPrefix(name, "api/") {
+			return c.Next()
+		}
+
+		w.refresh()
+		w.mu.RLock()
+		asset, ok := w.assets[name]
+		version := w.version.Version
+		w.mu.RUnlock()
+		if !ok {
+			return c.Next()
+		}
+
+		// Versioned references from the pages are immutable, everything else is revalidated
+		cacheControl := webCacheRevalidate
+		if w.dir == "" && path.Ext(name) != ".html" && c.Query(webVersionParam) == version {
+			cacheControl = webCacheImmutable
+		}
+		c.Set(fiber.HeaderCacheControl, cacheControl)
+		c.Set(fiber.HeaderETag, asset.etag)
+
+		if etagMatches(c.Get(fiber.HeaderIfNoneMatch), asset.etag) {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+		if asset.contentType != "" {
+			c.Set(fiber.HeaderContentType, asset.contentType)
+		}
+		return c.Send(asset.data)
+	}
+}
+
+// HandleVersion handles GET /api/webui/version
+func (w *WebUI) HandleVersion(c *fiber.Ctx) error {
+	return SendSuccess(c, w.Version(), "")
+}
@@ -0,0 +1,447 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Wi-Fi modes
+const (
+	WiFiModeClient = "client"
+	WiFiModeAP     = "ap"
+)
+
+// Wi-Fi defaults
+const (
+	DefaultWiFiInterface = "wlan0"
+	DefaultWiFiAddress   = "192.168.4.1/24"
+	DefaultHostapdConf   = "/etc/hostapd/hostapd.conf"
+	DefaultDnsmasqConf   = "/etc/dnsmasq.d/linht-ap.conf"
+	DefaultWiFiStateFile = "/var/lib/linht/wifi.json"
+	DefaultWiFiSSID      = "linht"
+	DefaultWiFiChannel   = 6
+	wifiModeSwitchDelay  = 2 * time.Second // lets the response reach a client on the interface being switched
+	wifiModeChangedEvent = "wifi.mode"
+	wifiEventSource      = "wifi"
+	maxWiFiAddressBits   = 27 // the DHCP range needs at least 32 addresses
+)
+
+// wifiInterfacePattern restricts interface names to what the kernel allows
+var wifiInterfacePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,15}$`)
+
+// errWiFiSwitching is returned while a mode switch is in progress
+var errWiFiSwitching = errors.New("a Wi-Fi mode switch is in progress")
+
+// WiFiAPSettings are the access point network settings
+type WiFiAPSettings struct {
+	SSID    string `yaml:"ssid" json:"ssid"`
+	Channel int    `yaml:"channel" json:"channel"`
+	PSK     string `yaml:"psk" json:"psk,omitempty"`
+	Country string `yaml:"country" json:"country,omitempty"` // ISO 3166 code for regulatory limits
+}
+
+// WiFiConfig holds Wi-Fi plugin configuration
+type WiFiConfig struct {
+	Interface   string         `yaml:"interface"`
+	Address     string         `yaml:"address"` // device address in AP mode, with prefix length
+	HostapdConf string         `yaml:"hostapd_conf"`
+	DnsmasqConf string         `yaml:"dnsmasq_conf"`
+	StateFile   string         `yaml:"state_file"`   // keeps the mode and AP settings across restarts
+	APUnits     []string       `yaml:"ap_units"`     // restarted in AP mode, stopped in client mode
+	ClientUnits []string       `yaml:"client_units"` // stopped in AP mode, restarted in client mode
+	AP          WiFiAPSettings `yaml:"ap"`           // used until settings are saved through the API
+}
+
+// wifiState is persisted in the state file
+type wifiState struct {
+	Mode  string          `json:"mode"`
+	AP    *WiFiAPSettings `json:"ap,omitempty"`
+	Since time.Time       `json:"since"`
+}
+
+// WiFiStatus describes the current mode; the passphrase is never returned
+type WiFiStatus struct {
+	Mode      string         `json:"mode"`
+	Since     *time.Time     `json:"since,omitempty"`
+	Switching string         `json:"switching,omitempty"` // mode being switched to
+	LastError string         `json:"last_error,omitempty"`
+	Interface string         `json:"interface"`
+	Address   string         `json:"address"`
+	AP        WiFiAPSettings `json:"ap"`
+	PSKSet    bool           `json:"psk_set"`
+}
+
+// WiFiPlugin switches the Wi-Fi interface between client and access point mode
+type WiFiPlugin struct {
+	mu        sync.RWMutex
+	config    WiFiConfig
+	state     wifiState
+	switching string
+	lastError string
+}
+
+// NewWiFiPlugin creates a new Wi-Fi plugin instance
+func NewWiFiPlugin(cfg WiFiConfig) (*WiFiPlugin, error) {
+	cfg = normalizeWiFiConfig(cfg)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	p := &WiFiPlugin{config: cfg, state: wifiState{Mode: WiFiModeClient}}
+	if err := p.loadState(); err != nil {
+		slog.Warn("Failed to load Wi-Fi state", "file", cfg.StateFile, "error", err)
+	}
+	return p, nil
+}
+
+// Start switches a device that was in AP mode when the manager stopped back to AP mode
+func (p *WiFiPlugin) Start() error {
+	settings := p.apSettings()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.state.Mode == WiFiModeAP {
+		slog.Info("Restoring Wi-Fi access point mode", "interface", p.config.Interface, "ssid", settings.SSID)
+		p.switching = WiFiModeAP
+		go p.switchMode(WiFiModeAP, settings)
+	}
+	return nil
+}
+
+// Name returns the plugin identifier
+func (p *WiFiPlugin) Name() string {
+	return "wifi"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *WiFiPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/wifi")
+
+	api.Get("/", p.handleStatus)
+	api.Put("/ap", p.handleSetAP)
+	api.Post("/mode", p.handleSetMode)
+}
+
+// Shutdown leaves the interface in its current mode
+func (p *WiFiPlugin) Shutdown() error {
+	return nil
+}
+
+// Reload applies new paths, units and default AP settings
+// The interface is not switched; the new settings apply to the next switch.
+func (p *WiFiPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[WiFiConfig]("wifi", config)
+	if err != nil {
+		return err
+	}
+	cfg = normalizeWiFiConfig(cfg)
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.config = cfg
+	p.mu.Unlock()
+
+	slog.Info("Wi-Fi config reloaded", "interface", cfg.Interface, "address", cfg.Address)
+	return nil
+}
+
+// Validate checks the interface, address and units
+func (cfg WiFiConfig) Validate() error {
+	if cfg.Interface != "" && !wifiInterfacePattern.MatchString(cfg.Interface) {
+		return fmt.Errorf("invalid wifi.interface %q", cfg.Interface)
+	}
+	if cfg.Address != "" {
+		prefix, err := netip.ParsePrefix(cfg.Address)
+		if err != nil || !prefix.Addr().Is4() || prefix.Bits() > maxWiFiAddressBits {
+			return fmt.Errorf("wifi.address must be an IPv4 address with a prefix of /%d or shorter, e.g. %s", maxWiFiAddressBits, DefaultWiFiAddress)
+		}
+	}
+	for _, unit := range append(append([]string{}, cfg.APUnits...), cfg.ClientUnits...) {
+		if unit == "" || strings.ContainsAny(unit, "/ ") {
+			return fmt.Errorf("invalid unit name %q in wifi units", unit)
+		}
+	}
+	if cfg.AP.PSK != "" {
+		if err := cfg.AP.validate(); err != nil {
+			return fmt.Errorf("wifi.ap: %w", err)
+		}
+	}
+	return nil
+}
+
+// apSettings returns the saved AP settings, or the configured ones
+func (p *WiFiPlugin) apSettings() WiFiAPSettings {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.state.AP != nil {
+		return *p.state.AP
+	}
+	return p.config.AP
+}
+
+// status returns the current mode and settings
+func (p *WiFiPlugin) status() WiFiStatus {
+	settings := p.apSettings()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	status := WiFiStatus{
+		Mode:      p.state.Mode,
+		Switching: p.switching,
+		LastError: p.lastError,
+		Interface: p.config.Interface,
+		Address:   p.config.Address,
+		AP:        settings,
+		PSKSet:    settings.PSK != "",
+	}
+	status.AP.PSK = ""
+	if !p.state.Since.IsZero() {
+		since := p.state.Since
+		status.Since = &since
+	}
+	return status
+}
+
+// loadState restores the mode and AP settings from the state file
+func (p *WiFiPlugin) loadState() error {
+	data, err := os.ReadFile(p.config.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var state wifiState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("invalid Wi-Fi state file: %w", err)
+	}
+	if state.Mode != WiFiModeAP {
+		state.Mode = WiFiModeClient
+	}
+	p.state = state
+	return nil
+}
+
+// saveState persists the state; the file holds the passphrase and is private
+func (p *WiFiPlugin) saveState(state wifiState) error {
+	p.mu.RLock()
+	file := p.config.StateFile
+	p.mu.RUnlock()
+
+	data, _ := json.MarshalIndent(state, "", "  ")
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to persist Wi-Fi state: %w", err)
+	}
+	if err := writePrivateFile(file, data); err != nil {
+		return fmt.Errorf("failed to persist Wi-Fi state: %w", err)
+	}
+	return nil
+}
+
+// beginSwitch marks a switch to mode as running, or fails when one already is
+func (p *WiFiPlugin) beginSwitch(mode string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.switching != "" {
+		return errWiFiSwitching
+	}
+	p.switching = mode
+	return nil
+}
+
+// switchMode reconfigures the interface and records the outcome
+// Called with the switch marked as running.
+func (p *WiFiPlugin) switchMode(mode string, settings WiFiAPSettings) {
+	p.mu.RLock()
+	cfg := p.config
+	p.mu.RUnlock()
+
+	var err error
+	if mode == WiFiModeAP {
+		err = startAP(cfg, settings)
+	} else {
+		err = startClient(cfg)
+	}
+
+	// A failed switch leaves the access point stopped, so the device is a client
+	current := mode
+	if err != nil {
+		current = WiFiModeClient
+	}
+
+	p.mu.Lock()
+	p.switching = ""
+	p.lastError = ""
+	if err != nil {
+		p.lastError = err.Error()
+	}
+	if p.state.Mode != current {
+		p.state.Mode = current
+		p.state.Since = time.Now()
+	}
+	state := p.state
+	p.mu.Unlock()
+
+	if saveErr := p.saveState(state); saveErr != nil {
+		slog.Error("Failed to save Wi-Fi state", "error", saveErr)
+	}
+
+	event := fiber.Map{"mode": state.Mode, "requested": mode, "success": err == nil}
+	if err != nil {
+		event["error"] = err.Error()
+		slog.Error("Wi-Fi mode switch failed", "mode", mode, "error", err)
+	} else {
+		slog.Info("Wi-Fi mode switched", "mode", mode, "interface", cfg.Interface)
+	}
+	PublishEvent(wifiModeChangedEvent, wifiEventSource, event)
+}
+
+// handleStatus handles GET /api/wifi
+func (p *WiFiPlugin) handleStatus(c *fiber.Ctx) error {
+	return SendSuccess(c, p.status(), "")
+}
+
+// handleSetAP handles PUT /api/wifi/ap
+// Saves the access point settings; the passphrase may be omitted to keep the current one.
+// In AP mode the access point is restarted with the new settings.
+func (p *WiFiPlugin) handleSetAP(c *fiber.Ctx) error {
+	var req WiFiAPSettings
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	current := p.apSettings()
+	if req.PSK == "" {
+		req.PSK = current.PSK
+	}
+	if req.Channel == 0 {
+		req.Channel = current.Channel
+	}
+	if err := req.validate(); err != nil {
+		return SendError(c, 400, err)
+	}
+
+	// Settings are not changed under a running switch
+	if err := p.beginSwitch(WiFiModeAP); err != nil {
+		return SendError(c, 409, err)
+	}
+	p.mu.Lock()
+	state := p.state
+	state.AP = &req
+	restart := state.Mode == WiFiModeAP
+	p.mu.Unlock()
+
+	err := p.saveState(state)
+	p.mu.Lock()
+	if err == nil {
+		p.state.AP = &req
+	}
+	if err != nil || !restart {
+		p.switching = ""
+	}
+	p.mu.Unlock()
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+
+	slog.InfoContext(c.UserContext(), "Wi-Fi access point settings saved", "ssid", req.SSID, "channel", req.Channel)
+	if restart {
+		time.AfterFunc(wifiModeSwitchDelay, func() { p.switchMode(WiFiModeAP, req) })
+		return c.Status(202).JSON(APIResponse{
+			Success: true,
+			Data:    p.status(),
+			Message: "Access point settings saved, restarting the access point",
+		})
+	}
+	return SendSuccess(c, p.status(), "Access point settings saved")
+}
+
+// handleSetMode handles POST /api/wifi/mode
+// Body: {"mode": "ap"} or {"mode": "client"}. The switch starts shortly after
+// the response, which may be the last one a client on that interface receives.
+func (p *WiFiPlugin) handleSetMode(c *fiber.Ctx) error {
+	var req struct {
+		Mode string `json:"mode"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	if req.Mode != WiFiModeAP && req.Mode != WiFiModeClient {
+		return SendErrorMessage(c, 400, fmt.Sprintf("mode must be %s or %s", WiFiModeAP, WiFiModeClient))
+	}
+
+	settings := p.apSettings()
+	if req.Mode == WiFiModeAP {
+		if err := settings.validate(); err != nil {
+			return SendErrorMessage(c, 400, fmt.Sprintf("Access point settings are incomplete: %v", err))
+		}
+	}
+	if err := p.beginSwitch(req.Mode); err != nil {
+		return SendError(c, 409, err)
+	}
+
+	slog.WarnContext(c.UserContext(), "Wi-Fi mode switch requested", "mode", req.Mode, "client", c.IP())
+	time.AfterFunc(wifiModeSwitchDelay, func() { p.switchMode(req.Mode, settings) })
+
+	return c.Status(202).JSON(APIResponse{
+		Success: true,
+		Data:    p.status(),
+		Message: fmt.Sprintf("Switching Wi-Fi to %s mode", req.Mode),
+	})
+}
+
+// normalizeWiFiConfig fills in defaults
+func normalizeWiFiConfig(cfg WiFiConfig) WiFiConfig {
+	if cfg.Interface == "" {
+		cfg.Interface = DefaultWiFiInterface
+	}
+	if cfg.Address == "" {
+		cfg.Address = DefaultWiFiAddress
+	}
+	if cfg.HostapdConf == "" {
+		cfg.HostapdConf = DefaultHostapdConf
+	}
+	if cfg.DnsmasqConf == "" {
+		cfg.DnsmasqConf = DefaultDnsmasqConf
+	}
+	if cfg.StateFile == "" {
+		cfg.StateFile = DefaultWiFiStateFile
+	}
+	if cfg.APUnits == nil {
+		cfg.APUnits = []string{"hostapd", "dnsmasq"}
+	}
+	if cfg.ClientUnits == nil {
+		cfg.ClientUnits = []string{"wpa_supplicant"}
+	}
+	if cfg.AP.SSID == "" {
+		cfg.AP.SSID = DefaultWiFiSSID
+	}
+	if cfg.AP.Channel == 0 {
+		cfg.AP.Channel = DefaultWiFiChannel
+	}
+	return cfg
+}
+
+// Register the plugin
+func init() {
+	Register("wifi", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[WiFiConfig]("wifi", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewWiFiPlugin(cfg)
+	})
+}
@@ -0,0 +1,216 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// wifiCommandTimeout bounds each systemctl and ip call of a mode switch
+const wifiCommandTimeout = 30 * time.Second
+
+// hostapdTemplate is the access point configuration written to wifi.hostapd_conf
+var hostapdTemplate = template.Must(template.New("hostapd").Parse(`# Generated by the LinHT web manager; changes are overwritten
+interface={{.Interface}}
+driver=nl80211
+ssid={{.SSID}}
+hw_mode={{.HWMode}}
+channel={{.Channel}}
+{{- if .Country}}
+country_code={{.Country}}
+ieee80211d=1
+{{- end}}
+wmm_enabled=1
+auth_algs=1
+wpa=2
+wpa_key_mgmt=WPA-PSK
+rsn_pairwise=CCMP
+wpa_passphrase={{.PSK}}
+`))
+
+// dnsmasqTemplate serves DHCP to access point clients, written to wifi.dnsmasq_conf
+// The empty router option keeps phones from routing their internet traffic to the device.
+var dnsmasqTemplate = template.Must(template.New("dnsmasq").Parse(`# Generated by the LinHT web manager; changes are overwritten
+interface={{.Interface}}
+bind-interfaces
+dhcp-range={{.RangeStart}},{{.RangeEnd}},{{.Netmask}},12h
+dhcp-option=option:router
+`))
+
+// apTemplateData fills the hostapd and dnsmasq templates
+type apTemplateData struct {
+	WiFiAPSettings
+	Interface  string
+	HWMode     string
+	RangeStart string
+	RangeEnd   string
+	Netmask    string
+}
+
+// validate checks the access point settings against what hostapd accepts
+func (s WiFiAPSettings) validate() error {
+	if len(s.SSID) == 0 || len(s.SSID) > 32 {
+		return fmt.Errorf("ssid must be 1 to 32 bytes long")
+	}
+	if strings.IndexFunc(s.SSID, func(r rune) bool { return r < 0x20 || r == 0x7f }) >= 0 {
+		return fmt.Errorf("ssid must not contain control characters")
+	}
+	if wifiBand(s.Channel) == "" {
+		return fmt.Errorf("unsupported channel %d", s.Channel)
+	}
+	if len(s.PSK) < 8 || len(s.PSK) > 63 {
+		return fmt.Errorf("psk must be 8 to 63 characters long")
+	}
+	for _, r := range s.PSK {
+		if r < 0x20 || r > 0x7e {
+			return fmt.Errorf("psk must be printable ASCII")
+		}
+	}
+	if s.Country != "" && (len(s.Country) != 2 || strings.ToUpper(s.Country) != s.Country) {
+		return fmt.Errorf("country must be a two-letter code such as AT")
+	}
+	return nil
+}
+
+// wifiBand returns the hostapd hw_mode of a channel, or "" when it is not supported
+func wifiBand(channel int) string {
+	switch {
+	case channel >= 1 && channel <= 14:
+		return "g"
+	case channel >= 36 && channel <= 144 && channel%4 == 0,
+		channel >= 149 && channel <= 165 && channel%4 == 1:
+		return "a"
+	}
+	return ""
+}
+
+// dhcpRange returns the lease range and netmask for the access point network
+// Leases start at the 10th address and end at the 100th or before the broadcast address.
+func dhcpRange(prefix netip.Prefix) (start, end netip.Addr, netmask string) {
+	base := prefix.Masked().Addr().As4()
+	network := binary.BigEndian.Uint32(base[:])
+	size := uint32(1) << (32 - prefix.Bits())
+
+	addr := func(offset uint32) netip.Addr {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], network+offset)
+		return netip.AddrFrom4(b)
+	}
+	return addr(10), addr(min(100, size-2)), net.IP(net.CIDRMask(prefix.Bits(), 32)).String()
+}
+
+// renderAPConfig writes the hostapd and dnsmasq configuration files
+// Both are kept readable by root only, the hostapd file holds the passphrase.
+func renderAPConfig(cfg WiFiConfig, settings WiFiAPSettings) error {
+	prefix := netip.MustParsePrefix(cfg.Address)
+	start, end, netmask := dhcpRange(prefix)
+	data := apTemplateData{
+		WiFiAPSettings: settings,
+		Interface:      cfg.Interface,
+		HWMode:         wifiBand(settings.Channel),
+		RangeStart:     start.String(),
+		RangeEnd:       end.String(),
+		Netmask:        netmask,
+	}
+
+	for path, tmpl := range map[string]*template.Template{cfg.HostapdConf: hostapdTemplate, cfg.DnsmasqConf: dnsmasqTemplate} {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return err
+		}
+		if err := writePrivateFile(path, buf.Bytes()); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// writePrivateFile replaces a file atomically with mode 0600
+func writePrivateFile(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	file.Close()
+	if err := os.Chmod(path, 0600); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// startAP switches the interface to access point mode
+// When the access point does not come up, client mode is restored so the
+// device stays reachable the way it was.
+func startAP(cfg WiFiConfig, settings WiFiAPSettings) error {
+	if err := renderAPConfig(cfg, settings); err != nil {
+		return err
+	}
+
+	for _, unit := range cfg.ClientUnits {
+		if err := wifiCommand("systemctl", "stop", unitName(unit)); err != nil {
+			slog.Warn("Failed to stop Wi-Fi client unit", "unit", unit, "error", err)
+		}
+	}
+
+	err := wifiCommand("ip", "addr", "flush", "dev", cfg.Interface)
+	if err == nil {
+		err = wifiCommand("ip", "addr", "add", cfg.Address, "dev", cfg.Interface)
+	}
+	if err == nil {
+		err = wifiCommand("ip", "link", "set", cfg.Interface, "up")
+	}
+	for _, unit := range cfg.APUnits {
+		if err != nil {
+			break
+		}
+		err = wifiCommand("systemctl", "restart", unitName(unit))
+	}
+
+	if err != nil {
+		slog.Error("Access point failed to start, restoring client mode", "error", err)
+		if restoreErr := startClient(cfg); restoreErr != nil {
+			slog.Error("Failed to restore Wi-Fi client mode", "error", restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// startClient stops the access point and hands the interface back to the client units
+func startClient(cfg WiFiConfig) error {
+	for _, unit := range cfg.APUnits {
+		if err := wifiCommand("systemctl", "stop", unitName(unit)); err != nil {
+			slog.Warn("Failed to stop access point unit", "unit", unit, "error", err)
+		}
+	}
+	if err := wifiCommand("ip", "addr", "flush", "dev", cfg.Interface); err != nil {
+		return err
+	}
+	for _, unit := range cfg.ClientUnits {
+		if err := wifiCommand("systemctl", "restart", unitName(unit)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wifiCommand runs one step of a mode switch
+func wifiCommand(name string, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), wifiCommandTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,488 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// WireGuard defaults
+const (
+	DefaultWireGuardConfigDir = "/etc/wireguard"
+	wireGuardCommandTimeout   = 30 * time.Second
+	maxWireGuardConfSize      = 64 * 1024
+	wireGuardEventSource      = "wireguard"
+)
+
+// wireGuardNamePattern is the interface name rule of wg-quick
+var wireGuardNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_=+.-]{1,15}$`)
+
+// errWireGuardNotFound is returned for an interface without a configuration file
+var errWireGuardNotFound = errors.New("wireguard interface not found")
+
+// WireGuardConfig holds WireGuard plugin configuration
+type WireGuardConfig struct {
+	ConfigDir    string `yaml:"config_dir"`    // holds <interface>.conf files for wg-quick
+	AllowScripts bool   `yaml:"allow_scripts"` // accept PreUp/PostUp/PreDown/PostDown in imported configs
+}
+
+// WireGuardPeerStatus is a peer with its runtime counters
+type WireGuardPeerStatus struct {
+	PublicKey           string     `json:"public_key"`
+	Endpoint            string     `json:"endpoint,omitempty"`
+	AllowedIPs          []string   `json:"allowed_ips"`
+	LatestHandshake     *time.Time `json:"latest_handshake,omitempty"` // nil before the first handshake
+	TransferRx          uint64     `json:"transfer_rx"`
+	TransferTx          uint64     `json:"transfer_tx"`
+	PersistentKeepalive int        `json:"persistent_keepalive,omitempty"`
+}
+
+// WireGuardInterface describes a configured interface; keys other than public ones are never returned
+type WireGuardInterface struct {
+	Name       string                `json:"name"`
+	Up         bool                  `json:"up"`
+	PublicKey  string                `json:"public_key,omitempty"`
+	Address    []string              `json:"address"`
+	ListenPort int                   `json:"listen_port,omitempty"` // the bound port while up
+	DNS        []string              `json:"dns,omitempty"`
+	Peers      []WireGuardPeerStatus `json:"peers"`
+	Error      string                `json:"error,omitempty"` // the configuration file could not be read
+}
+
+// wireGuardCreateRequest is the body of POST /api/wireguard
+type wireGuardCreateRequest struct {
+	Name string `json:"name"`
+	WireGuardConf
+}
+
+// wireGuardImportRequest is the JSON body of POST /api/wireguard/import
+type wireGuardImportRequest struct {
+	Name   string `json:"name"`
+	Config string `json:"config"`
+}
+
+// WireGuardPlugin manages wg-quick interfaces
+type WireGuardPlugin struct {
+	mu     sync.Mutex // serializes file changes and wg-quick calls
+	config WireGuardConfig
+}
+
+// NewWireGuardPlugin creates a new WireGuard plugin instance
+func NewWireGuardPlugin(cfg WireGuardConfig) (*WireGuardPlugin, error) {
+	return &WireGuardPlugin{config: normalizeWireGuardConfig(cfg)}, nil
+}
+
+// Name returns the plugin identifier
+func (p *WireGuardPlugin) Name() string {
+	return "wireguard"
+}
+
+// RegisterRoutes adds the plugin's HTTP routes
+func (p *WireGuardPlugin) RegisterRoutes(app *fiber.App) {
+	api := APIGroup(app, "/wireguard")
+
+	api.Get("/", p.handleList)
+	api.Post("/", p.handleCreate)
+	api.Post("/import", p.handleImport)
+	api.Get("/:name", p.handleGet)
+	api.Delete("/:name", p.handleDelete)
+	api.Post("/:name/up", p.handleUp)
+	api.Post("/:name/down", p.handleDown)
+}
+
+// Shutdown leaves the tunnels running, they do not depend on the manager
+func (p *WireGuardPlugin) Shutdown() error {
+	return nil
+}
+
+// Reload applies a new configuration
+func (p *WireGuardPlugin) Reload(config interface{}) error {
+	cfg, err := configAs[WireGuardConfig]("wireguard", config)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.config = normalizeWireGuardConfig(cfg)
+	p.mu.Unlock()
+
+	slog.Info("WireGuard config reloaded", "config_dir", cfg.ConfigDir)
+	return nil
+}
+
+// confPath returns the configuration file of an interface
+func (p *WireGuardPlugin) confPath(name string) string {
+	return filepath.Join(p.config.ConfigDir, name+".conf")
+}
+
+// names returns the interfaces with a configuration file
+func (p *WireGuardPlugin) names() ([]string, error) {
+	entries, err := os.ReadDir(p.config.ConfigDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".conf")
+		if ok && !entry.IsDir() && wireGuardNamePattern.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// inspect reads an interface's configuration and, while it is up, its peer counters
+func (p *WireGuardPlugin) inspect(ctx context.Context, name string) (WireGuardInterface, error) {
+	iface := WireGuardInterface{Name: name, Address: []string{}, Peers: []WireGuardPeerStatus{}}
+
+	data, err := os.ReadFile(p.confPath(name))
+	if os.IsNotExist(err) {
+		return iface, errWireGuardNotFound
+	}
+	if err != nil {
+		iface.Error = err.Error()
+		return iface, nil
+	}
+	conf, err := parseWireGuardConf(string(data))
+	if err != nil {
+		iface.Error = err.Error()
+	} else {
+		iface.PublicKey, _ = wireGuardPublicKey(conf.PrivateKey)
+		iface.Address = conf.Address
+		iface.ListenPort = conf.ListenPort
+		iface.DNS = conf.DNS
+		for _, peer := range conf.Peers {
+			iface.Peers = append(iface.Peers, WireGuardPeerStatus{
+				PublicKey:           peer.PublicKey,
+				Endpoint:            peer.Endpoint,
+				AllowedIPs:          peer.AllowedIPs,
+				PersistentKeepalive: peer.PersistentKeepalive,
+			})
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, wireGuardCommandTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "wg", "show", name, "dump").Output()
+	if err != nil {
+		// wg fails for interfaces that do not exist, i.e. are down
+		return iface, nil
+	}
+	iface.Up = true
+	iface.ListenPort, iface.Peers = parseWireGuardDump(string(output))
+	return iface, nil
+}
+
+// parseWireGuardDump parses `wg show <interface> dump`
+// The first line describes the interface, every further line a peer; fields are tab separated.
+func parseWireGuardDump(dump string) (int, []WireGuardPeerStatus) {
+	peers := []WireGuardPeerStatus{}
+	lines := strings.Split(strings.TrimSpace(dump), "\n")
+
+	listenPort := 0
+	if fields := strings.Split(lines[0], "\t"); len(fields) >= 3 {
+		listenPort, _ = strconv.Atoi(fields[2])
+	}
+
+	for _, line := range lines[1:] {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 8 {
+			continue
+		}
+		peer := WireGuardPeerStatus{PublicKey: fields[0], AllowedIPs: []string{}}
+		if fields[2] != "(none)" {
+			peer.Endpoint = fields[2]
+		}
+		if fields[3] != "(none)" {
+			peer.AllowedIPs = splitList(fields[3])
+		}
+		if seconds, _ := strconv.ParseInt(fields[4], 10, 64); seconds > 0 {
+			handshake := time.Unix(seconds, 0)
+			peer.LatestHandshake = &handshake
+		}
+		peer.TransferRx, _ = strconv.ParseUint(fields[5], 10, 64)
+		peer.TransferTx, _ = strconv.ParseUint(fields[6], 10, 64)
+		peer.PersistentKeepalive, _ = strconv.Atoi(fields[7]) // "off" leaves 0
+		peers = append(peers, peer)
+	}
+	return listenPort, peers
+}
+
+// saveConf writes a configuration file readable by root only
+// An existing interface is only replaced when overwrite is set.
+func (p *WireGuardPlugin) saveConf(name string, data []byte, overwrite bool) error {
+	path := p.confPath(name)
+	if _, err := os.Stat(path); err == nil && !overwrite {
+		return fiber.NewError(409, fmt.Sprintf("WireGuard interface %s already exists", name))
+	}
+	if err := os.MkdirAll(p.config.ConfigDir, 0700); err != nil {
+		return err
+	}
+	return writePrivateFile(path, data)
+}
+
+// wgQuick brings an interface up or down
+func (p *WireGuardPlugin) wgQuick(ctx context.Context, action, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, wireGuardCommandTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "wg-quick", action, p.confPath(name)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("wg-quick %s %s: %v: %s", action, name, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// wireGuardName returns the validated :name parameter
+func wireGuardName(c *fiber.Ctx) (string, error) {
+	name := c.Params("name")
+	if !wireGuardNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid interface name %q", name)
+	}
+	return name, nil
+}
+
+// sendWireGuardError maps errors to status codes
+func sendWireGuardError(c *fiber.Ctx, err error) error {
+	var fiberErr *fiber.Error
+	switch {
+	case errors.Is(err, errWireGuardNotFound):
+		return SendError(c, 404, err)
+	case errors.As(err, &fiberErr):
+		return SendErrorMessage(c, fiberErr.Code, fiberErr.Message)
+	}
+	return SendError(c, 500, err)
+}
+
+// handleList handles GET /api/wireguard
+func (p *WireGuardPlugin) handleList(c *fiber.Ctx) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	names, err := p.names()
+	if err != nil {
+		return SendError(c, 500, err)
+	}
+	interfaces := make([]WireGuardInterface, 0, len(names))
+	for _, name := range names {
+		iface, err := p.inspect(c.UserContext(), name)
+		if err != nil {
+			continue
+		}
+		interfaces = append(interfaces, iface)
+	}
+	return SendSuccess(c, interfaces, "")
+}
+
+// handleGet handles GET /api/wireguard/:name
+func (p *WireGuardPlugin) handleGet(c *fiber.Ctx) error {
+	name, err := wireGuardName(c)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	iface, err := p.inspect(c.UserContext(), name)
+	if err != nil {
+		return sendWireGuardError(c, err)
+	}
+	return SendSuccess(c, iface, "")
+}
+
+// handleCreate handles POST /api/wireguard
+// Writes a configuration from its fields; a private key is generated when
+// none is given. ?overwrite=true replaces an existing interface.
+func (p *WireGuardPlugin) handleCreate(c *fiber.Ctx) error {
+	var req wireGuardCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+	if !wireGuardNamePattern.MatchString(req.Name) {
+		return SendErrorMessage(c, 400, fmt.Sprintf("invalid interface name %q", req.Name))
+	}
+	if req.PrivateKey == "" {
+		key, err := generateWireGuardKey()
+		if err != nil {
+			return SendError(c, 500, err)
+		}
+		req.PrivateKey = key
+	}
+	if err := req.normalize(); err != nil {
+		return SendError(c, 400, err)
+	}
+
+	return p.store(c, req.Name, []byte(req.render()), "created")
+}
+
+// handleImport handles POST /api/wireguard/import
+// Accepts a wg-quick file as multipart upload (field "file", optional "name"
+// defaulting to the file name) or as JSON {"name": "...", "config": "..."}.
+func (p *WireGuardPlugin) handleImport(c *fiber.Ctx) error {
+	var req wireGuardImportRequest
+	if file, err := c.FormFile("file"); err == nil {
+		if file.Size > maxWireGuardConfSize {
+			return SendErrorMessage(c, 400, "Configuration file too large")
+		}
+		src, err := file.Open()
+		if err != nil {
+			return SendErrorMessage(c, 500, "Failed to open file")
+		}
+		data, err := io.ReadAll(io.LimitReader(src, maxWireGuardConfSize))
+		src.Close()
+		if err != nil {
+			return SendError(c, 500, err)
+		}
+		req.Config = string(data)
+		req.Name = c.FormValue("name", strings.TrimSuffix(filepath.Base(file.Filename), ".conf"))
+	} else if err := c.BodyParser(&req); err != nil {
+		return SendErrorMessage(c, 400, "Invalid request body")
+	}
+
+	if !wireGuardNamePattern.MatchString(req.Name) {
+		return SendErrorMessage(c, 400, fmt.Sprintf("invalid interface name %q", req.Name))
+	}
+	if len(req.Config) > maxWireGuardConfSize {
+		return SendErrorMessage(c, 400, "Configuration file too large")
+	}
+	conf, err := parseWireGuardConf(req.Config)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+	if conf.scripts && !p.getConfig().AllowScripts {
+		return SendErrorMessage(c, 400, "The configuration runs PreUp/PostUp/PreDown/PostDown commands; set wireguard.allow_scripts to import it")
+	}
+
+	return p.store(c, req.Name, []byte(req.Config), "imported")
+}
+
+// store saves a validated configuration and responds with the interface
+func (p *WireGuardPlugin) store(c *fiber.Ctx, name string, data []byte, action string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.saveConf(name, data, c.QueryBool("overwrite")); err != nil {
+		return sendWireGuardError(c, err)
+	}
+	iface, err := p.inspect(c.UserContext(), name)
+	if err != nil {
+		return sendWireGuardError(c, err)
+	}
+
+	slog.InfoContext(c.UserContext(), "WireGuard configuration "+action, "interface", name, "peers", len(iface.Peers))
+	return SendSuccess(c, iface, fmt.Sprintf("WireGuard interface %s %s", name, action))
+}
+
+// handleDelete handles DELETE /api/wireguard/:name
+// A running interface must be brought down first.
+func (p *WireGuardPlugin) handleDelete(c *fiber.Ctx) error {
+	name, err := wireGuardName(c)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	iface, err := p.inspect(c.UserContext(), name)
+	if err != nil {
+		return sendWireGuardError(c, err)
+	}
+	if iface.Up {
+		return SendErrorMessage(c, 409, fmt.Sprintf("WireGuard interface %s is up; bring it down first", name))
+	}
+	if err := os.Remove(p.confPath(name)); err != nil {
+		return SendError(c, 500, err)
+	}
+
+	slog.InfoContext(c.UserContext(), "WireGuard configuration deleted", "interface", name)
+	return SendSuccess(c, nil, fmt.Sprintf("WireGuard interface %s deleted", name))
+}
+
+// handleUp handles POST /api/wireguard/:name/up
+func (p *WireGuardPlugin) handleUp(c *fiber.Ctx) error {
+	return p.setState(c, true)
+}
+
+// handleDown handles POST /api/wireguard/:name/down
+func (p *WireGuardPlugin) handleDown(c *fiber.Ctx) error {
+	return p.setState(c, false)
+}
+
+// setState runs wg-quick unless the interface already is in the requested state
+func (p *WireGuardPlugin) setState(c *fiber.Ctx, up bool) error {
+	name, err := wireGuardName(c)
+	if err != nil {
+		return SendError(c, 400, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	iface, err := p.inspect(c.UserContext(), name)
+	if err != nil {
+		return sendWireGuardError(c, err)
+	}
+	action := "down"
+	if up {
+		action = "up"
+	}
+	if iface.Up == up {
+		return SendSuccess(c, iface, fmt.Sprintf("WireGuard interface %s is already %s", name, action))
+	}
+
+	if err := p.wgQuick(c.UserContext(), action, name); err != nil {
+		slog.ErrorContext(c.UserContext(), "WireGuard interface change failed", "interface", name, "action", action, "error", err)
+		return SendError(c, 500, err)
+	}
+	iface, _ = p.inspect(c.UserContext(), name)
+
+	slog.InfoContext(c.UserContext(), "WireGuard interface "+action, "interface", name, "client", c.IP())
+	PublishEvent("wireguard."+action, wireGuardEventSource, fiber.Map{"interface": name})
+	return SendSuccess(c, iface, fmt.Sprintf("WireGuard interface %s is %s", name, action))
+}
+
+// getConfig returns the current configuration
+func (p *WireGuardPlugin) getConfig() WireGuardConfig {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.config
+}
+
+// normalizeWireGuardConfig fills in defaults
+func normalizeWireGuardConfig(cfg WireGuardConfig) WireGuardConfig {
+	if cfg.ConfigDir == "" {
+		cfg.ConfigDir = DefaultWireGuardConfigDir
+	}
+	return cfg
+}
+
+// Register the plugin
+func init() {
+	Register("wireguard", func(config interface{}) (Plugin, error) {
+		cfg, err := configAs[WireGuardConfig]("wireguard", config)
+		if err != nil {
+			return nil, err
+		}
+		return NewWireGuardPlugin(cfg)
+	})
+}
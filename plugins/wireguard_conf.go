@@ -0,0 +1,333 @@
+package plugins
+
+import (
+	"bufio"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// wireGuardScriptKeys are wg-quick settings that run shell commands as root
+var wireGuardScriptKeys = map[string]bool{"preup": true, "postup": true, "predown": true, "postdown": true}
+
+// WireGuardPeer is a [Peer] section
+type WireGuardPeer struct {
+	PublicKey           string   `json:"public_key"`
+	PresharedKey        string   `json:"preshared_key,omitempty"` // accepted on create, never returned
+	Endpoint            string   `json:"endpoint,omitempty"`      // host:port
+	AllowedIPs          []string `json:"allowed_ips"`
+	PersistentKeepalive int      `json:"persistent_keepalive,omitempty"` // seconds
+}
+
+// WireGuardConf is a wg-quick configuration file
+type WireGuardConf struct {
+	PrivateKey string          `json:"private_key,omitempty"` // generated on create when empty
+	Address    []string        `json:"address"`
+	ListenPort int             `json:"listen_port,omitempty"`
+	DNS        []string        `json:"dns,omitempty"`
+	MTU        int             `json:"mtu,omitempty"`
+	Peers      []WireGuardPeer `json:"peers"`
+
+	scripts bool // has PreUp/PostUp/PreDown/PostDown
+}
+
+// generateWireGuardKey returns a new base64 private key
+func generateWireGuardKey() (string, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key.Bytes()), nil
+}
+
+// wireGuardPublicKey derives the base64 public key of a private key
+func wireGuardPublicKey(privateKey string) (string, error) {
+	raw, err := decodeWireGuardKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+// decodeWireGuardKey checks that a key is 32 bytes of base64
+func decodeWireGuardKey(key string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		return nil, fmt.Errorf("invalid key, expected 32 bytes of base64")
+	}
+	return raw, nil
+}
+
+// parseWireGuardConf parses and validates a wg-quick configuration
+// Settings it does not know (Table, FwMark, ...) are left to wg-quick.
+func parseWireGuardConf(text string) (WireGuardConf, error) {
+	var conf WireGuardConf
+	var peer *WireGuardPeer
+	section := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	line := 0
+	for scanner.Scan() {
+		line++
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") || strings.HasPrefix(entry, ";") {
+			continue
+		}
+		if strings.HasPrefix(entry, "[") {
+			section = strings.ToLower(strings.Trim(entry, "[]"))
+			switch section {
+			case "interface":
+			case "peer":
+				conf.Peers = append(conf.Peers, WireGuardPeer{})
+				peer = &conf.Peers[len(conf.Peers)-1]
+			default:
+				return conf, fmt.Errorf("line %d: unknown section %s", line, entry)
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || section == "" {
+			return conf, fmt.Errorf("line %d: expected Key = Value in a section", line)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		var err error
+		if section == "interface" {
+			err = conf.set(key, value)
+		} else {
+			err = peer.set(key, value)
+		}
+		if err != nil {
+			return conf, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return conf, err
+	}
+
+	if conf.PrivateKey == "" {
+		return conf, fmt.Errorf("[Interface] has no PrivateKey")
+	}
+	for i, peer := range conf.Peers {
+		if peer.PublicKey == "" {
+			return conf, fmt.Errorf("peer %d has no PublicKey", i+1)
+		}
+	}
+	return conf, nil
+}
+
+// set applies an [Interface] setting
+func (conf *WireGuardConf) set(key, value string) error {
+	switch key {
+	case "privatekey":
+		if _, err := decodeWireGuardKey(value); err != nil {
+			return fmt.Errorf("PrivateKey: %w", err)
+		}
+		conf.PrivateKey = value
+	case "address":
+		addresses, err := parsePrefixList(value, true)
+		if err != nil {
+			return fmt.Errorf("Address: %w", err)
+		}
+		conf.Address = append(conf.Address, addresses...)
+	case "listenport":
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("invalid ListenPort %q", value)
+		}
+		conf.ListenPort = port
+	case "dns":
+		conf.DNS = append(conf.DNS, splitList(value)...)
+	case "mtu":
+		mtu, err := strconv.Atoi(value)
+		if err != nil || mtu < 576 || mtu > 9000 {
+			return fmt.Errorf("invalid MTU %q", value)
+		}
+		conf.MTU = mtu
+	default:
+		if wireGuardScriptKeys[key] {
+			conf.scripts = true
+		}
+	}
+	return nil
+}
+
+// set applies a [Peer] setting
+func (peer *WireGuardPeer) set(key, value string) error {
+	switch key {
+	case "publickey":
+		if _, err := decodeWireGuardKey(value); err != nil {
+			return fmt.Errorf("PublicKey: %w", err)
+		}
+		peer.PublicKey = value
+	case "presharedkey":
+		if _, err := decodeWireGuardKey(value); err != nil {
+			return fmt.Errorf("PresharedKey: %w", err)
+		}
+		peer.PresharedKey = value
+	case "endpoint":
+		if err := validateEndpoint(value); err != nil {
+			return err
+		}
+		peer.Endpoint = value
+	case "allowedips":
+		prefixes, err := parsePrefixList(value, false)
+		if err != nil {
+			return fmt.Errorf("AllowedIPs: %w", err)
+		}
+		peer.AllowedIPs = append(peer.AllowedIPs, prefixes...)
+	case "persistentkeepalive":
+		if value == "off" {
+			return nil
+		}
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 || seconds > 65535 {
+			return fmt.Errorf("invalid PersistentKeepalive %q", value)
+		}
+		peer.PersistentKeepalive = seconds
+	default:
+		return fmt.Errorf("unknown peer setting %q", key)
+	}
+	return nil
+}
+
+// normalize validates a configuration built from an API request and masks its allowed IPs
+func (conf *WireGuardConf) normalize() error {
+	if _, err := decodeWireGuardKey(conf.PrivateKey); err != nil {
+		return fmt.Errorf("private_key: %w", err)
+	}
+	if len(conf.Address) == 0 {
+		return fmt.Errorf("address is required")
+	}
+	addresses, err := parsePrefixList(strings.Join(conf.Address, ","), true)
+	if err != nil {
+		return fmt.Errorf("address: %w", err)
+	}
+	conf.Address = addresses
+	if conf.ListenPort < 0 || conf.ListenPort > 65535 {
+		return fmt.Errorf("invalid listen_port %d", conf.ListenPort)
+	}
+	for _, dns := range conf.DNS {
+		if dns == "" || strings.ContainsAny(dns, " ,\n") {
+			return fmt.Errorf("invalid dns entry %q", dns)
+		}
+	}
+	if conf.MTU != 0 && (conf.MTU < 576 || conf.MTU > 9000) {
+		return fmt.Errorf("invalid mtu %d", conf.MTU)
+	}
+	for i := range conf.Peers {
+		peer := &conf.Peers[i]
+		if _, err := decodeWireGuardKey(peer.PublicKey); err != nil {
+			return fmt.Errorf("peer %d: public_key: %w", i+1, err)
+		}
+		if peer.PresharedKey != "" {
+			if _, err := decodeWireGuardKey(peer.PresharedKey); err != nil {
+				return fmt.Errorf("peer %d: preshared_key: %w", i+1, err)
+			}
+		}
+		if peer.Endpoint != "" {
+			if err := validateEndpoint(peer.Endpoint); err != nil {
+				return fmt.Errorf("peer %d: %w", i+1, err)
+			}
+		}
+		if len(peer.AllowedIPs) == 0 {
+			return fmt.Errorf("peer %d: allowed_ips is required", i+1)
+		}
+		allowed, err := parsePrefixList(strings.Join(peer.AllowedIPs, ","), false)
+		if err != nil {
+			return fmt.Errorf("peer %d: allowed_ips: %w", i+1, err)
+		}
+		peer.AllowedIPs = allowed
+		if peer.PersistentKeepalive < 0 || peer.PersistentKeepalive > 65535 {
+			return fmt.Errorf("peer %d: invalid persistent_keepalive", i+1)
+		}
+	}
+	return nil
+}
+
+// render writes the configuration in wg-quick format
+func (conf WireGuardConf) render() string {
+	var b strings.Builder
+	b.WriteString("# Generated by the LinHT web manager\n[Interface]\n")
+	fmt.Fprintf(&b, "PrivateKey = %s\n", conf.PrivateKey)
+	fmt.Fprintf(&b, "Address = %s\n", strings.Join(conf.Address, ", "))
+	if conf.ListenPort > 0 {
+		fmt.Fprintf(&b, "ListenPort = %d\n", conf.ListenPort)
+	}
+	if len(conf.DNS) > 0 {
+		fmt.Fprintf(&b, "DNS = %s\n", strings.Join(conf.DNS, ", "))
+	}
+	if conf.MTU > 0 {
+		fmt.Fprintf(&b, "MTU = %d\n", conf.MTU)
+	}
+	for _, peer := range conf.Peers {
+		b.WriteString("\n[Peer]\n")
+		fmt.Fprintf(&b, "PublicKey = %s\n", peer.PublicKey)
+		if peer.PresharedKey != "" {
+			fmt.Fprintf(&b, "PresharedKey = %s\n", peer.PresharedKey)
+		}
+		if peer.Endpoint != "" {
+			fmt.Fprintf(&b, "Endpoint = %s\n", peer.Endpoint)
+		}
+		fmt.Fprintf(&b, "AllowedIPs = %s\n", strings.Join(peer.AllowedIPs, ", "))
+		if peer.PersistentKeepalive > 0 {
+			fmt.Fprintf(&b, "PersistentKeepalive = %d\n", peer.PersistentKeepalive)
+		}
+	}
+	return b.String()
+}
+
+// parsePrefixList parses a comma separated list of prefixes
+// Interface addresses keep their host part (10.0.0.2/24); allowed IPs are masked.
+func parsePrefixList(value string, host bool) ([]string, error) {
+	var prefixes []string
+	for _, item := range splitList(value) {
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(item)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid prefix %q", item)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		if !host {
+			prefix = prefix.Masked()
+		}
+		prefixes = append(prefixes, prefix.String())
+	}
+	return prefixes, nil
+}
+
+// splitList splits a comma separated setting
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// validateEndpoint checks a host:port peer endpoint
+func validateEndpoint(endpoint string) error {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil || host == "" || strings.ContainsAny(host, " \n") {
+		return fmt.Errorf("invalid endpoint %q, expected host:port", endpoint)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid endpoint port in %q", endpoint)
+	}
+	return nil
+}
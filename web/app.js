@@ -33,6 +33,7 @@ function setupEventListeners() {
     // Images
     document.getElementById('refresh-images').addEventListener('click', loadImages);
     document.getElementById('import-file').addEventListener('change', handleImageImport);
+    document.getElementById('build-file').addEventListener('change', handleImageBuild);
     
     // Containers
     document.getElementById('refresh-containers').addEventListener('click', loadContainers);
@@ -72,9 +73,16 @@ function switchTab(tabName) {
         containers: loadContainers,
         files: () => FileManager.init(),
         cps: () => CPS.init(),
-        services: () => Services.init()
+        services: () => Services.init(),
+        config: () => ConfigEditor.init()
     };
     
+    if (tabName === 'containers') {
+        watchContainerEvents();
+    } else {
+        unwatchContainerEvents();
+    }
+    
     const loader = dataLoaders[tabName];
     if (loader) {
         loader();
@@ -136,7 +144,7 @@ function renderImage(image) {
         <div class="card">
             <div class="card-info">
                 <div class="card-title">${tags}</div>
-                <div class="card-meta">Size: ${size} • Created: ${created}</div>
+                <div class="card-meta">Size: ${size} • Created: ${created}${restart}${limits}</div>
             </div>
             <div class="card-actions">
                 <button class="btn" onclick="exportImage('${image.id}')">Export</button>
@@ -185,6 +193,52 @@ async function handleImageImport(e) {
     e.target.value = '';
 }
 
+async function handleImageBuild(e) {
+    const file = e.target.files[0];
+    e.target.value = '';
+    if (!file) return;
+    
+    const tag = prompt('Image tag (e.g. myapp:latest):');
+    if (!tag) return;
+    
+    const formData = new FormData();
+    formData.append('file', file);
+    formData.append('tag', tag);
+    
+    showToast(`Building ${tag}...`);
+    await withLoading('Building Docker image...', async () => {
+        try {
+            const response = await api('/api/images/build', { method: 'POST', body: formData });
+            if (!response.ok) {
+                let errorMessage = 'Failed to build image';
+                try {
+                    const data = await response.json();
+                    errorMessage = data.error || errorMessage;
+                } catch (e) { /* ignore */ }
+                showToast(errorMessage, 'error');
+                return;
+            }
+            
+            // Read the SSE stream until the build finishes
+            let result = null;
+            await readEventStream(response, (type, data) => {
+                if (type === 'done') result = { ok: true, data: JSON.parse(data) };
+                else if (type === 'error') result = { ok: false, data: JSON.parse(data) };
+                else console.log(data);
+            });
+            
+            if (result && result.ok) {
+                showToast(`Image ${result.data.tags.join(', ')} built successfully`, 'success');
+                loadImages();
+            } else {
+                showToast((result && result.data.error) || 'Build ended unexpectedly', 'error');
+            }
+        } catch (error) {
+            showToast(`Failed to build image: ${error.message}`, 'error');
+        }
+    });
+}
+
 async function exportImage(imageId) {
     await withLoading('Exporting Docker image...', async () => {
         try {
@@ -222,21 +276,56 @@ async function loadContainers() {
     const container = document.getElementById('containers-list');
     container.innerHTML = '<div class="loading">Loading containers...</div>';
     
-    await withLoading('Fetching Docker containers...', async () => {
-        try {
-            const response = await api('/api/containers');
-            const data = await response.json();
-            
-            if (data.success && data.data.length > 0) {
-                container.innerHTML = data.data.map(renderContainer).join('');
-            } else {
-                container.innerHTML = '<div class="empty">No containers found</div>';
-            }
-        } catch (error) {
-            container.innerHTML = '<div class="empty">Failed to load containers</div>';
-            showToast('Failed to load containers', 'error');
+    await withLoading('Fetching Docker containers...', renderContainerList);
+}
+
+async function renderContainerList() {
+    const container = document.getElementById('containers-list');
+    try {
+        const response = await api('/api/containers?limits=1');
+        const data = await response.json();
+        
+        if (data.success && data.data.length > 0) {
+            container.innerHTML = data.data.map(renderContainer).join('');
+        } else {
+            container.innerHTML = '<div class="empty">No containers found</div>';
         }
+    } catch (error) {
+        container.innerHTML = '<div class="empty">Failed to load containers</div>';
+        showToast('Failed to load containers', 'error');
+    }
+}
+
+// Live container updates via Docker events
+let dockerEventSource = null;
+let dockerEventTimer = null;
+
+function watchContainerEvents() {
+    if (dockerEventSource) return;
+    
+    dockerEventSource = new EventSource('/api/docker/events?type=container');
+    dockerEventSource.addEventListener('container', () => {
+        // Coalesce bursts (e.g. stop emits kill, die and stop) into one refresh
+        clearTimeout(dockerEventTimer);
+        dockerEventTimer = setTimeout(renderContainerList, 300);
     });
+    // EventSource reconnects on its own; resync once the stream is back
+    let interrupted = false;
+    dockerEventSource.addEventListener('error', () => { interrupted = true; });
+    dockerEventSource.addEventListener('ready', () => {
+        if (interrupted) {
+            interrupted = false;
+            renderContainerList();
+        }
+    });
+}
+
+function unwatchContainerEvents() {
+    clearTimeout(dockerEventTimer);
+    if (dockerEventSource) {
+        dockerEventSource.close();
+        dockerEventSource = null;
+    }
 }
 
 function renderContainer(container) {
@@ -245,16 +334,35 @@ function renderContainer(container) {
     const state = container.state.toLowerCase();
     const created = new Date(container.created).toLocaleString();
     
-    const actions = state === 'running'
-        ? `<button class="btn" onclick="viewLogs('${container.id}')">Logs</button>
-           <button class="btn btn-danger" onclick="stopContainer('${container.id}')">Stop</button>`
-        : `<button class="btn btn-success" onclick="startContainer('${container.id}')">Start</button>
+    const health = container.health && container.health !== 'none'
+        ? ` <span class="status status-${container.health}" title="Show healthcheck results" onclick="showContainerHealth('${container.id}')">${container.health}</span>`
+        : '';
+    
+    const limits = formatLimits(container.limits);
+    const restart = container.restart_policy && container.restart_policy !== 'no'
+        ? ` • Restart: ${container.restart_policy}` : '';
+    const managed = container.managed
+        ? ` <span class="status" title="Started by the manager after boot">boot #${container.managed.order}</span>`
+        : '';
+    
+    let actions;
+    if (state === 'running') {
+        actions = `<button class="btn" onclick="viewLogs('${container.id}')">Logs</button>
+           <button class="btn" onclick="restartContainer('${container.id}')">Restart</button>
+           <button class="btn" onclick="pauseContainer('${container.id}')">Pause</button>
+           <button class="btn btn-danger" onclick="stopContainer('${container.id}')">Stop</button>`;
+    } else if (state === 'paused') {
+        actions = `<button class="btn btn-success" onclick="unpauseContainer('${container.id}')">Unpause</button>
+           <button class="btn btn-danger" onclick="stopContainer('${container.id}')">Stop</button>`;
+    } else {
+        actions = `<button class="btn btn-success" onclick="startContainer('${container.id}')">Start</button>
            <button class="btn btn-danger" onclick="deleteContainer('${container.id}')">Delete</button>`;
+    }
     
     return `
         <div class="card">
             <div class="card-info">
-                <div class="card-title">${name} <span class="status status-${state}">${state}</span></div>
+                <div class="card-title">${name} <span class="status status-${state}">${state}</span>${health}${managed}</div>
                 <div class="card-meta">Image: ${container.image} • ${container.status} • Created: ${created}</div>
             </div>
             <div class="card-actions">${actions}</div>
@@ -262,6 +370,15 @@ function renderContainer(container) {
     `;
 }
 
+function formatLimits(limits) {
+    if (!limits) return '';
+    const parts = [];
+    if (limits.memory > 0) parts.push(`Mem ${formatBytes(limits.memory)}`);
+    if (limits.cpus > 0) parts.push(`CPUs ${limits.cpus}`);
+    if (limits.pids_limit > 0) parts.push(`PIDs ${limits.pids_limit}`);
+    return parts.length > 0 ? ` • Limits: ${parts.join(', ')}` : ' • No limits';
+}
+
 async function openCreateModal() {
     document.getElementById('create-container-modal').classList.remove('hidden');
     await populateImageDropdown();
@@ -304,19 +421,49 @@ async function handleCreateContainer(e) {
     const envText = document.getElementById('container-env').value;
     const cmdText = document.getElementById('container-cmd').value;
     
+    const healthcheckCmd = document.getElementById('container-healthcheck').value.trim();
+    const healthcheckInterval = parseInt(document.getElementById('container-healthcheck-interval').value, 10);
+    
     const env = envText.trim() ? envText.split('\n').filter(line => line.trim()) : [];
     const cmd = cmdText.trim() ? cmdText.split(' ').filter(part => part.trim()) : [];
+    const healthcheck = healthcheckCmd
+        ? { command: healthcheckCmd, interval: healthcheckInterval || 0 }
+        : undefined;
+    const restart_policy = document.getElementById('container-restart').value;
+    const limits = {
+        memory: document.getElementById('container-memory').value.trim(),
+        cpus: parseFloat(document.getElementById('container-cpus').value) || 0,
+        pids_limit: parseInt(document.getElementById('container-pids-limit').value, 10) || 0
+    };
     
     await apiCall('Creating Docker container...', '/api/containers', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ image, name, env, cmd })
+        body: JSON.stringify({ image, name, env, cmd, healthcheck, limits, restart_policy })
     }, 'Container created successfully', () => {
         closeCreateModal();
         loadContainers();
     });
 }
 
+async function showContainerHealth(containerId) {
+    try {
+        const response = await api(`/api/containers/${containerId}/health`);
+        const data = await response.json();
+        if (!data.success) {
+            showToast(data.error || 'Failed to load healthcheck results', 'error');
+            return;
+        }
+        
+        const probes = data.data.probes.map(probe =>
+            `${new Date(probe.start).toLocaleTimeString()} exit=${probe.exit_code} ${probe.output.trim()}`);
+        alert(`Health: ${data.data.status} (failing streak ${data.data.failing_streak})\n\n` +
+            (probes.length ? probes.join('\n') : 'No probe results yet'));
+    } catch (error) {
+        showToast(`Failed to load healthcheck results: ${error.message}`, 'error');
+    }
+}
+
 async function startContainer(containerId) {
     await apiCall('Starting Docker container...', `/api/containers/${containerId}/start`,
         { method: 'POST' }, 'Container started', loadContainers);
@@ -327,6 +474,21 @@ async function stopContainer(containerId) {
         { method: 'POST' }, 'Container stopped', loadContainers);
 }
 
+async function restartContainer(containerId) {
+    await apiCall('Restarting Docker container...', `/api/containers/${containerId}/restart`,
+        { method: 'POST' }, 'Container restarted', loadContainers);
+}
+
+async function pauseContainer(containerId) {
+    await apiCall('Pausing Docker container...', `/api/containers/${containerId}/pause`,
+        { method: 'POST' }, 'Container paused', loadContainers);
+}
+
+async function unpauseContainer(containerId) {
+    await apiCall('Unpausing Docker container...', `/api/containers/${containerId}/unpause`,
+        { method: 'POST' }, 'Container unpaused', loadContainers);
+}
+
 async function deleteContainer(containerId) {
     if (!confirm('Are you sure you want to delete this container?')) return;
     
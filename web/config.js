@@ -0,0 +1,74 @@
+// Config
+// Editor for the manager's own config.yaml with diff preview
+
+const ConfigEditor = {
+    initialized: false,
+
+    init() {
+        if (!this.initialized) {
+            this.setupEventListeners();
+            this.initialized = true;
+        }
+    },
+
+    setupEventListeners() {
+        document.getElementById('config-load-btn').addEventListener('click', () => this.loadConfig());
+        document.getElementById('config-preview-btn').addEventListener('click', () => this.previewConfig());
+        document.getElementById('config-save-btn').addEventListener('click', () => this.saveConfig());
+    },
+
+    async loadConfig() {
+        await apiCall('Loading configuration...', '/api/config/file', {}, null, (data) => {
+            document.getElementById('config-editor').value = data.data.content;
+            document.getElementById('config-diff').textContent = '';
+        });
+    },
+
+    async previewConfig() {
+        const content = document.getElementById('config-editor').value;
+        await apiCall('Computing diff...', '/api/config/preview', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ content })
+        }, null, (data) => {
+            this.renderDiff(data.data.diff);
+            if (!data.data.valid) {
+                showToast(`Invalid configuration: ${data.data.error}`, 'error');
+            }
+        });
+    },
+
+    async saveConfig() {
+        if (!confirm('Save configuration? The previous file will be backed up.')) {
+            return;
+        }
+
+        const content = document.getElementById('config-editor').value;
+        await apiCall('Saving configuration...', '/api/config/save', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ content })
+        }, 'Configuration saved', (data) => {
+            const restart = data.data.reload.restart_required || [];
+            if (restart.length > 0) {
+                showToast(`Restart required for: ${restart.join(', ')}`, 'info');
+            }
+            return this.loadConfig();
+        });
+    },
+
+    renderDiff(diff) {
+        const container = document.getElementById('config-diff');
+        if (!diff) {
+            container.innerHTML = '<div class="log-line">No changes</div>';
+            return;
+        }
+
+        container.innerHTML = diff.split('\n').map(line => {
+            let cls = 'log-line';
+            if (line.startsWith('+')) cls += ' diff-add';
+            else if (line.startsWith('-')) cls += ' diff-del';
+            return `<div class="${cls}">${escapeHtml(line)}</div>`;
+        }).join('');
+    }
+};
@@ -3,6 +3,7 @@
 
 const CPS = {
     settings: null,
+    revision: null, // ETag of the loaded settings, sent back as If-Match
     initialized: false,
 
     init() {
@@ -28,6 +29,7 @@ const CPS = {
 
             if (data.success) {
                 this.settings = data.data;
+                this.revision = response.headers.get('ETag');
                 this.renderForm();
                 showToast('Settings loaded successfully', 'success');
             } else {
@@ -51,18 +53,35 @@ const CPS = {
         // Collect values from form
         this.collectFormValues();
 
+        const headers = { 'Content-Type': 'application/json' };
+        if (this.revision) {
+            headers['If-Match'] = this.revision;
+        }
+
+        let overwrite = false;
         showLoading('Saving settings...');
         try {
             const response = await api('/api/cps/save', {
                 method: 'POST',
-                headers: { 'Content-Type': 'application/json' },
+                headers,
                 body: JSON.stringify(this.settings)
             });
 
             const data = await response.json();
 
             if (data.success) {
-                showToast('Settings saved successfully', 'success');
+                this.revision = data.data?.revision || null;
+                const failed = (data.data?.hooks || []).filter(hook => !hook.success);
+                showToast(data.message || 'Settings saved successfully', failed.length ? 'error' : 'success');
+            } else if (response.status === 409) {
+                // Someone else saved since we loaded; retry against their revision only if asked to
+                hideLoading();
+                overwrite = confirm('The settings were changed elsewhere since you loaded them. Overwrite them with your edits?');
+                if (overwrite) {
+                    this.revision = data.data?.revision || null;
+                } else {
+                    showToast('Save cancelled. Load the settings again to see the current values.', 'error');
+                }
             } else {
                 showToast(data.error || 'Failed to save settings', 'error');
             }
@@ -71,6 +90,10 @@ const CPS = {
         } finally {
             hideLoading();
         }
+
+        if (overwrite) {
+            await this.saveSettings();
+        }
     },
 
     renderForm() {
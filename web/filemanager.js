@@ -20,6 +20,11 @@ const FileManager = {
             this.showCreateFolderDialog();
         });
         
+        // New link button
+        document.getElementById('fm-symlink-btn').addEventListener('click', () => {
+            this.createSymlink();
+        });
+        
         // Upload button
         document.getElementById('fm-upload-btn').addEventListener('click', () => {
             document.getElementById('fm-upload-input').click();
@@ -32,6 +37,26 @@ const FileManager = {
             }
         });
         
+        // Fetch URL button
+        document.getElementById('fm-fetch-btn').addEventListener('click', () => {
+            this.fetchUrl();
+        });
+        
+        // Search button
+        document.getElementById('fm-search-btn').addEventListener('click', () => {
+            this.search();
+        });
+        
+        // Trash button
+        document.getElementById('fm-trash-btn').addEventListener('click', () => {
+            this.loadTrash();
+        });
+        
+        // Removable media button
+        document.getElementById('fm-media-btn').addEventListener('click', () => {
+            this.loadMedia();
+        });
+        
         // Refresh button
         document.getElementById('fm-refresh-btn').addEventListener('click', () => {
             this.loadDirectory(this.currentPath);
@@ -100,7 +125,7 @@ const FileManager = {
     
     // Render a single file row
     renderFileRow(item) {
-        const icon = item.isDir ? '📁' : '📄';
+        const icon = item.isSymlink ? '🔗' : (item.isDir ? '📁' : '📄');
         const size = item.isDir ? '-' : formatBytes(item.size);
         const modified = new Date(item.modified).toLocaleString();
         const nameClass = item.isDir ? 'fm-folder-name' : 'fm-file-name';
@@ -114,10 +139,12 @@ const FileManager = {
                     <span class="${nameClass}" onclick="${onclick}">
                         ${icon} ${escapeHtml(item.name)}
                     </span>
+                    ${item.isSymlink ? `<span class="fm-link-target${item.broken ? ' broken' : ''}" title="${item.broken ? 'Broken link' : 'Symbolic link'}">→ ${escapeHtml(item.target)}</span>` : ''}
                 </td>
                 <td>${size}</td>
                 <td>${modified}</td>
                 <td>
+                    ${item.isDir ? '' : `<button class="btn btn-sm" onclick="FileManager.hashFile('${escapeHtml(item.path)}')">Hash</button>`}
                     <button class="btn btn-sm btn-danger" onclick="FileManager.deleteItem('${escapeHtml(item.path)}', '${escapeHtml(item.name)}')">
                         Delete
                     </button>
@@ -126,6 +153,183 @@ const FileManager = {
         `;
     },
     
+    // Search below the current directory by glob (e.g. *.iq) or name fragment
+    async search() {
+        const query = prompt(`Search in ${this.currentPath} (glob like *.iq or part of a name):`);
+        if (!query) return;
+        
+        const param = /[*?[]/.test(query) ? 'pattern' : 'name';
+        const tbody = document.getElementById('fm-file-list');
+        
+        showLoading('Searching...');
+        try {
+            const response = await api(`/api/filemanager/search?path=${encodeURIComponent(this.currentPath)}&${param}=${encodeURIComponent(query)}`);
+            const data = await response.json();
+            
+            if (!data.success) {
+                showToast(data.error || 'Search failed', 'error');
+                return;
+            }
+            
+            const result = data.data;
+            document.getElementById('fm-breadcrumb').innerHTML =
+                `<span class="fm-breadcrumb-item" onclick="FileManager.loadDirectory('${escapeHtml(this.currentPath)}')">${escapeHtml(this.currentPath)}</span>` +
+                `<span class="fm-breadcrumb-current"> / search "${escapeHtml(query)}" (${result.items.length}${result.truncated ? '+' : ''} found)</span>`;
+            
+            if (result.items.length === 0) {
+                tbody.innerHTML = '<tr><td colspan="3" class="empty">No matches</td></tr>';
+            } else {
+                // Show full paths since results span directories
+                tbody.innerHTML = result.items
+                    .map(item => this.renderFileRow({ ...item, name: item.path }))
+                    .join('');
+            }
+            if (result.timed_out) {
+                showToast('Search timed out, results are incomplete', 'info');
+            }
+        } catch (error) {
+            showToast('Search failed', 'error');
+        } finally {
+            hideLoading();
+        }
+    },
+    
+    // Show items in the trash
+    async loadTrash() {
+        const tbody = document.getElementById('fm-file-list');
+        
+        showLoading('Loading trash...');
+        try {
+            const response = await api('/api/filemanager/trash');
+            const data = await response.json();
+            
+            if (!data.success) {
+                showToast(data.error || 'Failed to load trash', 'error');
+                return;
+            }
+            
+            document.getElementById('fm-breadcrumb').innerHTML =
+                `<span class="fm-breadcrumb-item" onclick="FileManager.loadDirectory('${escapeHtml(this.currentPath)}')">${escapeHtml(this.currentPath)}</span>` +
+                '<span class="fm-breadcrumb-current"> / trash</span>' +
+                (data.data.length ? ' <button class="btn btn-sm btn-danger" onclick="FileManager.purgeTrash()">Empty Trash</button>' : '');
+            
+            if (data.data.length === 0) {
+                tbody.innerHTML = '<tr><td colspan="3" class="empty">Trash is empty</td></tr>';
+                return;
+            }
+            
+            tbody.innerHTML = data.data.map(item => `
+                <tr>
+                    <td>${item.isDir ? '📁' : '📄'} ${escapeHtml(item.original_path)}</td>
+                    <td>${item.isDir ? '-' : formatBytes(item.size)}</td>
+                    <td>Deleted ${new Date(item.deleted_at).toLocaleString()}</td>
+                    <td>
+                        <button class="btn btn-sm" onclick="FileManager.restoreItem('${escapeHtml(item.id)}')">Restore</button>
+                        <button class="btn btn-sm btn-danger" onclick="FileManager.purgeTrash('${escapeHtml(item.id)}')">Delete Forever</button>
+                    </td>
+                </tr>
+            `).join('');
+        } catch (error) {
+            showToast('Failed to load trash', 'error');
+        } finally {
+            hideLoading();
+        }
+    },
+    
+    // Restore an item from the trash to its original location
+    async restoreItem(id) {
+        await apiCall('Restoring...', '/api/filemanager/trash/restore', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ id })
+        }, 'Restored successfully', () => this.loadTrash());
+    },
+    
+    // Permanently delete one trash item, or everything when no ID is given
+    async purgeTrash(id) {
+        const message = id ? 'Permanently delete this item?' : 'Permanently delete everything in the trash?';
+        if (!confirm(message)) return;
+        
+        const url = id ? `/api/filemanager/trash?id=${encodeURIComponent(id)}` : '/api/filemanager/trash';
+        await apiCall('Deleting...', url, { method: 'DELETE' },
+            id ? 'Deleted permanently' : 'Trash emptied', () => this.loadTrash());
+    },
+    
+    // Show removable media with mount/unmount actions
+    async loadMedia() {
+        const tbody = document.getElementById('fm-file-list');
+        
+        showLoading('Scanning devices...');
+        try {
+            const response = await api('/api/storage/devices?removable=true');
+            const data = await response.json();
+            
+            if (!data.success) {
+                showToast(data.error || 'Failed to list devices', 'error');
+                return;
+            }
+            
+            document.getElementById('fm-breadcrumb').innerHTML =
+                `<span class="fm-breadcrumb-item" onclick="FileManager.loadDirectory('${escapeHtml(this.currentPath)}')">${escapeHtml(this.currentPath)}</span>` +
+                '<span class="fm-breadcrumb-current"> / removable media</span>';
+            
+            // Flatten disks and their partitions, keeping those with a filesystem
+            const volumes = [];
+            const collect = (device) => {
+                if (device.fstype) volumes.push(device);
+                (device.children || []).forEach(collect);
+            };
+            data.data.devices.forEach(collect);
+            
+            if (volumes.length === 0) {
+                tbody.innerHTML = '<tr><td colspan="3" class="empty">No removable media found</td></tr>';
+                return;
+            }
+            
+            tbody.innerHTML = volumes.map(volume => {
+                const name = volume.label || volume.name;
+                const action = volume.mountpoint
+                    ? (volume.managed
+                        ? `<button class="btn btn-sm" onclick="FileManager.unmountMedia('${escapeHtml(volume.path)}')">Eject</button>`
+                        : '')
+                    : `<button class="btn btn-sm btn-primary" onclick="FileManager.mountMedia('${escapeHtml(volume.path)}')">Mount</button>`;
+                const label = volume.mountpoint
+                    ? `<span class="fm-folder-name" onclick="FileManager.loadDirectory('${escapeHtml(volume.mountpoint)}')">💾 ${escapeHtml(name)} (${escapeHtml(volume.mountpoint)})</span>`
+                    : `💾 ${escapeHtml(name)} (${escapeHtml(volume.path)})`;
+                return `
+                    <tr>
+                        <td>${label}</td>
+                        <td>${formatBytes(volume.size)}</td>
+                        <td>${escapeHtml(volume.fstype)}${volume.model ? ' • ' + escapeHtml(volume.model) : ''}</td>
+                        <td>${action}</td>
+                    </tr>
+                `;
+            }).join('');
+        } catch (error) {
+            showToast('Failed to list devices', 'error');
+        } finally {
+            hideLoading();
+        }
+    },
+    
+    // Mount a removable volume and open it
+    async mountMedia(device) {
+        await apiCall('Mounting...', '/api/storage/mount', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ device })
+        }, 'Mounted', (data) => this.loadDirectory(data.data.mountpoint));
+    },
+    
+    // Unmount a volume so it can be removed safely
+    async unmountMedia(device) {
+        await apiCall('Unmounting...', '/api/storage/unmount', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ device })
+        }, 'Safe to remove', () => this.loadMedia());
+    },
+    
     // Update breadcrumb navigation
     updateBreadcrumb(path) {
         const breadcrumb = document.getElementById('fm-breadcrumb');
@@ -166,12 +370,13 @@ const FileManager = {
     },
     
     // Upload file
-    async uploadFile(file) {
+    async uploadFile(file, overwrite = false) {
         showLoading('Uploading file...');
         
         const formData = new FormData();
         formData.append('file', file);
         formData.append('path', this.currentPath);
+        if (overwrite) formData.append('overwrite', 'true');
         
         try {
             const response = await api('/api/filemanager/upload', {
@@ -181,7 +386,12 @@ const FileManager = {
             
             const data = await response.json();
             
-            if (data.success) {
+            if (response.status === 409 && !overwrite && data.error && data.error.includes('already exists')) {
+                hideLoading();
+                if (confirm(`${file.name} already exists in ${this.currentPath}. Replace it?`)) {
+                    await this.uploadFile(file, true);
+                }
+            } else if (data.success) {
                 showToast('File uploaded successfully', 'success');
                 this.loadDirectory(this.currentPath);
             } else {
@@ -196,6 +406,61 @@ const FileManager = {
         }
     },
     
+    // Create a symbolic link in the current directory
+    async createSymlink() {
+        const target = prompt('Link target (absolute or relative path):');
+        if (!target) return;
+        const name = prompt('Link name:', target.split('/').filter(Boolean).pop() || '');
+        if (!name) return;
+        
+        const path = this.currentPath === '/' 
+            ? `/${name}` 
+            : `${this.currentPath}/${name}`;
+        await apiCall('Creating link...', '/api/filemanager/symlink', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ path, target })
+        }, 'Link created', () => this.loadDirectory(this.currentPath));
+    },
+    
+    // Download a file from a URL straight into the current directory
+    async fetchUrl() {
+        const url = prompt(`Download URL into ${this.currentPath}:`, 'https://');
+        if (!url || url === 'https://') return;
+        
+        showLoading('Downloading...');
+        try {
+            const response = await api('/api/filemanager/fetch?stream=true', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ url, path: this.currentPath })
+            });
+            if (!response.ok) {
+                const data = await response.json();
+                showToast(data.error || 'Failed to download', 'error');
+                return;
+            }
+            
+            await readEventStream(response, (type, data) => {
+                const payload = JSON.parse(data);
+                if (type === 'progress') {
+                    showLoading(payload.percent
+                        ? `Downloading... ${payload.percent.toFixed(0)}%`
+                        : `Downloading... ${formatBytes(payload.bytes)}`);
+                } else if (type === 'done') {
+                    showToast(`Saved ${payload.path}`, 'success');
+                    this.loadDirectory(this.currentPath);
+                } else if (type === 'error') {
+                    showToast(payload.error || 'Failed to download', 'error');
+                }
+            });
+        } catch (error) {
+            showToast('Failed to download', 'error');
+        } finally {
+            hideLoading();
+        }
+    },
+    
     // Download file
     async downloadFile(path) {
         showLoading('Downloading file...');
@@ -229,6 +494,32 @@ const FileManager = {
         }
     },
     
+    // Compute a file checksum, showing progress for large files
+    hashFile(path) {
+        const algo = prompt('Algorithm (md5, sha1, sha256):', 'sha256');
+        if (!algo) return;
+        
+        showLoading('Hashing file...');
+        const source = new EventSource(`/api/filemanager/hash?path=${encodeURIComponent(path)}&algo=${encodeURIComponent(algo)}&stream=true`);
+        
+        source.addEventListener('progress', (event) => {
+            const progress = JSON.parse(event.data);
+            showLoading(`Hashing file... ${progress.percent.toFixed(0)}%`);
+        });
+        source.addEventListener('done', (event) => {
+            source.close();
+            hideLoading();
+            const result = JSON.parse(event.data);
+            prompt(`${result.algorithm} of ${path.split('/').pop()}:`, result.hash);
+        });
+        source.addEventListener('error', (event) => {
+            source.close();
+            hideLoading();
+            const message = event.data ? JSON.parse(event.data).error : 'Failed to hash file';
+            showToast(message, 'error');
+        });
+    },
+    
     // Delete item
     async deleteItem(path, name) {
         if (!confirm(`Are you sure you want to delete "${name}"?`)) {
@@ -247,7 +538,7 @@ const FileManager = {
             const data = await response.json();
             
             if (data.success) {
-                showToast('Deleted successfully', 'success');
+                showToast(data.message || 'Deleted successfully', 'success');
                 this.loadDirectory(this.currentPath);
             } else {
                 showToast(data.error || 'Failed to delete', 'error');
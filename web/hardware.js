@@ -14,6 +14,7 @@ function initHardwareTab() {
     document.getElementById('hw-reset-btn').addEventListener('click', resetHardware);
     document.getElementById('hw-close-btn').addEventListener('click', closeHardware);
     document.getElementById('hw-refresh-btn').addEventListener('click', refreshHardwareStatus);
+    document.getElementById('hw-maintenance-btn').addEventListener('click', toggleMaintenance);
 
     // Control buttons
     document.getElementById('hw-set-mode-btn').addEventListener('click', setMode);
@@ -29,8 +30,165 @@ function initHardwareTab() {
     // Register viewer buttons
     document.getElementById('hw-read-all-regs-btn').addEventListener('click', readAllRegisters);
 
+    // I/Q recording buttons
+    document.getElementById('hw-capture-start-btn').addEventListener('click', startCapture);
+    document.getElementById('hw-capture-stop-btn').addEventListener('click', stopCapture);
+
+    // Test signal buttons
+    document.getElementById('hw-testsignal-start-btn').addEventListener('click', startTestSignal);
+    document.getElementById('hw-testsignal-stop-btn').addEventListener('click', stopTestSignal);
+
     // Check status on tab load
     refreshHardwareStatus();
+    refreshMaintenance();
+    refreshCapture();
+    refreshTestSignal();
+}
+
+// Start an I/Q recording
+async function startCapture() {
+    const duration = parseFloat(document.getElementById('hw-capture-duration').value);
+    const format = document.getElementById('hw-capture-format').value;
+    await apiCall('Starting recording...', '/api/hardware/capture/start', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify({duration, format})
+    }, 'Recording started', refreshCapture);
+}
+
+// Stop the running I/Q recording
+async function stopCapture() {
+    await apiCall('Stopping recording...', '/api/hardware/capture/stop', { method: 'POST' },
+        'Recording stopped', refreshCapture);
+}
+
+// Refresh recording status and list, polling while a recording runs
+let captureTimer = null;
+async function refreshCapture() {
+    clearTimeout(captureTimer);
+    try {
+        const response = await fetch('/api/hardware/capture/status');
+        const data = await response.json();
+        if (!data.success) return;
+
+        const status = data.data;
+        const statusEl = document.getElementById('hw-capture-status');
+        if (status.active) {
+            const elapsed = status.samples / status.sample_rate;
+            statusEl.textContent = `Recording ${status.name} (${elapsed.toFixed(1)}/${status.duration}s)`;
+            statusEl.className = 'hw-value status-error';
+            captureTimer = setTimeout(refreshCapture, 1000);
+        } else if (status.error) {
+            statusEl.textContent = `Failed: ${status.error}`;
+            statusEl.className = 'hw-value status-error';
+        } else {
+            statusEl.textContent = 'Idle';
+            statusEl.className = 'hw-value';
+        }
+
+        const listResponse = await fetch('/api/hardware/captures');
+        const list = await listResponse.json();
+        if (!list.success) return;
+
+        const tbody = document.getElementById('hw-capture-list');
+        if (list.data.files.length === 0) {
+            tbody.innerHTML = '<tr><td colspan="3" class="empty">No recordings</td></tr>';
+            return;
+        }
+        tbody.innerHTML = list.data.files.map(file => `
+            <tr>
+                <td><a href="${escapeHtml(file.download_url)}">${escapeHtml(file.name)}</a></td>
+                <td>${formatBytes(file.size)}</td>
+                <td>${new Date(file.modified).toLocaleString()}</td>
+            </tr>
+        `).join('');
+    } catch (error) {
+        console.error('Error loading recordings:', error);
+    }
+}
+
+// Start a CW or two-tone transmit test
+async function startTestSignal() {
+    const type = document.getElementById('hw-testsignal-type').value;
+    const duration = parseFloat(document.getElementById('hw-testsignal-duration').value);
+    const level = parseFloat(document.getElementById('hw-testsignal-level').value);
+    await apiCall('Starting test signal...', '/api/hardware/testsignal', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify({type, duration, level})
+    }, 'Test signal started', refreshTestSignal);
+}
+
+// Stop the active test signal
+async function stopTestSignal() {
+    await apiCall('Stopping test signal...', '/api/hardware/testsignal/stop', { method: 'POST' },
+        'Test signal stopped', refreshTestSignal);
+}
+
+// Refresh test signal status, polling while transmitting
+let testSignalTimer = null;
+async function refreshTestSignal() {
+    clearTimeout(testSignalTimer);
+    try {
+        const response = await fetch('/api/hardware/testsignal');
+        const data = await response.json();
+        if (!data.success) return;
+
+        const limits = data.data.limits;
+        document.getElementById('hw-testsignal-duration').max = limits.max_duration;
+        document.getElementById('hw-testsignal-level').max = limits.max_level;
+
+        const signal = data.data.signal;
+        const statusEl = document.getElementById('hw-testsignal-status');
+        if (signal && signal.active) {
+            statusEl.textContent = `Transmitting ${signal.type} on ${(signal.frequency / 1e6).toFixed(3)} MHz`;
+            statusEl.className = 'hw-value status-error';
+            testSignalTimer = setTimeout(refreshTestSignal, 1000);
+        } else if (signal && signal.error) {
+            statusEl.textContent = `Failed: ${signal.error}`;
+            statusEl.className = 'hw-value status-error';
+        } else {
+            statusEl.textContent = `Idle (max ${limits.max_duration}s, ${limits.max_level} dBFS)`;
+            statusEl.className = 'hw-value';
+        }
+    } catch (error) {
+        console.error('Error loading test signal status:', error);
+    }
+}
+
+// Maintenance mode blocks transmit operations
+let maintenanceEnabled = false;
+
+// Refresh the maintenance mode button
+async function refreshMaintenance() {
+    try {
+        const response = await fetch('/api/power/maintenance');
+        const data = await response.json();
+        if (data.success) {
+            maintenanceEnabled = data.data.enabled;
+            const btn = document.getElementById('hw-maintenance-btn');
+            btn.textContent = `Maintenance: ${maintenanceEnabled ? 'on' : 'off'}`;
+            btn.classList.toggle('btn-danger', maintenanceEnabled);
+            btn.title = data.data.reason || '';
+        }
+    } catch (error) {
+        console.error('Error loading maintenance mode:', error);
+    }
+}
+
+// Toggle maintenance mode
+async function toggleMaintenance() {
+    const enabled = !maintenanceEnabled;
+    let reason = '';
+    if (enabled) {
+        reason = prompt('Enable maintenance mode? Transmit operations will be blocked.\nReason (optional):', '');
+        if (reason === null) return;
+    }
+    await apiCall('Updating maintenance mode...', '/api/power/maintenance', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ enabled, reason })
+    }, enabled ? 'Maintenance mode enabled' : 'Maintenance mode disabled', refreshMaintenance);
 }
 
 // Initialize hardware
@@ -1,5 +1,5 @@
 // Services Module
-// Manages systemd services with the "linht-" prefix
+// Manages systemd services, timers and sockets with the "linht-" prefix
 
 const Services = {
     services: [],
@@ -16,6 +16,54 @@ const Services = {
 
     setupEventListeners() {
         document.getElementById('services-refresh-btn').addEventListener('click', () => this.loadServices());
+        document.getElementById('power-reboot-btn').addEventListener('click', () => this.schedulePower('reboot'));
+        document.getElementById('power-shutdown-btn').addEventListener('click', () => this.schedulePower('shutdown'));
+        document.getElementById('power-abort-btn').addEventListener('click', () => this.abortPower());
+        this.refreshPower();
+    },
+
+    // Schedule a reboot or shutdown; the delay leaves time to abort
+    async schedulePower(action) {
+        const delay = prompt(`${action === 'reboot' ? 'Reboot' : 'Shut down'} the device in how many seconds?`, '30');
+        if (delay === null) return;
+        const seconds = parseInt(delay, 10);
+        if (isNaN(seconds) || seconds < 0) {
+            showToast('Invalid delay', 'error');
+            return;
+        }
+        await apiCall('Scheduling...', `/api/power/${action}`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ delay: seconds })
+        }, null, (data) => {
+            showToast(data.message, 'info');
+            this.refreshPower();
+        });
+    },
+
+    async abortPower() {
+        await apiCall('Aborting...', '/api/power/abort', { method: 'POST' }, null, (data) => {
+            showToast(data.message, 'success');
+            this.refreshPower();
+        });
+    },
+
+    // Show the abort button while an action is pending
+    async refreshPower() {
+        try {
+            const response = await api('/api/power/status');
+            const data = await response.json();
+            const pending = data.success && data.data.pending;
+            const abortBtn = document.getElementById('power-abort-btn');
+            abortBtn.classList.toggle('hidden', !pending);
+            if (pending) {
+                abortBtn.textContent = `Abort ${pending.action} (${data.data.remaining}s)`;
+                clearTimeout(this.powerTimer);
+                this.powerTimer = setTimeout(() => this.refreshPower(), 1000);
+            }
+        } catch (error) {
+            console.error('Error loading power status:', error);
+        }
     },
 
     async loadServices() {
@@ -70,10 +118,15 @@ const Services = {
             ? `<button class="btn btn-sm" onclick="Services.disableService('${service.name}')">Disable</button>`
             : `<button class="btn btn-sm" onclick="Services.enableService('${service.name}')">Enable</button>`;
 
+        let description = escapeHtml(service.description || '-');
+        if (service.type === 'timer') {
+            description += `<br><small>Next: ${escapeHtml(service.next_elapse || 'n/a')}</small>`;
+        }
+
         return `
             <tr>
                 <td class="service-name">${service.name}</td>
-                <td class="service-description">${service.description || '-'}</td>
+                <td class="service-description">${description}</td>
                 <td><span class="status ${statusClass}">${statusText}</span></td>
                 <td><span class="status ${enabledClass}">${enabledText}</span></td>
                 <td class="service-actions">
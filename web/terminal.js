@@ -1,3 +1,11 @@
+// WebSocket frame kinds: the first byte of every binary message
+const FRAME_DATA = 0x00;
+const FRAME_CONTROL = 0x01;
+
+// Processed output is acknowledged in batches of at most this many bytes,
+// and of a quarter of the flow window the backend announces when it is smaller
+const ACK_BYTES = 16 * 1024;
+
 // Terminal component using xterm.js
 class WebShellTerminal {
     constructor() {
@@ -5,6 +13,15 @@ class WebShellTerminal {
         this.socket = null;
         this.fitAddon = null;
         this.sessionType = null;
+        this.sessionId = null;
+        this.closing = false;
+        this.reconnectAttempts = 0;
+        this.reconnectTimer = null;
+        this.unacked = 0;
+        this.ackBytes = ACK_BYTES;
+        this.paused = false;
+        this.encoder = new TextEncoder();
+        this.decoder = new TextDecoder();
     }
     
     // Initialize xterm.js terminal
@@ -65,6 +82,15 @@ class WebShellTerminal {
         };
         window.addEventListener('resize', this.resizeHandler);
         
+        // Handle terminal input (registered once, follows reconnects)
+        this.term.onData(data => {
+            this.sendFrame(FRAME_DATA, this.encoder.encode(data));
+        });
+        // Some mouse reports are raw bytes rather than UTF-8 text
+        this.term.onBinary(data => {
+            this.sendFrame(FRAME_DATA, Uint8Array.from(data, c => c.charCodeAt(0)));
+        });
+        
         return this;
     }
     
@@ -84,31 +110,50 @@ class WebShellTerminal {
         this.connect(wsUrl);
     }
     
+    // Reattach to the current session after a dropped connection
+    reattach() {
+        const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+        const wsUrl = `${protocol}//${location.host}/api/webshell/ws?session=${encodeURIComponent(this.sessionId)}`;
+        this.connect(wsUrl);
+    }
+    
     // Connect to WebSocket
     connect(url) {
         if (this.socket) {
+            this.socket.onclose = null;
             this.socket.close();
         }
 
+        this.closing = false;
+        // The backend starts flow control over for every connection
+        this.unacked = 0;
+        this.paused = false;
+        this.updatePauseButton();
         this.socket = new WebSocket(url);
+        this.socket.binaryType = 'arraybuffer';
         
         this.socket.onopen = () => {
-            this.term.write('\r\n\x1b[32m*** Connected ***\x1b[0m\r\n\r\n');
+            if (this.reconnectAttempts === 0) {
+                this.term.write('\r\n\x1b[32m*** Connected ***\x1b[0m\r\n\r\n');
+            }
             
             // Send initial resize
             this.sendResize();
-            
-            // Handle terminal input
-            this.term.onData(data => {
-                if (this.socket && this.socket.readyState === WebSocket.OPEN) {
-                    this.socket.send(data);
-                }
-            });
         };
         
         this.socket.onmessage = (event) => {
-            if (this.term) {
-                this.term.write(event.data);
+            const frame = new Uint8Array(event.data);
+            if (frame.length === 0) {
+                return;
+            }
+            if (frame[0] === FRAME_CONTROL) {
+                this.handleControl(JSON.parse(this.decoder.decode(frame.subarray(1))));
+                return;
+            }
+            if (frame[0] === FRAME_DATA && this.term) {
+                const data = frame.subarray(1);
+                // Acknowledge once xterm.js has rendered the output
+                this.term.write(data, () => this.acknowledge(data.length));
             }
         };
         
@@ -119,31 +164,137 @@ class WebShellTerminal {
             }
         };
         
-        this.socket.onclose = () => {
-            if (this.term) {
-                this.term.write('\r\n\x1b[33m*** Connection Closed ***\x1b[0m\r\n');
+        this.socket.onclose = (event) => {
+            if (!this.term) {
+                return;
+            }
+            // Try to reattach after an unexpected drop
+            if (!this.closing && this.sessionId && event.code !== 1000 && this.reconnectAttempts < 10) {
+                const delay = Math.min(1000 * 2 ** this.reconnectAttempts, 10000);
+                this.reconnectAttempts++;
+                this.term.write(`\r\n\x1b[33m*** Connection lost, reconnecting in ${delay / 1000}s ***\x1b[0m\r\n`);
+                this.reconnectTimer = setTimeout(() => this.reattach(), delay);
+                return;
             }
+            this.sessionId = null;
+            this.term.write('\r\n\x1b[33m*** Connection Closed ***\x1b[0m\r\n');
         };
     }
     
+    // Send a binary frame to the backend
+    sendFrame(kind, payload) {
+        if (this.socket && this.socket.readyState === WebSocket.OPEN) {
+            const frame = new Uint8Array(payload.length + 1);
+            frame[0] = kind;
+            frame.set(payload, 1);
+            this.socket.send(frame);
+        }
+    }
+    
+    // Send a control message to the backend
+    sendControl(msg) {
+        this.sendFrame(FRAME_CONTROL, this.encoder.encode(JSON.stringify(msg)));
+    }
+    
+    // Report processed output so the backend keeps sending
+    acknowledge(bytes) {
+        this.unacked += bytes;
+        if (this.unacked >= this.ackBytes) {
+            this.sendControl({ type: 'ack', bytes: this.unacked });
+            this.unacked = 0;
+        }
+    }
+    
+    // Stop or restart terminal output; the shell blocks while paused
+    togglePause() {
+        this.paused = !this.paused;
+        this.sendControl({ type: this.paused ? 'pause' : 'resume' });
+        this.updatePauseButton();
+    }
+    
+    // Reflect the pause state in the toolbar
+    updatePauseButton() {
+        const button = document.getElementById('pause-terminal');
+        if (button) {
+            button.textContent = this.paused ? 'Resume' : 'Pause';
+        }
+    }
+    
+    // Handle session control messages from the backend
+    handleControl(msg) {
+        if (msg.type === 'transfer') {
+            this.handleTransfer(msg);
+            return;
+        }
+        if (msg.type === 'error') {
+            if (this.term) {
+                this.term.write(`\r\n\x1b[31m*** ${msg.error} ***\x1b[0m\r\n`);
+            }
+            return;
+        }
+        if (msg.type !== 'session') {
+            return;
+        }
+        this.sessionId = msg.id;
+        this.ackBytes = msg.flow_window > 0
+            ? Math.max(1, Math.min(ACK_BYTES, Math.floor(msg.flow_window / 4)))
+            : ACK_BYTES;
+        if (msg.reattached && this.term) {
+            // Scrollback replay follows; start from a clean screen
+            this.term.reset();
+        }
+        this.reconnectAttempts = 0;
+    }
+    
+    // Handle sz/rz (ZMODEM) transfer notifications
+    handleTransfer(msg) {
+        switch (msg.status) {
+            case 'select': {
+                // Remote rz is waiting; pick a file on the device to send
+                const path = prompt('File to send (path on device):', msg.dir ? `${msg.dir}/` : '/');
+                this.sendControl(path ? { type: 'transfer', path } : { type: 'transfer', cancel: true });
+                break;
+            }
+            case 'complete':
+                if (msg.direction === 'download') {
+                    showToast(`Received ${(msg.files || []).length} file(s) into ${msg.dir}`, 'success');
+                } else {
+                    showToast('File sent', 'success');
+                }
+                break;
+            case 'failed':
+                showToast(`File transfer failed: ${msg.error}`, 'error');
+                break;
+        }
+    }
+    
     // Send terminal resize to backend
     sendResize() {
-        if (this.socket && this.socket.readyState === WebSocket.OPEN && this.term) {
-            const resizeMsg = JSON.stringify({
+        if (this.term) {
+            this.sendControl({
                 type: 'resize',
                 rows: this.term.rows,
                 cols: this.term.cols
             });
-            this.socket.send(resizeMsg);
         }
     }
     
     // Disconnect and cleanup
     disconnect() {
+        this.closing = true;
+        if (this.reconnectTimer) {
+            clearTimeout(this.reconnectTimer);
+            this.reconnectTimer = null;
+        }
         if (this.socket) {
-            this.socket.close();
+            this.socket.close(1000);
             this.socket = null;
         }
+        // Explicit close ends the session instead of leaving it for reattach
+        if (this.sessionId) {
+            api(`/api/webshell/sessions/${encodeURIComponent(this.sessionId)}`, { method: 'DELETE' }).catch(() => {});
+            this.sessionId = null;
+        }
     }
     
     // Destroy terminal instance
@@ -228,6 +379,13 @@ function selectContainer(containerId) {
     currentTerminal.connectContainer(containerId);
 }
 
+// Pause or resume terminal output
+function togglePauseTerminal() {
+    if (currentTerminal) {
+        currentTerminal.togglePause();
+    }
+}
+
 // Close terminal
 function closeTerminal() {
     if (currentTerminal) {
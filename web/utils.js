@@ -27,6 +27,26 @@ async function withLoading(message, asyncFn) {
     }
 }
 
+// Read a Server-Sent Events response from fetch(), calling onEvent(type, data) per event
+// Needed for streamed POST requests, which EventSource cannot make
+async function readEventStream(response, onEvent) {
+    const reader = response.body.getReader();
+    const decoder = new TextDecoder();
+    let buffer = '';
+    while (true) {
+        const { done, value } = await reader.read();
+        if (done) break;
+        buffer += decoder.decode(value, { stream: true });
+        const events = buffer.split('\n\n');
+        buffer = events.pop();
+        for (const event of events) {
+            const type = (event.match(/^event: (.*)$/m) || [])[1] || 'message';
+            const data = (event.match(/^data: (.*)$/m) || [])[1];
+            if (data !== undefined) onEvent(type, data);
+        }
+    }
+}
+
 // Async API call wrapper with loading, error handling, and toast notifications
 // Usage: await apiCall('Loading...', '/api/endpoint', { method: 'POST' }, 'Success!', onSuccess)
 async function apiCall(loadingMsg, url, options, successMsg, onSuccess) {